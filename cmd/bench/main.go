@@ -0,0 +1,305 @@
+// Command bench runs the same workload through each queue backend and
+// reports throughput, latency percentiles and loss, to help choose a
+// backend for a deployment.
+//
+// Usage:
+//
+//	bench -backends memory
+//	bench -backends memory,redis,postgres,sqs -rate 500 -duration 30s -transient 10 \
+//	    -redis-url redis://localhost:6379/1 -postgres-url postgres://localhost/bench \
+//	    -sqs-queue-url http://localhost:4566/000000000000/bench -sqs-endpoint http://localhost:4566
+//
+// Events are queued at -rate per second for -duration, and processed by a
+// worker pool configured as the server's is. Each attempt takes -work and
+// fails transiently with probability -transient percent, so some events
+// are retried and a few dead-lettered once their retries run out. Queueing
+// stops after -duration, and the run ends once every event is accounted for
+// or -drain passes.
+//
+// Use scratch instances: the Postgres backend uses the server's
+// webhook_job_queue table, and runs against a queue holding other jobs
+// would process, and count, them too. The Redis backend uses its own
+// stream, which is deleted afterwards.
+//
+// Bench prints one JSON report per backend: events queued and processed,
+// throughput, queueing and end-to-end latency percentiles (end-to-end is
+// from queueing to the attempt that succeeded, including retry delays),
+// and loss: events dead-lettered, never accounted for, or processed twice.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"gusto-webhook-guide/internal/models"
+	"gusto-webhook-guide/internal/worker"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/redis/go-redis/v9"
+)
+
+// benchStream is the Redis stream the Redis backend queues jobs on, kept
+// apart from the server's.
+const benchStream = "bench:jobs"
+
+// workload is the standardized load applied to every backend.
+type workload struct {
+	rate       int           // Events queued per second.
+	duration   time.Duration // How long events are queued for.
+	transient  float64       // Percentage of attempts that fail transiently.
+	work       time.Duration // How long each attempt takes.
+	workers    int
+	queueSize  int // Capacity of the in-memory queue.
+	retryDelay time.Duration
+	drain      time.Duration // How long to wait for outstanding events once queueing stops.
+}
+
+// latencies summarizes a set of durations.
+type latencies struct {
+	P50 string `json:"p50"`
+	P90 string `json:"p90"`
+	P99 string `json:"p99"`
+	Max string `json:"max"`
+}
+
+// report is printed for each backend.
+type report struct {
+	Backend      string    `json:"backend"`
+	Queued       int       `json:"queued"`
+	QueueErrors  int       `json:"queue_errors"` // Events that couldn't be queued, e.g. because the queue was full.
+	Processed    int       `json:"processed"`    // Events that succeeded.
+	Throughput   float64   `json:"throughput"`   // Events processed per second, from the first queued to the last processed.
+	Attempts     int       `json:"attempts"`
+	QueueLatency latencies `json:"queue_latency"`
+	EndToEnd     latencies `json:"end_to_end_latency"`
+	DeadLettered int       `json:"dead_lettered"` // Events whose retries ran out.
+	Missing      int       `json:"missing"`       // Queued events neither processed nor dead-lettered by the end of the drain.
+	Duplicates   int       `json:"duplicates"`    // Events processed successfully more than once.
+	Error        string    `json:"error,omitempty"`
+}
+
+func main() {
+	backends := flag.String("backends", "memory", `comma-separated backends to compare: "memory", "redis", "postgres" and "sqs"`)
+	redisURL := flag.String("redis-url", "", "Redis URL for the redis backend")
+	postgresURL := flag.String("postgres-url", "", "Postgres connection string for the postgres backend")
+	sqsQueueURL := flag.String("sqs-queue-url", "", "queue URL for the sqs backend")
+	sqsEndpoint := flag.String("sqs-endpoint", "", "SQS endpoint, e.g. a local emulator")
+	var w workload
+	flag.IntVar(&w.rate, "rate", 200, "events queued per second")
+	flag.DurationVar(&w.duration, "duration", 10*time.Second, "how long to queue events for")
+	flag.Float64Var(&w.transient, "transient", 10, "percentage of attempts that fail transiently")
+	flag.DurationVar(&w.work, "work", 2*time.Millisecond, "how long each attempt takes")
+	flag.IntVar(&w.workers, "workers", 8, "number of workers")
+	flag.IntVar(&w.queueSize, "queue-size", 10000, "capacity of the in-memory queue")
+	flag.DurationVar(&w.retryDelay, "retry-delay", 100*time.Millisecond, "delay before retrying a transient failure")
+	flag.DurationVar(&w.drain, "drain", 30*time.Second, "how long to wait for outstanding events once queueing stops")
+	verbose := flag.Bool("v", false, "log the worker pool's activity")
+	flag.Parse()
+	if w.rate <= 0 || w.duration <= 0 || w.workers <= 0 || w.transient < 0 || w.transient >= 100 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	if *verbose {
+		logger = slog.New(slog.NewJSONHandler(os.Stderr, nil))
+	}
+
+	ctx := context.Background()
+	failed := false
+	for _, kind := range strings.Split(*backends, ",") {
+		kind = strings.TrimSpace(kind)
+		queue, closeQueue, err := openQueue(ctx, kind, *redisURL, *postgresURL, *sqsQueueURL, *sqsEndpoint)
+		if err != nil {
+			printJSON(report{Backend: kind, Error: err.Error()})
+			failed = true
+			continue
+		}
+		printJSON(run(ctx, logger, kind, queue, w))
+		closeQueue()
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// openQueue connects to a backend's queue. A nil queue is the pool's own
+// in-memory queue.
+func openQueue(ctx context.Context, kind, redisURL, postgresURL, sqsQueueURL, sqsEndpoint string) (worker.JobQueue, func(), error) {
+	switch kind {
+	case "memory":
+		return nil, func() {}, nil
+	case "redis":
+		opts, err := redis.ParseURL(redisURL)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid -redis-url: %w", err)
+		}
+		client := redis.NewClient(opts)
+		queue := worker.NewRedisStreamQueue(client, benchStream, "bench", "bench", time.Minute)
+		if err := queue.Migrate(ctx); err != nil {
+			client.Close()
+			return nil, nil, err
+		}
+		return queue, func() {
+			client.Del(ctx, benchStream)
+			client.Close()
+		}, nil
+	case "postgres":
+		db, err := sql.Open("pgx", postgresURL)
+		if err != nil {
+			return nil, nil, err
+		}
+		queue := worker.NewPostgresQueue(db, time.Minute)
+		if err := queue.Migrate(ctx); err != nil {
+			db.Close()
+			return nil, nil, err
+		}
+		return queue, func() { db.Close() }, nil
+	case "sqs":
+		if sqsQueueURL == "" {
+			return nil, nil, errors.New("the sqs backend requires -sqs-queue-url")
+		}
+		cfg, err := awsconfig.LoadDefaultConfig(ctx)
+		if err != nil {
+			return nil, nil, fmt.Errorf("loading AWS configuration: %w", err)
+		}
+		client := sqs.NewFromConfig(cfg, func(o *sqs.Options) {
+			if sqsEndpoint != "" {
+				o.BaseEndpoint = &sqsEndpoint
+			}
+		})
+		return worker.NewSQSQueue(client, sqsQueueURL, "", time.Minute), func() {}, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown backend %q", kind)
+	}
+}
+
+// tally records what happened to each event of a run.
+type tally struct {
+	mu        sync.Mutex
+	queuedAt  map[string]time.Time
+	processed map[string]int
+	enqueue   []time.Duration
+	endToEnd  []time.Duration
+	attempts  int
+	last      time.Time // When the last event was processed.
+}
+
+// run applies the workload to one backend.
+func run(ctx context.Context, logger *slog.Logger, kind string, queue worker.JobQueue, w workload) report {
+	t := &tally{queuedAt: make(map[string]time.Time), processed: make(map[string]int)}
+	processor := worker.ProcessorFunc(func(ctx context.Context, event models.WebhookEvent) error {
+		time.Sleep(w.work)
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		t.attempts++
+		if rand.Float64()*100 < w.transient {
+			return worker.Transientf("simulated transient failure")
+		}
+		t.processed[event.UUID]++
+		if t.processed[event.UUID] == 1 {
+			now := time.Now()
+			t.endToEnd = append(t.endToEnd, now.Sub(t.queuedAt[event.UUID]))
+			t.last = now
+		}
+		return nil
+	})
+
+	pool := worker.NewPool(w.queueSize, w.workers, logger, worker.NewIdempotencyStore(), processor)
+	if queue != nil {
+		pool.SetQueue(queue)
+	}
+	pool.SetRetryDelay(w.retryDelay)
+	pool.Start(w.workers)
+
+	rep := report{Backend: kind}
+	runID := time.Now().UTC().Format("20060102T150405.000")
+	start := time.Now()
+	interval := time.Second / time.Duration(w.rate)
+	for i := 0; time.Since(start) < w.duration; i++ {
+		if wait := time.Until(start.Add(time.Duration(i) * interval)); wait > 0 {
+			time.Sleep(wait)
+		}
+		eventUUID := fmt.Sprintf("bench-%s-%s-%d", kind, runID, i)
+		payload, _ := json.Marshal(models.WebhookEvent{UUID: eventUUID, EventType: "bench.event", ResourceType: "Company", ResourceUUID: eventUUID})
+		queuedAt := time.Now()
+		t.mu.Lock()
+		t.queuedAt[eventUUID] = queuedAt
+		t.mu.Unlock()
+		if err := pool.Queue().Enqueue(ctx, models.Job{Payload: payload, ReceivedAt: queuedAt.UTC()}, 0); err != nil {
+			rep.QueueErrors++
+			t.mu.Lock()
+			delete(t.queuedAt, eventUUID)
+			t.mu.Unlock()
+			continue
+		}
+		rep.Queued++
+		t.mu.Lock()
+		t.enqueue = append(t.enqueue, time.Since(queuedAt))
+		t.mu.Unlock()
+	}
+
+	// Wait until every queued event has been processed or dead-lettered.
+	deadline := time.Now().Add(w.drain)
+	for time.Now().Before(deadline) {
+		t.mu.Lock()
+		done := len(t.processed)
+		t.mu.Unlock()
+		if done+pool.DeadLetters().Len() >= rep.Queued {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	stopCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	pool.StopContext(stopCtx)
+	cancel()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	rep.Attempts = t.attempts
+	rep.Processed = len(t.processed)
+	rep.DeadLettered = pool.DeadLetters().Len()
+	rep.Missing = max(rep.Queued-rep.Processed-rep.DeadLettered, 0)
+	for _, n := range t.processed {
+		if n > 1 {
+			rep.Duplicates++
+		}
+	}
+	if elapsed := t.last.Sub(start); rep.Processed > 0 && elapsed > 0 {
+		rep.Throughput = float64(rep.Processed) / elapsed.Seconds()
+	}
+	rep.QueueLatency = summarize(t.enqueue)
+	rep.EndToEnd = summarize(t.endToEnd)
+	return rep
+}
+
+// summarize returns the percentiles of ds.
+func summarize(ds []time.Duration) latencies {
+	if len(ds) == 0 {
+		return latencies{}
+	}
+	sorted := slices.Sorted(slices.Values(ds))
+	at := func(p float64) string {
+		return sorted[min(int(p*float64(len(sorted))), len(sorted)-1)].Round(time.Microsecond).String()
+	}
+	return latencies{P50: at(0.50), P90: at(0.90), P99: at(0.99), Max: sorted[len(sorted)-1].Round(time.Microsecond).String()}
+}
+
+func printJSON(v any) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
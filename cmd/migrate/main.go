@@ -0,0 +1,278 @@
+// Command migrate moves queued jobs and dead letters, and copies idempotency
+// keys, from one storage backend to another, e.g. when moving from the
+// in-memory defaults to Redis or from Redis to Postgres.
+//
+// Usage:
+//
+//	migrate -from memory -queue-snapshot queue.json -keys-snapshot keys.json -to redis -to-url redis://localhost:6379/0
+//	migrate -from redis -from-url redis://localhost:6379/0 -to postgres -to-url postgres://...
+//
+// The in-memory backend is read from, or written to, snapshot files: a queue
+// snapshot from GET /admin/queue/snapshot (loaded with POST
+// /admin/queue/restore) and an idempotency snapshot (IDEMPOTENCY_SNAPSHOT_PATH).
+// Redis and Postgres use the same keys and tables as the server.
+//
+// Migrate prints how many items of each kind were read, written and verified,
+// and exits with status 1 if any count disagrees.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"gusto-webhook-guide/internal/models"
+	"gusto-webhook-guide/internal/worker"
+	"io/fs"
+	"os"
+	"strings"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/redis/go-redis/v9"
+)
+
+// migrateConsumer is the Redis consumer name used while taking jobs.
+const migrateConsumer = "migrate"
+
+// backend is one end of a migration.
+type backend struct {
+	kind     string // "memory", "redis" or "postgres".
+	queue    worker.JobQueue
+	keys     worker.Store
+	memQueue *memoryQueue             // Set for the memory backend.
+	memKeys  *worker.IdempotencyStore // Set for the memory backend.
+	close    func()
+}
+
+// report is printed once the migration finishes.
+type report struct {
+	Jobs        worker.MigrationCount `json:"jobs"`
+	DeadLetters worker.MigrationCount `json:"dead_letters"`
+	Keys        worker.MigrationCount `json:"keys"`
+}
+
+func main() {
+	from := flag.String("from", "", `source backend: "memory", "redis" or "postgres"`)
+	fromURL := flag.String("from-url", "", "source Redis URL or Postgres connection string")
+	to := flag.String("to", "", `target backend: "memory", "redis" or "postgres"`)
+	toURL := flag.String("to-url", "", "target Redis URL or Postgres connection string")
+	queueSnapshot := flag.String("queue-snapshot", "queue-snapshot.json", "queue snapshot file for the memory backend")
+	keysSnapshot := flag.String("keys-snapshot", "idempotency-snapshot.json", "idempotency snapshot file for the memory backend")
+	what := flag.String("what", "jobs,dead_letters,keys", "comma-separated items to migrate")
+	idle := flag.Duration("idle", 2*time.Second, "stop taking jobs once the source queue has been empty this long")
+	requeueDeadLetters := flag.Bool("requeue-dead-letters", false,
+		"queue dead letters as new jobs when the target has no dead-letter queue, instead of skipping them")
+	flag.Parse()
+	if *from == "" || *to == "" || (*from == *to && *fromURL == *toURL) {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx := context.Background()
+	src, err := openBackend(ctx, *from, *fromURL, *queueSnapshot, *keysSnapshot, true)
+	if err != nil {
+		fatal(fmt.Errorf("opening source: %w", err))
+	}
+	defer src.close()
+	dst, err := openBackend(ctx, *to, *toURL, *queueSnapshot, *keysSnapshot, false)
+	if err != nil {
+		fatal(fmt.Errorf("opening target: %w", err))
+	}
+	defer dst.close()
+
+	var rep report
+	items := strings.Split(*what, ",")
+	for _, item := range items {
+		switch strings.TrimSpace(item) {
+		case "jobs":
+			rep.Jobs, err = worker.MoveJobs(ctx, src.queue, dst.queue, *idle)
+		case "dead_letters":
+			rep.DeadLetters, err = moveDeadLetters(ctx, src, dst, *requeueDeadLetters)
+		case "keys":
+			lister, ok := src.keys.(worker.Lister)
+			if !ok {
+				err = fmt.Errorf("the %s idempotency store can't list its keys", src.kind)
+				break
+			}
+			rep.Keys, err = worker.CopyKeys(ctx, lister, dst.keys)
+		default:
+			err = fmt.Errorf("unknown item %q", item)
+		}
+		if err != nil {
+			printJSON(rep)
+			fatal(err)
+		}
+	}
+
+	if dst.memQueue != nil {
+		if err := dst.memQueue.save(*queueSnapshot); err != nil {
+			fatal(err)
+		}
+	}
+	if dst.memKeys != nil {
+		if err := dst.memKeys.SaveSnapshot(*keysSnapshot); err != nil {
+			fatal(err)
+		}
+	}
+	// Whatever was moved out of a memory source is gone from its snapshot.
+	if src.memQueue != nil && (rep.Jobs.Verified > 0 || rep.DeadLetters.Written > 0) {
+		if err := src.memQueue.save(*queueSnapshot); err != nil {
+			fatal(err)
+		}
+	}
+
+	printJSON(rep)
+	if !rep.Jobs.Complete() || !rep.DeadLetters.Complete() || !rep.Keys.Complete() {
+		fatal(errors.New("some items were not migrated; see the counts above"))
+	}
+}
+
+// openBackend connects to a backend. The memory backend's snapshot files
+// are loaded when it is the source.
+func openBackend(ctx context.Context, kind, url, queueSnapshot, keysSnapshot string, source bool) (*backend, error) {
+	b := &backend{kind: kind, close: func() {}}
+	switch kind {
+	case "memory":
+		b.memQueue = &memoryQueue{snap: worker.QueueSnapshot{Version: worker.QueueSnapshotVersion, TakenAt: time.Now().UTC()}}
+		b.memKeys = worker.NewIdempotencyStore()
+		if source {
+			if err := b.memQueue.load(queueSnapshot); err != nil {
+				return nil, err
+			}
+			if _, err := b.memKeys.LoadSnapshot(keysSnapshot); err != nil {
+				return nil, err
+			}
+		}
+		b.queue, b.keys = b.memQueue, b.memKeys
+	case "redis":
+		opts, err := redis.ParseURL(url)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Redis URL: %w", err)
+		}
+		client := redis.NewClient(opts)
+		b.close = func() { client.Close() }
+		queue := worker.NewRedisStreamQueue(client, "webhooks:jobs", "workers", migrateConsumer, 5*time.Minute)
+		if err := queue.Migrate(ctx); err != nil {
+			return nil, err
+		}
+		b.queue = queue
+		b.keys = worker.NewRedisStore(client, "webhooks:idempotency:", 0)
+	case "postgres":
+		db, err := sql.Open("pgx", url)
+		if err != nil {
+			return nil, err
+		}
+		b.close = func() { db.Close() }
+		queue := worker.NewPostgresQueue(db, 5*time.Minute)
+		if err := queue.Migrate(ctx); err != nil {
+			return nil, err
+		}
+		store := worker.NewPostgresStore(db)
+		if err := store.Migrate(ctx); err != nil {
+			return nil, err
+		}
+		b.queue, b.keys = queue, store
+	default:
+		return nil, fmt.Errorf("unknown backend %q", kind)
+	}
+	return b, nil
+}
+
+// moveDeadLetters moves dead letters between memory backends, the only ones
+// with a dead-letter queue. Dead letters bound for another backend are
+// queued as new jobs if requeue is set, and otherwise left unwritten.
+func moveDeadLetters(ctx context.Context, src, dst *backend, requeue bool) (worker.MigrationCount, error) {
+	var count worker.MigrationCount
+	if src.memQueue == nil {
+		return count, nil
+	}
+	deadLetters := src.memQueue.snap.DeadLetters
+	count.Read = len(deadLetters)
+	for _, dl := range deadLetters {
+		switch {
+		case dst.memQueue != nil:
+			dst.memQueue.snap.DeadLetters = append(dst.memQueue.snap.DeadLetters, dl)
+		case requeue:
+			if err := dst.queue.Enqueue(ctx, models.Job{Payload: dl.Payload}, -1); err != nil {
+				return count, fmt.Errorf("queueing dead letter %s: %w", dl.ID, err)
+			}
+		default:
+			fmt.Fprintf(os.Stderr, "warning: the %s backend has no dead-letter queue; skipping %d dead letters (see -requeue-dead-letters)\n",
+				dst.kind, len(deadLetters))
+			return count, nil
+		}
+		count.Written++
+		count.Verified++
+	}
+	src.memQueue.snap.DeadLetters = nil
+	return count, nil
+}
+
+// memoryQueue is a worker.JobQueue over a queue snapshot file. Retries are
+// handed out as pending jobs, since other backends have no delayed jobs.
+type memoryQueue struct {
+	snap worker.QueueSnapshot
+}
+
+// load reads the snapshot at path. A missing file is an empty queue.
+func (q *memoryQueue) load(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading queue snapshot: %w", err)
+	}
+	if err := json.Unmarshal(data, &q.snap); err != nil {
+		return fmt.Errorf("decoding queue snapshot: %w", err)
+	}
+	q.snap.Pending = append(q.snap.Pending, q.snap.Retries...)
+	q.snap.Retries = nil
+	return nil
+}
+
+// save writes the snapshot to path.
+func (q *memoryQueue) save(path string) error {
+	data, err := json.Marshal(q.snap)
+	if err != nil {
+		return fmt.Errorf("encoding queue snapshot: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return fmt.Errorf("writing queue snapshot: %w", err)
+	}
+	return nil
+}
+
+// Enqueue implements worker.JobQueue.
+func (q *memoryQueue) Enqueue(_ context.Context, job models.Job, _ time.Duration) error {
+	q.snap.Pending = append(q.snap.Pending, worker.SnapshotJob{Payload: job.Payload, Attempts: job.Attempts, Priority: job.Priority, ReceivedAt: job.ReceivedAt})
+	return nil
+}
+
+// Dequeue implements worker.JobQueue, returning worker.ErrQueueClosed once
+// every job has been handed out. A job leaves the snapshot when acked.
+func (q *memoryQueue) Dequeue(context.Context) (models.Job, func() error, error) {
+	if len(q.snap.Pending) == 0 {
+		return models.Job{}, nil, worker.ErrQueueClosed
+	}
+	j := q.snap.Pending[0]
+	ack := func() error {
+		q.snap.Pending = q.snap.Pending[1:]
+		return nil
+	}
+	return models.Job{Payload: j.Payload, Attempts: j.Attempts, Priority: j.Priority, ReceivedAt: j.ReceivedAt}, ack, nil
+}
+
+func printJSON(v any) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "error:", err)
+	os.Exit(1)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"gusto-webhook-guide/internal/middleware"
+	"gusto-webhook-guide/internal/providers/gusto"
+	"io"
+	"log/slog"
+	"maps"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+)
+
+// devDefaults are the settings --dev applies where they aren't set already:
+// secrets, so that nothing is disabled for lack of one, and short timings,
+// so that retries and restarts don't hold up iterating.
+var devDefaults = map[string]string{
+	"ADMIN_TOKEN":              "dev-admin-token",
+	"GUSTO_API_TOKEN":          "dev-api-token",
+	"GUSTO_VERIFICATION_TOKEN": "dev-verification-token",
+	"RETRY_DELAY":              "1s",
+	"SHUTDOWN_GRACE":           "1s",
+}
+
+// devBackends select durable or shared backends. --dev ignores them, so that
+// everything is kept in memory and nothing needs to be running locally.
+var devBackends = []string{
+	"CLAIM_LOCK",
+	"IDEMPOTENCY_FALLBACK_STORE",
+	"IDEMPOTENCY_PREVIOUS_STORE",
+	"IDEMPOTENCY_STORE",
+	"JOB_QUEUE",
+	"REDIS_URL",
+	"SCHEDULE_STORE",
+}
+
+// applyDevMode adjusts the environment for --dev and writes a banner to w
+// listing what it changed, so that a developer setup can't be mistaken for
+// a production one.
+func applyDevMode(w io.Writer) {
+	var lines []string
+	for _, key := range slices.Sorted(maps.Keys(devDefaults)) {
+		if os.Getenv(key) == "" {
+			os.Setenv(key, devDefaults[key])
+			lines = append(lines, fmt.Sprintf("%s=%s", key, devDefaults[key]))
+		}
+	}
+	for _, key := range devBackends {
+		if os.Getenv(key) != "" {
+			os.Unsetenv(key)
+			lines = append(lines, fmt.Sprintf("%s ignored, using memory", key))
+		}
+	}
+
+	rule := strings.Repeat("!", 72)
+	fmt.Fprintln(w, rule)
+	fmt.Fprintln(w, "!!  DEVELOPMENT MODE: insecure defaults, in-memory state, mock Gusto API")
+	fmt.Fprintln(w, "!!  Never run with --dev in production.")
+	for _, line := range lines {
+		fmt.Fprintln(w, "!!    "+line)
+	}
+	fmt.Fprintln(w, rule)
+}
+
+// startMockGusto serves a gusto.MockAPI on a local port for --dev,
+// returning its base URL. Verification payloads are signed as signature
+// says, so that they pass the webhook routes' checks.
+func startMockGusto(logger *slog.Logger, verificationToken string, signature middleware.SignatureScheme) (string, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", fmt.Errorf("starting mock Gusto API: %w", err)
+	}
+	mock := &gusto.MockAPI{Logger: logger, VerificationToken: verificationToken, Signature: signature}
+	go http.Serve(listener, mock.Handler())
+	baseURL := "http://" + listener.Addr().String()
+	logger.Info("Mock Gusto API listening", "base_url", baseURL)
+	return baseURL, nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+// fileOwner gives the files a new process loads at startup, such as the
+// idempotency snapshot or SCHEDULE_PATH, a single writer while listeners
+// are handed off: it runs the tasks that write them until Release, and
+// makes the stores backed by them read-only meanwhile.
+type fileOwner struct {
+	parent context.Context
+	ctx    context.Context // Cancelled by Release.
+	cancel context.CancelFunc
+	tasks  []func(context.Context)
+	stores []interface{ SetReadOnly(bool) }
+	wg     sync.WaitGroup
+}
+
+func newFileOwner(parent context.Context) *fileOwner {
+	o := &fileOwner{parent: parent}
+	o.ctx, o.cancel = context.WithCancel(parent)
+	return o
+}
+
+// Go runs task until its context is cancelled by Release or the parent's,
+// and again after Reclaim.
+func (o *fileOwner) Go(task func(context.Context)) {
+	o.tasks = append(o.tasks, task)
+	o.run(task)
+}
+
+// Store makes store read-only from Release until Reclaim.
+func (o *fileOwner) Store(store interface{ SetReadOnly(bool) }) {
+	o.stores = append(o.stores, store)
+}
+
+// Release stops writing the files: stores refuse changes, and tasks are
+// stopped and waited for.
+func (o *fileOwner) Release() {
+	for _, store := range o.stores {
+		store.SetReadOnly(true)
+	}
+	o.cancel()
+	o.wg.Wait()
+}
+
+// Reclaim undoes Release, e.g. because the new process failed to start.
+func (o *fileOwner) Reclaim() {
+	for _, store := range o.stores {
+		store.SetReadOnly(false)
+	}
+	o.ctx, o.cancel = context.WithCancel(o.parent)
+	for _, task := range o.tasks {
+		o.run(task)
+	}
+}
+
+func (o *fileOwner) run(task func(context.Context)) {
+	ctx := o.ctx
+	o.wg.Add(1)
+	go func() {
+		defer o.wg.Done()
+		task(ctx)
+	}()
+}
@@ -1,31 +1,77 @@
 package main
 
 import (
+	"cmp"
 	"context"
+	"database/sql"
 	"errors"
+	"flag"
+	"fmt"
+	"gusto-webhook-guide/internal/admin"
+	"gusto-webhook-guide/internal/apierror"
+	"gusto-webhook-guide/internal/archive"
+	"gusto-webhook-guide/internal/canary"
+	"gusto-webhook-guide/internal/capture"
+	"gusto-webhook-guide/internal/cron"
+	"gusto-webhook-guide/internal/deliveries"
+	"gusto-webhook-guide/internal/devlog"
+	"gusto-webhook-guide/internal/listeners"
 	"gusto-webhook-guide/internal/middleware"
+	"gusto-webhook-guide/internal/models"
+	"gusto-webhook-guide/internal/providers/gusto"
+	"gusto-webhook-guide/internal/ratelimit"
+	"gusto-webhook-guide/internal/schema"
 	"gusto-webhook-guide/internal/setup"
+	"gusto-webhook-guide/internal/subscriptions"
+	"gusto-webhook-guide/internal/tenants"
+	"gusto-webhook-guide/internal/tracing"
 	"gusto-webhook-guide/internal/webhooks"
 	"gusto-webhook-guide/internal/worker"
+	"io"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
 	"github.com/go-chi/chi/v5"
+	_ "github.com/jackc/pgx/v5/stdlib"
 	"github.com/joho/godotenv"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/redis/go-redis/v9"
+	"github.com/segmentio/kafka-go"
 )
 
 func main() {
-	// Initialize a structured JSON logger.
+	// --dev is for local iteration: readable logs, default secrets, in-memory
+	// backends, a mock Gusto API and short retry and shutdown timings.
+	dev := flag.Bool("dev", false, "run in development mode (never in production)")
+	flag.Parse()
+
+	// Initialize a structured JSON logger, or a readable one in development.
 	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	if *dev {
+		logger = slog.New(devlog.NewHandler(os.Stderr, &devlog.Options{Level: slog.LevelDebug, NoColor: os.Getenv("NO_COLOR") != ""}))
+	}
+
+	// A process handed the listeners on SIGHUP starts from this environment,
+	// so it reads the .env file again and picks up any changes.
+	baseEnv := os.Environ()
 
 	// Load environment variables from a .env file for local development.
 	if err := godotenv.Load(); err != nil {
 		logger.Warn("No .env file found, continuing with environment variables")
 	}
+	if *dev {
+		applyDevMode(os.Stderr)
+	}
 
 	// Get server port from environment variables, with a default value.
 	port := os.Getenv("SERVER_PORT")
@@ -39,6 +85,33 @@ func main() {
 	if apiToken == "" {
 		logger.Warn("GUSTO_API_TOKEN not set. The /admin/setup-webhook endpoint will not work.")
 	}
+	// Every Gusto API client records per-endpoint latency, statuses and
+	// rate-limit headers, summarized at /admin/gusto-health.
+	gustoHealth := gusto.NewHealth()
+	gustoTransport := gusto.NewTransport(logger, gustoHealth)
+	subscriptionService := subscriptions.NewClient(apiToken)
+	subscriptionService.HTTP.Transport = gustoTransport
+	// Webhooks are signed as Gusto does by default, with a hex-encoded
+	// HMAC-SHA256 in X-Gusto-Signature. GUSTO_SIGNATURE_HEADER,
+	// GUSTO_SIGNATURE_ALGORITHM and GUSTO_SIGNATURE_ENCODING override that,
+	// e.g. for a sandbox or preview API that signs differently.
+	webhookSignature, err := gusto.ParseSignature(os.Getenv("GUSTO_SIGNATURE_HEADER"),
+		os.Getenv("GUSTO_SIGNATURE_ALGORITHM"), os.Getenv("GUSTO_SIGNATURE_ENCODING"))
+	if err != nil {
+		logger.Error("Invalid webhook signature configuration", "error", err)
+		os.Exit(1)
+	}
+
+	gustoBaseURL := gusto.DefaultBaseURL
+	if *dev {
+		baseURL, err := startMockGusto(logger, os.Getenv("GUSTO_VERIFICATION_TOKEN"), webhookSignature)
+		if err != nil {
+			logger.Error("Failed to start mock Gusto API", "error", err)
+			os.Exit(1)
+		}
+		gustoBaseURL = baseURL
+	}
+	subscriptionService.BaseURL = gustoBaseURL
 
 	// Read the verification token, which acts as our signing secret for incoming webhooks.
 	verificationToken := os.Getenv("GUSTO_VERIFICATION_TOKEN")
@@ -46,64 +119,1329 @@ func main() {
 		logger.Warn("GUSTO_VERIFICATION_TOKEN is not set. Webhook signature verification will fail.")
 	}
 
-	// Create the idempotency store.
-	idempotencyStore := worker.NewIdempotencyStore()
+	// Connect to Redis when configured. Multi-replica deployments use it to
+	// share rate limits and dedup state across instances.
+	var redisClient *redis.Client
+	if redisURL := os.Getenv("REDIS_URL"); redisURL != "" {
+		opts, err := redis.ParseURL(redisURL)
+		if err != nil {
+			logger.Error("Invalid REDIS_URL", "error", err)
+			os.Exit(1)
+		}
+		redisClient = redis.NewClient(opts)
+		defer redisClient.Close()
+	}
+
+	// Read the admin token protecting the authenticated /admin endpoints.
+	adminToken := os.Getenv("ADMIN_TOKEN")
+	if adminToken == "" {
+		logger.Warn("ADMIN_TOKEN is not set. Authenticated admin endpoints are disabled.")
+	}
+
+	// Background tasks (sweepers, probes) run until this context is cancelled
+	// at shutdown.
+	bgCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+
+	// Tasks writing files that a new process loads at startup, and stores
+	// backed by such files, are registered with handoffFiles, so that this
+	// process stops writing them before handing off its listeners.
+	handoffFiles := newFileOwner(bgCtx)
+
+	// Periodic maintenance tasks are registered with the scheduler as they
+	// are configured, and run once the server is listening. Each run is
+	// delayed by up to CRON_JITTER so that replicas don't run them together.
+	scheduler := cron.NewScheduler(logger)
+	cronJitter := durationFromEnv(logger, "CRON_JITTER", 10*time.Second)
+	sweepInterval := durationFromEnv(logger, "IDEMPOTENCY_SWEEP_INTERVAL", 10*time.Minute)
+
+	// Processed keys expire after IDEMPOTENCY_TTL so the store stays bounded.
+	// It should comfortably exceed Gusto's retry window.
+	idempotencyTTL := durationFromEnv(logger, "IDEMPOTENCY_TTL", 72*time.Hour)
+
+	// Create the idempotency store. The in-memory store is the default; set
+	// IDEMPOTENCY_STORE=postgres and DATABASE_URL for a durable, auditable one,
+	// IDEMPOTENCY_STORE=redis and REDIS_URL to share it across replicas,
+	// IDEMPOTENCY_STORE=dynamodb and DYNAMODB_TABLE where neither is available,
+	// IDEMPOTENCY_STORE=lru for a fixed-size in-memory store, or
+	// IDEMPOTENCY_STORE=sharded for an in-memory store with less lock contention.
+	var snapshotStore *worker.IdempotencyStore // Set when snapshots are enabled.
+	snapshotPath := os.Getenv("IDEMPOTENCY_SNAPSHOT_PATH")
+	var storeConns []io.Closer // Database handles opened for the stores.
+	defer func() {
+		for _, conn := range storeConns {
+			conn.Close()
+		}
+	}()
+	openIdempotencyStore := func(backend string) worker.Store {
+		switch backend {
+		case "", "memory":
+			memStore := worker.NewIdempotencyStoreWithTTL(idempotencyTTL)
+			scheduler.Register(sweepTask(logger, memStore, sweepInterval, cronJitter))
+
+			// Optionally persist the store to disk so single-node deployments
+			// survive restarts without reprocessing recent events.
+			if snapshotPath != "" {
+				loaded, err := memStore.LoadSnapshot(snapshotPath)
+				if err != nil {
+					logger.Error("Failed to restore idempotency snapshot", "path", snapshotPath, "error", err)
+					os.Exit(1)
+				}
+				logger.Info("Restored idempotency snapshot", "path", snapshotPath, "keys", loaded)
+				snapshotInterval := durationFromEnv(logger, "IDEMPOTENCY_SNAPSHOT_INTERVAL", time.Minute)
+				handoffFiles.Go(func(ctx context.Context) { memStore.RunSnapshotter(ctx, snapshotPath, snapshotInterval, logger) })
+				snapshotStore = memStore
+			}
+			return memStore
+		case "sharded":
+			// Like "memory", but with IDEMPOTENCY_SHARDS locks instead of one,
+			// for deployments running many workers.
+			shardedStore := worker.NewShardedStore(intFromEnv(logger, "IDEMPOTENCY_SHARDS", 32), idempotencyTTL)
+			scheduler.Register(sweepTask(logger, shardedStore, sweepInterval, cronJitter))
+			return shardedStore
+		case "lru":
+			return worker.NewLRUStore(intFromEnv(logger, "IDEMPOTENCY_MAX_ENTRIES", 100000))
+		case "redis":
+			if redisClient == nil {
+				logger.Error("IDEMPOTENCY_STORE=redis requires REDIS_URL")
+				os.Exit(1)
+			}
+			return worker.NewRedisStore(redisClient, "webhooks:idempotency:", idempotencyTTL)
+		case "postgres":
+			db, err := sql.Open("pgx", os.Getenv("DATABASE_URL"))
+			if err != nil {
+				logger.Error("Failed to open Postgres connection", "error", err)
+				os.Exit(1)
+			}
+			storeConns = append(storeConns, db)
+			pgStore := worker.NewPostgresStore(db)
+			if err := pgStore.Migrate(context.Background()); err != nil {
+				logger.Error("Failed to prepare Postgres idempotency store", "error", err)
+				os.Exit(1)
+			}
+			return pgStore
+		case "dynamodb":
+			table := os.Getenv("DYNAMODB_TABLE")
+			if table == "" {
+				logger.Error("IDEMPOTENCY_STORE=dynamodb requires DYNAMODB_TABLE")
+				os.Exit(1)
+			}
+			// Credentials and region come from the standard AWS environment.
+			awsCfg, err := awsconfig.LoadDefaultConfig(context.Background())
+			if err != nil {
+				logger.Error("Failed to load AWS configuration", "error", err)
+				os.Exit(1)
+			}
+			client := dynamodb.NewFromConfig(awsCfg, func(o *dynamodb.Options) {
+				// DYNAMODB_ENDPOINT points at DynamoDB Local during development.
+				if endpoint := os.Getenv("DYNAMODB_ENDPOINT"); endpoint != "" {
+					o.BaseEndpoint = &endpoint
+				}
+			})
+			return worker.NewDynamoDBStore(client, table, idempotencyTTL)
+		default:
+			logger.Error("Unknown IDEMPOTENCY_STORE backend", "backend", backend)
+			os.Exit(1)
+			return nil
+		}
+	}
+	idempotencyStore := openIdempotencyStore(os.Getenv("IDEMPOTENCY_STORE"))
+
+	// To switch backends without letting duplicates through, set
+	// IDEMPOTENCY_PREVIOUS_STORE to the old backend and
+	// IDEMPOTENCY_DUAL_WRITE_UNTIL to when to stop using it, at least one
+	// IDEMPOTENCY_TTL after the switch. Until then both are read and written.
+	dualWriting := false
+	if previousBackend := os.Getenv("IDEMPOTENCY_PREVIOUS_STORE"); previousBackend != "" {
+		until, err := time.Parse(time.RFC3339, os.Getenv("IDEMPOTENCY_DUAL_WRITE_UNTIL"))
+		if err != nil {
+			logger.Error("IDEMPOTENCY_PREVIOUS_STORE requires an RFC 3339 IDEMPOTENCY_DUAL_WRITE_UNTIL", "error", err)
+			os.Exit(1)
+		}
+		currentBackend := cmp.Or(os.Getenv("IDEMPOTENCY_STORE"), "memory")
+		if previousBackend == currentBackend || (inMemoryStore(previousBackend) && inMemoryStore(currentBackend)) {
+			logger.Error("IDEMPOTENCY_PREVIOUS_STORE must differ from IDEMPOTENCY_STORE, and not both be in memory", "previous", previousBackend, "current", currentBackend)
+			os.Exit(1)
+		}
+		if time.Now().Before(until) {
+			idempotencyStore = worker.NewDualStore(idempotencyStore, openIdempotencyStore(previousBackend), until)
+			dualWriting = true
+			logger.Info("Reading and writing both idempotency stores", "current", currentBackend, "previous", previousBackend, "until", until)
+		} else {
+			logger.Warn("IDEMPOTENCY_DUAL_WRITE_UNTIL has passed; unset IDEMPOTENCY_PREVIOUS_STORE", "until", until)
+		}
+	}
+
+	// With IDEMPOTENCY_FALLBACK_STORE set, usually to "memory", claims and
+	// outcomes go there while IDEMPOTENCY_STORE is failing, e.g. during a Redis
+	// outage, instead of blocking all processing. Dedup is then best effort.
+	// The failed store is tried again every IDEMPOTENCY_FALLBACK_RETRY.
+	if fallbackBackend := os.Getenv("IDEMPOTENCY_FALLBACK_STORE"); fallbackBackend != "" {
+		currentBackend := cmp.Or(os.Getenv("IDEMPOTENCY_STORE"), "memory")
+		if fallbackBackend == currentBackend || inMemoryStore(currentBackend) {
+			logger.Error("IDEMPOTENCY_FALLBACK_STORE must differ from IDEMPOTENCY_STORE, which must not be in memory", "fallback", fallbackBackend, "current", currentBackend)
+			os.Exit(1)
+		}
+		retry := durationFromEnv(logger, "IDEMPOTENCY_FALLBACK_RETRY", 5*time.Second)
+		idempotencyStore = worker.NewFallbackStore(idempotencyStore, openIdempotencyStore(fallbackBackend), retry, logger)
+		logger.Info("Falling back to another idempotency store on failure", "current", currentBackend, "fallback", fallbackBackend)
+	}
+
+	// Durable stores can hold claims left by a process that crashed mid-event,
+	// which would make Gusto's redeliveries look like duplicates. Reset claims
+	// older than IDEMPOTENCY_RECOVERY_AGE so the next delivery is processed.
+	if recoverer, ok := idempotencyStore.(worker.Recoverer); ok {
+		cutoff := time.Now().Add(-durationFromEnv(logger, "IDEMPOTENCY_RECOVERY_AGE", 10*time.Minute))
+		recovered, err := recoverer.RecoverStale(context.Background(), cutoff)
+		if err != nil {
+			logger.Error("Failed to recover stale idempotency claims", "error", err)
+		} else {
+			logger.Info("Recovered stale idempotency claims", "count", recovered)
+		}
+	}
 
 	// Create and start the worker pool.
+	// WORKER_COUNT=0 runs an ingestion-only process, for use with a shared
+	// JOB_QUEUE that separate worker processes consume.
 	const maxQueueSize = 100
-	const numWorkers = 5
-	workerPool := worker.NewPool(maxQueueSize, numWorkers, logger, idempotencyStore)
+	numWorkers := intFromEnv(logger, "WORKER_COUNT", 5)
+	processor := gusto.NewProcessor(logger)
+	processor.BaseURL = gustoBaseURL
+	processor.Client.Transport = gustoTransport
+
+	// Events are routed to handlers by type. Types without a handler are
+	// logged by default; UNREGISTERED_EVENT_POLICY=ignore skips them quietly
+	// and UNREGISTERED_EVENT_POLICY=dead_letter dead-letters them.
+	registry := worker.NewRegistry()
+	processor.Register(registry)
+	unregisteredPolicy, err := worker.ParseUnregisteredPolicy(os.Getenv("UNREGISTERED_EVENT_POLICY"))
+	if err != nil {
+		logger.Error("Invalid UNREGISTERED_EVENT_POLICY", "error", err)
+		os.Exit(1)
+	}
+	registry.SetUnregisteredPolicy(unregisteredPolicy)
+	workerPool := worker.NewPool(maxQueueSize, numWorkers, logger, idempotencyStore, registry)
+
+	// Optionally cap concurrent processing per event type, e.g.
+	// EVENT_CONCURRENCY_LIMITS="payroll.processed=2".
+	concurrencyLimits, err := worker.ParseConcurrencyLimits(os.Getenv("EVENT_CONCURRENCY_LIMITS"))
+	if err != nil {
+		logger.Error("Invalid EVENT_CONCURRENCY_LIMITS", "error", err)
+		os.Exit(1)
+	}
+	workerPool.SetConcurrencyLimits(concurrencyLimits)
+
+	// ORDERED_PROCESSING=true processes each resource's events one at a time
+	// and in order, each resource always on the same worker.
+	if os.Getenv("ORDERED_PROCESSING") == "true" {
+		workerPool.SetOrdered(true)
+	}
+
+	// BATCH_SIZE>1 lets each worker take up to that many jobs at once,
+	// waiting up to BATCH_MAX_WAIT to fill a batch, for handlers registered
+	// with Registry.OnBatch to process together.
+	batchSize := intFromEnv(logger, "BATCH_SIZE", 1)
+	workerPool.SetBatching(batchSize, durationFromEnv(logger, "BATCH_MAX_WAIT", 100*time.Millisecond))
+
+	// Optionally process some event types ahead of others, e.g.
+	// EVENT_PRIORITIES="payroll=high,company=low". Only the in-memory queue
+	// orders jobs by priority.
+	eventPriorities, err := worker.ParsePriorities(os.Getenv("EVENT_PRIORITIES"))
+	if err != nil {
+		logger.Error("Invalid EVENT_PRIORITIES", "error", err)
+		os.Exit(1)
+	}
+
+	// Optionally acknowledge events we don't care about without queueing
+	// them, e.g. WEBHOOK_EVENT_DENYLIST="contractor.*". With an allowlist,
+	// only the event types it matches are queued.
+	eventFilter, err := webhooks.ParseEventFilter(os.Getenv("WEBHOOK_EVENT_ALLOWLIST"), os.Getenv("WEBHOOK_EVENT_DENYLIST"))
+	if err != nil {
+		logger.Error("Invalid WEBHOOK_EVENT_ALLOWLIST or WEBHOOK_EVENT_DENYLIST", "error", err)
+		os.Exit(1)
+	}
+
+	// Optionally check events against a JSON Schema per event type, loaded
+	// from WEBHOOK_SCHEMA_DIR as <event_type>.json. Invalid events are
+	// rejected with a 400, or with WEBHOOK_SCHEMA_ACTION=dead_letter
+	// acknowledged and dead-lettered without being queued.
+	var eventSchemas *schema.Set
+	var schemaDeadLetter func(ctx context.Context, job models.Job, cause error)
+	if dir := os.Getenv("WEBHOOK_SCHEMA_DIR"); dir != "" {
+		eventSchemas, err = schema.LoadDir(dir)
+		if err != nil {
+			logger.Error("Failed to load event schemas", "dir", dir, "error", err)
+			os.Exit(1)
+		}
+		switch action := os.Getenv("WEBHOOK_SCHEMA_ACTION"); action {
+		case "", "reject":
+		case "dead_letter":
+			schemaDeadLetter = workerPool.DeadLetterJob
+		default:
+			logger.Error("Invalid WEBHOOK_SCHEMA_ACTION, want reject or dead_letter", "value", action)
+			os.Exit(1)
+		}
+		logger.Info("Validating events against their schemas", "event_types", eventSchemas.EventTypes(),
+			"dead_letter", schemaDeadLetter != nil)
+	}
+
+	// Optionally hold the response to some event types until they are
+	// processed, for at most a budget each, e.g.
+	// WEBHOOK_ACK_AFTER_PROCESSING="payroll.*=10s", so a 2xx confirms
+	// processing. The rest are acknowledged once queued.
+	ackPolicy, err := webhooks.ParseAckPolicy(os.Getenv("WEBHOOK_ACK_AFTER_PROCESSING"))
+	if err != nil {
+		logger.Error("Invalid WEBHOOK_ACK_AFTER_PROCESSING", "error", err)
+		os.Exit(1)
+	}
+
+	// Optionally redrive dead letters automatically when a trigger fires via
+	// POST /admin/dlq/triggers/{trigger}, e.g.
+	// DLQ_TRIAGE_RULES="auth_error:token_rotated".
+	triageRules, err := worker.ParseTriageRules(os.Getenv("DLQ_TRIAGE_RULES"))
+	if err != nil {
+		logger.Error("Invalid DLQ_TRIAGE_RULES", "error", err)
+		os.Exit(1)
+	}
+	workerPool.SetTriageRules(triageRules)
+
+	// Processor errors that are neither transient nor permanent are retried
+	// by default; UNKNOWN_ERROR_POLICY=dead_letter gives up on them instead.
+	unknownErrorPolicy, err := worker.ParseUnknownErrorPolicy(os.Getenv("UNKNOWN_ERROR_POLICY"))
+	if err != nil {
+		logger.Error("Invalid UNKNOWN_ERROR_POLICY", "error", err)
+		os.Exit(1)
+	}
+	workerPool.SetUnknownErrorPolicy(unknownErrorPolicy)
+
+	// DLQ_RETENTION, if set, deletes dead letters older than that, hourly.
+	if retention := durationFromEnv(logger, "DLQ_RETENTION", 0); retention > 0 {
+		scheduler.Register(cron.Task{
+			Name:     "dlq_retention",
+			Interval: time.Hour,
+			Jitter:   cronJitter,
+			Run: func(context.Context) error {
+				if pruned := workerPool.DeadLetters().Prune(time.Now().Add(-retention)); pruned > 0 {
+					logger.Info("Deleted expired dead letters", "deleted", pruned, "retention", retention)
+				}
+				return nil
+			},
+		})
+	}
+
+	// Give each processing attempt JOB_TIMEOUT to finish. At shutdown,
+	// in-flight jobs get SHUTDOWN_GRACE before their API calls are cancelled.
+	workerPool.SetJobTimeout(durationFromEnv(logger, "JOB_TIMEOUT", 5*time.Minute))
+	shutdownGrace := durationFromEnv(logger, "SHUTDOWN_GRACE", 10*time.Second)
+	workerPool.SetStopGrace(shutdownGrace)
+	// Wait RETRY_DELAY before retrying a transient failure, unless Gusto
+	// says when to.
+	workerPool.SetRetryDelay(durationFromEnv(logger, "RETRY_DELAY", 10*time.Second))
+	// Dead-letter jobs received more than MAX_JOB_AGE ago instead of
+	// processing them. Unset means no limit.
+	maxJobAge := durationFromEnv(logger, "MAX_JOB_AGE", 0)
+	workerPool.SetMaxJobAge(maxJobAge)
+	// Dead-letter transient failures instead of retrying them once retries
+	// make up RETRY_BUDGET_PERCENT of the attempts over RETRY_BUDGET_WINDOW.
+	// Unset means no budget.
+	retryBudgetPercent := intFromEnv(logger, "RETRY_BUDGET_PERCENT", 0)
+	workerPool.SetRetryBudget(retryBudgetPercent, durationFromEnv(logger, "RETRY_BUDGET_WINDOW", time.Minute))
+	// Quarantine jobs once POISON_ATTEMPTS of their attempts have panicked or
+	// run for POISON_SLOW_ATTEMPT or longer. Unset means no detection.
+	poisonAttempts := intFromEnv(logger, "POISON_ATTEMPTS", 0)
+	workerPool.SetPoisonDetection(poisonAttempts, durationFromEnv(logger, "POISON_SLOW_ATTEMPT", 0))
+	// With SKIP_NOOP_UPDATES=true, *.updated events whose payload only
+	// differs from the last update of the same resource in NOOP_IGNORE_FIELDS
+	// (comma-separated) are recorded as skipped instead of being processed.
+	skipNoOpUpdates := os.Getenv("SKIP_NOOP_UPDATES") == "true"
+	if skipNoOpUpdates {
+		var ignoreFields []string
+		for _, field := range strings.Split(os.Getenv("NOOP_IGNORE_FIELDS"), ",") {
+			if field = strings.TrimSpace(field); field != "" {
+				ignoreFields = append(ignoreFields, field)
+			}
+		}
+		workerPool.Use(worker.NewNoOpUpdates(intFromEnv(logger, "NOOP_CACHE_SIZE", 10000), ignoreFields).Middleware)
+	}
+
+	// GUSTO_STATUS_CHECK_INTERVAL, if set, polls Gusto's status page and
+	// holds retries while it declares an outage. Operators can also hold
+	// them with POST /admin/workers/retries/hold.
+	statusCheckInterval := durationFromEnv(logger, "GUSTO_STATUS_CHECK_INTERVAL", 0)
+	if statusCheckInterval > 0 {
+		statusPage := &gusto.StatusPage{URL: os.Getenv("GUSTO_STATUS_URL"), Pool: workerPool}
+		scheduler.Register(cron.Task{
+			Name:       "gusto_status",
+			Interval:   statusCheckInterval,
+			RunAtStart: true,
+			Run:        statusPage.Check,
+		})
+	}
+
+	// With REDIS_URL set, pausing workers or holding retries through the
+	// admin API does so on every replica, which check for changes every
+	// WORKER_CONTROLS_SYNC.
+	if redisClient != nil {
+		workerPool.SetControlStore(worker.NewRedisControlStore(redisClient, "webhooks:controls:"),
+			durationFromEnv(logger, "WORKER_CONTROLS_SYNC", 5*time.Second))
+	}
+
+	// CLAIM_LOCK makes replicas take a shared lock per event while
+	// processing it: "redis" (requires REDIS_URL) or "postgres" (requires
+	// DATABASE_URL). Useful when the idempotency store is not shared.
+	switch lock := os.Getenv("CLAIM_LOCK"); lock {
+	case "":
+	case "redis":
+		if redisClient == nil {
+			logger.Error("CLAIM_LOCK=redis requires REDIS_URL")
+			os.Exit(1)
+		}
+		workerPool.SetLocker(worker.NewRedisLocker(redisClient, "webhooks:lock:", durationFromEnv(logger, "CLAIM_LOCK_TTL", 5*time.Minute)))
+	case "postgres":
+		db, err := sql.Open("pgx", os.Getenv("DATABASE_URL"))
+		if err != nil {
+			logger.Error("Failed to open Postgres connection", "error", err)
+			os.Exit(1)
+		}
+		defer db.Close()
+		workerPool.SetLocker(worker.NewPostgresLocker(db))
+	default:
+		logger.Error("Unknown CLAIM_LOCK backend", "backend", lock)
+		os.Exit(1)
+	}
+
+	// JOB_QUEUE=postgres or redis keeps queued jobs in DATABASE_URL or
+	// REDIS_URL so they survive a crash; the default in-memory queue loses them.
+	// JOB_QUEUE=sqs uses the SQS queue at SQS_QUEUE_URL, and JOB_QUEUE=kafka
+	// the KAFKA_TOPIC topic on KAFKA_BROKERS.
+	switch queueBackend := os.Getenv("JOB_QUEUE"); queueBackend {
+	case "", "memory":
+	case "redis":
+		if redisClient == nil {
+			logger.Error("JOB_QUEUE=redis requires REDIS_URL")
+			os.Exit(1)
+		}
+		hostname, _ := os.Hostname()
+		consumer := fmt.Sprintf("%s-%d", hostname, os.Getpid())
+		streamQueue := worker.NewRedisStreamQueue(redisClient, "webhooks:jobs", "workers", consumer,
+			durationFromEnv(logger, "JOB_QUEUE_LEASE", 5*time.Minute))
+		if err := streamQueue.Migrate(context.Background()); err != nil {
+			logger.Error("Failed to prepare Redis job queue", "error", err)
+			os.Exit(1)
+		}
+		workerPool.SetQueue(streamQueue)
+	case "postgres":
+		db, err := sql.Open("pgx", os.Getenv("DATABASE_URL"))
+		if err != nil {
+			logger.Error("Failed to open Postgres connection", "error", err)
+			os.Exit(1)
+		}
+		defer db.Close()
+		pgQueue := worker.NewPostgresQueue(db, durationFromEnv(logger, "JOB_QUEUE_LEASE", 5*time.Minute))
+		if err := pgQueue.Migrate(context.Background()); err != nil {
+			logger.Error("Failed to prepare Postgres job queue", "error", err)
+			os.Exit(1)
+		}
+		workerPool.SetQueue(pgQueue)
+	case "sqs":
+		queueURL := os.Getenv("SQS_QUEUE_URL")
+		if queueURL == "" {
+			logger.Error("JOB_QUEUE=sqs requires SQS_QUEUE_URL")
+			os.Exit(1)
+		}
+		awsCfg, err := awsconfig.LoadDefaultConfig(context.Background())
+		if err != nil {
+			logger.Error("Failed to load AWS configuration", "error", err)
+			os.Exit(1)
+		}
+		client := sqs.NewFromConfig(awsCfg, func(o *sqs.Options) {
+			// SQS_ENDPOINT points at a local SQS emulator during development.
+			if endpoint := os.Getenv("SQS_ENDPOINT"); endpoint != "" {
+				o.BaseEndpoint = &endpoint
+			}
+		})
+		// SQS_DLQ_ARN enables POST /admin/queue/redrive for the queue's
+		// dead-letter queue.
+		workerPool.SetQueue(worker.NewSQSQueue(client, queueURL, os.Getenv("SQS_DLQ_ARN"),
+			durationFromEnv(logger, "JOB_QUEUE_LEASE", 5*time.Minute)))
+	case "kafka":
+		brokers := os.Getenv("KAFKA_BROKERS")
+		if brokers == "" {
+			logger.Error("JOB_QUEUE=kafka requires KAFKA_BROKERS")
+			os.Exit(1)
+		}
+		topic := os.Getenv("KAFKA_TOPIC")
+		if topic == "" {
+			topic = "gusto-webhooks"
+		}
+		writer := &kafka.Writer{
+			Addr:         kafka.TCP(strings.Split(brokers, ",")...),
+			Topic:        topic,
+			Balancer:     &kafka.Hash{}, // Keeps each resource's events on one partition.
+			RequiredAcks: kafka.RequireAll,
+		}
+		defer writer.Close()
+		// A process without workers must not join the consumer group, or it
+		// would be assigned partitions it never reads.
+		var queueReader worker.KafkaReader
+		if numWorkers > 0 {
+			group := os.Getenv("KAFKA_GROUP")
+			if group == "" {
+				group = "webhook-workers"
+			}
+			reader := kafka.NewReader(kafka.ReaderConfig{
+				Brokers: strings.Split(brokers, ","),
+				Topic:   topic,
+				GroupID: group,
+				// Commits are batched and keep the highest offset per
+				// partition, so acks finishing out of order can't move a
+				// committed offset backwards.
+				CommitInterval: time.Second,
+			})
+			defer reader.Close()
+			queueReader = reader
+		}
+		workerPool.SetQueue(worker.NewKafkaQueue(writer, queueReader))
+	default:
+		logger.Error("Unknown JOB_QUEUE backend", "backend", queueBackend)
+		os.Exit(1)
+	}
+
+	// Handlers can schedule future-dated events with worker.ScheduleAt. They
+	// are kept in SCHEDULE_PATH (in memory if empty), or with
+	// SCHEDULE_STORE=postgres in DATABASE_URL, shared by every replica.
+	switch scheduleBackend := os.Getenv("SCHEDULE_STORE"); scheduleBackend {
+	case "", "file":
+		schedule, err := worker.OpenFileSchedule(os.Getenv("SCHEDULE_PATH"))
+		if err != nil {
+			logger.Error("Failed to open schedule", "error", err)
+			os.Exit(1)
+		}
+		workerPool.SetSchedule(schedule)
+		handoffFiles.Store(schedule)
+	case "postgres":
+		db, err := sql.Open("pgx", os.Getenv("DATABASE_URL"))
+		if err != nil {
+			logger.Error("Failed to open Postgres connection", "error", err)
+			os.Exit(1)
+		}
+		defer db.Close()
+		pgSchedule := worker.NewPostgresSchedule(db)
+		if err := pgSchedule.Migrate(context.Background()); err != nil {
+			logger.Error("Failed to prepare Postgres schedule", "error", err)
+			os.Exit(1)
+		}
+		workerPool.SetSchedule(pgSchedule)
+	default:
+		logger.Error("Unknown SCHEDULE_STORE backend", "backend", scheduleBackend)
+		os.Exit(1)
+	}
+	schedulePoll := durationFromEnv(logger, "SCHEDULE_POLL_INTERVAL", 10*time.Second)
+	handoffFiles.Go(func(ctx context.Context) { workerPool.RunSchedule(ctx, schedulePoll) })
+
+	// Handlers can record what processing an event produced with
+	// worker.AddArtifacts and worker.SetResultData. RESULT_STORE keeps it for
+	// the admin and tenant event APIs: the last RESULT_HISTORY_SIZE results
+	// with "memory", or every result in DATABASE_URL with "postgres".
+	var resultStore worker.ResultStore
+	switch resultBackend := os.Getenv("RESULT_STORE"); resultBackend {
+	case "":
+	case "memory":
+		resultStore = worker.NewMemoryResultStore(intFromEnv(logger, "RESULT_HISTORY_SIZE", 10000))
+	case "postgres":
+		db, err := sql.Open("pgx", os.Getenv("DATABASE_URL"))
+		if err != nil {
+			logger.Error("Failed to open Postgres connection", "error", err)
+			os.Exit(1)
+		}
+		defer db.Close()
+		pgResults := worker.NewPostgresResultStore(db)
+		if err := pgResults.Migrate(context.Background()); err != nil {
+			logger.Error("Failed to prepare Postgres result store", "error", err)
+			os.Exit(1)
+		}
+		resultStore = pgResults
+	default:
+		logger.Error("Unknown RESULT_STORE backend", "backend", resultBackend)
+		os.Exit(1)
+	}
+	if resultStore != nil {
+		workerPool.SetResultStore(resultStore)
+	}
+
+	// With QUEUE_SPILL_PATH set, jobs still queued or waiting to be retried at
+	// shutdown are saved there and queued again at the next startup.
+	if spillPath := os.Getenv("QUEUE_SPILL_PATH"); spillPath != "" {
+		workerPool.SetSpillPath(spillPath)
+		restored, err := workerPool.LoadSpill()
+		if err != nil {
+			logger.Error("Failed to restore spilled jobs", "path", spillPath, "restored", restored, "error", err)
+			os.Exit(1)
+		}
+		if restored > 0 {
+			logger.Info("Restored jobs spilled at the last shutdown", "path", spillPath, "jobs", restored)
+		}
+	}
+	// Durable queues keep retries waiting for their delay, so that they
+	// survive a restart.
+	if loaded, err := workerPool.LoadRetries(context.Background()); err != nil {
+		logger.Error("Failed to load scheduled retries", "error", err)
+		os.Exit(1)
+	} else if loaded > 0 {
+		logger.Info("Loaded retries scheduled before the last shutdown", "retries", loaded)
+	}
 	workerPool.Start(numWorkers)
 
+	// With WORKER_MAX_COUNT above WORKER_COUNT, the pool adds workers while
+	// the queue backs up and retires them, down to WORKER_COUNT, once it
+	// drains. AUTOSCALE_LATENCY_TARGET also scales up on slow processing.
+	maxWorkers := intFromEnv(logger, "WORKER_MAX_COUNT", 0)
+	autoscaling := numWorkers > 0 && maxWorkers > numWorkers
+	if autoscaling {
+		autoscale := worker.DefaultAutoscaleConfig(numWorkers, maxWorkers)
+		autoscale.Interval = durationFromEnv(logger, "AUTOSCALE_INTERVAL", autoscale.Interval)
+		autoscale.LatencyTarget = durationFromEnv(logger, "AUTOSCALE_LATENCY_TARGET", 0)
+		if err := autoscale.Validate(); err != nil {
+			logger.Error("Invalid worker autoscaling settings", "error", err)
+			os.Exit(1)
+		}
+		go workerPool.RunAutoscaler(bgCtx, autoscale)
+	}
+
 	// --- Router Setup ---
 	router := chi.NewRouter()
+	// Errors, including for unknown routes, are JSON; see apierror.
+	router.NotFound(apierror.NotFound)
+	router.MethodNotAllowed(apierror.MethodNotAllowed)
+
+	// MIDDLEWARE_CONFIG optionally names a JSON file overriding which
+	// middleware each route group uses, e.g. {"metrics": ["auth"]}. Webhook
+	// routes must verify signatures and admin routes must authenticate.
+	routeMiddleware, err := middleware.LoadMatrix(os.Getenv("MIDDLEWARE_CONFIG"), middleware.Matrix{
+		// Request IDs come first so every log line carries one, tracing
+		// next so every stage is timed, the body is limited before anything
+		// reads it, and captures come before verification so rejected
+		// requests are kept.
+		"webhooks":   {"requestid", "trace", "ratelimit", "limit", "capture", "verify"},
+		"tenants":    {"requestid", "trace", "ratelimit", "limit", "capture", "verify"},
+		"admin":      {"auth"},
+		"metrics":    {},
+		"tenant_api": {"auth"},
+	})
+	if err != nil {
+		logger.Error("Invalid MIDDLEWARE_CONFIG", "error", err)
+		os.Exit(1)
+	}
+
+	// Keep the last CAPTURE_BUFFER_SIZE raw webhook requests for debugging.
+	captureSize := intFromEnv(logger, "CAPTURE_BUFFER_SIZE", 0)
+	captureRing := capture.NewRing(captureSize)
+
+	// Trace every webhook request and keep the last SLOW_TRACE_BUFFER_SIZE
+	// that took longer than SLOW_REQUEST_THRESHOLD to answer.
+	slowTraces := tracing.NewRecorder(logger, durationFromEnv(logger, "SLOW_REQUEST_THRESHOLD", time.Second),
+		intFromEnv(logger, "SLOW_TRACE_BUFFER_SIZE", 100))
+
+	// Remember bodies for the last DELIVERY_HISTORY_SIZE event UUIDs so changed
+	// duplicates are diffed and logged rather than silently dropped.
+	deliveryTracker := deliveries.NewTracker(intFromEnv(logger, "DELIVERY_HISTORY_SIZE", 1000))
+
+	// ARCHIVE_STORE keeps every verified event, as delivered, before it is
+	// queued: one file per event in ARCHIVE_PATH, or with "postgres" in
+	// DATABASE_URL. Events older than ARCHIVE_RETENTION are deleted hourly.
+	var eventArchive archive.Store
+	switch archiveBackend := os.Getenv("ARCHIVE_STORE"); archiveBackend {
+	case "":
+	case "file":
+		archivePath := os.Getenv("ARCHIVE_PATH")
+		if archivePath == "" {
+			logger.Error("ARCHIVE_STORE=file requires ARCHIVE_PATH")
+			os.Exit(1)
+		}
+		dirArchive, err := archive.OpenDirStore(archivePath)
+		if err != nil {
+			logger.Error("Failed to open event archive", "error", err)
+			os.Exit(1)
+		}
+		eventArchive = dirArchive
+	case "postgres":
+		db, err := sql.Open("pgx", os.Getenv("DATABASE_URL"))
+		if err != nil {
+			logger.Error("Failed to open Postgres connection", "error", err)
+			os.Exit(1)
+		}
+		defer db.Close()
+		pgArchive := archive.NewPostgresStore(db)
+		if err := pgArchive.Migrate(context.Background()); err != nil {
+			logger.Error("Failed to prepare Postgres event archive", "error", err)
+			os.Exit(1)
+		}
+		eventArchive = pgArchive
+	default:
+		logger.Error("Unknown ARCHIVE_STORE backend", "backend", archiveBackend)
+		os.Exit(1)
+	}
+	if retention := durationFromEnv(logger, "ARCHIVE_RETENTION", 30*24*time.Hour); eventArchive != nil {
+		scheduler.Register(cron.Task{
+			Name:     "archive_retention",
+			Interval: time.Hour,
+			Jitter:   cronJitter,
+			Run: func(ctx context.Context) error {
+				pruned, err := eventArchive.Prune(ctx, time.Now().Add(-retention))
+				if pruned > 0 {
+					logger.Info("Deleted expired archived events", "deleted", pruned, "retention", retention)
+				}
+				return err
+			},
+		})
+	}
+
+	// Follow the last REJECTION_HISTORY_SIZE events rejected with 503 to see
+	// whether Gusto's retries got them processed. One not delivered again
+	// within REJECTION_LOSS_WINDOW counts as lost to saturation; see
+	// /admin/queue/rejections.
+	rejectionHistorySize := intFromEnv(logger, "REJECTION_HISTORY_SIZE", 10000)
+	rejectionTracker := webhooks.NewRejectionTracker(rejectionHistorySize,
+		durationFromEnv(logger, "REJECTION_LOSS_WINDOW", 24*time.Hour))
+	if rejectionHistorySize > 0 {
+		scheduler.Register(cron.Task{
+			Name:     "rejection_outcomes",
+			Interval: time.Minute,
+			Jitter:   cronJitter,
+			Run: func(ctx context.Context) error {
+				return rejectionTracker.Resolve(ctx, idempotencyStore, time.Now().UTC())
+			},
+		})
+	}
+
+	// With GUSTO_EVENTS_POLL_INTERVAL set, events are also fetched from
+	// Gusto's events API with GUSTO_API_TOKEN and queued like webhook
+	// deliveries, for networks that can't accept inbound webhooks.
+	// GUSTO_EVENTS_CURSOR_PATH keeps the position across restarts.
+	eventsPollInterval := durationFromEnv(logger, "GUSTO_EVENTS_POLL_INTERVAL", 0)
+	if eventsPollInterval > 0 {
+		eventPoller := gusto.NewEventPoller(logger, apiToken, workerPool.Queue())
+		eventPoller.BaseURL = gustoBaseURL
+		eventPoller.Client.Transport = gustoTransport
+		eventPoller.CursorPath = os.Getenv("GUSTO_EVENTS_CURSOR_PATH")
+		scheduler.Register(cron.Task{
+			Name:       "gusto_events_poll",
+			Interval:   eventsPollInterval,
+			RunAtStart: true,
+			Run: func(ctx context.Context) error {
+				queued, err := eventPoller.Poll(ctx)
+				if queued > 0 {
+					logger.Info("Queued events from Gusto's events API", "queued", queued, "cursor", eventPoller.Cursor())
+				}
+				return err
+			},
+		})
+	}
+
+	// WEBHOOK_URL is this server's public webhook URL, whose subscription is
+	// kept in place. Reconciling creates the subscription if it is missing,
+	// and adds subscription types missing for events we handle with
+	// SUBSCRIPTION_AUTO_EXPAND=true.
+	webhookURL := os.Getenv("WEBHOOK_URL")
+	expandSubscription := os.Getenv("SUBSCRIPTION_AUTO_EXPAND") == "true"
+	reconcileSubscription := func(ctx context.Context) error {
+		sub, err := subscriptions.Reconcile(ctx, subscriptionService, logger, webhookURL)
+		if err != nil {
+			return fmt.Errorf("reconciling webhook subscription for %s: %w", webhookURL, err)
+		}
+		if _, err := subscriptions.EnsureTypes(ctx, subscriptionService, logger, sub, gusto.HandledEventTypes, expandSubscription); err != nil {
+			return fmt.Errorf("adding missing webhook subscription types: %w", err)
+		}
+		return nil
+	}
+
+	// Gusto's notifications about the subscription itself, e.g. its removal,
+	// are not queued: they mark it unhealthy (see /admin/subscription/health)
+	// and, with SUBSCRIPTION_AUTO_RESUBSCRIBE=true and WEBHOOK_URL set,
+	// reconcile it straight away.
+	subscriptionHealth := subscriptions.NewHealthState()
+	autoResubscribe := os.Getenv("SUBSCRIPTION_AUTO_RESUBSCRIBE") == "true" && webhookURL != ""
+	onSubscriptionEvent := func(event gusto.SubscriptionEvent) {
+		subscriptionHealth.Set(false, "Gusto sent "+event.EventType)
+		if !autoResubscribe {
+			return
+		}
+		go func() {
+			ctx, cancel := context.WithTimeout(bgCtx, time.Minute)
+			defer cancel()
+			if err := reconcileSubscription(ctx); err != nil {
+				logger.Error("Failed to re-subscribe after subscription notification", "event_type", event.EventType, "error", err)
+			}
+		}()
+	}
+	webhookControl := webhooks.ChainControls(gusto.VerificationHandler(logger), gusto.SubscriptionLifecycleHandler(logger, onSubscriptionEvent))
 
 	// --- Webhook Routes ---
-	webhookHandler := webhooks.NewHandler(logger, workerPool.JobQueue)
+	webhookHandler := webhooks.NewHandler(logger, workerPool.Queue())
+	webhookHandler.Deliveries = deliveryTracker
+	webhookHandler.Archive = eventArchive
+	webhookHandler.Rejections = rejectionTracker
+	webhookHandler.Control = webhookControl
+	// Briefly wait for room in a full queue rather than rejecting bursts.
+	enqueueWait := durationFromEnv(logger, "WEBHOOK_ENQUEUE_WAIT", 0)
+	webhookHandler.EnqueueWait = enqueueWait
+	webhookHandler.Priorities = eventPriorities
+	webhookHandler.Filter = eventFilter
+	webhookHandler.AckAfter = ackPolicy
+	webhookHandler.Schemas = eventSchemas
+	webhookHandler.DeadLetter = schemaDeadLetter
+	webhookHandler.Store = idempotencyStore
+	// When the queue is full, answer QUEUE_FULL_STATUS (429 or 503) with a
+	// Retry-After of how long the queue should take to drain at its recent
+	// rate, capped at QUEUE_FULL_RETRY_AFTER_MAX. QUEUE_FULL_RETRY_AFTER=false
+	// leaves the header out.
+	queueFullStatus := intFromEnv(logger, "QUEUE_FULL_STATUS", http.StatusTooManyRequests)
+	if queueFullStatus != http.StatusTooManyRequests && queueFullStatus != http.StatusServiceUnavailable {
+		logger.Error("Invalid QUEUE_FULL_STATUS, want 429 or 503", "value", queueFullStatus)
+		os.Exit(1)
+	}
+	var queueFullRetryAfter func() time.Duration
+	if os.Getenv("QUEUE_FULL_RETRY_AFTER") != "false" {
+		retryAfterMax := durationFromEnv(logger, "QUEUE_FULL_RETRY_AFTER_MAX", 5*time.Minute)
+		queueFullRetryAfter = func() time.Duration {
+			drainTime, known := workerPool.DrainTime()
+			if !known {
+				return retryAfterMax
+			}
+			return min(drainTime, retryAfterMax)
+		}
+	}
+	webhookHandler.QueueFullStatus = queueFullStatus
+	webhookHandler.RetryAfter = queueFullRetryAfter
+	webhookLimiter := newWebhookLimiter(logger, redisClient)
+
+	// With FORWARD_URL set, verified events are passed through unchanged to
+	// that URL instead of being processed, and Gusto gets its status code.
+	webhookHook := webhookHandler.HandleWebhook
+	if forwardURL := os.Getenv("FORWARD_URL"); forwardURL != "" {
+		forwarder := webhooks.NewForwarder(logger, forwardURL,
+			&http.Client{Timeout: durationFromEnv(logger, "FORWARD_TIMEOUT", 10*time.Second)})
+		forwarder.Headers = append(forwarder.Headers, webhookSignature.Header)
+		forwarder.Deliveries = deliveryTracker
+		forwarder.Control = webhookControl
+		webhookHook = forwarder.HandleWebhook
+		logger.Info("Forwarding webhooks without processing them", "upstream", forwardURL)
+	}
+
+	// SIGNATURE_BYPASS_MAX_TTL enables admin-minted bypass tokens, which let
+	// trusted test traffic (e.g. QA in staging) send unsigned webhooks in the
+	// X-Webhook-Bypass-Token header. Leave it unset in production.
+	var bypassTokens *middleware.BypassTokens
+	webhookVerifier := func(v middleware.Verifier, scope string) middleware.Verifier { return v }
+	if os.Getenv("SIGNATURE_BYPASS_MAX_TTL") != "" {
+		bypassTokens = middleware.NewBypassTokens(durationFromEnv(logger, "SIGNATURE_BYPASS_MAX_TTL", time.Hour), "webhooks", "tenants")
+		webhookVerifier = func(v middleware.Verifier, scope string) middleware.Verifier {
+			return middleware.BypassVerifier{Next: v, Tokens: bypassTokens, Scope: scope, Logger: logger}
+		}
+		logger.Warn("Signature bypass tokens are enabled. Do not use this in production.")
+	}
+	// Webhook requests must be JSON and at most WEBHOOK_MAX_BODY_BYTES long
+	// (1 MiB by default; 0 disables the limit).
+	var webhookLimitBody middleware.Middleware
+	if maxBodyBytes := intFromEnv(logger, "WEBHOOK_MAX_BODY_BYTES", 1<<20); maxBodyBytes > 0 {
+		webhookLimitBody = middleware.LimitBody(logger, int64(maxBodyBytes))
+	}
+	var webhookRateLimit, webhookCapture middleware.Middleware // Nil when disabled.
+	if webhookLimiter != nil {
+		webhookRateLimit = middleware.RateLimit(logger, webhookLimiter, "webhooks")
+	}
+	if captureSize > 0 {
+		webhookCapture = captureRing.Middleware
+	}
+	// webhookMiddleware returns the middleware available to webhook routes.
+	webhookMiddleware := func(verifier middleware.Verifier) map[string]middleware.Middleware {
+		return map[string]middleware.Middleware{
+			"requestid": middleware.RequestID,
+			"trace":     slowTraces.Middleware,
+			"ratelimit": webhookRateLimit,
+			"limit":     webhookLimitBody,
+			"capture":   webhookCapture,
+			"verify":    middleware.Verify(logger, verifier),
+		}
+	}
 	router.Route("/webhooks", func(r chi.Router) {
-		r.Use(middleware.VerifySignature(logger, verificationToken))
-		r.Post("/", webhookHandler.HandleWebhook)
+		r.Use(routeStack(logger, routeMiddleware, "webhooks",
+			webhookMiddleware(webhookVerifier(webhookSignature.Verifier(verificationToken), "webhooks")), "verify")...)
+		r.Post("/", webhookHook)
 	})
 
+	// --- Tenant Webhook Routes ---
+	// With PUBLIC_BASE_URL set, POST /admin/tenants onboards a tenant whose
+	// events arrive at /webhooks/t/{tenant}, signed with its own secret.
+	var tenantHandler *admin.TenantHandler
+	if publicURL := os.Getenv("PUBLIC_BASE_URL"); publicURL != "" {
+		tenantRegistry, err := tenants.OpenRegistry(os.Getenv("TENANT_REGISTRY_PATH"))
+		if err != nil {
+			logger.Error("Failed to open tenant registry", "error", err)
+			os.Exit(1)
+		}
+		handoffFiles.Store(tenantRegistry)
+		provisioner := tenants.NewProvisioner(logger, tenantRegistry, subscriptionService, publicURL,
+			durationFromEnv(logger, "TENANT_VERIFICATION_TIMEOUT", time.Minute))
+		tenantHandler = &admin.TenantHandler{Logger: logger, Provisioner: provisioner, Registry: tenantRegistry}
+
+		tenantWebhookHandler := webhooks.NewHandler(logger, workerPool.Queue())
+		tenantWebhookHandler.Deliveries = deliveryTracker
+		tenantWebhookHandler.Archive = eventArchive
+		tenantWebhookHandler.Rejections = rejectionTracker
+		tenantWebhookHandler.Control = webhooks.ChainControls(gusto.TenantVerificationHandler(logger, provisioner.Deliver),
+			gusto.SubscriptionLifecycleHandler(logger, nil))
+		tenantWebhookHandler.EnqueueWait = enqueueWait
+		tenantWebhookHandler.QueueFullStatus = queueFullStatus
+		tenantWebhookHandler.RetryAfter = queueFullRetryAfter
+		tenantWebhookHandler.Priorities = eventPriorities
+		tenantWebhookHandler.Filter = eventFilter
+		tenantWebhookHandler.AckAfter = ackPolicy
+		tenantWebhookHandler.Schemas = eventSchemas
+		tenantWebhookHandler.DeadLetter = schemaDeadLetter
+		tenantWebhookHandler.Store = idempotencyStore
+		// Tenants registered with a forward_url (PUT /admin/tenants/{id})
+		// have their verified events passed through to it instead.
+		tenantForwardClient := &http.Client{Timeout: durationFromEnv(logger, "FORWARD_TIMEOUT", 10*time.Second)}
+		tenantForward := func(upstream string) http.Handler {
+			forwarder := webhooks.NewForwarder(logger, upstream, tenantForwardClient)
+			forwarder.Headers = append(forwarder.Headers, webhookSignature.Header)
+			forwarder.Deliveries = deliveryTracker
+			forwarder.Control = tenantWebhookHandler.Control
+			return http.HandlerFunc(forwarder.HandleWebhook)
+		}
+		router.Route("/webhooks/t/{tenant}", func(r chi.Router) {
+			r.Use(routeStack(logger, routeMiddleware, "tenants",
+				webhookMiddleware(webhookVerifier(provisioner.Verifier(webhookSignature.Verifier), "tenants")), "verify")...)
+			r.Method(http.MethodPost, "/", tenantRegistry.Dispatch(http.HandlerFunc(tenantWebhookHandler.HandleWebhook), tenantForward))
+		})
+
+		// Tenants read their own recent events, the last
+		// TENANT_EVENT_HISTORY_SIZE of each, with an API token minted by
+		// POST /admin/tenants/{id}/api-token.
+		tenantEvents := tenants.NewEventLog(intFromEnv(logger, "TENANT_EVENT_HISTORY_SIZE", 1000))
+		tenantWebhookHandler.Recorder = tenantEvents.Record
+		tenantEventsHandler := &admin.TenantEventsHandler{Logger: logger, Registry: tenantRegistry, Events: tenantEvents, Store: idempotencyStore, Results: resultStore}
+		router.Route("/tenants/{tenant}", func(r chi.Router) {
+			r.Use(routeStack(logger, routeMiddleware, "tenant_api",
+				map[string]middleware.Middleware{"auth": tenantRegistry.RequireAPIToken(logger)}, "auth")...)
+			r.Get("/events", tenantEventsHandler.HandleList)
+			r.Get("/events/{uuid}", tenantEventsHandler.HandleGet)
+		})
+	}
+
+	// --- Metrics Route ---
+	adminMiddleware := map[string]middleware.Middleware{
+		"auth": middleware.RequireBearerToken(logger, adminToken),
+	}
+	router.With(routeStack(logger, routeMiddleware, "metrics", adminMiddleware)...).
+		Handle("/metrics", promhttp.Handler())
+
 	// --- Admin Route for Setup ---
 	setupHandler := &setup.Handler{
-		Logger:   logger,
-		APIToken: apiToken,
+		Logger:        logger,
+		Subscriptions: subscriptionService,
 	}
 	router.Post("/admin/setup-webhook", setupHandler.HandleWebhookSetup)
 
+	// --- Authenticated Admin Routes ---
+	idempotencyHandler := &admin.IdempotencyHandler{
+		Logger:  logger,
+		Store:   idempotencyStore,
+		Results: resultStore,
+	}
+	queueHandler := &admin.QueueHandler{
+		Logger: logger,
+		Pool:   workerPool,
+	}
+	unhandledHandler := &admin.UnhandledHandler{
+		Tracker: workerPool.Unhandled(),
+	}
+	deadLetterHandler := &admin.DeadLetterHandler{
+		Logger: logger,
+		Pool:   workerPool,
+	}
+	quarantineHandler := &admin.QuarantineHandler{
+		Logger: logger,
+		Pool:   workerPool,
+	}
+	workersHandler := &admin.WorkersHandler{
+		Logger: logger,
+		Pool:   workerPool,
+	}
+	// Log the deployment's shape once, and serve it at /admin/status.
+	deployment := admin.NewDeployment()
+	deployment.Storage = map[string]string{
+		"idempotency": cmp.Or(os.Getenv("IDEMPOTENCY_STORE"), "memory"),
+		"queue":       cmp.Or(os.Getenv("JOB_QUEUE"), "memory"),
+		"schedule":    cmp.Or(os.Getenv("SCHEDULE_STORE"), "file"),
+		"claim_lock":  cmp.Or(os.Getenv("CLAIM_LOCK"), "none"),
+		"archive":     cmp.Or(os.Getenv("ARCHIVE_STORE"), "none"),
+		"results":     cmp.Or(os.Getenv("RESULT_STORE"), "none"),
+		"controls":    "memory",
+	}
+	if redisClient != nil {
+		deployment.Storage["controls"] = "redis"
+	}
+	if dualWriting {
+		deployment.Storage["idempotency_previous"] = os.Getenv("IDEMPOTENCY_PREVIOUS_STORE")
+	}
+	if _, fallback := idempotencyStore.(*worker.FallbackStore); fallback {
+		deployment.Storage["idempotency_fallback"] = os.Getenv("IDEMPOTENCY_FALLBACK_STORE")
+	}
+	deployment.Workers = admin.WorkerSettings{
+		Count:     numWorkers,
+		MaxCount:  max(numWorkers, maxWorkers),
+		QueueSize: maxQueueSize,
+		BatchSize: batchSize,
+	}
+	deployment.Features = map[string]bool{
+		"ack_after_processing":   ackPolicy != nil,
+		"admin_api":              adminToken != "",
+		"autoscaling":            autoscaling,
+		"batching":               batchSize > 1,
+		"canary":                 os.Getenv("CANARY_URL") != "",
+		"captures":               captureSize > 0,
+		"dev_mode":               *dev,
+		"event_filter":           eventFilter != nil,
+		"forwarding":             os.Getenv("FORWARD_URL") != "",
+		"gusto_events_poll":      eventsPollInterval > 0,
+		"gusto_status":           statusCheckInterval > 0,
+		"idempotency_snapshots":  snapshotStore != nil,
+		"max_job_age":            maxJobAge > 0,
+		"noop_update_skip":       skipNoOpUpdates,
+		"ordered_processing":     os.Getenv("ORDERED_PROCESSING") == "true",
+		"poison_detection":       poisonAttempts > 0,
+		"queue_spill":            os.Getenv("QUEUE_SPILL_PATH") != "",
+		"rate_limit":             webhookLimiter != nil,
+		"retry_budget":           retryBudgetPercent > 0,
+		"schema_validation":      eventSchemas != nil,
+		"signature_bypass":       bypassTokens != nil,
+		"subscription_reconcile": os.Getenv("WEBHOOK_URL") != "",
+		"tenants":                tenantHandler != nil,
+		"unix_socket":            os.Getenv("UNIX_SOCKET_PATH") != "",
+	}
+	deployment.Log(logger)
+	statusHandler := &admin.StatusHandler{
+		Deployment: deployment,
+		Pool:       workerPool,
+	}
+	resourceHandler := &admin.ResourceHandler{
+		Logger:     logger,
+		Deliveries: deliveryTracker,
+		Pool:       workerPool,
+	}
+	ingestHandler := &admin.IngestHandler{Logger: logger, Queue: workerPool.Queue()}
+	router.Group(func(r chi.Router) {
+		r.Use(routeStack(logger, routeMiddleware, "admin", adminMiddleware, "auth")...)
+		r.Get("/admin/captures", captureRing.HandleDownload)
+		r.Get("/admin/cron", scheduler.HandleStatus)
+		r.Get("/admin/gusto-health", gustoHealth.HandleSummary)
+		r.Get("/admin/dlq", deadLetterHandler.HandleList)
+		r.Post("/admin/dlq/redrive", deadLetterHandler.HandleRedrive)
+		r.Post("/admin/dlq/triggers/{trigger}", deadLetterHandler.HandleTrigger)
+		r.Post("/admin/dlq/{id}/replay", deadLetterHandler.HandleReplay)
+		r.Get("/admin/events/{uuid}/deliveries", deliveryTracker.HandleGet)
+		r.Get("/admin/events/unhandled", unhandledHandler.HandleReport)
+		r.Get("/admin/idempotency", idempotencyHandler.HandleList)
+		r.Post("/admin/idempotency/expire", idempotencyHandler.HandleExpire)
+		r.Get("/admin/idempotency/{uuid}", idempotencyHandler.HandleGet)
+		r.Delete("/admin/idempotency/{uuid}", idempotencyHandler.HandleDelete)
+		r.Post("/admin/ingest/stream", ingestHandler.HandleStream)
+		r.Get("/admin/quarantine", quarantineHandler.HandleList)
+		r.Get("/admin/quarantine/{id}", quarantineHandler.HandleGet)
+		r.Delete("/admin/quarantine/{id}", quarantineHandler.HandleDelete)
+		r.Post("/admin/quarantine/{id}/release", quarantineHandler.HandleRelease)
+		r.Get("/admin/queue/snapshot", queueHandler.HandleSnapshot)
+		r.Post("/admin/queue/restore", queueHandler.HandleRestore)
+		r.Post("/admin/queue/redrive", queueHandler.HandleRedrive)
+		r.Get("/admin/queue/rejections", rejectionTracker.HandleReport)
+		r.Post("/admin/resources/{type}/{uuid}/reprocess", resourceHandler.HandleReprocess)
+		r.Get("/admin/slow-requests", slowTraces.HandleList)
+		r.Get("/admin/status", statusHandler.HandleStatus)
+		r.Get("/admin/subscription/health", subscriptionHealth.HandleStatus)
+		r.Get("/admin/workers", workersHandler.HandleStatus)
+		r.Post("/admin/workers/pause", workersHandler.HandlePause)
+		r.Post("/admin/workers/resume", workersHandler.HandleResume)
+		r.Post("/admin/workers/drain", workersHandler.HandleDrain)
+		r.Post("/admin/workers/retries/hold", workersHandler.HandleHoldRetries)
+		r.Post("/admin/workers/retries/release", workersHandler.HandleReleaseRetries)
+		if eventArchive != nil {
+			archiveHandler := &admin.ArchiveHandler{Logger: logger, Archive: eventArchive, Queue: workerPool.Queue(), Store: idempotencyStore}
+			r.Post("/admin/events/replay", archiveHandler.HandleBulkReplay)
+			r.Post("/admin/events/{uuid}/replay", archiveHandler.HandleReplay)
+		}
+		if tenantHandler != nil {
+			r.Get("/admin/tenants", tenantHandler.HandleList)
+			r.Post("/admin/tenants", tenantHandler.HandleProvision)
+			r.Put("/admin/tenants/{id}", tenantHandler.HandleRegister)
+			r.Post("/admin/tenants/{id}/api-token", tenantHandler.HandleMintAPIToken)
+			r.Put("/admin/tenants/{id}/redaction", tenantHandler.HandleSetRedaction)
+		}
+		if bypassTokens != nil {
+			bypassHandler := &admin.BypassTokenHandler{Logger: logger, Tokens: bypassTokens}
+			r.Get("/admin/bypass-tokens", bypassHandler.HandleList)
+			r.Post("/admin/bypass-tokens", bypassHandler.HandleMint)
+			r.Delete("/admin/bypass-tokens/{id}", bypassHandler.HandleRevoke)
+		}
+	})
+
 	// Create and configure the HTTP server.
 	server := &http.Server{
 		Addr:    serverAddr,
 		Handler: router,
 	}
 
+	// A process started by a SIGHUP handoff serves on its predecessor's
+	// sockets, so no delivery is refused while the configuration changes.
+	inherited, err := listeners.Inherit()
+	if err != nil {
+		logger.Error("Failed to inherit listeners", "error", err)
+		os.Exit(1)
+	}
+	tcpListener := inherited.Listener("tcp")
+	if tcpListener == nil {
+		if tcpListener, err = net.Listen("tcp", serverAddr); err != nil {
+			logger.Error("Server failed to start", "error", err)
+			os.Exit(1)
+		}
+	}
+	serverListeners := map[string]net.Listener{"tcp": tcpListener}
+
+	// --- Canary Probe ---
+	// When CANARY_URL is set, periodically send a signed synthetic event through
+	// the public endpoint to verify the full ingestion and processing path.
+	if canaryURL := os.Getenv("CANARY_URL"); canaryURL != "" {
+		prober := &canary.Prober{
+			Logger:    logger.With("component", "canary"),
+			URL:       canaryURL,
+			Secret:    verificationToken,
+			Signature: webhookSignature,
+			Interval:  durationFromEnv(logger, "CANARY_INTERVAL", time.Minute),
+			SLO:       durationFromEnv(logger, "CANARY_SLO", 30*time.Second),
+			Store:     idempotencyStore,
+			Client:    &http.Client{Timeout: 15 * time.Second},
+		}
+		go prober.Run(bgCtx)
+	}
+
 	// Start the server in a goroutine so it doesn't block.
 	go func() {
-		logger.Info("Server starting", "address", server.Addr)
-		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Info("Server starting", "address", server.Addr, "inherited", inherited != nil)
+		if err := server.Serve(tcpListener); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			logger.Error("Server failed to start", "error", err)
 			os.Exit(1)
 		}
 	}()
 
-	// Wait for an interrupt signal to gracefully shut down the server.
+	// With UNIX_SOCKET_PATH set, also serve on a Unix domain socket, e.g. for
+	// a sidecar proxy terminating TLS. UNIX_SOCKET_MODE sets its permissions.
+	if socketPath := os.Getenv("UNIX_SOCKET_PATH"); socketPath != "" {
+		mode, err := listeners.ParseMode(cmp.Or(os.Getenv("UNIX_SOCKET_MODE"), "660"))
+		if err != nil {
+			logger.Error("Invalid UNIX_SOCKET_MODE", "error", err)
+			os.Exit(1)
+		}
+		unixListener := inherited.Listener("unix")
+		if unixListener == nil {
+			if unixListener, err = listeners.Unix(socketPath, mode); err != nil {
+				logger.Error("Failed to listen on Unix socket", "path", socketPath, "error", err)
+				os.Exit(1)
+			}
+		}
+		serverListeners["unix"] = unixListener
+		go func() {
+			logger.Info("Server starting", "socket", socketPath, "mode", mode)
+			if err := server.Serve(unixListener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logger.Error("Server failed on Unix socket", "error", err)
+				os.Exit(1)
+			}
+		}()
+	}
+
+	// With WEBHOOK_URL set, make sure Gusto has a subscription for it, at
+	// startup and every SUBSCRIPTION_RECONCILE_INTERVAL. This runs once the
+	// server is listening, since a new subscription's verification payload
+	// is sent straight away. Subscription types missing for events we handle
+	// are reported, and added with SUBSCRIPTION_AUTO_EXPAND=true. In between,
+	// the subscription's health is checked every SUBSCRIPTION_HEALTH_INTERVAL.
+	if webhookURL != "" {
+		scheduler.Register(cron.Task{
+			Name:       "subscription_reconcile",
+			Interval:   durationFromEnv(logger, "SUBSCRIPTION_RECONCILE_INTERVAL", time.Hour),
+			Jitter:     cronJitter,
+			RunAtStart: true,
+			Run:        reconcileSubscription,
+		})
+		scheduler.Register(cron.Task{
+			Name:     "subscription_health",
+			Interval: durationFromEnv(logger, "SUBSCRIPTION_HEALTH_INTERVAL", 5*time.Minute),
+			Jitter:   cronJitter,
+			Run: func(ctx context.Context) error {
+				err := subscriptions.CheckHealth(ctx, subscriptionService, webhookURL, gusto.HandledEventTypes)
+				if err != nil {
+					subscriptionHealth.Set(false, err.Error())
+				} else {
+					subscriptionHealth.Set(true, "")
+				}
+				return err
+			},
+		})
+	}
+	handoffFiles.Go(scheduler.Run) // Some tasks save files, e.g. the events cursor.
+
+	// Tell the process we took over from, if any, that it can drain.
+	if err := inherited.Ready(); err != nil {
+		logger.Error("Failed to report readiness to the previous process", "error", err)
+	}
+
+	// Wait for an interrupt signal to gracefully shut down the server. On
+	// SIGHUP, start a new process with the configuration reloaded and hand
+	// it the listeners, then drain; if it fails to start, keep serving.
+	handoffTimeout := durationFromEnv(logger, "HANDOFF_TIMEOUT", 30*time.Second)
 	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	handedOff := false
+	for !handedOff {
+		if sig := <-quit; sig != syscall.SIGHUP {
+			break
+		}
+		logger.Info("Handing listeners off to a new process")
+		// The new process loads the files this one writes, so stop writing
+		// them first, leaving an up-to-date snapshot.
+		handoffFiles.Release()
+		if snapshotStore != nil {
+			if err := snapshotStore.SaveSnapshot(snapshotPath); err != nil {
+				logger.Error("Failed to write idempotency snapshot for the new process", "path", snapshotPath, "error", err)
+			}
+		}
+		process, err := listeners.Handoff(bgCtx, serverListeners, baseEnv, handoffTimeout)
+		if err != nil {
+			logger.Error("Listener handoff failed, still serving with the current configuration", "error", err)
+			handoffFiles.Reclaim()
+			continue
+		}
+		logger.Info("New process is serving", "pid", process.Pid)
+		// It has loaded the spill file already, so process what is queued
+		// rather than spill it.
+		workerPool.DrainOnStop()
+		handedOff = true
+	}
 	logger.Info("Server shutting down...")
 
-	// Stop the worker pool and wait for jobs to finish.
-	workerPool.Stop()
+	// shutdownServer stops accepting and waits up to 30 seconds for existing
+	// requests to finish.
+	shutdownServer := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			logger.Error("Server forced to shutdown", "error", err)
+		}
+	}
 
-	// Create a context with a timeout to allow existing requests to finish.
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
+	// Stop accepting first, so that no delivery is queued for workers that
+	// are stopping; after a handoff, new deliveries go to the new process.
+	// Requests held until their events are processed finish meanwhile.
+	shutdownServer()
 
-	// Attempt to gracefully shut down the server.
-	if err := server.Shutdown(ctx); err != nil {
-		logger.Error("Server forced to shutdown", "error", err)
+	// Stop background tasks before the workers so the canary doesn't report
+	// a false outage.
+	stopBackground()
+
+	// Stop the worker pool and wait for jobs to finish, cancelling any still
+	// running after SHUTDOWN_GRACE and abandoning those that ignore it.
+	stopCtx, cancelStop := context.WithTimeout(context.Background(), shutdownGrace)
+	workerPool.StopContext(stopCtx) // Logs any jobs it abandons.
+	cancelStop()
+
+	// Take a final snapshot now that no more outcomes will be recorded,
+	// unless the snapshot now belongs to the process we handed off to.
+	if snapshotStore != nil && !handedOff {
+		if err := snapshotStore.SaveSnapshot(snapshotPath); err != nil {
+			logger.Error("Failed to write final idempotency snapshot", "path", snapshotPath, "error", err)
+		}
 	}
 
 	logger.Info("Server exited gracefully")
-}
\ No newline at end of file
+}
+
+// durationFromEnv parses a time.Duration from the named environment variable,
+// falling back to def if it is unset or invalid.
+func durationFromEnv(logger *slog.Logger, key string, def time.Duration) time.Duration {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return def
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil || d <= 0 {
+		logger.Warn("Invalid duration in environment, using default", "key", key, "value", raw, "default", def)
+		return def
+	}
+	return d
+}
+
+// intFromEnv parses an int from the named environment variable, falling back
+// to def if it is unset or invalid.
+func intFromEnv(logger *slog.Logger, key string, def int) int {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return def
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 0 {
+		logger.Warn("Invalid integer in environment, using default", "key", key, "value", raw, "default", def)
+		return def
+	}
+	return n
+}
+
+// inMemoryStore reports whether an IDEMPOTENCY_STORE backend keeps its keys
+// in process memory.
+func inMemoryStore(backend string) bool {
+	return backend == "" || backend == "memory" || backend == "sharded" || backend == "lru"
+}
+
+// sweepTask returns a periodic task removing expired keys from an in-memory
+// idempotency store.
+func sweepTask(logger *slog.Logger, store interface {
+	Sweep() int
+	Len() int
+}, interval, jitter time.Duration) cron.Task {
+	return cron.Task{
+		Name:     "idempotency_sweep",
+		Interval: interval,
+		Jitter:   jitter,
+		Run: func(context.Context) error {
+			if removed := store.Sweep(); removed > 0 {
+				logger.Info("Swept expired idempotency keys", "removed", removed, "remaining", store.Len())
+			}
+			return nil
+		},
+	}
+}
+
+// routeStack returns the middleware configured for a route group, exiting
+// if the configuration is invalid.
+func routeStack(logger *slog.Logger, matrix middleware.Matrix, group string, available map[string]middleware.Middleware, required ...string) []middleware.Middleware {
+	stack, err := matrix.Stack(group, available, required...)
+	if err != nil {
+		logger.Error("Invalid MIDDLEWARE_CONFIG", "error", err)
+		os.Exit(1)
+	}
+	return stack
+}
+
+// newWebhookLimiter builds the /webhooks rate limiter from WEBHOOK_RATE_LIMIT
+// (requests per WEBHOOK_RATE_LIMIT_WINDOW). It returns nil when rate limiting
+// is disabled. With Redis available the limit is shared by all replicas.
+func newWebhookLimiter(logger *slog.Logger, redisClient *redis.Client) middleware.Limiter {
+	limit := intFromEnv(logger, "WEBHOOK_RATE_LIMIT", 0)
+	if limit == 0 {
+		return nil
+	}
+	window := durationFromEnv(logger, "WEBHOOK_RATE_LIMIT_WINDOW", time.Second)
+	if redisClient != nil {
+		return ratelimit.NewRedis(redisClient, "webhooks:ratelimit:", limit, window)
+	}
+	return ratelimit.NewLocal(limit, window)
+}
@@ -0,0 +1,111 @@
+// Command subscriptions manages Gusto webhook subscriptions from the command
+// line, using GUSTO_API_TOKEN from the environment or a .env file.
+//
+// Usage:
+//
+//	subscriptions list
+//	subscriptions create -url https://example.com/webhooks [-types Company,Employee]
+//	subscriptions verify -uuid <subscription uuid> -token <verification token>
+//	subscriptions reconcile -url https://example.com/webhooks
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"gusto-webhook-guide/internal/subscriptions"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/joho/godotenv"
+)
+
+const usage = `usage: subscriptions <command> [flags]
+
+Commands:
+  list       List webhook subscriptions
+  create     Create a subscription (-url, optional -types)
+  verify     Verify a subscription (-uuid, -token)
+  reconcile  Create a subscription for -url unless one exists
+`
+
+func main() {
+	godotenv.Load()
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	apiToken := os.Getenv("GUSTO_API_TOKEN")
+	if apiToken == "" {
+		fatal(fmt.Errorf("GUSTO_API_TOKEN is not set"))
+	}
+	svc := subscriptions.NewClient(apiToken)
+	ctx := context.Background()
+
+	cmd, args := os.Args[1], os.Args[2:]
+	flags := flag.NewFlagSet(cmd, flag.ExitOnError)
+	switch cmd {
+	case "list":
+		flags.Parse(args)
+		subs, err := svc.List(ctx)
+		if err != nil {
+			fatal(err)
+		}
+		printJSON(subs)
+	case "create":
+		url := flags.String("url", "", "URL Gusto delivers webhooks to")
+		types := flags.String("types", strings.Join(subscriptions.DefaultTypes, ","), "comma-separated subscription types")
+		flags.Parse(args)
+		requireFlags(flags, *url)
+		sub, err := svc.Create(ctx, *url, strings.Split(*types, ","))
+		if err != nil {
+			fatal(err)
+		}
+		printJSON(sub)
+	case "verify":
+		uuid := flags.String("uuid", "", "subscription UUID")
+		token := flags.String("token", "", "verification token sent to the subscription URL")
+		flags.Parse(args)
+		requireFlags(flags, *uuid, *token)
+		if err := svc.Verify(ctx, *uuid, *token); err != nil {
+			fatal(err)
+		}
+		fmt.Println("Subscription verified.")
+	case "reconcile":
+		url := flags.String("url", "", "URL Gusto delivers webhooks to")
+		flags.Parse(args)
+		requireFlags(flags, *url)
+		sub, err := subscriptions.Reconcile(ctx, svc, slog.New(slog.NewTextHandler(os.Stderr, nil)), *url)
+		if err != nil {
+			fatal(err)
+		}
+		printJSON(sub)
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+// requireFlags exits with the flag set's usage if any value is empty.
+func requireFlags(flags *flag.FlagSet, values ...string) {
+	for _, v := range values {
+		if v == "" {
+			flags.Usage()
+			os.Exit(2)
+		}
+	}
+}
+
+func printJSON(v any) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "error:", err)
+	os.Exit(1)
+}
@@ -0,0 +1,185 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"gusto-webhook-guide/internal/apierror"
+	"gusto-webhook-guide/internal/archive"
+	"gusto-webhook-guide/internal/models"
+	"gusto-webhook-guide/internal/worker"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// ArchiveHandler serves the endpoints replaying archived events, e.g. once
+// a bug in processing logic is fixed, without waiting for the provider to
+// deliver them again.
+type ArchiveHandler struct {
+	Logger  *slog.Logger
+	Archive archive.Store
+	Queue   worker.JobQueue
+	Store   worker.Store // The idempotency store.
+}
+
+// replayResponse reports the replay of one archived event. PreviousStatus
+// is the event's idempotency status before the replay; unless the replay
+// was forced, an event with a final status is dropped as a duplicate.
+type replayResponse struct {
+	EventUUID      string        `json:"event_uuid"`
+	EventType      string        `json:"event_type"`
+	PreviousStatus worker.Status `json:"previous_status,omitempty"`
+	Forced         bool          `json:"forced"`
+}
+
+// bulkReplayResponse reports a bulk replay.
+type bulkReplayResponse struct {
+	Queued   int    `json:"queued"`
+	InFlight int    `json:"in_flight"` // Events skipped by a forced replay because a worker is processing them.
+	Error    string `json:"error,omitempty"`
+}
+
+// errReplayInFlight is returned by replay when force is set and a worker
+// is still processing the event.
+var errReplayInFlight = errors.New("event is being processed")
+
+// HandleReplay queues the archived event with the {uuid} URL parameter
+// again. With force=true its idempotency key is deleted first, so it is
+// processed even if it was before.
+func (h *ArchiveHandler) HandleReplay(w http.ResponseWriter, r *http.Request) {
+	force, err := parseForce(r)
+	if err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+		return
+	}
+	eventUUID := chi.URLParam(r, "uuid")
+	e, found, err := h.Archive.Get(r.Context(), eventUUID)
+	if err != nil {
+		h.Logger.Error("Failed to read archived event", "event_uuid", eventUUID, "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to read archived event")
+		return
+	}
+	if !found {
+		apierror.Write(w, r, http.StatusNotFound, apierror.CodeNotFound, "No archived event with this UUID")
+		return
+	}
+
+	resp, err := h.replay(r, e, force)
+	switch {
+	case errors.Is(err, errReplayInFlight):
+		apierror.Write(w, r, http.StatusConflict, apierror.CodeConflict, "Event is being processed, try again once it finishes")
+		return
+	case err != nil:
+		h.Logger.Error("Failed to replay archived event", "event_uuid", eventUUID, "error", err)
+		apierror.Write(w, r, http.StatusServiceUnavailable, apierror.CodeUnavailable, "Failed to replay archived event")
+		return
+	}
+	h.Logger.Info("Archived event replayed via admin API", "event_uuid", eventUUID, "event_type", e.EventType,
+		"previous_status", resp.PreviousStatus, "forced", force)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	writeJSON(w, resp)
+}
+
+// HandleBulkReplay queues every archived event matching the query
+// parameters again, oldest first: event_type, and since and until as RFC
+// 3339 times compared with when the event was received. At least one of
+// them is required. With force=true idempotency keys are deleted first, as
+// for HandleReplay, and events a worker is processing are skipped. Queueing
+// waits for room in a full queue; if it fails the rest are left unqueued.
+func (h *ArchiveHandler) HandleBulkReplay(w http.ResponseWriter, r *http.Request) {
+	force, err := parseForce(r)
+	if err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+		return
+	}
+	query := r.URL.Query()
+	filter := archive.Filter{EventType: query.Get("event_type")}
+	for name, t := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if raw := query.Get(name); raw != "" {
+			parsed, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, fmt.Sprintf("%s must be an RFC 3339 time", name))
+				return
+			}
+			*t = parsed
+		}
+	}
+	if filter == (archive.Filter{}) {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, "At least one of event_type, since and until is required")
+		return
+	}
+
+	events, err := h.Archive.List(r.Context(), filter)
+	if err != nil {
+		h.Logger.Error("Failed to list archived events", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to list archived events")
+		return
+	}
+	var resp bulkReplayResponse
+	for _, e := range events {
+		_, err := h.replay(r, e, force)
+		if errors.Is(err, errReplayInFlight) {
+			resp.InFlight++
+			continue
+		}
+		if err != nil {
+			resp.Error = err.Error()
+			break
+		}
+		resp.Queued++
+	}
+	h.Logger.Info("Archived events replayed via admin API", "event_type", filter.EventType, "since", filter.Since,
+		"until", filter.Until, "forced", force, "matched", len(events), "queued", resp.Queued, "in_flight", resp.InFlight, "error", resp.Error)
+
+	w.Header().Set("Content-Type", "application/json")
+	if resp.Error != "" {
+		// Report how far the replay got so the rest can be retried.
+		w.WriteHeader(http.StatusServiceUnavailable)
+	} else {
+		w.WriteHeader(http.StatusAccepted)
+	}
+	writeJSON(w, resp)
+}
+
+// replay queues e as a new job. It counts as received now, so that a
+// maximum job age doesn't dead-letter it as stale.
+func (h *ArchiveHandler) replay(r *http.Request, e archive.Event, force bool) (replayResponse, error) {
+	resp := replayResponse{EventUUID: e.UUID, EventType: e.EventType, Forced: force}
+	rec, found, err := h.Store.Get(r.Context(), e.UUID)
+	if err != nil {
+		return resp, fmt.Errorf("reading idempotency key: %w", err)
+	}
+	if found {
+		resp.PreviousStatus = rec.Status
+	}
+	if force && found {
+		if rec.Status == worker.StatusProcessing {
+			return resp, errReplayInFlight
+		}
+		if err := h.Store.Delete(r.Context(), e.UUID); err != nil {
+			return resp, fmt.Errorf("deleting idempotency key: %w", err)
+		}
+	}
+	job := models.Job{Payload: e.Body, ReceivedAt: time.Now().UTC(), RequestID: e.RequestID}
+	if err := h.Queue.Enqueue(r.Context(), job, -1); err != nil {
+		return resp, fmt.Errorf("queueing event %s: %w", e.UUID, err)
+	}
+	return resp, nil
+}
+
+// parseForce reads the force query parameter.
+func parseForce(r *http.Request) (bool, error) {
+	raw := r.URL.Query().Get("force")
+	if raw == "" {
+		return false, nil
+	}
+	force, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, errors.New("force must be true or false")
+	}
+	return force, nil
+}
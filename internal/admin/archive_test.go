@@ -0,0 +1,197 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"gusto-webhook-guide/internal/archive"
+	"gusto-webhook-guide/internal/models"
+	"gusto-webhook-guide/internal/worker"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func newArchiveTestRouter(t *testing.T) (http.Handler, chan models.Job, *worker.IdempotencyStore) {
+	t.Helper()
+	ctx := context.Background()
+	store, err := archive.OpenDirStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("OpenDirStore failed: %v", err)
+	}
+	base := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i, e := range []archive.Event{
+		{UUID: "a", EventType: "company.updated", ReceivedAt: base},
+		{UUID: "b", EventType: "company.updated", ReceivedAt: base.Add(time.Hour), RequestID: "req-b"},
+		{UUID: "c", EventType: "payroll.processed", ReceivedAt: base.Add(time.Hour)},
+		{UUID: "running", EventType: "company.updated", ReceivedAt: base.Add(2 * time.Hour)},
+	} {
+		e.Body = fmt.Appendf(nil, `{"uuid":%q,"i":%d}`, e.UUID, i)
+		if err := store.Put(ctx, e); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+	keys := worker.NewIdempotencyStore()
+	keys.Set(ctx, "a", worker.Record{EventType: "company.updated", Status: worker.StatusSucceeded})
+	keys.Set(ctx, "running", worker.Record{EventType: "company.updated", Status: worker.StatusProcessing})
+
+	jobs := make(chan models.Job, 10)
+	h := &ArchiveHandler{
+		Logger:  slog.New(slog.NewJSONHandler(io.Discard, nil)),
+		Archive: store,
+		Queue:   worker.ChannelQueue(jobs),
+		Store:   keys,
+	}
+	router := chi.NewRouter()
+	router.Post("/admin/events/replay", h.HandleBulkReplay)
+	router.Post("/admin/events/{uuid}/replay", h.HandleReplay)
+	return router, jobs, keys
+}
+
+func TestHandleReplay(t *testing.T) {
+	testCases := []struct {
+		name               string
+		path               string
+		expectedStatusCode int
+		expectedPrevious   worker.Status
+		expectedKeyKept    bool
+	}{
+		{
+			name:               "Processed Event",
+			path:               "/admin/events/a/replay",
+			expectedStatusCode: http.StatusAccepted,
+			expectedPrevious:   worker.StatusSucceeded,
+			expectedKeyKept:    true,
+		},
+		{
+			name:               "Forced",
+			path:               "/admin/events/a/replay?force=true",
+			expectedStatusCode: http.StatusAccepted,
+			expectedPrevious:   worker.StatusSucceeded,
+		},
+		{
+			name:               "Forced While Processing",
+			path:               "/admin/events/running/replay?force=true",
+			expectedStatusCode: http.StatusConflict,
+		},
+		{
+			name:               "Not Archived",
+			path:               "/admin/events/missing/replay",
+			expectedStatusCode: http.StatusNotFound,
+		},
+		{
+			name:               "Invalid Force",
+			path:               "/admin/events/a/replay?force=maybe",
+			expectedStatusCode: http.StatusBadRequest,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			router, jobs, keys := newArchiveTestRouter(t)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest("POST", tc.path, nil))
+			if rr.Code != tc.expectedStatusCode {
+				t.Fatalf("incorrect status code: got %d want %d", rr.Code, tc.expectedStatusCode)
+			}
+			if rr.Code != http.StatusAccepted {
+				if len(jobs) != 0 {
+					t.Errorf("Expected nothing to be queued, got %d jobs", len(jobs))
+				}
+				return
+			}
+
+			var body replayResponse
+			if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decoding response: %v", err)
+			}
+			if body.EventUUID != "a" || body.PreviousStatus != tc.expectedPrevious {
+				t.Errorf("incorrect response: %+v", body)
+			}
+			if len(jobs) != 1 {
+				t.Fatalf("incorrect jobs queued: got %d want 1", len(jobs))
+			}
+			if job := <-jobs; string(job.Payload) != `{"uuid":"a","i":0}` || job.ReceivedAt.IsZero() {
+				t.Errorf("incorrect job: %+v", job)
+			}
+			if _, found, _ := keys.Get(context.Background(), "a"); found != tc.expectedKeyKept {
+				t.Errorf("incorrect idempotency key kept: got %v want %v", found, tc.expectedKeyKept)
+			}
+		})
+	}
+}
+
+func TestHandleBulkReplay(t *testing.T) {
+	testCases := []struct {
+		name               string
+		query              string
+		expectedStatusCode int
+		expectedQueued     []string
+		expectedInFlight   int
+	}{
+		{
+			name:               "By Event Type",
+			query:              "?event_type=company.updated",
+			expectedStatusCode: http.StatusAccepted,
+			expectedQueued:     []string{"a", "b", "running"},
+		},
+		{
+			name:               "Forced Skips Events Being Processed",
+			query:              "?event_type=company.updated&force=true",
+			expectedStatusCode: http.StatusAccepted,
+			expectedQueued:     []string{"a", "b"},
+			expectedInFlight:   1,
+		},
+		{
+			name:               "By Time Range",
+			query:              "?since=2026-01-01T12:30:00Z&until=2026-01-01T13:30:00Z",
+			expectedStatusCode: http.StatusAccepted,
+			expectedQueued:     []string{"b", "c"},
+		},
+		{
+			name:               "No Filter",
+			query:              "?force=true",
+			expectedStatusCode: http.StatusBadRequest,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			router, jobs, _ := newArchiveTestRouter(t)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest("POST", "/admin/events/replay"+tc.query, nil))
+			if rr.Code != tc.expectedStatusCode {
+				t.Fatalf("incorrect status code: got %d want %d", rr.Code, tc.expectedStatusCode)
+			}
+			if rr.Code != http.StatusAccepted {
+				return
+			}
+
+			var body bulkReplayResponse
+			if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decoding response: %v", err)
+			}
+			if body.Queued != len(tc.expectedQueued) || body.InFlight != tc.expectedInFlight {
+				t.Errorf("incorrect response: got %+v", body)
+			}
+			var queued []string
+			for len(jobs) > 0 {
+				var event models.WebhookEvent
+				json.Unmarshal((<-jobs).Payload, &event)
+				queued = append(queued, event.UUID)
+			}
+			if len(queued) != len(tc.expectedQueued) {
+				t.Fatalf("incorrect events queued: got %v want %v", queued, tc.expectedQueued)
+			}
+			for i := range queued {
+				if queued[i] != tc.expectedQueued[i] {
+					t.Errorf("incorrect events queued: got %v want %v", queued, tc.expectedQueued)
+					break
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"gusto-webhook-guide/internal/apierror"
+	"gusto-webhook-guide/internal/middleware"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// BypassTokenHandler serves the /admin/bypass-tokens endpoints, which mint,
+// list and revoke the short-lived tokens that let trusted test traffic skip
+// webhook signature verification.
+type BypassTokenHandler struct {
+	Logger *slog.Logger
+	Tokens *middleware.BypassTokens
+}
+
+// mintResponse is a newly minted token. Token is only ever returned here.
+type mintResponse struct {
+	Token string `json:"token"`
+	middleware.BypassToken
+}
+
+// HandleMint mints a token for the scope and TTL in the request body, e.g.
+// {"scope": "webhooks", "ttl": "1h", "note": "QA regression run"}.
+func (h *BypassTokenHandler) HandleMint(w http.ResponseWriter, r *http.Request) {
+	var requestBody struct {
+		Scope string `json:"scope"`
+		TTL   string `json:"ttl"`
+		Note  string `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid request body")
+		return
+	}
+	ttl, err := time.ParseDuration(requestBody.TTL)
+	if err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, "ttl must be a duration such as 30m")
+		return
+	}
+
+	token, t, err := h.Tokens.Mint(requestBody.Scope, ttl, requestBody.Note)
+	if errors.Is(err, middleware.ErrInvalidBypassRequest) {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+		return
+	}
+	if err != nil {
+		h.Logger.Error("Failed to mint bypass token", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to mint bypass token")
+		return
+	}
+
+	h.Logger.Info("Minted signature bypass token via admin API",
+		"bypass_token_id", t.ID,
+		"scope", t.Scope,
+		"note", t.Note,
+		"expires_at", t.ExpiresAt,
+		"remote_addr", r.RemoteAddr,
+	)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(mintResponse{Token: token, BypassToken: t})
+}
+
+// HandleList serves the unexpired tokens with their usage, without the
+// tokens themselves.
+func (h *BypassTokenHandler) HandleList(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, struct {
+		Tokens []middleware.BypassToken `json:"tokens"`
+	}{h.Tokens.List()})
+}
+
+// HandleRevoke revokes the token with the {id} URL parameter.
+func (h *BypassTokenHandler) HandleRevoke(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if !h.Tokens.Revoke(id) {
+		apierror.Write(w, r, http.StatusNotFound, apierror.CodeNotFound, "No bypass token with this ID")
+		return
+	}
+	h.Logger.Info("Revoked signature bypass token via admin API", "bypass_token_id", id, "remote_addr", r.RemoteAddr)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,74 @@
+package admin
+
+import (
+	"bytes"
+	"encoding/json"
+	"gusto-webhook-guide/internal/middleware"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestBypassTokenHandler(t *testing.T) {
+	h := &BypassTokenHandler{
+		Logger: slog.New(slog.NewJSONHandler(io.Discard, nil)),
+		Tokens: middleware.NewBypassTokens(time.Hour, "webhooks"),
+	}
+	router := chi.NewRouter()
+	router.Post("/admin/bypass-tokens", h.HandleMint)
+	router.Get("/admin/bypass-tokens", h.HandleList)
+	router.Delete("/admin/bypass-tokens/{id}", h.HandleRevoke)
+
+	testCases := []struct {
+		name               string
+		body               string
+		expectedStatusCode int
+	}{
+		{name: "Valid", body: `{"scope":"webhooks","ttl":"30m","note":"qa"}`, expectedStatusCode: http.StatusCreated},
+		{name: "Unknown Scope", body: `{"scope":"admin","ttl":"30m"}`, expectedStatusCode: http.StatusBadRequest},
+		{name: "TTL Too Long", body: `{"scope":"webhooks","ttl":"48h"}`, expectedStatusCode: http.StatusBadRequest},
+		{name: "Invalid TTL", body: `{"scope":"webhooks","ttl":"soon"}`, expectedStatusCode: http.StatusBadRequest},
+		{name: "Invalid Body", body: `{`, expectedStatusCode: http.StatusBadRequest},
+	}
+
+	var minted mintResponse
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest("POST", "/admin/bypass-tokens", bytes.NewBufferString(tc.body)))
+			if rr.Code != tc.expectedStatusCode {
+				t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, tc.expectedStatusCode)
+			}
+			if rr.Code == http.StatusCreated {
+				if err := json.Unmarshal(rr.Body.Bytes(), &minted); err != nil {
+					t.Fatalf("invalid JSON response: %v", err)
+				}
+			}
+		})
+	}
+	if minted.Token == "" || minted.ID == "" {
+		t.Fatalf("expected a minted token, got %+v", minted)
+	}
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/admin/bypass-tokens", nil))
+	if bytes.Contains(rr.Body.Bytes(), []byte(minted.Token)) {
+		t.Errorf("list response leaks the token: %s", rr.Body.String())
+	}
+	if !bytes.Contains(rr.Body.Bytes(), []byte(minted.ID)) {
+		t.Errorf("list response is missing the token ID: %s", rr.Body.String())
+	}
+
+	for _, want := range []int{http.StatusNoContent, http.StatusNotFound} {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("DELETE", "/admin/bypass-tokens/"+minted.ID, nil))
+		if rr.Code != want {
+			t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, want)
+		}
+	}
+}
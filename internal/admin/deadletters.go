@@ -0,0 +1,123 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"gusto-webhook-guide/internal/apierror"
+	"gusto-webhook-guide/internal/worker"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// DeadLetterHandler serves the /admin/dlq endpoints for triaging jobs that
+// failed for good.
+type DeadLetterHandler struct {
+	Logger *slog.Logger
+	Pool   *worker.Pool
+}
+
+// deadLetterFilter reads the filter query parameters: reason, event_type,
+// and since and until as RFC 3339 times.
+func deadLetterFilter(r *http.Request) (worker.DeadLetterFilter, error) {
+	query := r.URL.Query()
+	filter := worker.DeadLetterFilter{
+		Reason:    worker.DeadLetterReason(query.Get("reason")),
+		EventType: query.Get("event_type"),
+	}
+	for name, t := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if raw := query.Get(name); raw != "" {
+			parsed, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				return worker.DeadLetterFilter{}, fmt.Errorf("%s must be an RFC 3339 time", name)
+			}
+			*t = parsed
+		}
+	}
+	return filter, nil
+}
+
+// deadLetterListing sorts dead letters, oldest first by default.
+var deadLetterListing = listing[worker.DeadLetter]{
+	ID: func(dl worker.DeadLetter) string { return dl.ID },
+	Fields: map[string]func(worker.DeadLetter) string{
+		"dead_lettered_at": func(dl worker.DeadLetter) string { return timeKey(dl.DeadLetteredAt) },
+		"event_type":       func(dl worker.DeadLetter) string { return dl.EventType },
+		"reason":           func(dl worker.DeadLetter) string { return string(dl.Reason) },
+		"attempts":         func(dl worker.DeadLetter) string { return intKey(dl.Attempts) },
+	},
+	DefaultSort: "dead_lettered_at",
+}
+
+// HandleList serves a page of the dead-lettered jobs, optionally filtered by
+// the query parameters read by deadLetterFilter and paged as described by
+// listing.
+func (h *DeadLetterHandler) HandleList(w http.ResponseWriter, r *http.Request) {
+	filter, err := deadLetterFilter(r)
+	if err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+		return
+	}
+	entries, next, err := deadLetterListing.paginate(r, h.Pool.DeadLetters().List(filter))
+	if err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+		return
+	}
+	if entries == nil {
+		entries = []worker.DeadLetter{}
+	}
+	writeJSON(w, pageResponse("dead_letters", entries, next))
+}
+
+// HandleReplay queues the dead letter with the {id} URL parameter again,
+// with a fresh attempt count.
+func (h *DeadLetterHandler) HandleReplay(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	err := h.Pool.RedriveDeadLetter(r.Context(), id)
+	switch {
+	case err == nil:
+		h.Logger.Info("Dead letter replayed via admin API", "dead_letter_id", id)
+		w.WriteHeader(http.StatusAccepted)
+	case errors.Is(err, worker.ErrDeadLetterNotFound):
+		apierror.Write(w, r, http.StatusNotFound, apierror.CodeNotFound, "No dead letter with this ID")
+	default:
+		h.Logger.Error("Failed to replay dead letter", "dead_letter_id", id, "error", err)
+		apierror.Write(w, r, http.StatusServiceUnavailable, apierror.CodeUnavailable, "Failed to replay dead letter")
+	}
+}
+
+// HandleRedrive queues every dead letter matching the filter query
+// parameters again, with fresh attempt counts.
+func (h *DeadLetterHandler) HandleRedrive(w http.ResponseWriter, r *http.Request) {
+	filter, err := deadLetterFilter(r)
+	if err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+		return
+	}
+	redriven, err := h.Pool.RedriveDeadLetters(r.Context(), filter)
+	h.Logger.Info("Dead letters redriven via admin API", "redriven", redriven, "error", err)
+	h.writeRedriven(w, redriven, err)
+}
+
+// HandleTrigger fires the {trigger} URL parameter, e.g. token_rotated,
+// redriving the dead letters whose triage rules name it.
+func (h *DeadLetterHandler) HandleTrigger(w http.ResponseWriter, r *http.Request) {
+	trigger := chi.URLParam(r, "trigger")
+	redriven, err := h.Pool.Trigger(r.Context(), trigger)
+	h.Logger.Info("Dead-letter trigger fired via admin API", "trigger", trigger, "redriven", redriven, "error", err)
+	h.writeRedriven(w, redriven, err)
+}
+
+// writeRedriven reports how many dead letters were redriven. On failure it
+// also reports the error; the rest stay in the dead-letter queue.
+func (h *DeadLetterHandler) writeRedriven(w http.ResponseWriter, redriven int, err error) {
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		writeJSON(w, map[string]any{"redriven": redriven, "error": err.Error()})
+		return
+	}
+	writeJSON(w, map[string]any{"redriven": redriven})
+}
@@ -0,0 +1,133 @@
+package admin
+
+import (
+	"encoding/json"
+	"gusto-webhook-guide/internal/models"
+	"gusto-webhook-guide/internal/worker"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestDeadLetterHandler(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	pool := worker.NewPool(10, 0, logger, worker.NewIdempotencyStore(), worker.ProcessorFunc(nil))
+	defer pool.Stop()
+	pool.SetTriageRules([]worker.TriageRule{{Reason: worker.ReasonAuthError, On: worker.TriggerTokenRotated}})
+	pool.DeadLetters().Add(worker.DeadLetter{EventUUID: "a", Reason: worker.ReasonAuthError, Payload: []byte(`{"uuid":"a"}`)})
+	pool.DeadLetters().Add(worker.DeadLetter{EventUUID: "b", Reason: worker.ReasonHandlerBug, Payload: []byte(`{"uuid":"b"}`)})
+
+	h := &DeadLetterHandler{Logger: logger, Pool: pool}
+	router := chi.NewRouter()
+	router.Get("/admin/dlq", h.HandleList)
+	router.Post("/admin/dlq/triggers/{trigger}", h.HandleTrigger)
+
+	testCases := []struct {
+		name               string
+		method             string
+		path               string
+		expectedStatusCode int
+		expectedCount      int // Entries listed, or jobs redriven.
+	}{
+		{name: "List All", method: "GET", path: "/admin/dlq", expectedStatusCode: http.StatusOK, expectedCount: 2},
+		{name: "List By Reason", method: "GET", path: "/admin/dlq?reason=auth_error", expectedStatusCode: http.StatusOK, expectedCount: 1},
+		{name: "Unrelated Trigger", method: "POST", path: "/admin/dlq/triggers/deployed", expectedStatusCode: http.StatusOK, expectedCount: 0},
+		{name: "Token Rotated", method: "POST", path: "/admin/dlq/triggers/token_rotated", expectedStatusCode: http.StatusOK, expectedCount: 1},
+		{name: "List After Redrive", method: "GET", path: "/admin/dlq", expectedStatusCode: http.StatusOK, expectedCount: 1},
+	}
+
+	// Cases run in order: the auth error is redriven partway through.
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest(tc.method, tc.path, nil))
+			if rr.Code != tc.expectedStatusCode {
+				t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, tc.expectedStatusCode)
+			}
+			var resp struct {
+				DeadLetters []worker.DeadLetter `json:"dead_letters"`
+				Redriven    int                 `json:"redriven"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("invalid JSON response: %v", err)
+			}
+			if got := len(resp.DeadLetters) + resp.Redriven; got != tc.expectedCount {
+				t.Errorf("incorrect count: got %d want %d", got, tc.expectedCount)
+			}
+		})
+	}
+
+	var job models.Job
+	select {
+	case job = <-pool.JobQueue:
+	default:
+		t.Fatalf("expected the redriven job to be queued")
+	}
+	if string(job.Payload) != `{"uuid":"a"}` {
+		t.Errorf("incorrect redriven job: got %s want %s", job.Payload, `{"uuid":"a"}`)
+	}
+}
+
+func TestDeadLetterReplayAndRedrive(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	pool := worker.NewPool(10, 0, logger, worker.NewIdempotencyStore(), worker.ProcessorFunc(nil))
+	defer pool.Stop()
+	day := func(d int) time.Time { return time.Date(2026, 10, d, 12, 0, 0, 0, time.UTC) }
+	single, _ := pool.DeadLetters().Add(worker.DeadLetter{EventUUID: "a", EventType: "company.updated", DeadLetteredAt: day(1)})
+	pool.DeadLetters().Add(worker.DeadLetter{EventUUID: "b", EventType: "company.updated", DeadLetteredAt: day(2)})
+	pool.DeadLetters().Add(worker.DeadLetter{EventUUID: "c", EventType: "payroll.processed", DeadLetteredAt: day(3)})
+	pool.DeadLetters().Add(worker.DeadLetter{EventUUID: "d", EventType: "company.updated", DeadLetteredAt: day(4)})
+
+	h := &DeadLetterHandler{Logger: logger, Pool: pool}
+	router := chi.NewRouter()
+	router.Post("/admin/dlq/redrive", h.HandleRedrive)
+	router.Post("/admin/dlq/{id}/replay", h.HandleReplay)
+
+	testCases := []struct {
+		name               string
+		path               string
+		expectedStatusCode int
+		expectedRedriven   int
+	}{
+		{name: "Replay One", path: "/admin/dlq/" + single.ID + "/replay", expectedStatusCode: http.StatusAccepted},
+		{name: "Replay Again", path: "/admin/dlq/" + single.ID + "/replay", expectedStatusCode: http.StatusNotFound},
+		{name: "Invalid Time", path: "/admin/dlq/redrive?since=yesterday", expectedStatusCode: http.StatusBadRequest},
+		{
+			name:               "Redrive By Type And Time",
+			path:               "/admin/dlq/redrive?event_type=company.updated&until=2026-10-03T00:00:00Z",
+			expectedStatusCode: http.StatusOK,
+			expectedRedriven:   1,
+		},
+		{name: "Redrive Rest", path: "/admin/dlq/redrive", expectedStatusCode: http.StatusOK, expectedRedriven: 2},
+	}
+
+	// Cases run in order, each redriving what the earlier ones left.
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest("POST", tc.path, nil))
+			if rr.Code != tc.expectedStatusCode {
+				t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, tc.expectedStatusCode)
+			}
+			if rr.Code != http.StatusOK {
+				return
+			}
+			var resp struct {
+				Redriven int `json:"redriven"`
+			}
+			json.Unmarshal(rr.Body.Bytes(), &resp)
+			if resp.Redriven != tc.expectedRedriven {
+				t.Errorf("incorrect redriven count: got %d want %d", resp.Redriven, tc.expectedRedriven)
+			}
+		})
+	}
+
+	if got := len(pool.JobQueue); got != 4 {
+		t.Errorf("incorrect number of queued jobs: got %d want 4", got)
+	}
+}
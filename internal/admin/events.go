@@ -0,0 +1,39 @@
+package admin
+
+import (
+	"gusto-webhook-guide/internal/apierror"
+	"gusto-webhook-guide/internal/worker"
+	"net/http"
+	"time"
+)
+
+// UnhandledHandler serves /admin/events/unhandled, a report of the event
+// types that arrived in the last 24 hours with no handler.
+type UnhandledHandler struct {
+	Tracker *worker.UnhandledTracker
+}
+
+// unhandledListing sorts the report, most frequent event type first by
+// default.
+var unhandledListing = listing[worker.UnhandledType]{
+	ID: func(u worker.UnhandledType) string { return u.EventType },
+	Fields: map[string]func(worker.UnhandledType) string{
+		"count":      func(u worker.UnhandledType) string { return intKey(u.Count) },
+		"event_type": func(u worker.UnhandledType) string { return u.EventType },
+		"last_seen":  func(u worker.UnhandledType) string { return timeKey(u.LastSeen) },
+	},
+	DefaultSort: "-count",
+}
+
+// HandleReport serves a page of the report, paged as described by listing.
+func (h *UnhandledHandler) HandleReport(w http.ResponseWriter, r *http.Request) {
+	types, next, err := unhandledListing.paginate(r, h.Tracker.Report(time.Now().UTC()))
+	if err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+		return
+	}
+	if types == nil {
+		types = []worker.UnhandledType{}
+	}
+	writeJSON(w, pageResponse("event_types", types, next))
+}
@@ -0,0 +1,33 @@
+package admin
+
+import (
+	"encoding/json"
+	"gusto-webhook-guide/internal/worker"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHandleUnhandledReport(t *testing.T) {
+	tracker := worker.NewUnhandledTracker()
+	tracker.Observe(slog.New(slog.NewJSONHandler(io.Discard, nil)), "payroll.cancelled", time.Now().UTC())
+	h := &UnhandledHandler{Tracker: tracker}
+
+	rr := httptest.NewRecorder()
+	h.HandleReport(rr, httptest.NewRequest("GET", "/admin/events/unhandled", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	var resp struct {
+		EventTypes []worker.UnhandledType `json:"event_types"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid JSON response: %v", err)
+	}
+	if len(resp.EventTypes) != 1 || resp.EventTypes[0].EventType != "payroll.cancelled" {
+		t.Errorf("incorrect report: got %+v", resp.EventTypes)
+	}
+}
@@ -0,0 +1,242 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gusto-webhook-guide/internal/apierror"
+	"gusto-webhook-guide/internal/worker"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// IdempotencyHandler serves the /admin/idempotency endpoints, which let an
+// operator inspect dedup keys and delete one to allow an event to be
+// processed again.
+type IdempotencyHandler struct {
+	Logger  *slog.Logger
+	Store   worker.Store
+	Results worker.ResultStore // Optional; adds what processing produced to HandleGet.
+}
+
+// entryResponse is an idempotency key with the result of the event's last
+// attempt, if one was kept.
+type entryResponse struct {
+	worker.Entry
+	Result *worker.Result `json:"result,omitempty"`
+}
+
+// listResponse is a page of idempotency keys.
+type listResponse struct {
+	Keys       []worker.Entry `json:"keys"`
+	NextCursor string         `json:"next_cursor,omitempty"` // Pass as ?cursor= for the next page.
+}
+
+// HandleList serves a page of keys. Query parameters: event_type filters by
+// event type, and limit and cursor page through the keys as for other list
+// endpoints (see listing). Keys come in the store's own order, so sort isn't
+// supported.
+func (h *IdempotencyHandler) HandleList(w http.ResponseWriter, r *http.Request) {
+	lister, ok := h.Store.(worker.Lister)
+	if !ok {
+		apierror.Write(w, r, http.StatusNotImplemented, apierror.CodeNotImplemented, "Listing is not supported by this idempotency store")
+		return
+	}
+
+	query := r.URL.Query()
+	limit, err := parseLimit(query.Get("limit"))
+	if err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+		return
+	}
+
+	entries, next, err := lister.List(r.Context(), worker.ListOptions{
+		EventType: query.Get("event_type"),
+		Cursor:    query.Get("cursor"),
+		Limit:     limit,
+	})
+	if errors.Is(err, worker.ErrNotListable) {
+		apierror.Write(w, r, http.StatusNotImplemented, apierror.CodeNotImplemented, "Listing is not supported by this idempotency store")
+		return
+	}
+	if err != nil {
+		h.Logger.Error("Failed to list idempotency keys", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to list idempotency keys")
+		return
+	}
+	if entries == nil {
+		entries = []worker.Entry{}
+	}
+	writeJSON(w, listResponse{Keys: entries, NextCursor: next})
+}
+
+// HandleGet serves the record for the {uuid} URL parameter.
+func (h *IdempotencyHandler) HandleGet(w http.ResponseWriter, r *http.Request) {
+	key := chi.URLParam(r, "uuid")
+	rec, found, err := h.Store.Get(r.Context(), key)
+	if err != nil {
+		h.Logger.Error("Failed to read idempotency key", "event_uuid", key, "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to read idempotency key")
+		return
+	}
+	if !found {
+		apierror.Write(w, r, http.StatusNotFound, apierror.CodeNotFound, "No idempotency key for this event")
+		return
+	}
+	resp := entryResponse{Entry: worker.Entry{Key: key, Record: rec}}
+	if resp.Result, err = getResult(r, h.Results, key); err != nil {
+		h.Logger.Error("Failed to read event result", "event_uuid", key, "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to read event result")
+		return
+	}
+	writeJSON(w, resp)
+}
+
+// getResult returns the stored result for an event, or nil if there is none
+// or results is nil.
+func getResult(r *http.Request, results worker.ResultStore, eventUUID string) (*worker.Result, error) {
+	if results == nil {
+		return nil, nil
+	}
+	result, found, err := results.GetResult(r.Context(), eventUUID)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// HandleDelete removes the key for the {uuid} URL parameter, so the next
+// delivery of that event is processed instead of being ignored as a duplicate.
+func (h *IdempotencyHandler) HandleDelete(w http.ResponseWriter, r *http.Request) {
+	key := chi.URLParam(r, "uuid")
+	rec, found, err := h.Store.Get(r.Context(), key)
+	if err != nil {
+		h.Logger.Error("Failed to read idempotency key", "event_uuid", key, "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to read idempotency key")
+		return
+	}
+	if !found {
+		apierror.Write(w, r, http.StatusNotFound, apierror.CodeNotFound, "No idempotency key for this event")
+		return
+	}
+	if err := h.Store.Delete(r.Context(), key); err != nil {
+		h.Logger.Error("Failed to delete idempotency key", "event_uuid", key, "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to delete idempotency key")
+		return
+	}
+
+	h.Logger.Info("Deleted idempotency key via admin API", "event_uuid", key, "event_type", rec.EventType, "status", rec.Status)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// expireFilter selects the keys HandleExpire deletes.
+type expireFilter struct {
+	EventType string
+	Since     time.Time // Processed at or after.
+	Until     time.Time // Processed before.
+}
+
+// matches reports whether e passes the filter.
+func (f expireFilter) matches(e worker.Entry) bool {
+	return (f.EventType == "" || e.EventType == f.EventType) &&
+		(f.Since.IsZero() || !e.ProcessedAt.Before(f.Since)) &&
+		(f.Until.IsZero() || e.ProcessedAt.Before(f.Until))
+}
+
+// expireResponse reports what HandleExpire did.
+type expireResponse struct {
+	Expired  int `json:"expired"`
+	InFlight int `json:"in_flight"` // Matching keys left alone because a worker holds them.
+}
+
+// expireFailedResponse is the body of a 500 from HandleExpire, reporting
+// how far it got before a deletion failed.
+type expireFailedResponse struct {
+	apierror.Error
+	expireResponse
+}
+
+// HandleExpire deletes every key matching the query parameters, event_type
+// and since and until as RFC 3339 times compared with when the key was last
+// written, so that those events are processed again when they are next
+// delivered or replayed, e.g. after a fix during incident recovery. At
+// least one parameter is required. Keys claimed by a running attempt are
+// left alone, since deleting them would let a redelivery run alongside it.
+func (h *IdempotencyHandler) HandleExpire(w http.ResponseWriter, r *http.Request) {
+	lister, ok := h.Store.(worker.Lister)
+	if !ok {
+		apierror.Write(w, r, http.StatusNotImplemented, apierror.CodeNotImplemented, "Listing is not supported by this idempotency store")
+		return
+	}
+
+	query := r.URL.Query()
+	filter := expireFilter{EventType: query.Get("event_type")}
+	for name, t := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if raw := query.Get(name); raw != "" {
+			parsed, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, fmt.Sprintf("%s must be an RFC 3339 time", name))
+				return
+			}
+			*t = parsed
+		}
+	}
+	if filter == (expireFilter{}) {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, "At least one of event_type, since and until is required")
+		return
+	}
+
+	// Collect every match before deleting, so that deletions don't upset
+	// the store's cursors.
+	var keys []string
+	var resp expireResponse
+	cursor := ""
+	for {
+		entries, next, err := lister.List(r.Context(), worker.ListOptions{EventType: filter.EventType, Cursor: cursor, Limit: 500})
+		if errors.Is(err, worker.ErrNotListable) {
+			apierror.Write(w, r, http.StatusNotImplemented, apierror.CodeNotImplemented, "Listing is not supported by this idempotency store")
+			return
+		}
+		if err != nil {
+			h.Logger.Error("Failed to list idempotency keys", "error", err)
+			apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to list idempotency keys")
+			return
+		}
+		for _, e := range entries {
+			switch {
+			case !filter.matches(e):
+			case e.Status == worker.StatusProcessing:
+				resp.InFlight++
+			default:
+				keys = append(keys, e.Key)
+			}
+		}
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+
+	for _, key := range keys {
+		if err := h.Store.Delete(r.Context(), key); err != nil {
+			h.Logger.Error("Failed to expire idempotency keys", "expired", resp.Expired, "error", err)
+			apierror.WriteJSON(w, http.StatusInternalServerError, expireFailedResponse{
+				Error:          apierror.New(r, apierror.CodeInternal, "Failed to expire idempotency keys"),
+				expireResponse: resp,
+			})
+			return
+		}
+		resp.Expired++
+	}
+	h.Logger.Info("Expired idempotency keys via admin API", "event_type", filter.EventType,
+		"since", filter.Since, "until", filter.Until, "expired", resp.Expired, "in_flight", resp.InFlight)
+	writeJSON(w, resp)
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,313 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"gusto-webhook-guide/internal/apierror"
+	"gusto-webhook-guide/internal/worker"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func newTestRouter(store worker.Store) http.Handler {
+	h := &IdempotencyHandler{Logger: slog.New(slog.NewJSONHandler(io.Discard, nil)), Store: store}
+	router := chi.NewRouter()
+	router.Get("/admin/idempotency", h.HandleList)
+	router.Get("/admin/idempotency/{uuid}", h.HandleGet)
+	router.Delete("/admin/idempotency/{uuid}", h.HandleDelete)
+	router.Post("/admin/idempotency/expire", h.HandleExpire)
+	return router
+}
+
+func TestHandleList(t *testing.T) {
+	ctx := context.Background()
+	store := worker.NewIdempotencyStore()
+	for _, key := range []string{"a", "b", "c"} {
+		store.Set(ctx, key, worker.Record{EventType: "company.updated", Status: worker.StatusSucceeded})
+	}
+	store.Set(ctx, "d", worker.Record{EventType: "payroll.processed", Status: worker.StatusSucceeded})
+	router := newTestRouter(store)
+
+	testCases := []struct {
+		name               string
+		query              string
+		expectedStatusCode int
+		expectedKeys       []string
+		expectedNext       string
+	}{
+		{
+			name:               "All Keys",
+			query:              "",
+			expectedStatusCode: http.StatusOK,
+			expectedKeys:       []string{"a", "b", "c", "d"},
+		},
+		{
+			name:               "First Page",
+			query:              "?limit=2",
+			expectedStatusCode: http.StatusOK,
+			expectedKeys:       []string{"a", "b"},
+			expectedNext:       "b",
+		},
+		{
+			name:               "Next Page",
+			query:              "?limit=2&cursor=b",
+			expectedStatusCode: http.StatusOK,
+			expectedKeys:       []string{"c", "d"},
+		},
+		{
+			name:               "Filtered By Event Type",
+			query:              "?event_type=payroll.processed",
+			expectedStatusCode: http.StatusOK,
+			expectedKeys:       []string{"d"},
+		},
+		{
+			name:               "Invalid Limit",
+			query:              "?limit=0",
+			expectedStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest("GET", "/admin/idempotency"+tc.query, nil))
+
+			if rr.Code != tc.expectedStatusCode {
+				t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, tc.expectedStatusCode)
+			}
+			if rr.Code != http.StatusOK {
+				return
+			}
+			var resp listResponse
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("invalid JSON response: %v", err)
+			}
+			var keys []string
+			for _, e := range resp.Keys {
+				keys = append(keys, e.Key)
+			}
+			if len(keys) != len(tc.expectedKeys) {
+				t.Fatalf("incorrect keys: got %v want %v", keys, tc.expectedKeys)
+			}
+			for i := range keys {
+				if keys[i] != tc.expectedKeys[i] {
+					t.Errorf("incorrect keys: got %v want %v", keys, tc.expectedKeys)
+				}
+			}
+			if resp.NextCursor != tc.expectedNext {
+				t.Errorf("incorrect next cursor: got %q want %q", resp.NextCursor, tc.expectedNext)
+			}
+		})
+	}
+}
+
+// storeOnly hides any optional interfaces of the wrapped store.
+type storeOnly struct{ worker.Store }
+
+func TestHandleListUnsupported(t *testing.T) {
+	router := newTestRouter(storeOnly{worker.NewIdempotencyStore()})
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/admin/idempotency", nil))
+	if rr.Code != http.StatusNotImplemented {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusNotImplemented)
+	}
+}
+
+func TestHandleGetAndDelete(t *testing.T) {
+	ctx := context.Background()
+	store := worker.NewIdempotencyStore()
+	store.Set(ctx, "known-uuid", worker.Record{EventType: "company.updated", Status: worker.StatusSucceeded})
+	router := newTestRouter(store)
+
+	testCases := []struct {
+		name               string
+		method             string
+		path               string
+		expectedStatusCode int
+	}{
+		{name: "Get Known Key", method: "GET", path: "/admin/idempotency/known-uuid", expectedStatusCode: http.StatusOK},
+		{name: "Get Unknown Key", method: "GET", path: "/admin/idempotency/unknown-uuid", expectedStatusCode: http.StatusNotFound},
+		{name: "Delete Unknown Key", method: "DELETE", path: "/admin/idempotency/unknown-uuid", expectedStatusCode: http.StatusNotFound},
+		{name: "Delete Known Key", method: "DELETE", path: "/admin/idempotency/known-uuid", expectedStatusCode: http.StatusNoContent},
+		{name: "Get Deleted Key", method: "GET", path: "/admin/idempotency/known-uuid", expectedStatusCode: http.StatusNotFound},
+	}
+
+	// Cases run in order: the key is deleted partway through.
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest(tc.method, tc.path, nil))
+			if rr.Code != tc.expectedStatusCode {
+				t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, tc.expectedStatusCode)
+			}
+		})
+	}
+}
+
+func TestHandleGetWithResult(t *testing.T) {
+	ctx := context.Background()
+	store := worker.NewIdempotencyStore()
+	store.Set(ctx, "with-result", worker.Record{EventType: "employee.created", Status: worker.StatusSucceeded})
+	store.Set(ctx, "without-result", worker.Record{EventType: "employee.created", Status: worker.StatusSucceeded})
+	results := worker.NewMemoryResultStore(0)
+	results.PutResult(ctx, "with-result", worker.Result{
+		EventType: "employee.created",
+		Attempt:   1,
+		Artifacts: []worker.Artifact{{Kind: "employee", ID: "emp-1", Action: "created"}},
+	})
+	h := &IdempotencyHandler{Logger: slog.New(slog.NewJSONHandler(io.Discard, nil)), Store: store, Results: results}
+	router := chi.NewRouter()
+	router.Get("/admin/idempotency/{uuid}", h.HandleGet)
+
+	testCases := []struct {
+		name              string
+		path              string
+		expectedArtifacts int
+	}{
+		{name: "With Result", path: "/admin/idempotency/with-result", expectedArtifacts: 1},
+		{name: "Without Result", path: "/admin/idempotency/without-result", expectedArtifacts: 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest("GET", tc.path, nil))
+			if rr.Code != http.StatusOK {
+				t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+			}
+			var resp entryResponse
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decoding response: %v", err)
+			}
+			if resp.Status != worker.StatusSucceeded {
+				t.Errorf("incorrect status: got %q want %q", resp.Status, worker.StatusSucceeded)
+			}
+			if tc.expectedArtifacts == 0 {
+				if resp.Result != nil {
+					t.Errorf("expected no result, got %+v", resp.Result)
+				}
+				return
+			}
+			if resp.Result == nil || len(resp.Result.Artifacts) != tc.expectedArtifacts {
+				t.Errorf("incorrect result: %+v", resp.Result)
+			}
+		})
+	}
+}
+
+func TestHandleExpire(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	seed := func() *worker.IdempotencyStore {
+		store := worker.NewIdempotencyStore()
+		store.Set(ctx, "old", worker.Record{EventType: "company.updated", Status: worker.StatusSucceeded, ProcessedAt: now.Add(-2 * time.Hour)})
+		store.Set(ctx, "recent", worker.Record{EventType: "company.updated", Status: worker.StatusSucceeded, ProcessedAt: now.Add(-30 * time.Minute)})
+		store.Set(ctx, "failed", worker.Record{EventType: "company.updated", Status: worker.StatusDeadLettered, ProcessedAt: now.Add(-10 * time.Minute)})
+		store.Set(ctx, "running", worker.Record{EventType: "company.updated", Status: worker.StatusProcessing, ProcessedAt: now.Add(-time.Minute)})
+		store.Set(ctx, "other", worker.Record{EventType: "payroll.processed", Status: worker.StatusSucceeded, ProcessedAt: now.Add(-5 * time.Minute)})
+		return store
+	}
+
+	testCases := []struct {
+		name               string
+		query              string
+		expectedStatusCode int
+		expectedExpired    int
+		expectedInFlight   int
+		expectedLeft       []string
+	}{
+		{
+			name:               "Event Type in the Last Hour",
+			query:              "?event_type=company.updated&since=2026-01-01T11:00:00Z",
+			expectedStatusCode: http.StatusOK,
+			expectedExpired:    2,
+			expectedInFlight:   1,
+			expectedLeft:       []string{"old", "running", "other"},
+		},
+		{
+			name:               "Time Range Only",
+			query:              "?since=2026-01-01T11:00:00Z&until=2026-01-01T11:55:00Z",
+			expectedStatusCode: http.StatusOK,
+			expectedExpired:    2,
+			expectedLeft:       []string{"old", "running", "other"},
+		},
+		{
+			name:               "No Filter",
+			query:              "",
+			expectedStatusCode: http.StatusBadRequest,
+			expectedLeft:       []string{"old", "recent", "failed", "running", "other"},
+		},
+		{
+			name:               "Invalid Time",
+			query:              "?event_type=company.updated&since=an-hour-ago",
+			expectedStatusCode: http.StatusBadRequest,
+			expectedLeft:       []string{"old", "recent", "failed", "running", "other"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := seed()
+			req := httptest.NewRequest("POST", "/admin/idempotency/expire"+tc.query, nil)
+			rr := httptest.NewRecorder()
+			newTestRouter(store).ServeHTTP(rr, req)
+
+			if rr.Code != tc.expectedStatusCode {
+				t.Fatalf("incorrect status code: got %d want %d", rr.Code, tc.expectedStatusCode)
+			}
+			if rr.Code == http.StatusOK {
+				var body expireResponse
+				if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+					t.Fatalf("decoding response: %v", err)
+				}
+				if body.Expired != tc.expectedExpired || body.InFlight != tc.expectedInFlight {
+					t.Errorf("incorrect response: got %+v want %d expired and %d in flight", body, tc.expectedExpired, tc.expectedInFlight)
+				}
+			}
+			entries, _, _ := store.List(ctx, worker.ListOptions{})
+			if len(entries) != len(tc.expectedLeft) {
+				t.Errorf("incorrect keys left: got %d want %v", len(entries), tc.expectedLeft)
+			}
+			for _, key := range tc.expectedLeft {
+				if _, found, _ := store.Get(ctx, key); !found {
+					t.Errorf("Expected key %q to be kept", key)
+				}
+			}
+		})
+	}
+}
+
+// failingDeleteStore fails every Delete.
+type failingDeleteStore struct{ *worker.IdempotencyStore }
+
+func (failingDeleteStore) Delete(context.Context, string) error {
+	return errors.New("store unavailable")
+}
+
+func TestHandleExpireDeleteFails(t *testing.T) {
+	store := worker.NewIdempotencyStore()
+	store.Set(context.Background(), "old", worker.Record{EventType: "company.updated", Status: worker.StatusSucceeded, ProcessedAt: time.Now()})
+	req := httptest.NewRequest("POST", "/admin/idempotency/expire?event_type=company.updated", nil)
+	rr := httptest.NewRecorder()
+	newTestRouter(failingDeleteStore{store}).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Fatalf("incorrect status code: got %d want %d", rr.Code, http.StatusInternalServerError)
+	}
+	if got := rr.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("incorrect content type: got %q want %q", got, "application/json")
+	}
+	var body expireFailedResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if body.Code != apierror.CodeInternal || body.Message == "" || body.Expired != 0 {
+		t.Errorf("incorrect response: %s", rr.Body.String())
+	}
+}
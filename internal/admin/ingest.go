@@ -0,0 +1,167 @@
+package admin
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gusto-webhook-guide/internal/contextkeys"
+	"gusto-webhook-guide/internal/models"
+	"gusto-webhook-guide/internal/worker"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+const (
+	// maxIngestLineBytes bounds one event of a streamed ingestion.
+	maxIngestLineBytes = 1 << 20
+	// ingestFlushEvery is how many results are buffered before they are
+	// flushed to the client.
+	ingestFlushEvery = 100
+)
+
+// Statuses of the lines of a streamed ingestion.
+const (
+	IngestAccepted = "accepted"
+	IngestRejected = "rejected"
+)
+
+// IngestHandler serves /admin/ingest/stream, which queues events streamed
+// as NDJSON, e.g. to replay a multi-gigabyte export of past events, without
+// holding the stream in memory or making a request per event.
+type IngestHandler struct {
+	Logger *slog.Logger
+	Queue  worker.JobQueue
+}
+
+// ingestResult is the outcome for one line of a streamed ingestion.
+type ingestResult struct {
+	Line      int    `json:"line"` // 1-based.
+	EventUUID string `json:"event_uuid,omitempty"`
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+}
+
+// ingestSummary ends the response to a streamed ingestion.
+type ingestSummary struct {
+	Done     bool   `json:"done"`
+	Lines    int    `json:"lines"`
+	Accepted int    `json:"accepted"`
+	Rejected int    `json:"rejected"`
+	Error    string `json:"error,omitempty"` // Why ingestion stopped before the end of the stream.
+}
+
+// HandleStream queues each line of the request body, one event JSON per
+// line as Gusto delivers it, as a new job, and streams back an NDJSON
+// result per non-blank line, in order, followed by a summary. Queueing
+// waits for room in a full queue, which slows the upload down rather than
+// rejecting events. Lines that aren't valid events are rejected and the
+// rest carry on; if queueing or reading the stream fails, ingestion stops
+// and the summary reports the error. Events queued before are dropped as
+// duplicates by the workers, so a failed stream can be sent again whole.
+func (h *IngestHandler) HandleStream(w http.ResponseWriter, r *http.Request) {
+	rc := http.NewResponseController(w)
+	// Results are written while the body is still being read. HTTP/2 always
+	// allows this; for HTTP/1 it must be enabled, and may not be supported.
+	rc.EnableFullDuplex()
+	requestID, _ := r.Context().Value(contextkeys.RequestIDKey).(string)
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	enc := json.NewEncoder(w)
+	body := bufio.NewReaderSize(r.Body, maxIngestLineBytes)
+	var summary ingestSummary
+	for {
+		line, err := readIngestLine(body)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		summary.Lines++
+		var result ingestResult
+		switch {
+		case errors.Is(err, bufio.ErrBufferFull):
+			result = ingestResult{Status: IngestRejected, Error: fmt.Sprintf("line is longer than %d bytes", maxIngestLineBytes)}
+		case err != nil:
+			summary.Error = fmt.Sprintf("reading line %d: %v", summary.Lines, err)
+		case len(line) == 0:
+			continue
+		default:
+			result, err = h.ingest(r, line, requestID)
+			if err != nil {
+				summary.Error = fmt.Sprintf("queueing line %d: %v", summary.Lines, err)
+			}
+		}
+		if summary.Error != "" {
+			break
+		}
+
+		result.Line = summary.Lines
+		if result.Status == IngestAccepted {
+			summary.Accepted++
+		} else {
+			summary.Rejected++
+		}
+		enc.Encode(result)
+		if (summary.Accepted+summary.Rejected)%ingestFlushEvery == 0 {
+			rc.Flush()
+		}
+	}
+
+	summary.Done = summary.Error == ""
+	h.Logger.Info("Event stream ingested via admin API", "lines", summary.Lines, "accepted", summary.Accepted,
+		"rejected", summary.Rejected, "error", summary.Error)
+	enc.Encode(summary)
+	rc.Flush()
+}
+
+// ingest validates one line and queues it. An error means queueing failed
+// and the stream should stop.
+func (h *IngestHandler) ingest(r *http.Request, line []byte, requestID string) (ingestResult, error) {
+	var event struct {
+		UUID      string `json:"uuid"`
+		EventType string `json:"event_type"`
+	}
+	if err := json.Unmarshal(line, &event); err != nil {
+		return ingestResult{Status: IngestRejected, Error: "invalid event JSON"}, nil
+	}
+	result := ingestResult{EventUUID: event.UUID, Status: IngestRejected}
+	switch {
+	case event.UUID == "":
+		result.Error = `field "uuid" is required and must be a non-empty string`
+	case event.EventType == "":
+		result.Error = `field "event_type" is required and must be a non-empty string`
+	default:
+		job := models.Job{Payload: bytes.Clone(line), ReceivedAt: time.Now().UTC(), RequestID: requestID}
+		if err := h.Queue.Enqueue(r.Context(), job, -1); err != nil {
+			return result, err
+		}
+		result.Status = IngestAccepted
+	}
+	return result, nil
+}
+
+// readIngestLine returns the next line of body without its line ending, or
+// io.EOF at the end. A line longer than body's buffer is skipped and
+// reported as bufio.ErrBufferFull.
+func readIngestLine(body *bufio.Reader) ([]byte, error) {
+	line, err := body.ReadSlice('\n')
+	if errors.Is(err, bufio.ErrBufferFull) {
+		for errors.Is(err, bufio.ErrBufferFull) {
+			_, err = body.ReadSlice('\n')
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		return nil, bufio.ErrBufferFull
+	}
+	if errors.Is(err, io.EOF) && len(line) > 0 {
+		err = nil // The last line has no line ending.
+	}
+	if err != nil {
+		return nil, err
+	}
+	return bytes.TrimSpace(line), nil
+}
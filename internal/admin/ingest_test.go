@@ -0,0 +1,78 @@
+package admin
+
+import (
+	"bufio"
+	"encoding/json"
+	"gusto-webhook-guide/internal/models"
+	"gusto-webhook-guide/internal/worker"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandleStream(t *testing.T) {
+	jobs := make(chan models.Job, 10)
+	h := &IngestHandler{Logger: slog.New(slog.NewJSONHandler(io.Discard, nil)), Queue: worker.ChannelQueue(jobs)}
+
+	body := strings.Join([]string{
+		`{"uuid":"a","event_type":"company.updated"}`,
+		``,
+		`not json`,
+		`{"uuid":"b"}`,
+		`{"uuid":"` + strings.Repeat("x", maxIngestLineBytes) + `","event_type":"company.updated"}`,
+		`{"uuid":"c","event_type":"payroll.processed"}`, // No trailing line ending.
+	}, "\n")
+	rr := httptest.NewRecorder()
+	h.HandleStream(rr, httptest.NewRequest("POST", "/admin/ingest/stream", strings.NewReader(body)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	var results []ingestResult
+	var summary ingestSummary
+	scanner := bufio.NewScanner(rr.Body)
+	for scanner.Scan() {
+		if strings.Contains(scanner.Text(), `"done"`) {
+			json.Unmarshal(scanner.Bytes(), &summary)
+			continue
+		}
+		var result ingestResult
+		json.Unmarshal(scanner.Bytes(), &result)
+		results = append(results, result)
+	}
+
+	expected := []ingestResult{
+		{Line: 1, EventUUID: "a", Status: IngestAccepted},
+		{Line: 3, Status: IngestRejected},
+		{Line: 4, EventUUID: "b", Status: IngestRejected},
+		{Line: 5, Status: IngestRejected},
+		{Line: 6, EventUUID: "c", Status: IngestAccepted},
+	}
+	if len(results) != len(expected) {
+		t.Fatalf("incorrect number of results: got %d want %d: %s", len(results), len(expected), rr.Body.String())
+	}
+	for i, want := range expected {
+		got := results[i]
+		if got.Line != want.Line || got.EventUUID != want.EventUUID || got.Status != want.Status {
+			t.Errorf("incorrect result %d: got %+v want %+v", i, got, want)
+		}
+		if (got.Status == IngestRejected) != (got.Error != "") {
+			t.Errorf("result %d: expected an error only for rejected lines: %+v", i, got)
+		}
+	}
+	if want := (ingestSummary{Done: true, Lines: 6, Accepted: 2, Rejected: 3}); summary != want {
+		t.Errorf("incorrect summary: got %+v want %+v", summary, want)
+	}
+
+	close(jobs)
+	var queued []string
+	for job := range jobs {
+		queued = append(queued, string(job.Payload))
+	}
+	if len(queued) != 2 || queued[1] != `{"uuid":"c","event_type":"payroll.processed"}` {
+		t.Errorf("incorrect queued jobs: %q", queued)
+	}
+}
@@ -0,0 +1,151 @@
+package admin
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultPageSize = 100
+	maxPageSize     = 1000
+)
+
+// errInvalidCursor is returned for a cursor that wasn't issued for the
+// requested sort order.
+var errInvalidCursor = errors.New("invalid cursor")
+
+// listing describes how a list endpoint's items are sorted. Every list
+// endpoint takes the same query parameters:
+//
+//   - limit: the page size, from 1 to maxPageSize (default defaultPageSize).
+//   - sort: a field name, prefixed with "-" for descending order.
+//   - cursor: the next_cursor of the previous page.
+//
+// Items are ordered by the sort field and then by ID, and a cursor records
+// where the previous page ended rather than an offset, so items added or
+// removed between requests don't shift later pages.
+type listing[T any] struct {
+	// ID returns a unique, stable identifier for an item.
+	ID func(T) string
+	// Fields maps each sortable field to the key an item sorts by. Keys are
+	// compared as strings, so use timeKey and intKey for times and numbers.
+	Fields map[string]func(T) string
+	// DefaultSort is the sort parameter used when none is given.
+	DefaultSort string
+}
+
+// pageCursor is the decoded form of a cursor: the sort it was issued for,
+// and the sort key and ID of the last item returned.
+type pageCursor struct {
+	Sort string `json:"s"`
+	Key  string `json:"k"`
+	ID   string `json:"i"`
+}
+
+// paginate returns the page of items selected by r's query parameters, and
+// the cursor for the next page, or "" if this is the last. items may be in
+// any order. It returns an error suitable for a 400 response if the
+// parameters are invalid.
+func (l listing[T]) paginate(r *http.Request, items []T) ([]T, string, error) {
+	query := r.URL.Query()
+	limit, err := parseLimit(query.Get("limit"))
+	if err != nil {
+		return nil, "", err
+	}
+	sortParam := cmp.Or(query.Get("sort"), l.DefaultSort)
+	field, desc := strings.CutPrefix(sortParam, "-")
+	key, known := l.Fields[field]
+	if !known {
+		return nil, "", fmt.Errorf("sort must be one of %s, optionally prefixed with -", strings.Join(slices.Sorted(maps.Keys(l.Fields)), ", "))
+	}
+
+	compare := func(aKey, aID, bKey, bID string) int {
+		c := cmp.Or(cmp.Compare(aKey, bKey), cmp.Compare(aID, bID))
+		if desc {
+			return -c
+		}
+		return c
+	}
+	sorted := slices.SortedFunc(slices.Values(items), func(a, b T) int {
+		return compare(key(a), l.ID(a), key(b), l.ID(b))
+	})
+
+	if raw := query.Get("cursor"); raw != "" {
+		after, err := decodeCursor(raw)
+		if err != nil || after.Sort != sortParam {
+			return nil, "", errInvalidCursor
+		}
+		// Skip everything up to and including the last item returned, even
+		// if it has since been removed.
+		start, _ := slices.BinarySearchFunc(sorted, after, func(item T, c pageCursor) int {
+			return compare(key(item), l.ID(item), c.Key, c.ID)
+		})
+		if start < len(sorted) && l.ID(sorted[start]) == after.ID && key(sorted[start]) == after.Key {
+			start++
+		}
+		sorted = sorted[start:]
+	}
+
+	if len(sorted) <= limit {
+		return sorted, "", nil
+	}
+	page := sorted[:limit]
+	last := page[len(page)-1]
+	return page, encodeCursor(pageCursor{Sort: sortParam, Key: key(last), ID: l.ID(last)}), nil
+}
+
+// pageResponse is the body of a list endpoint: the page of items under
+// name, and next_cursor unless this is the last page.
+func pageResponse(name string, items any, next string) map[string]any {
+	body := map[string]any{name: items}
+	if next != "" {
+		body["next_cursor"] = next
+	}
+	return body
+}
+
+// parseLimit parses the limit query parameter, which may be empty.
+func parseLimit(raw string) (int, error) {
+	if raw == "" {
+		return defaultPageSize, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n <= 0 || n > maxPageSize {
+		return 0, fmt.Errorf("limit must be between 1 and %d", maxPageSize)
+	}
+	return n, nil
+}
+
+func encodeCursor(c pageCursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(raw string) (pageCursor, error) {
+	var c pageCursor
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return c, err
+	}
+	err = json.Unmarshal(data, &c)
+	return c, err
+}
+
+// timeKey formats t as a sort key that orders like the time itself.
+func timeKey(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000000000")
+}
+
+// intKey formats a non-negative n as a sort key that orders like n itself.
+func intKey(n int) string {
+	return fmt.Sprintf("%020d", n)
+}
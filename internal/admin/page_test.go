@@ -0,0 +1,95 @@
+package admin
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"testing"
+)
+
+type pageItem struct {
+	id    string
+	group string
+}
+
+var pageItemListing = listing[pageItem]{
+	ID:          func(it pageItem) string { return it.id },
+	Fields:      map[string]func(pageItem) string{"group": func(it pageItem) string { return it.group }},
+	DefaultSort: "group",
+}
+
+// collect pages through items with the given query, removing the items in
+// remove after the first page, and returns the IDs seen.
+func collect(t *testing.T, items []pageItem, query url.Values, remove ...string) []string {
+	t.Helper()
+	var ids []string
+	for page := 0; ; page++ {
+		r := httptest.NewRequest("GET", "/admin/items?"+query.Encode(), nil)
+		got, next, err := pageItemListing.paginate(r, items)
+		if err != nil {
+			t.Fatalf("paginate failed: %v", err)
+		}
+		for _, it := range got {
+			ids = append(ids, it.id)
+		}
+		if next == "" {
+			return ids
+		}
+		if page == 0 {
+			items = slices.DeleteFunc(slices.Clone(items), func(it pageItem) bool { return slices.Contains(remove, it.id) })
+		}
+		query.Set("cursor", next)
+	}
+}
+
+func TestListingPaginate(t *testing.T) {
+	items := []pageItem{{"e", "b"}, {"a", "b"}, {"c", "a"}, {"d", "c"}, {"b", "a"}}
+
+	testCases := []struct {
+		name     string
+		query    url.Values
+		remove   []string
+		expected []string
+	}{
+		{name: "Default Sort", query: url.Values{"limit": {"2"}}, expected: []string{"b", "c", "a", "e", "d"}},
+		{name: "Descending", query: url.Values{"limit": {"2"}, "sort": {"-group"}}, expected: []string{"d", "e", "a", "c", "b"}},
+		{name: "Single Page", query: url.Values{}, expected: []string{"b", "c", "a", "e", "d"}},
+		{
+			name:     "Last Item Of Page Removed",
+			query:    url.Values{"limit": {"2"}},
+			remove:   []string{"c"},
+			expected: []string{"b", "c", "a", "e", "d"},
+		},
+		{
+			name:     "Later Item Removed",
+			query:    url.Values{"limit": {"2"}},
+			remove:   []string{"e"},
+			expected: []string{"b", "c", "a", "d"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := collect(t, items, tc.query, tc.remove...); !slices.Equal(got, tc.expected) {
+				t.Errorf("incorrect iteration: got %v want %v", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestListingPaginateRejectsInvalidParameters(t *testing.T) {
+	r := httptest.NewRequest("GET", "/admin/items?limit=1", nil)
+	_, next, _ := pageItemListing.paginate(r, []pageItem{{"a", "a"}, {"b", "b"}})
+
+	for _, query := range []string{
+		"limit=0",
+		"limit=1001",
+		"sort=id",
+		"cursor=not-a-cursor",
+		"sort=-group&cursor=" + next, // Issued for another sort.
+	} {
+		r := httptest.NewRequest("GET", "/admin/items?"+query, nil)
+		if _, _, err := pageItemListing.paginate(r, nil); err == nil {
+			t.Errorf("expected an error for %q", query)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package admin
+
+import (
+	"errors"
+	"gusto-webhook-guide/internal/apierror"
+	"gusto-webhook-guide/internal/worker"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// QuarantineHandler serves the /admin/quarantine endpoints for inspecting
+// jobs quarantined as poison messages.
+type QuarantineHandler struct {
+	Logger *slog.Logger
+	Pool   *worker.Pool
+}
+
+// quarantineListing sorts quarantined jobs, oldest first by default.
+var quarantineListing = listing[worker.QuarantinedJob]{
+	ID: func(qj worker.QuarantinedJob) string { return qj.ID },
+	Fields: map[string]func(worker.QuarantinedJob) string{
+		"quarantined_at": func(qj worker.QuarantinedJob) string { return timeKey(qj.QuarantinedAt) },
+		"event_type":     func(qj worker.QuarantinedJob) string { return qj.EventType },
+	},
+	DefaultSort: "quarantined_at",
+}
+
+// HandleList serves a page of the quarantined jobs, paged as described by
+// listing.
+func (h *QuarantineHandler) HandleList(w http.ResponseWriter, r *http.Request) {
+	entries, next, err := quarantineListing.paginate(r, h.Pool.Quarantine().List())
+	if err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+		return
+	}
+	if entries == nil {
+		entries = []worker.QuarantinedJob{}
+	}
+	writeJSON(w, pageResponse("quarantined", entries, next))
+}
+
+// HandleGet serves the quarantined job with the {id} URL parameter, with its
+// payload and failure history.
+func (h *QuarantineHandler) HandleGet(w http.ResponseWriter, r *http.Request) {
+	qj, found := h.Pool.Quarantine().Get(chi.URLParam(r, "id"))
+	if !found {
+		apierror.Write(w, r, http.StatusNotFound, apierror.CodeNotFound, "No quarantined job with this ID")
+		return
+	}
+	writeJSON(w, qj)
+}
+
+// HandleRelease queues the quarantined job with the {id} URL parameter
+// again, with a fresh attempt count, e.g. once its handler is fixed.
+func (h *QuarantineHandler) HandleRelease(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	err := h.Pool.ReleaseQuarantined(r.Context(), id)
+	switch {
+	case err == nil:
+		h.Logger.Info("Quarantined job released via admin API", "quarantine_id", id)
+		w.WriteHeader(http.StatusAccepted)
+	case errors.Is(err, worker.ErrQuarantinedNotFound):
+		apierror.Write(w, r, http.StatusNotFound, apierror.CodeNotFound, "No quarantined job with this ID")
+	default:
+		h.Logger.Error("Failed to release quarantined job", "quarantine_id", id, "error", err)
+		apierror.Write(w, r, http.StatusServiceUnavailable, apierror.CodeUnavailable, "Failed to release quarantined job")
+	}
+}
+
+// HandleDelete discards the quarantined job with the {id} URL parameter.
+func (h *QuarantineHandler) HandleDelete(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if !h.Pool.Quarantine().Remove(id) {
+		apierror.Write(w, r, http.StatusNotFound, apierror.CodeNotFound, "No quarantined job with this ID")
+		return
+	}
+	h.Logger.Info("Quarantined job discarded via admin API", "quarantine_id", id)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,65 @@
+package admin
+
+import (
+	"encoding/json"
+	"gusto-webhook-guide/internal/worker"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestQuarantineHandler(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	pool := worker.NewPool(10, 0, logger, worker.NewIdempotencyStore(), worker.ProcessorFunc(nil))
+	defer pool.Stop()
+	pool.Quarantine().Add(worker.QuarantinedJob{EventUUID: "a", Payload: []byte(`{"uuid":"a"}`)})
+	pool.Quarantine().Add(worker.QuarantinedJob{EventUUID: "b", Payload: []byte(`{"uuid":"b"}`)})
+
+	h := &QuarantineHandler{Logger: logger, Pool: pool}
+	router := chi.NewRouter()
+	router.Get("/admin/quarantine", h.HandleList)
+	router.Get("/admin/quarantine/{id}", h.HandleGet)
+	router.Delete("/admin/quarantine/{id}", h.HandleDelete)
+	router.Post("/admin/quarantine/{id}/release", h.HandleRelease)
+
+	testCases := []struct {
+		name               string
+		method             string
+		path               string
+		expectedStatusCode int
+	}{
+		{name: "Get", method: "GET", path: "/admin/quarantine/1", expectedStatusCode: http.StatusOK},
+		{name: "Get Missing", method: "GET", path: "/admin/quarantine/9", expectedStatusCode: http.StatusNotFound},
+		{name: "Release", method: "POST", path: "/admin/quarantine/1/release", expectedStatusCode: http.StatusAccepted},
+		{name: "Release Missing", method: "POST", path: "/admin/quarantine/1/release", expectedStatusCode: http.StatusNotFound},
+		{name: "Delete", method: "DELETE", path: "/admin/quarantine/2", expectedStatusCode: http.StatusNoContent},
+		{name: "Delete Missing", method: "DELETE", path: "/admin/quarantine/2", expectedStatusCode: http.StatusNotFound},
+	}
+
+	// Cases run in order: each entry is released or deleted partway through.
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest(tc.method, tc.path, nil))
+			if rr.Code != tc.expectedStatusCode {
+				t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, tc.expectedStatusCode)
+			}
+		})
+	}
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/admin/quarantine", nil))
+	var resp struct {
+		Quarantined []worker.QuarantinedJob `json:"quarantined"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid JSON response: %v", err)
+	}
+	if len(resp.Quarantined) != 0 {
+		t.Errorf("Expected an empty quarantine, got %+v", resp.Quarantined)
+	}
+}
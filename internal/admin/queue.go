@@ -0,0 +1,93 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"gusto-webhook-guide/internal/apierror"
+	"gusto-webhook-guide/internal/worker"
+	"log/slog"
+	"net/http"
+)
+
+// maxSnapshotBytes bounds the size of an uploaded queue snapshot.
+const maxSnapshotBytes = 64 << 20
+
+// QueueHandler serves /admin/queue/snapshot and /admin/queue/restore, a
+// manual escape hatch for moving queued work between processes or queue
+// backends, and /admin/queue/redrive for queues with a dead-letter queue.
+type QueueHandler struct {
+	Logger *slog.Logger
+	Pool   *worker.Pool
+}
+
+// HandleSnapshot downloads the pending and scheduled-retry jobs as JSON.
+func (h *QueueHandler) HandleSnapshot(w http.ResponseWriter, r *http.Request) {
+	snap, err := h.Pool.SnapshotQueue()
+	if errors.Is(err, worker.ErrPoolStopping) {
+		apierror.Write(w, r, http.StatusServiceUnavailable, apierror.CodeUnavailable, "Worker pool is stopping")
+		return
+	}
+	if err != nil {
+		h.Logger.Error("Failed to snapshot job queue", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to snapshot job queue")
+		return
+	}
+
+	h.Logger.Info("Job queue snapshot taken", "pending", len(snap.Pending), "retries", len(snap.Retries))
+	w.Header().Set("Content-Disposition", `attachment; filename="queue-snapshot.json"`)
+	writeJSON(w, snap)
+}
+
+// HandleRestore enqueues the jobs from a snapshot sent as the request body.
+func (h *QueueHandler) HandleRestore(w http.ResponseWriter, r *http.Request) {
+	var snap worker.QueueSnapshot
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSnapshotBytes)).Decode(&snap); err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid queue snapshot")
+		return
+	}
+
+	restored, err := h.Pool.RestoreQueue(snap)
+	h.Logger.Info("Job queue snapshot restored", "restored", restored, "error", err)
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case err == nil:
+		w.WriteHeader(http.StatusOK)
+	case errors.Is(err, worker.ErrSnapshotVersion):
+		w.WriteHeader(http.StatusBadRequest)
+	default:
+		// The pool is stopping or the queue filled up part way; report how
+		// far it got so the rest can be retried.
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	resp := map[string]any{"restored": restored}
+	if err != nil {
+		resp["error"] = err.Error()
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// HandleRedrive moves dead-lettered jobs back to the queue, if the queue
+// backend has a dead-letter queue.
+func (h *QueueHandler) HandleRedrive(w http.ResponseWriter, r *http.Request) {
+	redriver, ok := h.Pool.Queue().(worker.Redriver)
+	if !ok {
+		apierror.Write(w, r, http.StatusNotImplemented, apierror.CodeNotImplemented, "Redrive is not supported by this job queue")
+		return
+	}
+
+	task, err := redriver.Redrive(r.Context())
+	if errors.Is(err, worker.ErrNoDeadLetterQueue) {
+		apierror.Write(w, r, http.StatusNotImplemented, apierror.CodeNotImplemented, "No dead-letter queue is configured")
+		return
+	}
+	if err != nil {
+		h.Logger.Error("Failed to redrive dead-lettered jobs", "error", err)
+		apierror.Write(w, r, http.StatusBadGateway, apierror.CodeUpstreamError, "Failed to redrive dead-lettered jobs")
+		return
+	}
+
+	h.Logger.Info("Dead-letter redrive started", "task", task)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"task": task})
+}
@@ -0,0 +1,113 @@
+package admin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"gusto-webhook-guide/internal/models"
+	"gusto-webhook-guide/internal/worker"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestQueueSnapshotRoundTrip(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	noop := worker.ProcessorFunc(nil)
+
+	source := worker.NewPool(10, 0, logger, worker.NewIdempotencyStore(), noop)
+	defer source.Stop()
+	source.JobQueue <- models.Job{Payload: []byte(`{"uuid":"a"}`)}
+	sourceHandler := &QueueHandler{Logger: logger, Pool: source}
+
+	rr := httptest.NewRecorder()
+	sourceHandler.HandleSnapshot(rr, httptest.NewRequest("GET", "/admin/queue/snapshot", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("snapshot returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	snapshot := rr.Body.Bytes()
+
+	target := worker.NewPool(10, 0, logger, worker.NewIdempotencyStore(), noop)
+	defer target.Stop()
+	targetHandler := &QueueHandler{Logger: logger, Pool: target}
+
+	testCases := []struct {
+		name               string
+		body               []byte
+		expectedStatusCode int
+		expectedRestored   int
+	}{
+		{name: "Valid Snapshot", body: snapshot, expectedStatusCode: http.StatusOK, expectedRestored: 1},
+		{name: "Invalid JSON", body: []byte(`not json`), expectedStatusCode: http.StatusBadRequest},
+		{name: "Unknown Version", body: []byte(`{"version": 99}`), expectedStatusCode: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			targetHandler.HandleRestore(rr, httptest.NewRequest("POST", "/admin/queue/restore", bytes.NewReader(tc.body)))
+			if rr.Code != tc.expectedStatusCode {
+				t.Fatalf("restore returned wrong status code: got %v want %v", rr.Code, tc.expectedStatusCode)
+			}
+			if tc.expectedRestored == 0 {
+				return
+			}
+			var resp struct {
+				Restored int `json:"restored"`
+			}
+			json.Unmarshal(rr.Body.Bytes(), &resp)
+			if resp.Restored != tc.expectedRestored {
+				t.Errorf("incorrect restored count: got %d want %d", resp.Restored, tc.expectedRestored)
+			}
+		})
+	}
+
+	if got := len(target.JobQueue); got != 1 {
+		t.Errorf("incorrect restored queue length: got %d want 1", got)
+	}
+}
+
+// redriveQueue is a JobQueue with a dead-letter queue.
+type redriveQueue struct {
+	worker.ChannelQueue
+	err error
+}
+
+func (q redriveQueue) Redrive(context.Context) (string, error) {
+	return "task-1", q.err
+}
+
+func TestHandleRedrive(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+
+	testCases := []struct {
+		name               string
+		queue              worker.JobQueue
+		expectedStatusCode int
+	}{
+		{name: "Unsupported Queue", queue: nil, expectedStatusCode: http.StatusNotImplemented},
+		{name: "No Dead-Letter Queue", queue: redriveQueue{err: worker.ErrNoDeadLetterQueue}, expectedStatusCode: http.StatusNotImplemented},
+		{name: "Redrive Failed", queue: redriveQueue{err: errors.New("access denied")}, expectedStatusCode: http.StatusBadGateway},
+		{name: "Redrive Started", queue: redriveQueue{}, expectedStatusCode: http.StatusAccepted},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			pool := worker.NewPool(10, 0, logger, worker.NewIdempotencyStore(), worker.ProcessorFunc(nil))
+			defer pool.Stop()
+			if tc.queue != nil {
+				pool.SetQueue(tc.queue)
+			}
+			h := &QueueHandler{Logger: logger, Pool: pool}
+
+			rr := httptest.NewRecorder()
+			h.HandleRedrive(rr, httptest.NewRequest("POST", "/admin/queue/redrive", nil))
+			if rr.Code != tc.expectedStatusCode {
+				t.Errorf("redrive returned wrong status code: got %v want %v", rr.Code, tc.expectedStatusCode)
+			}
+		})
+	}
+}
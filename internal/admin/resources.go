@@ -0,0 +1,62 @@
+package admin
+
+import (
+	"errors"
+	"gusto-webhook-guide/internal/apierror"
+	"gusto-webhook-guide/internal/deliveries"
+	"gusto-webhook-guide/internal/worker"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// ResourceHandler serves /admin/resources/{type}/{uuid}/reprocess, which
+// replays the recent events about one company, employee or other resource,
+// e.g. after a downstream system lost its data.
+type ResourceHandler struct {
+	Logger     *slog.Logger
+	Deliveries *deliveries.Tracker
+	Pool       *worker.Pool
+}
+
+// HandleReprocess replays, oldest first, every event in the delivery
+// history whose resource or entity matches the {type} and {uuid} URL
+// parameters, and reports the outcome of each. Only events still in the
+// history (see DELIVERY_HISTORY_SIZE) can be replayed.
+func (h *ResourceHandler) HandleReprocess(w http.ResponseWriter, r *http.Request) {
+	resourceType, resourceUUID := chi.URLParam(r, "type"), chi.URLParam(r, "uuid")
+	histories := h.Deliveries.ForResource(resourceType, resourceUUID)
+	if len(histories) == 0 {
+		apierror.Write(w, r, http.StatusNotFound, apierror.CodeNotFound, "No recorded events for this resource")
+		return
+	}
+
+	// Replay the bodies that were originally processed.
+	payloads := make([][]byte, len(histories))
+	for i, history := range histories {
+		payloads[i] = []byte(history.First.Body)
+	}
+
+	results, err := h.Pool.Replay(r.Context(), payloads)
+	h.Logger.Info("Reprocessed resource events via admin API",
+		"resource_type", resourceType,
+		"resource_uuid", resourceUUID,
+		"events", len(payloads),
+		"replayed", len(results),
+		"error", err,
+	)
+	if errors.Is(err, worker.ErrPoolStopping) {
+		apierror.Write(w, r, http.StatusServiceUnavailable, apierror.CodeUnavailable, "Worker pool is stopping")
+		return
+	}
+	if results == nil {
+		results = []worker.ReplayResult{}
+	}
+	resp := map[string]any{"events": results}
+	if err != nil {
+		// Report how far the replay got so the rest can be retried.
+		resp["error"] = err.Error()
+	}
+	writeJSON(w, resp)
+}
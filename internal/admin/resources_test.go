@@ -0,0 +1,78 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"gusto-webhook-guide/internal/deliveries"
+	"gusto-webhook-guide/internal/models"
+	"gusto-webhook-guide/internal/worker"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestHandleReprocess(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	tracker := deliveries.NewTracker(10)
+	now := time.Now()
+	tracker.Observe("1", []byte(`{"uuid":"1","event_type":"company.created","resource_type":"Company","resource_uuid":"c1"}`), now)
+	tracker.Observe("2", []byte(`{"uuid":"2","event_type":"company.created","resource_type":"Company","resource_uuid":"c2"}`), now)
+	tracker.Observe("3", []byte(`{"uuid":"3","event_type":"company.updated","resource_type":"Company","resource_uuid":"c1"}`), now)
+
+	var processed []string
+	processor := worker.ProcessorFunc(func(_ context.Context, event models.WebhookEvent) error {
+		processed = append(processed, event.UUID)
+		return nil
+	})
+	store := worker.NewIdempotencyStore()
+	pool := worker.NewPool(10, 0, logger, store, processor)
+	defer pool.Stop()
+
+	h := &ResourceHandler{Logger: logger, Deliveries: tracker, Pool: pool}
+	router := chi.NewRouter()
+	router.Post("/admin/resources/{type}/{uuid}/reprocess", h.HandleReprocess)
+
+	testCases := []struct {
+		name               string
+		path               string
+		expectedStatusCode int
+		expectedEvents     []string
+	}{
+		{name: "Known Resource", path: "/admin/resources/company/c1/reprocess", expectedStatusCode: http.StatusOK, expectedEvents: []string{"1", "3"}},
+		{name: "Reprocessed Again", path: "/admin/resources/company/c1/reprocess", expectedStatusCode: http.StatusOK, expectedEvents: []string{"1", "3"}},
+		{name: "Unknown Resource", path: "/admin/resources/company/c3/reprocess", expectedStatusCode: http.StatusNotFound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			processed = nil
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest("POST", tc.path, nil))
+			if rr.Code != tc.expectedStatusCode {
+				t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, tc.expectedStatusCode)
+			}
+			if rr.Code != http.StatusOK {
+				return
+			}
+			var resp struct {
+				Events []worker.ReplayResult `json:"events"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("invalid JSON response: %v", err)
+			}
+			if len(resp.Events) != len(tc.expectedEvents) || len(processed) != len(tc.expectedEvents) {
+				t.Fatalf("incorrect events: got %+v (processed %v) want %v", resp.Events, processed, tc.expectedEvents)
+			}
+			for i, uuid := range tc.expectedEvents {
+				if resp.Events[i].EventUUID != uuid || resp.Events[i].Status != worker.StatusSucceeded || processed[i] != uuid {
+					t.Errorf("incorrect event %d: got %+v (processed %v) want %s succeeded", i, resp.Events[i], processed, uuid)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package admin
+
+import (
+	"gusto-webhook-guide/internal/worker"
+	"log/slog"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"time"
+)
+
+// Deployment describes how the process is set up: its build, where it keeps
+// state, how many workers it runs and which optional features are on. It is
+// logged once at startup and served at /admin/status, so operators don't
+// have to piece the deployment's shape together from scattered log lines.
+type Deployment struct {
+	Version   string    `json:"version"` // The module version, or VCS revision for local builds.
+	GoVersion string    `json:"go_version"`
+	StartedAt time.Time `json:"started_at"`
+	// Storage names the backend of each store, e.g. "queue": "postgres".
+	Storage map[string]string `json:"storage"`
+	Workers WorkerSettings    `json:"workers"`
+	// Features reports whether each optional feature is enabled.
+	Features map[string]bool `json:"features"`
+	// SchemaVersions are the versions of the file formats this build reads
+	// and writes.
+	SchemaVersions map[string]int `json:"schema_versions"`
+	// Dependencies maps each module the binary was built with to its version.
+	Dependencies map[string]string `json:"dependencies"`
+}
+
+// WorkerSettings are the worker pool's sizes.
+type WorkerSettings struct {
+	Count     int `json:"count"`
+	MaxCount  int `json:"max_count"` // Above Count when autoscaling.
+	QueueSize int `json:"queue_size"`
+	BatchSize int `json:"batch_size"`
+}
+
+// NewDeployment creates a Deployment with the build details and schema
+// versions filled in, started now. The caller fills in the rest.
+func NewDeployment() Deployment {
+	d := Deployment{
+		Version:   "unknown",
+		GoVersion: runtime.Version(),
+		StartedAt: time.Now().UTC(),
+		SchemaVersions: map[string]int{
+			"idempotency_snapshot": worker.IdempotencySnapshotVersion,
+			"queue_snapshot":       worker.QueueSnapshotVersion,
+		},
+		Dependencies: make(map[string]string),
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return d
+	}
+	d.Version = info.Main.Version
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" && (d.Version == "" || d.Version == "(devel)") {
+			d.Version = setting.Value
+		}
+	}
+	for _, dep := range info.Deps {
+		d.Dependencies[dep.Path] = dep.Version
+	}
+	return d
+}
+
+// Log writes the deployment to logger as a single record.
+func (d Deployment) Log(logger *slog.Logger) {
+	logger.Info("Deployment configuration",
+		"version", d.Version,
+		"go_version", d.GoVersion,
+		"storage", d.Storage,
+		"workers", d.Workers,
+		"features", d.Features,
+		"schema_versions", d.SchemaVersions,
+		"dependencies", d.Dependencies,
+	)
+}
+
+// StatusHandler serves /admin/status, an overview of the deployment and
+// what its workers are doing.
+type StatusHandler struct {
+	Deployment Deployment
+	Pool       *worker.Pool
+}
+
+// statusResponse is the body of /admin/status.
+type statusResponse struct {
+	Deployment Deployment        `json:"deployment"`
+	Uptime     string            `json:"uptime"`
+	Workers    worker.PoolStatus `json:"workers"`
+}
+
+// HandleStatus reports the deployment and the pool's status.
+func (h *StatusHandler) HandleStatus(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, statusResponse{
+		Deployment: h.Deployment,
+		Uptime:     time.Since(h.Deployment.StartedAt).Round(time.Second).String(),
+		Workers:    h.Pool.Status(),
+	})
+}
@@ -0,0 +1,42 @@
+package admin
+
+import (
+	"encoding/json"
+	"gusto-webhook-guide/internal/worker"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandleStatus(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	pool := worker.NewPool(10, 0, logger, worker.NewIdempotencyStore(), worker.ProcessorFunc(nil))
+	defer pool.Stop()
+	pool.Pause()
+
+	deployment := NewDeployment()
+	deployment.Storage = map[string]string{"queue": "memory"}
+	deployment.Features = map[string]bool{"batching": false}
+	h := &StatusHandler{Deployment: deployment, Pool: pool}
+
+	rr := httptest.NewRecorder()
+	h.HandleStatus(rr, httptest.NewRequest("GET", "/admin/status", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	var body statusResponse
+	if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if body.Deployment.Storage["queue"] != "memory" || body.Deployment.GoVersion == "" {
+		t.Errorf("incorrect deployment: got %+v", body.Deployment)
+	}
+	if got := body.Deployment.SchemaVersions["queue_snapshot"]; got != worker.QueueSnapshotVersion {
+		t.Errorf("incorrect queue snapshot version: got %d want %d", got, worker.QueueSnapshotVersion)
+	}
+	if !body.Workers.Paused {
+		t.Errorf("incorrect worker status: got %+v want paused", body.Workers)
+	}
+}
@@ -0,0 +1,139 @@
+package admin
+
+import (
+	"encoding/json"
+	"gusto-webhook-guide/internal/apierror"
+	"gusto-webhook-guide/internal/tenants"
+	"gusto-webhook-guide/internal/worker"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// TenantEventsHandler serves /tenants/{tenant}/events, a read-only view of
+// the events delivered to one tenant and how far each got, for the tenant
+// itself. Routes must be authenticated per tenant, e.g. with
+// tenants.Registry.RequireAPIToken, since the handler trusts the {tenant}
+// URL parameter.
+type TenantEventsHandler struct {
+	Logger   *slog.Logger
+	Registry *tenants.Registry
+	Events   *tenants.EventLog
+	Store    worker.Store
+	Results  worker.ResultStore // Optional; adds what processing produced to HandleGet, if the tenant's policy allows.
+}
+
+// tenantEventResponse describes an event delivered to a tenant. Processing
+// is empty until a worker has claimed the event.
+type tenantEventResponse struct {
+	UUID            string          `json:"uuid"`
+	EventType       string          `json:"event_type"`
+	FirstReceivedAt time.Time       `json:"first_received_at"`
+	LastReceivedAt  time.Time       `json:"last_received_at"`
+	Deliveries      int             `json:"deliveries"`
+	LastDelivery    string          `json:"last_delivery"` // queued, filtered or rejected.
+	Processing      worker.Status   `json:"processing,omitempty"`
+	Attempts        int             `json:"attempts,omitempty"`
+	ProcessedAt     time.Time       `json:"processed_at,omitzero"`
+	Payload         json.RawMessage `json:"payload,omitempty"` // Redacted by the tenant's policy.
+	Result          *worker.Result  `json:"result,omitempty"`
+}
+
+// tenantEventListing sorts a tenant's events, most recently delivered first
+// by default.
+var tenantEventListing = listing[tenants.LoggedEvent]{
+	ID: func(e tenants.LoggedEvent) string { return e.UUID },
+	Fields: map[string]func(tenants.LoggedEvent) string{
+		"event_type":        func(e tenants.LoggedEvent) string { return e.EventType },
+		"first_received_at": func(e tenants.LoggedEvent) string { return timeKey(e.FirstReceivedAt) },
+		"last_received_at":  func(e tenants.LoggedEvent) string { return timeKey(e.LastReceivedAt) },
+	},
+	DefaultSort: "-last_received_at",
+}
+
+// HandleList serves a page of the tenant's events, paged as described by
+// listing. Payloads are left out; fetch an event to see its payload.
+func (h *TenantEventsHandler) HandleList(w http.ResponseWriter, r *http.Request) {
+	tenant := chi.URLParam(r, "tenant")
+	page, next, err := tenantEventListing.paginate(r, h.Events.List(tenant))
+	if err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+		return
+	}
+	list := make([]tenantEventResponse, len(page))
+	for i, e := range page {
+		if list[i], err = h.describe(r, e); err != nil {
+			h.Logger.Error("Failed to read tenant event status", "tenant", tenant, "event_uuid", e.UUID, "error", err)
+			apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to read event status")
+			return
+		}
+	}
+	writeJSON(w, pageResponse("events", list, next))
+}
+
+// HandleGet serves the tenant's event with the {uuid} URL parameter,
+// including its payload and the result of its last attempt, if one was
+// kept, as the tenant's redaction policy allows.
+func (h *TenantEventsHandler) HandleGet(w http.ResponseWriter, r *http.Request) {
+	tenant := chi.URLParam(r, "tenant")
+	e, found := h.Events.Get(tenant, chi.URLParam(r, "uuid"))
+	if !found {
+		apierror.Write(w, r, http.StatusNotFound, apierror.CodeNotFound, "No such event")
+		return
+	}
+	resp, err := h.describe(r, e)
+	if err != nil {
+		h.Logger.Error("Failed to read tenant event status", "tenant", tenant, "event_uuid", e.UUID, "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to read event status")
+		return
+	}
+	t, _ := h.Registry.Get(tenant)
+	if t.Redaction != nil && t.Redaction.IncludeResults {
+		result, err := getResult(r, h.Results, e.UUID)
+		if err != nil {
+			h.Logger.Error("Failed to read tenant event result", "tenant", tenant, "event_uuid", e.UUID, "error", err)
+			apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to read event result")
+			return
+		}
+		resp.Result = redactResult(t.Redaction, result)
+	}
+	resp.Payload = t.Redaction.Apply(e.Body)
+	writeJSON(w, resp)
+}
+
+// redactResult returns the part of result that policy lets the tenant see:
+// all of it if the policy hides none of the payload, or else only the kinds,
+// IDs and actions of its artifacts.
+func redactResult(policy *tenants.Redaction, result *worker.Result) *worker.Result {
+	if result == nil || !policy.Hides() {
+		return result
+	}
+	shown := &worker.Result{EventType: result.EventType, Attempt: result.Attempt, RecordedAt: result.RecordedAt}
+	for _, a := range result.Artifacts {
+		shown.Artifacts = append(shown.Artifacts, worker.Artifact{Kind: a.Kind, ID: a.ID, Action: a.Action})
+	}
+	return shown
+}
+
+// describe returns the response for e, with its processing status from the
+// idempotency store.
+func (h *TenantEventsHandler) describe(r *http.Request, e tenants.LoggedEvent) (tenantEventResponse, error) {
+	resp := tenantEventResponse{
+		UUID:            e.UUID,
+		EventType:       e.EventType,
+		FirstReceivedAt: e.FirstReceivedAt,
+		LastReceivedAt:  e.LastReceivedAt,
+		Deliveries:      e.Deliveries,
+		LastDelivery:    e.LastDelivery,
+	}
+	rec, found, err := h.Store.Get(r.Context(), e.UUID)
+	if err != nil || !found {
+		return resp, err
+	}
+	resp.Processing = rec.Status
+	resp.Attempts = rec.Attempts
+	resp.ProcessedAt = rec.ProcessedAt
+	return resp, nil
+}
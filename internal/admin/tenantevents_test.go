@@ -0,0 +1,151 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"gusto-webhook-guide/internal/tenants"
+	"gusto-webhook-guide/internal/worker"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestTenantEventsHandler(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	registry, _ := tenants.OpenRegistry("")
+	registry.Put(tenants.Tenant{ID: "acme"})
+	registry.Put(tenants.Tenant{ID: "globex"})
+	registry.SetRedaction("acme", tenants.Redaction{Fields: []string{"entity_uuid"}, IncludeResults: true})
+	events := tenants.NewEventLog(10)
+	store := worker.NewIdempotencyStore()
+	store.Set(context.Background(), "1", worker.Record{EventType: "company.updated", Status: worker.StatusSucceeded, Attempts: 2})
+	results := worker.NewMemoryResultStore(0)
+	results.PutResult(context.Background(), "1", worker.Result{
+		EventType: "company.updated",
+		Attempt:   2,
+		Error:     "entity e-1 not found",
+		Artifacts: []worker.Artifact{{Kind: "company", ID: "c-1", URL: "https://example.com/companies/e-1"}},
+		Data:      map[string]json.RawMessage{"company": json.RawMessage(`{"entity_uuid":"e-1"}`)},
+	})
+	h := &TenantEventsHandler{Logger: logger, Registry: registry, Events: events, Store: store, Results: results}
+
+	router := chi.NewRouter()
+	router.Post("/webhooks/t/{tenant}", func(w http.ResponseWriter, r *http.Request) {
+		events.Record(r, r.URL.Query().Get("uuid"), "company.updated", []byte(`{"uuid":"x","entity_uuid":"e-1"}`), "queued")
+	})
+	router.Get("/tenants/{tenant}/events", h.HandleList)
+	router.Get("/tenants/{tenant}/events/{uuid}", h.HandleGet)
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/webhooks/t/acme?uuid=1", nil))
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/webhooks/t/acme?uuid=2", nil))
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/webhooks/t/globex?uuid=3", nil))
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/tenants/acme/events?sort=event_type", nil))
+	var list struct {
+		Events []tenantEventResponse `json:"events"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &list); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if len(list.Events) != 2 || list.Events[0].UUID != "1" || list.Events[1].UUID != "2" {
+		t.Fatalf("incorrect events listed: %+v", list.Events)
+	}
+	if e := list.Events[0]; e.Processing != worker.StatusSucceeded || e.Attempts != 2 || e.LastDelivery != "queued" || e.Payload != nil {
+		t.Errorf("incorrect processed event: %+v", e)
+	}
+	if e := list.Events[1]; e.Processing != "" {
+		t.Errorf("expected no processing status for an unclaimed event, got %q", e.Processing)
+	}
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/tenants/acme/events/1", nil))
+	var event tenantEventResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &event); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if got, want := string(event.Payload), `{"entity_uuid":"[REDACTED]","uuid":"x"}`; got != want {
+		t.Errorf("incorrect payload: got %s want %s", got, want)
+	}
+	if event.Result == nil || len(event.Result.Artifacts) != 1 || event.Result.Artifacts[0].ID != "c-1" {
+		t.Fatalf("incorrect result: %+v", event.Result)
+	}
+	if event.Result.Error != "" || event.Result.Data != nil || event.Result.Artifacts[0].URL != "" {
+		t.Errorf("expected a redacted tenant's result to hide its error, data and artifact URLs: %+v", event.Result)
+	}
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/tenants/acme/events/3", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected another tenant's event to be hidden: got status %d want %d", rr.Code, http.StatusNotFound)
+	}
+}
+
+func TestRedactResult(t *testing.T) {
+	result := &worker.Result{
+		EventType: "company.updated",
+		Attempt:   1,
+		Error:     "failed",
+		Artifacts: []worker.Artifact{{Kind: "company", ID: "c-1", Action: "updated", URL: "https://example.com/c-1"}},
+		Data:      map[string]json.RawMessage{"company": json.RawMessage(`{"ssn":"123"}`)},
+	}
+
+	testCases := []struct {
+		name         string
+		policy       *tenants.Redaction
+		expectedFull bool
+	}{
+		{name: "Full Payload", policy: &tenants.Redaction{IncludeResults: true}, expectedFull: true},
+		{name: "Payload Omitted", policy: &tenants.Redaction{OmitPayload: true, IncludeResults: true}},
+		{name: "Fields Redacted", policy: &tenants.Redaction{Fields: []string{"data.ssn"}, IncludeResults: true}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := redactResult(tc.policy, result)
+			if tc.expectedFull {
+				if got != result {
+					t.Errorf("expected the full result, got %+v", got)
+				}
+				return
+			}
+			want := worker.Artifact{Kind: "company", ID: "c-1", Action: "updated"}
+			if got.Error != "" || got.Data != nil || len(got.Artifacts) != 1 || got.Artifacts[0] != want {
+				t.Errorf("incorrect redacted result: %+v", got)
+			}
+		})
+	}
+}
+
+func TestTenantEventsHandlerResultsNotIncluded(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	registry, _ := tenants.OpenRegistry("")
+	registry.Put(tenants.Tenant{ID: "acme"})
+	events := tenants.NewEventLog(10)
+	results := worker.NewMemoryResultStore(0)
+	results.PutResult(context.Background(), "1", worker.Result{EventType: "company.updated", Data: map[string]json.RawMessage{"ssn": json.RawMessage(`"123"`)}})
+	h := &TenantEventsHandler{Logger: logger, Registry: registry, Events: events, Store: worker.NewIdempotencyStore(), Results: results}
+
+	router := chi.NewRouter()
+	router.Post("/webhooks/t/{tenant}", func(w http.ResponseWriter, r *http.Request) {
+		events.Record(r, "1", "company.updated", []byte(`{"uuid":"1"}`), "queued")
+	})
+	router.Get("/tenants/{tenant}/events/{uuid}", h.HandleGet)
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/webhooks/t/acme", nil))
+
+	for _, policy := range []tenants.Redaction{{}, {OmitPayload: true}} {
+		registry.SetRedaction("acme", policy)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", "/tenants/acme/events/1", nil))
+		var event tenantEventResponse
+		if err := json.Unmarshal(rr.Body.Bytes(), &event); err != nil {
+			t.Fatalf("decoding response: %v", err)
+		}
+		if event.Result != nil {
+			t.Errorf("expected no result without include_results under %+v, got %+v", policy, event.Result)
+		}
+	}
+}
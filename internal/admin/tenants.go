@@ -0,0 +1,204 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"gusto-webhook-guide/internal/apierror"
+	"gusto-webhook-guide/internal/middleware"
+	"gusto-webhook-guide/internal/tenants"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// TenantHandler serves /admin/tenants, which onboards a tenant with its own
+// webhook subscription in a single call and lists the tenants onboarded.
+type TenantHandler struct {
+	Logger      *slog.Logger
+	Provisioner *tenants.Provisioner
+	Registry    *tenants.Registry
+}
+
+// tenantResponse describes a provisioned tenant. The secret and API token
+// are never returned.
+type tenantResponse struct {
+	ID               string                      `json:"id"`
+	SubscriptionUUID string                      `json:"subscription_uuid"`
+	WebhookURL       string                      `json:"webhook_url"`
+	CreatedAt        time.Time                   `json:"created_at"`
+	HasAPIToken      bool                        `json:"has_api_token"`
+	Redaction        *tenants.Redaction          `json:"redaction,omitempty"`
+	ForwardURL       string                      `json:"forward_url,omitempty"`
+	Signature        *middleware.SignatureScheme `json:"signature,omitempty"`
+}
+
+// tenantListing sorts tenants, oldest first by default.
+var tenantListing = listing[tenants.Tenant]{
+	ID: func(t tenants.Tenant) string { return t.ID },
+	Fields: map[string]func(tenants.Tenant) string{
+		"created_at": func(t tenants.Tenant) string { return timeKey(t.CreatedAt) },
+		"id":         func(t tenants.Tenant) string { return t.ID },
+	},
+	DefaultSort: "created_at",
+}
+
+// HandleList serves a page of the tenants, paged as described by listing.
+func (h *TenantHandler) HandleList(w http.ResponseWriter, r *http.Request) {
+	page, next, err := tenantListing.paginate(r, h.Registry.List())
+	if err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+		return
+	}
+	list := make([]tenantResponse, len(page))
+	for i, t := range page {
+		list[i] = newTenantResponse(t)
+	}
+	writeJSON(w, pageResponse("tenants", list, next))
+}
+
+// HandleProvision provisions the tenant named in the request body and
+// responds once its subscription is verified.
+func (h *TenantHandler) HandleProvision(w http.ResponseWriter, r *http.Request) {
+	var requestBody struct {
+		Tenant string `json:"tenant"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid request body")
+		return
+	}
+
+	t, err := h.Provisioner.Provision(r.Context(), requestBody.Tenant)
+	switch {
+	case errors.Is(err, tenants.ErrInvalidTenant):
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+		return
+	case errors.Is(err, tenants.ErrTenantExists):
+		apierror.Write(w, r, http.StatusConflict, apierror.CodeConflict, "Tenant already exists")
+		return
+	case errors.Is(err, context.DeadlineExceeded):
+		apierror.Write(w, r, http.StatusGatewayTimeout, apierror.CodeTimeout, "Timed out waiting for the verification payload")
+		return
+	case errors.Is(err, tenants.ErrRegistryReadOnly):
+		apierror.Write(w, r, http.StatusServiceUnavailable, apierror.CodeUnavailable, "Tenant registry is read-only while the server restarts")
+		return
+	case err != nil:
+		h.Logger.Error("Failed to provision tenant", "tenant", requestBody.Tenant, "error", err)
+		apierror.Write(w, r, http.StatusBadGateway, apierror.CodeUpstreamError, "Failed to provision tenant")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(newTenantResponse(t))
+}
+
+// HandleRegister registers an existing subscription as the {id} tenant,
+// with the subscription_uuid, secret and optional forward_url and signature
+// in the request body, or updates the tenant if it exists.
+func (h *TenantHandler) HandleRegister(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	var reg tenants.Registration
+	if err := json.NewDecoder(r.Body).Decode(&reg); err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid request body")
+		return
+	}
+	if reg.ForwardURL != "" {
+		if u, err := url.Parse(reg.ForwardURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, "forward_url must be an absolute http(s) URL")
+			return
+		}
+	}
+
+	t, created, err := h.Provisioner.Register(id, reg)
+	switch {
+	case errors.Is(err, tenants.ErrInvalidTenant), errors.Is(err, tenants.ErrInvalidRegistration),
+		errors.Is(err, tenants.ErrInvalidSignatureScheme):
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+		return
+	case errors.Is(err, tenants.ErrTenantExists):
+		apierror.Write(w, r, http.StatusConflict, apierror.CodeConflict, "Tenant is being provisioned")
+		return
+	case errors.Is(err, tenants.ErrRegistryReadOnly):
+		apierror.Write(w, r, http.StatusServiceUnavailable, apierror.CodeUnavailable, "Tenant registry is read-only while the server restarts")
+		return
+	case err != nil:
+		h.Logger.Error("Failed to register tenant", "tenant", id, "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to register tenant")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if created {
+		w.WriteHeader(http.StatusCreated)
+	}
+	writeJSON(w, newTenantResponse(t))
+}
+
+// HandleMintAPIToken creates an API token for the {id} tenant to read its
+// own events with, replacing any it had. The token is only returned here.
+func (h *TenantHandler) HandleMintAPIToken(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	token, err := h.Registry.MintAPIToken(id)
+	if errors.Is(err, tenants.ErrUnknownTenant) {
+		apierror.Write(w, r, http.StatusNotFound, apierror.CodeNotFound, "No such tenant")
+		return
+	}
+	if errors.Is(err, tenants.ErrRegistryReadOnly) {
+		apierror.Write(w, r, http.StatusServiceUnavailable, apierror.CodeUnavailable, "Tenant registry is read-only while the server restarts")
+		return
+	}
+	if err != nil {
+		h.Logger.Error("Failed to mint tenant API token", "tenant", id, "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to mint tenant API token")
+		return
+	}
+	h.Logger.Info("Minted tenant API token via admin API", "tenant", id)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{"tenant": id, "token": token})
+}
+
+// HandleSetRedaction replaces the {id} tenant's redaction policy with the
+// one in the request body, e.g. {"fields": ["entity_uuid"]}.
+func (h *TenantHandler) HandleSetRedaction(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	var policy tenants.Redaction
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid request body")
+		return
+	}
+	err := h.Registry.SetRedaction(id, policy)
+	if errors.Is(err, tenants.ErrUnknownTenant) {
+		apierror.Write(w, r, http.StatusNotFound, apierror.CodeNotFound, "No such tenant")
+		return
+	}
+	if errors.Is(err, tenants.ErrRegistryReadOnly) {
+		apierror.Write(w, r, http.StatusServiceUnavailable, apierror.CodeUnavailable, "Tenant registry is read-only while the server restarts")
+		return
+	}
+	if err != nil {
+		h.Logger.Error("Failed to set tenant redaction policy", "tenant", id, "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Failed to set redaction policy")
+		return
+	}
+	h.Logger.Info("Set tenant redaction policy via admin API", "tenant", id, "omit_payload", policy.OmitPayload, "fields", policy.Fields)
+	t, _ := h.Registry.Get(id)
+	writeJSON(w, newTenantResponse(t))
+}
+
+func newTenantResponse(t tenants.Tenant) tenantResponse {
+	return tenantResponse{
+		ID:               t.ID,
+		SubscriptionUUID: t.SubscriptionUUID,
+		WebhookURL:       t.WebhookURL,
+		CreatedAt:        t.CreatedAt,
+		HasAPIToken:      t.APITokenHash != "",
+		Redaction:        t.Redaction,
+		ForwardURL:       t.ForwardURL,
+		Signature:        t.Signature,
+	}
+}
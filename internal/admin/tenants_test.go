@@ -0,0 +1,168 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"gusto-webhook-guide/internal/subscriptions"
+	"gusto-webhook-guide/internal/tenants"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// instantSubscriber delivers the verification token as soon as the
+// subscription is created.
+type instantSubscriber struct {
+	deliver func(subscriptionID, token string)
+}
+
+func (s *instantSubscriber) Create(_ context.Context, url string, _ []string) (subscriptions.Subscription, error) {
+	if s.deliver != nil {
+		s.deliver("sub-1", "token-1")
+	}
+	return subscriptions.Subscription{UUID: "sub-1", URL: url}, nil
+}
+
+func (s *instantSubscriber) List(context.Context) ([]subscriptions.Subscription, error) {
+	return nil, nil
+}
+
+func (s *instantSubscriber) Verify(context.Context, string, string) error { return nil }
+
+func TestHandleProvision(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	registry, _ := tenants.OpenRegistry("")
+	subscriber := &instantSubscriber{}
+	provisioner := tenants.NewProvisioner(logger, registry, subscriber, "https://hooks.example.com", 50*time.Millisecond)
+	h := &TenantHandler{Logger: logger, Provisioner: provisioner}
+
+	testCases := []struct {
+		name               string
+		body               string
+		deliver            bool
+		expectedStatusCode int
+	}{
+		{name: "Provisioned", body: `{"tenant":"acme"}`, deliver: true, expectedStatusCode: http.StatusCreated},
+		{name: "Already Exists", body: `{"tenant":"acme"}`, deliver: true, expectedStatusCode: http.StatusConflict},
+		{name: "Invalid Tenant", body: `{"tenant":"Not Valid"}`, expectedStatusCode: http.StatusBadRequest},
+		{name: "Invalid Body", body: `not json`, expectedStatusCode: http.StatusBadRequest},
+		{name: "Verification Times Out", body: `{"tenant":"slow"}`, expectedStatusCode: http.StatusGatewayTimeout},
+	}
+
+	// Cases run in order: the second provisions the same tenant again.
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			subscriber.deliver = nil
+			if tc.deliver {
+				subscriber.deliver = provisioner.Deliver
+			}
+			rr := httptest.NewRecorder()
+			h.HandleProvision(rr, httptest.NewRequest("POST", "/admin/tenants", strings.NewReader(tc.body)))
+			if rr.Code != tc.expectedStatusCode {
+				t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, tc.expectedStatusCode)
+			}
+			if rr.Code != http.StatusCreated {
+				return
+			}
+			var resp map[string]any
+			json.Unmarshal(rr.Body.Bytes(), &resp)
+			if resp["webhook_url"] != "https://hooks.example.com/webhooks/t/acme" {
+				t.Errorf("incorrect webhook_url: got %v", resp["webhook_url"])
+			}
+			if _, leaked := resp["secret"]; leaked {
+				t.Errorf("Expected the secret not to be returned")
+			}
+		})
+	}
+}
+
+func TestHandleRegisterTenant(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	registry, _ := tenants.OpenRegistry("")
+	provisioner := tenants.NewProvisioner(logger, registry, &instantSubscriber{}, "https://hooks.example.com", 50*time.Millisecond)
+	h := &TenantHandler{Logger: logger, Provisioner: provisioner, Registry: registry}
+	router := chi.NewRouter()
+	router.Put("/admin/tenants/{id}", h.HandleRegister)
+
+	testCases := []struct {
+		name               string
+		tenant             string
+		body               string
+		expectedStatusCode int
+		expectedSecret     string
+	}{
+		{name: "Registered", tenant: "staging", body: `{"subscription_uuid":"sub-1","secret":"secret-1"}`,
+			expectedStatusCode: http.StatusCreated, expectedSecret: "secret-1"},
+		{name: "Updated", tenant: "staging", body: `{"subscription_uuid":"sub-1","secret":"secret-2","forward_url":"https://staging.example.com/hooks"}`,
+			expectedStatusCode: http.StatusOK, expectedSecret: "secret-2"},
+		{name: "Missing Secret", tenant: "prod", body: `{"subscription_uuid":"sub-2"}`, expectedStatusCode: http.StatusBadRequest},
+		{name: "Invalid Tenant", tenant: "Prod_EU", body: `{"subscription_uuid":"sub-2","secret":"s"}`, expectedStatusCode: http.StatusBadRequest},
+		{name: "Invalid Forward URL", tenant: "prod", body: `{"subscription_uuid":"sub-2","secret":"s","forward_url":"/hooks"}`,
+			expectedStatusCode: http.StatusBadRequest},
+		{name: "Invalid Body", tenant: "prod", body: `not json`, expectedStatusCode: http.StatusBadRequest},
+	}
+
+	// Cases run in order: the second updates the tenant the first registers.
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest("PUT", "/admin/tenants/"+tc.tenant, strings.NewReader(tc.body)))
+			if rr.Code != tc.expectedStatusCode {
+				t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, tc.expectedStatusCode)
+			}
+			if tc.expectedSecret == "" {
+				return
+			}
+			if strings.Contains(rr.Body.String(), tc.expectedSecret) {
+				t.Errorf("response includes the tenant secret")
+			}
+			if stored, _ := registry.Get(tc.tenant); stored.Secret != tc.expectedSecret {
+				t.Errorf("incorrect stored secret: got %q want %q", stored.Secret, tc.expectedSecret)
+			}
+		})
+	}
+}
+
+func TestHandleListTenants(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	registry, _ := tenants.OpenRegistry("")
+	now := time.Now().UTC()
+	for i, id := range []string{"globex", "acme", "initech"} {
+		registry.Put(tenants.Tenant{ID: id, Secret: "secret", CreatedAt: now.Add(time.Duration(i) * time.Minute)})
+	}
+	h := &TenantHandler{Logger: logger, Registry: registry}
+
+	var ids []string
+	target := "/admin/tenants?limit=2"
+	for target != "" {
+		rr := httptest.NewRecorder()
+		h.HandleList(rr, httptest.NewRequest("GET", target, nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+		}
+		if strings.Contains(rr.Body.String(), "secret") {
+			t.Fatal("response includes a tenant secret")
+		}
+		var body struct {
+			Tenants    []tenantResponse `json:"tenants"`
+			NextCursor string           `json:"next_cursor"`
+		}
+		json.Unmarshal(rr.Body.Bytes(), &body)
+		for _, tenant := range body.Tenants {
+			ids = append(ids, tenant.ID)
+		}
+		target = ""
+		if body.NextCursor != "" {
+			target = "/admin/tenants?limit=2&cursor=" + body.NextCursor
+		}
+	}
+	if want := []string{"globex", "acme", "initech"}; strings.Join(ids, ",") != strings.Join(want, ",") {
+		t.Errorf("incorrect tenants: got %v want %v", ids, want)
+	}
+}
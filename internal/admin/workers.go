@@ -0,0 +1,103 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"gusto-webhook-guide/internal/apierror"
+	"gusto-webhook-guide/internal/worker"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// defaultDrainTimeout bounds POST /admin/workers/drain without a timeout.
+const defaultDrainTimeout = 30 * time.Second
+
+// manualHoldSource identifies retry holds placed through the admin API.
+const manualHoldSource = "manual"
+
+// WorkersHandler serves /admin/workers, which reports what the worker pool
+// is doing and lets operators pause, resume and drain it during incidents.
+type WorkersHandler struct {
+	Logger *slog.Logger
+	Pool   *worker.Pool
+}
+
+// HandleStatus reports the pool's status.
+func (h *WorkersHandler) HandleStatus(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, h.Pool.Status())
+}
+
+// HandlePause stops workers taking new jobs, on every replica if the pool
+// shares its controls; queued jobs are kept.
+func (h *WorkersHandler) HandlePause(w http.ResponseWriter, r *http.Request) {
+	if err := h.Pool.PauseAll(r.Context()); err != nil {
+		h.Logger.Error("Failed to pause workers on other replicas", "error", err)
+		apierror.Write(w, r, http.StatusServiceUnavailable, apierror.CodeUnavailable, "Workers paused on this replica only")
+		return
+	}
+	writeJSON(w, h.Pool.Status())
+}
+
+// HandleResume lets paused workers take jobs again, like HandlePause.
+func (h *WorkersHandler) HandleResume(w http.ResponseWriter, r *http.Request) {
+	if err := h.Pool.ResumeAll(r.Context()); err != nil {
+		h.Logger.Error("Failed to resume workers on other replicas", "error", err)
+		apierror.Write(w, r, http.StatusServiceUnavailable, apierror.CodeUnavailable, "Workers resumed on this replica only")
+		return
+	}
+	writeJSON(w, h.Pool.Status())
+}
+
+// HandleHoldRetries holds scheduled retries, on every replica if the pool
+// shares its controls, e.g. during a Gusto outage the status page hasn't
+// declared, until HandleReleaseRetries. The optional reason query
+// parameter is shown in the status.
+func (h *WorkersHandler) HandleHoldRetries(w http.ResponseWriter, r *http.Request) {
+	if err := h.Pool.HoldAllRetries(r.Context(), manualHoldSource, r.URL.Query().Get("reason")); err != nil {
+		h.Logger.Error("Failed to hold retries on other replicas", "error", err)
+		apierror.Write(w, r, http.StatusServiceUnavailable, apierror.CodeUnavailable, "Retries held on this replica only")
+		return
+	}
+	writeJSON(w, h.Pool.Status())
+}
+
+// HandleReleaseRetries releases a hold placed by HandleHoldRetries, like
+// HandleHoldRetries. Holds placed by the status page are released when it
+// clears.
+func (h *WorkersHandler) HandleReleaseRetries(w http.ResponseWriter, r *http.Request) {
+	if err := h.Pool.ReleaseAllRetries(r.Context(), manualHoldSource); err != nil {
+		h.Logger.Error("Failed to release retries on other replicas", "error", err)
+		apierror.Write(w, r, http.StatusServiceUnavailable, apierror.CodeUnavailable, "Retries released on this replica only")
+		return
+	}
+	writeJSON(w, h.Pool.Status())
+}
+
+// HandleDrain waits, up to the timeout query parameter (e.g. "2m"), for
+// the queue to empty and in-flight jobs to finish.
+func (h *WorkersHandler) HandleDrain(w http.ResponseWriter, r *http.Request) {
+	timeout := defaultDrainTimeout
+	if raw := r.URL.Query().Get("timeout"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d <= 0 {
+			apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid timeout")
+			return
+		}
+		timeout = d
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	err := h.Pool.Drain(ctx)
+	switch {
+	case errors.Is(err, worker.ErrPaused):
+		apierror.Write(w, r, http.StatusConflict, apierror.CodeConflict, "Worker pool is paused; resume it to drain")
+	case err != nil:
+		h.Logger.Warn("Worker pool did not drain in time", "timeout", timeout, "status", h.Pool.Status())
+		apierror.Write(w, r, http.StatusGatewayTimeout, apierror.CodeTimeout, "Worker pool did not drain in time")
+	default:
+		h.Logger.Info("Worker pool drained")
+		writeJSON(w, h.Pool.Status())
+	}
+}
@@ -0,0 +1,54 @@
+package admin
+
+import (
+	"encoding/json"
+	"gusto-webhook-guide/internal/worker"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWorkersHandler(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	pool := worker.NewPool(10, 0, logger, worker.NewIdempotencyStore(), worker.ProcessorFunc(nil))
+	defer pool.Stop()
+	h := &WorkersHandler{Logger: logger, Pool: pool}
+
+	testCases := []struct {
+		name               string
+		handler            http.HandlerFunc
+		target             string
+		expectedStatusCode int
+		expectPaused       bool
+		expectHeld         bool
+	}{
+		{name: "Pause", handler: h.HandlePause, target: "/admin/workers/pause", expectedStatusCode: http.StatusOK, expectPaused: true},
+		{name: "Drain While Paused", handler: h.HandleDrain, target: "/admin/workers/drain", expectedStatusCode: http.StatusConflict, expectPaused: true},
+		{name: "Resume", handler: h.HandleResume, target: "/admin/workers/resume", expectedStatusCode: http.StatusOK},
+		{name: "Hold Retries", handler: h.HandleHoldRetries, target: "/admin/workers/retries/hold?reason=outage", expectedStatusCode: http.StatusOK, expectHeld: true},
+		{name: "Release Retries", handler: h.HandleReleaseRetries, target: "/admin/workers/retries/release", expectedStatusCode: http.StatusOK},
+		{name: "Invalid Drain Timeout", handler: h.HandleDrain, target: "/admin/workers/drain?timeout=soon", expectedStatusCode: http.StatusBadRequest},
+		{name: "Drain Idle Pool", handler: h.HandleDrain, target: "/admin/workers/drain?timeout=1s", expectedStatusCode: http.StatusOK},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			tc.handler(rr, httptest.NewRequest("POST", tc.target, nil))
+			if rr.Code != tc.expectedStatusCode {
+				t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, tc.expectedStatusCode)
+			}
+			if rr.Code == http.StatusOK {
+				var status worker.PoolStatus
+				json.NewDecoder(rr.Body).Decode(&status)
+				if status.Paused != tc.expectPaused {
+					t.Errorf("incorrect paused status: got %v want %v", status.Paused, tc.expectPaused)
+				}
+				if held := len(status.RetryHolds) > 0; held != tc.expectHeld {
+					t.Errorf("incorrect retry holds: got %+v want held %v", status.RetryHolds, tc.expectHeld)
+				}
+			}
+		})
+	}
+}
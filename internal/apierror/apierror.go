@@ -0,0 +1,77 @@
+// Package apierror writes the JSON error responses of the webhook and admin
+// endpoints, so that integrators and dashboards can tell failures apart by
+// a stable code rather than by parsing a message.
+package apierror
+
+import (
+	"encoding/json"
+	"gusto-webhook-guide/internal/contextkeys"
+	"net/http"
+)
+
+// Codes identifying why a request failed. Messages may change; codes don't.
+const (
+	CodeInvalidRequest       = "invalid_request"        // The request, e.g. its body or a parameter, is malformed.
+	CodeInvalidEvent         = "invalid_event"          // An event's envelope is missing a required field.
+	CodeSchemaViolation      = "schema_violation"       // An event fails its event type's schema.
+	CodeUnsupportedMediaType = "unsupported_media_type" // The body isn't JSON.
+	CodeBodyTooLarge         = "body_too_large"
+	CodeUnauthorized         = "unauthorized"      // Missing or wrong credentials.
+	CodeForbidden            = "forbidden"         // Credentials can't be checked, e.g. the admin API is disabled.
+	CodeMissingSignature     = "missing_signature" // A webhook without its signature header.
+	CodeInvalidSignature     = "invalid_signature"
+	CodeNotFound             = "not_found"
+	CodeMethodNotAllowed     = "method_not_allowed"
+	CodeConflict             = "conflict" // The request conflicts with the current state, e.g. a duplicate.
+	CodeRateLimited          = "rate_limited"
+	CodeServerBusy           = "server_busy"       // The queue is full; deliver the event again later.
+	CodeUnavailable          = "unavailable"       // The service can't do this right now, e.g. while shutting down.
+	CodeTimeout              = "timeout"           // Waiting for something to happen took too long.
+	CodeProcessingFailed     = "processing_failed" // An event held until processed failed.
+	CodeProcessingTimedOut   = "processing_timed_out"
+	CodeNotImplemented       = "not_implemented" // The configured backend doesn't support the operation.
+	CodeUpstreamError        = "upstream_error"  // A call to Gusto or a forwarding target failed.
+	CodeInternal             = "internal_error"
+)
+
+// Error is the body of an error response. Responses with more detail embed
+// it and add their own fields.
+type Error struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"` // Set for routes that assign request IDs.
+}
+
+// New returns an Error for a response to r.
+func New(r *http.Request, code, message string) Error {
+	requestID, _ := r.Context().Value(contextkeys.RequestIDKey).(string)
+	return Error{Code: code, Message: message, RequestID: requestID}
+}
+
+// Write responds to r with status and an Error, in place of http.Error.
+func Write(w http.ResponseWriter, r *http.Request, status int, code, message string) {
+	WriteJSON(w, status, New(r, code, message))
+}
+
+// WriteJSON responds with status and v, an Error or a type embedding one.
+func WriteJSON(w http.ResponseWriter, status int, v any) {
+	// As with http.Error, headers meant for the success response, such as
+	// Content-Length, must not apply to this one.
+	w.Header().Del("Content-Length")
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// NotFound responds with a CodeNotFound error, for use as a router's
+// handler of unknown routes.
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	Write(w, r, http.StatusNotFound, CodeNotFound, "No such endpoint")
+}
+
+// MethodNotAllowed responds with a CodeMethodNotAllowed error, for use as a
+// router's handler of known routes requested with the wrong method.
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	Write(w, r, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Method not allowed for this endpoint")
+}
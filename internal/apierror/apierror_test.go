@@ -0,0 +1,64 @@
+package apierror
+
+import (
+	"context"
+	"encoding/json"
+	"gusto-webhook-guide/internal/contextkeys"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	testCases := []struct {
+		name              string
+		requestID         string
+		expectedRequestID string
+	}{
+		{name: "With Request ID", requestID: "req-1", expectedRequestID: "req-1"},
+		{name: "Without Request ID"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/webhooks", nil)
+			if tc.requestID != "" {
+				req = req.WithContext(context.WithValue(req.Context(), contextkeys.RequestIDKey, tc.requestID))
+			}
+			rr := httptest.NewRecorder()
+			rr.Header().Set("Content-Length", "2")
+			Write(rr, req, http.StatusForbidden, CodeInvalidSignature, "Invalid signature")
+
+			if rr.Code != http.StatusForbidden {
+				t.Errorf("wrong status code: got %v want %v", rr.Code, http.StatusForbidden)
+			}
+			if got := rr.Header().Get("Content-Type"); got != "application/json" {
+				t.Errorf("wrong content type: got %q want %q", got, "application/json")
+			}
+			if got := rr.Header().Get("Content-Length"); got != "" {
+				t.Errorf("expected Content-Length to be dropped, got %q", got)
+			}
+			var body Error
+			if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decoding response: %v", err)
+			}
+			want := Error{Code: CodeInvalidSignature, Message: "Invalid signature", RequestID: tc.expectedRequestID}
+			if body != want {
+				t.Errorf("incorrect body: got %+v want %+v", body, want)
+			}
+		})
+	}
+}
+
+func TestWriteJSONEmbedded(t *testing.T) {
+	req := httptest.NewRequest("POST", "/webhooks", nil)
+	rr := httptest.NewRecorder()
+	WriteJSON(rr, http.StatusBadRequest, struct {
+		Error
+		Field string `json:"field"`
+	}{Error: New(req, CodeInvalidEvent, "missing uuid"), Field: "uuid"})
+
+	if got, want := rr.Body.String(), `{"code":"invalid_event","message":"missing uuid","field":"uuid"}`+"\n"; got != want {
+		t.Errorf("incorrect body: got %s want %s", got, want)
+	}
+}
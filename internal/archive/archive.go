@@ -0,0 +1,59 @@
+// Package archive keeps the raw webhook events we were delivered, as they
+// arrived, so that they can be processed again after a bug in processing
+// logic is fixed, without waiting for the provider to retry them.
+package archive
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// sensitiveHeaders are left out of archived headers.
+var sensitiveHeaders = []string{"Authorization", "Cookie", "Proxy-Authorization"}
+
+// Event is a verified webhook event as it was delivered.
+type Event struct {
+	UUID       string      `json:"uuid"`
+	EventType  string      `json:"event_type"`
+	ReceivedAt time.Time   `json:"received_at"`
+	RequestID  string      `json:"request_id,omitempty"`
+	Headers    http.Header `json:"headers"`
+	Body       []byte      `json:"body"` // The event's JSON, e.g. one element of a batched delivery.
+}
+
+// Filter selects archived events. Zero fields match everything.
+type Filter struct {
+	EventType string
+	Since     time.Time // Received at or after.
+	Until     time.Time // Received before.
+	Limit     int
+}
+
+// Matches reports whether e passes the filter, ignoring Limit.
+func (f Filter) Matches(e Event) bool {
+	return (f.EventType == "" || e.EventType == f.EventType) &&
+		(f.Since.IsZero() || !e.ReceivedAt.Before(f.Since)) &&
+		(f.Until.IsZero() || e.ReceivedAt.Before(f.Until))
+}
+
+// Store persists archived events, keeping one per event UUID.
+type Store interface {
+	// Put stores an event, replacing any earlier delivery of it.
+	Put(ctx context.Context, e Event) error
+	// Get returns the event with the given UUID.
+	Get(ctx context.Context, uuid string) (Event, bool, error)
+	// List returns the events matching f, oldest first.
+	List(ctx context.Context, f Filter) ([]Event, error)
+	// Prune deletes events received before cutoff, returning how many.
+	Prune(ctx context.Context, cutoff time.Time) (int, error)
+}
+
+// Headers returns a copy of h fit for archiving, without credentials.
+func Headers(h http.Header) http.Header {
+	out := h.Clone()
+	for _, name := range sensitiveHeaders {
+		out.Del(name)
+	}
+	return out
+}
@@ -0,0 +1,146 @@
+package archive
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// DirStore is a Store keeping each event in its own JSON file in a
+// directory, for a single process. Listing reads every file, so prune it to
+// keep listing fast.
+type DirStore struct {
+	dir string
+}
+
+var _ Store = (*DirStore)(nil)
+
+// OpenDirStore creates a DirStore in dir, creating the directory if needed.
+func OpenDirStore(dir string) (*DirStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("creating archive directory: %w", err)
+	}
+	return &DirStore{dir: dir}, nil
+}
+
+// path returns the file an event is kept in. Escaping keeps UUIDs from
+// naming files outside the directory.
+func (s *DirStore) path(uuid string) string {
+	return filepath.Join(s.dir, url.PathEscape(uuid)+".json")
+}
+
+// Put implements Store, replacing the event's file atomically.
+func (s *DirStore) Put(_ context.Context, e Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("encoding archived event: %w", err)
+	}
+	tmp, err := os.CreateTemp(s.dir, "*.tmp")
+	if err != nil {
+		return fmt.Errorf("creating archive file: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // No-op once renamed.
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing archive file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing archive file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path(e.UUID)); err != nil {
+		return fmt.Errorf("replacing archive file: %w", err)
+	}
+	return nil
+}
+
+// Get implements Store.
+func (s *DirStore) Get(_ context.Context, uuid string) (Event, bool, error) {
+	e, err := readEvent(s.path(uuid))
+	if errors.Is(err, fs.ErrNotExist) {
+		return Event{}, false, nil
+	}
+	if err != nil {
+		return Event{}, false, err
+	}
+	return e, true, nil
+}
+
+// List implements Store.
+func (s *DirStore) List(_ context.Context, f Filter) ([]Event, error) {
+	events, err := s.all()
+	if err != nil {
+		return nil, err
+	}
+	events = slices.DeleteFunc(events, func(e Event) bool { return !f.Matches(e) })
+	if f.Limit > 0 && len(events) > f.Limit {
+		events = events[:f.Limit]
+	}
+	return events, nil
+}
+
+// Prune implements Store.
+func (s *DirStore) Prune(_ context.Context, cutoff time.Time) (int, error) {
+	events, err := s.all()
+	if err != nil {
+		return 0, err
+	}
+	pruned := 0
+	for _, e := range events {
+		if !e.ReceivedAt.Before(cutoff) {
+			break
+		}
+		if err := os.Remove(s.path(e.UUID)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return pruned, fmt.Errorf("deleting archived event: %w", err)
+		}
+		pruned++
+	}
+	return pruned, nil
+}
+
+// all reads every archived event, oldest first.
+func (s *DirStore) all() ([]Event, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading archive directory: %w", err)
+	}
+	var events []Event
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		e, err := readEvent(filepath.Join(s.dir, entry.Name()))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue // Pruned or replaced meanwhile.
+		}
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	slices.SortFunc(events, func(a, b Event) int {
+		return cmp.Or(a.ReceivedAt.Compare(b.ReceivedAt), cmp.Compare(a.UUID, b.UUID))
+	})
+	return events, nil
+}
+
+func readEvent(path string) (Event, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Event{}, err
+	}
+	var e Event
+	if err := json.Unmarshal(data, &e); err != nil {
+		return Event{}, fmt.Errorf("decoding archived event %s: %w", filepath.Base(path), err)
+	}
+	return e, nil
+}
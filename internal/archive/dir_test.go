@@ -0,0 +1,102 @@
+package archive
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDirStore(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	s, err := OpenDirStore(filepath.Join(dir, "archive"))
+	if err != nil {
+		t.Fatalf("OpenDirStore failed: %v", err)
+	}
+
+	base := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	events := []Event{
+		{UUID: "c", EventType: "company.updated", ReceivedAt: base.Add(2 * time.Minute), Body: []byte(`{"uuid":"c"}`)},
+		{UUID: "a", EventType: "company.updated", ReceivedAt: base, Headers: http.Header{"X-Gusto-Signature": {"sig"}}, Body: []byte(`{"uuid":"a"}`)},
+		{UUID: "b", EventType: "employee.created", ReceivedAt: base.Add(time.Minute), Body: []byte(`{"uuid":"b"}`)},
+		{UUID: "../escape", EventType: "employee.created", ReceivedAt: base.Add(3 * time.Minute), Body: []byte(`{}`)},
+	}
+	for _, e := range events {
+		if err := s.Put(ctx, e); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "escape.json")); err == nil {
+		t.Errorf("Expected the UUID to be kept inside the archive directory")
+	}
+
+	e, found, err := s.Get(ctx, "a")
+	if err != nil || !found || string(e.Body) != `{"uuid":"a"}` || e.Headers.Get("X-Gusto-Signature") != "sig" {
+		t.Errorf("incorrect event: got %+v, %v (err %v)", e, found, err)
+	}
+	if _, found, err := s.Get(ctx, "missing"); err != nil || found {
+		t.Errorf("Get of a missing event: got found %v (err %v)", found, err)
+	}
+
+	testCases := []struct {
+		name   string
+		filter Filter
+		want   []string
+	}{
+		{"Everything Oldest First", Filter{}, []string{"a", "b", "c", "../escape"}},
+		{"By Event Type", Filter{EventType: "company.updated"}, []string{"a", "c"}},
+		{"By Time Range", Filter{Since: base.Add(time.Minute), Until: base.Add(3 * time.Minute)}, []string{"b", "c"}},
+		{"Limited", Filter{Limit: 1}, []string{"a"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			list, err := s.List(ctx, tc.filter)
+			if err != nil {
+				t.Fatalf("List failed: %v", err)
+			}
+			var got []string
+			for _, e := range list {
+				got = append(got, e.UUID)
+			}
+			if len(got) != len(tc.want) {
+				t.Fatalf("incorrect events: got %v want %v", got, tc.want)
+			}
+			for i := range got {
+				if got[i] != tc.want[i] {
+					t.Errorf("incorrect events: got %v want %v", got, tc.want)
+					break
+				}
+			}
+		})
+	}
+
+	// A redelivery replaces the archived event.
+	if err := s.Put(ctx, Event{UUID: "a", EventType: "company.updated", ReceivedAt: base.Add(4 * time.Minute), Body: []byte(`{"uuid":"a","v":2}`)}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if e, _, _ := s.Get(ctx, "a"); string(e.Body) != `{"uuid":"a","v":2}` {
+		t.Errorf("incorrect body after redelivery: %s", e.Body)
+	}
+
+	pruned, err := s.Prune(ctx, base.Add(2*time.Minute+time.Second))
+	if err != nil || pruned != 2 {
+		t.Errorf("Prune: got %d (err %v) want 2", pruned, err)
+	}
+	if list, _ := s.List(ctx, Filter{}); len(list) != 2 {
+		t.Errorf("incorrect events left after Prune: got %d want 2", len(list))
+	}
+}
+
+func TestHeaders(t *testing.T) {
+	h := http.Header{"Authorization": {"Bearer x"}, "Cookie": {"a=b"}, "X-Gusto-Signature": {"sig"}}
+	got := Headers(h)
+	if got.Get("Authorization") != "" || got.Get("Cookie") != "" || got.Get("X-Gusto-Signature") != "sig" {
+		t.Errorf("incorrect archived headers: %v", got)
+	}
+	if h.Get("Authorization") == "" {
+		t.Errorf("Expected the request's headers to be left alone")
+	}
+}
@@ -0,0 +1,126 @@
+package archive
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// postgresSchema creates the table used by PostgresStore.
+const postgresSchema = `
+CREATE TABLE IF NOT EXISTS webhook_event_archive (
+	uuid        TEXT PRIMARY KEY,
+	event_type  TEXT NOT NULL,
+	received_at TIMESTAMPTZ NOT NULL,
+	request_id  TEXT NOT NULL DEFAULT '',
+	headers     JSONB NOT NULL,
+	body        BYTEA NOT NULL
+);
+CREATE INDEX IF NOT EXISTS webhook_event_archive_received_at_idx ON webhook_event_archive (received_at);
+CREATE INDEX IF NOT EXISTS webhook_event_archive_event_type_idx ON webhook_event_archive (event_type, received_at);
+`
+
+// PostgresStore is a Store backed by a Postgres table, shared by every
+// replica.
+type PostgresStore struct {
+	db *sql.DB
+}
+
+var _ Store = (*PostgresStore)(nil)
+
+// NewPostgresStore creates a PostgresStore using an open database handle.
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+// Migrate creates the backing table if it does not already exist.
+func (s *PostgresStore) Migrate(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, postgresSchema); err != nil {
+		return fmt.Errorf("creating event archive table: %w", err)
+	}
+	return nil
+}
+
+// Put implements Store.
+func (s *PostgresStore) Put(ctx context.Context, e Event) error {
+	headers, err := json.Marshal(e.Headers)
+	if err != nil {
+		return fmt.Errorf("encoding archived headers: %w", err)
+	}
+	if _, err := s.db.ExecContext(ctx, `
+		INSERT INTO webhook_event_archive (uuid, event_type, received_at, request_id, headers, body)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (uuid) DO UPDATE SET event_type = EXCLUDED.event_type, received_at = EXCLUDED.received_at,
+			request_id = EXCLUDED.request_id, headers = EXCLUDED.headers, body = EXCLUDED.body`,
+		e.UUID, e.EventType, e.ReceivedAt, e.RequestID, headers, e.Body,
+	); err != nil {
+		return fmt.Errorf("archiving event: %w", err)
+	}
+	return nil
+}
+
+// Get implements Store.
+func (s *PostgresStore) Get(ctx context.Context, uuid string) (Event, bool, error) {
+	rows, err := s.query(ctx, `WHERE uuid = $1`, uuid)
+	if err != nil || len(rows) == 0 {
+		return Event{}, false, err
+	}
+	return rows[0], true, nil
+}
+
+// List implements Store.
+func (s *PostgresStore) List(ctx context.Context, f Filter) ([]Event, error) {
+	limit := sql.NullInt64{Int64: int64(f.Limit), Valid: f.Limit > 0}
+	return s.query(ctx, `
+		WHERE ($1 = '' OR event_type = $1)
+		AND ($2::timestamptz IS NULL OR received_at >= $2)
+		AND ($3::timestamptz IS NULL OR received_at < $3)
+		ORDER BY received_at, uuid LIMIT $4`,
+		f.EventType, nullTime(f.Since), nullTime(f.Until), limit,
+	)
+}
+
+// Prune implements Store.
+func (s *PostgresStore) Prune(ctx context.Context, cutoff time.Time) (int, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM webhook_event_archive WHERE received_at < $1`, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("pruning event archive: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
+
+// query reads the archived events selected by the SQL following FROM.
+func (s *PostgresStore) query(ctx context.Context, where string, args ...any) ([]Event, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT uuid, event_type, received_at, request_id, headers, body FROM webhook_event_archive `+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("reading event archive: %w", err)
+	}
+	defer rows.Close()
+
+	var events []Event
+	for rows.Next() {
+		var e Event
+		var headers []byte
+		if err := rows.Scan(&e.UUID, &e.EventType, &e.ReceivedAt, &e.RequestID, &headers, &e.Body); err != nil {
+			return nil, fmt.Errorf("reading event archive: %w", err)
+		}
+		if err := json.Unmarshal(headers, &e.Headers); err != nil {
+			return nil, fmt.Errorf("decoding archived headers of %s: %w", e.UUID, err)
+		}
+		e.ReceivedAt = e.ReceivedAt.UTC()
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading event archive: %w", err)
+	}
+	return events, nil
+}
+
+// nullTime stores t as NULL if it is unset.
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
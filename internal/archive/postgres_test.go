@@ -0,0 +1,56 @@
+package archive
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+func TestPostgresStore(t *testing.T) {
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN not set; skipping Postgres tests")
+	}
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	if _, err := db.Exec(`DROP TABLE IF EXISTS webhook_event_archive`); err != nil {
+		t.Fatalf("failed to reset table: %v", err)
+	}
+	s := NewPostgresStore(db)
+	if err := s.Migrate(ctx); err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+
+	base := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	for _, e := range []Event{
+		{UUID: "b", EventType: "employee.created", ReceivedAt: base.Add(time.Minute), Headers: http.Header{}, Body: []byte(`{"uuid":"b"}`)},
+		{UUID: "a", EventType: "company.updated", ReceivedAt: base, RequestID: "req-1", Headers: http.Header{"X-Gusto-Signature": {"sig"}}, Body: []byte(`{"uuid":"a"}`)},
+	} {
+		if err := s.Put(ctx, e); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+
+	e, found, err := s.Get(ctx, "a")
+	if err != nil || !found || e.RequestID != "req-1" || e.Headers.Get("X-Gusto-Signature") != "sig" || !e.ReceivedAt.Equal(base) {
+		t.Errorf("incorrect event: got %+v, %v (err %v)", e, found, err)
+	}
+	if list, err := s.List(ctx, Filter{}); err != nil || len(list) != 2 || list[0].UUID != "a" {
+		t.Errorf("incorrect list: got %+v (err %v)", list, err)
+	}
+	if list, err := s.List(ctx, Filter{EventType: "employee.created", Since: base}); err != nil || len(list) != 1 || list[0].UUID != "b" {
+		t.Errorf("incorrect filtered list: got %+v (err %v)", list, err)
+	}
+	if pruned, err := s.Prune(ctx, base.Add(time.Second)); err != nil || pruned != 1 {
+		t.Errorf("Prune: got %d (err %v) want 1", pruned, err)
+	}
+}
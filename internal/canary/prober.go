@@ -0,0 +1,151 @@
+package canary
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"gusto-webhook-guide/internal/middleware"
+	"gusto-webhook-guide/internal/models"
+	"gusto-webhook-guide/internal/providers/gusto"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// EventType is the event type used for synthetic canary events.
+const EventType = "canary.probe"
+
+const pollInterval = 250 * time.Millisecond
+
+// ProcessedChecker reports whether an event UUID has been fully processed.
+type ProcessedChecker interface {
+	Has(ctx context.Context, key string) (bool, error)
+}
+
+// Prober periodically injects a signed synthetic event through the public
+// webhook endpoint and checks that it is processed within the SLO.
+type Prober struct {
+	Logger   *slog.Logger
+	URL      string        // Public webhook URL, e.g. https://example.com/webhooks
+	Secret   string        // HMAC secret used to sign the synthetic event.
+	Interval time.Duration // How often a probe is sent.
+	SLO      time.Duration // Maximum time allowed from send to processed.
+	Store    ProcessedChecker
+	Client   *http.Client
+
+	// Signature is how the event is signed, gusto.DefaultSignature if zero.
+	Signature middleware.SignatureScheme
+}
+
+// Run sends a probe every Interval until ctx is cancelled. Failures are logged
+// as alerts, and a recovery is logged once the path is healthy again.
+func (p *Prober) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+
+	consecutiveFailures := 0
+	for {
+		latency, err := p.Probe(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			consecutiveFailures++
+			p.Logger.Error("CRITICAL: Canary probe failed, end-to-end webhook processing is degraded",
+				"error", err,
+				"consecutive_failures", consecutiveFailures,
+				"slo", p.SLO,
+			)
+		} else {
+			if consecutiveFailures > 0 {
+				p.Logger.Info("Canary probe recovered", "previous_failures", consecutiveFailures)
+			}
+			consecutiveFailures = 0
+			p.Logger.Info("Canary probe succeeded", "latency", latency)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Probe sends a single synthetic event and waits for it to be processed.
+// It returns the end-to-end latency on success.
+func (p *Prober) Probe(ctx context.Context) (time.Duration, error) {
+	uuid, err := newUUID()
+	if err != nil {
+		return 0, fmt.Errorf("generating canary uuid: %w", err)
+	}
+
+	body, err := json.Marshal(models.WebhookEvent{
+		UUID:         uuid,
+		EventType:    EventType,
+		ResourceType: "Canary",
+		ResourceUUID: uuid,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("encoding canary event: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.SLO)
+	defer cancel()
+
+	start := time.Now()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("building canary request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	signature := cmp.Or(p.Signature, gusto.DefaultSignature)
+	req.Header.Set(signature.Header, signature.Sign(p.Secret, body))
+
+	resp, err := p.client().Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("sending canary event: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		return 0, fmt.Errorf("canary event rejected with status %d", resp.StatusCode)
+	}
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		processed, err := p.Store.Has(ctx, uuid)
+		if err == nil && processed {
+			return time.Since(start), nil
+		}
+		select {
+		case <-ctx.Done():
+			if err != nil {
+				return 0, fmt.Errorf("canary event %s not confirmed within %s: %w", uuid, p.SLO, err)
+			}
+			return 0, fmt.Errorf("canary event %s not processed within %s", uuid, p.SLO)
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *Prober) client() *http.Client {
+	if p.Client != nil {
+		return p.Client
+	}
+	return http.DefaultClient
+}
+
+// newUUID returns a random RFC 4122 version 4 UUID.
+func newUUID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
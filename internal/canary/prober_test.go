@@ -0,0 +1,105 @@
+package canary
+
+import (
+	"context"
+	"encoding/json"
+	"gusto-webhook-guide/internal/models"
+	"gusto-webhook-guide/internal/providers/gusto"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeStore is a minimal concurrency-safe ProcessedChecker for tests.
+type fakeStore struct {
+	mu   sync.Mutex
+	keys map[string]bool
+}
+
+func (s *fakeStore) Has(_ context.Context, key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.keys[key], nil
+}
+
+func (s *fakeStore) set(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[key] = true
+}
+
+func TestProbe(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	const secret = "test-secret"
+
+	testCases := []struct {
+		name         string
+		statusCode   int
+		markComplete bool
+		expectErr    bool
+	}{
+		{
+			name:         "Success - Event accepted and processed",
+			statusCode:   http.StatusAccepted,
+			markComplete: true,
+			expectErr:    false,
+		},
+		{
+			name:         "Failure - Event rejected",
+			statusCode:   http.StatusServiceUnavailable,
+			markComplete: false,
+			expectErr:    true,
+		},
+		{
+			name:         "Failure - Event accepted but never processed",
+			statusCode:   http.StatusAccepted,
+			markComplete: false,
+			expectErr:    true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := &fakeStore{keys: map[string]bool{}}
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				if got, want := r.Header.Get("X-Gusto-Signature"), gusto.DefaultSignature.Sign(secret, body); got != want {
+					t.Errorf("wrong signature: got %q want %q", got, want)
+				}
+
+				var event models.WebhookEvent
+				if err := json.Unmarshal(body, &event); err != nil {
+					t.Errorf("invalid canary body: %v", err)
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				if event.EventType != EventType {
+					t.Errorf("wrong event type: got %q want %q", event.EventType, EventType)
+				}
+				if tc.markComplete {
+					store.set(event.UUID)
+				}
+				w.WriteHeader(tc.statusCode)
+			}))
+			defer server.Close()
+
+			prober := &Prober{
+				Logger: logger,
+				URL:    server.URL,
+				Secret: secret,
+				SLO:    500 * time.Millisecond,
+				Store:  store,
+			}
+
+			_, err := prober.Probe(t.Context())
+			if (err != nil) != tc.expectErr {
+				t.Errorf("unexpected probe result: got err %v, want error %v", err, tc.expectErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package capture
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const redacted = "[REDACTED]"
+
+// sensitiveHeaders are replaced before a request is stored.
+var sensitiveHeaders = []string{"Authorization", "Cookie", "Proxy-Authorization"}
+
+// sensitiveBodyFields are top-level JSON fields replaced before a request is
+// stored. The verification token doubles as our HMAC secret.
+var sensitiveBodyFields = []string{"verification_token"}
+
+// Request is a captured raw HTTP request.
+type Request struct {
+	ReceivedAt time.Time           `json:"received_at"`
+	Method     string              `json:"method"`
+	Path       string              `json:"path"`
+	RemoteAddr string              `json:"remote_addr"`
+	Headers    map[string][]string `json:"headers"`
+	Body       string              `json:"body"`
+}
+
+// Ring keeps the last N captured requests in memory, so signature mismatch
+// investigations don't require reproducing traffic.
+type Ring struct {
+	mu    sync.Mutex
+	items []Request
+	next  int // Index the next request will be written to.
+	full  bool
+}
+
+// NewRing creates a Ring holding up to size requests.
+func NewRing(size int) *Ring {
+	return &Ring{items: make([]Request, size)}
+}
+
+// Add stores a request, evicting the oldest one if the ring is full.
+func (r *Ring) Add(req Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.items) == 0 {
+		return
+	}
+	r.items[r.next] = req
+	r.next = (r.next + 1) % len(r.items)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// Snapshot returns the captured requests, oldest first.
+func (r *Ring) Snapshot() []Request {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.full {
+		return append([]Request(nil), r.items[:r.next]...)
+	}
+	out := make([]Request, 0, len(r.items))
+	out = append(out, r.items[r.next:]...)
+	return append(out, r.items[:r.next]...)
+}
+
+// Middleware captures each request, with secrets redacted, before passing it
+// on. It should run before signature verification so rejected requests are
+// captured too.
+func (r *Ring) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		if err != nil {
+			// Let the next handler surface the read error.
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		headers := req.Header.Clone()
+		for _, name := range sensitiveHeaders {
+			if headers.Get(name) != "" {
+				headers.Set(name, redacted)
+			}
+		}
+
+		r.Add(Request{
+			ReceivedAt: time.Now().UTC(),
+			Method:     req.Method,
+			Path:       req.URL.Path,
+			RemoteAddr: req.RemoteAddr,
+			Headers:    headers,
+			Body:       redactBody(body),
+		})
+		next.ServeHTTP(w, req)
+	})
+}
+
+// HandleDownload serves the captured requests as a JSON attachment.
+func (r *Ring) HandleDownload(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", `attachment; filename="captured-requests.json"`)
+	json.NewEncoder(w).Encode(r.Snapshot())
+}
+
+// redactBody replaces sensitive top-level JSON fields. Bodies without such
+// fields are returned byte-for-byte so signatures can still be recomputed.
+func redactBody(body []byte) string {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return string(body)
+	}
+
+	changed := false
+	for _, name := range sensitiveBodyFields {
+		if _, ok := fields[name]; ok {
+			fields[name] = json.RawMessage(`"` + redacted + `"`)
+			changed = true
+		}
+	}
+	if !changed {
+		return string(body)
+	}
+
+	out, err := json.Marshal(fields)
+	if err != nil {
+		return redacted
+	}
+	return string(out)
+}
@@ -0,0 +1,103 @@
+package capture
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRing(t *testing.T) {
+	ring := NewRing(3)
+	for _, path := range []string{"/a", "/b", "/c", "/d"} {
+		ring.Add(Request{Path: path})
+	}
+
+	// The oldest request is evicted once the ring is full.
+	snapshot := ring.Snapshot()
+	var got []string
+	for _, req := range snapshot {
+		got = append(got, req.Path)
+	}
+	if want := "/b,/c,/d"; strings.Join(got, ",") != want {
+		t.Errorf("incorrect snapshot order: got %v want %v", got, want)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	testCases := []struct {
+		name         string
+		body         string
+		expectedBody string
+	}{
+		{
+			name:         "Event Body Kept Verbatim",
+			body:         `{"uuid": "123",  "event_type": "company.updated"}`,
+			expectedBody: `{"uuid": "123",  "event_type": "company.updated"}`,
+		},
+		{
+			name:         "Verification Token Redacted",
+			body:         `{"verification_token":"secret","webhook_subscription_uuid":"xyz"}`,
+			expectedBody: `{"verification_token":"[REDACTED]","webhook_subscription_uuid":"xyz"}`,
+		},
+		{
+			name:         "Non-JSON Body Kept Verbatim",
+			body:         `not json`,
+			expectedBody: `not json`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ring := NewRing(1)
+
+			// The next handler must still see the full, unredacted body.
+			nextHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				if string(body) != tc.body {
+					t.Errorf("next handler saw wrong body: got %q want %q", body, tc.body)
+				}
+				w.WriteHeader(http.StatusOK)
+			})
+
+			req := httptest.NewRequest("POST", "/webhooks", bytes.NewBufferString(tc.body))
+			req.Header.Set("Authorization", "Bearer token")
+			req.Header.Set("X-Gusto-Signature", "abc")
+			ring.Middleware(nextHandler).ServeHTTP(httptest.NewRecorder(), req)
+
+			captured := ring.Snapshot()
+			if len(captured) != 1 {
+				t.Fatalf("expected 1 captured request, got %d", len(captured))
+			}
+			if captured[0].Body != tc.expectedBody {
+				t.Errorf("incorrect captured body: got %q want %q", captured[0].Body, tc.expectedBody)
+			}
+			headers := http.Header(captured[0].Headers)
+			if got := headers.Get("Authorization"); got != redacted {
+				t.Errorf("Authorization header not redacted: got %q", got)
+			}
+			if got := headers.Get("X-Gusto-Signature"); got != "abc" {
+				t.Errorf("signature header should be kept: got %q", got)
+			}
+		})
+	}
+}
+
+func TestHandleDownload(t *testing.T) {
+	ring := NewRing(2)
+	ring.Add(Request{Path: "/webhooks", Body: "{}"})
+
+	rr := httptest.NewRecorder()
+	ring.HandleDownload(rr, httptest.NewRequest("GET", "/admin/captures", nil))
+
+	var got []Request
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON response: %v", err)
+	}
+	if len(got) != 1 || got[0].Path != "/webhooks" {
+		t.Errorf("unexpected download contents: %+v", got)
+	}
+}
@@ -5,3 +5,7 @@ type CtxKey string
 
 // RequestBodyKey is the key for storing the raw request body in the context.
 const RequestBodyKey CtxKey = "requestBody"
+
+// RequestIDKey is the key for storing the request's correlation ID in the
+// context.
+const RequestIDKey CtxKey = "requestID"
@@ -0,0 +1,24 @@
+package cron
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	taskRuns = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cron_task_runs_total",
+		Help: "Periodic task runs, by task and result (success, failure, or skipped because the previous run was still going).",
+	}, []string{"task", "result"})
+
+	taskDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "cron_task_duration_seconds",
+		Help:    "How long each periodic task run took, by task.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"task"})
+
+	taskLastSuccess = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cron_task_last_success_timestamp_seconds",
+		Help: "Unix time each periodic task last succeeded, by task.",
+	}, []string{"task"})
+)
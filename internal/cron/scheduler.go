@@ -0,0 +1,181 @@
+// Package cron runs periodic maintenance tasks in process, such as sweeping
+// expired idempotency keys or checking the webhook subscription.
+package cron
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Task is a periodic maintenance task.
+type Task struct {
+	Name     string
+	Interval time.Duration
+	// Jitter, if set, delays each run by a random duration of up to Jitter,
+	// so replicas started together don't run the task together.
+	Jitter time.Duration
+	// RunAtStart runs the task as soon as the scheduler starts, rather than
+	// after the first interval.
+	RunAtStart bool
+	Run        func(ctx context.Context) error
+}
+
+// TaskStatus describes a task's most recent runs.
+type TaskStatus struct {
+	Name     string `json:"name"`
+	Interval string `json:"interval"`
+	Running  bool   `json:"running"`
+	Runs     int    `json:"runs"`
+	Failures int    `json:"failures"`
+	// Skipped counts runs that were due while the previous one was still
+	// running.
+	Skipped      int       `json:"skipped"`
+	LastStarted  time.Time `json:"last_started,omitzero"`
+	LastDuration string    `json:"last_duration,omitempty"`
+	LastError    string    `json:"last_error,omitempty"` // Empty if the last run succeeded.
+	LastSuccess  time.Time `json:"last_success,omitzero"`
+	NextRun      time.Time `json:"next_run,omitzero"`
+}
+
+// Scheduler runs registered tasks on their intervals. A run that is due
+// while the task's previous run is still going is skipped, so runs of a task
+// never overlap.
+type Scheduler struct {
+	logger *slog.Logger
+	wg     sync.WaitGroup
+
+	mu    sync.Mutex
+	tasks []*task
+}
+
+type task struct {
+	Task
+	status TaskStatus // Guarded by Scheduler.mu.
+}
+
+// NewScheduler creates a Scheduler with no tasks.
+func NewScheduler(logger *slog.Logger) *Scheduler {
+	return &Scheduler{logger: logger}
+}
+
+// Register adds a task. It panics if the task has no name or function, a
+// non-positive interval, or a name already registered. It must be called
+// before Run.
+func (s *Scheduler) Register(t Task) {
+	if t.Name == "" || t.Run == nil || t.Interval <= 0 {
+		panic(fmt.Sprintf("cron: invalid task %q", t.Name))
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, existing := range s.tasks {
+		if existing.Name == t.Name {
+			panic(fmt.Sprintf("cron: task %q registered twice", t.Name))
+		}
+	}
+	s.tasks = append(s.tasks, &task{Task: t, status: TaskStatus{Name: t.Name, Interval: t.Interval.String()}})
+}
+
+// Run runs the tasks until ctx is cancelled, then waits for runs in
+// progress, whose contexts are cancelled too, to return.
+func (s *Scheduler) Run(ctx context.Context) {
+	s.mu.Lock()
+	tasks := s.tasks
+	s.mu.Unlock()
+	for _, t := range tasks {
+		s.wg.Add(1)
+		go s.loop(ctx, t)
+	}
+	<-ctx.Done()
+	s.wg.Wait()
+}
+
+// Status returns the status of every task, in the order they were
+// registered.
+func (s *Scheduler) Status() []TaskStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	statuses := make([]TaskStatus, len(s.tasks))
+	for i, t := range s.tasks {
+		statuses[i] = t.status
+	}
+	return statuses
+}
+
+// loop starts t every interval, plus jitter, until ctx is cancelled.
+func (s *Scheduler) loop(ctx context.Context, t *task) {
+	defer s.wg.Done()
+	delay := t.Interval
+	if t.RunAtStart {
+		delay = 0
+	}
+	for {
+		if t.Jitter > 0 {
+			delay += rand.N(t.Jitter)
+		}
+		s.mu.Lock()
+		t.status.NextRun = time.Now().UTC().Add(delay)
+		s.mu.Unlock()
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		s.start(ctx, t)
+		delay = t.Interval
+	}
+}
+
+// start runs t in the background, unless its previous run hasn't finished.
+func (s *Scheduler) start(ctx context.Context, t *task) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t.status.Running {
+		t.status.Skipped++
+		taskRuns.WithLabelValues(t.Name, "skipped").Inc()
+		s.logger.Warn("Periodic task still running, skipping this run", "task", t.Name, "started", t.status.LastStarted)
+		return
+	}
+	t.status.Running = true
+	t.status.LastStarted = time.Now().UTC()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		start := time.Now()
+		err := t.Run(ctx)
+		elapsed := time.Since(start)
+		taskDuration.WithLabelValues(t.Name).Observe(elapsed.Seconds())
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		t.status.Running = false
+		t.status.Runs++
+		t.status.LastDuration = elapsed.String()
+		if err != nil {
+			t.status.Failures++
+			t.status.LastError = err.Error()
+			taskRuns.WithLabelValues(t.Name, "failure").Inc()
+			s.logger.Error("Periodic task failed", "task", t.Name, "duration", elapsed, "error", err)
+			return
+		}
+		t.status.LastError = ""
+		t.status.LastSuccess = time.Now().UTC()
+		taskRuns.WithLabelValues(t.Name, "success").Inc()
+		taskLastSuccess.WithLabelValues(t.Name).SetToCurrentTime()
+	}()
+}
+
+// HandleStatus serves the status of every task as JSON.
+func (s *Scheduler) HandleStatus(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.Status())
+}
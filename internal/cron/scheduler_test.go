@@ -0,0 +1,107 @@
+package cron
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSchedulerRunsTasks(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	s := NewScheduler(logger)
+	var ok, failing atomic.Int32
+	s.Register(Task{Name: "ok", Interval: 10 * time.Millisecond, Run: func(context.Context) error {
+		ok.Add(1)
+		return nil
+	}})
+	s.Register(Task{Name: "failing", Interval: time.Hour, RunAtStart: true, Run: func(context.Context) error {
+		failing.Add(1)
+		return errors.New("boom")
+	}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	s.Run(ctx)
+
+	if ok.Load() < 2 {
+		t.Errorf("task ran too few times: got %d", ok.Load())
+	}
+	if failing.Load() != 1 {
+		t.Errorf("RunAtStart task ran %d times, want 1", failing.Load())
+	}
+	statuses := s.Status()
+	if len(statuses) != 2 {
+		t.Fatalf("incorrect statuses: got %+v", statuses)
+	}
+	if st := statuses[0]; st.Name != "ok" || st.Runs != int(ok.Load()) || st.Failures != 0 || st.LastSuccess.IsZero() {
+		t.Errorf("incorrect status for ok: got %+v", st)
+	}
+	if st := statuses[1]; st.Failures != 1 || st.LastError != "boom" || !st.LastSuccess.IsZero() {
+		t.Errorf("incorrect status for failing: got %+v", st)
+	}
+}
+
+func TestSchedulerSkipsOverlappingRuns(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	s := NewScheduler(logger)
+	var running, maxRunning, runs atomic.Int32
+	s.Register(Task{Name: "slow", Interval: 5 * time.Millisecond, Run: func(ctx context.Context) error {
+		runs.Add(1)
+		n := running.Add(1)
+		defer running.Add(-1)
+		if n > maxRunning.Load() {
+			maxRunning.Store(n)
+		}
+		select {
+		case <-time.After(40 * time.Millisecond):
+		case <-ctx.Done():
+		}
+		return nil
+	}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	s.Run(ctx)
+
+	if maxRunning.Load() != 1 {
+		t.Errorf("runs overlapped: up to %d at once", maxRunning.Load())
+	}
+	if st := s.Status()[0]; st.Skipped == 0 || st.Running {
+		t.Errorf("expected skipped runs and nothing running after Run returned: got %+v", st)
+	}
+}
+
+func TestSchedulerHandleStatus(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	s := NewScheduler(logger)
+	s.Register(Task{Name: "sweep", Interval: time.Minute, Run: func(context.Context) error { return nil }})
+
+	rr := httptest.NewRecorder()
+	s.HandleStatus(rr, httptest.NewRequest("GET", "/admin/cron", nil))
+
+	var statuses []TaskStatus
+	if err := json.Unmarshal(rr.Body.Bytes(), &statuses); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(statuses) != 1 || statuses[0].Name != "sweep" || statuses[0].Interval != "1m0s" {
+		t.Errorf("incorrect statuses: got %+v", statuses)
+	}
+}
+
+func TestRegisterRejectsDuplicates(t *testing.T) {
+	s := NewScheduler(slog.New(slog.NewJSONHandler(io.Discard, nil)))
+	task := Task{Name: "sweep", Interval: time.Minute, Run: func(context.Context) error { return nil }}
+	s.Register(task)
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic for a duplicate task name")
+		}
+	}()
+	s.Register(task)
+}
@@ -0,0 +1,90 @@
+package deliveries
+
+import (
+	"encoding/json"
+	"reflect"
+	"slices"
+	"strconv"
+)
+
+// Change is a single difference between two JSON documents.
+type Change struct {
+	Path string `json:"path"` // Dotted path to the changed value, e.g. "payload.name" or "items[2]".
+	Old  any    `json:"old"`  // Nil if the value was added.
+	New  any    `json:"new"`  // Nil if the value was removed.
+}
+
+// Diff returns the structural differences between two JSON bodies. If either
+// body isn't valid JSON, the whole bodies are reported as a single change.
+func Diff(oldBody, newBody []byte) []Change {
+	var oldVal, newVal any
+	if json.Unmarshal(oldBody, &oldVal) != nil || json.Unmarshal(newBody, &newVal) != nil {
+		if string(oldBody) == string(newBody) {
+			return nil
+		}
+		return []Change{{Path: "", Old: string(oldBody), New: string(newBody)}}
+	}
+	return diffValues("", oldVal, newVal, nil)
+}
+
+func diffValues(path string, oldVal, newVal any, changes []Change) []Change {
+	switch o := oldVal.(type) {
+	case map[string]any:
+		n, ok := newVal.(map[string]any)
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(o)+len(n))
+		for k := range o {
+			keys = append(keys, k)
+		}
+		for k := range n {
+			if _, seen := o[k]; !seen {
+				keys = append(keys, k)
+			}
+		}
+		slices.Sort(keys)
+		for _, k := range keys {
+			ov, inOld := o[k]
+			nv, inNew := n[k]
+			switch {
+			case !inOld:
+				changes = append(changes, Change{Path: join(path, k), New: nv})
+			case !inNew:
+				changes = append(changes, Change{Path: join(path, k), Old: ov})
+			default:
+				changes = diffValues(join(path, k), ov, nv, changes)
+			}
+		}
+		return changes
+	case []any:
+		n, ok := newVal.([]any)
+		if !ok {
+			break
+		}
+		for i := 0; i < max(len(o), len(n)); i++ {
+			elem := path + "[" + strconv.Itoa(i) + "]"
+			switch {
+			case i >= len(o):
+				changes = append(changes, Change{Path: elem, New: n[i]})
+			case i >= len(n):
+				changes = append(changes, Change{Path: elem, Old: o[i]})
+			default:
+				changes = diffValues(elem, o[i], n[i], changes)
+			}
+		}
+		return changes
+	}
+
+	if !reflect.DeepEqual(oldVal, newVal) {
+		changes = append(changes, Change{Path: path, Old: oldVal, New: newVal})
+	}
+	return changes
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,65 @@
+package deliveries
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	testCases := []struct {
+		name     string
+		oldBody  string
+		newBody  string
+		expected []Change
+	}{
+		{
+			name:     "Identical Bodies",
+			oldBody:  `{"uuid":"1","payload":{"name":"Acme"}}`,
+			newBody:  `{"payload": {"name": "Acme"}, "uuid": "1"}`,
+			expected: nil,
+		},
+		{
+			name:    "Changed Nested Field",
+			oldBody: `{"uuid":"1","payload":{"name":"Acme"}}`,
+			newBody: `{"uuid":"1","payload":{"name":"Acme Inc"}}`,
+			expected: []Change{
+				{Path: "payload.name", Old: "Acme", New: "Acme Inc"},
+			},
+		},
+		{
+			name:    "Added and Removed Fields",
+			oldBody: `{"uuid":"1","a":1}`,
+			newBody: `{"uuid":"1","b":2}`,
+			expected: []Change{
+				{Path: "a", Old: float64(1)},
+				{Path: "b", New: float64(2)},
+			},
+		},
+		{
+			name:    "Array Elements",
+			oldBody: `{"ids":[1,2]}`,
+			newBody: `{"ids":[1,3,4]}`,
+			expected: []Change{
+				{Path: "ids[1]", Old: float64(2), New: float64(3)},
+				{Path: "ids[2]", New: float64(4)},
+			},
+		},
+		{
+			name:    "Invalid JSON",
+			oldBody: `{"uuid":"1"}`,
+			newBody: `not json`,
+			expected: []Change{
+				{Path: "", Old: `{"uuid":"1"}`, New: "not json"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := Diff([]byte(tc.oldBody), []byte(tc.newBody))
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("incorrect diff: got %+v want %+v", got, tc.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package deliveries
+
+import (
+	"bytes"
+	"encoding/json"
+	"gusto-webhook-guide/internal/apierror"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Delivery is one received body for an event UUID.
+type Delivery struct {
+	ReceivedAt time.Time `json:"received_at"`
+	Body       string    `json:"body"`
+	Changes    []Change  `json:"changes,omitempty"` // Differences from the first delivery.
+}
+
+// History is every distinct body received for an event UUID.
+type History struct {
+	EventUUID string     `json:"event_uuid"`
+	First     Delivery   `json:"first"`
+	Variants  []Delivery `json:"variants"` // Later deliveries whose body differed from First.
+
+	resources []resource // The resource and entity the event is about.
+}
+
+// resource identifies a Gusto object an event refers to.
+type resource struct {
+	Type string
+	UUID string
+}
+
+// resourcesOf returns the resource and entity named by an event body.
+func resourcesOf(body []byte) []resource {
+	var envelope struct {
+		ResourceType string `json:"resource_type"`
+		ResourceUUID string `json:"resource_uuid"`
+		EntityType   string `json:"entity_type"`
+		EntityUUID   string `json:"entity_uuid"`
+	}
+	if json.Unmarshal(body, &envelope) != nil {
+		return nil
+	}
+	var resources []resource
+	if envelope.ResourceUUID != "" {
+		resources = append(resources, resource{Type: envelope.ResourceType, UUID: envelope.ResourceUUID})
+	}
+	if envelope.EntityUUID != "" {
+		resources = append(resources, resource{Type: envelope.EntityType, UUID: envelope.EntityUUID})
+	}
+	return resources
+}
+
+// Tracker remembers the bodies delivered for recent event UUIDs so that a
+// duplicate arriving with different content is detected instead of being
+// silently dropped by the idempotency check.
+type Tracker struct {
+	mu      sync.Mutex
+	max     int
+	order   []string // Event UUIDs in first-seen order, for eviction.
+	history map[string]*History
+}
+
+// NewTracker creates a Tracker remembering up to max event UUIDs. The oldest
+// UUID is forgotten once the limit is reached.
+func NewTracker(max int) *Tracker {
+	return &Tracker{
+		max:     max,
+		history: make(map[string]*History),
+	}
+}
+
+// Observe records a delivered body. If an earlier delivery of the same UUID
+// had a different body, the new body is stored and its changes returned; an
+// identical or already-seen body returns nil.
+func (t *Tracker) Observe(eventUUID string, body []byte, receivedAt time.Time) []Change {
+	if t.max <= 0 || eventUUID == "" {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	h, found := t.history[eventUUID]
+	if !found {
+		if len(t.order) >= t.max {
+			delete(t.history, t.order[0])
+			t.order = t.order[1:]
+		}
+		t.order = append(t.order, eventUUID)
+		t.history[eventUUID] = &History{
+			EventUUID: eventUUID,
+			First:     Delivery{ReceivedAt: receivedAt, Body: string(body)},
+			resources: resourcesOf(body),
+		}
+		return nil
+	}
+
+	if bytes.Equal([]byte(h.First.Body), body) {
+		return nil
+	}
+	for _, v := range h.Variants {
+		if bytes.Equal([]byte(v.Body), body) {
+			return nil
+		}
+	}
+
+	changes := Diff([]byte(h.First.Body), body)
+	h.Variants = append(h.Variants, Delivery{ReceivedAt: receivedAt, Body: string(body), Changes: changes})
+	return changes
+}
+
+// Get returns a copy of the delivery history for an event UUID.
+func (t *Tracker) Get(eventUUID string) (History, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	h, found := t.history[eventUUID]
+	if !found {
+		return History{}, false
+	}
+	out := *h
+	out.Variants = append([]Delivery{}, h.Variants...)
+	return out, true
+}
+
+// ForResource returns the histories of the events about a resource, oldest
+// first. An event matches if either its resource or its entity has the
+// given type (compared case-insensitively, e.g. "company" or "Employee")
+// and UUID.
+func (t *Tracker) ForResource(resourceType, resourceUUID string) []History {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var histories []History
+	for _, eventUUID := range t.order {
+		h := t.history[eventUUID]
+		for _, res := range h.resources {
+			if res.UUID == resourceUUID && strings.EqualFold(res.Type, resourceType) {
+				out := *h
+				out.Variants = append([]Delivery{}, h.Variants...)
+				histories = append(histories, out)
+				break
+			}
+		}
+	}
+	return histories
+}
+
+// HandleGet serves the delivery history for the {uuid} URL parameter.
+func (t *Tracker) HandleGet(w http.ResponseWriter, r *http.Request) {
+	h, found := t.Get(chi.URLParam(r, "uuid"))
+	if !found {
+		apierror.Write(w, r, http.StatusNotFound, apierror.CodeNotFound, "No deliveries recorded for this event")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h)
+}
@@ -0,0 +1,122 @@
+package deliveries
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestTracker(t *testing.T) {
+	now := time.Now()
+	tracker := NewTracker(2)
+
+	original := []byte(`{"uuid":"a","payload":{"name":"Acme"}}`)
+	changed := []byte(`{"uuid":"a","payload":{"name":"Acme Inc"}}`)
+
+	if changes := tracker.Observe("a", original, now); changes != nil {
+		t.Errorf("first delivery should report no changes, got %+v", changes)
+	}
+	if changes := tracker.Observe("a", original, now); changes != nil {
+		t.Errorf("identical duplicate should report no changes, got %+v", changes)
+	}
+	if changes := tracker.Observe("a", changed, now); len(changes) != 1 {
+		t.Errorf("changed duplicate should report 1 change, got %+v", changes)
+	}
+	if changes := tracker.Observe("a", changed, now); changes != nil {
+		t.Errorf("an already-seen variant should not be reported again, got %+v", changes)
+	}
+
+	h, found := tracker.Get("a")
+	if !found {
+		t.Fatalf("expected history for event a")
+	}
+	if h.First.Body != string(original) || len(h.Variants) != 1 || h.Variants[0].Body != string(changed) {
+		t.Errorf("unexpected history: %+v", h)
+	}
+
+	// The oldest UUID is evicted once the tracker is full.
+	tracker.Observe("b", original, now)
+	tracker.Observe("c", original, now)
+	if _, found := tracker.Get("a"); found {
+		t.Errorf("expected event a to be evicted")
+	}
+	if _, found := tracker.Get("c"); !found {
+		t.Errorf("expected event c to be tracked")
+	}
+}
+
+func TestHandleGet(t *testing.T) {
+	tracker := NewTracker(10)
+	tracker.Observe("a", []byte(`{"uuid":"a"}`), time.Now())
+
+	router := chi.NewRouter()
+	router.Get("/admin/events/{uuid}/deliveries", tracker.HandleGet)
+
+	testCases := []struct {
+		name               string
+		uuid               string
+		expectedStatusCode int
+	}{
+		{name: "Success - Known Event", uuid: "a", expectedStatusCode: http.StatusOK},
+		{name: "Failure - Unknown Event", uuid: "missing", expectedStatusCode: http.StatusNotFound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest("GET", "/admin/events/"+tc.uuid+"/deliveries", nil))
+
+			if status := rr.Code; status != tc.expectedStatusCode {
+				t.Errorf("handler returned wrong status code: got %v want %v", status, tc.expectedStatusCode)
+			}
+			if tc.expectedStatusCode == http.StatusOK {
+				var h History
+				if err := json.Unmarshal(rr.Body.Bytes(), &h); err != nil || h.EventUUID != tc.uuid {
+					t.Errorf("unexpected response body %q (err %v)", rr.Body.String(), err)
+				}
+			}
+		})
+	}
+}
+
+func TestForResource(t *testing.T) {
+	now := time.Now()
+	tracker := NewTracker(10)
+	tracker.Observe("1", []byte(`{"uuid":"1","resource_type":"Company","resource_uuid":"c1"}`), now)
+	tracker.Observe("2", []byte(`{"uuid":"2","resource_type":"Company","resource_uuid":"c2"}`), now)
+	tracker.Observe("3", []byte(`{"uuid":"3","resource_type":"Company","resource_uuid":"c1","entity_type":"Employee","entity_uuid":"e1"}`), now)
+	tracker.Observe("4", []byte(`not json`), now)
+
+	testCases := []struct {
+		name          string
+		resourceType  string
+		resourceUUID  string
+		expectedUUIDs []string
+	}{
+		{name: "Resource", resourceType: "company", resourceUUID: "c1", expectedUUIDs: []string{"1", "3"}},
+		{name: "Entity", resourceType: "Employee", resourceUUID: "e1", expectedUUIDs: []string{"3"}},
+		{name: "Wrong Type", resourceType: "employee", resourceUUID: "c1"},
+		{name: "Unknown UUID", resourceType: "company", resourceUUID: "c3"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var uuids []string
+			for _, h := range tracker.ForResource(tc.resourceType, tc.resourceUUID) {
+				uuids = append(uuids, h.EventUUID)
+			}
+			if len(uuids) != len(tc.expectedUUIDs) {
+				t.Fatalf("incorrect events: got %v want %v", uuids, tc.expectedUUIDs)
+			}
+			for i := range uuids {
+				if uuids[i] != tc.expectedUUIDs[i] {
+					t.Errorf("incorrect events: got %v want %v", uuids, tc.expectedUUIDs)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+// Package devlog formats log records for reading in a terminal during local
+// development, rather than for a log pipeline.
+package devlog
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ANSI escape codes for the colors used.
+const (
+	reset  = "\033[0m"
+	dim    = "\033[2m"
+	red    = "\033[31m"
+	yellow = "\033[33m"
+	cyan   = "\033[36m"
+	gray   = "\033[90m"
+)
+
+// Options configure a Handler.
+type Options struct {
+	Level   slog.Leveler // The minimum level logged, by default Info.
+	NoColor bool         // Leave out the ANSI color codes, e.g. when not writing to a terminal.
+}
+
+// Handler is a slog.Handler that writes each record as a single line: its
+// time, level and message followed by its attributes as key=value pairs,
+// colored by level.
+type Handler struct {
+	opts   Options
+	w      io.Writer
+	mu     *sync.Mutex // Shared with the handlers derived from this one.
+	attrs  string      // Attributes added with WithAttrs, already formatted.
+	groups string      // Groups opened with WithGroup, as a key prefix.
+}
+
+var _ slog.Handler = (*Handler)(nil)
+
+// NewHandler creates a Handler writing to w. A nil opts uses the defaults.
+func NewHandler(w io.Writer, opts *Options) *Handler {
+	h := &Handler{w: w, mu: &sync.Mutex{}}
+	if opts != nil {
+		h.opts = *opts
+	}
+	return h
+}
+
+// Enabled implements slog.Handler.
+func (h *Handler) Enabled(_ context.Context, level slog.Level) bool {
+	min := slog.LevelInfo
+	if h.opts.Level != nil {
+		min = h.opts.Level.Level()
+	}
+	return level >= min
+}
+
+// Handle implements slog.Handler.
+func (h *Handler) Handle(_ context.Context, r slog.Record) error {
+	var b strings.Builder
+	if !r.Time.IsZero() {
+		b.WriteString(h.color(gray, r.Time.Format("15:04:05.000")))
+		b.WriteByte(' ')
+	}
+	b.WriteString(h.color(levelColor(r.Level), fmt.Sprintf("%-5s", r.Level.String())))
+	b.WriteByte(' ')
+	b.WriteString(r.Message)
+	b.WriteString(h.attrs)
+	r.Attrs(func(a slog.Attr) bool {
+		h.appendAttr(&b, h.groups, a)
+		return true
+	})
+	b.WriteByte('\n')
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := io.WriteString(h.w, b.String())
+	return err
+}
+
+// WithAttrs implements slog.Handler.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var b strings.Builder
+	for _, a := range attrs {
+		h.appendAttr(&b, h.groups, a)
+	}
+	h2 := *h
+	h2.attrs += b.String()
+	return &h2
+}
+
+// WithGroup implements slog.Handler.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.groups += name + "."
+	return &h2
+}
+
+// appendAttr writes a as " key=value", flattening groups into dotted keys.
+func (h *Handler) appendAttr(b *strings.Builder, prefix string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, ga := range a.Value.Group() {
+			h.appendAttr(b, prefix, ga)
+		}
+		return
+	}
+	b.WriteByte(' ')
+	b.WriteString(h.color(dim, prefix+a.Key+"="))
+	b.WriteString(formatValue(a.Value))
+}
+
+// color wraps s in the given color, unless colors are off.
+func (h *Handler) color(code, s string) string {
+	if h.opts.NoColor {
+		return s
+	}
+	return code + s + reset
+}
+
+func levelColor(level slog.Level) string {
+	switch {
+	case level >= slog.LevelError:
+		return red
+	case level >= slog.LevelWarn:
+		return yellow
+	case level >= slog.LevelInfo:
+		return cyan
+	default:
+		return gray
+	}
+}
+
+// formatValue formats v, quoting it if it would otherwise be ambiguous.
+func formatValue(v slog.Value) string {
+	var s string
+	switch v.Kind() {
+	case slog.KindString:
+		s = v.String()
+	case slog.KindTime:
+		s = v.Time().Format(time.RFC3339Nano)
+	default:
+		s = fmt.Sprint(v.Any())
+	}
+	if s == "" || strings.ContainsAny(s, " =\"\t\n") {
+		return strconv.Quote(s)
+	}
+	return s
+}
@@ -0,0 +1,37 @@
+package devlog
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewHandler(&buf, &Options{Level: slog.LevelDebug, NoColor: true}))
+
+	logger.With("component", "worker").WithGroup("job").Warn("Event failed",
+		"event_uuid", "abc", "error", errors.New("gusto unavailable"), slog.Group("retry", "attempt", 2))
+
+	line := buf.String()
+	// Drop the time, which varies.
+	_, line, _ = strings.Cut(line, " ")
+	want := `WARN  Event failed component=worker job.event_uuid=abc job.error="gusto unavailable" job.retry.attempt=2` + "\n"
+	if line != want {
+		t.Errorf("incorrect line:\ngot  %q\nwant %q", line, want)
+	}
+}
+
+func TestHandlerLevel(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewHandler(&buf, nil))
+
+	logger.Debug("hidden")
+	logger.Error("shown")
+
+	if out := buf.String(); strings.Contains(out, "hidden") || !strings.Contains(out, "\033[31mERROR\033[0m shown") {
+		t.Errorf("unexpected output: %q", out)
+	}
+}
@@ -0,0 +1,163 @@
+package listeners
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+)
+
+// handoffEnv names the listeners a process was handed, in the order of
+// their file descriptors. It is set only for a process started by Handoff.
+const handoffEnv = "LISTENER_HANDOFF"
+
+// handoffFirstFD is the file descriptor of a handed-off process's readiness
+// pipe. Its listeners follow.
+const handoffFirstFD = 3
+
+// ErrHandoffFailed is returned by Handoff when the new process exits or
+// isn't ready in time. The caller keeps its listeners and carries on serving.
+var ErrHandoffFailed = errors.New("listener handoff failed")
+
+// Inherited holds the listeners handed to this process by its predecessor.
+// A nil *Inherited, for a process started normally, has none.
+type Inherited struct {
+	listeners map[string]net.Listener
+	ready     *os.File
+}
+
+// Inherit returns the listeners handed to this process by Handoff, or nil
+// if it wasn't started by one. Call it once, at startup.
+func Inherit() (*Inherited, error) {
+	names := os.Getenv(handoffEnv)
+	if names == "" {
+		return nil, nil
+	}
+	// Unset so a process started by this one isn't confused by it.
+	os.Unsetenv(handoffEnv)
+
+	inherited := &Inherited{
+		listeners: make(map[string]net.Listener),
+		ready:     os.NewFile(handoffFirstFD, "handoff-ready"),
+	}
+	for i, name := range strings.Split(names, ",") {
+		f := os.NewFile(uintptr(handoffFirstFD+1+i), name)
+		ln, err := net.FileListener(f)
+		f.Close() // FileListener holds its own copy.
+		if err != nil {
+			return nil, fmt.Errorf("inheriting %s listener: %w", name, err)
+		}
+		inherited.listeners[name] = ln
+	}
+	return inherited, nil
+}
+
+// Listener returns the inherited listener with the given name, or nil.
+func (i *Inherited) Listener(name string) net.Listener {
+	if i == nil {
+		return nil
+	}
+	return i.listeners[name]
+}
+
+// Ready tells the predecessor that this process is serving, so it can stop
+// accepting and drain. It does nothing for a process started normally.
+func (i *Inherited) Ready() error {
+	if i == nil || i.ready == nil {
+		return nil
+	}
+	defer func() { i.ready = nil }()
+	defer i.ready.Close()
+	_, err := i.ready.Write([]byte{1})
+	return err
+}
+
+// Handoff starts a new copy of this process, with the same arguments and
+// the environment env, and hands it listeners so it serves on the same
+// sockets without any being closed in between. It returns once the new
+// process calls Ready, after which the caller should stop accepting, drain
+// and exit. If the new process exits first, e.g. because its configuration
+// is invalid, or isn't ready within timeout, it is killed and Handoff
+// returns an error wrapping ErrHandoffFailed; the caller keeps serving.
+func Handoff(ctx context.Context, listeners map[string]net.Listener, env []string, timeout time.Duration) (*os.Process, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("finding executable: %w", err)
+	}
+	readyRead, readyWrite, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("creating readiness pipe: %w", err)
+	}
+	defer readyRead.Close()
+
+	names := slices.Sorted(maps.Keys(listeners))
+	files := []*os.File{readyWrite}
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+	for _, name := range names {
+		ln, ok := listeners[name].(interface{ File() (*os.File, error) })
+		if !ok {
+			return nil, fmt.Errorf("%s listener can't be handed off", name)
+		}
+		f, err := ln.File()
+		if err != nil {
+			return nil, fmt.Errorf("duplicating %s listener: %w", name, err)
+		}
+		files = append(files, f)
+	}
+
+	cmd := exec.Command(executable, os.Args[1:]...)
+	cmd.Env = append(slices.Clone(env), handoffEnv+"="+strings.Join(names, ","))
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = files
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("starting new process: %w", err)
+	}
+	// Close our copy of the write end, so the read fails if the new process
+	// exits without calling Ready.
+	readyWrite.Close()
+	files = files[1:]
+
+	ready := make(chan error, 1)
+	go func() {
+		b := make([]byte, 1)
+		_, err := io.ReadFull(readyRead, b)
+		ready <- err
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case err = <-ready:
+		if err == nil {
+			// The socket file now belongs to the new process too, so don't
+			// remove it when our listener closes.
+			for _, ln := range listeners {
+				if unix, ok := ln.(*net.UnixListener); ok {
+					unix.SetUnlinkOnClose(false)
+				}
+			}
+			go cmd.Wait() // Reap it if it exits before we do.
+			return cmd.Process, nil
+		}
+		err = fmt.Errorf("%w: new process exited before it was ready", ErrHandoffFailed)
+	case <-timer.C:
+		err = fmt.Errorf("%w: new process wasn't ready within %s", ErrHandoffFailed, timeout)
+	case <-ctx.Done():
+		err = fmt.Errorf("%w: %w", ErrHandoffFailed, ctx.Err())
+	}
+	cmd.Process.Kill()
+	cmd.Wait()
+	return nil, err
+}
@@ -0,0 +1,86 @@
+package listeners
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+// TestMain runs the test binary as the new process when Handoff starts it.
+func TestMain(m *testing.M) {
+	if os.Getenv(handoffEnv) != "" {
+		runHandoffChild()
+		return
+	}
+	os.Exit(m.Run())
+}
+
+// runHandoffChild serves one connection on the inherited "tcp" listener,
+// answering "new", or exits without calling Ready if asked to fail.
+func runHandoffChild() {
+	inherited, err := Inherit()
+	if err != nil || os.Getenv("HANDOFF_TEST_FAIL") != "" {
+		os.Exit(1)
+	}
+	ln := inherited.Listener("tcp")
+	if ln == nil || inherited.Ready() != nil {
+		os.Exit(1)
+	}
+	conn, err := ln.Accept()
+	if err != nil {
+		os.Exit(1)
+	}
+	conn.Write([]byte("new"))
+	conn.Close()
+	os.Exit(0)
+}
+
+func TestHandoff(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	defer ln.Close()
+	listeners := map[string]net.Listener{"tcp": ln}
+
+	// A new process that fails leaves this one serving.
+	_, err = Handoff(context.Background(), listeners, append(os.Environ(), "HANDOFF_TEST_FAIL=1"), 10*time.Second)
+	if !errors.Is(err, ErrHandoffFailed) {
+		t.Fatalf("incorrect error for a failed handoff: got %v want %v", err, ErrHandoffFailed)
+	}
+
+	process, err := Handoff(context.Background(), listeners, os.Environ(), 10*time.Second)
+	if err != nil {
+		t.Fatalf("Handoff failed: %v", err)
+	}
+	defer process.Kill()
+	// Once we stop accepting, connections go to the new process.
+	ln.Close()
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	got, err := io.ReadAll(conn)
+	if err != nil || string(got) != "new" {
+		t.Errorf("incorrect response: got %q (err %v) want %q", got, err, "new")
+	}
+}
+
+func TestInheritWithoutHandoff(t *testing.T) {
+	inherited, err := Inherit()
+	if err != nil || inherited != nil {
+		t.Fatalf("expected nothing inherited, got %v (err %v)", inherited, err)
+	}
+	if ln := inherited.Listener("tcp"); ln != nil {
+		t.Errorf("expected no listener, got %v", ln)
+	}
+	if err := inherited.Ready(); err != nil {
+		t.Errorf("Ready failed: %v", err)
+	}
+}
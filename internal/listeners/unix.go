@@ -0,0 +1,53 @@
+// Package listeners opens the sockets the server accepts requests on.
+package listeners
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// ErrSocketInUse is returned by Unix when another process is still serving
+// on the socket path.
+var ErrSocketInUse = errors.New("unix socket is in use by another process")
+
+// Unix listens on a Unix domain socket at path, e.g. for a sidecar proxy on
+// the same host that terminates TLS, and sets the socket file's permissions
+// to mode. A socket file left behind by a process that didn't shut down
+// cleanly is replaced, so a restarted server can listen again; one that is
+// still being served is not. The file is removed when the listener closes.
+func Unix(path string, mode fs.FileMode) (net.Listener, error) {
+	if _, err := os.Stat(path); err == nil {
+		conn, dialErr := net.DialTimeout("unix", path, time.Second)
+		if dialErr == nil {
+			conn.Close()
+			return nil, fmt.Errorf("%w: %s", ErrSocketInUse, path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("removing stale socket: %w", err)
+		}
+	}
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("setting socket permissions: %w", err)
+	}
+	return ln, nil
+}
+
+// ParseMode parses socket file permissions written in octal, e.g. "660".
+func ParseMode(raw string) (fs.FileMode, error) {
+	mode, err := strconv.ParseUint(raw, 8, 32)
+	if err != nil || mode > 0o777 {
+		return 0, fmt.Errorf("invalid socket mode %q: want octal permissions such as 660", raw)
+	}
+	return fs.FileMode(mode), nil
+}
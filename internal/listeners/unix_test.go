@@ -0,0 +1,61 @@
+package listeners
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUnix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "webhooks.sock")
+
+	ln, err := Unix(path, 0o660)
+	if err != nil {
+		t.Fatalf("Unix failed: %v", err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o660 {
+		t.Errorf("incorrect socket permissions: got %v (err %v) want %v", info.Mode().Perm(), err, os.FileMode(0o660))
+	}
+	accepting := make(chan struct{})
+	go func(ln net.Listener) {
+		defer close(accepting)
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}(ln)
+	if _, err := Unix(path, 0o660); !errors.Is(err, ErrSocketInUse) {
+		t.Errorf("incorrect error for a socket in use: got %v want %v", err, ErrSocketInUse)
+	}
+	ln.Close()
+	<-accepting
+
+	// A socket file left behind by a crashed process is replaced.
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+	replaced, err := Unix(path, 0o600)
+	if err != nil {
+		t.Fatalf("Unix failed to replace a stale socket: %v", err)
+	}
+	replaced.Close()
+}
+
+func TestParseMode(t *testing.T) {
+	if mode, err := ParseMode("660"); err != nil || mode != 0o660 {
+		t.Errorf("incorrect mode: got %v (err %v) want %v", mode, err, os.FileMode(0o660))
+	}
+	for _, raw := range []string{"", "rw", "999", "7777"} {
+		if _, err := ParseMode(raw); err == nil {
+			t.Errorf("expected an error parsing %q", raw)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"gusto-webhook-guide/internal/apierror"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// RequireBearerToken is a middleware that only allows requests carrying
+// "Authorization: Bearer <token>". An empty token disables the protected
+// routes entirely rather than leaving them open.
+func RequireBearerToken(logger *slog.Logger, token string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if token == "" {
+				apierror.Write(w, r, http.StatusForbidden, apierror.CodeForbidden, "Admin API is disabled")
+				return
+			}
+
+			provided, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+				logger.Warn("Rejected unauthenticated admin request", "path", r.URL.Path, "remote_addr", r.RemoteAddr)
+				apierror.Write(w, r, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
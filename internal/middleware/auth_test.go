@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequireBearerToken(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+
+	testCases := []struct {
+		name               string
+		token              string // The token to initialize the middleware with.
+		authHeader         string
+		expectedStatusCode int
+	}{
+		{
+			name:               "Success - Valid Token",
+			token:              "admin-secret",
+			authHeader:         "Bearer admin-secret",
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "Failure - Wrong Token",
+			token:              "admin-secret",
+			authHeader:         "Bearer wrong",
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:               "Failure - Missing Header",
+			token:              "admin-secret",
+			authHeader:         "",
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:               "Failure - Not a Bearer Token",
+			token:              "admin-secret",
+			authHeader:         "Basic admin-secret",
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:               "Failure - Admin API Disabled",
+			token:              "",
+			authHeader:         "Bearer ",
+			expectedStatusCode: http.StatusForbidden,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			nextHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+
+			req := httptest.NewRequest("GET", "/admin/captures", nil)
+			if tc.authHeader != "" {
+				req.Header.Set("Authorization", tc.authHeader)
+			}
+			rr := httptest.NewRecorder()
+
+			RequireBearerToken(logger, tc.token)(nextHandler).ServeHTTP(rr, req)
+
+			if status := rr.Code; status != tc.expectedStatusCode {
+				t.Errorf("handler returned wrong status code: got %v want %v", status, tc.expectedStatusCode)
+			}
+		})
+	}
+}
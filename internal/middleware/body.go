@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"gusto-webhook-guide/internal/apierror"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+)
+
+// LimitBody is a middleware that only lets through JSON requests whose body
+// is at most maxBytes long. Other content types are rejected with 415 and
+// longer bodies with 413, without reading more than maxBytes of them. It
+// should run before anything that reads the body, e.g. captures.
+func LimitBody(logger *slog.Logger, maxBytes int64) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			if err != nil || mediaType != "application/json" {
+				logger.Warn("Rejecting request that isn't JSON", "content_type", r.Header.Get("Content-Type"))
+				apierror.Write(w, r, http.StatusUnsupportedMediaType, apierror.CodeUnsupportedMediaType, "Content-Type must be application/json")
+				return
+			}
+			if r.ContentLength > maxBytes {
+				logger.Warn("Rejecting oversized request", "content_length", r.ContentLength, "max_bytes", maxBytes)
+				apierror.Write(w, r, http.StatusRequestEntityTooLarge, apierror.CodeBodyTooLarge, "Request body too large")
+				return
+			}
+
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBytes))
+			r.Body.Close()
+			var tooLarge *http.MaxBytesError
+			switch {
+			case errors.As(err, &tooLarge):
+				logger.Warn("Rejecting oversized request", "max_bytes", maxBytes)
+				apierror.Write(w, r, http.StatusRequestEntityTooLarge, apierror.CodeBodyTooLarge, "Request body too large")
+				return
+			case err != nil:
+				logger.Error("Failed to read request body", "error", err)
+				apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, "Cannot read request body")
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
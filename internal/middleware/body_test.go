@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLimitBody(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+
+	testCases := []struct {
+		name               string
+		contentType        string
+		body               string
+		unknownLength      bool // Sent chunked, without a Content-Length.
+		expectedStatusCode int
+	}{
+		{name: "Success - Within Limit", contentType: "application/json", body: `{"uuid":"1"}`, expectedStatusCode: http.StatusOK},
+		{name: "Success - With Charset", contentType: "application/json; charset=utf-8", body: `{}`, expectedStatusCode: http.StatusOK},
+		{name: "Failure - Form Encoded", contentType: "application/x-www-form-urlencoded", body: `{}`, expectedStatusCode: http.StatusUnsupportedMediaType},
+		{name: "Failure - No Content Type", body: `{}`, expectedStatusCode: http.StatusUnsupportedMediaType},
+		{name: "Failure - Content-Length Too Large", contentType: "application/json", body: strings.Repeat("x", 17), expectedStatusCode: http.StatusRequestEntityTooLarge},
+		{name: "Failure - Body Too Large Without Length", contentType: "application/json", body: strings.Repeat("x", 17), unknownLength: true, expectedStatusCode: http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var received string
+			nextHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				received = string(body)
+				w.WriteHeader(http.StatusOK)
+			})
+
+			req := httptest.NewRequest("POST", "/webhooks", strings.NewReader(tc.body))
+			if tc.contentType != "" {
+				req.Header.Set("Content-Type", tc.contentType)
+			}
+			if tc.unknownLength {
+				req.ContentLength = -1
+			}
+			rr := httptest.NewRecorder()
+			LimitBody(logger, 16)(nextHandler).ServeHTTP(rr, req)
+
+			if rr.Code != tc.expectedStatusCode {
+				t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, tc.expectedStatusCode)
+			}
+			if rr.Code == http.StatusOK && received != tc.body {
+				t.Errorf("next handler got body %q want %q", received, tc.body)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package middleware
+
+import (
+	"cmp"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// BypassHeader carries a bypass token in place of a webhook signature.
+const BypassHeader = "X-Webhook-Bypass-Token"
+
+var (
+	// ErrInvalidBypassToken is returned for a bypass token that is unknown,
+	// expired, revoked or minted for another scope.
+	ErrInvalidBypassToken = errors.New("invalid bypass token")
+	// ErrInvalidBypassRequest is returned by Mint for an unknown scope or a
+	// TTL outside the allowed range.
+	ErrInvalidBypassRequest = errors.New("invalid bypass token request")
+)
+
+// BypassToken describes a minted bypass token. The token itself is only
+// returned by Mint; just its hash is kept.
+type BypassToken struct {
+	ID        string    `json:"id"`
+	Scope     string    `json:"scope"`
+	Note      string    `json:"note,omitempty"` // Who or what the token is for.
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Uses      int       `json:"uses"`
+	LastUsed  time.Time `json:"last_used,omitzero"`
+}
+
+// BypassTokens holds short-lived tokens that let trusted test traffic, e.g.
+// from QA in staging, skip webhook signature verification for one scope
+// without disabling verification for everyone else.
+type BypassTokens struct {
+	mu     sync.Mutex
+	maxTTL time.Duration
+	scopes []string
+	tokens map[string]*BypassToken // By token hash.
+	now    func() time.Time
+}
+
+// NewBypassTokens creates an empty BypassTokens whose tokens last at most
+// maxTTL and are valid for one of scopes.
+func NewBypassTokens(maxTTL time.Duration, scopes ...string) *BypassTokens {
+	return &BypassTokens{
+		maxTTL: maxTTL,
+		scopes: scopes,
+		tokens: make(map[string]*BypassToken),
+		now:    time.Now,
+	}
+}
+
+// Mint creates a token for scope that expires after ttl, returning the
+// token and its description.
+func (b *BypassTokens) Mint(scope string, ttl time.Duration, note string) (string, BypassToken, error) {
+	if !slices.Contains(b.scopes, scope) {
+		return "", BypassToken{}, fmt.Errorf("%w: unknown scope %q", ErrInvalidBypassRequest, scope)
+	}
+	if ttl <= 0 || ttl > b.maxTTL {
+		return "", BypassToken{}, fmt.Errorf("%w: ttl must be between 0 and %v", ErrInvalidBypassRequest, b.maxTTL)
+	}
+
+	raw := make([]byte, 32)
+	rand.Read(raw)
+	token := hex.EncodeToString(raw)
+	hash := hashBypassToken(token)
+	now := b.now().UTC()
+	t := &BypassToken{ID: hash[:12], Scope: scope, Note: note, CreatedAt: now, ExpiresAt: now.Add(ttl)}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.sweep(now)
+	b.tokens[hash] = t
+	return token, *t, nil
+}
+
+// Check records a use of token for scope and returns its description, or
+// ErrInvalidBypassToken if it isn't valid for scope.
+func (b *BypassTokens) Check(token, scope string) (BypassToken, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now().UTC()
+	t, found := b.tokens[hashBypassToken(token)]
+	if !found || !now.Before(t.ExpiresAt) || t.Scope != scope {
+		return BypassToken{}, ErrInvalidBypassToken
+	}
+	t.Uses++
+	t.LastUsed = now
+	return *t, nil
+}
+
+// Revoke deletes the token with the given ID and reports whether it existed.
+func (b *BypassTokens) Revoke(id string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for hash, t := range b.tokens {
+		if t.ID == id {
+			delete(b.tokens, hash)
+			return true
+		}
+	}
+	return false
+}
+
+// List returns the unexpired tokens, newest first.
+func (b *BypassTokens) List() []BypassToken {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.sweep(b.now().UTC())
+	tokens := make([]BypassToken, 0, len(b.tokens))
+	for _, t := range b.tokens {
+		tokens = append(tokens, *t)
+	}
+	slices.SortFunc(tokens, func(a, b BypassToken) int {
+		return cmp.Or(b.CreatedAt.Compare(a.CreatedAt), cmp.Compare(a.ID, b.ID))
+	})
+	return tokens
+}
+
+// sweep drops expired tokens. b.mu must be held.
+func (b *BypassTokens) sweep(now time.Time) {
+	for hash, t := range b.tokens {
+		if !now.Before(t.ExpiresAt) {
+			delete(b.tokens, hash)
+		}
+	}
+}
+
+func hashBypassToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// BypassVerifier accepts requests carrying a valid bypass token for Scope
+// in BypassHeader, and verifies all others with Next. Every use of a token
+// is logged for auditing.
+type BypassVerifier struct {
+	Next   Verifier
+	Tokens *BypassTokens
+	Scope  string
+	Logger *slog.Logger
+}
+
+// Verify implements Verifier.
+func (v BypassVerifier) Verify(r *http.Request, body []byte) error {
+	token := r.Header.Get(BypassHeader)
+	if token == "" {
+		return v.Next.Verify(r, body)
+	}
+	t, err := v.Tokens.Check(token, v.Scope)
+	if err != nil {
+		v.Logger.Warn("Rejected webhook with invalid bypass token", "scope", v.Scope, "path", r.URL.Path, "remote_addr", r.RemoteAddr)
+		return err
+	}
+	v.Logger.Info("Webhook signature check bypassed with token",
+		"bypass_token_id", t.ID,
+		"scope", t.Scope,
+		"note", t.Note,
+		"uses", t.Uses,
+		"path", r.URL.Path,
+		"remote_addr", r.RemoteAddr,
+	)
+	return nil
+}
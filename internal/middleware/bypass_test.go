@@ -0,0 +1,124 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestBypassTokensMint(t *testing.T) {
+	tokens := NewBypassTokens(time.Hour, "webhooks")
+
+	testCases := []struct {
+		name        string
+		scope       string
+		ttl         time.Duration
+		expectedErr error
+	}{
+		{name: "Valid", scope: "webhooks", ttl: time.Hour},
+		{name: "Unknown Scope", scope: "tenants", ttl: time.Minute, expectedErr: ErrInvalidBypassRequest},
+		{name: "TTL Above Maximum", scope: "webhooks", ttl: 2 * time.Hour, expectedErr: ErrInvalidBypassRequest},
+		{name: "Zero TTL", scope: "webhooks", ttl: 0, expectedErr: ErrInvalidBypassRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			token, _, err := tokens.Mint(tc.scope, tc.ttl, "qa")
+			if !errors.Is(err, tc.expectedErr) {
+				t.Fatalf("incorrect error: got %v want %v", err, tc.expectedErr)
+			}
+			if err == nil && token == "" {
+				t.Errorf("expected a token")
+			}
+		})
+	}
+}
+
+func TestBypassTokensCheck(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tokens := NewBypassTokens(time.Hour, "webhooks", "tenants")
+	tokens.now = func() time.Time { return now }
+
+	token, minted, err := tokens.Mint("webhooks", 10*time.Minute, "qa")
+	if err != nil {
+		t.Fatalf("Mint failed: %v", err)
+	}
+	if _, err := tokens.Check(token, "tenants"); !errors.Is(err, ErrInvalidBypassToken) {
+		t.Errorf("token accepted for another scope: got %v want %v", err, ErrInvalidBypassToken)
+	}
+	if _, err := tokens.Check("unknown", "webhooks"); !errors.Is(err, ErrInvalidBypassToken) {
+		t.Errorf("unknown token accepted: got %v want %v", err, ErrInvalidBypassToken)
+	}
+	used, err := tokens.Check(token, "webhooks")
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if used.Uses != 1 || !used.LastUsed.Equal(now) {
+		t.Errorf("incorrect usage: got %d at %v want 1 at %v", used.Uses, used.LastUsed, now)
+	}
+
+	now = now.Add(10 * time.Minute)
+	if _, err := tokens.Check(token, "webhooks"); !errors.Is(err, ErrInvalidBypassToken) {
+		t.Errorf("expired token accepted: got %v want %v", err, ErrInvalidBypassToken)
+	}
+	if got := tokens.List(); len(got) != 0 {
+		t.Errorf("expired token listed: got %v", got)
+	}
+	if tokens.Revoke(minted.ID) {
+		t.Errorf("expected expired token to be gone")
+	}
+}
+
+func TestBypassVerifier(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	const testPayload = `{"event":"test"}`
+	tokens := NewBypassTokens(time.Hour, "webhooks")
+	valid, _, _ := tokens.Mint("webhooks", time.Hour, "qa")
+	revoked, revokedToken, _ := tokens.Mint("webhooks", time.Hour, "old run")
+	tokens.Revoke(revokedToken.ID)
+
+	testCases := []struct {
+		name               string
+		bypassHeader       string
+		signatureHeader    string
+		expectedStatusCode int
+	}{
+		{name: "Valid Token Without Signature", bypassHeader: valid, expectedStatusCode: http.StatusOK},
+		{name: "Revoked Token", bypassHeader: revoked, expectedStatusCode: http.StatusForbidden},
+		{name: "Invalid Token With Valid Signature", bypassHeader: "wrong", signatureHeader: calculateHmac("test-secret", testPayload), expectedStatusCode: http.StatusForbidden},
+		{name: "No Token Falls Back To Signature", signatureHeader: calculateHmac("test-secret", testPayload), expectedStatusCode: http.StatusOK},
+		{name: "No Token Or Signature", expectedStatusCode: http.StatusForbidden},
+	}
+
+	verifier := BypassVerifier{
+		Next:   HMACVerifier{Header: "X-Gusto-Signature", Secret: "test-secret"},
+		Tokens: tokens,
+		Scope:  "webhooks",
+		Logger: logger,
+	}
+	handler := Verify(logger, verifier)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/webhooks", bytes.NewBufferString(testPayload))
+			if tc.bypassHeader != "" {
+				req.Header.Set(BypassHeader, tc.bypassHeader)
+			}
+			if tc.signatureHeader != "" {
+				req.Header.Set("X-Gusto-Signature", tc.signatureHeader)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			if rr.Code != tc.expectedStatusCode {
+				t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, tc.expectedStatusCode)
+			}
+		})
+	}
+}
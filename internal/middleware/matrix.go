@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+)
+
+// Middleware wraps a handler, e.g. to verify or limit requests.
+type Middleware = func(next http.Handler) http.Handler
+
+// Matrix lists, for each route group, the names of the middleware applied
+// to its routes, outermost first.
+type Matrix map[string][]string
+
+// LoadMatrix reads a Matrix from a JSON file, e.g.
+// {"webhooks": ["trace", "verify"], "metrics": ["auth"]}. Groups the file
+// doesn't mention keep their stack from def. An empty path returns def.
+func LoadMatrix(path string, def Matrix) (Matrix, error) {
+	m := make(Matrix, len(def))
+	for group, names := range def {
+		m[group] = names
+	}
+	if path == "" {
+		return m, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading middleware config: %w", err)
+	}
+	var groups Matrix
+	if err := json.Unmarshal(data, &groups); err != nil {
+		return nil, fmt.Errorf("decoding middleware config: %w", err)
+	}
+	for group, names := range groups {
+		if _, known := def[group]; !known {
+			return nil, fmt.Errorf("middleware config: unknown route group %q", group)
+		}
+		m[group] = names
+	}
+	return m, nil
+}
+
+// Stack returns the middleware for group, looked up by name in available.
+// A name mapped to nil is known but disabled, e.g. rate limiting without a
+// limit set, and is skipped. It fails if the group names unknown middleware
+// or leaves out any of required, e.g. signature verification.
+func (m Matrix) Stack(group string, available map[string]Middleware, required ...string) ([]Middleware, error) {
+	names := m[group]
+	for _, name := range required {
+		if !slices.Contains(names, name) {
+			return nil, fmt.Errorf("route group %q must use %q middleware", group, name)
+		}
+	}
+
+	var stack []Middleware
+	for _, name := range names {
+		mw, known := available[name]
+		if !known {
+			return nil, fmt.Errorf("route group %q: unknown middleware %q", group, name)
+		}
+		if mw != nil {
+			stack = append(stack, mw)
+		}
+	}
+	return stack, nil
+}
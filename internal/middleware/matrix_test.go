@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadMatrix(t *testing.T) {
+	def := Matrix{"webhooks": {"trace", "verify"}, "metrics": {}}
+	path := filepath.Join(t.TempDir(), "middleware.json")
+	os.WriteFile(path, []byte(`{"metrics": ["auth"]}`), 0o600)
+
+	m, err := LoadMatrix(path, def)
+	if err != nil {
+		t.Fatalf("LoadMatrix failed: %v", err)
+	}
+	if len(m["webhooks"]) != 2 || len(m["metrics"]) != 1 || m["metrics"][0] != "auth" {
+		t.Errorf("incorrect matrix: got %v", m)
+	}
+
+	os.WriteFile(path, []byte(`{"healthz": []}`), 0o600)
+	if _, err := LoadMatrix(path, def); err == nil {
+		t.Error("expected an error for an unknown route group")
+	}
+}
+
+func TestMatrixStack(t *testing.T) {
+	pass := func(next http.Handler) http.Handler { return next }
+	available := map[string]Middleware{"trace": pass, "ratelimit": nil, "verify": pass}
+
+	testCases := []struct {
+		name        string
+		names       []string
+		expectedLen int
+		expectErr   bool
+	}{
+		{name: "Disabled Middleware Skipped", names: []string{"trace", "ratelimit", "verify"}, expectedLen: 2},
+		{name: "Unknown Middleware", names: []string{"audit", "verify"}, expectErr: true},
+		{name: "Required Middleware Missing", names: []string{"trace"}, expectErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			stack, err := Matrix{"webhooks": tc.names}.Stack("webhooks", available, "verify")
+			if (err != nil) != tc.expectErr {
+				t.Fatalf("unexpected error result: got %v", err)
+			}
+			if len(stack) != tc.expectedLen {
+				t.Errorf("incorrect stack length: got %d want %d", len(stack), tc.expectedLen)
+			}
+		})
+	}
+}
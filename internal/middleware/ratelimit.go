@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"context"
+	"gusto-webhook-guide/internal/apierror"
+	"log/slog"
+	"net/http"
+)
+
+// Limiter decides whether another request identified by key may proceed.
+type Limiter interface {
+	Allow(ctx context.Context, key string) (bool, error)
+}
+
+// RateLimit is a middleware that rejects requests with 429 once limiter
+// reports that the budget for key is exhausted. If the limiter itself fails,
+// the request is allowed through so a limiter outage doesn't drop webhooks.
+func RateLimit(logger *slog.Logger, limiter Limiter, key string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			allowed, err := limiter.Allow(r.Context(), key)
+			if err != nil {
+				logger.Error("Rate limiter unavailable, allowing request", "key", key, "error", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+			if !allowed {
+				logger.Warn("Rate limit exceeded, rejecting request", "key", key)
+				apierror.Write(w, r, http.StatusTooManyRequests, apierror.CodeRateLimited, "Too many requests")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
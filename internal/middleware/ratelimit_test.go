@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// stubLimiter returns a fixed decision for every request.
+type stubLimiter struct {
+	allowed bool
+	err     error
+}
+
+func (s stubLimiter) Allow(context.Context, string) (bool, error) { return s.allowed, s.err }
+
+func TestRateLimit(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+
+	testCases := []struct {
+		name               string
+		limiter            stubLimiter
+		expectedStatusCode int
+	}{
+		{
+			name:               "Success - Within Limit",
+			limiter:            stubLimiter{allowed: true},
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "Failure - Limit Exceeded",
+			limiter:            stubLimiter{allowed: false},
+			expectedStatusCode: http.StatusTooManyRequests,
+		},
+		{
+			name:               "Success - Limiter Unavailable Fails Open",
+			limiter:            stubLimiter{err: errors.New("connection refused")},
+			expectedStatusCode: http.StatusOK,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			nextHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+
+			req := httptest.NewRequest("POST", "/webhooks", nil)
+			rr := httptest.NewRecorder()
+
+			RateLimit(logger, tc.limiter, "webhooks")(nextHandler).ServeHTTP(rr, req)
+
+			if status := rr.Code; status != tc.expectedStatusCode {
+				t.Errorf("handler returned wrong status code: got %v want %v", status, tc.expectedStatusCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"gusto-webhook-guide/internal/contextkeys"
+	"net/http"
+)
+
+// RequestIDHeader is the header a request ID is accepted from and echoed in.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds accepted request IDs, so a sender can't bloat
+// every log line of an event.
+const maxRequestIDLength = 128
+
+// RequestID is a middleware that gives each request a correlation ID, taken
+// from the X-Request-ID header if it holds a usable one and generated
+// otherwise. The ID is stored in the request context under
+// contextkeys.RequestIDKey, and echoed in the response header.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextkeys.RequestIDKey, id)))
+	})
+}
+
+// validRequestID reports whether id is non-empty, not too long and only
+// printable ASCII without spaces.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID returns a random 128-bit ID, hex encoded.
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
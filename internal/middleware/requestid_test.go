@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"gusto-webhook-guide/internal/contextkeys"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestID(t *testing.T) {
+	testCases := []struct {
+		name       string
+		header     string
+		expectSame bool // The header's ID is kept rather than replaced.
+	}{
+		{name: "Success - Header Kept", header: "req-123_abc.def", expectSame: true},
+		{name: "Success - Generated When Missing"},
+		{name: "Success - Generated When Too Long", header: strings.Repeat("a", maxRequestIDLength+1)},
+		{name: "Success - Generated When It Has Spaces", header: "req 123"},
+		{name: "Success - Generated When It Has Control Characters", header: "req\x00123"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var received string
+			nextHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				received, _ = r.Context().Value(contextkeys.RequestIDKey).(string)
+			})
+
+			req := httptest.NewRequest("POST", "/webhooks", nil)
+			if tc.header != "" {
+				req.Header.Set(RequestIDHeader, tc.header)
+			}
+			rr := httptest.NewRecorder()
+			RequestID(nextHandler).ServeHTTP(rr, req)
+
+			if received == "" {
+				t.Fatal("expected a request ID in the context")
+			}
+			if got := rr.Header().Get(RequestIDHeader); got != received {
+				t.Errorf("expected response header %q to match the context's %q", got, received)
+			}
+			if tc.expectSame && received != tc.header {
+				t.Errorf("expected request ID %q, got %q", tc.header, received)
+			}
+			if !tc.expectSame && received == tc.header {
+				t.Errorf("expected a generated request ID, got the header's %q", received)
+			}
+		})
+	}
+}
@@ -4,22 +4,80 @@ import (
 	"bytes"
 	"context"
 	"crypto/hmac"
-	"crypto/sha256"
-	"encoding/hex"
+	"errors"
+	"fmt"
+	"gusto-webhook-guide/internal/apierror"
 	"gusto-webhook-guide/internal/contextkeys"
+	"gusto-webhook-guide/internal/tracing"
 	"io"
 	"log/slog"
 	"net/http"
 )
 
-// VerifySignature is a middleware to validate the X-Gusto-Signature header.
-func VerifySignature(logger *slog.Logger, secret string) func(next http.Handler) http.Handler {
+var (
+	// ErrMissingSignature is returned when a request has no signature header.
+	ErrMissingSignature = errors.New("missing signature header")
+	// ErrInvalidSignature is returned when a signature doesn't match the body.
+	ErrInvalidSignature = errors.New("invalid signature")
+	// ErrNoSecret is returned by verifiers that have no secret configured yet.
+	// Verify lets such requests through so that a provider's subscription
+	// handshake can complete before the secret is known.
+	ErrNoSecret = errors.New("no signing secret configured")
+	// ErrAwaitingSecret is returned by verifiers that will only know the
+	// secret once a subscription handshake completes, for requests other
+	// than the handshake's. Verify rejects them as unauthorized.
+	ErrAwaitingSecret = errors.New("signing secret not known yet")
+)
+
+// Verifier authenticates an incoming webhook request given its raw body.
+// Each provider supplies its own implementation.
+type Verifier interface {
+	Verify(r *http.Request, body []byte) error
+}
+
+// HMACVerifier checks an HMAC of the body sent in Header, by default a
+// hex-encoded HMAC-SHA256; see SignatureScheme for the alternatives.
+type HMACVerifier struct {
+	Header    string
+	Secret    string
+	Algorithm string // Empty for "sha256".
+	Encoding  string // Empty for "hex".
+}
+
+// Verify implements Verifier.
+func (v HMACVerifier) Verify(r *http.Request, body []byte) error {
+	if v.Secret == "" {
+		return ErrNoSecret
+	}
+
+	signature := r.Header.Get(v.Header)
+	if signature == "" {
+		return fmt.Errorf("%w: %s", ErrMissingSignature, v.Header)
+	}
+
+	scheme := SignatureScheme{Header: v.Header, Algorithm: v.Algorithm, Encoding: v.Encoding}
+	if err := scheme.Validate(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	expectedSignature := scheme.Sign(v.Secret, body)
+
+	if !hmac.Equal([]byte(signature), []byte(expectedSignature)) {
+		return fmt.Errorf("%w: received %q", ErrInvalidSignature, signature)
+	}
+	return nil
+}
+
+// Verify is a middleware that authenticates webhook requests with verifier.
+// The raw body is stored in the request context for later handlers.
+func Verify(logger *slog.Logger, verifier Verifier) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			endRead := tracing.StartStage(r.Context(), "body_read")
 			bodyBytes, err := io.ReadAll(r.Body)
+			endRead()
 			if err != nil {
 				logger.Error("Failed to read request body", "error", err)
-				http.Error(w, "Cannot read request body", http.StatusInternalServerError)
+				apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Cannot read request body")
 				return
 			}
 			r.Body.Close()
@@ -29,37 +87,28 @@ func VerifySignature(logger *slog.Logger, secret string) func(next http.Handler)
 			ctx := context.WithValue(r.Context(), contextkeys.RequestBodyKey, bodyBytes)
 			r = r.WithContext(ctx) // Update the request with the new context.
 
-			// This is a special ping from Gusto during the webhook subscription process.
-			// The docs say it may contain a specific value, but in practice, it often has
-			// a signature that can't be verified yet. For setup, we need to allow this
-			// initial request through without a full HMAC check if our secret isn't set yet.
-			if secret == "" {
+			endVerify := tracing.StartStage(ctx, "verify")
+			err = verifier.Verify(r, bodyBytes)
+			endVerify()
+
+			switch {
+			case err == nil:
+				next.ServeHTTP(w, r)
+			case errors.Is(err, ErrNoSecret):
+				// Providers such as Gusto send a verification payload during
+				// subscription setup, before we know the secret. Allow it
+				// through so the handshake can complete.
 				logger.Warn("Signature verification is running with an empty secret. Allowing request for setup purposes.")
 				next.ServeHTTP(w, r)
-				return
-			}
-
-			gustoSignature := r.Header.Get("X-Gusto-Signature")
-			if gustoSignature == "" {
-				http.Error(w, "Missing X-Gusto-Signature header", http.StatusForbidden)
-				return
+			case errors.Is(err, ErrAwaitingSecret):
+				logger.Warn("Rejecting request that can't be verified until setup completes", "error", err)
+				apierror.Write(w, r, http.StatusUnauthorized, apierror.CodeUnauthorized, "Signing secret not known yet")
+			case errors.Is(err, ErrMissingSignature):
+				apierror.Write(w, r, http.StatusForbidden, apierror.CodeMissingSignature, "Missing signature header")
+			default:
+				logger.Warn("Invalid signature received", "error", err)
+				apierror.Write(w, r, http.StatusForbidden, apierror.CodeInvalidSignature, "Invalid signature")
 			}
-
-			mac := hmac.New(sha256.New, []byte(secret))
-			mac.Write(bodyBytes)
-			expectedSignature := hex.EncodeToString(mac.Sum(nil))
-
-			if !hmac.Equal([]byte(gustoSignature), []byte(expectedSignature)) {
-				logger.Warn(
-					"Invalid signature received",
-					"received_signature", gustoSignature,
-					"expected_signature", expectedSignature,
-				)
-				http.Error(w, "Invalid signature", http.StatusForbidden)
-				return
-			}
-
-			next.ServeHTTP(w, r)
 		})
 	}
-}
\ No newline at end of file
+}
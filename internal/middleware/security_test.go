@@ -5,6 +5,8 @@ import (
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
+	"gusto-webhook-guide/internal/apierror"
 	"gusto-webhook-guide/internal/contextkeys"
 	"io"
 	"log/slog"
@@ -23,6 +25,7 @@ func TestVerifySignature(t *testing.T) {
 		secret             string // The secret to initialize the middleware with.
 		signatureHeader    string // The signature to send in the request header.
 		expectedStatusCode int
+		expectedErrorCode  string // The code of the JSON error response, if any.
 		expectBodyInCtx    bool
 	}{
 		{
@@ -37,6 +40,7 @@ func TestVerifySignature(t *testing.T) {
 			secret:             "test-secret",
 			signatureHeader:    "invalid-signature",
 			expectedStatusCode: http.StatusForbidden,
+			expectedErrorCode:  apierror.CodeInvalidSignature,
 			expectBodyInCtx:    false,
 		},
 		{
@@ -44,6 +48,7 @@ func TestVerifySignature(t *testing.T) {
 			secret:             "test-secret",
 			signatureHeader:    "",
 			expectedStatusCode: http.StatusForbidden,
+			expectedErrorCode:  apierror.CodeMissingSignature,
 			expectBodyInCtx:    false,
 		},
 		{
@@ -79,13 +84,19 @@ func TestVerifySignature(t *testing.T) {
 			rr := httptest.NewRecorder()
 
 			// Create the middleware handler to test.
-			handlerToTest := VerifySignature(logger, tc.secret)(nextHandler)
+			handlerToTest := Verify(logger, HMACVerifier{Header: "X-Gusto-Signature", Secret: tc.secret})(nextHandler)
 			handlerToTest.ServeHTTP(rr, req)
 
 			// Assert the final status code.
 			if status := rr.Code; status != tc.expectedStatusCode {
 				t.Errorf("handler returned wrong status code: got %v want %v", status, tc.expectedStatusCode)
 			}
+			if tc.expectedErrorCode != "" {
+				var body apierror.Error
+				if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil || body.Code != tc.expectedErrorCode {
+					t.Errorf("incorrect error body: got %s (%v) want code %q", rr.Body.String(), err, tc.expectedErrorCode)
+				}
+			}
 		})
 	}
 }
@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// signatureAlgorithms are the hash functions an HMAC signature can use.
+var signatureAlgorithms = map[string]func() hash.Hash{
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// signatureEncodings are the ways an HMAC signature can be written in its
+// header.
+var signatureEncodings = map[string]func([]byte) string{
+	"hex":    hex.EncodeToString,
+	"base64": base64.StdEncoding.EncodeToString,
+}
+
+// SignatureScheme describes how a provider signs webhooks: an HMAC of the
+// body, keyed with the subscription's secret, sent encoded in a header.
+// Empty Algorithm and Encoding mean "sha256" and "hex".
+type SignatureScheme struct {
+	Header    string `json:"header"`
+	Algorithm string `json:"algorithm,omitempty"` // "sha1", "sha256" or "sha512".
+	Encoding  string `json:"encoding,omitempty"`  // "hex" or "base64".
+}
+
+// Validate reports whether s names a header and a supported algorithm and
+// encoding.
+func (s SignatureScheme) Validate() error {
+	if strings.TrimSpace(s.Header) == "" {
+		return errors.New("signature header is required")
+	}
+	if _, ok := signatureAlgorithms[s.algorithm()]; !ok {
+		return fmt.Errorf("unsupported signature algorithm %q, want one of %s", s.Algorithm, strings.Join(slices.Sorted(maps.Keys(signatureAlgorithms)), ", "))
+	}
+	if _, ok := signatureEncodings[s.encoding()]; !ok {
+		return fmt.Errorf("unsupported signature encoding %q, want one of %s", s.Encoding, strings.Join(slices.Sorted(maps.Keys(signatureEncodings)), ", "))
+	}
+	return nil
+}
+
+// Sign returns the signature of body with secret, as it is sent in Header.
+// It returns "" if s isn't valid.
+func (s SignatureScheme) Sign(secret string, body []byte) string {
+	newHash, encode := signatureAlgorithms[s.algorithm()], signatureEncodings[s.encoding()]
+	if newHash == nil || encode == nil {
+		return ""
+	}
+	mac := hmac.New(newHash, []byte(secret))
+	mac.Write(body)
+	return encode(mac.Sum(nil))
+}
+
+// Verifier returns a Verifier checking signatures made this way with secret.
+func (s SignatureScheme) Verifier(secret string) Verifier {
+	return HMACVerifier{Header: s.Header, Secret: secret, Algorithm: s.Algorithm, Encoding: s.Encoding}
+}
+
+func (s SignatureScheme) algorithm() string {
+	if s.Algorithm == "" {
+		return "sha256"
+	}
+	return strings.ToLower(s.Algorithm)
+}
+
+func (s SignatureScheme) encoding() string {
+	if s.Encoding == "" {
+		return "hex"
+	}
+	return strings.ToLower(s.Encoding)
+}
@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSignatureScheme(t *testing.T) {
+	const secret = "test-secret"
+	body := []byte(`{"event":"test"}`)
+	sha1MAC := hmac.New(sha1.New, []byte(secret))
+	sha1MAC.Write(body)
+	sha512MAC := hmac.New(sha512.New, []byte(secret))
+	sha512MAC.Write(body)
+
+	testCases := []struct {
+		name              string
+		scheme            SignatureScheme
+		expectedSignature string
+		expectedValid     bool
+	}{
+		{
+			name:              "Default Is Hex SHA-256",
+			scheme:            SignatureScheme{Header: "X-Gusto-Signature"},
+			expectedSignature: calculateHmac(secret, string(body)),
+			expectedValid:     true,
+		},
+		{
+			name:              "SHA-1 Hex",
+			scheme:            SignatureScheme{Header: "X-Signature", Algorithm: "sha1", Encoding: "hex"},
+			expectedSignature: hex.EncodeToString(sha1MAC.Sum(nil)),
+			expectedValid:     true,
+		},
+		{
+			name:              "SHA-512 Base64",
+			scheme:            SignatureScheme{Header: "X-Signature", Algorithm: "SHA512", Encoding: "base64"},
+			expectedSignature: base64.StdEncoding.EncodeToString(sha512MAC.Sum(nil)),
+			expectedValid:     true,
+		},
+		{name: "Missing Header", scheme: SignatureScheme{}},
+		{name: "Unsupported Algorithm", scheme: SignatureScheme{Header: "X-Signature", Algorithm: "md5"}},
+		{name: "Unsupported Encoding", scheme: SignatureScheme{Header: "X-Signature", Encoding: "base32"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.scheme.Validate()
+			if (err == nil) != tc.expectedValid {
+				t.Fatalf("incorrect Validate result: got %v, want valid %v", err, tc.expectedValid)
+			}
+			if !tc.expectedValid {
+				return
+			}
+			signature := tc.scheme.Sign(secret, body)
+			if signature != tc.expectedSignature {
+				t.Errorf("incorrect signature: got %q want %q", signature, tc.expectedSignature)
+			}
+
+			req := httptest.NewRequest("POST", "/webhooks", bytes.NewReader(body))
+			req.Header.Set(tc.scheme.Header, signature)
+			if err := tc.scheme.Verifier(secret).Verify(req, body); err != nil {
+				t.Errorf("Verify rejected a correct signature: %v", err)
+			}
+			req.Header.Set(tc.scheme.Header, tc.scheme.Sign("other-secret", body))
+			if err := tc.scheme.Verifier(secret).Verify(req, body); !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("expected ErrInvalidSignature for another secret's signature, got %v", err)
+			}
+		})
+	}
+}
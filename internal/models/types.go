@@ -1,6 +1,10 @@
 package models
 
-import "encoding/json"
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
 
 // WebhookEvent represents the structure of an incoming webhook from Gusto.
 type WebhookEvent struct {
@@ -13,8 +17,37 @@ type WebhookEvent struct {
 	Payload      json.RawMessage `json:"payload"`
 }
 
+// Priority orders jobs waiting in the in-memory queue; higher-priority jobs
+// are processed first. The zero value is PriorityNormal.
+type Priority int
+
+const (
+	PriorityLow    Priority = -1
+	PriorityNormal Priority = 0
+	PriorityHigh   Priority = 1
+)
+
 // Job wraps the raw event payload and includes a retry counter.
 type Job struct {
 	Payload  []byte
 	Attempts int
-}
\ No newline at end of file
+	Priority Priority
+	// ReceivedAt is when the webhook was first received. It is kept across
+	// retries, and is zero for jobs whose queue didn't record it.
+	ReceivedAt time.Time
+	// RequestID correlates the job with the HTTP request that delivered it,
+	// in logs from receipt through every retry. It is empty for jobs that
+	// didn't arrive by webhook or whose queue didn't record it.
+	RequestID string
+	// Ctx carries request-scoped values (e.g. tracing) from the HTTP handler
+	// into the worker. It must not be tied to the request's cancellation.
+	Ctx context.Context
+}
+
+// Context returns the job's context, or context.Background if none was set.
+func (j Job) Context() context.Context {
+	if j.Ctx == nil {
+		return context.Background()
+	}
+	return j.Ctx
+}
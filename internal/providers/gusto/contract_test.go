@@ -0,0 +1,205 @@
+//go:build integration
+
+package gusto
+
+// Contract tests against the real Gusto demo API. They check that the
+// response shapes our code decodes still match what Gusto sends, so a change
+// on Gusto's side fails here rather than in production. Run them with:
+//
+//	GUSTO_API_TOKEN=... go test -tags integration -run Contract -v ./internal/providers/gusto/
+//
+// Company checks also need GUSTO_CONTRACT_COMPANY_UUID and
+// GUSTO_CONTRACT_COMPANY_TOKEN. Setting GUSTO_CONTRACT_SUBSCRIPTION_UUID
+// additionally asks Gusto to resend that subscription's verification payload.
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+)
+
+var contractClient = &http.Client{Timeout: 30 * time.Second}
+
+// contractEnv returns the named environment variable, or skips the test.
+func contractEnv(t *testing.T, name string) string {
+	t.Helper()
+	value := os.Getenv(name)
+	if value == "" {
+		t.Skipf("%s not set; skipping contract test", name)
+	}
+	return value
+}
+
+// contractRequest calls the Gusto demo API and returns the status and body.
+func contractRequest(t *testing.T, method, path, token string, body any) (int, []byte) {
+	t.Helper()
+	var reqBody io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("failed to encode request body: %v", err)
+		}
+		reqBody = bytes.NewReader(encoded)
+	}
+	req, err := http.NewRequestWithContext(t.Context(), method, DefaultBaseURL+path, reqBody)
+	if err != nil {
+		t.Fatalf("failed to build request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := contractClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s failed: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("failed to read response body: %v", err)
+	}
+	return resp.StatusCode, respBody
+}
+
+// requireShape fails unless obj has every field in fields with the given
+// JSON kind: "string", "number", "bool", "array" or "object".
+func requireShape(t *testing.T, what string, obj map[string]any, fields map[string]string) {
+	t.Helper()
+	for name, kind := range fields {
+		value, found := obj[name]
+		if !found {
+			t.Errorf("%s: missing field %q in %v", what, name, obj)
+			continue
+		}
+		if got := jsonKind(value); got != kind {
+			t.Errorf("%s: field %q is %s, want %s", what, name, got, kind)
+		}
+	}
+}
+
+func jsonKind(value any) string {
+	switch value.(type) {
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "bool"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	case nil:
+		return "null"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// subscriptionShape is the subset of a webhook subscription we depend on;
+// setup.Handler reads the uuid of the one it creates.
+var subscriptionShape = map[string]string{
+	"uuid":               "string",
+	"url":                "string",
+	"status":             "string",
+	"subscription_types": "array",
+}
+
+func TestContractWebhookSubscriptions(t *testing.T) {
+	token := contractEnv(t, "GUSTO_API_TOKEN")
+
+	status, body := contractRequest(t, "GET", "/v1/webhook_subscriptions", token, nil)
+	if status != http.StatusOK {
+		t.Fatalf("incorrect status listing subscriptions: got %d want %d (body %s)", status, http.StatusOK, body)
+	}
+	var subscriptions []map[string]any
+	if err := json.Unmarshal(body, &subscriptions); err != nil {
+		t.Fatalf("subscriptions response is no longer a JSON array: %v (body %s)", err, body)
+	}
+	for _, sub := range subscriptions {
+		requireShape(t, "webhook subscription", sub, subscriptionShape)
+	}
+}
+
+func TestContractCreateSubscriptionValidation(t *testing.T) {
+	token := contractEnv(t, "GUSTO_API_TOKEN")
+
+	// An invalid URL must be rejected with the error shape Processor parses,
+	// without creating anything.
+	status, body := contractRequest(t, "POST", "/v1/webhook_subscriptions", token, map[string]any{
+		"url":                "not a url",
+		"subscription_types": []string{"Company"},
+	})
+	if status < 400 || status >= 500 {
+		t.Fatalf("expected a 4xx for an invalid subscription, got %d (body %s)", status, body)
+	}
+	requireAPIError(t, body)
+}
+
+func TestContractRequestVerificationToken(t *testing.T) {
+	token := contractEnv(t, "GUSTO_API_TOKEN")
+	subscriptionUUID := contractEnv(t, "GUSTO_CONTRACT_SUBSCRIPTION_UUID")
+
+	// Gusto resends the verification payload handled by VerificationHandler
+	// to the subscription's URL; check that Gusto accepts the request.
+	status, body := contractRequest(t, "GET", "/v1/webhook_subscriptions/"+subscriptionUUID+"/request_verification_token", token, nil)
+	if status != http.StatusOK {
+		t.Fatalf("incorrect status requesting verification token: got %d want %d (body %s)", status, http.StatusOK, body)
+	}
+}
+
+func TestContractFetchCompany(t *testing.T) {
+	companyUUID := contractEnv(t, "GUSTO_CONTRACT_COMPANY_UUID")
+	token := contractEnv(t, "GUSTO_CONTRACT_COMPANY_TOKEN")
+
+	status, body := contractRequest(t, "GET", "/v1/companies/"+companyUUID, token, nil)
+	if status != http.StatusOK {
+		t.Fatalf("incorrect status fetching company: got %d want %d (body %s)", status, http.StatusOK, body)
+	}
+	var company map[string]any
+	if err := json.Unmarshal(body, &company); err != nil {
+		t.Fatalf("company response is not a JSON object: %v (body %s)", err, body)
+	}
+	requireShape(t, "company", company, map[string]string{
+		"uuid": "string",
+		"name": "string",
+	})
+	if company["uuid"] != companyUUID {
+		t.Errorf("incorrect company uuid: got %v want %v", company["uuid"], companyUUID)
+	}
+}
+
+func TestContractCompanyNotFound(t *testing.T) {
+	token := contractEnv(t, "GUSTO_CONTRACT_COMPANY_TOKEN")
+
+	// Processor classifies failures by the category of the first error.
+	status, body := contractRequest(t, "GET", "/v1/companies/00000000-0000-0000-0000-000000000000", token, nil)
+	if status < 400 {
+		t.Fatalf("expected an error fetching an unknown company, got %d (body %s)", status, body)
+	}
+	requireAPIError(t, body)
+}
+
+// requireAPIError fails unless body decodes into a non-empty APIErrorResponse
+// whose errors carry a category and a message.
+func requireAPIError(t *testing.T, body []byte) {
+	t.Helper()
+	var apiErr APIErrorResponse
+	if err := json.Unmarshal(body, &apiErr); err != nil {
+		t.Fatalf("error response no longer matches APIErrorResponse: %v (body %s)", err, body)
+	}
+	if len(apiErr.Errors) == 0 {
+		t.Fatalf("error response has no errors array entries: %s", body)
+	}
+	for _, e := range apiErr.Errors {
+		if e.Category == "" || e.Message == "" {
+			t.Errorf("error entry missing category or message: %+v (body %s)", e, body)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package gusto
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gusto-webhook-guide/internal/models"
+	"gusto-webhook-guide/internal/worker"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultEventsPageSize is how many events EventPoller asks for per page.
+const defaultEventsPageSize = 100
+
+// ErrInvalidEventsPage is returned for a page of events that can't be read.
+var ErrInvalidEventsPage = errors.New("invalid events page")
+
+// EventPoller pages through Gusto's events API and queues each event as a
+// job, exactly as if it had been delivered by webhook, for deployments
+// whose network can't accept inbound webhooks. It remembers the last event
+// queued and asks only for later ones; events queued twice, e.g. after a
+// restart without CursorPath, are dropped as duplicates by the idempotency
+// store.
+type EventPoller struct {
+	Logger      *slog.Logger
+	BaseURL     string
+	AccessToken string
+	Client      *http.Client
+	Queue       worker.JobQueue
+	PageSize    int
+	// CursorPath, if set, is a file the UUID of the last event queued is
+	// kept in, so polling resumes there after a restart. Without it, the
+	// first poll starts from the oldest event Gusto still lists.
+	CursorPath string
+
+	mu     sync.Mutex // Serializes polls.
+	cursor string
+	loaded bool
+}
+
+// NewEventPoller creates an EventPoller for the Gusto demo API that queues
+// events on queue.
+func NewEventPoller(logger *slog.Logger, accessToken string, queue worker.JobQueue) *EventPoller {
+	return &EventPoller{
+		Logger:      logger,
+		BaseURL:     DefaultBaseURL,
+		AccessToken: accessToken,
+		Client:      &http.Client{Timeout: 15 * time.Second},
+		Queue:       queue,
+		PageSize:    defaultEventsPageSize,
+	}
+}
+
+// Poll queues every event published since the last one queued, a page at a
+// time, and returns how many it queued. If the queue fills up it stops and
+// returns the error; the next poll carries on from the last event queued.
+func (p *EventPoller) Poll(ctx context.Context) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.loaded && p.CursorPath != "" {
+		data, err := os.ReadFile(p.CursorPath)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return 0, fmt.Errorf("reading events cursor: %w", err)
+		}
+		p.cursor = strings.TrimSpace(string(data))
+	}
+	p.loaded = true
+
+	queued := 0
+	for {
+		events, err := p.fetch(ctx)
+		if err != nil {
+			return queued, err
+		}
+		for _, raw := range events {
+			var envelope struct {
+				UUID string `json:"uuid"`
+			}
+			if err := json.Unmarshal(raw, &envelope); err != nil || envelope.UUID == "" {
+				return queued, fmt.Errorf("%w: event without a uuid after %q", ErrInvalidEventsPage, p.cursor)
+			}
+			job := models.Job{Payload: raw, ReceivedAt: time.Now().UTC()}
+			if err := p.Queue.Enqueue(ctx, job, 0); err != nil {
+				return queued, fmt.Errorf("queueing event %s: %w", envelope.UUID, err)
+			}
+			eventsPolled.Inc()
+			queued++
+			if err := p.advance(envelope.UUID); err != nil {
+				return queued, err
+			}
+		}
+		if len(events) < p.PageSize {
+			return queued, nil
+		}
+	}
+}
+
+// Cursor returns the UUID of the last event queued, or "" before any was.
+func (p *EventPoller) Cursor() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.cursor
+}
+
+// fetch returns the page of events after the cursor, oldest first.
+func (p *EventPoller) fetch(ctx context.Context) ([]json.RawMessage, error) {
+	query := url.Values{
+		"limit":      {strconv.Itoa(p.PageSize)},
+		"sort_order": {"asc"},
+	}
+	if p.cursor != "" {
+		query.Set("starting_after_uuid", p.cursor)
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", p.BaseURL+"/v1/events?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+p.AccessToken)
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("listing events: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("listing events: reading response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("listing events: Gusto API returned %d: %s", resp.StatusCode, body)
+	}
+	var events []json.RawMessage
+	if err := json.Unmarshal(body, &events); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidEventsPage, err)
+	}
+	return events, nil
+}
+
+// advance moves the cursor past eventUUID, saving it to CursorPath if set.
+func (p *EventPoller) advance(eventUUID string) error {
+	p.cursor = eventUUID
+	if p.CursorPath == "" {
+		return nil
+	}
+	tmp := p.CursorPath + ".tmp"
+	if err := os.WriteFile(tmp, []byte(eventUUID+"\n"), 0o600); err != nil {
+		return fmt.Errorf("saving events cursor: %w", err)
+	}
+	if err := os.Rename(tmp, p.CursorPath); err != nil {
+		return fmt.Errorf("saving events cursor: %w", err)
+	}
+	return nil
+}
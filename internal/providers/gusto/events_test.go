@@ -0,0 +1,101 @@
+package gusto
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"gusto-webhook-guide/internal/models"
+	"gusto-webhook-guide/internal/worker"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+// eventsAPI serves uuids as Gusto's events API does, paging with limit and
+// starting_after_uuid.
+func eventsAPI(t *testing.T, uuids []string) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/events" || r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, "unexpected request", http.StatusUnauthorized)
+			return
+		}
+		start := 0
+		if after := r.URL.Query().Get("starting_after_uuid"); after != "" {
+			start = slices.Index(uuids, after) + 1
+		}
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		end := min(start+limit, len(uuids))
+		page := []map[string]string{}
+		for _, uuid := range uuids[start:end] {
+			page = append(page, map[string]string{"uuid": uuid, "event_type": "company.updated"})
+		}
+		json.NewEncoder(w).Encode(page)
+	}))
+}
+
+func TestEventPollerPoll(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	uuids := []string{"1", "2", "3", "4", "5"}
+	server := eventsAPI(t, uuids)
+	defer server.Close()
+
+	jobQueue := make(chan models.Job, 10)
+	poller := NewEventPoller(logger, "token", worker.ChannelQueue(jobQueue))
+	poller.BaseURL = server.URL
+	poller.PageSize = 2
+	poller.CursorPath = filepath.Join(t.TempDir(), "cursor")
+
+	queued, err := poller.Poll(context.Background())
+	if err != nil || queued != 5 {
+		t.Fatalf("first poll: got %d queued, error %v; want 5", queued, err)
+	}
+	for _, uuid := range uuids {
+		var event models.WebhookEvent
+		json.Unmarshal((<-jobQueue).Payload, &event)
+		if event.UUID != uuid || event.EventType != "company.updated" {
+			t.Errorf("incorrect job: got %+v want event %s", event, uuid)
+		}
+	}
+
+	// A new poller resumes from the saved cursor.
+	resumed := NewEventPoller(logger, "token", worker.ChannelQueue(jobQueue))
+	resumed.BaseURL = server.URL
+	resumed.CursorPath = poller.CursorPath
+	if queued, err := resumed.Poll(context.Background()); err != nil || queued != 0 {
+		t.Errorf("poll after restart: got %d queued, error %v; want 0", queued, err)
+	}
+	if resumed.Cursor() != "5" {
+		t.Errorf("incorrect cursor: got %q want %q", resumed.Cursor(), "5")
+	}
+}
+
+func TestEventPollerStopsWhenQueueIsFull(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	server := eventsAPI(t, []string{"1", "2", "3"})
+	defer server.Close()
+
+	jobQueue := make(chan models.Job, 2)
+	poller := NewEventPoller(logger, "token", worker.ChannelQueue(jobQueue))
+	poller.BaseURL = server.URL
+
+	queued, err := poller.Poll(context.Background())
+	if !errors.Is(err, worker.ErrQueueFull) || queued != 2 {
+		t.Fatalf("got %d queued, error %v; want 2 and %v", queued, err, worker.ErrQueueFull)
+	}
+	<-jobQueue
+	<-jobQueue
+	if queued, err := poller.Poll(context.Background()); err != nil || queued != 1 {
+		t.Errorf("next poll: got %d queued, error %v; want the remaining event", queued, err)
+	}
+	var event models.WebhookEvent
+	json.Unmarshal((<-jobQueue).Payload, &event)
+	if event.UUID != "3" {
+		t.Errorf("incorrect event queued: got %s want 3", event.UUID)
+	}
+}
@@ -0,0 +1,134 @@
+package gusto
+
+import (
+	"cmp"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// healthSamples is how many recent latencies are kept per endpoint for the
+// percentiles in the summary.
+const healthSamples = 200
+
+// Observation is one Gusto API request recorded by Transport.
+type Observation struct {
+	Endpoint           string
+	StatusCode         int // Zero if no response was received.
+	Duration           time.Duration
+	Retry              bool // Made while retrying an event.
+	RateLimitRemaining int  // -1 if not reported.
+	At                 time.Time
+}
+
+// EndpointHealth summarizes the requests to one endpoint since startup.
+type EndpointHealth struct {
+	Endpoint           string         `json:"endpoint"`
+	Requests           int            `json:"requests"`
+	Errors             int            `json:"errors"`   // Requests with no response, e.g. timeouts.
+	Statuses           map[string]int `json:"statuses"` // Requests by status class, e.g. "2xx".
+	Retries            int            `json:"retries"`
+	LatencyP50Ms       float64        `json:"latency_p50_ms"` // Latencies are over the most recent requests.
+	LatencyP95Ms       float64        `json:"latency_p95_ms"`
+	LatencyMaxMs       float64        `json:"latency_max_ms"`
+	RateLimitRemaining *int           `json:"rate_limit_remaining,omitempty"`
+	LastRequest        time.Time      `json:"last_request"`
+}
+
+// Health keeps a per-endpoint summary of Gusto API requests, served at
+// /admin/gusto-health.
+type Health struct {
+	mu        sync.Mutex
+	endpoints map[string]*endpointStats
+}
+
+type endpointStats struct {
+	summary   EndpointHealth
+	latencies []time.Duration // Ring of the most recent healthSamples latencies.
+	next      int
+}
+
+// NewHealth creates an empty Health.
+func NewHealth() *Health {
+	return &Health{endpoints: make(map[string]*endpointStats)}
+}
+
+// Observe records a request.
+func (h *Health) Observe(obs Observation) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, found := h.endpoints[obs.Endpoint]
+	if !found {
+		s = &endpointStats{summary: EndpointHealth{Endpoint: obs.Endpoint, Statuses: make(map[string]int)}}
+		h.endpoints[obs.Endpoint] = s
+	}
+
+	s.summary.Requests++
+	if obs.StatusCode == 0 {
+		s.summary.Errors++
+	} else {
+		s.summary.Statuses[strconv.Itoa(obs.StatusCode/100)+"xx"]++
+	}
+	if obs.Retry {
+		s.summary.Retries++
+	}
+	if obs.RateLimitRemaining >= 0 {
+		remaining := obs.RateLimitRemaining
+		s.summary.RateLimitRemaining = &remaining
+	}
+	s.summary.LastRequest = obs.At
+
+	if len(s.latencies) < healthSamples {
+		s.latencies = append(s.latencies, obs.Duration)
+	} else {
+		s.latencies[s.next] = obs.Duration
+		s.next = (s.next + 1) % healthSamples
+	}
+}
+
+// Summary returns the summary of every endpoint, busiest first.
+func (h *Health) Summary() []EndpointHealth {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	summaries := make([]EndpointHealth, 0, len(h.endpoints))
+	for _, s := range h.endpoints {
+		summary := s.summary
+		summary.Statuses = make(map[string]int, len(s.summary.Statuses))
+		for class, n := range s.summary.Statuses {
+			summary.Statuses[class] = n
+		}
+		sorted := slices.Clone(s.latencies)
+		slices.Sort(sorted)
+		summary.LatencyP50Ms = milliseconds(percentile(sorted, 50))
+		summary.LatencyP95Ms = milliseconds(percentile(sorted, 95))
+		summary.LatencyMaxMs = milliseconds(percentile(sorted, 100))
+		summaries = append(summaries, summary)
+	}
+	slices.SortFunc(summaries, func(a, b EndpointHealth) int {
+		return cmp.Or(cmp.Compare(b.Requests, a.Requests), cmp.Compare(a.Endpoint, b.Endpoint))
+	})
+	return summaries
+}
+
+// percentile returns the pth percentile of sorted latencies, by the
+// nearest-rank method.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := (p*len(sorted) + 99) / 100
+	return sorted[max(rank, 1)-1]
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// HandleSummary serves the per-endpoint summary as JSON.
+func (h *Health) HandleSummary(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"endpoints": h.Summary()})
+}
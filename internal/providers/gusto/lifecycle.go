@@ -0,0 +1,46 @@
+package gusto
+
+import (
+	"cmp"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// subscriptionEventPrefix starts the event type of notifications about the
+// webhook subscription itself, rather than about payroll data.
+const subscriptionEventPrefix = "webhook_subscription."
+
+// SubscriptionEvent is a notification that a webhook subscription changed,
+// e.g. "webhook_subscription.removed", "webhook_subscription.disabled" or
+// "webhook_subscription.verification_reset".
+type SubscriptionEvent struct {
+	EventType        string
+	SubscriptionUUID string // Empty if the payload didn't say.
+}
+
+// SubscriptionLifecycleHandler returns a control handler for notifications
+// about the webhook subscription itself. They mean deliveries may stop, so
+// rather than being queued as data events they are acknowledged, logged as
+// errors to alert on, counted, and passed to onEvent if it is set.
+func SubscriptionLifecycleHandler(logger *slog.Logger, onEvent func(SubscriptionEvent)) func(w http.ResponseWriter, payload map[string]any) bool {
+	return func(w http.ResponseWriter, payload map[string]any) bool {
+		eventType, _ := payload["event_type"].(string)
+		if !strings.HasPrefix(eventType, subscriptionEventPrefix) {
+			return false
+		}
+
+		subscriptionUUID, _ := payload["webhook_subscription_uuid"].(string)
+		entityUUID, _ := payload["entity_uuid"].(string)
+		event := SubscriptionEvent{EventType: eventType, SubscriptionUUID: cmp.Or(subscriptionUUID, entityUUID)}
+		subscriptionEvents.WithLabelValues(eventType).Inc()
+		logger.Error("Gusto changed the webhook subscription; deliveries may stop",
+			"event_type", eventType, "webhook_subscription_uuid", event.SubscriptionUUID)
+		if onEvent != nil {
+			onEvent(event)
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("Subscription notification acknowledged.\n"))
+		return true
+	}
+}
@@ -0,0 +1,34 @@
+package gusto
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSubscriptionLifecycleHandler(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	var got []SubscriptionEvent
+	handle := SubscriptionLifecycleHandler(logger, func(event SubscriptionEvent) {
+		got = append(got, event)
+	})
+
+	rr := httptest.NewRecorder()
+	if handle(rr, map[string]any{"event_type": "payroll.submitted", "entity_uuid": "p1"}) {
+		t.Fatalf("Expected data event not to be handled")
+	}
+
+	rr = httptest.NewRecorder()
+	if !handle(rr, map[string]any{"event_type": "webhook_subscription.removed", "entity_uuid": "s1"}) {
+		t.Fatalf("Expected subscription notification to be handled")
+	}
+	if rr.Code != http.StatusOK {
+		t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	want := SubscriptionEvent{EventType: "webhook_subscription.removed", SubscriptionUUID: "s1"}
+	if len(got) != 1 || got[0] != want {
+		t.Errorf("incorrect events passed on: got %+v want [%+v]", got, want)
+	}
+}
@@ -0,0 +1,34 @@
+package gusto
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	apiRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "gusto_api_request_duration_seconds",
+		Help:    "Latency of Gusto API requests, by endpoint and status code (\"error\" if no response was received).",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"endpoint", "status"})
+
+	apiRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gusto_api_retries_total",
+		Help: "Gusto API requests made while retrying an event, by endpoint.",
+	}, []string{"endpoint"})
+
+	apiRateLimitRemaining = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "gusto_api_rate_limit_remaining",
+		Help: "Requests remaining in the current rate-limit window, as last reported by Gusto, by endpoint.",
+	}, []string{"endpoint"})
+
+	eventsPolled = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "gusto_events_polled_total",
+		Help: "Events queued from Gusto's events API by the event poller.",
+	})
+
+	subscriptionEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gusto_subscription_events_total",
+		Help: "Notifications about the webhook subscription itself, e.g. its removal, by event type.",
+	}, []string{"event_type"})
+)
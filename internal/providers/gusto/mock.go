@@ -0,0 +1,182 @@
+package gusto
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"gusto-webhook-guide/internal/middleware"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// MockAPI is an in-memory stand-in for the parts of the Gusto API this
+// service calls, for developing without Gusto credentials or network access.
+// Any company can be fetched, and webhook subscriptions can be created,
+// listed, updated and verified. The events list is always empty. As with Gusto, creating a subscription sends
+// its verification payload to the subscription's URL, signed with
+// VerificationToken as Signature says, and verifying it takes the same token.
+type MockAPI struct {
+	Logger            *slog.Logger
+	VerificationToken string
+	Signature         middleware.SignatureScheme // DefaultSignature if zero.
+	HTTP              *http.Client               // Sends verification payloads; defaults to a client with a 10 second timeout.
+
+	mu   sync.Mutex
+	subs []mockSubscription
+}
+
+// mockSubscription is a webhook subscription as the API returns it.
+type mockSubscription struct {
+	UUID              string   `json:"uuid"`
+	URL               string   `json:"url"`
+	Status            string   `json:"status"`
+	SubscriptionTypes []string `json:"subscription_types"`
+}
+
+// Handler returns the API's routes.
+func (m *MockAPI) Handler() http.Handler {
+	r := chi.NewRouter()
+	r.Get("/v1/companies/{uuid}", m.getCompany)
+	r.Get("/v1/events", m.listEvents)
+	r.Get("/v1/webhook_subscriptions", m.listSubscriptions)
+	r.Post("/v1/webhook_subscriptions", m.createSubscription)
+	r.Put("/v1/webhook_subscriptions/{uuid}", m.updateSubscription)
+	r.Put("/v1/webhook_subscriptions/{uuid}/verify", m.verifySubscription)
+	return r
+}
+
+func (m *MockAPI) getCompany(w http.ResponseWriter, r *http.Request) {
+	writeMockJSON(w, http.StatusOK, map[string]string{
+		"uuid":        chi.URLParam(r, "uuid"),
+		"name":        "Mock Company",
+		"trade_name":  "Mock Co",
+		"entity_type": "LLC",
+	})
+}
+
+func (m *MockAPI) listEvents(w http.ResponseWriter, _ *http.Request) {
+	writeMockJSON(w, http.StatusOK, []any{})
+}
+
+func (m *MockAPI) listSubscriptions(w http.ResponseWriter, _ *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	writeMockJSON(w, http.StatusOK, append([]mockSubscription{}, m.subs...))
+}
+
+func (m *MockAPI) createSubscription(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		URL               string   `json:"url"`
+		SubscriptionTypes []string `json:"subscription_types"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.URL == "" {
+		writeMockError(w, http.StatusUnprocessableEntity, "a url is required")
+		return
+	}
+	m.mu.Lock()
+	sub := mockSubscription{
+		UUID:              fmt.Sprintf("mock-subscription-%d", len(m.subs)+1),
+		URL:               req.URL,
+		Status:            "unverified",
+		SubscriptionTypes: req.SubscriptionTypes,
+	}
+	m.subs = append(m.subs, sub)
+	m.mu.Unlock()
+
+	// Gusto sends the verification payload once the subscription exists.
+	go m.sendVerification(sub)
+	writeMockJSON(w, http.StatusCreated, sub)
+}
+
+func (m *MockAPI) updateSubscription(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		SubscriptionTypes []string `json:"subscription_types"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeMockError(w, http.StatusUnprocessableEntity, "invalid request body")
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := m.find(chi.URLParam(r, "uuid"))
+	if i < 0 {
+		writeMockError(w, http.StatusNotFound, "webhook subscription not found")
+		return
+	}
+	m.subs[i].SubscriptionTypes = req.SubscriptionTypes
+	writeMockJSON(w, http.StatusOK, m.subs[i])
+}
+
+func (m *MockAPI) verifySubscription(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		VerificationToken string `json:"verification_token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.VerificationToken != m.VerificationToken {
+		writeMockError(w, http.StatusUnprocessableEntity, "invalid verification token")
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := m.find(chi.URLParam(r, "uuid"))
+	if i < 0 {
+		writeMockError(w, http.StatusNotFound, "webhook subscription not found")
+		return
+	}
+	m.subs[i].Status = "verified"
+	writeMockJSON(w, http.StatusOK, m.subs[i])
+}
+
+// find returns the index of the subscription with the given UUID, or -1.
+// m.mu must be held.
+func (m *MockAPI) find(uuid string) int {
+	return slices.IndexFunc(m.subs, func(s mockSubscription) bool { return s.UUID == uuid })
+}
+
+// sendVerification posts sub's verification payload to its URL.
+func (m *MockAPI) sendVerification(sub mockSubscription) {
+	body, _ := json.Marshal(map[string]string{
+		"verification_token":        m.VerificationToken,
+		"webhook_subscription_uuid": sub.UUID,
+	})
+	req, err := http.NewRequest(http.MethodPost, sub.URL, bytes.NewReader(body))
+	if err != nil {
+		m.Logger.Error("Mock Gusto API could not send verification payload", "url", sub.URL, "error", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	signature := cmp.Or(m.Signature, DefaultSignature)
+	req.Header.Set(signature.Header, signature.Sign(m.VerificationToken, body))
+
+	client := m.HTTP
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		m.Logger.Error("Mock Gusto API could not send verification payload", "url", sub.URL, "error", err)
+		return
+	}
+	resp.Body.Close()
+	m.Logger.Info("Mock Gusto API sent verification payload", "url", sub.URL, "status", resp.StatusCode)
+}
+
+func writeMockJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeMockError(w http.ResponseWriter, status int, message string) {
+	var resp APIErrorResponse
+	resp.Errors = append(resp.Errors, struct {
+		Category string `json:"category"`
+		Message  string `json:"message"`
+	}{Category: "invalid_request", Message: message})
+	writeMockJSON(w, status, resp)
+}
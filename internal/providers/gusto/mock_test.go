@@ -0,0 +1,80 @@
+package gusto
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMockAPI(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+
+	// The webhook endpoint the mock delivers the verification payload to.
+	received := make(chan map[string]string, 1)
+	webhooks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := NewVerifier("secret").Verify(r, body); err != nil {
+			t.Errorf("verification payload failed signature verification: %v", err)
+		}
+		var payload map[string]string
+		json.Unmarshal(body, &payload)
+		received <- payload
+	}))
+	defer webhooks.Close()
+
+	mock := &MockAPI{Logger: logger, VerificationToken: "secret"}
+	api := httptest.NewServer(mock.Handler())
+	defer api.Close()
+
+	do := func(method, path, body string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequestWithContext(context.Background(), method, api.URL+path, strings.NewReader(body))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+		return resp
+	}
+
+	resp := do("POST", "/v1/webhook_subscriptions", `{"url":"`+webhooks.URL+`","subscription_types":["Company"]}`)
+	var sub mockSubscription
+	json.NewDecoder(resp.Body).Decode(&sub)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated || sub.UUID == "" || sub.Status != "unverified" {
+		t.Fatalf("unexpected creation response: %d %+v", resp.StatusCode, sub)
+	}
+
+	select {
+	case payload := <-received:
+		if payload["verification_token"] != "secret" || payload["webhook_subscription_uuid"] != sub.UUID {
+			t.Errorf("unexpected verification payload: %v", payload)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected a verification payload to be sent")
+	}
+
+	if resp := do("PUT", "/v1/webhook_subscriptions/"+sub.UUID+"/verify", `{"verification_token":"wrong"}`); resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("incorrect status for wrong token: got %d want %d", resp.StatusCode, http.StatusUnprocessableEntity)
+	}
+	if resp := do("PUT", "/v1/webhook_subscriptions/"+sub.UUID+"/verify", `{"verification_token":"secret"}`); resp.StatusCode != http.StatusOK {
+		t.Errorf("incorrect status for verification: got %d want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	resp = do("GET", "/v1/webhook_subscriptions", "")
+	var subs []mockSubscription
+	json.NewDecoder(resp.Body).Decode(&subs)
+	resp.Body.Close()
+	if len(subs) != 1 || subs[0].Status != "verified" {
+		t.Errorf("unexpected subscriptions: %+v", subs)
+	}
+
+	if resp := do("GET", "/v1/companies/c1", ""); resp.StatusCode != http.StatusOK {
+		t.Errorf("incorrect status fetching a company: got %d want %d", resp.StatusCode, http.StatusOK)
+	}
+}
@@ -0,0 +1,107 @@
+package gusto
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"gusto-webhook-guide/internal/models"
+	"gusto-webhook-guide/internal/worker"
+	"strings"
+)
+
+// CompanyPayload is the payload of company.* events.
+type CompanyPayload struct {
+	Name       string `json:"name"`
+	TradeName  string `json:"trade_name"`
+	EIN        string `json:"ein"`
+	EntityType string `json:"entity_type"`
+}
+
+// Complete reports whether the payload carries the company's details, so
+// they don't need to be fetched from the API.
+func (c CompanyPayload) Complete() bool {
+	return c.Name != ""
+}
+
+// EmployeePayload is the payload of employee.* events.
+type EmployeePayload struct {
+	FirstName  string `json:"first_name"`
+	LastName   string `json:"last_name"`
+	Email      string `json:"email"`
+	Terminated bool   `json:"terminated"`
+}
+
+// PayrollPayload is the payload of payroll.* events.
+type PayrollPayload struct {
+	CheckDate string `json:"check_date"`
+	Processed bool   `json:"processed"`
+	PayPeriod struct {
+		StartDate string `json:"start_date"`
+		EndDate   string `json:"end_date"`
+	} `json:"pay_period"`
+}
+
+// ContractorPayload is the payload of contractor.* events. Type is
+// "Individual" or "Business"; BusinessName is only set for the latter.
+type ContractorPayload struct {
+	Type         string `json:"type"`
+	FirstName    string `json:"first_name"`
+	LastName     string `json:"last_name"`
+	BusinessName string `json:"business_name"`
+	Email        string `json:"email"`
+	WageType     string `json:"wage_type"`
+	IsActive     bool   `json:"is_active"`
+}
+
+// payloadDecoders decode the payload of each resource's events, keyed by
+// the part of the event type before the dot.
+var payloadDecoders = map[string]func(json.RawMessage) (any, error){
+	"company":    decodeAs[CompanyPayload],
+	"contractor": decodeAs[ContractorPayload],
+	"employee":   decodeAs[EmployeePayload],
+	"payroll":    decodeAs[PayrollPayload],
+}
+
+func decodeAs[T any](raw json.RawMessage) (any, error) {
+	var payload T
+	err := json.Unmarshal(raw, &payload)
+	return payload, err
+}
+
+// DecodePayload decodes the event's payload into the type for its event
+// type: a CompanyPayload for company.* events, a ContractorPayload for
+// contractor.* events, an EmployeePayload for employee.* events and a
+// PayrollPayload for payroll.* events. It returns
+// nil for other event types and for events without a payload. Gusto may
+// send only some fields, so handlers fall back to the API for the rest.
+func DecodePayload(event models.WebhookEvent) (any, error) {
+	resource, _, _ := strings.Cut(event.EventType, ".")
+	decode, found := payloadDecoders[resource]
+	if !found || len(event.Payload) == 0 || bytes.Equal(event.Payload, []byte("null")) {
+		return nil, nil
+	}
+	payload, err := decode(event.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("decoding %s payload: %w", event.EventType, err)
+	}
+	return payload, nil
+}
+
+// Typed adapts fn, which takes the event's payload decoded as T, to a
+// worker.JobHandler, e.g. Typed(func(ctx context.Context, event
+// models.WebhookEvent, employee EmployeePayload) error { ... }) registered
+// for "employee.*". Events without a payload get T's zero value. A payload
+// that doesn't decode as T fails the job for good with worker.ErrSchema,
+// since it won't decode on a later attempt either.
+func Typed[T any](fn func(ctx context.Context, event models.WebhookEvent, payload T) error) worker.JobHandler {
+	return func(ctx context.Context, event models.WebhookEvent) error {
+		var payload T
+		if len(event.Payload) > 0 {
+			if err := json.Unmarshal(event.Payload, &payload); err != nil {
+				return worker.Permanentf("%w: decoding %s payload: %w", worker.ErrSchema, event.EventType, err)
+			}
+		}
+		return fn(ctx, event, payload)
+	}
+}
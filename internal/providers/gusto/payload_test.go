@@ -0,0 +1,89 @@
+package gusto
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"gusto-webhook-guide/internal/models"
+	"gusto-webhook-guide/internal/worker"
+	"reflect"
+	"testing"
+)
+
+func TestDecodePayload(t *testing.T) {
+	var payroll PayrollPayload
+	payroll.CheckDate = "2026-10-30"
+	payroll.PayPeriod.StartDate = "2026-10-01"
+
+	testCases := []struct {
+		name        string
+		eventType   string
+		payload     string
+		expected    any
+		expectError bool
+	}{
+		{
+			name:      "Company Event",
+			eventType: "company.updated",
+			payload:   `{"name":"Acme Corp","trade_name":"Acme","unknown":true}`,
+			expected:  CompanyPayload{Name: "Acme Corp", TradeName: "Acme"},
+		},
+		{
+			name:      "Employee Event",
+			eventType: "employee.terminated",
+			payload:   `{"first_name":"Sam","terminated":true}`,
+			expected:  EmployeePayload{FirstName: "Sam", Terminated: true},
+		},
+		{
+			name:      "Payroll Event",
+			eventType: "payroll.submitted",
+			payload:   `{"check_date":"2026-10-30","pay_period":{"start_date":"2026-10-01"}}`,
+			expected:  payroll,
+		},
+		{name: "Empty Payload", eventType: "company.updated", payload: ``, expected: nil},
+		{name: "Null Payload", eventType: "company.updated", payload: `null`, expected: nil},
+		{
+			name:      "Contractor Event",
+			eventType: "contractor.created",
+			payload:   `{"type":"Business","business_name":"Acme LLC","is_active":true}`,
+			expected:  ContractorPayload{Type: "Business", BusinessName: "Acme LLC", IsActive: true},
+		},
+		{name: "Unknown Resource", eventType: "time_off_request.created", payload: `{"name":"x"}`, expected: nil},
+		{name: "Malformed Payload", eventType: "employee.created", payload: `{"terminated":"yes"}`, expectError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			event := models.WebhookEvent{EventType: tc.eventType, Payload: json.RawMessage(tc.payload)}
+			got, err := DecodePayload(event)
+			if (err != nil) != tc.expectError {
+				t.Fatalf("incorrect error: got %v want error %v", err, tc.expectError)
+			}
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("incorrect payload: got %#v want %#v", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestTyped(t *testing.T) {
+	var got EmployeePayload
+	handler := Typed(func(_ context.Context, _ models.WebhookEvent, employee EmployeePayload) error {
+		got = employee
+		return nil
+	})
+
+	event := models.WebhookEvent{EventType: "employee.updated", Payload: json.RawMessage(`{"first_name":"Sam","email":"sam@example.com"}`)}
+	if err := handler(context.Background(), event); err != nil {
+		t.Fatalf("handler failed: %v", err)
+	}
+	if want := (EmployeePayload{FirstName: "Sam", Email: "sam@example.com"}); got != want {
+		t.Errorf("incorrect payload: got %#v want %#v", got, want)
+	}
+
+	event.Payload = json.RawMessage(`{"terminated":"yes"}`)
+	err := handler(context.Background(), event)
+	if !errors.Is(err, worker.ErrSchema) || !worker.IsPermanent(err) {
+		t.Errorf("malformed payload: got %v want a permanent schema error", err)
+	}
+}
@@ -0,0 +1,127 @@
+package gusto
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gusto-webhook-guide/internal/models"
+	"gusto-webhook-guide/internal/worker"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// canaryEventType matches canary.EventType, which can't be imported here
+// because the canary package imports this one.
+const canaryEventType = "canary.probe"
+
+// DefaultBaseURL is the Gusto demo (sandbox) API.
+const DefaultBaseURL = "https://api.gusto-demo.com"
+
+// HandledEventTypes are the event types Process has handlers for, so the
+// webhook subscription must deliver them. Keep it in step with Register.
+var HandledEventTypes = []string{"company.updated"}
+
+// APIErrorResponse defines the structure of a Gusto API error.
+type APIErrorResponse struct {
+	Errors []struct {
+		Category string `json:"category"`
+		Message  string `json:"message"`
+	} `json:"errors"`
+}
+
+// Processor implements worker.Processor for Gusto events.
+type Processor struct {
+	Logger      *slog.Logger
+	BaseURL     string
+	AccessToken string
+	Client      *http.Client
+}
+
+// NewProcessor creates a Processor talking to the Gusto demo API.
+func NewProcessor(logger *slog.Logger) *Processor {
+	return &Processor{
+		Logger:      logger,
+		BaseURL:     DefaultBaseURL,
+		AccessToken: "supply-access-token-here",
+		Client:      &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+// Register registers the Processor's handlers with r: one for each of
+// HandledEventTypes, and one for canary events.
+func (p *Processor) Register(r *worker.Registry) {
+	r.On("company.updated", Typed(p.companyUpdated))
+	// Synthetic canary events only need to reach a handler.
+	r.On(canaryEventType, func(context.Context, models.WebhookEvent) error { return nil })
+}
+
+// Process handles an event with the handlers Register registers, for use
+// without a shared worker.Registry. Other event types are unhandled.
+func (p *Processor) Process(ctx context.Context, event models.WebhookEvent) error {
+	r := worker.NewRegistry()
+	p.Register(r)
+	return r.Process(ctx, event)
+}
+
+// companyUpdated uses the company in the event's payload where that is
+// enough, and otherwise fetches the company from the Gusto API.
+func (p *Processor) companyUpdated(ctx context.Context, event models.WebhookEvent, company CompanyPayload) error {
+	if company.Complete() {
+		p.Logger.Info("Company details taken from webhook payload, no API call needed.")
+		return nil
+	}
+
+	// 1. Make an API call to get company details.
+	companyURL := fmt.Sprintf("%s/v1/companies/%s", p.BaseURL, event.ResourceUUID)
+	req, _ := http.NewRequestWithContext(ctx, "GET", companyURL, nil)
+	req.Header.Set("Authorization", "Bearer "+p.AccessToken)
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		// A client-side error (e.g., DNS, timeout) is a transient failure.
+		return worker.Transientf("http client error: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// 2. Handle the API response.
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		// Retrying won't help until the access token is replaced.
+		return worker.Permanentf("%w: Gusto API returned %d", worker.ErrAuth, resp.StatusCode)
+	}
+	// Gusto asks us to back off with a 429, or a 503 with Retry-After;
+	// retry when it says rather than after the default delay.
+	retryAfter := RetryAfter(resp.Header, time.Now())
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return &worker.ErrTransient{Err: errors.New("Gusto API rate limit exceeded"), RetryAfter: retryAfter}
+	}
+	if resp.StatusCode >= 400 {
+		// This is an API error from Gusto. Parse the error response.
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		var gustoError APIErrorResponse
+		if err := json.Unmarshal(bodyBytes, &gustoError); err != nil {
+			// If we can't parse the error, treat it as transient.
+			return &worker.ErrTransient{Err: fmt.Errorf("failed to parse Gusto error response: %w", err), RetryAfter: retryAfter}
+		}
+
+		if len(gustoError.Errors) > 0 {
+			errorCategory := gustoError.Errors[0].Category
+			apiErr := fmt.Errorf("Gusto API error: %s", gustoError.Errors[0].Message)
+
+			// Use the 'category' from the JSON error to classify the failure.
+			switch errorCategory {
+			case "server_error", "rate_limit_error", "system_error":
+				return &worker.ErrTransient{Err: apiErr, RetryAfter: retryAfter}
+			default:
+				// Treat all others (validation, auth, etc.) as permanent.
+				return &worker.ErrPermanent{Err: apiErr}
+			}
+		}
+	}
+
+	// If status code is 2xx, the API call was successful.
+	p.Logger.Info("Successfully fetched company details after webhook event.")
+	return nil
+}
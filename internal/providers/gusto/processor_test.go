@@ -0,0 +1,265 @@
+package gusto
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"gusto-webhook-guide/internal/models"
+	"gusto-webhook-guide/internal/vcr"
+	"gusto-webhook-guide/internal/worker"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProcess(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+
+	testCases := []struct {
+		name            string
+		eventType       string
+		payload         string
+		statusCode      int
+		retryAfter      string // Retry-After response header.
+		responseBody    string
+		expectTransient bool
+		expectPermanent bool
+		expectUnhandled bool
+		expectAuth      bool
+		expectSchema    bool
+		expectAPICall   bool
+		expectDelay     time.Duration // RetryAfter of a transient error.
+	}{
+		{
+			name:          "Success - Company Fetched",
+			eventType:     "company.updated",
+			statusCode:    http.StatusOK,
+			responseBody:  `{"uuid":"company-1"}`,
+			expectAPICall: true,
+		},
+		{
+			name:            "Transient - Server Error Category",
+			eventType:       "company.updated",
+			statusCode:      http.StatusInternalServerError,
+			responseBody:    `{"errors":[{"category":"server_error","message":"boom"}]}`,
+			expectTransient: true,
+			expectAPICall:   true,
+		},
+		{
+			name:            "Transient - Rate Limited",
+			eventType:       "company.updated",
+			statusCode:      http.StatusTooManyRequests,
+			retryAfter:      "120",
+			expectTransient: true,
+			expectAPICall:   true,
+			expectDelay:     2 * time.Minute,
+		},
+		{
+			name:            "Transient - Unavailable With Retry-After",
+			eventType:       "company.updated",
+			statusCode:      http.StatusServiceUnavailable,
+			retryAfter:      "30",
+			responseBody:    `{"errors":[{"category":"system_error","message":"maintenance"}]}`,
+			expectTransient: true,
+			expectAPICall:   true,
+			expectDelay:     30 * time.Second,
+		},
+		{
+			name:            "Permanent - Validation Error Category",
+			eventType:       "company.updated",
+			statusCode:      http.StatusUnprocessableEntity,
+			responseBody:    `{"errors":[{"category":"invalid_attribute_value","message":"bad"}]}`,
+			expectPermanent: true,
+			expectAPICall:   true,
+		},
+		{
+			name:            "Permanent - Unauthorized",
+			eventType:       "company.updated",
+			statusCode:      http.StatusUnauthorized,
+			responseBody:    `<html>unauthorized</html>`,
+			expectPermanent: true,
+			expectAuth:      true,
+			expectAPICall:   true,
+		},
+		{
+			name:            "Transient - Unparseable Error Body",
+			eventType:       "company.updated",
+			statusCode:      http.StatusBadGateway,
+			responseBody:    `<html>bad gateway</html>`,
+			expectTransient: true,
+			expectAPICall:   true,
+		},
+		{
+			name:          "Success - Company In Payload",
+			eventType:     "company.updated",
+			payload:       `{"name":"Acme Corp"}`,
+			expectAPICall: false,
+		},
+		{
+			name:          "Success - Partial Payload Fetched",
+			eventType:     "company.updated",
+			payload:       `{"ein":"00-0000000"}`,
+			statusCode:    http.StatusOK,
+			responseBody:  `{"uuid":"company-1"}`,
+			expectAPICall: true,
+		},
+		{
+			name:            "Permanent - Malformed Payload",
+			eventType:       "company.updated",
+			payload:         `{"name":42}`,
+			expectPermanent: true,
+			expectSchema:    true,
+			expectAPICall:   false,
+		},
+		{
+			name:            "Unhandled - Other Event Types",
+			eventType:       "company.created",
+			expectUnhandled: true,
+			expectAPICall:   false,
+		},
+		{
+			name:          "Success - Canary Events",
+			eventType:     "canary.probe",
+			expectAPICall: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			called := false
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				called = true
+				if got, want := r.URL.Path, "/v1/companies/company-1"; got != want {
+					t.Errorf("wrong request path: got %q want %q", got, want)
+				}
+				if tc.retryAfter != "" {
+					w.Header().Set("Retry-After", tc.retryAfter)
+				}
+				w.WriteHeader(tc.statusCode)
+				io.WriteString(w, tc.responseBody)
+			}))
+			defer server.Close()
+
+			processor := NewProcessor(logger)
+			processor.BaseURL = server.URL
+
+			event := models.WebhookEvent{UUID: "event-1", EventType: tc.eventType, ResourceUUID: "company-1"}
+			if tc.payload != "" {
+				event.Payload = json.RawMessage(tc.payload)
+			}
+			err := processor.Process(context.Background(), event)
+
+			var transientErr *worker.ErrTransient
+			var permanentErr *worker.ErrPermanent
+			if got := errors.As(err, &transientErr); got != tc.expectTransient {
+				t.Errorf("transient classification: got %v want %v (err %v)", got, tc.expectTransient, err)
+			}
+			if transientErr != nil && transientErr.RetryAfter != tc.expectDelay {
+				t.Errorf("incorrect retry delay: got %v want %v", transientErr.RetryAfter, tc.expectDelay)
+			}
+			if got := errors.As(err, &permanentErr); got != tc.expectPermanent {
+				t.Errorf("permanent classification: got %v want %v (err %v)", got, tc.expectPermanent, err)
+			}
+			if got := errors.Is(err, worker.ErrUnhandled); got != tc.expectUnhandled {
+				t.Errorf("unhandled classification: got %v want %v (err %v)", got, tc.expectUnhandled, err)
+			}
+			if got := errors.Is(err, worker.ErrAuth); got != tc.expectAuth {
+				t.Errorf("auth classification: got %v want %v (err %v)", got, tc.expectAuth, err)
+			}
+			if got := errors.Is(err, worker.ErrSchema); got != tc.expectSchema {
+				t.Errorf("schema classification: got %v want %v (err %v)", got, tc.expectSchema, err)
+			}
+			if !tc.expectTransient && !tc.expectPermanent && !tc.expectUnhandled && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if called != tc.expectAPICall {
+				t.Errorf("API call expectation failed: got %v want %v", called, tc.expectAPICall)
+			}
+		})
+	}
+}
+
+func TestProcessHonorsContext(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	processor := NewProcessor(logger)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	event := models.WebhookEvent{UUID: "cancelled-uuid", EventType: "company.updated"}
+	err := processor.Process(ctx, event)
+
+	var transientErr *worker.ErrTransient
+	if !errors.As(err, &transientErr) {
+		t.Fatalf("expected a transient error, got %v", err)
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected error to wrap context.Canceled, got %v", err)
+	}
+}
+
+// cassetteCompanyUUID is the company in the company_fetched cassette. When
+// recording, the real GUSTO_CONTRACT_COMPANY_UUID is replaced with it.
+const cassetteCompanyUUID = "7b3d2a1e-5c4f-4e8a-9d6b-1f2e3a4b5c6d"
+
+// einPattern matches a company's EIN in an API response, scrubbed from
+// recorded cassettes.
+var einPattern = regexp.MustCompile(`"ein":"[^"]*"`)
+
+// TestProcessWithCassettes runs the company.updated handler against
+// recorded Gusto API responses. To re-record them against the demo API, run
+// make record-cassettes with GUSTO_CONTRACT_COMPANY_UUID and
+// GUSTO_CONTRACT_COMPANY_TOKEN set.
+func TestProcessWithCassettes(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+
+	testCases := []struct {
+		name            string
+		cassette        string
+		companyUUID     string
+		expectPermanent bool
+	}{
+		{name: "Company Fetched", cassette: "company_fetched", companyUUID: cassetteCompanyUUID},
+		{name: "Company Not Found", cassette: "company_not_found", companyUUID: "00000000-0000-4000-8000-000000000000", expectPermanent: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := vcr.Open(t, filepath.Join("testdata", "cassettes", tc.cassette+".json"))
+			p := NewProcessor(logger)
+			p.Client = &http.Client{Transport: rec}
+			companyUUID := tc.companyUUID
+			if rec.Mode == vcr.ModeRecord {
+				p.AccessToken = os.Getenv("GUSTO_CONTRACT_COMPANY_TOKEN")
+				if realUUID := os.Getenv("GUSTO_CONTRACT_COMPANY_UUID"); realUUID != "" && companyUUID == cassetteCompanyUUID {
+					companyUUID = realUUID
+					rec.Sanitize = func(i *vcr.Interaction) {
+						i.Request.URL = strings.ReplaceAll(i.Request.URL, realUUID, cassetteCompanyUUID)
+						i.Response.Body = strings.ReplaceAll(i.Response.Body, realUUID, cassetteCompanyUUID)
+						i.Response.Body = einPattern.ReplaceAllString(i.Response.Body, `"ein":"00-0000000"`)
+					}
+				}
+			}
+
+			err := p.Process(context.Background(), models.WebhookEvent{
+				UUID:         "event-1",
+				EventType:    "company.updated",
+				ResourceUUID: companyUUID,
+			})
+			if tc.expectPermanent {
+				if !worker.IsPermanent(err) {
+					t.Errorf("Expected a permanent error, got %v", err)
+				}
+			} else if err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package gusto
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"gusto-webhook-guide/internal/worker"
+	"net/http"
+	"time"
+)
+
+// DefaultStatusPageURL is the summary endpoint of Gusto's public status
+// page, which is hosted on Atlassian Statuspage.
+const DefaultStatusPageURL = "https://status.gusto.com/api/v2/status.json"
+
+// statusPageHoldSource identifies the status page's hold on retries.
+const statusPageHoldSource = "status_page"
+
+// StatusPage watches Gusto's status page and holds the worker pool's
+// retries while it declares an outage, so events failing against a down API
+// aren't retried into the dead-letter queue. Only a major or critical
+// indicator counts as an outage; minor incidents are retried as usual.
+type StatusPage struct {
+	URL  string       // Defaults to DefaultStatusPageURL.
+	HTTP *http.Client // Defaults to a client with a 10 second timeout.
+	Pool *worker.Pool
+}
+
+// statusSummary is the part of a Statuspage status.json response we use.
+type statusSummary struct {
+	Status struct {
+		Indicator   string `json:"indicator"` // none, minor, major or critical.
+		Description string `json:"description"`
+	} `json:"status"`
+}
+
+// Check fetches the status page and holds or releases retries to match.
+// If the page can't be read, the hold is left as it was.
+func (s *StatusPage) Check(ctx context.Context) error {
+	summary, err := s.fetch(ctx)
+	if err != nil {
+		return err
+	}
+	switch summary.Status.Indicator {
+	case "major", "critical":
+		s.Pool.HoldRetries(statusPageHoldSource, fmt.Sprintf("Gusto status: %s", summary.Status.Description))
+	default:
+		s.Pool.ReleaseRetries(statusPageHoldSource)
+	}
+	return nil
+}
+
+func (s *StatusPage) fetch(ctx context.Context) (statusSummary, error) {
+	var summary statusSummary
+	url := s.URL
+	if url == "" {
+		url = DefaultStatusPageURL
+	}
+	client := s.HTTP
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return summary, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return summary, fmt.Errorf("fetching Gusto status: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return summary, fmt.Errorf("fetching Gusto status: unexpected status %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&summary); err != nil {
+		return summary, fmt.Errorf("decoding Gusto status: %w", err)
+	}
+	return summary, nil
+}
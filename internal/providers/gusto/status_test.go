@@ -0,0 +1,51 @@
+package gusto
+
+import (
+	"context"
+	"fmt"
+	"gusto-webhook-guide/internal/worker"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStatusPageCheck(t *testing.T) {
+	indicator := "major"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if indicator == "" {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		fmt.Fprintf(w, `{"status":{"indicator":%q,"description":"Partial System Outage"}}`, indicator)
+	}))
+	defer server.Close()
+
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	pool := worker.NewPool(10, 0, logger, worker.NewIdempotencyStore(), worker.ProcessorFunc(nil))
+	defer pool.Stop()
+	s := &StatusPage{URL: server.URL, Pool: pool}
+
+	testCases := []struct {
+		indicator  string
+		expectErr  bool
+		expectHeld bool
+	}{
+		{indicator: "major", expectHeld: true},
+		{indicator: "", expectErr: true, expectHeld: true}, // An unreadable page keeps the hold.
+		{indicator: "minor"},
+		{indicator: "critical", expectHeld: true},
+		{indicator: "none"},
+	}
+	for _, tc := range testCases {
+		indicator = tc.indicator
+		err := s.Check(context.Background())
+		if (err != nil) != tc.expectErr {
+			t.Errorf("indicator %q: incorrect error: got %v want error %v", tc.indicator, err, tc.expectErr)
+		}
+		if held := len(pool.RetryHolds()) > 0; held != tc.expectHeld {
+			t.Errorf("indicator %q: incorrect hold: got %v want %v", tc.indicator, held, tc.expectHeld)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package gusto
+
+import (
+	"context"
+	"gusto-webhook-guide/internal/worker"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Default and maximum pause after a 429, when the response has no
+// Retry-After header and when it asks for longer.
+const (
+	defaultRateLimitPause = time.Second
+	maxRateLimitPause     = 30 * time.Second
+)
+
+// Transport is an http.RoundTripper for Gusto API clients that records the
+// latency, status code and rate-limit headers of each request per endpoint,
+// in metrics and in Health, and logs failed requests. After a 429 it holds
+// back every request it carries until the Retry-After time, briefly, so the
+// other workers don't keep hammering an API that is throttling us.
+type Transport struct {
+	Base   http.RoundTripper // Defaults to http.DefaultTransport.
+	Logger *slog.Logger
+	Health *Health
+
+	mu          sync.Mutex
+	pausedUntil time.Time
+}
+
+// NewTransport creates a Transport recording into health.
+func NewTransport(logger *slog.Logger, health *Health) *Transport {
+	return &Transport{Logger: logger, Health: health}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	endpoint := Endpoint(req)
+	retry := worker.Attempt(req.Context()) > 1
+	if err := t.waitForPause(req.Context()); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	resp, err := base.RoundTrip(req)
+	elapsed := time.Since(start)
+
+	obs := Observation{Endpoint: endpoint, Duration: elapsed, Retry: retry, RateLimitRemaining: -1, At: start.UTC()}
+	status := "error"
+	if err == nil {
+		obs.StatusCode = resp.StatusCode
+		status = strconv.Itoa(resp.StatusCode)
+		if remaining, parseErr := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining")); parseErr == nil {
+			obs.RateLimitRemaining = remaining
+			apiRateLimitRemaining.WithLabelValues(endpoint).Set(float64(remaining))
+		}
+	}
+	apiRequestDuration.WithLabelValues(endpoint, status).Observe(elapsed.Seconds())
+	if retry {
+		apiRetries.WithLabelValues(endpoint).Inc()
+	}
+	if t.Health != nil {
+		t.Health.Observe(obs)
+	}
+
+	if err == nil && resp.StatusCode == http.StatusTooManyRequests {
+		t.pause(RetryAfter(resp.Header, time.Now()))
+	}
+
+	switch {
+	case err != nil:
+		t.Logger.Warn("Gusto API request failed", "endpoint", endpoint, "duration", elapsed, "error", err)
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		t.Logger.Warn("Gusto API request returned an error status",
+			"endpoint", endpoint,
+			"status", resp.StatusCode,
+			"duration", elapsed,
+			"retry_after", resp.Header.Get("Retry-After"),
+		)
+	default:
+		t.Logger.Debug("Gusto API request completed", "endpoint", endpoint, "status", resp.StatusCode, "duration", elapsed)
+	}
+	return resp, err
+}
+
+// pause holds back requests for d, or defaultRateLimitPause if d is zero,
+// capped at maxRateLimitPause. It never shortens a pause already in effect.
+func (t *Transport) pause(d time.Duration) {
+	if d <= 0 {
+		d = defaultRateLimitPause
+	}
+	until := time.Now().Add(min(d, maxRateLimitPause))
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if until.After(t.pausedUntil) {
+		t.pausedUntil = until
+	}
+}
+
+// waitForPause blocks until any pause after a 429 is over or ctx is done.
+func (t *Transport) waitForPause(ctx context.Context) error {
+	t.mu.Lock()
+	wait := time.Until(t.pausedUntil)
+	t.mu.Unlock()
+	if wait <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// RetryAfter returns how long a response's Retry-After header asks callers
+// to wait, given in seconds or as an HTTP date, or zero if it has none.
+func RetryAfter(header http.Header, now time.Time) time.Duration {
+	raw := header.Get("Retry-After")
+	if raw == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(raw); err == nil {
+		return max(time.Duration(seconds)*time.Second, 0)
+	}
+	if at, err := http.ParseTime(raw); err == nil {
+		return max(at.Sub(now), 0)
+	}
+	return 0
+}
+
+// Endpoint returns the method and path of req with IDs replaced by "{id}",
+// e.g. "GET /v1/companies/{id}", so requests group by API endpoint rather
+// than by resource.
+func Endpoint(req *http.Request) string {
+	segments := strings.Split(req.URL.Path, "/")
+	for i, seg := range segments {
+		if isID(seg) {
+			segments[i] = "{id}"
+		}
+	}
+	return req.Method + " " + strings.Join(segments, "/")
+}
+
+// isID reports whether a path segment is a UUID or a numeric ID.
+func isID(seg string) bool {
+	if seg == "" {
+		return false
+	}
+	if _, err := strconv.ParseUint(seg, 10, 64); err == nil {
+		return true
+	}
+	if len(seg) != 36 {
+		return false
+	}
+	for i, c := range seg {
+		switch {
+		case i == 8 || i == 13 || i == 18 || i == 23:
+			if c != '-' {
+				return false
+			}
+		case !strings.ContainsRune("0123456789abcdefABCDEF", c):
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,150 @@
+package gusto
+
+import (
+	"context"
+	"errors"
+	"gusto-webhook-guide/internal/worker"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestEndpoint(t *testing.T) {
+	testCases := []struct {
+		method   string
+		path     string
+		expected string
+	}{
+		{method: "GET", path: "/v1/companies/7b1d2a4c-0d5e-4f3a-9b8c-1a2b3c4d5e6f", expected: "GET /v1/companies/{id}"},
+		{method: "PUT", path: "/v1/webhook_subscriptions/7B1D2A4C-0D5E-4F3A-9B8C-1A2B3C4D5E6F/verify", expected: "PUT /v1/webhook_subscriptions/{id}/verify"},
+		{method: "GET", path: "/v1/employees/12345/jobs", expected: "GET /v1/employees/{id}/jobs"},
+		{method: "POST", path: "/v1/webhook_subscriptions", expected: "POST /v1/webhook_subscriptions"},
+		{method: "GET", path: "/v1/companies/not-a-uuid", expected: "GET /v1/companies/not-a-uuid"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.expected, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, nil)
+			if got := Endpoint(req); got != tc.expected {
+				t.Errorf("incorrect endpoint: got %q want %q", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestTransport(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "42")
+		if r.URL.Path == "/v1/companies/1" {
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer server.Close()
+
+	health := NewHealth()
+	client := &http.Client{Transport: NewTransport(logger, health)}
+	get := func(ctx context.Context, path string) {
+		req, _ := http.NewRequestWithContext(ctx, "GET", server.URL+path, nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		resp.Body.Close()
+	}
+	get(worker.WithAttempt(context.Background(), 2), "/v1/companies/2")
+	get(context.Background(), "/v1/webhook_subscriptions")
+
+	// Requests to an unreachable server count as errors.
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+	req, _ := http.NewRequest("GET", unreachable.URL+"/v1/webhook_subscriptions", nil)
+	if _, err := client.Do(req); err == nil {
+		t.Fatalf("expected request to a closed server to fail")
+	}
+
+	// Last, since a 429 pauses later requests.
+	get(context.Background(), "/v1/companies/1")
+
+	summary := health.Summary()
+	if len(summary) != 2 {
+		t.Fatalf("incorrect endpoints: got %+v want 2", summary)
+	}
+	companies := summary[0]
+	if companies.Endpoint != "GET /v1/companies/{id}" || companies.Requests != 2 || companies.Retries != 1 {
+		t.Errorf("incorrect company endpoint summary: got %+v", companies)
+	}
+	if companies.Statuses["2xx"] != 1 || companies.Statuses["4xx"] != 1 {
+		t.Errorf("incorrect status counts: got %v want 1 2xx and 1 4xx", companies.Statuses)
+	}
+	if companies.RateLimitRemaining == nil || *companies.RateLimitRemaining != 42 {
+		t.Errorf("incorrect rate limit remaining: got %v want 42", companies.RateLimitRemaining)
+	}
+	subscriptions := summary[1]
+	if subscriptions.Requests != 2 || subscriptions.Errors != 1 {
+		t.Errorf("incorrect subscription endpoint summary: got %+v", subscriptions)
+	}
+}
+
+func TestTransportPausesAfterRateLimit(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Retry-After", "5")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: NewTransport(logger, nil)}
+	req, _ := http.NewRequest("GET", server.URL+"/v1/companies/1", nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	// The next request waits out the pause, so it gives up when its
+	// context does without reaching the server.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req, _ = http.NewRequestWithContext(ctx, "GET", server.URL+"/v1/companies/2", nil)
+	if _, err := client.Do(req); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("incorrect error: got %v want %v", err, context.DeadlineExceeded)
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("incorrect number of requests: got %d want 1", got)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name     string
+		header   string
+		expected time.Duration
+	}{
+		{name: "Missing", header: "", expected: 0},
+		{name: "Seconds", header: "120", expected: 2 * time.Minute},
+		{name: "HTTP Date", header: now.Add(30 * time.Second).Format(http.TimeFormat), expected: 30 * time.Second},
+		{name: "Date In The Past", header: now.Add(-time.Minute).Format(http.TimeFormat), expected: 0},
+		{name: "Invalid", header: "soon", expected: 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			header := http.Header{}
+			if tc.header != "" {
+				header.Set("Retry-After", tc.header)
+			}
+			if got := RetryAfter(header, now); got != tc.expected {
+				t.Errorf("incorrect delay: got %v want %v", got, tc.expected)
+			}
+		})
+	}
+}
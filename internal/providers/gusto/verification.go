@@ -0,0 +1,45 @@
+package gusto
+
+import (
+	"log/slog"
+	"net/http"
+)
+
+// VerificationHandler returns a control handler for the payload Gusto sends
+// to a new subscription's URL. It logs the token and UUID needed to complete
+// the handshake and reports whether the payload was a verification payload.
+func VerificationHandler(logger *slog.Logger) func(w http.ResponseWriter, payload map[string]any) bool {
+	return func(w http.ResponseWriter, payload map[string]any) bool {
+		token, isVerification := payload["verification_token"]
+		if !isVerification {
+			return false
+		}
+
+		logger.Info("✅ Received verification payload from Gusto. Use the token and UUID from the logs to complete verification.",
+			"verification_token", token,
+			"webhook_subscription_uuid", payload["webhook_subscription_uuid"],
+		)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("Verification payload acknowledged.\n"))
+		return true
+	}
+}
+
+// TenantVerificationHandler is like VerificationHandler but passes the token
+// and subscription UUID to onToken, which completes the handshake without
+// manual steps.
+func TenantVerificationHandler(logger *slog.Logger, onToken func(subscriptionUUID, token string)) func(w http.ResponseWriter, payload map[string]any) bool {
+	return func(w http.ResponseWriter, payload map[string]any) bool {
+		token, isVerification := payload["verification_token"].(string)
+		if !isVerification {
+			return false
+		}
+
+		subscriptionUUID, _ := payload["webhook_subscription_uuid"].(string)
+		logger.Info("Received verification payload for tenant subscription", "webhook_subscription_uuid", subscriptionUUID)
+		onToken(subscriptionUUID, token)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("Verification payload acknowledged.\n"))
+		return true
+	}
+}
@@ -0,0 +1,64 @@
+package gusto
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestVerificationHandler(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	handle := VerificationHandler(logger)
+
+	testCases := []struct {
+		name           string
+		payload        map[string]any
+		expectHandled  bool
+		expectedStatus int
+	}{
+		{
+			name:           "Verification Payload",
+			payload:        map[string]any{"verification_token": "abc", "webhook_subscription_uuid": "xyz"},
+			expectHandled:  true,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:          "Event Payload",
+			payload:       map[string]any{"event_type": "company.created"},
+			expectHandled: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			if handled := handle(rr, tc.payload); handled != tc.expectHandled {
+				t.Fatalf("handled: got %v want %v", handled, tc.expectHandled)
+			}
+			if tc.expectHandled && rr.Code != tc.expectedStatus {
+				t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, tc.expectedStatus)
+			}
+		})
+	}
+}
+
+func TestTenantVerificationHandler(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	var got string
+	handle := TenantVerificationHandler(logger, func(subscriptionUUID, token string) {
+		got = subscriptionUUID + ":" + token
+	})
+
+	rr := httptest.NewRecorder()
+	if !handle(rr, map[string]any{"verification_token": "abc", "webhook_subscription_uuid": "xyz"}) {
+		t.Fatalf("Expected verification payload to be handled")
+	}
+	if got != "xyz:abc" {
+		t.Errorf("incorrect token delivered: got %q want %q", got, "xyz:abc")
+	}
+	if handle(httptest.NewRecorder(), map[string]any{"event_type": "company.created"}) {
+		t.Errorf("Expected event payload not to be handled")
+	}
+}
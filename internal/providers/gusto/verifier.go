@@ -0,0 +1,35 @@
+package gusto
+
+import (
+	"cmp"
+	"gusto-webhook-guide/internal/middleware"
+)
+
+// SignatureHeader is the header Gusto sends the body's HMAC-SHA256 in.
+const SignatureHeader = "X-Gusto-Signature"
+
+// DefaultSignature is how Gusto signs webhooks: a hex-encoded HMAC-SHA256 of
+// the body in SignatureHeader.
+var DefaultSignature = middleware.SignatureScheme{Header: SignatureHeader, Algorithm: "sha256", Encoding: "hex"}
+
+// ParseSignature returns DefaultSignature with the header, algorithm and
+// encoding given replacing its own, leaving those that are empty, for when
+// Gusto, e.g. in a sandbox or preview API, signs webhooks differently.
+func ParseSignature(header, algorithm, encoding string) (middleware.SignatureScheme, error) {
+	s := middleware.SignatureScheme{
+		Header:    cmp.Or(header, DefaultSignature.Header),
+		Algorithm: cmp.Or(algorithm, DefaultSignature.Algorithm),
+		Encoding:  cmp.Or(encoding, DefaultSignature.Encoding),
+	}
+	if err := s.Validate(); err != nil {
+		return middleware.SignatureScheme{}, err
+	}
+	return s, nil
+}
+
+// NewVerifier returns a Verifier for Gusto webhooks signed as
+// DefaultSignature. The secret is the verification_token received during
+// subscription setup.
+func NewVerifier(secret string) middleware.Verifier {
+	return DefaultSignature.Verifier(secret)
+}
@@ -0,0 +1,30 @@
+package gusto
+
+import "testing"
+
+func TestParseSignature(t *testing.T) {
+	testCases := []struct {
+		name                        string
+		header, algorithm, encoding string
+		expectedHeader              string
+		expectedAlgorithm           string
+		expectErr                   bool
+	}{
+		{name: "Defaults", expectedHeader: SignatureHeader, expectedAlgorithm: "sha256"},
+		{name: "Overridden Header", header: "X-Gusto-Preview-Signature", expectedHeader: "X-Gusto-Preview-Signature", expectedAlgorithm: "sha256"},
+		{name: "Overridden Algorithm", algorithm: "sha512", encoding: "base64", expectedHeader: SignatureHeader, expectedAlgorithm: "sha512"},
+		{name: "Unsupported Algorithm", algorithm: "md5", expectErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, err := ParseSignature(tc.header, tc.algorithm, tc.encoding)
+			if (err != nil) != tc.expectErr {
+				t.Fatalf("incorrect error: got %v, want error %v", err, tc.expectErr)
+			}
+			if err == nil && (s.Header != tc.expectedHeader || s.Algorithm != tc.expectedAlgorithm) {
+				t.Errorf("incorrect scheme: got %+v", s)
+			}
+		})
+	}
+}
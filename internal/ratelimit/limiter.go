@@ -0,0 +1,89 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Local is a fixed-window limiter held in process memory. Each replica
+// enforces its own limit, so it is only suitable for single-node deployments.
+type Local struct {
+	mu      sync.Mutex
+	limit   int
+	window  time.Duration
+	windows map[string]localWindow
+	now     func() time.Time
+}
+
+type localWindow struct {
+	start time.Time
+	count int
+}
+
+// NewLocal creates a Local limiter allowing limit requests per window.
+func NewLocal(limit int, window time.Duration) *Local {
+	return &Local{
+		limit:   limit,
+		window:  window,
+		windows: make(map[string]localWindow),
+		now:     time.Now,
+	}
+}
+
+// Allow reports whether another request for key fits in the current window.
+func (l *Local) Allow(_ context.Context, key string) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	w := l.windows[key]
+	if now.Sub(w.start) >= l.window {
+		w = localWindow{start: now}
+	}
+	w.count++
+	l.windows[key] = w
+	return w.count <= l.limit, nil
+}
+
+// allowScript increments the counter for the current window and sets its
+// expiry on first use, atomically, so concurrent replicas share one budget.
+var allowScript = redis.NewScript(`
+local count = redis.call("INCR", KEYS[1])
+if count == 1 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return count
+`)
+
+// Redis is a fixed-window limiter whose counters live in Redis, so every
+// replica behind a load balancer enforces the same shared limit.
+type Redis struct {
+	client redis.UniversalClient
+	prefix string
+	limit  int
+	window time.Duration
+}
+
+// NewRedis creates a Redis limiter allowing limit requests per window across
+// all replicas. Keys are namespaced with prefix.
+func NewRedis(client redis.UniversalClient, prefix string, limit int, window time.Duration) *Redis {
+	return &Redis{
+		client: client,
+		prefix: prefix,
+		limit:  limit,
+		window: window,
+	}
+}
+
+// Allow reports whether another request for key fits in the current window.
+func (l *Redis) Allow(ctx context.Context, key string) (bool, error) {
+	count, err := allowScript.Run(ctx, l.client, []string{l.prefix + key}, l.window.Milliseconds()).Int64()
+	if err != nil {
+		return false, fmt.Errorf("incrementing rate limit counter: %w", err)
+	}
+	return count <= int64(l.limit), nil
+}
@@ -0,0 +1,75 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestLocal(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	limiter := NewLocal(2, time.Minute)
+	limiter.now = func() time.Time { return now }
+
+	for i, want := range []bool{true, true, false} {
+		if got, _ := limiter.Allow(ctx, "webhooks"); got != want {
+			t.Errorf("request %d: got %v want %v", i+1, got, want)
+		}
+	}
+
+	// Other keys have their own budget.
+	if got, _ := limiter.Allow(ctx, "admin"); !got {
+		t.Errorf("expected a separate key to be allowed")
+	}
+
+	// The budget resets once the window has passed.
+	now = now.Add(time.Minute)
+	if got, _ := limiter.Allow(ctx, "webhooks"); !got {
+		t.Errorf("expected request in a new window to be allowed")
+	}
+}
+
+func TestRedis(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+
+	// Two limiters sharing one Redis behave like two replicas.
+	replicaA := NewRedis(client, "rl:", 2, time.Minute)
+	replicaB := NewRedis(client, "rl:", 2, time.Minute)
+
+	for i, tc := range []struct {
+		limiter *Redis
+		want    bool
+	}{
+		{replicaA, true},
+		{replicaB, true},
+		{replicaA, false},
+		{replicaB, false},
+	} {
+		got, err := tc.limiter.Allow(ctx, "webhooks")
+		if err != nil {
+			t.Fatalf("request %d: unexpected error: %v", i+1, err)
+		}
+		if got != tc.want {
+			t.Errorf("request %d: got %v want %v", i+1, got, tc.want)
+		}
+	}
+
+	// The budget resets once the counter expires.
+	server.FastForward(time.Minute)
+	if got, _ := replicaA.Allow(ctx, "webhooks"); !got {
+		t.Errorf("expected request in a new window to be allowed")
+	}
+
+	// Limiter errors are surfaced to the caller.
+	server.Close()
+	if _, err := replicaA.Allow(ctx, "webhooks"); err == nil {
+		t.Errorf("expected an error when Redis is unavailable")
+	}
+}
@@ -0,0 +1,308 @@
+// Package schema validates webhook events against JSON Schemas, so that
+// malformed events can be turned away at ingestion instead of failing deep
+// inside a worker.
+//
+// It supports the subset of JSON Schema needed to describe event payloads:
+// type, enum, const, required, properties, additionalProperties, items,
+// minItems, maxItems, minLength, maxLength, pattern, minimum and maximum.
+// Annotations such as title and description are ignored, and schemas using
+// other validation keywords (e.g. $ref or oneOf) fail to compile rather
+// than being silently half-checked.
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxViolations bounds how many violations Validate reports.
+const maxViolations = 20
+
+// unsupportedKeywords are validation keywords Compile refuses.
+var unsupportedKeywords = []string{
+	"$ref", "$dynamicRef", "allOf", "anyOf", "oneOf", "not", "if", "then", "else",
+	"dependentRequired", "dependentSchemas", "patternProperties", "propertyNames",
+	"prefixItems", "contains", "uniqueItems", "exclusiveMinimum", "exclusiveMaximum",
+	"multipleOf", "minProperties", "maxProperties", "format",
+}
+
+var knownTypes = []string{"array", "boolean", "integer", "null", "number", "object", "string"}
+
+// Violation is one way an event fails its schema.
+type Violation struct {
+	Path    string `json:"path"` // A JSON Pointer to the offending value, e.g. "/payload/company_uuid".
+	Message string `json:"message"`
+}
+
+func (v Violation) String() string {
+	if v.Path == "" {
+		return v.Message
+	}
+	return v.Path + ": " + v.Message
+}
+
+// Schema is a compiled JSON Schema.
+type Schema struct {
+	reject bool // The schema is false.
+
+	types      []string
+	enum       []any
+	constant   any
+	hasConst   bool
+	required   []string
+	properties map[string]*Schema
+	additional *Schema // For properties not listed in properties; nil allows any.
+	items      *Schema
+	minItems   *int
+	maxItems   *int
+	minLength  *int
+	maxLength  *int
+	pattern    *regexp.Regexp
+	minimum    *float64
+	maximum    *float64
+}
+
+// Compile parses a JSON Schema document.
+func Compile(data []byte) (*Schema, error) {
+	var raw any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("decoding schema: %w", err)
+	}
+	return compile(raw, "")
+}
+
+func compile(raw any, path string) (*Schema, error) {
+	if b, ok := raw.(bool); ok {
+		return &Schema{reject: !b}, nil
+	}
+	doc, ok := raw.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("schema at %q must be an object or a boolean", path)
+	}
+	for _, keyword := range unsupportedKeywords {
+		if _, found := doc[keyword]; found {
+			return nil, fmt.Errorf("schema at %q: unsupported keyword %q", path, keyword)
+		}
+	}
+
+	s := &Schema{}
+	var err error
+	if t, found := doc["type"]; found {
+		if s.types, err = compileTypes(t); err != nil {
+			return nil, fmt.Errorf("schema at %q: %w", path, err)
+		}
+	}
+	if enum, found := doc["enum"]; found {
+		values, ok := enum.([]any)
+		if !ok {
+			return nil, fmt.Errorf("schema at %q: enum must be an array", path)
+		}
+		s.enum = values
+	}
+	s.constant, s.hasConst = doc["const"]
+	if required, found := doc["required"]; found {
+		names, ok := required.([]any)
+		if !ok {
+			return nil, fmt.Errorf("schema at %q: required must be an array of strings", path)
+		}
+		for _, name := range names {
+			n, ok := name.(string)
+			if !ok {
+				return nil, fmt.Errorf("schema at %q: required must be an array of strings", path)
+			}
+			s.required = append(s.required, n)
+		}
+	}
+	if properties, found := doc["properties"]; found {
+		props, ok := properties.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("schema at %q: properties must be an object", path)
+		}
+		s.properties = make(map[string]*Schema, len(props))
+		for name, prop := range props {
+			if s.properties[name], err = compile(prop, path+"/properties/"+escape(name)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if additional, found := doc["additionalProperties"]; found {
+		if s.additional, err = compile(additional, path+"/additionalProperties"); err != nil {
+			return nil, err
+		}
+	}
+	if items, found := doc["items"]; found {
+		if s.items, err = compile(items, path+"/items"); err != nil {
+			return nil, err
+		}
+	}
+	for keyword, limit := range map[string]**int{
+		"minItems": &s.minItems, "maxItems": &s.maxItems, "minLength": &s.minLength, "maxLength": &s.maxLength,
+	} {
+		if *limit, err = compileCount(doc, keyword); err != nil {
+			return nil, fmt.Errorf("schema at %q: %w", path, err)
+		}
+	}
+	for keyword, limit := range map[string]**float64{"minimum": &s.minimum, "maximum": &s.maximum} {
+		if v, found := doc[keyword]; found {
+			n, ok := v.(float64)
+			if !ok {
+				return nil, fmt.Errorf("schema at %q: %s must be a number", path, keyword)
+			}
+			*limit = &n
+		}
+	}
+	if pattern, found := doc["pattern"]; found {
+		p, ok := pattern.(string)
+		if !ok {
+			return nil, fmt.Errorf("schema at %q: pattern must be a string", path)
+		}
+		if s.pattern, err = regexp.Compile(p); err != nil {
+			return nil, fmt.Errorf("schema at %q: invalid pattern: %w", path, err)
+		}
+	}
+	return s, nil
+}
+
+func compileTypes(raw any) ([]string, error) {
+	var names []any
+	switch t := raw.(type) {
+	case string:
+		names = []any{t}
+	case []any:
+		names = t
+	default:
+		return nil, errors.New("type must be a string or an array of strings")
+	}
+	var types []string
+	for _, name := range names {
+		n, ok := name.(string)
+		if !ok || !slices.Contains(knownTypes, n) {
+			return nil, fmt.Errorf("unknown type %v", name)
+		}
+		types = append(types, n)
+	}
+	return types, nil
+}
+
+func compileCount(doc map[string]any, keyword string) (*int, error) {
+	v, found := doc[keyword]
+	if !found {
+		return nil, nil
+	}
+	n, ok := v.(float64)
+	if !ok || n < 0 || n != math.Trunc(n) {
+		return nil, fmt.Errorf("%s must be a non-negative integer", keyword)
+	}
+	count := int(n)
+	return &count, nil
+}
+
+// Validate checks v, a value decoded by encoding/json into an any, and
+// returns up to 20 ways it fails the schema, or none if it is valid.
+func (s *Schema) Validate(v any) []Violation {
+	var violations []Violation
+	s.validate(v, "", &violations)
+	return violations
+}
+
+func (s *Schema) validate(v any, path string, violations *[]Violation) {
+	report := func(path, format string, args ...any) {
+		if len(*violations) < maxViolations {
+			*violations = append(*violations, Violation{Path: path, Message: fmt.Sprintf(format, args...)})
+		}
+	}
+	if s.reject {
+		report(path, "is not allowed")
+		return
+	}
+	if len(s.types) > 0 && !slices.ContainsFunc(s.types, func(t string) bool { return hasType(v, t) }) {
+		report(path, "must be of type %s", strings.Join(s.types, " or "))
+		return
+	}
+	if s.enum != nil && !slices.ContainsFunc(s.enum, func(e any) bool { return reflect.DeepEqual(e, v) }) {
+		allowed, _ := json.Marshal(s.enum)
+		report(path, "must be one of %s", allowed)
+	}
+	if s.hasConst && !reflect.DeepEqual(s.constant, v) {
+		want, _ := json.Marshal(s.constant)
+		report(path, "must be %s", want)
+	}
+
+	switch v := v.(type) {
+	case map[string]any:
+		for _, name := range s.required {
+			if _, found := v[name]; !found {
+				report(path+"/"+escape(name), "is required")
+			}
+		}
+		for _, name := range slices.Sorted(maps.Keys(v)) {
+			if prop, found := s.properties[name]; found {
+				prop.validate(v[name], path+"/"+escape(name), violations)
+			} else if s.additional != nil {
+				s.additional.validate(v[name], path+"/"+escape(name), violations)
+			}
+		}
+	case []any:
+		if s.minItems != nil && len(v) < *s.minItems {
+			report(path, "must have at least %d items", *s.minItems)
+		}
+		if s.maxItems != nil && len(v) > *s.maxItems {
+			report(path, "must have at most %d items", *s.maxItems)
+		}
+		if s.items != nil {
+			for i, item := range v {
+				s.items.validate(item, path+"/"+strconv.Itoa(i), violations)
+			}
+		}
+	case string:
+		length := utf8.RuneCountInString(v)
+		if s.minLength != nil && length < *s.minLength {
+			report(path, "must be at least %d characters long", *s.minLength)
+		}
+		if s.maxLength != nil && length > *s.maxLength {
+			report(path, "must be at most %d characters long", *s.maxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(v) {
+			report(path, "must match %q", s.pattern)
+		}
+	case float64:
+		if s.minimum != nil && v < *s.minimum {
+			report(path, "must be at least %v", *s.minimum)
+		}
+		if s.maximum != nil && v > *s.maximum {
+			report(path, "must be at most %v", *s.maximum)
+		}
+	}
+}
+
+func hasType(v any, t string) bool {
+	switch v := v.(type) {
+	case nil:
+		return t == "null"
+	case bool:
+		return t == "boolean"
+	case string:
+		return t == "string"
+	case float64:
+		return t == "number" || t == "integer" && v == math.Trunc(v)
+	case []any:
+		return t == "array"
+	case map[string]any:
+		return t == "object"
+	}
+	return false
+}
+
+// escape encodes name as a JSON Pointer reference token.
+func escape(name string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(name)
+}
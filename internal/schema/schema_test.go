@@ -0,0 +1,125 @@
+package schema
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const payrollSchema = `{
+	"type": "object",
+	"required": ["uuid", "event_type", "payload"],
+	"properties": {
+		"uuid": {"type": "string", "minLength": 1},
+		"event_type": {"const": "payroll.processed"},
+		"payload": {
+			"type": "object",
+			"required": ["company_uuid", "check_date"],
+			"properties": {
+				"company_uuid": {"type": "string", "pattern": "^[0-9a-f-]{36}$"},
+				"check_date": {"type": "string"},
+				"employee_count": {"type": "integer", "minimum": 0},
+				"status": {"enum": ["processed", "reversed"]},
+				"tags": {"type": "array", "maxItems": 2, "items": {"type": "string"}}
+			},
+			"additionalProperties": false
+		}
+	}
+}`
+
+func TestValidate(t *testing.T) {
+	s, err := Compile([]byte(payrollSchema))
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+
+	testCases := []struct {
+		name     string
+		event    string
+		expected []string
+	}{
+		{
+			name:  "Valid",
+			event: `{"uuid": "e1", "event_type": "payroll.processed", "payload": {"company_uuid": "00000000-0000-0000-0000-000000000000", "check_date": "2026-01-01", "employee_count": 3, "tags": ["a"]}}`,
+		},
+		{
+			name:     "Missing Fields",
+			event:    `{"uuid": "e1", "event_type": "payroll.processed", "payload": {}}`,
+			expected: []string{"/payload/company_uuid: is required", "/payload/check_date: is required"},
+		},
+		{
+			name:  "Wrong Values",
+			event: `{"uuid": "", "event_type": "payroll.cancelled", "payload": {"company_uuid": "acme", "check_date": 20260101, "employee_count": 1.5, "status": "pending", "tags": ["a", "b", 3], "extra": true}}`,
+			expected: []string{
+				`/event_type: must be "payroll.processed"`,
+				`/payload/check_date: must be of type string`,
+				`/payload/company_uuid: must match "^[0-9a-f-]{36}$"`,
+				`/payload/employee_count: must be of type integer`,
+				`/payload/extra: is not allowed`,
+				`/payload/status: must be one of ["processed","reversed"]`,
+				`/payload/tags: must have at most 2 items`,
+				`/payload/tags/2: must be of type string`,
+				`/uuid: must be at least 1 characters long`,
+			},
+		},
+		{name: "Not An Object", event: `[]`, expected: []string{"must be of type object"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var event any
+			if err := json.Unmarshal([]byte(tc.event), &event); err != nil {
+				t.Fatalf("decoding event: %v", err)
+			}
+			var got []string
+			for _, v := range s.Validate(event) {
+				got = append(got, v.String())
+			}
+			if strings.Join(got, "\n") != strings.Join(tc.expected, "\n") {
+				t.Errorf("incorrect violations:\ngot  %q\nwant %q", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestCompileRejectsInvalidSchemas(t *testing.T) {
+	for _, schema := range []string{
+		`"object"`,
+		`{"type": "map"}`,
+		`{"oneOf": [{"type": "string"}]}`,
+		`{"properties": {"payload": {"$ref": "#/$defs/payload"}}}`,
+		`{"minLength": -1}`,
+		`{"pattern": "("}`,
+	} {
+		if _, err := Compile([]byte(schema)); err == nil {
+			t.Errorf("expected an error compiling %s", schema)
+		}
+	}
+}
+
+func TestLoadDir(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "payroll.processed.json"), []byte(payrollSchema), 0o600)
+	os.WriteFile(filepath.Join(dir, "README.md"), []byte("not a schema"), 0o600)
+
+	set, err := LoadDir(dir)
+	if err != nil {
+		t.Fatalf("LoadDir failed: %v", err)
+	}
+	if types := set.EventTypes(); len(types) != 1 || types[0] != "payroll.processed" {
+		t.Errorf("incorrect event types: %v", types)
+	}
+	if violations, known := set.Validate("payroll.processed", map[string]any{}); !known || len(violations) != 3 {
+		t.Errorf("Validate = %v, %v, want 3 violations", violations, known)
+	}
+	if _, known := set.Validate("company.updated", map[string]any{}); known {
+		t.Errorf("Expected no schema for company.updated")
+	}
+
+	os.WriteFile(filepath.Join(dir, "company.updated.json"), []byte(`{"anyOf": []}`), 0o600)
+	if _, err := LoadDir(dir); err == nil || !strings.Contains(err.Error(), "company.updated.json") {
+		t.Errorf("expected an error naming the invalid schema, got %v", err)
+	}
+}
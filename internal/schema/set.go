@@ -0,0 +1,54 @@
+package schema
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Set holds a schema per event type.
+type Set struct {
+	schemas map[string]*Schema
+}
+
+// LoadDir compiles every .json file in dir as the schema of the event type
+// it is named after, e.g. payroll.processed.json for "payroll.processed".
+// Each schema describes a whole event, including its envelope.
+func LoadDir(dir string) (*Set, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("listing schemas: %w", err)
+	}
+	set := &Set{schemas: make(map[string]*Schema, len(paths))}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading schema: %w", err)
+		}
+		s, err := Compile(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", filepath.Base(path), err)
+		}
+		set.schemas[strings.TrimSuffix(filepath.Base(path), ".json")] = s
+	}
+	return set, nil
+}
+
+// EventTypes returns the event types with a schema, sorted.
+func (s *Set) EventTypes() []string {
+	return slices.Sorted(maps.Keys(s.schemas))
+}
+
+// Validate checks event, a decoded event of eventType, against its
+// schema. It reports false if the event type has none, in which case any
+// event passes.
+func (s *Set) Validate(eventType string, event any) ([]Violation, bool) {
+	schema, found := s.schemas[eventType]
+	if !found {
+		return nil, false
+	}
+	return schema.Validate(event), true
+}
@@ -1,18 +1,19 @@
 package setup
 
 import (
-	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
+	"gusto-webhook-guide/internal/apierror"
+	"gusto-webhook-guide/internal/subscriptions"
 	"log/slog"
 	"net/http"
 )
 
 // Handler contains dependencies for the setup handler.
 type Handler struct {
-	Logger   *slog.Logger
-	APIToken string
+	Logger        *slog.Logger
+	Subscriptions subscriptions.Service
 }
 
 // HandleWebhookSetup now ONLY creates the webhook subscription.
@@ -21,43 +22,28 @@ func (h *Handler) HandleWebhookSetup(w http.ResponseWriter, r *http.Request) {
 		URL string `json:"webhook_url"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid request body")
 		return
 	}
 	webhookURL := requestBody.URL
 	if webhookURL == "" {
-		http.Error(w, "webhook_url is required", http.StatusBadRequest)
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, "webhook_url is required")
 		return
 	}
 
 	h.Logger.Info("Step 1: Kicking off webhook subscription creation...", "url", webhookURL)
 
-	createURL := "https://api.gusto-demo.com/v1/webhook_subscriptions"
-	createBody := fmt.Sprintf(`{"url": "%s", "subscription_types": ["Company"]}`, webhookURL)
-	req, _ := http.NewRequest("POST", createURL, bytes.NewBufferString(createBody))
-	req.Header.Set("Authorization", "Bearer "+h.APIToken)
-	req.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Error creating subscription: %v", err), http.StatusInternalServerError)
+	sub, err := h.Subscriptions.Create(r.Context(), webhookURL, nil)
+	var apiErr *subscriptions.APIError
+	if errors.As(err, &apiErr) {
+		apierror.Write(w, r, apiErr.StatusCode, apierror.CodeUpstreamError, fmt.Sprintf("Failed to create subscription: %v", apiErr))
 		return
 	}
-	defer resp.Body.Close()
-
-	bodyBytes, _ := io.ReadAll(resp.Body)
-	if resp.StatusCode != http.StatusCreated {
-		msg := fmt.Sprintf("Failed to create subscription. Status: %s, Body: %s", resp.Status, string(bodyBytes))
-		http.Error(w, msg, resp.StatusCode)
+	if err != nil {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, fmt.Sprintf("Error creating subscription: %v", err))
 		return
 	}
 
-	var createResp struct {
-		UUID string `json:"uuid"`
-	}
-	json.Unmarshal(bodyBytes, &createResp)
-
-	h.Logger.Info("✅ Subscription created. Gusto is now sending the verification payload to your /webhooks endpoint. Check the logs below.", "uuid", createResp.UUID)
-	fmt.Fprintf(w, "Subscription created with UUID: %s. Check your server logs for the verification token from Gusto.", createResp.UUID)
-}
\ No newline at end of file
+	h.Logger.Info("✅ Subscription created. Gusto is now sending the verification payload to your /webhooks endpoint. Check the logs below.", "uuid", sub.UUID)
+	fmt.Fprintf(w, "Subscription created with UUID: %s. Check your server logs for the verification token from Gusto.", sub.UUID)
+}
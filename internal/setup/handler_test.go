@@ -0,0 +1,65 @@
+package setup
+
+import (
+	"context"
+	"errors"
+	"gusto-webhook-guide/internal/subscriptions"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// stubService returns err from Create, or a new subscription if it is nil.
+type stubService struct{ err error }
+
+func (s stubService) Create(_ context.Context, url string, _ []string) (subscriptions.Subscription, error) {
+	if s.err != nil {
+		return subscriptions.Subscription{}, s.err
+	}
+	return subscriptions.Subscription{UUID: "sub-1", URL: url}, nil
+}
+
+func (s stubService) List(context.Context) ([]subscriptions.Subscription, error) { return nil, nil }
+
+func (s stubService) Verify(context.Context, string, string) error { return nil }
+
+func TestHandleWebhookSetup(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+
+	testCases := []struct {
+		name               string
+		body               string
+		err                error
+		expectedStatusCode int
+	}{
+		{name: "Created", body: `{"webhook_url": "https://hooks.example.com/webhooks"}`, expectedStatusCode: http.StatusOK},
+		{name: "Missing URL", body: `{}`, expectedStatusCode: http.StatusBadRequest},
+		{name: "Invalid Body", body: `not json`, expectedStatusCode: http.StatusBadRequest},
+		{
+			name:               "Gusto Rejects",
+			body:               `{"webhook_url": "bad"}`,
+			err:                &subscriptions.APIError{StatusCode: http.StatusUnprocessableEntity, Message: "url is invalid"},
+			expectedStatusCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:               "Network Error",
+			body:               `{"webhook_url": "https://hooks.example.com/webhooks"}`,
+			err:                errors.New("connection refused"),
+			expectedStatusCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := &Handler{Logger: logger, Subscriptions: stubService{err: tc.err}}
+			rr := httptest.NewRecorder()
+			h.HandleWebhookSetup(rr, httptest.NewRequest("POST", "/admin/setup-webhook", strings.NewReader(tc.body)))
+			if rr.Code != tc.expectedStatusCode {
+				t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, tc.expectedStatusCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package subscriptions
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"gusto-webhook-guide/internal/providers/gusto"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Client is a Service backed by the Gusto API.
+type Client struct {
+	BaseURL  string
+	APIToken string
+	HTTP     *http.Client
+}
+
+var (
+	_ Service = (*Client)(nil)
+	_ Updater = (*Client)(nil)
+)
+
+// NewClient creates a Client for the Gusto demo API.
+func NewClient(apiToken string) *Client {
+	return &Client{
+		BaseURL:  gusto.DefaultBaseURL,
+		APIToken: apiToken,
+		HTTP:     &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+// Create implements Service.
+func (c *Client) Create(ctx context.Context, url string, types []string) (Subscription, error) {
+	if len(types) == 0 {
+		types = DefaultTypes
+	}
+	body, _ := json.Marshal(map[string]any{"url": url, "subscription_types": types})
+	var created Subscription
+	if err := c.do(ctx, "POST", "/v1/webhook_subscriptions", body, http.StatusCreated, &created); err != nil {
+		return Subscription{}, fmt.Errorf("creating subscription: %w", err)
+	}
+	if created.UUID == "" {
+		return Subscription{}, fmt.Errorf("creating subscription: %w: no uuid", ErrInvalidResponse)
+	}
+	return created, nil
+}
+
+// List implements Service.
+func (c *Client) List(ctx context.Context) ([]Subscription, error) {
+	var subs []Subscription
+	if err := c.do(ctx, "GET", "/v1/webhook_subscriptions", nil, http.StatusOK, &subs); err != nil {
+		return nil, fmt.Errorf("listing subscriptions: %w", err)
+	}
+	return subs, nil
+}
+
+// Verify implements Service.
+func (c *Client) Verify(ctx context.Context, uuid, token string) error {
+	body, _ := json.Marshal(map[string]string{"verification_token": token})
+	if err := c.do(ctx, "PUT", "/v1/webhook_subscriptions/"+uuid+"/verify", body, http.StatusOK, nil); err != nil {
+		return fmt.Errorf("verifying subscription: %w", err)
+	}
+	return nil
+}
+
+// Update implements Updater.
+func (c *Client) Update(ctx context.Context, uuid string, types []string) (Subscription, error) {
+	body, _ := json.Marshal(map[string]any{"subscription_types": types})
+	var updated Subscription
+	if err := c.do(ctx, "PUT", "/v1/webhook_subscriptions/"+uuid, body, http.StatusOK, &updated); err != nil {
+		return Subscription{}, fmt.Errorf("updating subscription: %w", err)
+	}
+	return updated, nil
+}
+
+// do sends a JSON request and decodes the response into out, if non-nil.
+// Unexpected statuses are returned as an *APIError.
+func (c *Client) do(ctx context.Context, method, path string, body []byte, wantStatus int, out any) error {
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.APIToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("reading response: %w", err)
+	}
+	if resp.StatusCode != wantStatus {
+		apiErr := &APIError{StatusCode: resp.StatusCode, Body: string(respBody)}
+		var gustoErr gusto.APIErrorResponse
+		if json.Unmarshal(respBody, &gustoErr) == nil && len(gustoErr.Errors) > 0 {
+			apiErr.Category = gustoErr.Errors[0].Category
+			apiErr.Message = gustoErr.Errors[0].Message
+		}
+		return apiErr
+	}
+	if out != nil {
+		if err := json.Unmarshal(respBody, out); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidResponse, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package subscriptions
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClient(t *testing.T) {
+	var verifiedToken string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer api-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/v1/webhook_subscriptions":
+			switch body["url"] {
+			case "https://hooks.example.com/webhooks":
+				w.WriteHeader(http.StatusCreated)
+				w.Write([]byte(`{"uuid":"sub-1","url":"https://hooks.example.com/webhooks","status":"unverified","subscription_types":["Company"]}`))
+			case "https://garbled.example.com/webhooks":
+				w.WriteHeader(http.StatusCreated)
+				w.Write([]byte(`not json`))
+			default:
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				w.Write([]byte(`{"errors":[{"category":"invalid_attribute_value","message":"url is invalid"}]}`))
+			}
+		case r.Method == "GET" && r.URL.Path == "/v1/webhook_subscriptions":
+			w.Write([]byte(`[{"uuid":"sub-1","url":"https://hooks.example.com/webhooks","status":"verified"}]`))
+		case r.Method == "PUT" && r.URL.Path == "/v1/webhook_subscriptions/sub-1/verify":
+			verifiedToken, _ = body["verification_token"].(string)
+			w.Write([]byte(`{"uuid":"sub-1","status":"verified"}`))
+		case r.Method == "PUT" && r.URL.Path == "/v1/webhook_subscriptions/sub-1":
+			types, _ := json.Marshal(body["subscription_types"])
+			w.Write([]byte(`{"uuid":"sub-1","status":"verified","subscription_types":` + string(types) + `}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`<html>not found</html>`))
+		}
+	}))
+	defer server.Close()
+
+	c := NewClient("api-token")
+	c.BaseURL = server.URL
+	ctx := context.Background()
+
+	sub, err := c.Create(ctx, "https://hooks.example.com/webhooks", nil)
+	if err != nil || sub.UUID != "sub-1" || sub.Verified() {
+		t.Fatalf("incorrect Create result: got %+v (err %v)", sub, err)
+	}
+	subs, err := c.List(ctx)
+	if err != nil || len(subs) != 1 || !subs[0].Verified() {
+		t.Fatalf("incorrect List result: got %+v (err %v)", subs, err)
+	}
+	if err := c.Verify(ctx, "sub-1", "token-1"); err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if verifiedToken != "token-1" {
+		t.Errorf("incorrect verification token sent: got %q want %q", verifiedToken, "token-1")
+	}
+	updated, err := c.Update(ctx, "sub-1", []string{"Company", "Payroll"})
+	if err != nil || len(updated.SubscriptionTypes) != 2 {
+		t.Fatalf("incorrect Update result: got %+v (err %v)", updated, err)
+	}
+
+	testCases := []struct {
+		name           string
+		call           func() error
+		expectedStatus int    // For an *APIError; zero otherwise.
+		expectedCat    string // Category of the *APIError.
+		expectInvalid  bool
+	}{
+		{
+			name:           "Described API Error",
+			call:           func() error { _, err := c.Create(ctx, "not-a-url", nil); return err },
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedCat:    "invalid_attribute_value",
+		},
+		{
+			name:           "Undescribed API Error",
+			call:           func() error { return c.Verify(ctx, "unknown", "token-1") },
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:          "Undecodable Response",
+			call:          func() error { _, err := c.Create(ctx, "https://garbled.example.com/webhooks", nil); return err },
+			expectInvalid: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.call()
+			var apiErr *APIError
+			if tc.expectedStatus != 0 {
+				if !errors.As(err, &apiErr) {
+					t.Fatalf("Expected an *APIError, got %v", err)
+				}
+				if apiErr.StatusCode != tc.expectedStatus || apiErr.Category != tc.expectedCat {
+					t.Errorf("incorrect APIError: got %d %q want %d %q", apiErr.StatusCode, apiErr.Category, tc.expectedStatus, tc.expectedCat)
+				}
+			}
+			if got := errors.Is(err, ErrInvalidResponse); got != tc.expectInvalid {
+				t.Errorf("invalid response classification: got %v want %v (err %v)", got, tc.expectInvalid, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package subscriptions
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"strings"
+)
+
+// TypeFor returns the subscription type that delivers eventType, e.g.
+// "Company" for "company.updated" and "CompanyBenefit" for
+// "company_benefit.created".
+func TypeFor(eventType string) string {
+	resource, _, _ := strings.Cut(eventType, ".")
+	var b strings.Builder
+	for _, word := range strings.Split(resource, "_") {
+		if word != "" {
+			b.WriteString(strings.ToUpper(word[:1]) + word[1:])
+		}
+	}
+	return b.String()
+}
+
+// Missing returns, sorted, the subscription types needed to receive
+// eventTypes that sub doesn't deliver.
+func Missing(sub Subscription, eventTypes []string) []string {
+	var missing []string
+	for _, eventType := range eventTypes {
+		t := TypeFor(eventType)
+		if !slices.Contains(sub.SubscriptionTypes, t) && !slices.Contains(missing, t) {
+			missing = append(missing, t)
+		}
+	}
+	slices.Sort(missing)
+	return missing
+}
+
+// EnsureTypes checks that sub delivers every event type in eventTypes, which
+// are those the service has handlers for. Missing types are logged as an
+// error, since their events never arrive; with expand set, they are also
+// added to the subscription if svc is an Updater. It returns the
+// subscription as it now stands.
+func EnsureTypes(ctx context.Context, svc Service, logger *slog.Logger, sub Subscription, eventTypes []string, expand bool) (Subscription, error) {
+	missing := Missing(sub, eventTypes)
+	if len(missing) == 0 {
+		return sub, nil
+	}
+	updater, canUpdate := svc.(Updater)
+	if !expand || !canUpdate {
+		logger.Error("Webhook subscription is missing types that have handlers; their events will never arrive",
+			"uuid", sub.UUID, "subscribed", sub.SubscriptionTypes, "missing", missing)
+		return sub, nil
+	}
+
+	updated, err := updater.Update(ctx, sub.UUID, append(slices.Clone(sub.SubscriptionTypes), missing...))
+	if err != nil {
+		return sub, err
+	}
+	logger.Warn("Added missing types to webhook subscription", "uuid", sub.UUID, "added", missing)
+	return updated, nil
+}
@@ -0,0 +1,65 @@
+package subscriptions
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+)
+
+// updatingService is a fakeService that can also update subscriptions.
+type updatingService struct {
+	fakeService
+	updated []string
+}
+
+func (u *updatingService) Update(_ context.Context, uuid string, types []string) (Subscription, error) {
+	u.updated = types
+	return Subscription{UUID: uuid, SubscriptionTypes: types}, nil
+}
+
+func TestTypeFor(t *testing.T) {
+	testCases := map[string]string{
+		"company.updated":         "Company",
+		"company_benefit.created": "CompanyBenefit",
+		"payroll":                 "Payroll",
+	}
+	for eventType, want := range testCases {
+		if got := TypeFor(eventType); got != want {
+			t.Errorf("incorrect type for %s: got %q want %q", eventType, got, want)
+		}
+	}
+}
+
+func TestEnsureTypes(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	sub := Subscription{UUID: "sub-1", SubscriptionTypes: []string{"Company"}}
+	handled := []string{"company.updated", "payroll.processed", "payroll.submitted", "employee.created"}
+
+	if got := Missing(sub, handled); !slices.Equal(got, []string{"Employee", "Payroll"}) {
+		t.Fatalf("incorrect missing types: got %v", got)
+	}
+
+	testCases := []struct {
+		name          string
+		svc           Service
+		expand        bool
+		expectedTypes []string
+	}{
+		{name: "Warn Only", svc: &updatingService{}, expectedTypes: []string{"Company"}},
+		{name: "Expand", svc: &updatingService{}, expand: true, expectedTypes: []string{"Company", "Employee", "Payroll"}},
+		{name: "Expand Unsupported", svc: &fakeService{}, expand: true, expectedTypes: []string{"Company"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := EnsureTypes(context.Background(), tc.svc, logger, sub, handled, tc.expand)
+			if err != nil {
+				t.Fatalf("EnsureTypes failed: %v", err)
+			}
+			if !slices.Equal(got.SubscriptionTypes, tc.expectedTypes) {
+				t.Errorf("incorrect subscription types: got %v want %v", got.SubscriptionTypes, tc.expectedTypes)
+			}
+		})
+	}
+}
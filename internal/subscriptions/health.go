@@ -0,0 +1,66 @@
+package subscriptions
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// HealthStatus is the last known health of the webhook subscription.
+type HealthStatus struct {
+	Healthy bool      `json:"healthy"`
+	Reason  string    `json:"reason,omitempty"` // Why it is unhealthy.
+	Since   time.Time `json:"since,omitzero"`   // When it last changed.
+}
+
+// HealthState tracks whether the webhook subscription is healthy, as last
+// reported by health checks and Gusto's notifications about it. It starts
+// out healthy.
+type HealthState struct {
+	mu     sync.Mutex
+	status HealthStatus
+}
+
+// NewHealthState creates a HealthState that is healthy.
+func NewHealthState() *HealthState {
+	subscriptionHealthy.Set(1)
+	return &HealthState{status: HealthStatus{Healthy: true}}
+}
+
+// Set records the subscription's health, with the reason if unhealthy. It
+// reports whether that changed it.
+func (h *HealthState) Set(healthy bool, reason string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if healthy {
+		reason = ""
+	}
+	if h.status.Healthy == healthy && h.status.Reason == reason {
+		return false
+	}
+	changed := h.status.Healthy != healthy
+	h.status = HealthStatus{Healthy: healthy, Reason: reason, Since: h.status.Since}
+	if changed {
+		h.status.Since = time.Now().UTC()
+	}
+	if healthy {
+		subscriptionHealthy.Set(1)
+	} else {
+		subscriptionHealthy.Set(0)
+	}
+	return changed
+}
+
+// Status returns the subscription's last known health.
+func (h *HealthState) Status() HealthStatus {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.status
+}
+
+// HandleStatus serves the subscription's last known health as JSON.
+func (h *HealthState) HandleStatus(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.Status())
+}
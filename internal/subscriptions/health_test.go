@@ -0,0 +1,29 @@
+package subscriptions
+
+import "testing"
+
+func TestHealthState(t *testing.T) {
+	h := NewHealthState()
+	if !h.Status().Healthy {
+		t.Fatalf("Expected a new health state to be healthy")
+	}
+	if h.Set(true, "") {
+		t.Errorf("Expected staying healthy not to be a change")
+	}
+	if !h.Set(false, "received webhook_subscription.removed") {
+		t.Errorf("Expected becoming unhealthy to be a change")
+	}
+	status := h.Status()
+	if status.Healthy || status.Reason != "received webhook_subscription.removed" || status.Since.IsZero() {
+		t.Errorf("unexpected status: %+v", status)
+	}
+	if h.Set(false, "no webhook subscription") {
+		t.Errorf("Expected a new reason alone not to be a change")
+	}
+	if h.Status().Reason != "no webhook subscription" {
+		t.Errorf("Expected the reason to be updated, got %q", h.Status().Reason)
+	}
+	if !h.Set(true, "") || h.Status().Reason != "" {
+		t.Errorf("Expected recovering to be a change that clears the reason")
+	}
+}
@@ -0,0 +1,11 @@
+package subscriptions
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var subscriptionHealthy = promauto.NewGauge(prometheus.GaugeOpts{
+	Name: "webhook_subscription_healthy",
+	Help: "1 while the webhook subscription is believed healthy, 0 after a failed health check or a notification such as its removal.",
+})
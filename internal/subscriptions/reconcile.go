@@ -0,0 +1,59 @@
+package subscriptions
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// Reconcile makes sure a subscription exists for url, creating one if
+// needed, and returns it. An existing subscription that is still unverified
+// is logged so the handshake can be finished; a newly created one is
+// verified when Gusto's verification payload arrives.
+func Reconcile(ctx context.Context, svc Service, logger *slog.Logger, url string) (Subscription, error) {
+	subs, err := svc.List(ctx)
+	if err != nil {
+		return Subscription{}, err
+	}
+	for _, sub := range subs {
+		if sub.URL != url {
+			continue
+		}
+		if sub.Verified() {
+			logger.Info("Webhook subscription is in place", "url", url, "uuid", sub.UUID)
+		} else {
+			logger.Warn("Webhook subscription exists but is not verified yet", "url", url, "uuid", sub.UUID, "status", sub.Status)
+		}
+		return sub, nil
+	}
+
+	sub, err := svc.Create(ctx, url, nil)
+	if err != nil {
+		return Subscription{}, err
+	}
+	logger.Info("Created missing webhook subscription; Gusto is sending the verification payload", "url", url, "uuid", sub.UUID)
+	return sub, nil
+}
+
+// CheckHealth returns an error unless Gusto has a verified subscription for
+// url that delivers the subscription types of eventTypes. Unlike Reconcile
+// it changes nothing, so it is cheap to run often.
+func CheckHealth(ctx context.Context, svc Service, url string, eventTypes []string) error {
+	subs, err := svc.List(ctx)
+	if err != nil {
+		return err
+	}
+	for _, sub := range subs {
+		if sub.URL != url {
+			continue
+		}
+		if !sub.Verified() {
+			return fmt.Errorf("webhook subscription %s for %s is %s", sub.UUID, url, sub.Status)
+		}
+		if missing := Missing(sub, eventTypes); len(missing) > 0 {
+			return fmt.Errorf("webhook subscription %s for %s is missing types %v", sub.UUID, url, missing)
+		}
+		return nil
+	}
+	return fmt.Errorf("no webhook subscription for %s", url)
+}
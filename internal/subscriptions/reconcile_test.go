@@ -0,0 +1,113 @@
+package subscriptions
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+)
+
+// fakeService is an in-memory Service.
+type fakeService struct {
+	subs    []Subscription
+	created int
+}
+
+func (f *fakeService) Create(_ context.Context, url string, types []string) (Subscription, error) {
+	f.created++
+	sub := Subscription{UUID: "new-uuid", URL: url, Status: "unverified", SubscriptionTypes: types}
+	f.subs = append(f.subs, sub)
+	return sub, nil
+}
+
+func (f *fakeService) List(context.Context) ([]Subscription, error) { return f.subs, nil }
+
+func (f *fakeService) Verify(context.Context, string, string) error { return nil }
+
+func TestReconcile(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	const url = "https://hooks.example.com/webhooks"
+
+	testCases := []struct {
+		name          string
+		existing      []Subscription
+		expectedUUID  string
+		expectCreated bool
+	}{
+		{
+			name:         "Verified Subscription Exists",
+			existing:     []Subscription{{UUID: "old-uuid", URL: url, Status: "verified"}},
+			expectedUUID: "old-uuid",
+		},
+		{
+			name:         "Unverified Subscription Exists",
+			existing:     []Subscription{{UUID: "old-uuid", URL: url, Status: "unverified"}},
+			expectedUUID: "old-uuid",
+		},
+		{
+			name:          "Only Other URLs",
+			existing:      []Subscription{{UUID: "other-uuid", URL: "https://elsewhere.example.com", Status: "verified"}},
+			expectedUUID:  "new-uuid",
+			expectCreated: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			svc := &fakeService{subs: tc.existing}
+			sub, err := Reconcile(context.Background(), svc, logger, url)
+			if err != nil {
+				t.Fatalf("Reconcile failed: %v", err)
+			}
+			if sub.UUID != tc.expectedUUID {
+				t.Errorf("incorrect subscription: got %q want %q", sub.UUID, tc.expectedUUID)
+			}
+			if created := svc.created > 0; created != tc.expectCreated {
+				t.Errorf("created: got %v want %v", created, tc.expectCreated)
+			}
+		})
+	}
+}
+
+func TestCheckHealth(t *testing.T) {
+	const url = "https://hooks.example.com/webhooks"
+	eventTypes := []string{"company.updated"}
+
+	testCases := []struct {
+		name        string
+		existing    []Subscription
+		expectError bool
+	}{
+		{
+			name:     "Healthy",
+			existing: []Subscription{{UUID: "a", URL: url, Status: "verified", SubscriptionTypes: []string{"Company"}}},
+		},
+		{
+			name:        "Missing",
+			existing:    []Subscription{{UUID: "a", URL: "https://other.example.com", Status: "verified", SubscriptionTypes: []string{"Company"}}},
+			expectError: true,
+		},
+		{
+			name:        "Unverified",
+			existing:    []Subscription{{UUID: "a", URL: url, Status: "unverified", SubscriptionTypes: []string{"Company"}}},
+			expectError: true,
+		},
+		{
+			name:        "Missing Types",
+			existing:    []Subscription{{UUID: "a", URL: url, Status: "verified", SubscriptionTypes: []string{"Employee"}}},
+			expectError: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			svc := &fakeService{subs: tc.existing}
+			err := CheckHealth(context.Background(), svc, url, eventTypes)
+			if (err != nil) != tc.expectError {
+				t.Errorf("incorrect result: got %v want error %v", err, tc.expectError)
+			}
+			if svc.created != 0 {
+				t.Error("CheckHealth created a subscription")
+			}
+		})
+	}
+}
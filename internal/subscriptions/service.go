@@ -0,0 +1,60 @@
+package subscriptions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// DefaultTypes are the subscription types created when none are given.
+var DefaultTypes = []string{"Company"}
+
+// ErrInvalidResponse is returned when a successful API response can't be
+// decoded.
+var ErrInvalidResponse = errors.New("invalid API response")
+
+// Subscription is a webhook subscription registered with Gusto.
+type Subscription struct {
+	UUID              string   `json:"uuid"`
+	URL               string   `json:"url"`
+	Status            string   `json:"status"` // e.g. "unverified" or "verified".
+	SubscriptionTypes []string `json:"subscription_types"`
+}
+
+// Verified reports whether the subscription has completed its handshake.
+func (s Subscription) Verified() bool {
+	return s.Status == "verified"
+}
+
+// Service manages webhook subscriptions.
+type Service interface {
+	// Create registers a subscription delivering the given types to url.
+	// Gusto then sends a verification payload to url.
+	Create(ctx context.Context, url string, types []string) (Subscription, error)
+	// List returns the existing subscriptions.
+	List(ctx context.Context) ([]Subscription, error)
+	// Verify completes the handshake with the token Gusto sent to the URL.
+	Verify(ctx context.Context, uuid, token string) error
+}
+
+// Updater is implemented by Services that can change the types an existing
+// subscription delivers.
+type Updater interface {
+	// Update replaces the subscription's types.
+	Update(ctx context.Context, uuid string, types []string) (Subscription, error)
+}
+
+// APIError is returned when Gusto responds with an unexpected status.
+type APIError struct {
+	StatusCode int
+	Category   string // From Gusto's error body, if it had one.
+	Message    string
+	Body       string // Raw response body, for errors Gusto didn't describe.
+}
+
+func (e *APIError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("Gusto API error (status %d, %s): %s", e.StatusCode, e.Category, e.Message)
+	}
+	return fmt.Sprintf("Gusto API error (status %d): %s", e.StatusCode, e.Body)
+}
@@ -0,0 +1,128 @@
+package tenants
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"gusto-webhook-guide/internal/apierror"
+	"gusto-webhook-guide/internal/middleware"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// redacted replaces the payload fields a tenant's Redaction hides.
+const redacted = "[REDACTED]"
+
+// Redaction is a tenant's policy for the event payloads shown to it.
+type Redaction struct {
+	// OmitPayload leaves payloads out altogether.
+	OmitPayload bool `json:"omit_payload,omitempty"`
+	// Fields are replaced with "[REDACTED]", written as dotted paths, e.g.
+	// "entity_uuid" or "data.ssn". A path through an array applies to
+	// each of its elements.
+	Fields []string `json:"fields,omitempty"`
+	// IncludeResults shows the tenant what processing its events produced.
+	// While any of the payload is hidden, only the kinds, IDs and actions of
+	// artifacts are shown, since derived records, errors and artifact URLs
+	// may carry copies of hidden fields.
+	IncludeResults bool `json:"include_results,omitempty"`
+}
+
+// Hides reports whether the policy hides any of a payload.
+func (p *Redaction) Hides() bool {
+	return p != nil && (p.OmitPayload || len(p.Fields) > 0)
+}
+
+// Apply returns body with the policy applied, or nil if the payload is
+// omitted. A body that isn't JSON is omitted too, since its fields can't
+// be redacted. A nil policy redacts nothing.
+func (p *Redaction) Apply(body []byte) json.RawMessage {
+	if len(body) == 0 || p != nil && p.OmitPayload {
+		return nil
+	}
+	var payload any
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil
+	}
+	if p == nil || len(p.Fields) == 0 {
+		return body
+	}
+	for _, field := range p.Fields {
+		redactPath(payload, strings.Split(field, "."))
+	}
+	out, err := json.Marshal(payload)
+	if err != nil {
+		return nil
+	}
+	return out
+}
+
+// redactPath replaces the value at path within v.
+func redactPath(v any, path []string) {
+	switch v := v.(type) {
+	case map[string]any:
+		child, found := v[path[0]]
+		if !found {
+			return
+		}
+		if len(path) == 1 {
+			v[path[0]] = redacted
+			return
+		}
+		redactPath(child, path[1:])
+	case []any:
+		for _, elem := range v {
+			redactPath(elem, path)
+		}
+	}
+}
+
+// MintAPIToken creates a new API token for the tenant with the given ID,
+// replacing any it had, and returns it. Only its hash is stored.
+func (r *Registry) MintAPIToken(id string) (string, error) {
+	raw := make([]byte, 32)
+	rand.Read(raw)
+	token := hex.EncodeToString(raw)
+	err := r.update(id, func(t *Tenant) { t.APITokenHash = hashAPIToken(token) })
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// SetRedaction sets the redaction policy of the tenant with the given ID.
+func (r *Registry) SetRedaction(id string, policy Redaction) error {
+	return r.update(id, func(t *Tenant) { t.Redaction = &policy })
+}
+
+func hashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// RequireAPIToken returns a middleware for routes with a {tenant} URL
+// parameter that only allows requests carrying "Authorization: Bearer
+// <token>" with that tenant's API token. Unknown tenants and tenants
+// without a token are refused the same way, so their existence isn't
+// revealed.
+func (r *Registry) RequireAPIToken(logger *slog.Logger) middleware.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			id := chi.URLParam(req, "tenant")
+			t, found := r.Get(id)
+			provided, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+			if !found || !ok || t.APITokenHash == "" ||
+				subtle.ConstantTimeCompare([]byte(hashAPIToken(provided)), []byte(t.APITokenHash)) != 1 {
+				logger.Warn("Rejected unauthenticated tenant API request", "tenant", id, "path", req.URL.Path, "remote_addr", req.RemoteAddr)
+				apierror.Write(w, req, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized")
+				return
+			}
+			next.ServeHTTP(w, req)
+		})
+	}
+}
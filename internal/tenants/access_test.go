@@ -0,0 +1,82 @@
+package tenants
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestRedactionApply(t *testing.T) {
+	body := []byte(`{"uuid":"1","entity_uuid":"e-1","data":{"ssn":"123","items":[{"ssn":"4"},{"ssn":"5"}]}}`)
+
+	testCases := []struct {
+		name     string
+		policy   *Redaction
+		expected string
+	}{
+		{name: "No Policy", expected: string(body)},
+		{name: "Omitted", policy: &Redaction{OmitPayload: true}},
+		{
+			name:     "Fields Redacted",
+			policy:   &Redaction{Fields: []string{"entity_uuid", "data.ssn", "data.items.ssn", "data.missing"}},
+			expected: `{"data":{"items":[{"ssn":"[REDACTED]"},{"ssn":"[REDACTED]"}],"ssn":"[REDACTED]"},"entity_uuid":"[REDACTED]","uuid":"1"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := string(tc.policy.Apply(body)); got != tc.expected {
+				t.Errorf("incorrect payload: got %s want %s", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestRequireAPIToken(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	registry, _ := OpenRegistry("")
+	registry.Put(Tenant{ID: "acme"})
+	registry.Put(Tenant{ID: "globex"})
+	if _, err := registry.MintAPIToken("missing"); !errors.Is(err, ErrUnknownTenant) {
+		t.Errorf("incorrect error minting for an unknown tenant: got %v want %v", err, ErrUnknownTenant)
+	}
+	token, err := registry.MintAPIToken("acme")
+	if err != nil {
+		t.Fatalf("MintAPIToken failed: %v", err)
+	}
+
+	router := chi.NewRouter()
+	router.With(registry.RequireAPIToken(logger)).Get("/tenants/{tenant}/events", func(w http.ResponseWriter, r *http.Request) {})
+
+	testCases := []struct {
+		name               string
+		tenant             string
+		token              string
+		expectedStatusCode int
+	}{
+		{name: "Own Token", tenant: "acme", token: token, expectedStatusCode: http.StatusOK},
+		{name: "Another Tenant's Token", tenant: "globex", token: token, expectedStatusCode: http.StatusUnauthorized},
+		{name: "Wrong Token", tenant: "acme", token: "nope", expectedStatusCode: http.StatusUnauthorized},
+		{name: "No Token", tenant: "acme", expectedStatusCode: http.StatusUnauthorized},
+		{name: "Unknown Tenant", tenant: "initech", token: token, expectedStatusCode: http.StatusUnauthorized},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/tenants/"+tc.tenant+"/events", nil)
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			if rr.Code != tc.expectedStatusCode {
+				t.Errorf("incorrect status: got %d want %d", rr.Code, tc.expectedStatusCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package tenants
+
+import (
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// LoggedEvent is an event delivered to a tenant's webhook route.
+type LoggedEvent struct {
+	UUID            string
+	EventType       string
+	FirstReceivedAt time.Time
+	LastReceivedAt  time.Time
+	Deliveries      int
+	LastDelivery    string // How the last delivery was answered: queued, filtered or rejected.
+	Body            []byte // The last delivery's body.
+}
+
+// EventLog remembers the events recently delivered to each tenant, so that
+// tenants can look up their own events. Its contents are lost on restart.
+type EventLog struct {
+	mu     sync.Mutex
+	max    int
+	events map[string][]*LoggedEvent // By tenant ID, oldest first.
+}
+
+// NewEventLog creates an EventLog remembering up to max events per tenant.
+// The oldest event is forgotten once the limit is reached.
+func NewEventLog(max int) *EventLog {
+	return &EventLog{max: max, events: make(map[string][]*LoggedEvent)}
+}
+
+// Record records a delivery of an event to the tenant named by the request's
+// {tenant} URL parameter. It is meant as a webhooks.Handler's Recorder.
+func (l *EventLog) Record(r *http.Request, eventUUID, eventType string, body []byte, status string) {
+	tenant := chi.URLParam(r, "tenant")
+	if l.max <= 0 || tenant == "" {
+		return
+	}
+	now := time.Now().UTC()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	events := l.events[tenant]
+	if i := slices.IndexFunc(events, func(e *LoggedEvent) bool { return e.UUID == eventUUID }); i >= 0 {
+		e := events[i]
+		e.LastReceivedAt = now
+		e.Deliveries++
+		e.LastDelivery = status
+		e.Body = body
+		return
+	}
+	if len(events) >= l.max {
+		events = events[1:]
+	}
+	l.events[tenant] = append(events, &LoggedEvent{
+		UUID:            eventUUID,
+		EventType:       eventType,
+		FirstReceivedAt: now,
+		LastReceivedAt:  now,
+		Deliveries:      1,
+		LastDelivery:    status,
+		Body:            body,
+	})
+}
+
+// List returns copies of the events logged for tenant, oldest first.
+func (l *EventLog) List(tenant string) []LoggedEvent {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	events := make([]LoggedEvent, len(l.events[tenant]))
+	for i, e := range l.events[tenant] {
+		events[i] = *e
+	}
+	return events
+}
+
+// Get returns a copy of the event with the given UUID, if it was logged for
+// tenant.
+func (l *EventLog) Get(tenant, eventUUID string) (LoggedEvent, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, e := range l.events[tenant] {
+		if e.UUID == eventUUID {
+			return *e, true
+		}
+	}
+	return LoggedEvent{}, false
+}
@@ -0,0 +1,40 @@
+package tenants
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestEventLog(t *testing.T) {
+	log := NewEventLog(2)
+	record := func(tenant, eventUUID, status string) {
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("tenant", tenant)
+		req := httptest.NewRequest("POST", "/webhooks/t/"+tenant, nil)
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		log.Record(req, eventUUID, "company.updated", []byte(`{}`), status)
+	}
+
+	record("acme", "1", "rejected")
+	record("acme", "1", "queued")
+	record("acme", "2", "queued")
+	record("acme", "3", "filtered")
+	record("globex", "4", "queued")
+
+	events := log.List("acme")
+	if len(events) != 2 || events[0].UUID != "2" || events[1].UUID != "3" {
+		t.Fatalf("incorrect events after eviction: %+v", events)
+	}
+	if _, found := log.Get("acme", "4"); found {
+		t.Error("expected another tenant's event to be hidden")
+	}
+
+	record("acme", "2", "rejected")
+	e, found := log.Get("acme", "2")
+	if !found || e.Deliveries != 2 || e.LastDelivery != "rejected" {
+		t.Errorf("incorrect redelivered event: %+v (found %v)", e, found)
+	}
+}
@@ -0,0 +1,276 @@
+package tenants
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gusto-webhook-guide/internal/middleware"
+	"gusto-webhook-guide/internal/subscriptions"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+var (
+	// ErrInvalidTenant is returned for tenant IDs that can't be used in a URL path.
+	ErrInvalidTenant = errors.New("tenant ID must be 1-63 lowercase letters, digits or dashes")
+	// ErrTenantExists is returned when provisioning a tenant that is already
+	// provisioned or being provisioned.
+	ErrTenantExists = errors.New("tenant already exists")
+	// ErrInvalidRegistration is returned when registering a tenant without
+	// a subscription UUID or secret.
+	ErrInvalidRegistration = errors.New("subscription_uuid and secret are required")
+	// ErrInvalidSignatureScheme is returned when registering a tenant with a
+	// signature scheme that isn't supported.
+	ErrInvalidSignatureScheme = errors.New("invalid signature scheme")
+	// ErrUnknownTenant is returned by the tenant Verifier for unprovisioned tenants.
+	ErrUnknownTenant = errors.New("unknown tenant")
+	// ErrRegistryReadOnly is returned when changing a read-only Registry.
+	ErrRegistryReadOnly = errors.New("tenant registry is read-only")
+)
+
+var tenantIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+// Provisioner onboards tenants in one step: it creates a subscription for
+// the tenant's /webhooks/t/{tenant} path, waits for the verification token
+// to arrive there, verifies the subscription and stores the token as the
+// tenant's signing secret.
+type Provisioner struct {
+	logger        *slog.Logger
+	registry      *Registry
+	subscriptions subscriptions.Service
+	baseURL       string
+	timeout       time.Duration
+
+	mu      sync.Mutex
+	pending map[string]struct{}    // Tenant IDs being provisioned.
+	tokens  map[string]chan string // Verification tokens by subscription ID.
+}
+
+// NewProvisioner creates a Provisioner. baseURL is this server's public URL
+// and timeout bounds the wait for the verification token.
+func NewProvisioner(logger *slog.Logger, registry *Registry, svc subscriptions.Service, baseURL string, timeout time.Duration) *Provisioner {
+	return &Provisioner{
+		logger:        logger,
+		registry:      registry,
+		subscriptions: svc,
+		baseURL:       strings.TrimRight(baseURL, "/"),
+		timeout:       timeout,
+		pending:       make(map[string]struct{}),
+		tokens:        make(map[string]chan string),
+	}
+}
+
+// Provision onboards the tenant with the given ID and returns it.
+func (p *Provisioner) Provision(ctx context.Context, id string) (Tenant, error) {
+	if !tenantIDPattern.MatchString(id) {
+		return Tenant{}, ErrInvalidTenant
+	}
+	if err := p.reserve(id); err != nil {
+		return Tenant{}, err
+	}
+	defer p.unreserve(id)
+
+	webhookURL := p.baseURL + "/webhooks/t/" + id
+	logger := p.logger.With("tenant", id)
+	logger.Info("Provisioning tenant webhook subscription", "url", webhookURL)
+
+	sub, err := p.subscriptions.Create(ctx, webhookURL, nil)
+	if err != nil {
+		return Tenant{}, err
+	}
+	subscriptionID := sub.UUID
+	logger = logger.With("webhook_subscription_uuid", subscriptionID)
+
+	token, err := p.waitForToken(ctx, subscriptionID)
+	if err != nil {
+		logger.Error("Verification token did not arrive; the subscription is left unverified", "error", err)
+		return Tenant{}, fmt.Errorf("waiting for verification token: %w", err)
+	}
+	if err := p.subscriptions.Verify(ctx, subscriptionID, token); err != nil {
+		return Tenant{}, err
+	}
+
+	t := Tenant{
+		ID:               id,
+		SubscriptionUUID: subscriptionID,
+		WebhookURL:       webhookURL,
+		Secret:           token,
+		CreatedAt:        time.Now().UTC(),
+	}
+	if err := p.registry.Put(t); err != nil {
+		return Tenant{}, fmt.Errorf("storing tenant: %w", err)
+	}
+	logger.Info("✅ Tenant provisioned")
+	return t, nil
+}
+
+// Registration is an existing subscription registered as a tenant, e.g.
+// one per environment or organization created outside this server.
+type Registration struct {
+	SubscriptionUUID string `json:"subscription_uuid"`
+	Secret           string `json:"secret"` // The subscription's verification token.
+	ForwardURL       string `json:"forward_url,omitempty"`
+	// Signature is how the subscription's webhooks are signed, if not as
+	// the tenant routes' Verifier expects by default.
+	Signature *middleware.SignatureScheme `json:"signature,omitempty"`
+}
+
+// Register adds the tenant with the given ID for an existing subscription,
+// or updates its subscription, secret, forwarding and signature scheme if it
+// exists, e.g. to rotate the secret. It reports whether the tenant was created. The
+// subscription must already deliver to the tenant's /webhooks/t/{tenant}
+// path.
+func (p *Provisioner) Register(id string, reg Registration) (Tenant, bool, error) {
+	if !tenantIDPattern.MatchString(id) {
+		return Tenant{}, false, ErrInvalidTenant
+	}
+	if reg.SubscriptionUUID == "" || reg.Secret == "" {
+		return Tenant{}, false, ErrInvalidRegistration
+	}
+	if reg.Signature != nil {
+		if err := reg.Signature.Validate(); err != nil {
+			return Tenant{}, false, fmt.Errorf("%w: %v", ErrInvalidSignatureScheme, err)
+		}
+	}
+	// Holding p.mu keeps Provision from reserving the ID meanwhile.
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, provisioning := p.pending[id]; provisioning {
+		return Tenant{}, false, ErrTenantExists
+	}
+	t, found := p.registry.Get(id)
+	if !found {
+		t = Tenant{ID: id, WebhookURL: p.baseURL + "/webhooks/t/" + id, CreatedAt: time.Now().UTC()}
+	}
+	t.SubscriptionUUID, t.Secret, t.ForwardURL, t.Signature = reg.SubscriptionUUID, reg.Secret, reg.ForwardURL, reg.Signature
+	if err := p.registry.Put(t); err != nil {
+		return Tenant{}, false, fmt.Errorf("storing tenant: %w", err)
+	}
+	p.logger.Info("✅ Tenant registered", "tenant", id, "webhook_subscription_uuid", t.SubscriptionUUID,
+		"created", !found, "forward_url", t.ForwardURL)
+	return t, !found, nil
+}
+
+// Deliver hands over a verification token received for a subscription. It
+// is meant as the callback of the tenant routes' verification handler.
+// Tokens arriving while nothing is being provisioned are ignored.
+func (p *Provisioner) Deliver(subscriptionID, token string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.pending) == 0 {
+		p.logger.Warn("Ignoring verification token received outside of provisioning", "webhook_subscription_uuid", subscriptionID)
+		return
+	}
+	select {
+	case p.tokenChan(subscriptionID) <- token:
+	default: // Already delivered.
+	}
+}
+
+// waitForToken waits for Deliver to be called for subscriptionID. The token
+// may arrive before Subscribe has even returned.
+func (p *Provisioner) waitForToken(ctx context.Context, subscriptionID string) (string, error) {
+	p.mu.Lock()
+	ch := p.tokenChan(subscriptionID)
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		delete(p.tokens, subscriptionID)
+		p.mu.Unlock()
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+	select {
+	case token := <-ch:
+		return token, nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// tokenChan returns the channel for subscriptionID's token, creating it if
+// needed. p.mu must be held.
+func (p *Provisioner) tokenChan(subscriptionID string) chan string {
+	ch, found := p.tokens[subscriptionID]
+	if !found {
+		ch = make(chan string, 1)
+		p.tokens[subscriptionID] = ch
+	}
+	return ch
+}
+
+// reserve marks id as being provisioned, failing if it already exists.
+func (p *Provisioner) reserve(id string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, found := p.pending[id]; found {
+		return ErrTenantExists
+	}
+	if _, found := p.registry.Get(id); found {
+		return ErrTenantExists
+	}
+	p.pending[id] = struct{}{}
+	return nil
+}
+
+func (p *Provisioner) unreserve(id string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.pending, id)
+	if len(p.pending) == 0 {
+		clear(p.tokens) // Drop tokens nobody is waiting for.
+	}
+}
+
+// Verifier returns a Verifier for routes with a {tenant} URL parameter that
+// checks each request with that tenant's secret, built by newVerifier, or
+// by the tenant's own signature scheme if it has one.
+// While a tenant is being provisioned, only its verification payload is
+// let through unsigned, so that the handshake can complete; anything else
+// is rejected as unauthorized.
+func (p *Provisioner) Verifier(newVerifier func(secret string) middleware.Verifier) middleware.Verifier {
+	return tenantVerifier{p: p, newVerifier: newVerifier}
+}
+
+type tenantVerifier struct {
+	p           *Provisioner
+	newVerifier func(secret string) middleware.Verifier
+}
+
+// Verify implements middleware.Verifier.
+func (v tenantVerifier) Verify(r *http.Request, body []byte) error {
+	id := chi.URLParam(r, "tenant")
+	if t, found := v.p.registry.Get(id); found {
+		if t.Signature != nil {
+			return t.Signature.Verifier(t.Secret).Verify(r, body)
+		}
+		return v.newVerifier(t.Secret).Verify(r, body)
+	}
+	v.p.mu.Lock()
+	_, provisioning := v.p.pending[id]
+	v.p.mu.Unlock()
+	if !provisioning {
+		return fmt.Errorf("%w %q", ErrUnknownTenant, id)
+	}
+	if !isVerificationPayload(body) {
+		return fmt.Errorf("%w: tenant %q is being provisioned", middleware.ErrAwaitingSecret, id)
+	}
+	return middleware.ErrNoSecret
+}
+
+// isVerificationPayload reports whether body is a subscription's
+// verification payload, a JSON object with a verification_token.
+func isVerificationPayload(body []byte) bool {
+	var payload struct {
+		VerificationToken string `json:"verification_token"`
+	}
+	return json.Unmarshal(body, &payload) == nil && payload.VerificationToken != ""
+}
@@ -0,0 +1,233 @@
+package tenants
+
+import (
+	"cmp"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"gusto-webhook-guide/internal/middleware"
+	"gusto-webhook-guide/internal/subscriptions"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// fakeSubscriber stands in for the provider. If deliver is set, it sends the
+// verification token to it before Create returns, as Gusto may.
+type fakeSubscriber struct {
+	deliver   func(subscriptionID, token string)
+	url       string
+	verified  string
+	verifyErr error
+}
+
+func (f *fakeSubscriber) Create(_ context.Context, url string, _ []string) (subscriptions.Subscription, error) {
+	f.url = url
+	if f.deliver != nil {
+		f.deliver("sub-1", "token-1")
+	}
+	return subscriptions.Subscription{UUID: "sub-1", URL: url}, nil
+}
+
+func (f *fakeSubscriber) List(context.Context) ([]subscriptions.Subscription, error) { return nil, nil }
+
+func (f *fakeSubscriber) Verify(_ context.Context, subscriptionID, token string) error {
+	f.verified = subscriptionID + ":" + token
+	return f.verifyErr
+}
+
+func newTestProvisioner(t *testing.T, subscriber subscriptions.Service) *Provisioner {
+	t.Helper()
+	registry, _ := OpenRegistry("")
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	return NewProvisioner(logger, registry, subscriber, "https://hooks.example.com/", 50*time.Millisecond)
+}
+
+func TestProvision(t *testing.T) {
+	subscriber := &fakeSubscriber{}
+	p := newTestProvisioner(t, subscriber)
+	subscriber.deliver = p.Deliver
+
+	tenant, err := p.Provision(context.Background(), "acme")
+	if err != nil {
+		t.Fatalf("Provision failed: %v", err)
+	}
+	if want := "https://hooks.example.com/webhooks/t/acme"; subscriber.url != want || tenant.WebhookURL != want {
+		t.Errorf("incorrect webhook URL: got %q want %q", subscriber.url, want)
+	}
+	if subscriber.verified != "sub-1:token-1" {
+		t.Errorf("incorrect verification: got %q want %q", subscriber.verified, "sub-1:token-1")
+	}
+	if stored, _ := p.registry.Get("acme"); stored.Secret != "token-1" {
+		t.Errorf("incorrect stored secret: got %q want %q", stored.Secret, "token-1")
+	}
+
+	if _, err := p.Provision(context.Background(), "acme"); !errors.Is(err, ErrTenantExists) {
+		t.Errorf("incorrect error for existing tenant: got %v want %v", err, ErrTenantExists)
+	}
+}
+
+func TestProvisionFailures(t *testing.T) {
+	testCases := []struct {
+		name        string
+		tenant      string
+		deliver     bool
+		verifyErr   error
+		expectedErr error
+	}{
+		{name: "Invalid Tenant ID", tenant: "Acme/Corp", expectedErr: ErrInvalidTenant},
+		{name: "Token Never Arrives", tenant: "acme", expectedErr: context.DeadlineExceeded},
+		{name: "Verification Rejected", tenant: "acme", deliver: true, verifyErr: errors.New("invalid token")},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			subscriber := &fakeSubscriber{verifyErr: tc.verifyErr}
+			p := newTestProvisioner(t, subscriber)
+			if tc.deliver {
+				subscriber.deliver = p.Deliver
+			}
+
+			_, err := p.Provision(context.Background(), tc.tenant)
+			if err == nil {
+				t.Fatalf("Expected Provision to fail")
+			}
+			if tc.expectedErr != nil && !errors.Is(err, tc.expectedErr) {
+				t.Errorf("incorrect error: got %v want %v", err, tc.expectedErr)
+			}
+			if _, found := p.registry.Get(tc.tenant); found {
+				t.Errorf("Expected failed tenant not to be stored")
+			}
+		})
+	}
+}
+
+func TestTenantVerifier(t *testing.T) {
+	p := newTestProvisioner(t, &fakeSubscriber{})
+	p.registry.Put(Tenant{ID: "acme", Secret: "acme-secret"})
+	sandbox := middleware.SignatureScheme{Header: "X-Sandbox-Signature", Algorithm: "sha512", Encoding: "base64"}
+	p.registry.Put(Tenant{ID: "sandbox", Secret: "sandbox-secret", Signature: &sandbox})
+	p.pending["onboarding"] = struct{}{}
+
+	newVerifier := func(secret string) middleware.Verifier {
+		return middleware.HMACVerifier{Header: "X-Signature", Secret: secret}
+	}
+	var reached bool
+	router := chi.NewRouter()
+	router.Route("/webhooks/t/{tenant}", func(r chi.Router) {
+		r.Use(middleware.Verify(slog.New(slog.NewJSONHandler(io.Discard, nil)), p.Verifier(newVerifier)))
+		r.Post("/", func(w http.ResponseWriter, r *http.Request) { reached = true })
+	})
+
+	body := `{"event_type":"company.updated"}`
+	sign := func(secret string) string {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(body))
+		return hex.EncodeToString(mac.Sum(nil))
+	}
+
+	testCases := []struct {
+		name               string
+		tenant             string
+		header             string // X-Signature if empty.
+		signature          string
+		body               string // The event body if empty.
+		expectedStatusCode int
+	}{
+		{name: "Signed With Tenant Secret", tenant: "acme", signature: sign("acme-secret"), expectedStatusCode: http.StatusOK},
+		{name: "Signed With Another Secret", tenant: "acme", signature: sign("other-secret"), expectedStatusCode: http.StatusForbidden},
+		{
+			name:               "Verification Payload While Provisioning",
+			tenant:             "onboarding",
+			body:               `{"verification_token":"token-1","webhook_subscription_uuid":"sub-1"}`,
+			expectedStatusCode: http.StatusOK,
+		},
+		{name: "Unsigned Event While Provisioning", tenant: "onboarding", expectedStatusCode: http.StatusUnauthorized},
+		{name: "Signed Event While Provisioning", tenant: "onboarding", signature: sign("acme-secret"), expectedStatusCode: http.StatusUnauthorized},
+		{name: "Unknown Tenant", tenant: "nobody", signature: sign("acme-secret"), expectedStatusCode: http.StatusForbidden},
+		{
+			name:               "Signed With Tenant Scheme",
+			tenant:             "sandbox",
+			header:             sandbox.Header,
+			signature:          sandbox.Sign("sandbox-secret", []byte(body)),
+			expectedStatusCode: http.StatusOK,
+		},
+		{name: "Signed With Default Scheme", tenant: "sandbox", signature: sign("sandbox-secret"), expectedStatusCode: http.StatusForbidden},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			reached = false
+			req := httptest.NewRequest("POST", "/webhooks/t/"+tc.tenant+"/", strings.NewReader(cmp.Or(tc.body, body)))
+			if tc.signature != "" {
+				req.Header.Set(cmp.Or(tc.header, "X-Signature"), tc.signature)
+			}
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			if rr.Code != tc.expectedStatusCode {
+				t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, tc.expectedStatusCode)
+			}
+			if reached != (tc.expectedStatusCode == http.StatusOK) {
+				t.Errorf("incorrect handling: handler reached %v for status %d", reached, rr.Code)
+			}
+		})
+	}
+}
+
+func TestRegister(t *testing.T) {
+	p := newTestProvisioner(t, &fakeSubscriber{})
+
+	tenant, created, err := p.Register("staging", Registration{SubscriptionUUID: "sub-1", Secret: "secret-1"})
+	if err != nil || !created {
+		t.Fatalf("Register failed: created=%v err=%v", created, err)
+	}
+	if want := "https://hooks.example.com/webhooks/t/staging"; tenant.WebhookURL != want {
+		t.Errorf("incorrect webhook URL: got %q want %q", tenant.WebhookURL, want)
+	}
+
+	// Registering again updates the secret and forwarding, keeping the rest.
+	p.registry.SetRedaction("staging", Redaction{OmitPayload: true})
+	updated, created, err := p.Register("staging", Registration{SubscriptionUUID: "sub-1", Secret: "secret-2", ForwardURL: "https://staging.example.com/hooks"})
+	if err != nil || created {
+		t.Fatalf("Register failed: created=%v err=%v", created, err)
+	}
+	if updated.Secret != "secret-2" || updated.ForwardURL != "https://staging.example.com/hooks" {
+		t.Errorf("incorrect updated tenant: %+v", updated)
+	}
+	if !updated.CreatedAt.Equal(tenant.CreatedAt) || updated.Redaction == nil {
+		t.Errorf("Expected the creation time and redaction policy to be kept: %+v", updated)
+	}
+
+	p.pending["onboarding"] = struct{}{}
+	testCases := []struct {
+		name        string
+		tenant      string
+		reg         Registration
+		expectedErr error
+	}{
+		{name: "Invalid Tenant ID", tenant: "Prod/EU", reg: Registration{SubscriptionUUID: "sub-2", Secret: "s"}, expectedErr: ErrInvalidTenant},
+		{name: "Missing Secret", tenant: "prod", reg: Registration{SubscriptionUUID: "sub-2"}, expectedErr: ErrInvalidRegistration},
+		{name: "Being Provisioned", tenant: "onboarding", reg: Registration{SubscriptionUUID: "sub-2", Secret: "s"}, expectedErr: ErrTenantExists},
+		{
+			name:        "Unsupported Signature Scheme",
+			tenant:      "prod",
+			reg:         Registration{SubscriptionUUID: "sub-2", Secret: "s", Signature: &middleware.SignatureScheme{Header: "X-Signature", Algorithm: "md5"}},
+			expectedErr: ErrInvalidSignatureScheme,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, _, err := p.Register(tc.tenant, tc.reg); !errors.Is(err, tc.expectedErr) {
+				t.Errorf("incorrect error: got %v want %v", err, tc.expectedErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package tenants
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gusto-webhook-guide/internal/middleware"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Tenant is a provisioned tenant with its own Gusto subscription and
+// signing secret.
+type Tenant struct {
+	ID               string    `json:"id"`
+	SubscriptionUUID string    `json:"subscription_uuid"`
+	WebhookURL       string    `json:"webhook_url"`
+	Secret           string    `json:"secret"` // The subscription's verification token.
+	CreatedAt        time.Time `json:"created_at"`
+	// APITokenHash is the SHA-256 of the token the tenant reads its own
+	// events with, or empty if it has none.
+	APITokenHash string     `json:"api_token_hash,omitempty"`
+	Redaction    *Redaction `json:"redaction,omitempty"` // Nil shows the tenant full payloads.
+	// ForwardURL is where the tenant's verified events are passed through
+	// to instead of being processed, or empty to process them.
+	ForwardURL string `json:"forward_url,omitempty"`
+	// Signature is how the tenant's webhooks are signed, or nil for the
+	// provider's default.
+	Signature *middleware.SignatureScheme `json:"signature,omitempty"`
+}
+
+// Registry holds provisioned tenants. With a path it is persisted to a JSON
+// file on every change, so secrets survive restarts.
+type Registry struct {
+	mu       sync.RWMutex
+	path     string
+	tenants  map[string]Tenant
+	readOnly bool
+}
+
+// OpenRegistry creates a Registry backed by path, loading any tenants
+// already saved there. An empty path keeps tenants in memory only.
+func OpenRegistry(path string) (*Registry, error) {
+	r := &Registry{path: path, tenants: make(map[string]Tenant)}
+	if path == "" {
+		return r, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading tenant registry: %w", err)
+	}
+	if err := json.Unmarshal(data, &r.tenants); err != nil {
+		return nil, fmt.Errorf("decoding tenant registry: %w", err)
+	}
+	return r, nil
+}
+
+// Get returns the tenant with the given ID.
+func (r *Registry) Get(id string) (Tenant, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	t, found := r.tenants[id]
+	return t, found
+}
+
+// List returns every tenant, in no particular order.
+func (r *Registry) List() []Tenant {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return slices.Collect(maps.Values(r.tenants))
+}
+
+// SetReadOnly sets whether the registry refuses changes, with
+// ErrRegistryReadOnly, so that it leaves its file to another process, e.g.
+// one being handed the listeners.
+func (r *Registry) SetReadOnly(readOnly bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.readOnly = readOnly
+}
+
+// Put adds or replaces a tenant.
+func (r *Registry) Put(t Tenant) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	prev, existed := r.tenants[t.ID]
+	r.tenants[t.ID] = t
+	if err := r.save(); err != nil {
+		if existed {
+			r.tenants[t.ID] = prev
+		} else {
+			delete(r.tenants, t.ID)
+		}
+		return err
+	}
+	return nil
+}
+
+// update applies fn to the tenant with the given ID and saves the result.
+func (r *Registry) update(id string, fn func(*Tenant)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	prev, found := r.tenants[id]
+	if !found {
+		return ErrUnknownTenant
+	}
+	t := prev
+	fn(&t)
+	r.tenants[id] = t
+	if err := r.save(); err != nil {
+		r.tenants[id] = prev
+		return err
+	}
+	return nil
+}
+
+// save writes the registry to its file, replacing it atomically. The file
+// holds signing secrets, so it is only readable by its owner. r.mu must be
+// held.
+func (r *Registry) save() error {
+	if r.readOnly {
+		return ErrRegistryReadOnly
+	}
+	if r.path == "" {
+		return nil
+	}
+	data, err := json.Marshal(r.tenants)
+	if err != nil {
+		return fmt.Errorf("encoding tenant registry: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(r.path), filepath.Base(r.path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("creating tenant registry file: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // No-op once renamed.
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing tenant registry file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing tenant registry file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), r.path); err != nil {
+		return fmt.Errorf("replacing tenant registry file: %w", err)
+	}
+	return nil
+}
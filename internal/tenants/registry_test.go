@@ -0,0 +1,80 @@
+package tenants
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRegistryPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tenants.json")
+	registry, err := OpenRegistry(path)
+	if err != nil {
+		t.Fatalf("OpenRegistry failed: %v", err)
+	}
+	want := Tenant{ID: "acme", SubscriptionUUID: "sub-1", Secret: "s3cret", CreatedAt: time.Now().UTC().Truncate(time.Second)}
+	if err := registry.Put(want); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("registry file not written: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("incorrect file permissions: got %v want %v", perm, os.FileMode(0o600))
+	}
+
+	reopened, err := OpenRegistry(path)
+	if err != nil {
+		t.Fatalf("OpenRegistry failed: %v", err)
+	}
+	got, found := reopened.Get("acme")
+	if !found || got != want {
+		t.Errorf("incorrect tenant after reopening: got %+v want %+v", got, want)
+	}
+	if _, found := reopened.Get("other"); found {
+		t.Errorf("Expected unknown tenant to be absent")
+	}
+}
+
+func TestOpenRegistryMissingFile(t *testing.T) {
+	registry, err := OpenRegistry(filepath.Join(t.TempDir(), "missing.json"))
+	if err != nil {
+		t.Fatalf("OpenRegistry failed: %v", err)
+	}
+	if _, found := registry.Get("acme"); found {
+		t.Errorf("Expected empty registry")
+	}
+}
+
+func TestRegistryReadOnly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tenants.json")
+	registry, _ := OpenRegistry(path)
+	registry.Put(Tenant{ID: "acme", Secret: "s3cret"})
+
+	registry.SetReadOnly(true)
+	if err := registry.Put(Tenant{ID: "globex"}); !errors.Is(err, ErrRegistryReadOnly) {
+		t.Errorf("incorrect Put error: got %v want %v", err, ErrRegistryReadOnly)
+	}
+	if _, err := registry.MintAPIToken("acme"); !errors.Is(err, ErrRegistryReadOnly) {
+		t.Errorf("incorrect MintAPIToken error: got %v want %v", err, ErrRegistryReadOnly)
+	}
+	if _, found := registry.Get("globex"); found {
+		t.Errorf("Expected a refused tenant to be absent")
+	}
+	if got, _ := registry.Get("acme"); got.APITokenHash != "" {
+		t.Errorf("Expected a refused change to be undone, got %+v", got)
+	}
+	reopened, _ := OpenRegistry(path)
+	if got := len(reopened.List()); got != 1 {
+		t.Errorf("incorrect number of saved tenants: got %d want 1", got)
+	}
+
+	registry.SetReadOnly(false)
+	if err := registry.Put(Tenant{ID: "globex"}); err != nil {
+		t.Errorf("Put failed once writable again: %v", err)
+	}
+}
@@ -0,0 +1,20 @@
+package tenants
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Dispatch returns a handler for routes with a {tenant} URL parameter that
+// resolves each request's downstream from the tenant's config: tenants with
+// a ForwardURL are served by forward(ForwardURL), the rest by process.
+func (r *Registry) Dispatch(process http.Handler, forward func(upstream string) http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if t, found := r.Get(chi.URLParam(req, "tenant")); found && t.ForwardURL != "" {
+			forward(t.ForwardURL).ServeHTTP(w, req)
+			return
+		}
+		process.ServeHTTP(w, req)
+	})
+}
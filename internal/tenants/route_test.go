@@ -0,0 +1,41 @@
+package tenants
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestDispatch(t *testing.T) {
+	registry, _ := OpenRegistry("")
+	registry.Put(Tenant{ID: "prod", Secret: "s"})
+	registry.Put(Tenant{ID: "staging", Secret: "s", ForwardURL: "https://staging.example.com/hooks"})
+
+	var served string
+	process := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { served = "process" })
+	forward := func(upstream string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { served = "forward " + upstream })
+	}
+	router := chi.NewRouter()
+	router.Handle("/webhooks/t/{tenant}", registry.Dispatch(process, forward))
+
+	testCases := []struct {
+		tenant string
+		served string
+	}{
+		{tenant: "prod", served: "process"},
+		{tenant: "staging", served: "forward https://staging.example.com/hooks"},
+		{tenant: "unknown", served: "process"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.tenant, func(t *testing.T) {
+			served = ""
+			router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/webhooks/t/"+tc.tenant, nil))
+			if served != tc.served {
+				t.Errorf("incorrect handler: got %q want %q", served, tc.served)
+			}
+		})
+	}
+}
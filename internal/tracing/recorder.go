@@ -0,0 +1,122 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var slowRequests = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "webhook_slow_requests_total",
+	Help: "Webhook requests that took longer than the latency threshold to answer.",
+})
+
+// Recorder traces requests and keeps the last N that took longer than a
+// latency threshold, to diagnose sporadic slow acknowledgements that make a
+// provider retry.
+type Recorder struct {
+	logger    *slog.Logger
+	threshold time.Duration
+
+	mu     sync.Mutex
+	traces []Trace
+	next   int // Index the next trace will be written to.
+	full   bool
+}
+
+// NewRecorder creates a Recorder keeping up to size traces of requests
+// slower than threshold.
+func NewRecorder(logger *slog.Logger, threshold time.Duration, size int) *Recorder {
+	return &Recorder{logger: logger, threshold: threshold, traces: make([]Trace, size)}
+}
+
+// Add stores a trace, evicting the oldest one if the recorder is full.
+func (rec *Recorder) Add(t Trace) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if len(rec.traces) == 0 {
+		return
+	}
+	rec.traces[rec.next] = t
+	rec.next = (rec.next + 1) % len(rec.traces)
+	if rec.next == 0 {
+		rec.full = true
+	}
+}
+
+// Traces returns the recorded traces, newest first.
+func (rec *Recorder) Traces() []Trace {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	n := rec.next
+	if rec.full {
+		n = len(rec.traces)
+	}
+	out := make([]Trace, 0, n)
+	for i := 1; i <= n; i++ {
+		out = append(out, rec.traces[(rec.next-i+len(rec.traces))%len(rec.traces)])
+	}
+	return out
+}
+
+// Middleware traces each request, letting later handlers time their stages
+// with StartStage, and records it if it was slow. It should run first so
+// the time spent in other middleware counts too.
+func (rec *Recorder) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a := &active{start: time.Now()}
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r.WithContext(context.WithValue(r.Context(), traceKey{}, a)))
+
+		elapsed := time.Since(a.start)
+		if elapsed < rec.threshold {
+			return
+		}
+		a.mu.Lock()
+		stages := append([]Stage{}, a.stages...)
+		a.mu.Unlock()
+
+		slowRequests.Inc()
+		rec.logger.Warn("Slow webhook request", "path", r.URL.Path, "status", sw.status, "duration", elapsed, "threshold", rec.threshold)
+		rec.Add(Trace{
+			Method:     r.Method,
+			Path:       r.URL.Path,
+			RemoteAddr: r.RemoteAddr,
+			StartedAt:  a.start.UTC(),
+			DurationMs: millis(elapsed),
+			Status:     sw.status,
+			Stages:     stages,
+		})
+	})
+}
+
+// HandleList serves the recorded slow request traces, newest first.
+func (rec *Recorder) HandleList(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		ThresholdMs float64 `json:"threshold_ms"`
+		Traces      []Trace `json:"traces"`
+	}{millis(rec.threshold), rec.Traces()})
+}
+
+// statusWriter records the status code written to a response.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap returns the underlying ResponseWriter, for http.ResponseController.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
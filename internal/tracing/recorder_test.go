@@ -0,0 +1,79 @@
+package tracing
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecorderOrder(t *testing.T) {
+	rec := NewRecorder(slog.New(slog.NewJSONHandler(io.Discard, nil)), time.Second, 3)
+	for _, path := range []string{"/a", "/b", "/c", "/d"} {
+		rec.Add(Trace{Path: path})
+	}
+
+	// The oldest trace is evicted once the recorder is full.
+	var got []string
+	for _, tr := range rec.Traces() {
+		got = append(got, tr.Path)
+	}
+	if want := "/d,/c,/b"; strings.Join(got, ",") != want {
+		t.Errorf("incorrect trace order: got %v want %v", got, want)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		endVerify := StartStage(r.Context(), "verify")
+		endVerify()
+		defer StartStage(r.Context(), "enqueue")()
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	testCases := []struct {
+		name           string
+		threshold      time.Duration
+		expectedTraces int
+	}{
+		{name: "Slow Request Recorded", threshold: 0, expectedTraces: 1},
+		{name: "Fast Request Ignored", threshold: time.Hour, expectedTraces: 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := NewRecorder(logger, tc.threshold, 10)
+			rr := httptest.NewRecorder()
+			rec.Middleware(handler).ServeHTTP(rr, httptest.NewRequest("POST", "/webhooks", nil))
+
+			traces := rec.Traces()
+			if len(traces) != tc.expectedTraces {
+				t.Fatalf("incorrect number of traces: got %d want %d", len(traces), tc.expectedTraces)
+			}
+			if tc.expectedTraces == 0 {
+				return
+			}
+			tr := traces[0]
+			if tr.Path != "/webhooks" || tr.Status != http.StatusAccepted {
+				t.Errorf("incorrect trace: got %+v", tr)
+			}
+			var stages []string
+			for _, s := range tr.Stages {
+				stages = append(stages, s.Name)
+			}
+			if want := "verify,enqueue"; strings.Join(stages, ",") != want {
+				t.Errorf("incorrect stages: got %v want %v", stages, want)
+			}
+		})
+	}
+}
+
+func TestStartStageWithoutTrace(t *testing.T) {
+	// Stages outside a traced request are ignored.
+	StartStage(context.Background(), "decode")()
+}
@@ -0,0 +1,58 @@
+package tracing
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Stage is a timed step of handling a request, e.g. reading the body.
+type Stage struct {
+	Name       string  `json:"name"`
+	OffsetMs   float64 `json:"offset_ms"` // When the stage started, since the request arrived.
+	DurationMs float64 `json:"duration_ms"`
+}
+
+// Trace records the stages of one request.
+type Trace struct {
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	RemoteAddr string    `json:"remote_addr"`
+	StartedAt  time.Time `json:"started_at"`
+	DurationMs float64   `json:"duration_ms"`
+	Status     int       `json:"status"`
+	Stages     []Stage   `json:"stages"`
+}
+
+// active is the trace of a request in progress. Stages may end on other
+// goroutines, so they are added under a lock.
+type active struct {
+	mu     sync.Mutex
+	start  time.Time
+	stages []Stage
+}
+
+type traceKey struct{}
+
+// StartStage starts timing the named stage of the request traced in ctx and
+// returns a function that ends it. Without a trace in ctx it does nothing,
+// so handlers can call it unconditionally:
+//
+//	defer tracing.StartStage(r.Context(), "decode")()
+func StartStage(ctx context.Context, name string) func() {
+	a, ok := ctx.Value(traceKey{}).(*active)
+	if !ok {
+		return func() {}
+	}
+	start := time.Now()
+	return func() {
+		stage := Stage{Name: name, OffsetMs: millis(start.Sub(a.start)), DurationMs: millis(time.Since(start))}
+		a.mu.Lock()
+		a.stages = append(a.stages, stage)
+		a.mu.Unlock()
+	}
+}
+
+func millis(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,77 @@
+package vcr
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.Header.Get("Authorization") == "" {
+			t.Errorf("Expected the request to reach the server unchanged")
+		}
+		w.Header().Set("X-Request-Id", "req-1")
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, `{"uuid":"real-uuid"}`)
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "cassettes", "create.json")
+	rec, err := New(path, ModeRecord)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	rec.Sanitize = func(i *Interaction) {
+		i.Response.Body = strings.ReplaceAll(i.Response.Body, "real-uuid", "fixture-uuid")
+	}
+	client := &http.Client{Transport: rec}
+	req, _ := http.NewRequest("POST", server.URL+"/v1/things", strings.NewReader(`{"name":"a"}`))
+	req.Header.Set("Authorization", "Bearer token")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("recording request failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != `{"uuid":"real-uuid"}` {
+		t.Errorf("Expected the live response while recording, got %s", body)
+	}
+	if err := rec.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	replayer, err := New(path, ModeReplay)
+	if err != nil {
+		t.Fatalf("loading cassette failed: %v", err)
+	}
+	client = &http.Client{Transport: replayer}
+	resp, err = client.Post(server.URL+"/v1/things", "application/json", strings.NewReader(`{"name":"a"}`))
+	if err != nil {
+		t.Fatalf("replayed request failed: %v", err)
+	}
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated || string(body) != `{"uuid":"fixture-uuid"}` || resp.Header.Get("X-Request-Id") != "req-1" {
+		t.Errorf("unexpected replayed response: %d %v %s", resp.StatusCode, resp.Header, body)
+	}
+	if resp.Header.Get("Set-Cookie") != "" {
+		t.Errorf("Expected cookies not to be recorded")
+	}
+	if calls != 1 {
+		t.Errorf("Expected replay not to reach the server, got %d calls", calls)
+	}
+
+	// Each interaction is replayed once, and other requests don't match.
+	_, err = client.Post(server.URL+"/v1/things", "application/json", strings.NewReader(`{"name":"a"}`))
+	if !errors.Is(err, ErrNoInteraction) {
+		t.Errorf("incorrect error for a used interaction: got %v want %v", err, ErrNoInteraction)
+	}
+}
@@ -0,0 +1,114 @@
+package webhooks
+
+import (
+	"context"
+	"fmt"
+	"gusto-webhook-guide/internal/worker"
+	"strings"
+	"time"
+)
+
+// Poll intervals while waiting for an event's outcome. The interval starts
+// short, as most events are processed quickly, and backs off.
+const (
+	ackPollMin = 10 * time.Millisecond
+	ackPollMax = 250 * time.Millisecond
+)
+
+// Outcomes of waiting for an event to be processed before acknowledging it.
+const (
+	AckProcessed = "processed" // Succeeded or skipped.
+	AckFailed    = "failed"    // Failed for good, e.g. dead-lettered.
+	AckTimedOut  = "timed_out" // Still queued or processing when the budget ran out.
+)
+
+// AckPolicy chooses which event types are acknowledged only once they are
+// processed, for consumers that treat a 2xx as confirmation of durable
+// processing, and how long to hold the response for each. Other event types
+// are acknowledged as soon as they are queued. A nil *AckPolicy acknowledges
+// every event once queued.
+type AckPolicy struct {
+	rules []ackRule
+}
+
+type ackRule struct {
+	pattern string
+	budget  time.Duration
+}
+
+// ParseAckPolicy parses a comma-separated list of pattern=budget pairs, e.g.
+// "payroll.*=10s,company.updated=5s", where patterns are written as for
+// ParseEventFilter and budgets as for time.ParseDuration. The first pattern
+// matching an event type applies. It returns nil if raw is empty.
+func ParseAckPolicy(raw string) (*AckPolicy, error) {
+	var rules []ackRule
+	for _, pair := range strings.Split(raw, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		pattern, rawBudget, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid acknowledgment rule %q: want event_type=budget", pair)
+		}
+		patterns, err := parseEventPatterns(pattern)
+		if err != nil {
+			return nil, err
+		}
+		if len(patterns) != 1 {
+			return nil, fmt.Errorf("invalid acknowledgment rule %q: want event_type=budget", pair)
+		}
+		budget, err := time.ParseDuration(strings.TrimSpace(rawBudget))
+		if err != nil || budget <= 0 {
+			return nil, fmt.Errorf("invalid acknowledgment rule %q: budget must be a positive duration", pair)
+		}
+		rules = append(rules, ackRule{pattern: patterns[0], budget: budget})
+	}
+	if len(rules) == 0 {
+		return nil, nil
+	}
+	return &AckPolicy{rules: rules}, nil
+}
+
+// Budget returns how long to wait for events of eventType to be processed
+// before answering, and false if they are acknowledged once queued.
+func (p *AckPolicy) Budget(eventType string) (time.Duration, bool) {
+	if p == nil {
+		return 0, false
+	}
+	for _, rule := range p.rules {
+		if matchesAny([]string{rule.pattern}, eventType) {
+			return rule.budget, true
+		}
+	}
+	return 0, false
+}
+
+// awaitOutcome polls the idempotency store until the event with the given
+// UUID has a final status, or until deadline or ctx ends, and returns one
+// of the Ack outcomes. Failures to read the store are retried until the
+// deadline.
+func awaitOutcome(ctx context.Context, store worker.Store, eventUUID string, deadline time.Time) string {
+	ctx, cancel := context.WithDeadline(ctx, deadline)
+	defer cancel()
+	interval := ackPollMin
+	for {
+		// Read without the deadline, so that the outcome is checked at least
+		// once even if it has passed.
+		rec, found, err := store.Get(context.WithoutCancel(ctx), eventUUID)
+		if err == nil && found {
+			switch rec.Status {
+			case worker.StatusSucceeded, worker.StatusSkipped:
+				return AckProcessed
+			case worker.StatusPermanentFailure, worker.StatusDeadLettered, worker.StatusQuarantined:
+				return AckFailed
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return AckTimedOut
+		case <-time.After(interval):
+		}
+		interval = min(2*interval, ackPollMax)
+	}
+}
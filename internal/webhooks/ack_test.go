@@ -0,0 +1,44 @@
+package webhooks
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAckPolicy(t *testing.T) {
+	policy, err := ParseAckPolicy("payroll.processed=5s, payroll.*=10s, *=30s")
+	if err != nil {
+		t.Fatalf("ParseAckPolicy failed: %v", err)
+	}
+	testCases := []struct {
+		eventType string
+		budget    time.Duration
+	}{
+		{eventType: "payroll.processed", budget: 5 * time.Second},
+		{eventType: "payroll.cancelled", budget: 10 * time.Second},
+		{eventType: "company.updated", budget: 30 * time.Second},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.eventType, func(t *testing.T) {
+			if budget, ok := policy.Budget(tc.eventType); !ok || budget != tc.budget {
+				t.Errorf("Budget(%q) = %v, %v, want %v, true", tc.eventType, budget, ok, tc.budget)
+			}
+		})
+	}
+
+	var none *AckPolicy
+	if _, ok := none.Budget("payroll.processed"); ok {
+		t.Errorf("Expected a nil policy to acknowledge every event once queued")
+	}
+}
+
+func TestParseAckPolicyRejectsInvalidRules(t *testing.T) {
+	for _, raw := range []string{"payroll.processed", "payroll*=5s", "payroll.processed=soon", "payroll.processed=0s", "=5s"} {
+		if _, err := ParseAckPolicy(raw); err == nil {
+			t.Errorf("expected an error parsing %q", raw)
+		}
+	}
+	if policy, err := ParseAckPolicy(" "); policy != nil || err != nil {
+		t.Errorf("ParseAckPolicy of an empty list = %v, %v, want nil, nil", policy, err)
+	}
+}
@@ -0,0 +1,72 @@
+package webhooks
+
+import (
+	"fmt"
+	"strings"
+)
+
+// EventFilter decides which event types are queued. Events it filters out
+// are still acknowledged, so the provider doesn't deliver them again, but
+// never reach the queue. A nil *EventFilter lets every event through.
+type EventFilter struct {
+	allow []string
+	deny  []string
+}
+
+// ParseEventFilter builds an EventFilter from comma-separated lists of
+// event type patterns, written as for worker.Registry: an exact event type,
+// e.g. "payroll.processed", a prefix, e.g. "contractor.*", or "*". An empty
+// allow list allows every event type. Denied event types are filtered out
+// even if they are also allowed. It returns nil if both lists are empty.
+func ParseEventFilter(allow, deny string) (*EventFilter, error) {
+	allowed, err := parseEventPatterns(allow)
+	if err != nil {
+		return nil, err
+	}
+	denied, err := parseEventPatterns(deny)
+	if err != nil {
+		return nil, err
+	}
+	if len(allowed) == 0 && len(denied) == 0 {
+		return nil, nil
+	}
+	return &EventFilter{allow: allowed, deny: denied}, nil
+}
+
+// parseEventPatterns parses a comma-separated list of event type patterns.
+func parseEventPatterns(raw string) ([]string, error) {
+	var patterns []string
+	for _, pattern := range strings.Split(raw, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if wildcard := strings.Index(pattern, "*"); wildcard >= 0 && (pattern != "*" && !strings.HasSuffix(pattern, ".*") || wildcard != len(pattern)-1) {
+			return nil, fmt.Errorf("invalid event type pattern %q: \"*\" may only end the pattern after a dot, e.g. \"contractor.*\"", pattern)
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns, nil
+}
+
+// Allows reports whether events of eventType should be queued.
+func (f *EventFilter) Allows(eventType string) bool {
+	if f == nil {
+		return true
+	}
+	if matchesAny(f.deny, eventType) {
+		return false
+	}
+	return len(f.allow) == 0 || matchesAny(f.allow, eventType)
+}
+
+// matchesAny reports whether any of patterns matches eventType.
+func matchesAny(patterns []string, eventType string) bool {
+	for _, pattern := range patterns {
+		if pattern == "*" || pattern == eventType ||
+			strings.HasSuffix(pattern, ".*") && strings.HasPrefix(eventType, strings.TrimSuffix(pattern, "*")) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,44 @@
+package webhooks
+
+import "testing"
+
+func TestEventFilter(t *testing.T) {
+	testCases := []struct {
+		name      string
+		allow     string
+		deny      string
+		eventType string
+		expected  bool
+	}{
+		{name: "No Lists", eventType: "contractor.created", expected: true},
+		{name: "Denied By Prefix", deny: "contractor.*", eventType: "contractor.created", expected: false},
+		{name: "Prefix Needs The Dot", deny: "contractor.*", eventType: "contractor_payment.created", expected: true},
+		{name: "Denied Exactly", deny: "company.updated, payroll.processed", eventType: "payroll.processed", expected: false},
+		{name: "Allowed", allow: "payroll.*,company.created", eventType: "company.created", expected: true},
+		{name: "Not Allowed", allow: "payroll.*,company.created", eventType: "company.updated", expected: false},
+		{name: "Deny Wins", allow: "*", deny: "employee.terminated", eventType: "employee.terminated", expected: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			filter, err := ParseEventFilter(tc.allow, tc.deny)
+			if err != nil {
+				t.Fatalf("ParseEventFilter failed: %v", err)
+			}
+			if got := filter.Allows(tc.eventType); got != tc.expected {
+				t.Errorf("Allows(%q) = %v, want %v", tc.eventType, got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestParseEventFilterRejectsInvalidPatterns(t *testing.T) {
+	for _, pattern := range []string{"contractor*", "*.created", "company.*.updated"} {
+		if _, err := ParseEventFilter("", pattern); err == nil {
+			t.Errorf("expected an error parsing %q", pattern)
+		}
+	}
+	if filter, err := ParseEventFilter(" ", ""); err != nil || filter != nil {
+		t.Errorf("expected no filter for empty lists, got %v (err %v)", filter, err)
+	}
+}
@@ -0,0 +1,106 @@
+package webhooks
+
+import (
+	"bytes"
+	"encoding/json"
+	"gusto-webhook-guide/internal/apierror"
+	"gusto-webhook-guide/internal/contextkeys"
+	"gusto-webhook-guide/internal/deliveries"
+	"gusto-webhook-guide/internal/tracing"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Forwarder is a pass-through alternative to Handler. Rather than queueing
+// events for processing, it forwards each verified raw body synchronously to
+// a single upstream URL and answers with the upstream's status code.
+type Forwarder struct {
+	Logger   *slog.Logger
+	Upstream string
+	Client   *http.Client
+	// Headers are copied from the incoming request to the upstream one, e.g.
+	// the provider's signature header so the upstream can verify it too.
+	Headers []string
+	// Deliveries, if set, records bodies per event UUID to detect duplicates
+	// that arrive with different content.
+	Deliveries *deliveries.Tracker
+	// Control, if set, handles provider-specific non-event payloads such as
+	// subscription verification. It reports whether it wrote a response.
+	Control func(w http.ResponseWriter, payload map[string]any) bool
+}
+
+// NewForwarder creates a Forwarder that sends requests to upstream.
+func NewForwarder(logger *slog.Logger, upstream string, client *http.Client) *Forwarder {
+	return &Forwarder{
+		Logger:   logger,
+		Upstream: upstream,
+		Client:   client,
+		Headers:  []string{"Content-Type"},
+	}
+}
+
+// HandleWebhook handles provider control payloads (e.g. verification) and
+// forwards everything else to the upstream unchanged. If the upstream can't
+// be reached it answers 502, so the provider retries the delivery.
+func (f *Forwarder) HandleWebhook(w http.ResponseWriter, r *http.Request) {
+	bodyBytes, ok := r.Context().Value(contextkeys.RequestBodyKey).([]byte)
+	if !ok {
+		f.Logger.Error("Could not retrieve request body from context")
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Internal server error")
+		return
+	}
+
+	// The body is forwarded as is, but decode it to record the event and to
+	// answer control payloads ourselves.
+	var payload map[string]any
+	endDecode := tracing.StartStage(r.Context(), "decode")
+	err := json.Unmarshal(bodyBytes, &payload)
+	endDecode()
+	if err == nil && f.Control != nil && f.Control(w, payload) {
+		return
+	}
+	eventUUID, _ := payload["uuid"].(string)
+	eventType, _ := payload["event_type"].(string)
+	if f.Deliveries != nil && eventUUID != "" {
+		if changes := f.Deliveries.Observe(eventUUID, bodyBytes, time.Now().UTC()); changes != nil {
+			f.Logger.Warn("Duplicate event delivered with a different body",
+				"event_uuid", eventUUID,
+				"changes", changes,
+			)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodPost, f.Upstream, bytes.NewReader(bodyBytes))
+	if err != nil {
+		f.Logger.Error("Failed to build upstream request", "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Internal server error")
+		return
+	}
+	for _, name := range f.Headers {
+		if value := r.Header.Get(name); value != "" {
+			req.Header.Set(name, value)
+		}
+	}
+
+	start := time.Now()
+	endForward := tracing.StartStage(r.Context(), "forward")
+	resp, err := f.Client.Do(req)
+	endForward()
+	if err != nil {
+		f.Logger.Error("Failed to forward webhook", "event_uuid", eventUUID, "event_type", eventType, "error", err)
+		apierror.Write(w, r, http.StatusBadGateway, apierror.CodeUpstreamError, "Upstream unavailable")
+		return
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body) // Allow the connection to be reused.
+
+	f.Logger.Info("Webhook forwarded",
+		"event_uuid", eventUUID,
+		"event_type", eventType,
+		"status", resp.StatusCode,
+		"duration", time.Since(start),
+	)
+	w.WriteHeader(resp.StatusCode)
+}
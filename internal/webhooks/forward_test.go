@@ -0,0 +1,80 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"gusto-webhook-guide/internal/contextkeys"
+	"gusto-webhook-guide/internal/providers/gusto"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestForwarderHandleWebhook(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+
+	var forwarded []byte
+	var signature string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded, _ = io.ReadAll(r.Body)
+		signature = r.Header.Get(gusto.SignatureHeader)
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	defer upstream.Close()
+
+	testCases := []struct {
+		name               string
+		upstream           string
+		requestBody        []byte
+		expectedStatusCode int
+		expectForwarded    bool
+	}{
+		{
+			name:               "Event Forwarded",
+			upstream:           upstream.URL,
+			requestBody:        []byte(`{"event_type": "company.created", "uuid": "123"}`),
+			expectedStatusCode: http.StatusTeapot,
+			expectForwarded:    true,
+		},
+		{
+			name:               "Verification Answered Locally",
+			upstream:           upstream.URL,
+			requestBody:        []byte(`{"verification_token": "abc", "webhook_subscription_uuid": "xyz"}`),
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "Upstream Unreachable",
+			upstream:           "http://127.0.0.1:1",
+			requestBody:        []byte(`{"event_type": "company.created", "uuid": "123"}`),
+			expectedStatusCode: http.StatusBadGateway,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			forwarded, signature = nil, ""
+			forwarder := NewForwarder(logger, tc.upstream, upstream.Client())
+			forwarder.Headers = append(forwarder.Headers, gusto.SignatureHeader)
+			forwarder.Control = gusto.VerificationHandler(logger)
+
+			req := httptest.NewRequest("POST", "/webhooks", bytes.NewReader(tc.requestBody))
+			req.Header.Set(gusto.SignatureHeader, "sig")
+			req = req.WithContext(context.WithValue(req.Context(), contextkeys.RequestBodyKey, tc.requestBody))
+			rr := httptest.NewRecorder()
+			forwarder.HandleWebhook(rr, req)
+
+			if status := rr.Code; status != tc.expectedStatusCode {
+				t.Errorf("handler returned wrong status code: got %v want %v", status, tc.expectedStatusCode)
+			}
+			if tc.expectForwarded {
+				if !bytes.Equal(forwarded, tc.requestBody) || signature != "sig" {
+					t.Errorf("incorrect forwarded request: got body %q signature %q", forwarded, signature)
+				}
+			} else if forwarded != nil {
+				t.Errorf("request unexpectedly forwarded: %q", forwarded)
+			}
+		})
+	}
+}
@@ -1,69 +1,464 @@
 package webhooks
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"gusto-webhook-guide/internal/apierror"
+	"gusto-webhook-guide/internal/archive"
 	"gusto-webhook-guide/internal/contextkeys"
+	"gusto-webhook-guide/internal/deliveries"
 	"gusto-webhook-guide/internal/models"
+	"gusto-webhook-guide/internal/schema"
+	"gusto-webhook-guide/internal/tracing"
+	"gusto-webhook-guide/internal/worker"
 	"log/slog"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 // Handler contains dependencies for the webhook HTTP handlers.
 type Handler struct {
-	Logger   *slog.Logger
-	JobQueue chan<- models.Job // Corrected type
+	Logger *slog.Logger
+	Queue  worker.JobQueue
+	// Deliveries, if set, records bodies per event UUID to detect duplicates
+	// that arrive with different content.
+	Deliveries *deliveries.Tracker
+	// Archive, if set, stores every event with the request's headers before
+	// it is queued, so it can be replayed later. An event that can't be
+	// archived is rejected like one that can't be queued.
+	Archive archive.Store
+	// Control, if set, handles provider-specific non-event payloads such as
+	// subscription verification. It reports whether it wrote a response.
+	Control func(w http.ResponseWriter, payload map[string]any) bool
+	// EnqueueWait is how long to wait for room in a full queue before
+	// rejecting an event, to absorb short bursts. Zero rejects immediately.
+	EnqueueWait time.Duration
+	// Priorities assigns queued events a priority by event type. Unlisted
+	// event types are normal priority.
+	Priorities worker.Priorities
+	// Filter, if set, chooses which event types are queued. The rest are
+	// acknowledged with 202 and counted, but not queued.
+	Filter *EventFilter
+	// Rejections, if set, follows events rejected because they couldn't be
+	// queued, to tell whether they were eventually processed.
+	Rejections *RejectionTracker
+	// QueueFullStatus is the status code events are rejected with when the
+	// queue is full, e.g. 429 so the provider backs off. Zero means 503.
+	// Other enqueue failures are always answered with 503.
+	QueueFullStatus int
+	// Recorder, if set, is told how each valid event was answered: with
+	// BatchEventQueued, BatchEventFiltered, BatchEventRejected or
+	// BatchEventDeadLettered.
+	Recorder func(r *http.Request, eventUUID, eventType string, body []byte, status string)
+	// RetryAfter, if set, estimates how long until a full queue has room,
+	// sent in a Retry-After header when rejecting events because of it.
+	RetryAfter func() time.Duration
+	// AckAfter, if set, holds the response to events of the event types it
+	// lists until Store records their outcome, for at most their budget.
+	// Processed events are answered with 200, failed ones with 500 and
+	// those still unprocessed with 504, so the provider delivers them again.
+	AckAfter *AckPolicy
+	// Store is the idempotency store AckAfter reads outcomes from.
+	Store worker.Store
+	// Schemas, if set, checks events of the event types it has a schema for
+	// before they are queued. Invalid events are rejected with a 400 listing
+	// the violations, or dead-lettered if DeadLetter is set.
+	Schemas *schema.Set
+	// DeadLetter, if set, takes events that fail their schema instead of
+	// rejecting them, so the provider doesn't deliver them again. They are
+	// acknowledged with 202 and never queued.
+	DeadLetter func(ctx context.Context, job models.Job, cause error)
 }
 
 // NewHandler creates a new instance of the webhook Handler.
-func NewHandler(logger *slog.Logger, jobQueue chan<- models.Job) *Handler {
+func NewHandler(logger *slog.Logger, queue worker.JobQueue) *Handler {
 	return &Handler{
-		Logger:   logger,
-		JobQueue: jobQueue,
+		Logger: logger,
+		Queue:  queue,
 	}
 }
 
-// HandleWebhook is the final, correct version that handles both verification and events.
+// ChainControls returns a Control that tries each of controls in turn,
+// until one writes a response.
+func ChainControls(controls ...func(w http.ResponseWriter, payload map[string]any) bool) func(w http.ResponseWriter, payload map[string]any) bool {
+	return func(w http.ResponseWriter, payload map[string]any) bool {
+		for _, control := range controls {
+			if control(w, payload) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// HandleWebhook handles provider control payloads (e.g. verification) and events.
 func (h *Handler) HandleWebhook(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
 	bodyBytes, ok := r.Context().Value(contextkeys.RequestBodyKey).([]byte)
 	if !ok {
 		h.Logger.Error("Could not retrieve request body from context")
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "Internal server error")
+		return
+	}
+
+	if trimmed := bytes.TrimSpace(bodyBytes); len(trimmed) > 0 && trimmed[0] == '[' {
+		h.handleBatch(w, r, bodyBytes, start)
 		return
 	}
 
 	var payload map[string]any
-	if err := json.Unmarshal(bodyBytes, &payload); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	endDecode := tracing.StartStage(r.Context(), "decode")
+	err := json.Unmarshal(bodyBytes, &payload)
+	endDecode()
+	if err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid request body")
 		return
 	}
 
-	if token, isVerification := payload["verification_token"]; isVerification {
-		h.Logger.Info("✅ Received verification payload from Gusto. Use the token and UUID from the logs to complete verification.",
-			"verification_token", token,
-			"webhook_subscription_uuid", payload["webhook_subscription_uuid"],
-		)
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("Verification payload acknowledged.\n"))
+	if h.Control != nil && h.Control(w, payload) {
 		return
 	}
 
-	if _, isEvent := payload["event_type"]; isEvent {
-		// Create a new job with 0 initial attempts.
-		job := models.Job{
-			Payload:  bodyBytes,
-			Attempts: 0,
+	_, hasType := payload["event_type"]
+	_, hasUUID := payload["uuid"]
+	if hasType || hasUUID {
+		if field := invalidEnvelopeField(payload); field != "" {
+			invalidEvents.WithLabelValues(field).Inc()
+			h.Logger.Warn("Rejecting event with an invalid envelope", "field", field, "body", string(bodyBytes))
+			writeInvalidEvent(w, r, field)
+			return
 		}
-		select {
-		case h.JobQueue <- job:
-			h.Logger.Info("Webhook event successfully queued for processing")
+
+		if h.filtered(payload) {
+			h.record(r, payload, bodyBytes, BatchEventFiltered)
 			w.WriteHeader(http.StatusAccepted)
-		default:
-			h.Logger.Error("Job queue is full. Rejecting webhook event.")
-			http.Error(w, "Server busy.", http.StatusServiceUnavailable)
+			return
+		}
+		if violations := h.validate(payload); violations != nil {
+			if h.DeadLetter == nil {
+				h.rejectInvalid(w, r, payload, nil, violations)
+				return
+			}
+			h.deadLetterInvalid(r, payload, bodyBytes, violations)
+			h.record(r, payload, bodyBytes, BatchEventDeadLettered)
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		if err := h.enqueue(r, payload, bodyBytes); err != nil {
+			h.record(r, payload, bodyBytes, BatchEventRejected)
+			apierror.Write(w, r, h.rejectionStatus(w, err), apierror.CodeServerBusy, "Server busy")
+			return
 		}
+		h.record(r, payload, bodyBytes, BatchEventQueued)
+		if budget, ok := h.ackBudget(payload); ok {
+			switch outcome := h.awaitAck(r, payload, start.Add(budget)); outcome {
+			case AckProcessed:
+				w.WriteHeader(http.StatusOK)
+			case AckFailed:
+				apierror.Write(w, r, ackStatus(outcome), apierror.CodeProcessingFailed, "Event processing failed")
+			default:
+				apierror.Write(w, r, ackStatus(outcome), apierror.CodeProcessingTimedOut, "Event not processed within the acknowledgment budget")
+			}
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
 		return
 	}
 
 	h.Logger.Warn("Received webhook with unknown payload format", "body", string(bodyBytes))
-	http.Error(w, "Unknown request format", http.StatusBadRequest)
-}
\ No newline at end of file
+	apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, "Unknown request format")
+}
+
+// enqueue queues a validated event as a new job, logging and tracking the
+// outcome. An error means the event was rejected and should be answered
+// with rejectionStatus, so the provider delivers it again.
+func (h *Handler) enqueue(r *http.Request, payload map[string]any, body []byte) error {
+	eventUUID := payload["uuid"].(string)
+	requestID, _ := r.Context().Value(contextkeys.RequestIDKey).(string)
+	logger := h.Logger.With("event_uuid", eventUUID)
+	if requestID != "" {
+		logger = logger.With("request_id", requestID)
+	}
+	if h.Deliveries != nil {
+		if changes := h.Deliveries.Observe(eventUUID, body, time.Now().UTC()); changes != nil {
+			logger.Warn("Duplicate event delivered with a different body", "changes", changes)
+		}
+	}
+
+	// Create a new job with 0 initial attempts. The request context is
+	// detached from cancellation since it ends as soon as we respond.
+	eventType := payload["event_type"].(string)
+	job := models.Job{
+		Payload:    body,
+		Attempts:   0,
+		Priority:   h.Priorities.For(eventType),
+		ReceivedAt: time.Now().UTC(),
+		RequestID:  requestID,
+		Ctx:        context.WithoutCancel(r.Context()),
+	}
+
+	if h.Archive != nil {
+		endArchive := tracing.StartStage(r.Context(), "archive")
+		err := h.Archive.Put(r.Context(), archive.Event{
+			UUID:       eventUUID,
+			EventType:  eventType,
+			ReceivedAt: job.ReceivedAt,
+			RequestID:  requestID,
+			Headers:    archive.Headers(r.Header),
+			Body:       body,
+		})
+		endArchive()
+		if err != nil {
+			h.Rejections.Rejected(eventUUID, RejectedArchiveError, time.Now().UTC())
+			logger.Error("Failed to archive webhook event. Rejecting it.", "error", err)
+			return err
+		}
+	}
+
+	endEnqueue := tracing.StartStage(r.Context(), "enqueue")
+	err := h.Queue.Enqueue(r.Context(), job, h.EnqueueWait)
+	endEnqueue()
+
+	switch {
+	case err == nil:
+		h.Rejections.Accepted(eventUUID, time.Now().UTC())
+		logger.Info("Webhook event successfully queued for processing")
+	case errors.Is(err, worker.ErrQueueFull):
+		h.Rejections.Rejected(eventUUID, RejectedQueueFull, time.Now().UTC())
+		logger.Error("Job queue is full. Rejecting webhook event.")
+	default:
+		h.Rejections.Rejected(eventUUID, RejectedEnqueueError, time.Now().UTC())
+		logger.Error("Failed to queue webhook event", "error", err)
+	}
+	return err
+}
+
+// ackBudget returns how long to hold the response to a queued event for
+// its outcome, and false if it is acknowledged once queued.
+func (h *Handler) ackBudget(payload map[string]any) (time.Duration, bool) {
+	if h.Store == nil {
+		return 0, false
+	}
+	return h.AckAfter.Budget(payload["event_type"].(string))
+}
+
+// awaitAck waits until deadline for a queued event's outcome, counting and
+// logging it.
+func (h *Handler) awaitAck(r *http.Request, payload map[string]any, deadline time.Time) string {
+	eventUUID, eventType := payload["uuid"].(string), payload["event_type"].(string)
+	endAwait := tracing.StartStage(r.Context(), "await_processing")
+	outcome := awaitOutcome(r.Context(), h.Store, eventUUID, deadline)
+	endAwait()
+	ackWaits.WithLabelValues(outcome).Inc()
+	if outcome == AckProcessed {
+		h.Logger.Debug("Acknowledging event after processing", "event_uuid", eventUUID, "event_type", eventType)
+	} else {
+		h.Logger.Warn("Event not processed within its acknowledgment budget", "event_uuid", eventUUID,
+			"event_type", eventType, "outcome", outcome)
+	}
+	return outcome
+}
+
+// ackStatus returns the status code answering an event that is
+// acknowledged after processing, given its outcome.
+func ackStatus(outcome string) int {
+	switch outcome {
+	case AckProcessed:
+		return http.StatusOK
+	case AckFailed:
+		return http.StatusInternalServerError
+	default:
+		return http.StatusGatewayTimeout
+	}
+}
+
+// filtered reports whether the Filter drops a validated event, counting
+// and logging it if so.
+func (h *Handler) filtered(payload map[string]any) bool {
+	eventType := payload["event_type"].(string)
+	if h.Filter.Allows(eventType) {
+		return false
+	}
+	filteredEvents.WithLabelValues(eventType).Inc()
+	h.Logger.Debug("Acknowledged filtered event without queueing it", "event_uuid", payload["uuid"], "event_type", eventType)
+	return true
+}
+
+// record passes the outcome of a valid event to the Recorder, if any.
+func (h *Handler) record(r *http.Request, payload map[string]any, body []byte, status string) {
+	if h.Recorder != nil {
+		h.Recorder(r, payload["uuid"].(string), payload["event_type"].(string), body, status)
+	}
+}
+
+// rejectionStatus returns the status code to reject an event with after
+// enqueueing it failed with err. For a full queue, it also sets the
+// Retry-After header.
+func (h *Handler) rejectionStatus(w http.ResponseWriter, err error) int {
+	if !errors.Is(err, worker.ErrQueueFull) {
+		return http.StatusServiceUnavailable
+	}
+	if h.RetryAfter != nil {
+		seconds := max(int(math.Ceil(h.RetryAfter().Seconds())), 1)
+		w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	}
+	if h.QueueFullStatus == 0 {
+		return http.StatusServiceUnavailable
+	}
+	return h.QueueFullStatus
+}
+
+// Statuses of the events in a batch response, also passed to Recorder.
+// Events acknowledged after processing are reported with their outcome
+// (AckProcessed, AckFailed or AckTimedOut) in place of BatchEventQueued.
+const (
+	BatchEventQueued       = "queued"
+	BatchEventFiltered     = "filtered"
+	BatchEventRejected     = "rejected"
+	BatchEventDeadLettered = "dead_lettered" // Failed its schema, see Handler.DeadLetter.
+)
+
+// batchEventResult is the outcome for one event of a batched delivery.
+type batchEventResult struct {
+	UUID   string `json:"uuid"`
+	Status string `json:"status"`
+}
+
+// batchResponse is the body of the response to a batched delivery.
+type batchResponse struct {
+	Events []batchEventResult `json:"events"`
+}
+
+// invalidBatchEventResponse is the body of a 400 for a batch with an event
+// whose envelope is invalid.
+type invalidBatchEventResponse struct {
+	apierror.Error
+	Index int    `json:"index"`
+	Field string `json:"field,omitempty"`
+}
+
+// handleBatch handles a JSON array of events delivered in one request. Each
+// event is queued as its own job. The batch is checked before anything is
+// queued, so a malformed one, or one with an event that fails its schema
+// unless DeadLetter is set, is rejected whole with a 400. If some events
+// can't be queued, the request is rejected, as a single event would be, so
+// the whole batch is delivered again; the events that were queued are then
+// dropped as duplicates by the idempotency store. Otherwise events
+// acknowledged after processing are waited for, and the first that fails or
+// runs out of budget sets the status as for a single event. Either way the
+// body reports each event's status.
+func (h *Handler) handleBatch(w http.ResponseWriter, r *http.Request, body []byte, start time.Time) {
+	var elements []json.RawMessage
+	endDecode := tracing.StartStage(r.Context(), "decode")
+	err := json.Unmarshal(body, &elements)
+	payloads := make([]map[string]any, len(elements))
+	for i := 0; err == nil && i < len(elements); i++ {
+		err = json.Unmarshal(elements[i], &payloads[i])
+	}
+	endDecode()
+	if err != nil || len(elements) == 0 {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid request body")
+		return
+	}
+
+	violations := make([][]schema.Violation, len(payloads))
+	for i, payload := range payloads {
+		if field := invalidEnvelopeField(payload); field != "" {
+			invalidEvents.WithLabelValues(field).Inc()
+			h.Logger.Warn("Rejecting batch with an invalid event envelope", "index", i, "field", field, "body", string(elements[i]))
+			apierror.WriteJSON(w, http.StatusBadRequest, invalidBatchEventResponse{
+				Error: apierror.New(r, apierror.CodeInvalidEvent, fmt.Sprintf("event %d: field %q is required and must be a non-empty string", i, field)),
+				Index: i,
+				Field: field,
+			})
+			return
+		}
+		if !h.Filter.Allows(payload["event_type"].(string)) {
+			continue
+		}
+		if violations[i] = h.validate(payload); violations[i] != nil && h.DeadLetter == nil {
+			h.rejectInvalid(w, r, payload, &i, violations[i])
+			return
+		}
+	}
+
+	status := http.StatusAccepted
+	response := batchResponse{Events: make([]batchEventResult, len(payloads))}
+	for i, payload := range payloads {
+		response.Events[i] = batchEventResult{UUID: payload["uuid"].(string), Status: BatchEventQueued}
+		if h.filtered(payload) {
+			response.Events[i].Status = BatchEventFiltered
+		} else if violations[i] != nil {
+			h.deadLetterInvalid(r, payload, elements[i], violations[i])
+			response.Events[i].Status = BatchEventDeadLettered
+		} else if err := h.enqueue(r, payload, elements[i]); err != nil {
+			response.Events[i].Status = BatchEventRejected
+			if status == http.StatusAccepted {
+				status = h.rejectionStatus(w, err)
+			}
+		}
+		h.record(r, payload, elements[i], response.Events[i].Status)
+	}
+	// Wait for events acknowledged after processing, unless the batch is
+	// to be delivered again anyway.
+	rejected := status != http.StatusAccepted
+	for i, payload := range payloads {
+		if rejected || response.Events[i].Status != BatchEventQueued {
+			continue
+		}
+		budget, ok := h.ackBudget(payload)
+		if !ok {
+			continue
+		}
+		outcome := h.awaitAck(r, payload, start.Add(budget))
+		response.Events[i].Status = outcome
+		if outcome != AckProcessed && status == http.StatusAccepted {
+			status = ackStatus(outcome)
+		}
+	}
+	batchDeliveries.WithLabelValues(strconv.Itoa(status)).Inc()
+	batchDeliveryEvents.Observe(float64(len(payloads)))
+	writeJSON(w, status, response)
+}
+
+// writeJSON writes v as the JSON body of a response with the given status.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// requiredEnvelopeFields are the fields every event must carry as non-empty
+// strings, since workers can't process or deduplicate an event without them.
+var requiredEnvelopeFields = []string{"uuid", "event_type"}
+
+// invalidEnvelopeField returns the first required field that payload lacks
+// or has no usable value for, or "" if the envelope is valid.
+func invalidEnvelopeField(payload map[string]any) string {
+	for _, field := range requiredEnvelopeFields {
+		if value, _ := payload[field].(string); value == "" {
+			return field
+		}
+	}
+	return ""
+}
+
+// invalidEventResponse is the body of a 400 for an invalid event envelope.
+type invalidEventResponse struct {
+	apierror.Error
+	Field string `json:"field"`
+}
+
+// writeInvalidEvent responds 400, pointing at the field that was missing,
+// empty or not a string.
+func writeInvalidEvent(w http.ResponseWriter, r *http.Request, field string) {
+	apierror.WriteJSON(w, http.StatusBadRequest, invalidEventResponse{
+		Error: apierror.New(r, apierror.CodeInvalidEvent, fmt.Sprintf("event field %q is required and must be a non-empty string", field)),
+		Field: field,
+	})
+}
@@ -3,13 +3,24 @@ package webhooks
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"gusto-webhook-guide/internal/apierror"
+	"gusto-webhook-guide/internal/archive"
 	"gusto-webhook-guide/internal/contextkeys"
 	"gusto-webhook-guide/internal/models"
+	"gusto-webhook-guide/internal/providers/gusto"
+	"gusto-webhook-guide/internal/schema"
+	"gusto-webhook-guide/internal/worker"
 	"io"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestHandleWebhook(t *testing.T) {
@@ -76,13 +87,15 @@ func TestHandleWebhook(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			jobQueue := make(chan models.Job, tc.jobQueueCapacity)
-			handler := NewHandler(logger, jobQueue)
+			handler := NewHandler(logger, worker.ChannelQueue(jobQueue))
+			handler.Control = gusto.VerificationHandler(logger)
 
 			req := httptest.NewRequest("POST", "/webhooks", bytes.NewReader(tc.requestBody))
 			rr := httptest.NewRecorder()
 
 			if tc.setBodyInContext {
 				ctx := context.WithValue(req.Context(), contextkeys.RequestBodyKey, tc.requestBody)
+				ctx = context.WithValue(ctx, contextkeys.RequestIDKey, "req-1")
 				req = req.WithContext(ctx)
 			}
 
@@ -94,8 +107,11 @@ func TestHandleWebhook(t *testing.T) {
 
 			var jobWasQueued bool
 			select {
-			case <-jobQueue:
+			case job := <-jobQueue:
 				jobWasQueued = true
+				if job.RequestID != "req-1" {
+					t.Errorf("incorrect job request ID: got %q want %q", job.RequestID, "req-1")
+				}
 			default:
 				jobWasQueued = false
 			}
@@ -105,4 +121,451 @@ func TestHandleWebhook(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+
+func TestHandleWebhookEnqueueWait(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	body := []byte(`{"event_type": "company.created", "uuid": "123"}`)
+
+	testCases := []struct {
+		name               string
+		enqueueWait        time.Duration
+		drainAfter         time.Duration // When a worker frees a slot; zero never.
+		expectedStatusCode int
+	}{
+		{name: "Slot Frees Within Wait", enqueueWait: time.Second, drainAfter: 20 * time.Millisecond, expectedStatusCode: http.StatusAccepted},
+		{name: "Queue Stays Full", enqueueWait: 20 * time.Millisecond, expectedStatusCode: http.StatusServiceUnavailable},
+		{name: "No Wait Configured", drainAfter: 20 * time.Millisecond, expectedStatusCode: http.StatusServiceUnavailable},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			jobQueue := make(chan models.Job, 1)
+			jobQueue <- models.Job{} // Already full.
+			handler := NewHandler(logger, worker.ChannelQueue(jobQueue))
+			handler.EnqueueWait = tc.enqueueWait
+			if tc.drainAfter > 0 {
+				time.AfterFunc(tc.drainAfter, func() { <-jobQueue })
+			}
+
+			req := httptest.NewRequest("POST", "/webhooks", bytes.NewReader(body))
+			req = req.WithContext(context.WithValue(req.Context(), contextkeys.RequestBodyKey, body))
+			rr := httptest.NewRecorder()
+			handler.HandleWebhook(rr, req)
+
+			if status := rr.Code; status != tc.expectedStatusCode {
+				t.Errorf("handler returned wrong status code: got %v want %v", status, tc.expectedStatusCode)
+			}
+		})
+	}
+}
+
+func TestHandleWebhookRejectsInvalidEnvelopes(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+
+	testCases := []struct {
+		name          string
+		requestBody   string
+		expectedField string
+	}{
+		{name: "Missing UUID", requestBody: `{"event_type": "company.created"}`, expectedField: "uuid"},
+		{name: "Empty UUID", requestBody: `{"event_type": "company.created", "uuid": ""}`, expectedField: "uuid"},
+		{name: "Missing Event Type", requestBody: `{"uuid": "123"}`, expectedField: "event_type"},
+		{name: "Non-String Event Type", requestBody: `{"event_type": 42, "uuid": "123"}`, expectedField: "event_type"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			jobQueue := make(chan models.Job, 1)
+			handler := NewHandler(logger, worker.ChannelQueue(jobQueue))
+
+			req := httptest.NewRequest("POST", "/webhooks", strings.NewReader(tc.requestBody))
+			req = req.WithContext(context.WithValue(req.Context(), contextkeys.RequestBodyKey, []byte(tc.requestBody)))
+			rr := httptest.NewRecorder()
+			handler.HandleWebhook(rr, req)
+
+			if rr.Code != http.StatusBadRequest {
+				t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+			}
+			var body invalidEventResponse
+			if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil || body.Field != tc.expectedField || body.Code != apierror.CodeInvalidEvent || body.Message == "" {
+				t.Errorf("incorrect error body: got %q (%v) want field %q", rr.Body.String(), err, tc.expectedField)
+			}
+			if len(jobQueue) != 0 {
+				t.Error("an invalid event was queued")
+			}
+		})
+	}
+}
+
+func TestHandleWebhookBatch(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+
+	testCases := []struct {
+		name               string
+		requestBody        string
+		jobQueueCapacity   int
+		expectedStatusCode int
+		expectedStatuses   []string
+		expectedJobs       int
+	}{
+		{
+			name:               "All Queued",
+			requestBody:        `[{"event_type": "company.updated", "uuid": "1"}, {"event_type": "employee.created", "uuid": "2"}]`,
+			jobQueueCapacity:   2,
+			expectedStatusCode: http.StatusAccepted,
+			expectedStatuses:   []string{BatchEventQueued, BatchEventQueued},
+			expectedJobs:       2,
+		},
+		{
+			name:               "Queue Fills Up",
+			requestBody:        `[{"event_type": "company.updated", "uuid": "1"}, {"event_type": "employee.created", "uuid": "2"}]`,
+			jobQueueCapacity:   1,
+			expectedStatusCode: http.StatusServiceUnavailable,
+			expectedStatuses:   []string{BatchEventQueued, BatchEventRejected},
+			expectedJobs:       1,
+		},
+		{
+			name:               "Invalid Event Rejects The Batch",
+			requestBody:        `[{"event_type": "company.updated", "uuid": "1"}, {"event_type": "employee.created"}]`,
+			jobQueueCapacity:   2,
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "Element Not An Object",
+			requestBody:        ` [{"event_type": "company.updated", "uuid": "1"}, 42]`,
+			jobQueueCapacity:   2,
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "Empty Batch",
+			requestBody:        `[]`,
+			jobQueueCapacity:   2,
+			expectedStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			jobQueue := make(chan models.Job, tc.jobQueueCapacity)
+			handler := NewHandler(logger, worker.ChannelQueue(jobQueue))
+
+			req := httptest.NewRequest("POST", "/webhooks", strings.NewReader(tc.requestBody))
+			req = req.WithContext(context.WithValue(req.Context(), contextkeys.RequestBodyKey, []byte(tc.requestBody)))
+			rr := httptest.NewRecorder()
+			handler.HandleWebhook(rr, req)
+
+			if rr.Code != tc.expectedStatusCode {
+				t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, tc.expectedStatusCode)
+			}
+			if len(jobQueue) != tc.expectedJobs {
+				t.Errorf("incorrect number of jobs queued: got %d want %d", len(jobQueue), tc.expectedJobs)
+			}
+			if tc.expectedStatuses == nil {
+				return
+			}
+			var body batchResponse
+			if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decoding response: %v", err)
+			}
+			for i, result := range body.Events {
+				if result.Status != tc.expectedStatuses[i] {
+					t.Errorf("event %d: got status %q want %q", i, result.Status, tc.expectedStatuses[i])
+				}
+			}
+			if len(body.Events) == 2 {
+				if job := <-jobQueue; !strings.Contains(string(job.Payload), `"uuid": "1"`) {
+					t.Errorf("first job holds the wrong event: %s", job.Payload)
+				}
+			}
+		})
+	}
+}
+
+func TestHandleWebhookQueueFullStatus(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	body := []byte(`{"event_type": "company.created", "uuid": "123"}`)
+
+	testCases := []struct {
+		name               string
+		queueFullStatus    int
+		retryAfter         func() time.Duration
+		expectedStatusCode int
+		expectedRetryAfter string
+	}{
+		{name: "Default", expectedStatusCode: http.StatusServiceUnavailable},
+		{
+			name:               "Too Many Requests With Retry-After",
+			queueFullStatus:    http.StatusTooManyRequests,
+			retryAfter:         func() time.Duration { return 2500 * time.Millisecond },
+			expectedStatusCode: http.StatusTooManyRequests,
+			expectedRetryAfter: "3",
+		},
+		{
+			name:               "Retry-After Of At Least A Second",
+			queueFullStatus:    http.StatusServiceUnavailable,
+			retryAfter:         func() time.Duration { return 0 },
+			expectedStatusCode: http.StatusServiceUnavailable,
+			expectedRetryAfter: "1",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			jobQueue := make(chan models.Job, 1)
+			jobQueue <- models.Job{} // Already full.
+			handler := NewHandler(logger, worker.ChannelQueue(jobQueue))
+			handler.QueueFullStatus = tc.queueFullStatus
+			handler.RetryAfter = tc.retryAfter
+
+			req := httptest.NewRequest("POST", "/webhooks", bytes.NewReader(body))
+			req = req.WithContext(context.WithValue(req.Context(), contextkeys.RequestBodyKey, body))
+			rr := httptest.NewRecorder()
+			handler.HandleWebhook(rr, req)
+
+			if rr.Code != tc.expectedStatusCode {
+				t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, tc.expectedStatusCode)
+			}
+			if got := rr.Header().Get("Retry-After"); got != tc.expectedRetryAfter {
+				t.Errorf("incorrect Retry-After: got %q want %q", got, tc.expectedRetryAfter)
+			}
+		})
+	}
+}
+
+func TestHandleWebhookFiltersEvents(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	filter, err := ParseEventFilter("", "contractor.*")
+	if err != nil {
+		t.Fatalf("ParseEventFilter failed: %v", err)
+	}
+	jobQueue := make(chan models.Job, 2)
+	handler := NewHandler(logger, worker.ChannelQueue(jobQueue))
+	handler.Filter = filter
+
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/webhooks", strings.NewReader(body))
+		req = req.WithContext(context.WithValue(req.Context(), contextkeys.RequestBodyKey, []byte(body)))
+		rr := httptest.NewRecorder()
+		handler.HandleWebhook(rr, req)
+		return rr
+	}
+
+	if rr := post(`{"event_type": "contractor.created", "uuid": "1"}`); rr.Code != http.StatusAccepted || len(jobQueue) != 0 {
+		t.Errorf("filtered event: got status %d with %d jobs queued, want 202 with none", rr.Code, len(jobQueue))
+	}
+
+	rr := post(`[{"event_type": "contractor.updated", "uuid": "2"}, {"event_type": "company.updated", "uuid": "3"}]`)
+	if rr.Code != http.StatusAccepted || len(jobQueue) != 1 {
+		t.Fatalf("filtered batch: got status %d with %d jobs queued, want 202 with 1", rr.Code, len(jobQueue))
+	}
+	var body batchResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if len(body.Events) != 2 || body.Events[0].Status != BatchEventFiltered || body.Events[1].Status != BatchEventQueued {
+		t.Errorf("incorrect batch statuses: %+v", body.Events)
+	}
+}
+
+func TestHandleWebhookArchivesEvents(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	dir := t.TempDir()
+	store, err := archive.OpenDirStore(dir)
+	if err != nil {
+		t.Fatalf("OpenDirStore failed: %v", err)
+	}
+	jobQueue := make(chan models.Job, 2)
+	handler := NewHandler(logger, worker.ChannelQueue(jobQueue))
+	handler.Archive = store
+
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/webhooks", strings.NewReader(body))
+		req.Header.Set("X-Gusto-Signature", "sig")
+		req.Header.Set("Authorization", "Bearer secret")
+		req = req.WithContext(context.WithValue(req.Context(), contextkeys.RequestBodyKey, []byte(body)))
+		rr := httptest.NewRecorder()
+		handler.HandleWebhook(rr, req)
+		return rr
+	}
+
+	body := `{"event_type": "company.updated", "uuid": "1"}`
+	if rr := post(body); rr.Code != http.StatusAccepted || len(jobQueue) != 1 {
+		t.Fatalf("got status %d with %d jobs queued, want 202 with 1", rr.Code, len(jobQueue))
+	}
+	e, found, err := store.Get(context.Background(), "1")
+	if err != nil || !found {
+		t.Fatalf("event was not archived (err %v)", err)
+	}
+	if string(e.Body) != body || e.EventType != "company.updated" || e.ReceivedAt.IsZero() {
+		t.Errorf("incorrect archived event: %+v", e)
+	}
+	if e.Headers.Get("X-Gusto-Signature") != "sig" || e.Headers.Get("Authorization") != "" {
+		t.Errorf("incorrect archived headers: %v", e.Headers)
+	}
+
+	// An event that can't be archived isn't queued, so the provider
+	// delivers it again.
+	os.RemoveAll(dir)
+	if rr := post(`{"event_type": "company.updated", "uuid": "2"}`); rr.Code != http.StatusServiceUnavailable || len(jobQueue) != 1 {
+		t.Errorf("archive failure: got status %d with %d jobs queued, want 503 with 1", rr.Code, len(jobQueue))
+	}
+}
+
+func TestHandleWebhookAckAfterProcessing(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	policy, err := ParseAckPolicy("payroll.*=200ms")
+	if err != nil {
+		t.Fatalf("ParseAckPolicy failed: %v", err)
+	}
+	store := worker.NewIdempotencyStore()
+	jobQueue := make(chan models.Job, 10)
+	handler := NewHandler(logger, worker.ChannelQueue(jobQueue))
+	handler.AckAfter = policy
+	handler.Store = store
+
+	// Stand in for the workers: outcomes are chosen by UUID, and "slow"
+	// events are never finished.
+	outcomes := map[string]worker.Status{"ok": worker.StatusSucceeded, "bad": worker.StatusDeadLettered}
+	go func() {
+		for job := range jobQueue {
+			var event models.WebhookEvent
+			json.Unmarshal(job.Payload, &event)
+			if status, found := outcomes[event.UUID]; found {
+				time.Sleep(20 * time.Millisecond)
+				store.Set(context.Background(), event.UUID, worker.Record{EventType: event.EventType, Status: status})
+			}
+		}
+	}()
+	defer close(jobQueue)
+
+	testCases := []struct {
+		name               string
+		requestBody        string
+		expectedStatusCode int
+		expectedStatuses   []string
+	}{
+		{name: "Processed", requestBody: `{"event_type": "payroll.processed", "uuid": "ok"}`, expectedStatusCode: http.StatusOK},
+		{name: "Failed", requestBody: `{"event_type": "payroll.processed", "uuid": "bad"}`, expectedStatusCode: http.StatusInternalServerError},
+		{name: "Timed Out", requestBody: `{"event_type": "payroll.processed", "uuid": "slow"}`, expectedStatusCode: http.StatusGatewayTimeout},
+		{name: "Acknowledged Once Queued", requestBody: `{"event_type": "company.updated", "uuid": "slow-2"}`, expectedStatusCode: http.StatusAccepted},
+		{
+			name:               "Batch",
+			requestBody:        `[{"event_type": "payroll.processed", "uuid": "ok"}, {"event_type": "company.updated", "uuid": "slow-3"}]`,
+			expectedStatusCode: http.StatusAccepted,
+			expectedStatuses:   []string{AckProcessed, BatchEventQueued},
+		},
+		{
+			name:               "Batch Timed Out",
+			requestBody:        `[{"event_type": "payroll.processed", "uuid": "slow-4"}, {"event_type": "payroll.processed", "uuid": "ok"}]`,
+			expectedStatusCode: http.StatusGatewayTimeout,
+			expectedStatuses:   []string{AckTimedOut, AckProcessed},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/webhooks", strings.NewReader(tc.requestBody))
+			req = req.WithContext(context.WithValue(req.Context(), contextkeys.RequestBodyKey, []byte(tc.requestBody)))
+			rr := httptest.NewRecorder()
+			handler.HandleWebhook(rr, req)
+			if rr.Code != tc.expectedStatusCode {
+				t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, tc.expectedStatusCode)
+			}
+			if tc.expectedStatuses == nil {
+				return
+			}
+			var body batchResponse
+			json.Unmarshal(rr.Body.Bytes(), &body)
+			var statuses []string
+			for _, e := range body.Events {
+				statuses = append(statuses, e.Status)
+			}
+			if strings.Join(statuses, ",") != strings.Join(tc.expectedStatuses, ",") {
+				t.Errorf("incorrect batch statuses: got %v want %v", statuses, tc.expectedStatuses)
+			}
+		})
+	}
+}
+
+func TestHandleWebhookValidatesSchemas(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "payroll.processed.json"),
+		[]byte(`{"required": ["payload"], "properties": {"payload": {"type": "object", "required": ["company_uuid"]}}}`), 0o600)
+	schemas, err := schema.LoadDir(dir)
+	if err != nil {
+		t.Fatalf("LoadDir failed: %v", err)
+	}
+
+	const (
+		valid   = `{"event_type": "payroll.processed", "uuid": "1", "payload": {"company_uuid": "c"}}`
+		invalid = `{"event_type": "payroll.processed", "uuid": "2", "payload": {}}`
+		other   = `{"event_type": "company.updated", "uuid": "3"}`
+	)
+	testCases := []struct {
+		name               string
+		deadLetter         bool
+		requestBody        string
+		expectedStatusCode int
+		expectedQueued     int
+		expectedDead       int
+		expectedBody       string
+	}{
+		{name: "Valid", requestBody: valid, expectedStatusCode: http.StatusAccepted, expectedQueued: 1},
+		{name: "No Schema", requestBody: other, expectedStatusCode: http.StatusAccepted, expectedQueued: 1},
+		{
+			name:               "Rejected",
+			requestBody:        invalid,
+			expectedStatusCode: http.StatusBadRequest,
+			expectedBody:       `"violations":[{"path":"/payload/company_uuid","message":"is required"}]`,
+		},
+		{
+			name:               "Batch Rejected",
+			requestBody:        "[" + valid + "," + invalid + "]",
+			expectedStatusCode: http.StatusBadRequest,
+			expectedBody:       `"index":1`,
+		},
+		{name: "Dead-Lettered", deadLetter: true, requestBody: invalid, expectedStatusCode: http.StatusAccepted, expectedDead: 1},
+		{
+			name:               "Batch Dead-Lettered",
+			deadLetter:         true,
+			requestBody:        "[" + valid + "," + invalid + "," + other + "]",
+			expectedStatusCode: http.StatusAccepted,
+			expectedQueued:     2,
+			expectedDead:       1,
+			expectedBody:       `{"uuid":"2","status":"dead_lettered"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			jobQueue := make(chan models.Job, 10)
+			handler := NewHandler(logger, worker.ChannelQueue(jobQueue))
+			handler.Schemas = schemas
+			var dead []error
+			if tc.deadLetter {
+				handler.DeadLetter = func(_ context.Context, job models.Job, cause error) { dead = append(dead, cause) }
+			}
+
+			req := httptest.NewRequest("POST", "/webhooks", strings.NewReader(tc.requestBody))
+			req = req.WithContext(context.WithValue(req.Context(), contextkeys.RequestBodyKey, []byte(tc.requestBody)))
+			rr := httptest.NewRecorder()
+			handler.HandleWebhook(rr, req)
+			if rr.Code != tc.expectedStatusCode {
+				t.Errorf("handler returned wrong status code: got %v want %v", rr.Code, tc.expectedStatusCode)
+			}
+			if len(jobQueue) != tc.expectedQueued || len(dead) != tc.expectedDead {
+				t.Errorf("got %d jobs queued and %d dead-lettered, want %d and %d", len(jobQueue), len(dead), tc.expectedQueued, tc.expectedDead)
+			}
+			for _, cause := range dead {
+				if !errors.Is(cause, worker.ErrSchema) || !worker.IsPermanent(cause) {
+					t.Errorf("expected a permanent schema error, got %v", cause)
+				}
+			}
+			if !strings.Contains(rr.Body.String(), tc.expectedBody) {
+				t.Errorf("response %s does not contain %s", rr.Body.String(), tc.expectedBody)
+			}
+		})
+	}
+}
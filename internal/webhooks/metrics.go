@@ -0,0 +1,49 @@
+package webhooks
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	invalidEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "webhook_invalid_events_total",
+		Help: "Events rejected with 400 before queueing, by the envelope field that was missing or invalid.",
+	}, []string{"field"})
+
+	schemaViolations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "webhook_schema_violations_total",
+		Help: "Events that failed their event type's schema at ingestion, by event type and action: rejected or dead_lettered.",
+	}, []string{"event_type", "action"})
+
+	filteredEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "webhook_filtered_events_total",
+		Help: "Events acknowledged without being queued because their event type is filtered out, by event type.",
+	}, []string{"event_type"})
+
+	batchDeliveries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "webhook_batch_deliveries_total",
+		Help: "Requests delivering a JSON array of events that passed validation, by response status code.",
+	}, []string{"status"})
+
+	batchDeliveryEvents = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "webhook_batch_delivery_events",
+		Help:    "Number of events per batched delivery.",
+		Buckets: prometheus.ExponentialBuckets(1, 2, 8),
+	})
+
+	queueRejections = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "webhook_queue_rejections_total",
+		Help: "Event deliveries rejected with 503 because they couldn't be archived or queued, by reason.",
+	}, []string{"reason"})
+
+	ackWaits = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "webhook_ack_after_processing_total",
+		Help: "Events whose response was held until they were processed, by outcome: processed, failed or timed_out.",
+	}, []string{"outcome"})
+
+	rejectionOutcomes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "webhook_queue_rejection_outcomes_total",
+		Help: "What became of events rejected with 503, by outcome; \"lost\" counts events never delivered again, i.e. lost to saturation.",
+	}, []string{"outcome"})
+)
@@ -0,0 +1,182 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"gusto-webhook-guide/internal/worker"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Reasons an event was rejected with a 503.
+const (
+	RejectedQueueFull    = "queue_full"    // The queue had no room.
+	RejectedEnqueueError = "enqueue_error" // The queue couldn't be written to.
+	RejectedArchiveError = "archive_error" // The event couldn't be archived.
+)
+
+// Outcomes of a rejected event, as far as this process can tell.
+const (
+	RejectionPending     = "pending"     // Not delivered again yet.
+	RejectionRedelivered = "redelivered" // Accepted on a later delivery, not yet processed.
+	RejectionSucceeded   = "succeeded"   // Redelivered and processed.
+	RejectionFailed      = "failed"      // Redelivered, but failed for good, e.g. dead-lettered.
+	RejectionLost        = "lost"        // Not delivered again within the loss window.
+)
+
+// Rejection is an event that was turned away with a 503 and what became of it.
+type Rejection struct {
+	EventUUID       string    `json:"event_uuid"`
+	Reason          string    `json:"reason"`
+	Rejections      int       `json:"rejections"` // How many deliveries were turned away.
+	FirstRejectedAt time.Time `json:"first_rejected_at"`
+	RedeliveredAt   time.Time `json:"redelivered_at,omitzero"`
+	Outcome         string    `json:"outcome"`
+}
+
+// final reports whether the outcome will no longer change.
+func (r Rejection) final() bool {
+	return r.Outcome == RejectionSucceeded || r.Outcome == RejectionFailed || r.Outcome == RejectionLost
+}
+
+// RejectionTracker follows events rejected because the queue was saturated,
+// to tell whether the provider's retries eventually got them processed or
+// the rejections cost us the event. An event that isn't delivered again
+// within the loss window is counted as lost.
+type RejectionTracker struct {
+	mu         sync.Mutex
+	max        int
+	lossWindow time.Duration
+	order      []string // Event UUIDs in first-rejected order, for eviction.
+	entries    map[string]*Rejection
+}
+
+// NewRejectionTracker creates a RejectionTracker remembering up to max
+// rejected events, which counts an event as lost if it isn't delivered again
+// within lossWindow. This should exceed how long the provider keeps
+// retrying. The oldest event is forgotten once the limit is reached.
+func NewRejectionTracker(max int, lossWindow time.Duration) *RejectionTracker {
+	return &RejectionTracker{max: max, lossWindow: lossWindow, entries: make(map[string]*Rejection)}
+}
+
+// Rejected records that a delivery of the event was turned away.
+func (t *RejectionTracker) Rejected(eventUUID, reason string, at time.Time) {
+	queueRejections.WithLabelValues(reason).Inc()
+	if t == nil || t.max <= 0 || eventUUID == "" {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if r, found := t.entries[eventUUID]; found {
+		r.Rejections++
+		return
+	}
+	if len(t.order) >= t.max {
+		delete(t.entries, t.order[0])
+		t.order = t.order[1:]
+	}
+	t.order = append(t.order, eventUUID)
+	t.entries[eventUUID] = &Rejection{
+		EventUUID:       eventUUID,
+		Reason:          reason,
+		Rejections:      1,
+		FirstRejectedAt: at,
+		Outcome:         RejectionPending,
+	}
+}
+
+// Accepted records that a delivery of the event was queued, which recovers
+// it if it was rejected before.
+func (t *RejectionTracker) Accepted(eventUUID string, at time.Time) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if r, found := t.entries[eventUUID]; found && r.Outcome == RejectionPending {
+		r.Outcome = RejectionRedelivered
+		r.RedeliveredAt = at
+	}
+}
+
+// Resolve settles what it can of the outstanding rejections: redelivered
+// events are looked up in the idempotency store to see if they were
+// processed, and events still not redelivered after the loss window are
+// counted as lost.
+func (t *RejectionTracker) Resolve(ctx context.Context, store worker.Store, now time.Time) error {
+	t.mu.Lock()
+	var redelivered []string
+	for _, eventUUID := range t.order {
+		r := t.entries[eventUUID]
+		switch {
+		case r.Outcome == RejectionPending && now.Sub(r.FirstRejectedAt) >= t.lossWindow:
+			r.Outcome = RejectionLost
+			rejectionOutcomes.WithLabelValues(RejectionLost).Inc()
+		case r.Outcome == RejectionRedelivered:
+			redelivered = append(redelivered, eventUUID)
+		}
+	}
+	t.mu.Unlock()
+
+	for _, eventUUID := range redelivered {
+		rec, found, err := store.Get(ctx, eventUUID)
+		if err != nil {
+			return err
+		}
+		outcome := ""
+		switch {
+		case !found:
+		case rec.Status == worker.StatusSucceeded:
+			outcome = RejectionSucceeded
+		case rec.Status == worker.StatusPermanentFailure, rec.Status == worker.StatusDeadLettered, rec.Status == worker.StatusQuarantined:
+			outcome = RejectionFailed
+		}
+		if outcome == "" {
+			continue
+		}
+		t.mu.Lock()
+		if r, found := t.entries[eventUUID]; found && !r.final() {
+			r.Outcome = outcome
+			rejectionOutcomes.WithLabelValues(outcome).Inc()
+		}
+		t.mu.Unlock()
+	}
+	return nil
+}
+
+// RejectionReport counts the tracked rejections by outcome and lists them,
+// most recent first.
+type RejectionReport struct {
+	Outcomes   map[string]int `json:"outcomes"`
+	Rejections []Rejection    `json:"rejections"`
+}
+
+// Report returns the tracked rejections.
+func (t *RejectionTracker) Report() RejectionReport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	report := RejectionReport{
+		Outcomes: map[string]int{
+			RejectionPending:     0,
+			RejectionRedelivered: 0,
+			RejectionSucceeded:   0,
+			RejectionFailed:      0,
+			RejectionLost:        0,
+		},
+		Rejections: []Rejection{},
+	}
+	for _, eventUUID := range slices.Backward(t.order) {
+		r := *t.entries[eventUUID]
+		report.Outcomes[r.Outcome]++
+		report.Rejections = append(report.Rejections, r)
+	}
+	return report
+}
+
+// HandleReport serves the tracked rejections as JSON.
+func (t *RejectionTracker) HandleReport(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(t.Report())
+}
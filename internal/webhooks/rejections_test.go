@@ -0,0 +1,95 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"gusto-webhook-guide/internal/contextkeys"
+	"gusto-webhook-guide/internal/models"
+	"gusto-webhook-guide/internal/worker"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRejectionTrackerOutcomes(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	ctx := context.Background()
+	jobQueue := make(chan models.Job, 1)
+	handler := NewHandler(logger, worker.ChannelQueue(jobQueue))
+	handler.Rejections = NewRejectionTracker(10, time.Hour)
+
+	deliver := func(eventUUID string) int {
+		body := []byte(`{"event_type": "company.created", "uuid": "` + eventUUID + `"}`)
+		req := httptest.NewRequest("POST", "/webhooks", bytes.NewReader(body))
+		req = req.WithContext(context.WithValue(req.Context(), contextkeys.RequestBodyKey, body))
+		rr := httptest.NewRecorder()
+		handler.HandleWebhook(rr, req)
+		return rr.Code
+	}
+
+	jobQueue <- models.Job{} // Full, so every event is rejected.
+	for _, eventUUID := range []string{"succeeds", "fails", "processing", "lost", "succeeds"} {
+		if status := deliver(eventUUID); status != http.StatusServiceUnavailable {
+			t.Fatalf("delivering %s while the queue was full: got status %d want %d", eventUUID, status, http.StatusServiceUnavailable)
+		}
+	}
+	for _, eventUUID := range []string{"succeeds", "fails", "processing"} {
+		<-jobQueue
+		if status := deliver(eventUUID); status != http.StatusAccepted {
+			t.Fatalf("redelivering %s: got status %d want %d", eventUUID, status, http.StatusAccepted)
+		}
+	}
+	if status := deliver("never-rejected"); status != http.StatusServiceUnavailable {
+		t.Fatalf("got status %d want %d", status, http.StatusServiceUnavailable)
+	}
+	<-jobQueue
+
+	store := worker.NewIdempotencyStore()
+	store.Set(ctx, "succeeds", worker.Record{Status: worker.StatusSucceeded})
+	store.Set(ctx, "fails", worker.Record{Status: worker.StatusDeadLettered})
+	store.Set(ctx, "processing", worker.Record{Status: worker.StatusProcessing})
+
+	// Within the loss window, only processed events are settled.
+	if err := handler.Rejections.Resolve(ctx, store, time.Now()); err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	report := handler.Rejections.Report()
+	want := map[string]int{RejectionPending: 2, RejectionRedelivered: 1, RejectionSucceeded: 1, RejectionFailed: 1, RejectionLost: 0}
+	for outcome, n := range want {
+		if report.Outcomes[outcome] != n {
+			t.Errorf("%s: got %d want %d (%v)", outcome, report.Outcomes[outcome], n, report.Outcomes)
+		}
+	}
+	if latest := report.Rejections[0]; latest.EventUUID != "never-rejected" {
+		t.Errorf("most recent rejection: got %s want never-rejected", latest.EventUUID)
+	}
+
+	// Past the loss window, events never delivered again are lost.
+	if err := handler.Rejections.Resolve(ctx, store, time.Now().Add(2*time.Hour)); err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	report = handler.Rejections.Report()
+	if report.Outcomes[RejectionLost] != 2 || report.Outcomes[RejectionRedelivered] != 1 {
+		t.Errorf("incorrect outcomes after the loss window: %v", report.Outcomes)
+	}
+	for _, r := range report.Rejections {
+		if r.EventUUID == "succeeds" && r.Rejections != 2 {
+			t.Errorf("rejections of succeeds: got %d want 2", r.Rejections)
+		}
+	}
+}
+
+func TestRejectionTrackerEvictsOldest(t *testing.T) {
+	tracker := NewRejectionTracker(2, time.Hour)
+	now := time.Now()
+	for _, eventUUID := range []string{"a", "b", "c"} {
+		tracker.Rejected(eventUUID, RejectedQueueFull, now)
+	}
+	report := tracker.Report()
+	if len(report.Rejections) != 2 || report.Rejections[0].EventUUID != "c" || report.Rejections[1].EventUUID != "b" {
+		t.Errorf("incorrect rejections kept: %+v", report.Rejections)
+	}
+}
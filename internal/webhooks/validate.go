@@ -0,0 +1,69 @@
+package webhooks
+
+import (
+	"context"
+	"fmt"
+	"gusto-webhook-guide/internal/apierror"
+	"gusto-webhook-guide/internal/contextkeys"
+	"gusto-webhook-guide/internal/models"
+	"gusto-webhook-guide/internal/schema"
+	"gusto-webhook-guide/internal/worker"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// invalidSchemaResponse is the body of a 400 for an event, or an event of a
+// batch, that fails its event type's schema.
+type invalidSchemaResponse struct {
+	apierror.Error
+	Index      *int               `json:"index,omitempty"` // Set for batches.
+	EventUUID  string             `json:"event_uuid"`
+	EventType  string             `json:"event_type"`
+	Violations []schema.Violation `json:"violations"`
+}
+
+// validate checks a validated envelope against its event type's schema, if
+// Schemas has one, and returns the violations.
+func (h *Handler) validate(payload map[string]any) []schema.Violation {
+	if h.Schemas == nil {
+		return nil
+	}
+	violations, _ := h.Schemas.Validate(payload["event_type"].(string), payload)
+	return violations
+}
+
+// rejectInvalid answers an event that fails its schema with a 400. index is
+// the event's position in a batch, or nil.
+func (h *Handler) rejectInvalid(w http.ResponseWriter, r *http.Request, payload map[string]any, index *int, violations []schema.Violation) {
+	eventUUID, eventType := payload["uuid"].(string), payload["event_type"].(string)
+	schemaViolations.WithLabelValues(eventType, "rejected").Inc()
+	h.Logger.Warn("Rejecting event that fails its schema", "event_uuid", eventUUID, "event_type", eventType, "violations", violations)
+	message := fmt.Sprintf("event does not match the %s schema", eventType)
+	if index != nil {
+		message = fmt.Sprintf("event %d: %s", *index, message)
+	}
+	apierror.WriteJSON(w, http.StatusBadRequest, invalidSchemaResponse{
+		Error:      apierror.New(r, apierror.CodeSchemaViolation, message),
+		Index:      index,
+		EventUUID:  eventUUID,
+		EventType:  eventType,
+		Violations: violations,
+	})
+}
+
+// deadLetterInvalid hands an event that fails its schema to DeadLetter
+// instead of queueing it.
+func (h *Handler) deadLetterInvalid(r *http.Request, payload map[string]any, body []byte, violations []schema.Violation) {
+	eventUUID, eventType := payload["uuid"].(string), payload["event_type"].(string)
+	schemaViolations.WithLabelValues(eventType, "dead_lettered").Inc()
+	h.Logger.Warn("Dead-lettering event that fails its schema", "event_uuid", eventUUID, "event_type", eventType, "violations", violations)
+	messages := make([]string, len(violations))
+	for i, v := range violations {
+		messages[i] = v.String()
+	}
+	requestID, _ := r.Context().Value(contextkeys.RequestIDKey).(string)
+	job := models.Job{Payload: body, ReceivedAt: time.Now().UTC(), RequestID: requestID}
+	h.DeadLetter(context.WithoutCancel(r.Context()), job,
+		worker.Permanentf("%w: does not match the %s schema: %s", worker.ErrSchema, eventType, strings.Join(messages, "; ")))
+}
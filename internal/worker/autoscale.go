@@ -0,0 +1,130 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// AutoscaleConfig bounds and tunes Pool.RunAutoscaler.
+type AutoscaleConfig struct {
+	Min, Max int
+	// Interval is how often the worker count is reconsidered.
+	Interval time.Duration
+	// ScaleUpAt and ScaleDownAt are the queue occupancies, from 0 to 1, at
+	// or above which workers are added and at or below which one is retired.
+	// Queues that don't report their depth are scaled on latency and
+	// throughput alone.
+	ScaleUpAt, ScaleDownAt float64
+	// LatencyTarget, if set, adds workers while jobs wait in the queue and
+	// processing takes longer than this on average, e.g. because a slow
+	// provider API is keeping workers busy.
+	LatencyTarget time.Duration
+}
+
+// DefaultAutoscaleConfig returns a config scaling between min and max.
+func DefaultAutoscaleConfig(min, max int) AutoscaleConfig {
+	return AutoscaleConfig{Min: min, Max: max, Interval: 15 * time.Second, ScaleUpAt: 0.5, ScaleDownAt: 0.1}
+}
+
+// Validate reports whether the config can be used.
+func (c AutoscaleConfig) Validate() error {
+	switch {
+	case c.Min < 1 || c.Max < c.Min:
+		return fmt.Errorf("autoscale bounds must satisfy 1 <= min <= max, got %d and %d", c.Min, c.Max)
+	case c.Interval <= 0:
+		return fmt.Errorf("autoscale interval must be positive, got %v", c.Interval)
+	case c.ScaleDownAt < 0 || c.ScaleDownAt >= c.ScaleUpAt || c.ScaleUpAt > 1:
+		return fmt.Errorf("autoscale occupancies must satisfy 0 <= down < up <= 1, got %v and %v", c.ScaleDownAt, c.ScaleUpAt)
+	}
+	return nil
+}
+
+// depther is implemented by queues that can report how full they are.
+type depther interface {
+	Depth() (length, capacity int)
+}
+
+// RunAutoscaler adjusts the number of workers every cfg.Interval until ctx
+// is cancelled, between cfg.Min and cfg.Max. It complements Start, which
+// sets the initial count. Ordered pools (see SetOrdered) keep their count.
+func (p *Pool) RunAutoscaler(ctx context.Context, cfg AutoscaleConfig) {
+	if p.ordered {
+		p.logger.Warn("Ordered worker pools don't autoscale")
+		return
+	}
+	ticker := time.NewTicker(cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			p.autoscale(cfg)
+		case <-ctx.Done():
+			return
+		case <-p.ctx.Done():
+			return
+		}
+	}
+}
+
+// autoscale adds workers while the queue backs up, growing by half at a
+// time, and retires one at a time once it has drained.
+func (p *Pool) autoscale(cfg AutoscaleConfig) {
+	n := p.Workers()
+	avg, jobs := p.takeLatency()
+
+	occupancy := -1.0 // Unknown.
+	if q, ok := p.queue.(depther); ok {
+		if length, capacity := q.Depth(); capacity > 0 {
+			occupancy = float64(length) / float64(capacity)
+		}
+	}
+	backlog := occupancy != 0 // Unknown counts, since workers are busy.
+	slow := cfg.LatencyTarget > 0 && avg > cfg.LatencyTarget
+
+	target := n
+	switch {
+	case n < cfg.Min:
+		target = cfg.Min
+	case n > cfg.Max:
+		target = cfg.Max
+	case occupancy >= cfg.ScaleUpAt || (slow && backlog):
+		target = min(n+max(n/2, 1), cfg.Max)
+	case slow:
+		// Busy workers aren't retired while processing is slow.
+	case occupancy >= 0 && occupancy <= cfg.ScaleDownAt, occupancy < 0 && jobs < n:
+		target = max(n-1, cfg.Min)
+	}
+	if target == n {
+		return
+	}
+
+	p.logger.Info("Autoscaling workers", "from", n, "to", target, "occupancy", occupancy, "avg_latency", avg, "jobs", jobs)
+	for ; n < target; n++ {
+		p.addWorker()
+	}
+	for ; n > target; n-- {
+		p.removeWorker()
+	}
+}
+
+// observeLatency records how long processing one job took.
+func (p *Pool) observeLatency(d time.Duration) {
+	p.latencyMu.Lock()
+	defer p.latencyMu.Unlock()
+	p.latencyTotal += d
+	p.latencyJobs++
+}
+
+// takeLatency returns the average processing time and the number of jobs
+// processed since it was last called.
+func (p *Pool) takeLatency() (time.Duration, int) {
+	p.latencyMu.Lock()
+	defer p.latencyMu.Unlock()
+	total, jobs := p.latencyTotal, p.latencyJobs
+	p.latencyTotal, p.latencyJobs = 0, 0
+	if jobs == 0 {
+		return 0, 0
+	}
+	return total / time.Duration(jobs), jobs
+}
@@ -0,0 +1,124 @@
+package worker
+
+import (
+	"context"
+	"gusto-webhook-guide/internal/models"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+// depthQueue is a JobQueue that never yields jobs but reports a fixed depth.
+type depthQueue struct{ length, capacity int }
+
+func (q *depthQueue) Enqueue(context.Context, models.Job, time.Duration) error { return nil }
+
+func (q *depthQueue) Dequeue(ctx context.Context) (models.Job, func() error, error) {
+	<-ctx.Done()
+	return models.Job{}, nil, ctx.Err()
+}
+
+func (q *depthQueue) Depth() (int, int) { return q.length, q.capacity }
+
+func TestAutoscale(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	cfg := DefaultAutoscaleConfig(2, 6)
+	cfg.LatencyTarget = time.Second
+
+	testCases := []struct {
+		name            string
+		workers         int
+		length          int
+		latency         time.Duration // Of one job processed in the interval.
+		expectedWorkers int
+	}{
+		{name: "Below Minimum", workers: 1, length: 0, expectedWorkers: 2},
+		{name: "Backed Up", workers: 4, length: 60, expectedWorkers: 6},
+		{name: "Backed Up At Maximum", workers: 6, length: 100, expectedWorkers: 6},
+		{name: "Slow With Backlog", workers: 2, length: 20, latency: 2 * time.Second, expectedWorkers: 3},
+		{name: "Steady", workers: 3, length: 30, expectedWorkers: 3},
+		{name: "Drained", workers: 3, length: 5, expectedWorkers: 2},
+		{name: "Drained At Minimum", workers: 2, length: 0, expectedWorkers: 2},
+		{name: "Drained But Slow", workers: 3, length: 0, latency: 2 * time.Second, expectedWorkers: 3},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			pool := NewPool(1, 1, logger, NewIdempotencyStore(), stubProcessor)
+			defer pool.Stop()
+			pool.SetQueue(&depthQueue{length: tc.length, capacity: 100})
+			pool.Start(tc.workers)
+			if tc.latency > 0 {
+				pool.observeLatency(tc.latency)
+			}
+
+			pool.autoscale(cfg)
+			if got := pool.Workers(); got != tc.expectedWorkers {
+				t.Errorf("incorrect worker count: got %d want %d", got, tc.expectedWorkers)
+			}
+		})
+	}
+}
+
+func TestAutoscaleWithoutDepth(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	pool := NewPool(1, 1, logger, NewIdempotencyStore(), stubProcessor)
+	defer pool.Stop()
+	pool.SetQueue(queueOnly{&depthQueue{}})
+	pool.Start(3)
+
+	// Fewer jobs than workers in an interval means some sat idle.
+	pool.observeLatency(time.Millisecond)
+	pool.autoscale(DefaultAutoscaleConfig(1, 5))
+	if got := pool.Workers(); got != 2 {
+		t.Errorf("incorrect worker count: got %d want 2", got)
+	}
+}
+
+// queueOnly hides any optional interfaces of the wrapped queue.
+type queueOnly struct{ JobQueue }
+
+func TestAutoscaleRetiresIdleWorkers(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	pool := NewPool(10, 1, logger, NewIdempotencyStore(), stubProcessor)
+	pool.Start(3)
+	pool.removeWorker()
+
+	// The retired worker stops waiting on the empty in-memory queue.
+	done := make(chan struct{})
+	go func() {
+		pool.Stop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop blocked on a retired worker")
+	}
+	if got := pool.Workers(); got != 0 {
+		t.Errorf("incorrect worker count after Stop: got %d want 0", got)
+	}
+}
+
+func TestAutoscaleConfigValidate(t *testing.T) {
+	testCases := []struct {
+		name        string
+		cfg         AutoscaleConfig
+		expectError bool
+	}{
+		{name: "Default", cfg: DefaultAutoscaleConfig(1, 10)},
+		{name: "Min Above Max", cfg: DefaultAutoscaleConfig(5, 2), expectError: true},
+		{name: "Zero Min", cfg: DefaultAutoscaleConfig(0, 2), expectError: true},
+		{name: "Inverted Occupancies", cfg: AutoscaleConfig{Min: 1, Max: 2, Interval: time.Second, ScaleUpAt: 0.1, ScaleDownAt: 0.5}, expectError: true},
+		{name: "Zero Interval", cfg: AutoscaleConfig{Min: 1, Max: 2, ScaleUpAt: 0.5}, expectError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.cfg.Validate(); (err != nil) != tc.expectError {
+				t.Errorf("unexpected error result: got %v want error %v", err, tc.expectError)
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"gusto-webhook-guide/internal/models"
+	"runtime/debug"
+	"slices"
+	"time"
+)
+
+// BatchHandler processes several events at once, e.g. with a single bulk
+// upsert downstream. It returns nil if every event succeeded, or one error
+// per event, in order, classified like a Processor's.
+type BatchHandler func(ctx context.Context, events []models.WebhookEvent) []error
+
+// BatchProcessor is implemented by Processors that can process several
+// events at once. Pools with batching enabled use it instead of Process.
+type BatchProcessor interface {
+	ProcessBatch(ctx context.Context, events []models.WebhookEvent) []error
+}
+
+// SetBatching makes each worker take up to size jobs off the queue at once,
+// waiting at most maxWait after the first for the rest, and process them as
+// a batch if the Processor is a BatchProcessor. Each job is still claimed,
+// retried and dead-lettered on its own. A batch counts against each event
+// type's concurrency limit once, its attempt shares a single job timeout,
+// and job middleware doesn't run around it. Ordered pools don't batch. A
+// size below 2 disables batching, the default. It must be called before Start.
+func (p *Pool) SetBatching(size int, maxWait time.Duration) {
+	p.batchSize = size
+	p.batchWait = maxWait
+}
+
+// batchWorker is a worker that takes jobs off the queue in batches.
+func (p *Pool) batchWorker(ctx context.Context, id int) {
+	defer p.wg.Done()
+	p.logger.Info("Worker started", "worker_id", id, "batch_size", p.batchSize)
+
+	for ctx.Err() == nil {
+		p.waitResumed(ctx)
+		jobs, acks, err := p.dequeueBatch(ctx)
+		if err != nil {
+			if errors.Is(err, ErrQueueClosed) || ctx.Err() != nil {
+				break
+			}
+			p.logger.Error("Worker failed to dequeue job", "worker_id", id, "error", err)
+			select {
+			case <-time.After(time.Second): // Don't spin while the queue is unavailable.
+			case <-ctx.Done():
+			}
+			continue
+		}
+
+		p.inFlight.Add(int64(len(jobs)))
+		p.handleBatch(id, jobs)
+		for _, ack := range acks {
+			if err := ack(); err != nil {
+				p.logger.Error("Worker failed to acknowledge job", "worker_id", id, "error", err)
+			}
+		}
+		p.inFlight.Add(-int64(len(jobs)))
+	}
+	p.logger.Info("Worker stopped", "worker_id", id)
+}
+
+// dequeueBatch waits for a job, then takes up to batchSize-1 more that
+// arrive within batchWait. It returns an error only if the first Dequeue
+// fails.
+func (p *Pool) dequeueBatch(ctx context.Context) ([]models.Job, []func() error, error) {
+	job, ack, err := p.queue.Dequeue(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	jobs, acks := []models.Job{job}, []func() error{ack}
+
+	waitCtx, cancel := context.WithTimeout(ctx, p.batchWait)
+	defer cancel()
+	for len(jobs) < p.batchSize {
+		job, ack, err := p.queue.Dequeue(waitCtx)
+		if err != nil {
+			break // Out of time, or the queue closed; process what we have.
+		}
+		jobs, acks = append(jobs, job), append(acks, ack)
+	}
+	return jobs, acks, nil
+}
+
+// handleBatch claims a batch of jobs, processes the claimed events together
+// and records each outcome.
+func (p *Pool) handleBatch(id int, jobs []models.Job) {
+	bp, ok := p.processor.(BatchProcessor)
+	if !ok {
+		for _, job := range jobs {
+			p.handleJob(id, job)
+		}
+		return
+	}
+
+	var batch []*claimedJob
+	for _, job := range jobs {
+		c, ok := p.claimJob(id, job)
+		if !ok {
+			continue
+		}
+		defer c.unlock()
+		if c.err != nil {
+			p.finishJob(c, c.err)
+			continue
+		}
+		batch = append(batch, c)
+	}
+	if len(batch) == 0 {
+		return
+	}
+
+	// Take one concurrency slot per event type, always in the same order so
+	// that workers holding some don't wait on each other.
+	var types []string
+	for _, c := range batch {
+		types = append(types, c.event.EventType)
+	}
+	slices.Sort(types)
+	for _, eventType := range slices.Compact(types) {
+		done, err := p.acquire(p.jobsCtx, eventType)
+		if err != nil {
+			for _, c := range batch {
+				p.finishJob(c, Transientf("waiting for concurrency slot: %w", err))
+			}
+			return
+		}
+		defer done()
+	}
+
+	start := time.Now()
+	errs := p.processBatch(bp, batch)
+	elapsed := time.Since(start)
+	for i, c := range batch {
+		c.elapsed = elapsed
+		p.finishJob(c, errs[i])
+	}
+}
+
+// processBatch hands the claimed events to bp, returning one error per
+// event. Like processEvent, its context ends at the job timeout, or when
+// Stop gives up waiting for it.
+func (p *Pool) processBatch(bp BatchProcessor, batch []*claimedJob) []error {
+	events := make([]models.WebhookEvent, len(batch))
+	for i, c := range batch {
+		events[i] = c.event
+	}
+	p.logger.Info("Worker processing batch", "events", len(events))
+
+	ctx := context.Background()
+	var cancel context.CancelFunc
+	if p.jobTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, p.jobTimeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+	stop := context.AfterFunc(p.jobsCtx, cancel)
+	defer stop()
+
+	start := time.Now()
+	errs := p.callBatchHandler(ctx, bp, events)
+	elapsed := time.Since(start)
+	for range events {
+		p.observeLatency(elapsed / time.Duration(len(events)))
+	}
+
+	if errs == nil {
+		errs = make([]error, len(events))
+	} else if len(errs) != len(events) {
+		// Which events failed is unknown, and another attempt won't say.
+		err := Permanentf("batch handler returned %d errors for %d events", len(errs), len(events))
+		errs = slices.Repeat([]error{err}, len(events))
+	}
+	for i, err := range errs {
+		if err != nil && ctx.Err() != nil && !IsPermanent(err) && !IsTransient(err) {
+			// An interrupted attempt may well succeed next time.
+			errs[i] = Transientf("processing interrupted: %w", err)
+		}
+		p.metrics.JobProcessed(events[i].EventType, errorClass(errs[i]), elapsed/time.Duration(len(events)))
+	}
+	return errs
+}
+
+// callBatchHandler runs bp on events. A panic is logged with its stack and
+// fails every event with a transient error, like callHandler.
+func (p *Pool) callBatchHandler(ctx context.Context, bp BatchProcessor, events []models.WebhookEvent) (errs []error) {
+	defer func() {
+		if r := recover(); r != nil {
+			handlerPanics.WithLabelValues("batch").Inc()
+			p.logger.Error("Batch handler panicked", "events", len(events), "panic", r, "stack", string(debug.Stack()))
+			errs = slices.Repeat([]error{Transientf("batch handler panicked: %v", r)}, len(events))
+		}
+	}()
+	return bp.ProcessBatch(ctx, events)
+}
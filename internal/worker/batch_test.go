@@ -0,0 +1,105 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"gusto-webhook-guide/internal/models"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestPoolBatching(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	registry := NewRegistry()
+	var batches [][]string
+	registry.OnBatch("employee.*", func(_ context.Context, events []models.WebhookEvent) []error {
+		var uuids []string
+		for _, event := range events {
+			uuids = append(uuids, event.UUID)
+		}
+		batches = append(batches, uuids)
+		errs := make([]error, len(events))
+		errs[1] = Permanentf("bad employee")
+		return errs
+	})
+	var single []string
+	registry.On("company.updated", func(_ context.Context, event models.WebhookEvent) error {
+		single = append(single, event.UUID)
+		return nil
+	})
+
+	store := NewIdempotencyStore()
+	pool := NewPool(10, 0, logger, store, registry)
+	pool.SetBatching(5, 50*time.Millisecond)
+	for _, event := range []models.WebhookEvent{
+		{UUID: "e1", EventType: "employee.created"},
+		{UUID: "c1", EventType: "company.updated"},
+		{UUID: "e2", EventType: "employee.updated"},
+		{UUID: "e1", EventType: "employee.created"}, // A duplicate.
+		{UUID: "e3", EventType: "employee.terminated"},
+	} {
+		payload, _ := json.Marshal(event)
+		pool.JobQueue <- models.Job{Payload: payload}
+	}
+	pool.Start(1)
+	pool.Stop()
+
+	if want := [][]string{{"e1", "e2", "e3"}}; !slices.EqualFunc(batches, want, slices.Equal) {
+		t.Errorf("incorrect batches: got %v want %v", batches, want)
+	}
+	if want := []string{"c1"}; !slices.Equal(single, want) {
+		t.Errorf("incorrect single events: got %v want %v", single, want)
+	}
+	for uuid, status := range map[string]Status{"e1": StatusSucceeded, "e2": StatusPermanentFailure, "e3": StatusSucceeded, "c1": StatusSucceeded} {
+		if rec, found, _ := store.Get(context.Background(), uuid); !found || rec.Status != status {
+			t.Errorf("incorrect record for %s: got %+v (found %v) want status %q", uuid, rec, found, status)
+		}
+	}
+	if entries := pool.DeadLetters().List(DeadLetterFilter{}); len(entries) != 1 || entries[0].EventUUID != "e2" {
+		t.Errorf("incorrect dead letters: got %+v", entries)
+	}
+}
+
+func TestPoolBatchingWaitsAtMostMaxWait(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	processed := make(chan []models.WebhookEvent, 1)
+	registry := NewRegistry()
+	registry.OnBatch("*", func(_ context.Context, events []models.WebhookEvent) []error {
+		processed <- events
+		return nil
+	})
+	pool := NewPool(10, 0, logger, NewIdempotencyStore(), registry)
+	pool.SetBatching(5, 20*time.Millisecond)
+	pool.Start(1)
+	defer pool.Stop()
+
+	payload, _ := json.Marshal(models.WebhookEvent{UUID: "alone", EventType: "company.updated"})
+	pool.JobQueue <- models.Job{Payload: payload}
+	select {
+	case events := <-processed:
+		if len(events) != 1 || events[0].UUID != "alone" {
+			t.Errorf("incorrect batch: got %+v", events)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("a partial batch was not processed after the max wait")
+	}
+}
+
+func TestRegistryProcessBatchErrorCount(t *testing.T) {
+	registry := NewRegistry()
+	registry.OnBatch("employee.*", func(context.Context, []models.WebhookEvent) []error {
+		return []error{nil} // One error for two events.
+	})
+	errs := registry.ProcessBatch(context.Background(), []models.WebhookEvent{
+		{UUID: "a", EventType: "employee.created"},
+		{UUID: "b", EventType: "employee.updated"},
+		{UUID: "c", EventType: "payroll.processed"},
+	})
+	if len(errs) != 3 || !IsPermanent(errs[0]) || !IsPermanent(errs[1]) || !errors.Is(errs[2], ErrUnhandled) {
+		t.Errorf("incorrect errors: got %v", errs)
+	}
+}
@@ -0,0 +1,65 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sync/semaphore"
+)
+
+// SetConcurrencyLimits caps how many jobs of each event type may be processed
+// at once, e.g. to protect a fragile downstream ledger. Event types without a
+// limit are only bounded by the number of workers. It must be called before
+// Start. A worker waiting for a slot is not available for other jobs.
+func (p *Pool) SetConcurrencyLimits(limits map[string]int64) {
+	p.limits = make(map[string]*semaphore.Weighted, len(limits))
+	for eventType, max := range limits {
+		p.limits[eventType] = semaphore.NewWeighted(max)
+		concurrencyLimit.WithLabelValues(eventType).Set(float64(max))
+	}
+}
+
+// acquire blocks until a processing slot for eventType is free, returning a
+// function that releases it.
+func (p *Pool) acquire(ctx context.Context, eventType string) (func(), error) {
+	sem, limited := p.limits[eventType]
+	if limited && !sem.TryAcquire(1) {
+		concurrencyWaits.WithLabelValues(eventType).Inc()
+		if err := sem.Acquire(ctx, 1); err != nil {
+			return nil, err
+		}
+	}
+
+	gauge := inflightJobs.WithLabelValues(eventType)
+	gauge.Inc()
+	return func() {
+		gauge.Dec()
+		if limited {
+			sem.Release(1)
+		}
+	}, nil
+}
+
+// ParseConcurrencyLimits parses a comma-separated list of event_type=max
+// pairs, e.g. "payroll.processed=2,company.updated=5".
+func ParseConcurrencyLimits(raw string) (map[string]int64, error) {
+	limits := make(map[string]int64)
+	for _, pair := range strings.Split(raw, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		eventType, rawMax, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(eventType) == "" {
+			return nil, fmt.Errorf("invalid concurrency limit %q: want event_type=max", pair)
+		}
+		max, err := strconv.ParseInt(strings.TrimSpace(rawMax), 10, 64)
+		if err != nil || max < 1 {
+			return nil, fmt.Errorf("invalid concurrency limit %q: max must be a positive integer", pair)
+		}
+		limits[strings.TrimSpace(eventType)] = max
+	}
+	return limits, nil
+}
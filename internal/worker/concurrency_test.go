@@ -0,0 +1,85 @@
+package worker
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseConcurrencyLimits(t *testing.T) {
+	testCases := []struct {
+		name      string
+		raw       string
+		expected  map[string]int64
+		expectErr bool
+	}{
+		{
+			name:     "Success - Multiple Limits",
+			raw:      "payroll.processed=2, company.updated=5",
+			expected: map[string]int64{"payroll.processed": 2, "company.updated": 5},
+		},
+		{
+			name:     "Success - Empty",
+			raw:      "",
+			expected: map[string]int64{},
+		},
+		{
+			name:      "Failure - Missing Separator",
+			raw:       "payroll.processed",
+			expectErr: true,
+		},
+		{
+			name:      "Failure - Non-Positive Limit",
+			raw:       "payroll.processed=0",
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			limits, err := ParseConcurrencyLimits(tc.raw)
+			if (err != nil) != tc.expectErr {
+				t.Fatalf("unexpected error result: got %v, want error %v", err, tc.expectErr)
+			}
+			if !tc.expectErr && !reflect.DeepEqual(limits, tc.expected) {
+				t.Errorf("incorrect limits: got %v want %v", limits, tc.expected)
+			}
+		})
+	}
+}
+
+func TestConcurrencyLimits(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	pool := NewPool(1, 1, logger, NewIdempotencyStore(), stubProcessor)
+	pool.SetConcurrencyLimits(map[string]int64{"payroll.processed": 1})
+
+	release, err := pool.acquire(context.Background(), "payroll.processed")
+	if err != nil {
+		t.Fatalf("first acquire failed: %v", err)
+	}
+
+	// A second payroll job must wait while the only slot is taken.
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := pool.acquire(ctx, "payroll.processed"); err == nil {
+		t.Errorf("expected second acquire to block until the context expired")
+	}
+
+	// Event types without a limit are unaffected.
+	otherRelease, err := pool.acquire(context.Background(), "company.updated")
+	if err != nil {
+		t.Fatalf("acquire for unlimited event type failed: %v", err)
+	}
+	otherRelease()
+
+	// Once released, the slot can be taken again.
+	release()
+	release, err = pool.acquire(context.Background(), "payroll.processed")
+	if err != nil {
+		t.Fatalf("acquire after release failed: %v", err)
+	}
+	release()
+}
@@ -0,0 +1,107 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrPaused is returned by Drain while the pool is paused, since paused
+// workers never empty the queue.
+var ErrPaused = errors.New("worker pool is paused")
+
+// drainPollInterval is how often Drain checks whether the pool is idle.
+const drainPollInterval = 100 * time.Millisecond
+
+// PoolStatus describes what the workers are doing.
+type PoolStatus struct {
+	Workers  int  `json:"workers"`
+	Paused   bool `json:"paused"`
+	InFlight int  `json:"in_flight"`
+	// Queued is the number of jobs waiting, or -1 if the queue can't tell.
+	Queued int `json:"queued"`
+	// RetryHolds are the reasons scheduled retries are held, if any.
+	RetryHolds []RetryHold `json:"retry_holds,omitempty"`
+}
+
+// Pause stops workers taking new jobs off the queue, e.g. during an
+// incident. Jobs already being processed finish, and queued jobs stay
+// queued until Resume.
+func (p *Pool) Pause() {
+	p.pauseMu.Lock()
+	defer p.pauseMu.Unlock()
+	if p.resumed == nil {
+		p.resumed = make(chan struct{})
+		p.logger.Warn("Worker pool paused")
+	}
+}
+
+// Resume lets workers take jobs again after Pause.
+func (p *Pool) Resume() {
+	p.pauseMu.Lock()
+	defer p.pauseMu.Unlock()
+	if p.resumed != nil {
+		close(p.resumed)
+		p.resumed = nil
+		p.logger.Info("Worker pool resumed")
+	}
+}
+
+// Paused reports whether the pool is paused.
+func (p *Pool) Paused() bool {
+	p.pauseMu.Lock()
+	defer p.pauseMu.Unlock()
+	return p.resumed != nil
+}
+
+// waitResumed blocks while the pool is paused, until Resume or ctx is done.
+func (p *Pool) waitResumed(ctx context.Context) {
+	p.pauseMu.Lock()
+	resumed := p.resumed
+	p.pauseMu.Unlock()
+	if resumed == nil {
+		return
+	}
+	select {
+	case <-resumed:
+	case <-ctx.Done():
+	}
+}
+
+// Drain waits until no jobs are queued or being processed, returning
+// ctx.Err() if that takes too long. Workers keep taking new jobs meanwhile,
+// and retries waiting for their delay aren't counted. For queues that can't
+// report their depth, only jobs being processed are waited for.
+func (p *Pool) Drain(ctx context.Context) error {
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for {
+		status := p.Status()
+		if status.Paused {
+			return ErrPaused
+		}
+		if status.InFlight == 0 && status.Queued <= 0 {
+			return nil
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Status reports what the workers are doing.
+func (p *Pool) Status() PoolStatus {
+	status := PoolStatus{
+		Workers:    p.Workers(),
+		Paused:     p.Paused(),
+		InFlight:   int(p.inFlight.Load()),
+		Queued:     -1,
+		RetryHolds: p.RetryHolds(),
+	}
+	if q, ok := p.queue.(depther); ok {
+		status.Queued, _ = q.Depth()
+	}
+	return status
+}
@@ -0,0 +1,48 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"gusto-webhook-guide/internal/models"
+	"io"
+	"log/slog"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPoolPauseResumeDrain(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	var processed atomic.Int32
+	processor := ProcessorFunc(func(context.Context, models.WebhookEvent) error {
+		processed.Add(1)
+		return nil
+	})
+	pool := NewPool(10, 2, logger, NewIdempotencyStore(), processor)
+	defer pool.Stop()
+
+	pool.Pause()
+	pool.Start(2)
+	for _, uuid := range []string{"a", "b", "c"} {
+		payload, _ := json.Marshal(models.WebhookEvent{UUID: uuid, EventType: "company.updated"})
+		pool.JobQueue <- models.Job{Payload: payload}
+	}
+	if err := pool.Drain(context.Background()); !errors.Is(err, ErrPaused) {
+		t.Errorf("incorrect error draining a paused pool: got %v want %v", err, ErrPaused)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if status := pool.Status(); processed.Load() != 0 || status.Queued != 3 || !status.Paused {
+		t.Fatalf("paused pool took jobs: processed %d, status %+v", processed.Load(), status)
+	}
+
+	pool.Resume()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := pool.Drain(ctx); err != nil {
+		t.Fatalf("Drain failed: %v", err)
+	}
+	if got := processed.Load(); got != 3 {
+		t.Errorf("incorrect number of jobs processed: got %d want 3", got)
+	}
+}
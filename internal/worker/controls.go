@@ -0,0 +1,226 @@
+package worker
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// defaultControlsSync is how often a pool with a ControlStore picks up
+// toggles set on other replicas, unless told otherwise.
+const defaultControlsSync = 5 * time.Second
+
+// Controls are the operational toggles a ControlStore shares.
+type Controls struct {
+	Paused     bool
+	RetryHolds []RetryHold // Sorted by source.
+}
+
+// ControlStore shares the toggles operators set through the admin API
+// between replicas, so that pausing the workers or holding the retries of
+// one does so for them all. See Pool.SetControlStore.
+type ControlStore interface {
+	// SetPaused records whether workers are paused.
+	SetPaused(ctx context.Context, paused bool) error
+	// PutRetryHold records a hold on retries, replacing any from its source.
+	PutRetryHold(ctx context.Context, hold RetryHold) error
+	// DeleteRetryHold removes source's hold on retries; removing none is not
+	// an error.
+	DeleteRetryHold(ctx context.Context, source string) error
+	// Controls returns the recorded toggles.
+	Controls(ctx context.Context) (Controls, error)
+}
+
+// SetControlStore makes PauseAll, ResumeAll, HoldAllRetries and
+// ReleaseAllRetries record their toggles in s, and has the pool check s
+// every interval (every 5 seconds if interval is not positive) for changes
+// made on other replicas. Pausing, resuming, holding and releasing on its
+// own, as Stop and the status page check do, stays local. It must be
+// called before Start.
+func (p *Pool) SetControlStore(s ControlStore, interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultControlsSync
+	}
+	p.controls = s
+	p.controlsSync = interval
+}
+
+// PauseAll pauses the workers of every replica sharing the pool's
+// ControlStore, or just this one without one. This replica is paused even
+// if recording the pause fails.
+func (p *Pool) PauseAll(ctx context.Context) error {
+	return p.setPausedAll(ctx, true)
+}
+
+// ResumeAll resumes the workers paused by PauseAll on every replica, like
+// PauseAll.
+func (p *Pool) ResumeAll(ctx context.Context) error {
+	return p.setPausedAll(ctx, false)
+}
+
+func (p *Pool) setPausedAll(ctx context.Context, paused bool) error {
+	if paused {
+		p.Pause()
+	} else {
+		p.Resume()
+	}
+	if p.controls == nil {
+		return nil
+	}
+	p.controlsMu.Lock()
+	defer p.controlsMu.Unlock()
+	if err := p.controls.SetPaused(ctx, paused); err != nil {
+		return fmt.Errorf("sharing pause: %w", err)
+	}
+	p.shared.Paused = paused
+	return nil
+}
+
+// HoldAllRetries holds retries on every replica sharing the pool's
+// ControlStore, like HoldRetries, or just on this one without one. This
+// replica holds them even if recording the hold fails.
+func (p *Pool) HoldAllRetries(ctx context.Context, source, reason string) error {
+	hold := p.holdRetries(RetryHold{Source: source, Reason: reason, Since: time.Now().UTC()})
+	if p.controls == nil {
+		return nil
+	}
+	p.controlsMu.Lock()
+	defer p.controlsMu.Unlock()
+	if err := p.controls.PutRetryHold(ctx, hold); err != nil {
+		return fmt.Errorf("sharing retry hold: %w", err)
+	}
+	p.shared.RetryHolds = slices.DeleteFunc(p.shared.RetryHolds, func(h RetryHold) bool { return h.Source == source })
+	p.shared.RetryHolds = append(p.shared.RetryHolds, hold)
+	return nil
+}
+
+// ReleaseAllRetries releases source's hold on retries on every replica,
+// like HoldAllRetries.
+func (p *Pool) ReleaseAllRetries(ctx context.Context, source string) error {
+	p.ReleaseRetries(source)
+	if p.controls == nil {
+		return nil
+	}
+	p.controlsMu.Lock()
+	defer p.controlsMu.Unlock()
+	if err := p.controls.DeleteRetryHold(ctx, source); err != nil {
+		return fmt.Errorf("sharing retry release: %w", err)
+	}
+	p.shared.RetryHolds = slices.DeleteFunc(p.shared.RetryHolds, func(h RetryHold) bool { return h.Source == source })
+	return nil
+}
+
+// syncControls applies the ControlStore's toggles until Stop. Only changes
+// are applied, so that a replica paused on its own isn't resumed by the
+// next check.
+func (p *Pool) syncControls() {
+	defer close(p.controlsDone)
+	ticker := time.NewTicker(p.controlsSync)
+	defer ticker.Stop()
+	for {
+		p.controlsMu.Lock()
+		controls, err := p.controls.Controls(p.ctx)
+		switch {
+		case errors.Is(err, context.Canceled):
+		case err != nil:
+			controlsSyncFailures.Inc()
+			p.logger.Error("Failed to read shared worker controls", "error", err)
+		default:
+			p.applyControls(controls)
+		}
+		p.controlsMu.Unlock()
+
+		select {
+		case <-ticker.C:
+		case <-p.ctx.Done():
+			return
+		}
+	}
+}
+
+// applyControls applies what changed between the shared toggles last seen
+// and controls. p.controlsMu must be held.
+func (p *Pool) applyControls(controls Controls) {
+	if controls.Paused != p.shared.Paused {
+		if controls.Paused {
+			p.Pause()
+		} else {
+			p.Resume()
+		}
+	}
+	for _, hold := range controls.RetryHolds {
+		i := slices.IndexFunc(p.shared.RetryHolds, func(h RetryHold) bool { return h.Source == hold.Source })
+		if i < 0 || p.shared.RetryHolds[i].Reason != hold.Reason {
+			p.holdRetries(hold)
+		}
+	}
+	for _, hold := range p.shared.RetryHolds {
+		if !slices.ContainsFunc(controls.RetryHolds, func(h RetryHold) bool { return h.Source == hold.Source }) {
+			p.ReleaseRetries(hold.Source)
+		}
+	}
+	p.shared = controls
+}
+
+// RedisControlStore is a ControlStore in Redis, under keys namespaced with
+// a prefix.
+type RedisControlStore struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+var _ ControlStore = (*RedisControlStore)(nil)
+
+// NewRedisControlStore creates a RedisControlStore whose keys are namespaced
+// with prefix.
+func NewRedisControlStore(client redis.UniversalClient, prefix string) *RedisControlStore {
+	return &RedisControlStore{client: client, prefix: prefix}
+}
+
+// SetPaused implements ControlStore.
+func (s *RedisControlStore) SetPaused(ctx context.Context, paused bool) error {
+	if !paused {
+		return s.client.Del(ctx, s.prefix+"paused").Err()
+	}
+	return s.client.Set(ctx, s.prefix+"paused", time.Now().UTC().Format(time.RFC3339), 0).Err()
+}
+
+// PutRetryHold implements ControlStore.
+func (s *RedisControlStore) PutRetryHold(ctx context.Context, hold RetryHold) error {
+	data, err := json.Marshal(hold)
+	if err != nil {
+		return fmt.Errorf("encoding retry hold: %w", err)
+	}
+	return s.client.HSet(ctx, s.prefix+"retry_holds", hold.Source, data).Err()
+}
+
+// DeleteRetryHold implements ControlStore.
+func (s *RedisControlStore) DeleteRetryHold(ctx context.Context, source string) error {
+	return s.client.HDel(ctx, s.prefix+"retry_holds", source).Err()
+}
+
+// Controls implements ControlStore.
+func (s *RedisControlStore) Controls(ctx context.Context) (Controls, error) {
+	pipe := s.client.Pipeline()
+	paused := pipe.Exists(ctx, s.prefix+"paused")
+	holds := pipe.HGetAll(ctx, s.prefix+"retry_holds")
+	if _, err := pipe.Exec(ctx); err != nil {
+		return Controls{}, err
+	}
+	controls := Controls{Paused: paused.Val() > 0}
+	for source, data := range holds.Val() {
+		var hold RetryHold
+		if err := json.Unmarshal([]byte(data), &hold); err != nil {
+			return Controls{}, fmt.Errorf("decoding retry hold %q: %w", source, err)
+		}
+		controls.RetryHolds = append(controls.RetryHolds, hold)
+	}
+	slices.SortFunc(controls.RetryHolds, func(a, b RetryHold) int { return cmp.Compare(a.Source, b.Source) })
+	return controls, nil
+}
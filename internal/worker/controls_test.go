@@ -0,0 +1,116 @@
+package worker
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestSharedControls(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+
+	replicas := make([]*Pool, 2)
+	for i := range replicas {
+		replicas[i] = NewPool(10, 1, logger, NewIdempotencyStore(), ProcessorFunc(nil))
+		replicas[i].SetControlStore(NewRedisControlStore(client, "controls:"), 10*time.Millisecond)
+		replicas[i].Start(1)
+		defer replicas[i].Stop()
+	}
+	a, b := replicas[0], replicas[1]
+
+	// A replica paused on its own stays paused, and alone.
+	b.Pause()
+	time.Sleep(30 * time.Millisecond)
+	if !b.Paused() || a.Paused() {
+		t.Fatalf("incorrect local pause: a paused %v, b paused %v", a.Paused(), b.Paused())
+	}
+	b.Resume()
+
+	if err := a.PauseAll(context.Background()); err != nil {
+		t.Fatalf("PauseAll failed: %v", err)
+	}
+	if !a.Paused() {
+		t.Errorf("expected the pausing replica to be paused at once")
+	}
+	waitFor(t, b.Paused)
+
+	if err := b.ResumeAll(context.Background()); err != nil {
+		t.Fatalf("ResumeAll failed: %v", err)
+	}
+	waitFor(t, func() bool { return !a.Paused() })
+
+	// A replica started while the pool is paused starts paused.
+	if err := a.PauseAll(context.Background()); err != nil {
+		t.Fatalf("PauseAll failed: %v", err)
+	}
+	c := NewPool(10, 1, logger, NewIdempotencyStore(), ProcessorFunc(nil))
+	c.SetControlStore(NewRedisControlStore(client, "controls:"), 10*time.Millisecond)
+	c.Start(1)
+	defer c.Stop()
+	waitFor(t, c.Paused)
+
+	// Failing to share a pause still pauses this replica.
+	a.ResumeAll(context.Background())
+	server.Close()
+	if err := a.PauseAll(context.Background()); err == nil {
+		t.Errorf("expected an error sharing a pause without Redis")
+	}
+	if !a.Paused() {
+		t.Errorf("expected the replica to be paused despite the error")
+	}
+	a.Resume()
+}
+
+func TestSharedRetryHolds(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+
+	replicas := make([]*Pool, 2)
+	for i := range replicas {
+		replicas[i] = NewPool(10, 1, logger, NewIdempotencyStore(), ProcessorFunc(nil))
+		replicas[i].SetControlStore(NewRedisControlStore(client, "controls:"), 10*time.Millisecond)
+		replicas[i].Start(1)
+		defer replicas[i].Stop()
+	}
+	a, b := replicas[0], replicas[1]
+	holds := func(p *Pool) []RetryHold { return p.RetryHolds() }
+
+	// Holds placed on one replica alone, e.g. by the status page, stay there.
+	b.HoldRetries("status_page", "Gusto status: major outage")
+	time.Sleep(30 * time.Millisecond)
+	if len(holds(a)) != 0 {
+		t.Fatalf("expected a local hold to stay local, got %+v", holds(a))
+	}
+
+	if err := a.HoldAllRetries(context.Background(), "manual", "payroll api down"); err != nil {
+		t.Fatalf("HoldAllRetries failed: %v", err)
+	}
+	waitFor(t, func() bool { return len(holds(b)) == 2 })
+	if got, want := holds(b)[0], holds(a)[0]; got.Source != "manual" || got.Reason != want.Reason || !got.Since.Equal(want.Since) {
+		t.Errorf("incorrect shared hold: got %+v want %+v", got, want)
+	}
+
+	// A new reason is picked up too.
+	if err := b.HoldAllRetries(context.Background(), "manual", "still down"); err != nil {
+		t.Fatalf("HoldAllRetries failed: %v", err)
+	}
+	waitFor(t, func() bool { return len(holds(a)) == 1 && holds(a)[0].Reason == "still down" })
+
+	if err := b.ReleaseAllRetries(context.Background(), "manual"); err != nil {
+		t.Fatalf("ReleaseAllRetries failed: %v", err)
+	}
+	waitFor(t, func() bool { return len(holds(a)) == 0 })
+	if got := holds(b); len(got) != 1 || got[0].Source != "status_page" {
+		t.Errorf("expected only the local hold to remain, got %+v", got)
+	}
+}
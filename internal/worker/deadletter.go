@@ -0,0 +1,318 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gusto-webhook-guide/internal/models"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultDeadLetterCapacity is how many entries a Pool's dead-letter queue
+// keeps before evicting the oldest.
+const defaultDeadLetterCapacity = 1000
+
+// DeadLetterReason classifies why a job was dead-lettered, to help decide
+// what to do with it.
+type DeadLetterReason string
+
+const (
+	ReasonSchemaError      DeadLetterReason = "schema_error"           // The payload couldn't be decoded.
+	ReasonAuthError        DeadLetterReason = "auth_error"             // Gusto rejected our credentials.
+	ReasonRetriesExhausted DeadLetterReason = "retries_exhausted"      // Transient failures on every attempt.
+	ReasonRetryBudget      DeadLetterReason = "retry_budget_exhausted" // A transient failure while the pool's retry budget was spent.
+	ReasonUnhandled        DeadLetterReason = "unhandled"              // No handler is registered for the event type.
+	ReasonStale            DeadLetterReason = "stale"                  // The job outlived the pool's maximum job age.
+	ReasonHandlerBug       DeadLetterReason = "handler_bug"            // Any other permanent failure.
+)
+
+var deadLetterReasons = []DeadLetterReason{ReasonSchemaError, ReasonAuthError, ReasonRetriesExhausted, ReasonRetryBudget, ReasonUnhandled, ReasonStale, ReasonHandlerBug}
+
+// ErrDeadLetterNotFound is returned for a dead-letter ID that isn't queued.
+var ErrDeadLetterNotFound = errors.New("dead letter not found")
+
+// TriggerTokenRotated is the trigger to fire once the Gusto API token has
+// been replaced.
+const TriggerTokenRotated = "token_rotated"
+
+// classify returns the dead-letter reason for a job's final error.
+func classify(err error) DeadLetterReason {
+	switch {
+	case errors.Is(err, ErrSchema):
+		return ReasonSchemaError
+	case errors.Is(err, ErrAuth):
+		return ReasonAuthError
+	case errors.Is(err, ErrUnhandled):
+		return ReasonUnhandled
+	case errors.Is(err, ErrStale):
+		return ReasonStale
+	case IsTransient(err):
+		return ReasonRetriesExhausted
+	default:
+		return ReasonHandlerBug
+	}
+}
+
+// DeadLetter is a job that failed for good, kept so it can be inspected and
+// redriven once the cause is fixed.
+type DeadLetter struct {
+	ID             string           `json:"id"`
+	EventUUID      string           `json:"event_uuid,omitempty"` // Empty if the payload couldn't be decoded.
+	EventType      string           `json:"event_type,omitempty"`
+	RequestID      string           `json:"request_id,omitempty"` // The delivery's request ID, kept through redrives.
+	Reason         DeadLetterReason `json:"reason"`
+	Error          string           `json:"error"`
+	Attempts       int              `json:"attempts"`
+	Payload        []byte           `json:"payload"`
+	DeadLetteredAt time.Time        `json:"dead_lettered_at"`
+}
+
+// DeadLetterQueue is an in-memory list of dead-lettered jobs, oldest first.
+// Its contents are lost on restart.
+type DeadLetterQueue struct {
+	mu      sync.Mutex
+	max     int
+	nextID  int
+	entries []DeadLetter
+}
+
+// NewDeadLetterQueue creates a DeadLetterQueue keeping up to max entries.
+// The oldest entry is evicted once the limit is reached.
+func NewDeadLetterQueue(max int) *DeadLetterQueue {
+	return &DeadLetterQueue{max: max}
+}
+
+// Add stores dl with a new ID and returns it. It also returns the entry
+// evicted to make room, if any.
+func (q *DeadLetterQueue) Add(dl DeadLetter) (added DeadLetter, evicted *DeadLetter) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.nextID++
+	dl.ID = strconv.Itoa(q.nextID)
+	if len(q.entries) >= q.max {
+		oldest := q.entries[0]
+		evicted = &oldest
+		q.entries = q.entries[1:]
+	}
+	q.entries = append(q.entries, dl)
+	return dl, evicted
+}
+
+// DeadLetterFilter selects dead letters. Zero fields match everything.
+type DeadLetterFilter struct {
+	Reason    DeadLetterReason
+	EventType string
+	Since     time.Time // Dead-lettered at or after.
+	Until     time.Time // Dead-lettered before.
+}
+
+func (f DeadLetterFilter) matches(dl DeadLetter) bool {
+	return (f.Reason == "" || dl.Reason == f.Reason) &&
+		(f.EventType == "" || dl.EventType == f.EventType) &&
+		(f.Since.IsZero() || !dl.DeadLetteredAt.Before(f.Since)) &&
+		(f.Until.IsZero() || dl.DeadLetteredAt.Before(f.Until))
+}
+
+// List returns the entries matching filter, oldest first.
+func (q *DeadLetterQueue) List(filter DeadLetterFilter) []DeadLetter {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var entries []DeadLetter
+	for _, dl := range q.entries {
+		if filter.matches(dl) {
+			entries = append(entries, dl)
+		}
+	}
+	return entries
+}
+
+// Get returns the entry with the given ID.
+func (q *DeadLetterQueue) Get(id string) (DeadLetter, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	i := slices.IndexFunc(q.entries, func(dl DeadLetter) bool { return dl.ID == id })
+	if i < 0 {
+		return DeadLetter{}, false
+	}
+	return q.entries[i], true
+}
+
+// Remove deletes the entry with the given ID and reports whether it existed.
+func (q *DeadLetterQueue) Remove(id string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	i := slices.IndexFunc(q.entries, func(dl DeadLetter) bool { return dl.ID == id })
+	if i < 0 {
+		return false
+	}
+	q.entries = slices.Delete(q.entries, i, i+1)
+	return true
+}
+
+// Len returns the number of entries.
+func (q *DeadLetterQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.entries)
+}
+
+// Prune deletes the entries dead-lettered before cutoff, e.g. to enforce a
+// retention period, and returns how many were deleted.
+func (q *DeadLetterQueue) Prune(cutoff time.Time) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	before := len(q.entries)
+	q.entries = slices.DeleteFunc(q.entries, func(dl DeadLetter) bool { return dl.DeadLetteredAt.Before(cutoff) })
+	return before - len(q.entries)
+}
+
+// TriageRule redrives the dead letters with Reason whenever trigger On is
+// fired, e.g. auth_error entries once the API token has been rotated.
+type TriageRule struct {
+	Reason DeadLetterReason
+	On     string
+}
+
+// ParseTriageRules parses rules written as comma-separated reason:trigger
+// pairs, e.g. "auth_error:token_rotated,handler_bug:deployed".
+func ParseTriageRules(s string) ([]TriageRule, error) {
+	var rules []TriageRule
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		reason, trigger, ok := strings.Cut(pair, ":")
+		if !ok || trigger == "" {
+			return nil, fmt.Errorf("invalid triage rule %q: want reason:trigger", pair)
+		}
+		if !slices.Contains(deadLetterReasons, DeadLetterReason(reason)) {
+			return nil, fmt.Errorf("invalid triage rule %q: unknown reason %q", pair, reason)
+		}
+		rules = append(rules, TriageRule{Reason: DeadLetterReason(reason), On: trigger})
+	}
+	return rules, nil
+}
+
+// DeadLetters returns the pool's dead-letter queue.
+func (p *Pool) DeadLetters() *DeadLetterQueue {
+	return p.deadLetters
+}
+
+// SetTriageRules sets the rules applied by Trigger.
+func (p *Pool) SetTriageRules(rules []TriageRule) {
+	p.triageMu.Lock()
+	defer p.triageMu.Unlock()
+	p.triageRules = rules
+}
+
+// Trigger fires the named trigger, redriving the dead letters of every
+// reason with a rule for it. It returns how many were redriven.
+func (p *Pool) Trigger(ctx context.Context, trigger string) (int, error) {
+	p.triageMu.Lock()
+	var reasons []DeadLetterReason
+	for _, rule := range p.triageRules {
+		if rule.On == trigger {
+			reasons = append(reasons, rule.Reason)
+		}
+	}
+	p.triageMu.Unlock()
+
+	redriven := 0
+	for _, reason := range reasons {
+		n, err := p.RedriveDeadLetters(ctx, DeadLetterFilter{Reason: reason})
+		redriven += n
+		if err != nil {
+			return redriven, err
+		}
+	}
+	if redriven > 0 {
+		p.logger.Info("Dead letters redriven by trigger", "trigger", trigger, "redriven", redriven)
+	}
+	return redriven, nil
+}
+
+// RedriveDeadLetter queues the dead letter with the given ID again.
+func (p *Pool) RedriveDeadLetter(ctx context.Context, id string) error {
+	dl, found := p.deadLetters.Get(id)
+	if !found {
+		return ErrDeadLetterNotFound
+	}
+	return p.redrive(ctx, dl)
+}
+
+// RedriveDeadLetters queues every dead letter matching filter again, oldest
+// first. It stops at the first failure, e.g. a full queue, returning how
+// many were redriven; the rest stay in the dead-letter queue.
+func (p *Pool) RedriveDeadLetters(ctx context.Context, filter DeadLetterFilter) (int, error) {
+	redriven := 0
+	for _, dl := range p.deadLetters.List(filter) {
+		if err := p.redrive(ctx, dl); err != nil {
+			return redriven, err
+		}
+		redriven++
+	}
+	return redriven, nil
+}
+
+// redrive queues a dead letter again with a fresh attempt count and removes
+// it from the dead-letter queue. Its idempotency record is deleted first so
+// the job isn't ignored as a duplicate.
+func (p *Pool) redrive(ctx context.Context, dl DeadLetter) error {
+	if p.ctx.Err() != nil {
+		return ErrPoolStopping
+	}
+	if dl.EventUUID != "" {
+		if err := p.idempotencyStore.Delete(ctx, dl.EventUUID); err != nil {
+			return fmt.Errorf("releasing idempotency key for %s: %w", dl.EventUUID, err)
+		}
+	}
+	if err := p.queue.Enqueue(ctx, models.Job{Payload: dl.Payload, RequestID: dl.RequestID}, 0); err != nil {
+		return fmt.Errorf("redriving dead letter %s: %w", dl.ID, err)
+	}
+	p.deadLetters.Remove(dl.ID)
+	return nil
+}
+
+// DeadLetterJob dead-letters a job without processing it, e.g. an event
+// turned away at ingestion, with the reason cause is classified as. The
+// event is recorded as StatusDeadLettered, so later deliveries of it are
+// dropped as duplicates until it is redriven.
+func (p *Pool) DeadLetterJob(ctx context.Context, job models.Job, cause error) {
+	var event models.WebhookEvent
+	json.Unmarshal(job.Payload, &event) // An undecodable payload is kept as is.
+	logger := p.logger.With("event_uuid", event.UUID, "event_type", event.EventType)
+	if job.RequestID != "" {
+		logger = logger.With("request_id", job.RequestID)
+	}
+	if event.UUID != "" {
+		now := time.Now().UTC()
+		p.record(ctx, logger, event.UUID, Record{EventType: event.EventType, ClaimedAt: now}, StatusDeadLettered, cause)
+	}
+	p.deadLetter(logger, job, event, job.Attempts, classify(cause), cause)
+}
+
+// deadLetter adds a job that failed for good to the dead-letter queue.
+func (p *Pool) deadLetter(logger *slog.Logger, job models.Job, event models.WebhookEvent, attempts int, reason DeadLetterReason, cause error) {
+	p.metrics.JobDeadLettered(event.EventType, reason)
+	dl, evicted := p.deadLetters.Add(DeadLetter{
+		EventUUID:      event.UUID,
+		EventType:      event.EventType,
+		RequestID:      job.RequestID,
+		Reason:         reason,
+		Error:          cause.Error(),
+		Attempts:       attempts,
+		Payload:        job.Payload,
+		DeadLetteredAt: time.Now().UTC(),
+	})
+	logger.Info("Job added to dead-letter queue", "dead_letter_id", dl.ID, "reason", reason)
+	if evicted != nil {
+		logger.Warn("Dead-letter queue is full, evicted the oldest entry", "evicted_id", evicted.ID, "evicted_event_uuid", evicted.EventUUID)
+	}
+}
@@ -0,0 +1,209 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gusto-webhook-guide/internal/models"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestClassify(t *testing.T) {
+	testCases := []struct {
+		name     string
+		err      error
+		expected DeadLetterReason
+	}{
+		{name: "Schema Error", err: &ErrPermanent{Err: fmt.Errorf("%w: bad field", ErrSchema)}, expected: ReasonSchemaError},
+		{name: "Auth Error", err: &ErrPermanent{Err: fmt.Errorf("%w: 401", ErrAuth)}, expected: ReasonAuthError},
+		{name: "Transient Error", err: &ErrTransient{Err: errors.New("timeout")}, expected: ReasonRetriesExhausted},
+		{name: "Unhandled Event Type", err: Permanentf("%w: employee.created", ErrUnhandled), expected: ReasonUnhandled},
+		{name: "Stale Job", err: fmt.Errorf("%w: received 2h ago", ErrStale), expected: ReasonStale},
+		{name: "Other Permanent Error", err: &ErrPermanent{Err: errors.New("validation failed")}, expected: ReasonHandlerBug},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := classify(tc.err); got != tc.expected {
+				t.Errorf("incorrect reason: got %q want %q", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestParseTriageRules(t *testing.T) {
+	testCases := []struct {
+		name        string
+		input       string
+		expected    []TriageRule
+		expectError bool
+	}{
+		{name: "Empty", input: ""},
+		{
+			name:     "Several Rules",
+			input:    "auth_error:token_rotated, handler_bug:deployed",
+			expected: []TriageRule{{Reason: ReasonAuthError, On: TriggerTokenRotated}, {Reason: ReasonHandlerBug, On: "deployed"}},
+		},
+		{name: "Missing Trigger", input: "auth_error", expectError: true},
+		{name: "Unknown Reason", input: "typo_error:deployed", expectError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rules, err := ParseTriageRules(tc.input)
+			if (err != nil) != tc.expectError {
+				t.Fatalf("incorrect error: got %v want error %v", err, tc.expectError)
+			}
+			if len(rules) != len(tc.expected) {
+				t.Fatalf("incorrect rules: got %+v want %+v", rules, tc.expected)
+			}
+			for i := range rules {
+				if rules[i] != tc.expected[i] {
+					t.Errorf("incorrect rules: got %+v want %+v", rules, tc.expected)
+				}
+			}
+		})
+	}
+}
+
+func TestDeadLetterQueueEviction(t *testing.T) {
+	q := NewDeadLetterQueue(2)
+	first, _ := q.Add(DeadLetter{EventUUID: "a"})
+	q.Add(DeadLetter{EventUUID: "b"})
+	if _, evicted := q.Add(DeadLetter{EventUUID: "c"}); evicted == nil || evicted.ID != first.ID {
+		t.Errorf("incorrect eviction: got %+v want %s", evicted, first.ID)
+	}
+	if _, found := q.Get(first.ID); found {
+		t.Errorf("expected the oldest entry to be evicted")
+	}
+	if got := len(q.List(DeadLetterFilter{})); got != 2 {
+		t.Errorf("incorrect length: got %d want 2", got)
+	}
+}
+
+func TestDeadLetterQueuePrune(t *testing.T) {
+	q := NewDeadLetterQueue(10)
+	now := time.Now().UTC()
+	q.Add(DeadLetter{EventUUID: "old", DeadLetteredAt: now.Add(-48 * time.Hour)})
+	q.Add(DeadLetter{EventUUID: "new", DeadLetteredAt: now.Add(-time.Hour)})
+	if removed := q.Prune(now.Add(-24 * time.Hour)); removed != 1 {
+		t.Errorf("incorrect number pruned: got %d want 1", removed)
+	}
+	if entries := q.List(DeadLetterFilter{}); len(entries) != 1 || entries[0].EventUUID != "new" {
+		t.Errorf("incorrect entries left: got %+v", entries)
+	}
+}
+
+func TestPoolDeadLettersAndTrigger(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	store := NewIdempotencyStore()
+	authFailing := true
+	processor := ProcessorFunc(func(ctx context.Context, event models.WebhookEvent) error {
+		if event.UUID == "auth" && authFailing {
+			return &ErrPermanent{Err: fmt.Errorf("%w: 401", ErrAuth)}
+		}
+		return stubProcessor(ctx, event)
+	})
+	pool := NewPool(10, 0, logger, store, processor)
+	defer pool.Stop()
+	pool.SetTriageRules([]TriageRule{{Reason: ReasonAuthError, On: TriggerTokenRotated}})
+
+	pool.handleJob(1, models.Job{Payload: []byte(`{"uuid":"auth","event_type":"company.created"}`)})
+	pool.handleJob(1, models.Job{Payload: []byte(`{"uuid":"bug","event_type":"company.deleted"}`)})
+	pool.handleJob(1, models.Job{Payload: []byte(`not json`)})
+
+	expected := []DeadLetterReason{ReasonAuthError, ReasonHandlerBug, ReasonSchemaError}
+	entries := pool.DeadLetters().List(DeadLetterFilter{})
+	if len(entries) != len(expected) {
+		t.Fatalf("incorrect dead letters: got %+v want reasons %v", entries, expected)
+	}
+	for i, dl := range entries {
+		if dl.Reason != expected[i] || dl.Attempts != 1 {
+			t.Errorf("incorrect dead letter %d: got %+v want reason %q after 1 attempt", i, dl, expected[i])
+		}
+	}
+
+	// Other triggers leave the entries alone.
+	if n, err := pool.Trigger(ctx, "deployed"); err != nil || n != 0 {
+		t.Errorf("incorrect redrive for unrelated trigger: got %d (err %v) want 0", n, err)
+	}
+
+	// After the token is rotated, auth errors are queued again and processed.
+	authFailing = false
+	if n, err := pool.Trigger(ctx, TriggerTokenRotated); err != nil || n != 1 {
+		t.Fatalf("incorrect redrive: got %d (err %v) want 1", n, err)
+	}
+	if got := len(pool.DeadLetters().List(DeadLetterFilter{Reason: ReasonAuthError})); got != 0 {
+		t.Errorf("redriven entries should leave the dead-letter queue: got %d", got)
+	}
+	job := <-pool.JobQueue
+	if job.Attempts != 0 {
+		t.Errorf("incorrect attempts for redriven job: got %d want 0", job.Attempts)
+	}
+	pool.handleJob(1, job)
+	if rec, _, _ := store.Get(ctx, "auth"); rec.Status != StatusSucceeded {
+		t.Errorf("incorrect status after redrive: got %q want %q", rec.Status, StatusSucceeded)
+	}
+}
+
+func TestPoolDeadLettersStaleJobs(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	store := NewIdempotencyStore()
+	var processed []string
+	processor := ProcessorFunc(func(_ context.Context, event models.WebhookEvent) error {
+		processed = append(processed, event.UUID)
+		return nil
+	})
+	pool := NewPool(10, 0, logger, store, processor)
+	defer pool.Stop()
+	pool.SetMaxJobAge(time.Hour)
+
+	now := time.Now().UTC()
+	pool.handleJob(1, models.Job{Payload: []byte(`{"uuid":"stale","event_type":"company.updated"}`), ReceivedAt: now.Add(-2 * time.Hour)})
+	pool.handleJob(1, models.Job{Payload: []byte(`{"uuid":"fresh","event_type":"company.updated"}`), ReceivedAt: now.Add(-time.Minute)})
+	pool.handleJob(1, models.Job{Payload: []byte(`{"uuid":"unknown","event_type":"company.updated"}`)})
+
+	if want := []string{"fresh", "unknown"}; fmt.Sprint(processed) != fmt.Sprint(want) {
+		t.Errorf("incorrect events processed: got %v want %v", processed, want)
+	}
+	entries := pool.DeadLetters().List(DeadLetterFilter{})
+	if len(entries) != 1 || entries[0].EventUUID != "stale" || entries[0].Reason != ReasonStale {
+		t.Fatalf("incorrect dead letters: got %+v want the stale event", entries)
+	}
+	if rec, _, _ := store.Get(ctx, "stale"); rec.Status != StatusDeadLettered {
+		t.Errorf("incorrect status for stale event: got %q want %q", rec.Status, StatusDeadLettered)
+	}
+}
+
+func TestPoolDeadLetterJob(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	store := NewIdempotencyStore()
+	pool := NewPool(10, 0, logger, store, stubProcessor)
+	defer pool.Stop()
+
+	job := models.Job{Payload: []byte(`{"uuid":"invalid","event_type":"payroll.processed"}`), RequestID: "req-1"}
+	pool.DeadLetterJob(ctx, job, fmt.Errorf("%w: /payload: is required", ErrSchema))
+
+	entries := pool.DeadLetters().List(DeadLetterFilter{})
+	if len(entries) != 1 || entries[0].Reason != ReasonSchemaError || entries[0].EventUUID != "invalid" || entries[0].RequestID != "req-1" {
+		t.Fatalf("incorrect dead letters: got %+v want the invalid event", entries)
+	}
+	if rec, _, _ := store.Get(ctx, "invalid"); rec.Status != StatusDeadLettered {
+		t.Errorf("incorrect status: got %q want %q", rec.Status, StatusDeadLettered)
+	}
+
+	// Redriving it clears the record so it is processed.
+	if err := pool.RedriveDeadLetter(ctx, entries[0].ID); err != nil {
+		t.Fatalf("RedriveDeadLetter failed: %v", err)
+	}
+	pool.handleJob(1, <-pool.JobQueue)
+	if rec, _, _ := store.Get(ctx, "invalid"); rec.Status != StatusSucceeded {
+		t.Errorf("incorrect status after redrive: got %q want %q", rec.Status, StatusSucceeded)
+	}
+}
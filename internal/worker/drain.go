@@ -0,0 +1,66 @@
+package worker
+
+import (
+	"sync"
+	"time"
+)
+
+// drainSlots is how many slot-long intervals a drainMeter counts finished
+// jobs over, each drainSlot long.
+const (
+	drainSlots = 6
+	drainSlot  = 10 * time.Second
+)
+
+// drainMeter counts jobs finished over the last drainSlots*drainSlot, to
+// measure how fast the queue drains. The zero value is ready to use.
+type drainMeter struct {
+	mu    sync.Mutex
+	slots [drainSlots]struct {
+		index    int64 // Which interval since the epoch the count is for.
+		finished int
+	}
+}
+
+// finish counts a job finished at now.
+func (m *drainMeter) finish(now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	index := now.UnixNano() / int64(drainSlot)
+	s := &m.slots[index%drainSlots]
+	if s.index != index {
+		s.index, s.finished = index, 0
+	}
+	s.finished++
+}
+
+// rate returns the jobs finished per second over the window ending at now.
+func (m *drainMeter) rate(now time.Time) float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	index := now.UnixNano() / int64(drainSlot)
+	finished := 0
+	for _, s := range m.slots {
+		if index-s.index < drainSlots {
+			finished += s.finished
+		}
+	}
+	return float64(finished) / (drainSlots * drainSlot).Seconds()
+}
+
+// DrainTime estimates how long the jobs now waiting in the queue will take
+// to be picked up, at the rate jobs finished over the last minute, e.g. to
+// tell a sender when to try again. It reports false if it can't tell,
+// because the queue doesn't report its depth or no job finished recently.
+func (p *Pool) DrainTime() (time.Duration, bool) {
+	q, ok := p.queue.(depther)
+	if !ok {
+		return 0, false
+	}
+	queued, _ := q.Depth()
+	rate := p.drain.rate(time.Now())
+	if rate == 0 {
+		return 0, false
+	}
+	return time.Duration(float64(queued) / rate * float64(time.Second)), true
+}
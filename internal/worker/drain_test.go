@@ -0,0 +1,36 @@
+package worker
+
+import (
+	"gusto-webhook-guide/internal/models"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestDrainTime(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	pool := NewPool(10, 1, logger, NewIdempotencyStore(), stubProcessor)
+
+	if _, known := pool.DrainTime(); known {
+		t.Error("drain time known before any job finished")
+	}
+
+	// 30 jobs finished over the last minute is one every 2 seconds.
+	now := time.Now()
+	for i := range 30 {
+		pool.drain.finish(now.Add(-time.Duration(i) * time.Second))
+	}
+	for range 5 {
+		pool.JobQueue <- models.Job{}
+	}
+	drainTime, known := pool.DrainTime()
+	if !known || drainTime != 10*time.Second {
+		t.Errorf("incorrect drain time: got %v (known %v) want 10s", drainTime, known)
+	}
+
+	// Finished jobs age out of the window.
+	if rate := pool.drain.rate(now.Add(2 * time.Minute)); rate != 0 {
+		t.Errorf("rate after the window passed: got %v want 0", rate)
+	}
+}
@@ -0,0 +1,141 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DualStore is a Store for moving idempotency keys from one backend to
+// another without a window in which duplicates slip through. Until the
+// overlap period ends it reads from both stores and writes to both, so events
+// recorded only in the previous store are still recognised, and replicas not
+// yet switched over still see events this one handles. Once the period is
+// over, which should exceed the previous store's key TTL, only the current
+// store is used and the previous one can be retired.
+type DualStore struct {
+	current  Store
+	previous Store
+	until    time.Time
+	now      func() time.Time
+}
+
+var _ Store = (*DualStore)(nil)
+
+// NewDualStore creates a DualStore that migrates from previous to current,
+// using both until the given time.
+func NewDualStore(current, previous Store, until time.Time) *DualStore {
+	return &DualStore{current: current, previous: previous, until: until, now: time.Now}
+}
+
+// overlapping reports whether the previous store is still in use.
+func (s *DualStore) overlapping() bool {
+	return s.now().Before(s.until)
+}
+
+// Has reports whether either store has a recorded outcome for key.
+func (s *DualStore) Has(ctx context.Context, key string) (bool, error) {
+	found, err := s.current.Has(ctx, key)
+	if err != nil || found || !s.overlapping() {
+		return found, err
+	}
+	found, err = s.previous.Has(ctx, key)
+	if err != nil {
+		return false, fmt.Errorf("previous store: %w", err)
+	}
+	return found, nil
+}
+
+// Get returns the record for key from the current store, or the previous
+// one if only it has the key.
+func (s *DualStore) Get(ctx context.Context, key string) (Record, bool, error) {
+	rec, found, err := s.current.Get(ctx, key)
+	if err != nil || found || !s.overlapping() {
+		return rec, found, err
+	}
+	rec, found, err = s.previous.Get(ctx, key)
+	if err != nil {
+		return Record{}, false, fmt.Errorf("previous store: %w", err)
+	}
+	return rec, found, nil
+}
+
+// Set records the outcome in both stores.
+func (s *DualStore) Set(ctx context.Context, key string, rec Record) error {
+	if err := s.current.Set(ctx, key, rec); err != nil {
+		return err
+	}
+	if !s.overlapping() {
+		return nil
+	}
+	if err := s.previous.Set(ctx, key, rec); err != nil {
+		return fmt.Errorf("previous store: %w", err)
+	}
+	return nil
+}
+
+// SetIfAbsent claims key in the previous store and then the current one,
+// so replicas using either store can't both claim it. If the current store
+// refuses the claim, the claim just taken in the previous store is
+// released.
+func (s *DualStore) SetIfAbsent(ctx context.Context, key string, rec Record) (bool, error) {
+	if !s.overlapping() {
+		return s.current.SetIfAbsent(ctx, key, rec)
+	}
+	stored, err := s.previous.SetIfAbsent(ctx, key, rec)
+	if err != nil {
+		return false, fmt.Errorf("previous store: %w", err)
+	}
+	if !stored {
+		return false, nil
+	}
+	stored, err = s.current.SetIfAbsent(ctx, key, rec)
+	if err != nil || !stored {
+		if deleteErr := s.previous.Delete(ctx, key); deleteErr != nil {
+			err = errors.Join(err, fmt.Errorf("releasing claim in previous store: %w", deleteErr))
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// Delete removes key from both stores.
+func (s *DualStore) Delete(ctx context.Context, key string) error {
+	if err := s.current.Delete(ctx, key); err != nil {
+		return err
+	}
+	if !s.overlapping() {
+		return nil
+	}
+	if err := s.previous.Delete(ctx, key); err != nil {
+		return fmt.Errorf("previous store: %w", err)
+	}
+	return nil
+}
+
+// List implements Lister by listing the current store, if it can be listed.
+// Keys only in the previous store aren't included.
+func (s *DualStore) List(ctx context.Context, opts ListOptions) ([]Entry, string, error) {
+	lister, ok := s.current.(Lister)
+	if !ok {
+		return nil, "", ErrNotListable
+	}
+	return lister.List(ctx, opts)
+}
+
+// RecoverStale implements Recoverer for whichever of the stores are
+// durable, returning the number of claims reset in both.
+func (s *DualStore) RecoverStale(ctx context.Context, cutoff time.Time) (int, error) {
+	total := 0
+	for _, store := range []Store{s.current, s.previous} {
+		if recoverer, ok := store.(Recoverer); ok {
+			n, err := recoverer.RecoverStale(ctx, cutoff)
+			total += n
+			if err != nil {
+				return total, err
+			}
+		}
+	}
+	return total, nil
+}
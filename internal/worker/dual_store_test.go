@@ -0,0 +1,55 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestDualStore(t *testing.T) {
+	ctx := context.Background()
+	previous := NewIdempotencyStore()
+	current := NewIdempotencyStore()
+	now := time.Now()
+	store := NewDualStore(current, previous, now.Add(time.Hour))
+	store.now = func() time.Time { return now }
+
+	// An event recorded only in the previous store is still a duplicate.
+	previous.Set(ctx, "old", Record{Status: StatusSucceeded})
+	if found, err := store.Has(ctx, "old"); err != nil || !found {
+		t.Errorf("incorrect Has for previous store's key: got %v (err %v) want true", found, err)
+	}
+	if stored, err := store.SetIfAbsent(ctx, "old", Record{Status: StatusProcessing}); err != nil || stored {
+		t.Errorf("incorrect SetIfAbsent for previous store's key: got %v (err %v) want false", stored, err)
+	}
+
+	// New claims and outcomes are written to both.
+	if stored, err := store.SetIfAbsent(ctx, "new", Record{Status: StatusProcessing}); err != nil || !stored {
+		t.Fatalf("incorrect SetIfAbsent for new key: got %v (err %v) want true", stored, err)
+	}
+	store.Set(ctx, "new", Record{Status: StatusSucceeded})
+	for name, s := range map[string]Store{"current": current, "previous": previous} {
+		if rec, _, _ := s.Get(ctx, "new"); rec.Status != StatusSucceeded {
+			t.Errorf("incorrect status in %s store: got %q want %q", name, rec.Status, StatusSucceeded)
+		}
+	}
+
+	// A claim the current store refuses is released from the previous one.
+	current.Set(ctx, "current-only", Record{Status: StatusProcessing})
+	if stored, _ := store.SetIfAbsent(ctx, "current-only", Record{Status: StatusProcessing}); stored {
+		t.Error("claimed a key the current store already holds")
+	}
+	if _, found, _ := previous.Get(ctx, "current-only"); found {
+		t.Error("claim left behind in the previous store")
+	}
+
+	// Once the overlap is over, the previous store is ignored.
+	now = now.Add(2 * time.Hour)
+	if found, _ := store.Has(ctx, "old"); found {
+		t.Error("previous store consulted after the overlap period")
+	}
+	store.Set(ctx, "later", Record{Status: StatusSucceeded})
+	if _, found, _ := previous.Get(ctx, "later"); found {
+		t.Error("previous store written after the overlap period")
+	}
+}
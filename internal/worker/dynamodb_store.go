@@ -0,0 +1,294 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// DynamoDBAPI is the subset of the DynamoDB client used by DynamoDBStore.
+// *dynamodb.Client satisfies it.
+type DynamoDBAPI interface {
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+	UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)
+	Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
+}
+
+// DynamoDBStore is a Store backed by a DynamoDB table, for deployments
+// without Redis or Postgres. The table must have a string partition key named
+// event_uuid; enable DynamoDB TTL on the expires_at attribute so expired
+// items are eventually deleted.
+type DynamoDBStore struct {
+	client DynamoDBAPI
+	table  string
+	ttl    time.Duration // Zero means items never expire.
+	now    func() time.Time
+}
+
+var (
+	_ Store     = (*DynamoDBStore)(nil)
+	_ Recoverer = (*DynamoDBStore)(nil)
+	_ Lister    = (*DynamoDBStore)(nil)
+)
+
+// NewDynamoDBStore creates a DynamoDBStore using table. Items expire after
+// ttl; a zero ttl keeps them forever.
+func NewDynamoDBStore(client DynamoDBAPI, table string, ttl time.Duration) *DynamoDBStore {
+	return &DynamoDBStore{
+		client: client,
+		table:  table,
+		ttl:    ttl,
+		now:    time.Now,
+	}
+}
+
+// Has reports whether a key (event UUID) has a recorded outcome.
+func (s *DynamoDBStore) Has(ctx context.Context, key string) (bool, error) {
+	rec, found, err := s.Get(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	return found && rec.Status.final(), nil
+}
+
+// Get returns the record for a key (event UUID).
+func (s *DynamoDBStore) Get(ctx context.Context, key string) (Record, bool, error) {
+	out, err := s.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(s.table),
+		Key:            s.itemKey(key),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return Record{}, false, fmt.Errorf("reading idempotency key: %w", err)
+	}
+	// DynamoDB deletes expired items lazily, so they can still be returned
+	// for a while after expiring.
+	if len(out.Item) == 0 || s.expired(out.Item) {
+		return Record{}, false, nil
+	}
+	return recordFromItem(out.Item), true, nil
+}
+
+// Set records the outcome for a key (event UUID), overwriting any prior outcome.
+func (s *DynamoDBStore) Set(ctx context.Context, key string, rec Record) error {
+	_, err := s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.table),
+		Item:      s.item(key, rec),
+	})
+	if err != nil {
+		return fmt.Errorf("recording idempotency key: %w", err)
+	}
+	return nil
+}
+
+// SetIfAbsent stores a key (event UUID) unless it already exists. The write
+// is conditional, so only one replica can claim an event; an expired item
+// that DynamoDB has not deleted yet counts as absent.
+func (s *DynamoDBStore) SetIfAbsent(ctx context.Context, key string, rec Record) (bool, error) {
+	_, err := s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:                aws.String(s.table),
+		Item:                     s.item(key, rec),
+		ConditionExpression:      aws.String("attribute_not_exists(event_uuid) OR expires_at <= :now OR #status = :pending"),
+		ExpressionAttributeNames: map[string]string{"#status": "status"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":now":     epochSeconds(s.now()),
+			":pending": &types.AttributeValueMemberS{Value: string(StatusPending)},
+		},
+	})
+	var conditionFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionFailed) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("claiming idempotency key: %w", err)
+	}
+	return true, nil
+}
+
+// Delete removes a key (event UUID) from the store.
+func (s *DynamoDBStore) Delete(ctx context.Context, key string) error {
+	_, err := s.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(s.table),
+		Key:       s.itemKey(key),
+	})
+	if err != nil {
+		return fmt.Errorf("deleting idempotency key: %w", err)
+	}
+	return nil
+}
+
+// List returns a page of items in Scan order, which is not sorted. The
+// cursor is the last event UUID scanned, and a page may hold somewhat more
+// than opts.Limit entries.
+func (s *DynamoDBStore) List(ctx context.Context, opts ListOptions) ([]Entry, string, error) {
+	input := &dynamodb.ScanInput{TableName: aws.String(s.table)}
+	if opts.Limit > 0 {
+		input.Limit = aws.Int32(int32(min(opts.Limit, 1000)))
+	}
+	if opts.EventType != "" {
+		input.FilterExpression = aws.String("event_type = :event_type")
+		input.ExpressionAttributeValues = map[string]types.AttributeValue{
+			":event_type": &types.AttributeValueMemberS{Value: opts.EventType},
+		}
+	}
+	if opts.Cursor != "" {
+		input.ExclusiveStartKey = s.itemKey(opts.Cursor)
+	}
+
+	var entries []Entry
+	for {
+		out, err := s.client.Scan(ctx, input)
+		if err != nil {
+			return nil, "", fmt.Errorf("listing idempotency keys: %w", err)
+		}
+		for _, item := range out.Items {
+			rec := recordFromItem(item)
+			if s.expired(item) || (opts.EventType != "" && rec.EventType != opts.EventType) {
+				continue
+			}
+			key, _ := item["event_uuid"].(*types.AttributeValueMemberS)
+			if key == nil {
+				continue
+			}
+			entries = append(entries, Entry{Key: key.Value, Record: rec})
+		}
+
+		lastKey, _ := out.LastEvaluatedKey["event_uuid"].(*types.AttributeValueMemberS)
+		if lastKey == nil {
+			return entries, "", nil
+		}
+		if opts.Limit > 0 && len(entries) >= opts.Limit {
+			return entries, lastKey.Value, nil
+		}
+		input.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+}
+
+// RecoverStale resets claims that were taken before cutoff and never
+// completed. It scans the whole table, so it is meant to run once at startup.
+func (s *DynamoDBStore) RecoverStale(ctx context.Context, cutoff time.Time) (int, error) {
+	recovered := 0
+	var startKey map[string]types.AttributeValue
+	for {
+		out, err := s.client.Scan(ctx, &dynamodb.ScanInput{
+			TableName:                aws.String(s.table),
+			FilterExpression:         aws.String("#status = :processing"),
+			ExpressionAttributeNames: map[string]string{"#status": "status"},
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":processing": &types.AttributeValueMemberS{Value: string(StatusProcessing)},
+			},
+			ExclusiveStartKey: startKey,
+		})
+		if err != nil {
+			return recovered, fmt.Errorf("scanning for stale claims: %w", err)
+		}
+
+		for _, item := range out.Items {
+			// Timestamps are stored as RFC 3339 strings, which don't sort
+			// lexically, so the age check happens here rather than in the filter.
+			rec := recordFromItem(item)
+			if rec.Status != StatusProcessing || s.expired(item) || !rec.ClaimedAt.Before(cutoff) {
+				continue
+			}
+			_, err := s.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+				TableName:                aws.String(s.table),
+				Key:                      map[string]types.AttributeValue{"event_uuid": item["event_uuid"]},
+				UpdateExpression:         aws.String("SET #status = :pending, last_error = :error, processed_at = :now"),
+				ConditionExpression:      aws.String("#status = :processing"),
+				ExpressionAttributeNames: map[string]string{"#status": "status"},
+				ExpressionAttributeValues: map[string]types.AttributeValue{
+					":pending":    &types.AttributeValueMemberS{Value: string(StatusPending)},
+					":processing": &types.AttributeValueMemberS{Value: string(StatusProcessing)},
+					":error":      &types.AttributeValueMemberS{Value: abandonedClaimError},
+					":now":        &types.AttributeValueMemberS{Value: s.now().UTC().Format(time.RFC3339Nano)},
+				},
+			})
+			var conditionFailed *types.ConditionalCheckFailedException
+			if errors.As(err, &conditionFailed) {
+				continue // Completed since it was scanned.
+			}
+			if err != nil {
+				return recovered, fmt.Errorf("resetting stale claim: %w", err)
+			}
+			recovered++
+		}
+
+		if len(out.LastEvaluatedKey) == 0 {
+			return recovered, nil
+		}
+		startKey = out.LastEvaluatedKey
+	}
+}
+
+func (s *DynamoDBStore) itemKey(key string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"event_uuid": &types.AttributeValueMemberS{Value: key},
+	}
+}
+
+// item converts a Record into a DynamoDB item, adding the TTL attribute.
+func (s *DynamoDBStore) item(key string, rec Record) map[string]types.AttributeValue {
+	item := map[string]types.AttributeValue{
+		"event_uuid":   &types.AttributeValueMemberS{Value: key},
+		"event_type":   &types.AttributeValueMemberS{Value: rec.EventType},
+		"status":       &types.AttributeValueMemberS{Value: string(rec.Status)},
+		"attempts":     &types.AttributeValueMemberN{Value: strconv.Itoa(rec.Attempts)},
+		"processed_at": &types.AttributeValueMemberS{Value: rec.ProcessedAt.Format(time.RFC3339Nano)},
+	}
+	if rec.LastError != "" {
+		item["last_error"] = &types.AttributeValueMemberS{Value: rec.LastError}
+	}
+	if !rec.ClaimedAt.IsZero() {
+		item["claimed_at"] = &types.AttributeValueMemberS{Value: rec.ClaimedAt.Format(time.RFC3339Nano)}
+	}
+	if s.ttl > 0 {
+		item["expires_at"] = epochSeconds(s.now().Add(s.ttl))
+	}
+	return item
+}
+
+// expired reports whether an item's TTL attribute is in the past.
+func (s *DynamoDBStore) expired(item map[string]types.AttributeValue) bool {
+	n, ok := item["expires_at"].(*types.AttributeValueMemberN)
+	if !ok {
+		return false
+	}
+	expiresAt, err := strconv.ParseInt(n.Value, 10, 64)
+	return err == nil && expiresAt <= s.now().Unix()
+}
+
+// recordFromItem converts a DynamoDB item back into a Record.
+func recordFromItem(item map[string]types.AttributeValue) Record {
+	str := func(name string) string {
+		if v, ok := item[name].(*types.AttributeValueMemberS); ok {
+			return v.Value
+		}
+		return ""
+	}
+
+	rec := Record{
+		EventType: str("event_type"),
+		Status:    Status(str("status")),
+		LastError: str("last_error"),
+	}
+	if v, ok := item["attempts"].(*types.AttributeValueMemberN); ok {
+		rec.Attempts, _ = strconv.Atoi(v.Value)
+	}
+	rec.ClaimedAt, _ = time.Parse(time.RFC3339Nano, str("claimed_at"))
+	rec.ProcessedAt, _ = time.Parse(time.RFC3339Nano, str("processed_at"))
+	return rec
+}
+
+// epochSeconds formats t the way DynamoDB TTL expects: a Number holding Unix
+// seconds.
+func epochSeconds(t time.Time) types.AttributeValue {
+	return &types.AttributeValueMemberN{Value: strconv.FormatInt(t.Unix(), 10)}
+}
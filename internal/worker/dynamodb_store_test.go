@@ -0,0 +1,240 @@
+package worker
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// fakeDynamoDB is an in-memory DynamoDBAPI. It understands only the
+// expressions DynamoDBStore sends.
+type fakeDynamoDB struct {
+	mu    sync.Mutex
+	items map[string]map[string]types.AttributeValue
+}
+
+func newFakeDynamoDB() *fakeDynamoDB {
+	return &fakeDynamoDB{items: make(map[string]map[string]types.AttributeValue)}
+}
+
+func fakeKey(key map[string]types.AttributeValue) string {
+	return key["event_uuid"].(*types.AttributeValueMemberS).Value
+}
+
+func fakeString(v types.AttributeValue) string {
+	s, _ := v.(*types.AttributeValueMemberS)
+	if s == nil {
+		return ""
+	}
+	return s.Value
+}
+
+var errConditionFailed = &types.ConditionalCheckFailedException{Message: aws.String("conditional request failed")}
+
+func fakeNumber(v types.AttributeValue) int64 {
+	n, _ := strconv.ParseInt(v.(*types.AttributeValueMemberN).Value, 10, 64)
+	return n
+}
+
+func (f *fakeDynamoDB) GetItem(_ context.Context, in *dynamodb.GetItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return &dynamodb.GetItemOutput{Item: f.items[fakeKey(in.Key)]}, nil
+}
+
+func (f *fakeDynamoDB) PutItem(_ context.Context, in *dynamodb.PutItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	key := fakeKey(in.Item)
+	if in.ConditionExpression != nil {
+		if existing, found := f.items[key]; found {
+			expiresAt, hasTTL := existing["expires_at"]
+			live := !hasTTL || fakeNumber(expiresAt) > fakeNumber(in.ExpressionAttributeValues[":now"])
+			if live && fakeString(existing["status"]) != string(StatusPending) {
+				return nil, errConditionFailed
+			}
+		}
+	}
+	f.items[key] = in.Item
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (f *fakeDynamoDB) DeleteItem(_ context.Context, in *dynamodb.DeleteItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.items, fakeKey(in.Key))
+	return &dynamodb.DeleteItemOutput{}, nil
+}
+
+// UpdateItem supports only the update made by DynamoDBStore.RecoverStale.
+func (f *fakeDynamoDB) UpdateItem(_ context.Context, in *dynamodb.UpdateItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	item, found := f.items[fakeKey(in.Key)]
+	if !found || fakeString(item["status"]) != string(StatusProcessing) {
+		return nil, errConditionFailed
+	}
+	item["status"] = in.ExpressionAttributeValues[":pending"]
+	item["last_error"] = in.ExpressionAttributeValues[":error"]
+	item["processed_at"] = in.ExpressionAttributeValues[":now"]
+	return &dynamodb.UpdateItemOutput{}, nil
+}
+
+// Scan returns every item one per page, to exercise pagination, ignoring
+// the filter expression.
+func (f *fakeDynamoDB) Scan(_ context.Context, in *dynamodb.ScanInput, _ ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	keys := make([]string, 0, len(f.items))
+	for key := range f.items {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	start := 0
+	if in.ExclusiveStartKey != nil {
+		start = slices.Index(keys, fakeKey(in.ExclusiveStartKey)) + 1
+	}
+	if start >= len(keys) {
+		return &dynamodb.ScanOutput{}, nil
+	}
+	item := f.items[keys[start]]
+	out := &dynamodb.ScanOutput{Items: []map[string]types.AttributeValue{item}}
+	if start+1 < len(keys) {
+		out.LastEvaluatedKey = map[string]types.AttributeValue{"event_uuid": item["event_uuid"]}
+	}
+	return out, nil
+}
+
+func TestDynamoDBStore(t *testing.T) {
+	ctx := context.Background()
+	client := newFakeDynamoDB()
+	store := NewDynamoDBStore(client, "webhook-idempotency", time.Hour)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+	key := "dynamo-uuid-123"
+
+	if found, err := store.Has(ctx, key); err != nil || found {
+		t.Fatalf("Expected Has(%q) to be false, got %v (err %v)", key, found, err)
+	}
+
+	// Only the first claim succeeds, and a claim is not an outcome.
+	claim := Record{EventType: "company.updated", Status: StatusProcessing, ProcessedAt: now}
+	if ok, err := store.SetIfAbsent(ctx, key, claim); err != nil || !ok {
+		t.Fatalf("Expected first SetIfAbsent to succeed, got %v (err %v)", ok, err)
+	}
+	if ok, err := store.SetIfAbsent(ctx, key, claim); err != nil || ok {
+		t.Errorf("Expected second SetIfAbsent to fail, got %v (err %v)", ok, err)
+	}
+	if found, _ := store.Has(ctx, key); found {
+		t.Errorf("Expected Has(%q) to be false while only claimed", key)
+	}
+	if got, want := fakeNumber(client.items[key]["expires_at"]), now.Add(time.Hour).Unix(); got != want {
+		t.Errorf("incorrect expires_at: got %v want %v", got, want)
+	}
+
+	// Releasing the claim allows it to be taken again.
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if ok, _ := store.SetIfAbsent(ctx, key, claim); !ok {
+		t.Errorf("Expected SetIfAbsent to succeed after release")
+	}
+
+	// Get round-trips every recorded field.
+	failed := Record{
+		EventType:   "company.updated",
+		Status:      StatusPermanentFailure,
+		Attempts:    2,
+		LastError:   "permanent error: validation failed",
+		ClaimedAt:   now.Add(-time.Second),
+		ProcessedAt: now,
+	}
+	if err := store.Set(ctx, key, failed); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if found, err := store.Has(ctx, key); err != nil || !found {
+		t.Fatalf("Expected Has(%q) to be true, got %v (err %v)", key, found, err)
+	}
+	got, found, err := store.Get(ctx, key)
+	if err != nil || !found {
+		t.Fatalf("Expected Get to succeed, got found=%v err=%v", found, err)
+	}
+	if got != failed {
+		t.Errorf("incorrect record: got %+v want %+v", got, failed)
+	}
+
+	// An expired item DynamoDB hasn't deleted yet is treated as absent.
+	now = now.Add(2 * time.Hour)
+	if found, _ := store.Has(ctx, key); found {
+		t.Errorf("Expected Has(%q) to be false after expiry", key)
+	}
+	if ok, err := store.SetIfAbsent(ctx, key, claim); err != nil || !ok {
+		t.Errorf("Expected SetIfAbsent to succeed after expiry, got %v (err %v)", ok, err)
+	}
+}
+
+func TestDynamoDBStoreRecoverStale(t *testing.T) {
+	ctx := context.Background()
+	store := NewDynamoDBStore(newFakeDynamoDB(), "webhook-idempotency", time.Hour)
+	cutoff := time.Now().UTC().Add(-10 * time.Minute)
+	store.Set(ctx, "stale", Record{Status: StatusProcessing, Attempts: 2, ClaimedAt: cutoff.Add(-time.Minute)})
+	store.Set(ctx, "fresh", Record{Status: StatusProcessing, Attempts: 1, ClaimedAt: cutoff.Add(time.Minute)})
+	store.Set(ctx, "done", Record{Status: StatusSucceeded, ClaimedAt: cutoff.Add(-time.Hour)})
+
+	recovered, err := store.RecoverStale(ctx, cutoff)
+	if err != nil {
+		t.Fatalf("RecoverStale failed: %v", err)
+	}
+	if recovered != 1 {
+		t.Errorf("incorrect recovered count: got %d want 1", recovered)
+	}
+	if rec, _, _ := store.Get(ctx, "stale"); rec.Status != StatusPending || rec.Attempts != 2 || rec.LastError == "" {
+		t.Errorf("stale claim not reset to pending: %+v", rec)
+	}
+	if rec, _, _ := store.Get(ctx, "fresh"); rec.Status != StatusProcessing {
+		t.Errorf("fresh claim should be left alone, got %q", rec.Status)
+	}
+
+	// A pending key can be claimed again, but a processing one can't.
+	claim := Record{Status: StatusProcessing, Attempts: 3, ClaimedAt: time.Now().UTC()}
+	if ok, err := store.SetIfAbsent(ctx, "stale", claim); err != nil || !ok {
+		t.Errorf("Expected SetIfAbsent to claim a pending key, got %v (err %v)", ok, err)
+	}
+	if ok, _ := store.SetIfAbsent(ctx, "fresh", claim); ok {
+		t.Errorf("Expected SetIfAbsent to fail for a processing key")
+	}
+}
+
+func TestDynamoDBStoreList(t *testing.T) {
+	ctx := context.Background()
+	store := NewDynamoDBStore(newFakeDynamoDB(), "webhook-idempotency", 0)
+	store.Set(ctx, "a", Record{EventType: "company.updated", Status: StatusSucceeded})
+	store.Set(ctx, "b", Record{EventType: "payroll.processed", Status: StatusSucceeded})
+	store.Set(ctx, "c", Record{EventType: "company.updated", Status: StatusSucceeded})
+
+	entries, next, err := store.List(ctx, ListOptions{EventType: "company.updated", Limit: 1})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Key != "a" || next != "a" {
+		t.Fatalf("incorrect first page: %+v (next %q)", entries, next)
+	}
+
+	// The fake returns one item per page, so "b" is skipped by the filter
+	// and the scan continues until it finds "c".
+	entries, next, err = store.List(ctx, ListOptions{EventType: "company.updated", Cursor: next, Limit: 1})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Key != "c" || next != "" {
+		t.Errorf("incorrect second page: %+v (next %q)", entries, next)
+	}
+}
@@ -1,6 +1,10 @@
 package worker
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"time"
+)
 
 // ErrPermanent signifies an error that is unlikely to be resolved by a retry,
 // such as a validation error (4xx).
@@ -10,8 +14,94 @@ func (e *ErrPermanent) Error() string { return fmt.Sprintf("permanent error: %v"
 func (e *ErrPermanent) Unwrap() error { return e.Err }
 
 // ErrTransient signifies a temporary error that may be resolved by a retry,
-// such as a network issue or a temporary server error (5xx).
-type ErrTransient struct{ Err error }
+// such as a network issue or a temporary server error (5xx). RetryAfter, if
+// set, is how long the provider asked us to wait before trying again, e.g.
+// from a 429's Retry-After header; it replaces the default retry delay.
+type ErrTransient struct {
+	Err        error
+	RetryAfter time.Duration
+}
 
 func (e *ErrTransient) Error() string { return fmt.Sprintf("transient error: %v", e.Err) }
-func (e *ErrTransient) Unwrap() error { return e.Err }
\ No newline at end of file
+func (e *ErrTransient) Unwrap() error { return e.Err }
+
+// Transientf returns an *ErrTransient whose error is formatted as by
+// fmt.Errorf, so %w wraps a cause.
+func Transientf(format string, args ...any) error {
+	return &ErrTransient{Err: fmt.Errorf(format, args...)}
+}
+
+// Permanentf returns an *ErrPermanent whose error is formatted as by
+// fmt.Errorf, so %w wraps a cause.
+func Permanentf(format string, args ...any) error {
+	return &ErrPermanent{Err: fmt.Errorf(format, args...)}
+}
+
+// IsTransient reports whether err, or any error it wraps, is an *ErrTransient.
+func IsTransient(err error) bool {
+	var transientErr *ErrTransient
+	return errors.As(err, &transientErr)
+}
+
+// IsPermanent reports whether err, or any error it wraps, is an *ErrPermanent.
+func IsPermanent(err error) bool {
+	var permanentErr *ErrPermanent
+	return errors.As(err, &permanentErr)
+}
+
+// ErrUnhandled is returned, possibly wrapped, by a Processor for events it
+// has no handler for. The event is recorded as succeeded so it isn't
+// retried, and counted so new event types don't go unnoticed.
+var ErrUnhandled = errors.New("no handler for event type")
+
+// ErrSkipped is returned, possibly wrapped, by a Processor or JobMiddleware
+// for an event it deliberately didn't process, e.g. a no-op update (see
+// NoOpUpdates). The event is recorded as StatusSkipped and not retried.
+var ErrSkipped = errors.New("event skipped")
+
+var (
+	// ErrSchema is returned, possibly wrapped, by a Processor for events
+	// whose payload it couldn't decode.
+	ErrSchema = errors.New("invalid event payload")
+	// ErrAuth is returned, possibly wrapped, by a Processor when the
+	// provider rejected its credentials.
+	ErrAuth = errors.New("not authorized by provider")
+	// ErrStale is the error a job is dead-lettered with when it is older
+	// than the pool's maximum job age (see Pool.SetMaxJobAge).
+	ErrStale = errors.New("job too old to process")
+	// ErrHandlerPanic is wrapped by the transient error an attempt fails
+	// with when its handler panics.
+	ErrHandlerPanic = errors.New("handler panicked")
+)
+
+// UnknownErrorPolicy is what a Pool does with a Processor error that is
+// neither an *ErrTransient nor an *ErrPermanent.
+type UnknownErrorPolicy string
+
+const (
+	// RetryUnknown retries unknown errors like transient ones. It is the default.
+	RetryUnknown UnknownErrorPolicy = "retry"
+	// DeadLetterUnknown gives up on them like permanent ones.
+	DeadLetterUnknown UnknownErrorPolicy = "dead_letter"
+)
+
+// ParseUnknownErrorPolicy parses "retry" or "dead_letter". An empty string
+// is RetryUnknown.
+func ParseUnknownErrorPolicy(s string) (UnknownErrorPolicy, error) {
+	switch policy := UnknownErrorPolicy(s); policy {
+	case "":
+		return RetryUnknown, nil
+	case RetryUnknown, DeadLetterUnknown:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown error policy %q: want %q or %q", s, RetryUnknown, DeadLetterUnknown)
+	}
+}
+
+// wrap converts an unknown error into the error type the policy treats it as.
+func (p UnknownErrorPolicy) wrap(err error) error {
+	if p == DeadLetterUnknown {
+		return &ErrPermanent{Err: err}
+	}
+	return &ErrTransient{Err: err}
+}
@@ -0,0 +1,44 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestErrorHelpers(t *testing.T) {
+	cause := errors.New("connection reset")
+
+	testCases := []struct {
+		name              string
+		err               error
+		expectedTransient bool
+		expectedPermanent bool
+	}{
+		{name: "Transientf", err: Transientf("fetching company: %w", cause), expectedTransient: true},
+		{name: "Permanentf", err: Permanentf("%w: bad payload", ErrSchema), expectedPermanent: true},
+		{name: "Wrapped Transient", err: fmt.Errorf("handler: %w", Transientf("timeout")), expectedTransient: true},
+		{name: "Struct Literal", err: &ErrPermanent{Err: cause}, expectedPermanent: true},
+		{name: "Plain Error", err: cause},
+		{name: "Nil", err: nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := IsTransient(tc.err); got != tc.expectedTransient {
+				t.Errorf("IsTransient: got %v want %v", got, tc.expectedTransient)
+			}
+			if got := IsPermanent(tc.err); got != tc.expectedPermanent {
+				t.Errorf("IsPermanent: got %v want %v", got, tc.expectedPermanent)
+			}
+		})
+	}
+
+	// Causes stay inspectable through the constructors.
+	if err := Transientf("fetching company: %w", cause); !errors.Is(err, cause) {
+		t.Errorf("Transientf lost its cause: got %v want it to wrap %v", err, cause)
+	}
+	if err := Permanentf("%w: bad payload", ErrSchema); !errors.Is(err, ErrSchema) {
+		t.Errorf("Permanentf lost its cause: got %v want it to wrap %v", err, ErrSchema)
+	}
+}
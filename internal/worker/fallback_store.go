@@ -0,0 +1,246 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+)
+
+// defaultFallbackRetry is how long a FallbackStore waits after the primary
+// store fails before trying it again, unless told otherwise.
+const defaultFallbackRetry = 5 * time.Second
+
+// FallbackStore is a Store that degrades to a second, usually in-memory,
+// store while its primary one is unreachable, e.g. during a Redis outage,
+// rather than failing every claim and so blocking all processing. Dedup in
+// the meantime is only as good as the fallback: with an in-memory one, a
+// duplicate delivered to another replica is processed again.
+//
+// Once the primary fails, operations go to the fallback and the primary is
+// tried again every retry interval, so a dead primary doesn't make every
+// event wait for its timeout. Keys recorded in the fallback are still
+// consulted after the primary recovers, so events handled during the outage
+// aren't processed again when Gusto retries them, and keys deleted during
+// the outage are deleted from the primary before it is used again, so that
+// claims it still holds don't refuse the events' retries.
+type FallbackStore struct {
+	primary  Store
+	fallback Store
+	retry    time.Duration
+	logger   *slog.Logger
+	now      func() time.Time
+
+	mu        sync.Mutex
+	downSince time.Time // When the primary failed; zero while it is healthy.
+	nextTry   time.Time // When to try the primary again.
+	// pendingDeletes are keys deleted while the primary was down, still to
+	// be deleted from it.
+	pendingDeletes map[string]struct{}
+}
+
+var _ Store = (*FallbackStore)(nil)
+
+// NewFallbackStore creates a FallbackStore using fallback whenever primary
+// fails, and trying primary again at most every retry, or a default
+// interval if retry is not positive.
+func NewFallbackStore(primary, fallback Store, retry time.Duration, logger *slog.Logger) *FallbackStore {
+	if retry <= 0 {
+		retry = defaultFallbackRetry
+	}
+	return &FallbackStore{primary: primary, fallback: fallback, retry: retry, logger: logger, now: time.Now}
+}
+
+// Degraded reports whether the fallback is in use, and since when.
+func (s *FallbackStore) Degraded() (bool, time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return !s.downSince.IsZero(), s.downSince
+}
+
+// do runs call against the primary store unless it is down and not yet due
+// another try, and against the fallback if the primary isn't used or fails,
+// reporting whether the primary was used.
+func (s *FallbackStore) do(ctx context.Context, op string, call func(Store) error) (bool, error) {
+	if s.tryPrimary() {
+		err := s.deletePending(ctx)
+		if err == nil {
+			err = call(s.primary)
+		}
+		if err == nil {
+			s.primaryUp()
+			return true, nil
+		}
+		if ctx.Err() != nil {
+			// The caller gave up; that says nothing about the primary.
+			return false, err
+		}
+		s.primaryDown(op, err)
+	}
+	idempotencyFallbackOps.WithLabelValues(op).Inc()
+	if err := call(s.fallback); err != nil {
+		return false, fmt.Errorf("fallback store: %w", err)
+	}
+	return false, nil
+}
+
+// deletePending deletes the keys deleted while the primary was down from
+// the primary, stopping at the first failure.
+func (s *FallbackStore) deletePending(ctx context.Context) error {
+	s.mu.Lock()
+	keys := slices.Collect(maps.Keys(s.pendingDeletes))
+	s.mu.Unlock()
+	for _, key := range keys {
+		if err := s.primary.Delete(ctx, key); err != nil {
+			return err
+		}
+		s.mu.Lock()
+		delete(s.pendingDeletes, key)
+		s.mu.Unlock()
+	}
+	if len(keys) > 0 {
+		s.logger.Info("Deleted keys released during the outage from the idempotency store", "keys", len(keys))
+	}
+	return nil
+}
+
+// tryPrimary reports whether the next operation should go to the primary.
+func (s *FallbackStore) tryPrimary() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.downSince.IsZero() || !s.now().Before(s.nextTry)
+}
+
+func (s *FallbackStore) primaryDown(op string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	s.nextTry = now.Add(s.retry)
+	if !s.downSince.IsZero() {
+		return
+	}
+	s.downSince = now
+	idempotencyFallbackActive.Set(1)
+	idempotencyFallbackActivations.Inc()
+	s.logger.Error("Idempotency store failed, falling back to best-effort dedup", "operation", op, "error", err, "retry", s.retry)
+}
+
+func (s *FallbackStore) primaryUp() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.downSince.IsZero() {
+		return
+	}
+	s.logger.Info("Idempotency store recovered", "degraded_for", s.now().Sub(s.downSince))
+	s.downSince = time.Time{}
+	idempotencyFallbackActive.Set(0)
+}
+
+// Has reports whether key has a recorded outcome in the primary store or,
+// failing that, the fallback.
+func (s *FallbackStore) Has(ctx context.Context, key string) (bool, error) {
+	var found bool
+	_, err := s.do(ctx, "has", func(store Store) (err error) {
+		found, err = store.Has(ctx, key)
+		return err
+	})
+	if err != nil || found {
+		return found, err
+	}
+	return s.fallback.Has(ctx, key)
+}
+
+// Get returns the record for key from the primary store or, failing that,
+// the fallback.
+func (s *FallbackStore) Get(ctx context.Context, key string) (Record, bool, error) {
+	var rec Record
+	var found bool
+	_, err := s.do(ctx, "get", func(store Store) (err error) {
+		rec, found, err = store.Get(ctx, key)
+		return err
+	})
+	if err != nil || found {
+		return rec, found, err
+	}
+	return s.fallback.Get(ctx, key)
+}
+
+// Set records the outcome in the primary store, or the fallback while the
+// primary is down.
+func (s *FallbackStore) Set(ctx context.Context, key string, rec Record) error {
+	_, err := s.do(ctx, "set", func(store Store) error {
+		return store.Set(ctx, key, rec)
+	})
+	return err
+}
+
+// SetIfAbsent claims key in the primary store, or the fallback while the
+// primary is down. A key the fallback holds, claimed or handled during an
+// outage, is refused either way.
+func (s *FallbackStore) SetIfAbsent(ctx context.Context, key string, rec Record) (bool, error) {
+	if held, found, err := s.fallback.Get(ctx, key); err != nil {
+		return false, fmt.Errorf("fallback store: %w", err)
+	} else if found && held.Status != StatusPending {
+		return false, nil
+	}
+	var stored bool
+	_, err := s.do(ctx, "set_if_absent", func(store Store) (err error) {
+		stored, err = store.SetIfAbsent(ctx, key, rec)
+		return err
+	})
+	return stored, err
+}
+
+// Delete removes key from both stores, so that an event deliberately
+// forgotten, or released after failing, is processed again wherever it was
+// recorded. While the primary is down, key is deleted from it once it
+// recovers.
+func (s *FallbackStore) Delete(ctx context.Context, key string) error {
+	usedPrimary, err := s.do(ctx, "delete", func(store Store) error {
+		return store.Delete(ctx, key)
+	})
+	if err != nil {
+		return err
+	}
+	if !usedPrimary {
+		s.mu.Lock()
+		if s.pendingDeletes == nil {
+			s.pendingDeletes = make(map[string]struct{})
+		}
+		s.pendingDeletes[key] = struct{}{}
+		s.mu.Unlock()
+	}
+	if err := s.fallback.Delete(ctx, key); err != nil {
+		return fmt.Errorf("fallback store: %w", err)
+	}
+	return nil
+}
+
+// List implements Lister by listing the primary store, if it can be listed.
+// Keys only in the fallback aren't included.
+func (s *FallbackStore) List(ctx context.Context, opts ListOptions) ([]Entry, string, error) {
+	lister, ok := s.primary.(Lister)
+	if !ok {
+		return nil, "", ErrNotListable
+	}
+	return lister.List(ctx, opts)
+}
+
+// RecoverStale implements Recoverer for whichever of the stores are
+// durable, returning the number of claims reset in both.
+func (s *FallbackStore) RecoverStale(ctx context.Context, cutoff time.Time) (int, error) {
+	total := 0
+	var errs []error
+	for _, store := range []Store{s.primary, s.fallback} {
+		if recoverer, ok := store.(Recoverer); ok {
+			n, err := recoverer.RecoverStale(ctx, cutoff)
+			total += n
+			errs = append(errs, err)
+		}
+	}
+	return total, errors.Join(errs...)
+}
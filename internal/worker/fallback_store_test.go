@@ -0,0 +1,173 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+// flakyStore is an IdempotencyStore that fails every operation while down.
+type flakyStore struct {
+	*IdempotencyStore
+	down  bool
+	calls int
+}
+
+var errStoreDown = errors.New("connection refused")
+
+func (s *flakyStore) fail() error {
+	s.calls++
+	if s.down {
+		return errStoreDown
+	}
+	return nil
+}
+
+func (s *flakyStore) Has(ctx context.Context, key string) (bool, error) {
+	if err := s.fail(); err != nil {
+		return false, err
+	}
+	return s.IdempotencyStore.Has(ctx, key)
+}
+
+func (s *flakyStore) Get(ctx context.Context, key string) (Record, bool, error) {
+	if err := s.fail(); err != nil {
+		return Record{}, false, err
+	}
+	return s.IdempotencyStore.Get(ctx, key)
+}
+
+func (s *flakyStore) Set(ctx context.Context, key string, rec Record) error {
+	if err := s.fail(); err != nil {
+		return err
+	}
+	return s.IdempotencyStore.Set(ctx, key, rec)
+}
+
+func (s *flakyStore) SetIfAbsent(ctx context.Context, key string, rec Record) (bool, error) {
+	if err := s.fail(); err != nil {
+		return false, err
+	}
+	return s.IdempotencyStore.SetIfAbsent(ctx, key, rec)
+}
+
+func (s *flakyStore) Delete(ctx context.Context, key string) error {
+	if err := s.fail(); err != nil {
+		return err
+	}
+	return s.IdempotencyStore.Delete(ctx, key)
+}
+
+func TestFallbackStore(t *testing.T) {
+	ctx := context.Background()
+	primary := &flakyStore{IdempotencyStore: NewIdempotencyStore()}
+	fallback := NewIdempotencyStore()
+	now := time.Now()
+	store := NewFallbackStore(primary, fallback, time.Minute, slog.New(slog.NewJSONHandler(io.Discard, nil)))
+	store.now = func() time.Time { return now }
+
+	// While the primary is healthy, the fallback isn't written.
+	if stored, err := store.SetIfAbsent(ctx, "before", Record{Status: StatusProcessing}); err != nil || !stored {
+		t.Fatalf("incorrect SetIfAbsent: got %v (err %v) want true", stored, err)
+	}
+	store.Set(ctx, "before", Record{Status: StatusSucceeded})
+	if _, found, _ := fallback.Get(ctx, "before"); found {
+		t.Error("fallback written while the primary is healthy")
+	}
+
+	// Once the primary fails, claims and outcomes go to the fallback
+	// instead of failing.
+	primary.down = true
+	if stored, err := store.SetIfAbsent(ctx, "during", Record{Status: StatusProcessing}); err != nil || !stored {
+		t.Fatalf("incorrect SetIfAbsent during outage: got %v (err %v) want true", stored, err)
+	}
+	if err := store.Set(ctx, "during", Record{Status: StatusSucceeded}); err != nil {
+		t.Fatalf("Set during outage failed: %v", err)
+	}
+	if degraded, since := store.Degraded(); !degraded || !since.Equal(now) {
+		t.Errorf("incorrect Degraded: got %v, %v want true, %v", degraded, since, now)
+	}
+	if found, err := store.Has(ctx, "during"); err != nil || !found {
+		t.Errorf("incorrect Has for key handled during outage: got %v (err %v) want true", found, err)
+	}
+
+	// The primary isn't tried again until the retry interval has passed.
+	calls := primary.calls
+	store.Has(ctx, "during")
+	if primary.calls != calls {
+		t.Error("primary tried again before the retry interval")
+	}
+
+	// After it recovers, the primary is used again, and events handled
+	// during the outage are still recognised.
+	primary.down = false
+	now = now.Add(2 * time.Minute)
+	if found, err := store.Has(ctx, "during"); err != nil || !found {
+		t.Errorf("incorrect Has after recovery: got %v (err %v) want true", found, err)
+	}
+	if degraded, _ := store.Degraded(); degraded {
+		t.Error("still degraded after the primary recovered")
+	}
+	if stored, _ := store.SetIfAbsent(ctx, "during", Record{Status: StatusProcessing}); stored {
+		t.Error("claimed a key handled during the outage")
+	}
+
+	// Deleting forgets the key in both stores.
+	if err := store.Delete(ctx, "during"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, found, _ := fallback.Get(ctx, "during"); found {
+		t.Error("key left behind in the fallback store")
+	}
+}
+
+func TestFallbackStoreReleasesClaimAfterOutage(t *testing.T) {
+	ctx := context.Background()
+	primary := &flakyStore{IdempotencyStore: NewIdempotencyStore()}
+	now := time.Now()
+	store := NewFallbackStore(primary, NewIdempotencyStore(), time.Minute, slog.New(slog.NewJSONHandler(io.Discard, nil)))
+	store.now = func() time.Time { return now }
+
+	// A job claims its event, then fails while the primary is down, so its
+	// claim can only be released in the fallback.
+	store.SetIfAbsent(ctx, "evt", Record{Status: StatusProcessing})
+	primary.down = true
+	if err := store.Delete(ctx, "evt"); err != nil {
+		t.Fatalf("Delete during outage failed: %v", err)
+	}
+	if _, found, _ := primary.IdempotencyStore.Get(ctx, "evt"); !found {
+		t.Fatal("expected the claim to still be held by the primary")
+	}
+
+	// The primary failing its next try keeps the release pending.
+	now = now.Add(2 * time.Minute)
+	store.Has(ctx, "other")
+
+	// Once it recovers, the claim is released before the primary is used,
+	// so Gusto's retry of the event is processed.
+	primary.down = false
+	now = now.Add(2 * time.Minute)
+	if stored, err := store.SetIfAbsent(ctx, "evt", Record{Status: StatusProcessing}); err != nil || !stored {
+		t.Errorf("incorrect SetIfAbsent after recovery: got %v (err %v) want true", stored, err)
+	}
+	if len(store.pendingDeletes) != 0 {
+		t.Errorf("releases still pending after recovery: %v", store.pendingDeletes)
+	}
+}
+
+func TestFallbackStoreIgnoresCancellation(t *testing.T) {
+	primary := &flakyStore{IdempotencyStore: NewIdempotencyStore(), down: true}
+	store := NewFallbackStore(primary, NewIdempotencyStore(), time.Minute, slog.New(slog.NewJSONHandler(io.Discard, nil)))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := store.SetIfAbsent(ctx, "a", Record{Status: StatusProcessing}); !errors.Is(err, errStoreDown) {
+		t.Errorf("expected the primary's error for a cancelled call, got %v", err)
+	}
+	if degraded, _ := store.Degraded(); degraded {
+		t.Error("a cancelled call switched to the fallback")
+	}
+}
@@ -0,0 +1,140 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"gusto-webhook-guide/internal/models"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// KafkaWriter is the subset of *kafka.Writer used by KafkaQueue.
+type KafkaWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+// KafkaReader is the subset of a consumer group *kafka.Reader used by
+// KafkaQueue.
+type KafkaReader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+// KafkaQueue is a durable JobQueue backed by a Kafka topic. Events are keyed
+// by resource UUID, so all events for a resource land on the same partition
+// and are delivered in order. The topic keeps events after they are
+// processed: another consumer group can read the same events independently,
+// and a new group can replay the topic from the start.
+//
+// Delivery is at least once. A partition's offset is only committed past a
+// job once it and every job fetched before it from that partition have been
+// acknowledged, so jobs still in flight when the process dies are delivered
+// again.
+type KafkaQueue struct {
+	writer KafkaWriter
+	reader KafkaReader // Nil for a process that only produces.
+
+	mu      sync.Mutex
+	pending map[int][]*kafkaDelivery // In-flight jobs per partition, in fetch order.
+}
+
+// kafkaDelivery is a fetched message and whether its job was acknowledged.
+type kafkaDelivery struct {
+	msg   kafka.Message
+	acked bool
+}
+
+var _ JobQueue = (*KafkaQueue)(nil)
+
+// NewKafkaQueue creates a KafkaQueue publishing with writer and consuming
+// with reader, which must belong to a consumer group. reader may be nil if
+// this process never dequeues.
+func NewKafkaQueue(writer KafkaWriter, reader KafkaReader) *KafkaQueue {
+	return &KafkaQueue{
+		writer:  writer,
+		reader:  reader,
+		pending: make(map[int][]*kafkaDelivery),
+	}
+}
+
+// Enqueue implements JobQueue. The topic is unbounded, so wait is unused.
+func (q *KafkaQueue) Enqueue(ctx context.Context, job models.Job, _ time.Duration) error {
+	var envelope struct {
+		ResourceUUID string `json:"resource_uuid"`
+	}
+	json.Unmarshal(job.Payload, &envelope) // Jobs without one are spread over partitions.
+
+	err := q.writer.WriteMessages(ctx, kafka.Message{
+		Key:   []byte(envelope.ResourceUUID),
+		Value: job.Payload,
+		Headers: []kafka.Header{
+			{Key: "attempts", Value: []byte(strconv.Itoa(job.Attempts))},
+			{Key: "received_at", Value: []byte(formatReceivedAt(job.ReceivedAt))},
+			{Key: "request_id", Value: []byte(job.RequestID)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("enqueuing job: %w", err)
+	}
+	return nil
+}
+
+// Dequeue implements JobQueue.
+func (q *KafkaQueue) Dequeue(ctx context.Context) (models.Job, func() error, error) {
+	if q.reader == nil {
+		return models.Job{}, nil, ErrQueueClosed
+	}
+	msg, err := q.reader.FetchMessage(ctx)
+	if ctx.Err() != nil {
+		return models.Job{}, nil, ctx.Err()
+	}
+	if err != nil {
+		return models.Job{}, nil, fmt.Errorf("dequeuing job: %w", err)
+	}
+
+	job := models.Job{Payload: msg.Value}
+	for _, h := range msg.Headers {
+		switch h.Key {
+		case "attempts":
+			job.Attempts, _ = strconv.Atoi(string(h.Value))
+		case "received_at":
+			job.ReceivedAt = parseReceivedAt(string(h.Value))
+		case "request_id":
+			job.RequestID = string(h.Value)
+		}
+	}
+
+	d := &kafkaDelivery{msg: msg}
+	q.mu.Lock()
+	q.pending[msg.Partition] = append(q.pending[msg.Partition], d)
+	q.mu.Unlock()
+	return job, func() error { return q.ack(ctx, d) }, nil
+}
+
+// ack marks d as handled and commits its partition's offset past every
+// leading job that has been acknowledged.
+func (q *KafkaQueue) ack(ctx context.Context, d *kafkaDelivery) error {
+	q.mu.Lock()
+	d.acked = true
+	inFlight := q.pending[d.msg.Partition]
+	done := 0
+	for done < len(inFlight) && inFlight[done].acked {
+		done++
+	}
+	if done == 0 {
+		q.mu.Unlock()
+		return nil // An earlier job is still being handled.
+	}
+	last := inFlight[done-1].msg
+	q.pending[d.msg.Partition] = inFlight[done:]
+	q.mu.Unlock()
+
+	if err := q.reader.CommitMessages(context.WithoutCancel(ctx), last); err != nil {
+		return fmt.Errorf("acknowledging job: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"gusto-webhook-guide/internal/models"
+	"sync"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// fakeKafka is an in-memory KafkaWriter and KafkaReader. Messages with the
+// same key go to the same of two partitions, and fetches alternate between
+// partitions.
+type fakeKafka struct {
+	mu        sync.Mutex
+	messages  []kafka.Message
+	fetched   int
+	committed map[int]int64 // Last committed offset per partition.
+}
+
+func (f *fakeKafka) WriteMessages(_ context.Context, msgs ...kafka.Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, msg := range msgs {
+		msg.Partition = len(msg.Key) % 2
+		msg.Offset = int64(len(f.messages))
+		f.messages = append(f.messages, msg)
+	}
+	return nil
+}
+
+func (f *fakeKafka) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.fetched == len(f.messages) {
+		return kafka.Message{}, errors.New("no more messages")
+	}
+	f.fetched++
+	return f.messages[f.fetched-1], nil
+}
+
+func (f *fakeKafka) CommitMessages(_ context.Context, msgs ...kafka.Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, msg := range msgs {
+		f.committed[msg.Partition] = msg.Offset
+	}
+	return nil
+}
+
+func TestKafkaQueue(t *testing.T) {
+	ctx := context.Background()
+	client := &fakeKafka{committed: make(map[int]int64)}
+	q := NewKafkaQueue(client, client)
+
+	for _, payload := range []string{
+		`{"uuid":"1","resource_uuid":"aa"}`,
+		`{"uuid":"2","resource_uuid":"aa"}`,
+		`{"uuid":"3","resource_uuid":"b"}`,
+	} {
+		if err := q.Enqueue(ctx, models.Job{Payload: []byte(payload), Attempts: 1}, 0); err != nil {
+			t.Fatalf("Enqueue failed: %v", err)
+		}
+	}
+	if client.messages[0].Partition != client.messages[1].Partition {
+		t.Errorf("events for the same resource were split across partitions")
+	}
+
+	var acks []func() error
+	for i := range 3 {
+		job, ack, err := q.Dequeue(ctx)
+		if err != nil {
+			t.Fatalf("Dequeue failed: %v", err)
+		}
+		if job.Attempts != 1 {
+			t.Errorf("incorrect attempts for job %d: got %d want 1", i, job.Attempts)
+		}
+		acks = append(acks, ack)
+	}
+
+	// Acknowledging a later job commits nothing while an earlier job on the
+	// same partition is in flight.
+	if err := acks[1](); err != nil {
+		t.Fatalf("ack failed: %v", err)
+	}
+	if _, found := client.committed[0]; found {
+		t.Errorf("offset committed past an unacknowledged job: got %v", client.committed)
+	}
+	if err := acks[0](); err != nil {
+		t.Fatalf("ack failed: %v", err)
+	}
+	if got := client.committed[0]; got != 1 {
+		t.Errorf("incorrect committed offset: got %d want 1", got)
+	}
+	// Other partitions are committed independently.
+	if err := acks[2](); err != nil {
+		t.Fatalf("ack failed: %v", err)
+	}
+	if got, found := client.committed[1]; !found || got != 2 {
+		t.Errorf("incorrect committed offset: got %d want 2", got)
+	}
+}
+
+func TestKafkaQueueProducerOnly(t *testing.T) {
+	q := NewKafkaQueue(&fakeKafka{}, nil)
+	if _, _, err := q.Dequeue(context.Background()); !errors.Is(err, ErrQueueClosed) {
+		t.Errorf("incorrect error: got %v want %v", err, ErrQueueClosed)
+	}
+}
@@ -0,0 +1,130 @@
+package worker
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Locker is a lock shared by every replica, held while an event is
+// processed. It complements the idempotency store's claim for deployments
+// where the store itself is not shared.
+type Locker interface {
+	// TryLock takes the lock for key without waiting. It reports false if
+	// another holder has it; otherwise the returned function releases it.
+	TryLock(ctx context.Context, key string) (unlock func() error, acquired bool, err error)
+}
+
+// SetLocker makes workers hold locker's lock for each event while
+// processing it. Events whose lock is held elsewhere are skipped as
+// duplicates. It must be called before Start.
+func (p *Pool) SetLocker(locker Locker) {
+	p.locker = locker
+}
+
+// tryLock takes the claim lock for key if a Locker is configured. The
+// returned unlock function is always safe to call.
+func (p *Pool) tryLock(ctx context.Context, logger *slog.Logger, key string) (func(), bool, error) {
+	if p.locker == nil {
+		return func() {}, true, nil
+	}
+	unlock, acquired, err := p.locker.TryLock(ctx, key)
+	if err != nil || !acquired {
+		return func() {}, acquired, err
+	}
+	return func() {
+		if err := unlock(); err != nil {
+			logger.Error("Failed to release claim lock", "error", err)
+		}
+	}, true, nil
+}
+
+// unlockScript deletes the lock only if it still holds our token, so a lock
+// that expired and was taken by another replica is left alone.
+var unlockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// RedisLocker is a Locker using SET NX with an expiry. The expiry frees the
+// lock if its holder crashes, so it must exceed the longest processing time.
+type RedisLocker struct {
+	client redis.UniversalClient
+	prefix string
+	ttl    time.Duration
+}
+
+var _ Locker = (*RedisLocker)(nil)
+
+// NewRedisLocker creates a RedisLocker whose keys are namespaced with prefix
+// and expire after ttl.
+func NewRedisLocker(client redis.UniversalClient, prefix string, ttl time.Duration) *RedisLocker {
+	return &RedisLocker{client: client, prefix: prefix, ttl: ttl}
+}
+
+// TryLock implements Locker.
+func (l *RedisLocker) TryLock(ctx context.Context, key string) (func() error, bool, error) {
+	token := make([]byte, 16)
+	rand.Read(token)
+	value := hex.EncodeToString(token)
+
+	acquired, err := l.client.SetNX(ctx, l.prefix+key, value, l.ttl).Result()
+	if err != nil {
+		return nil, false, fmt.Errorf("taking claim lock: %w", err)
+	}
+	if !acquired {
+		return nil, false, nil
+	}
+	return func() error {
+		if err := unlockScript.Run(context.WithoutCancel(ctx), l.client, []string{l.prefix + key}, value).Err(); err != nil {
+			return fmt.Errorf("releasing claim lock: %w", err)
+		}
+		return nil
+	}, true, nil
+}
+
+// PostgresLocker is a Locker using session-level advisory locks. Each held
+// lock pins one connection, and Postgres releases it if the process dies.
+type PostgresLocker struct {
+	db *sql.DB
+}
+
+var _ Locker = (*PostgresLocker)(nil)
+
+// NewPostgresLocker creates a PostgresLocker using an open database handle.
+func NewPostgresLocker(db *sql.DB) *PostgresLocker {
+	return &PostgresLocker{db: db}
+}
+
+// TryLock implements Locker. Keys are hashed to the 64-bit advisory lock
+// space.
+func (l *PostgresLocker) TryLock(ctx context.Context, key string) (func() error, bool, error) {
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("taking claim lock: %w", err)
+	}
+	var acquired bool
+	if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock(hashtextextended($1, 0))`, key).Scan(&acquired); err != nil {
+		conn.Close()
+		return nil, false, fmt.Errorf("taking claim lock: %w", err)
+	}
+	if !acquired {
+		conn.Close()
+		return nil, false, nil
+	}
+	return func() error {
+		defer conn.Close()
+		if _, err := conn.ExecContext(context.WithoutCancel(ctx), `SELECT pg_advisory_unlock(hashtextextended($1, 0))`, key); err != nil {
+			return fmt.Errorf("releasing claim lock: %w", err)
+		}
+		return nil
+	}, true, nil
+}
@@ -0,0 +1,100 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"gusto-webhook-guide/internal/models"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// testLocker exercises the TryLock contract shared by every Locker.
+func testLocker(t *testing.T, a, b Locker) {
+	t.Helper()
+	ctx := context.Background()
+
+	unlock, ok, err := a.TryLock(ctx, "lock-uuid")
+	if err != nil || !ok {
+		t.Fatalf("Expected first TryLock to succeed, got %v (err %v)", ok, err)
+	}
+	if _, ok, err := b.TryLock(ctx, "lock-uuid"); err != nil || ok {
+		t.Fatalf("Expected TryLock of a held lock to fail, got %v (err %v)", ok, err)
+	}
+	if _, ok, _ := b.TryLock(ctx, "other-uuid"); !ok {
+		t.Errorf("Expected TryLock of a different key to succeed")
+	}
+	if err := unlock(); err != nil {
+		t.Fatalf("unlock failed: %v", err)
+	}
+	if _, ok, _ := b.TryLock(ctx, "lock-uuid"); !ok {
+		t.Errorf("Expected TryLock to succeed after unlock")
+	}
+}
+
+func TestRedisLocker(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+
+	testLocker(t, NewRedisLocker(client, "lock:", time.Minute), NewRedisLocker(client, "lock:", time.Minute))
+
+	// An expired lock can be taken over, and the old holder's unlock must
+	// not release the new holder's lock.
+	ctx := context.Background()
+	locker := NewRedisLocker(client, "lock:", time.Minute)
+	unlock, _, _ := locker.TryLock(ctx, "expiring-uuid")
+	server.FastForward(time.Minute)
+	if _, ok, _ := locker.TryLock(ctx, "expiring-uuid"); !ok {
+		t.Fatalf("Expected TryLock to succeed after expiry")
+	}
+	unlock()
+	if !server.Exists("lock:expiring-uuid") {
+		t.Errorf("Expected the new holder's lock to survive a stale unlock")
+	}
+}
+
+func TestPostgresLocker(t *testing.T) {
+	db := openTestPostgres(t)
+	other := openTestPostgres(t)
+	testLocker(t, NewPostgresLocker(db), NewPostgresLocker(other))
+}
+
+func TestWorkerSkipsLockedEvent(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	idempotencyStore := NewIdempotencyStore()
+	pool := NewPool(2, 1, logger, idempotencyStore, stubProcessor)
+	pool.SetLocker(NewRedisLocker(client, "lock:", time.Minute))
+
+	// Another replica holds the lock for this event.
+	other := NewRedisLocker(client, "lock:", time.Minute)
+	if _, ok, _ := other.TryLock(context.Background(), "locked-uuid"); !ok {
+		t.Fatalf("failed to take lock")
+	}
+
+	pool.Start(1)
+	for _, uuid := range []string{"locked-uuid", "free-uuid"} {
+		payloadBytes, _ := json.Marshal(models.WebhookEvent{UUID: uuid, EventType: "company.created"})
+		pool.JobQueue <- models.Job{Payload: payloadBytes}
+	}
+	close(pool.JobQueue)
+	pool.wg.Wait()
+
+	if _, found, _ := idempotencyStore.Get(context.Background(), "locked-uuid"); found {
+		t.Errorf("Expected locked event to be skipped")
+	}
+	if rec, _, _ := idempotencyStore.Get(context.Background(), "free-uuid"); rec.Status != StatusSucceeded {
+		t.Errorf("incorrect status: got %q want %q", rec.Status, StatusSucceeded)
+	}
+	if server.Exists("lock:free-uuid") {
+		t.Errorf("Expected lock to be released after processing")
+	}
+}
@@ -0,0 +1,129 @@
+package worker
+
+import (
+	"container/list"
+	"context"
+	"sync"
+)
+
+// LRUStore is an in-memory Store holding at most a fixed number of keys.
+// When full, the least recently used key is evicted, so memory stays
+// predictable at the cost of not deduplicating very old events.
+type LRUStore struct {
+	mu         sync.Mutex
+	maxEntries int
+	order      *list.List // Front is most recently used; values are *lruEntry.
+	items      map[string]*list.Element
+}
+
+type lruEntry struct {
+	key string
+	rec Record
+}
+
+var (
+	_ Store  = (*LRUStore)(nil)
+	_ Lister = (*LRUStore)(nil)
+)
+
+// NewLRUStore creates an LRUStore holding up to maxEntries keys.
+func NewLRUStore(maxEntries int) *LRUStore {
+	return &LRUStore{
+		maxEntries: maxEntries,
+		order:      list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+// Has reports whether a key (event UUID) has a recorded outcome.
+func (s *LRUStore) Has(_ context.Context, key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	el, found := s.items[key]
+	if !found {
+		return false, nil
+	}
+	s.order.MoveToFront(el)
+	return el.Value.(*lruEntry).rec.Status.final(), nil
+}
+
+// Get returns the record for a key (event UUID).
+func (s *LRUStore) Get(_ context.Context, key string) (Record, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	el, found := s.items[key]
+	if !found {
+		return Record{}, false, nil
+	}
+	s.order.MoveToFront(el)
+	return el.Value.(*lruEntry).rec, true, nil
+}
+
+// Set adds a key (event UUID) to the store, evicting the least recently used
+// key if the store is full.
+func (s *LRUStore) Set(_ context.Context, key string, rec Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.set(key, rec)
+	return nil
+}
+
+// SetIfAbsent adds a key (event UUID) to the store unless it is already present.
+func (s *LRUStore) SetIfAbsent(_ context.Context, key string, rec Record) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if el, found := s.items[key]; found && el.Value.(*lruEntry).rec.Status != StatusPending {
+		s.order.MoveToFront(el)
+		return false, nil
+	}
+	s.set(key, rec)
+	return true, nil
+}
+
+// Delete removes a key (event UUID) from the store.
+func (s *LRUStore) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if el, found := s.items[key]; found {
+		s.order.Remove(el)
+		delete(s.items, key)
+	}
+	return nil
+}
+
+// List returns a page of entries, ordered by key. Listing doesn't count as
+// use, so it leaves the eviction order alone.
+func (s *LRUStore) List(_ context.Context, opts ListOptions) ([]Entry, string, error) {
+	s.mu.Lock()
+	var entries []Entry
+	for key, el := range s.items {
+		if rec := el.Value.(*lruEntry).rec; inPage(key, rec, opts) {
+			entries = append(entries, Entry{Key: key, Record: rec})
+		}
+	}
+	s.mu.Unlock()
+	entries, next := paginate(entries, opts.Limit)
+	return entries, next, nil
+}
+
+// Len returns the number of keys currently held.
+func (s *LRUStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.items)
+}
+
+// set stores rec under key and evicts as needed. Callers must hold s.mu.
+func (s *LRUStore) set(key string, rec Record) {
+	if el, found := s.items[key]; found {
+		el.Value.(*lruEntry).rec = rec
+		s.order.MoveToFront(el)
+		return
+	}
+	s.items[key] = s.order.PushFront(&lruEntry{key: key, rec: rec})
+	for s.maxEntries > 0 && s.order.Len() > s.maxEntries {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.items, oldest.Value.(*lruEntry).key)
+	}
+}
@@ -0,0 +1,82 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+func TestLRUStore(t *testing.T) {
+	ctx := context.Background()
+	done := Record{Status: StatusSucceeded}
+
+	t.Run("Evicts Least Recently Used", func(t *testing.T) {
+		store := NewLRUStore(2)
+		store.Set(ctx, "a", done)
+		store.Set(ctx, "b", done)
+
+		// Touch "a" so that "b" becomes the least recently used key.
+		store.Has(ctx, "a")
+		store.Set(ctx, "c", done)
+
+		if found, _ := store.Has(ctx, "b"); found {
+			t.Errorf("Expected key %q to be evicted", "b")
+		}
+		for _, key := range []string{"a", "c"} {
+			if found, _ := store.Has(ctx, key); !found {
+				t.Errorf("Expected key %q to be retained", key)
+			}
+		}
+		if got := store.Len(); got != 2 {
+			t.Errorf("incorrect Len: got %d want 2", got)
+		}
+	})
+
+	t.Run("Claims and Releases", func(t *testing.T) {
+		store := NewLRUStore(10)
+		claim := Record{Status: StatusProcessing}
+		if ok, _ := store.SetIfAbsent(ctx, "k", claim); !ok {
+			t.Fatalf("Expected first claim to succeed")
+		}
+		if ok, _ := store.SetIfAbsent(ctx, "k", claim); ok {
+			t.Errorf("Expected second claim to fail")
+		}
+		if found, _ := store.Has(ctx, "k"); found {
+			t.Errorf("Expected a claim not to count as an outcome")
+		}
+		store.Delete(ctx, "k")
+		if _, found, _ := store.Get(ctx, "k"); found {
+			t.Errorf("Expected key to be gone after Delete")
+		}
+	})
+
+	t.Run("Concurrency Safety", func(t *testing.T) {
+		store := NewLRUStore(50)
+		var wg sync.WaitGroup
+		wg.Add(100)
+		for i := range 100 {
+			go func() {
+				defer wg.Done()
+				store.Set(ctx, string(rune('a'+i%26)), done)
+				store.Has(ctx, "a")
+			}()
+		}
+		wg.Wait()
+		if got := store.Len(); got > 50 {
+			t.Errorf("store exceeded its capacity: %d keys", got)
+		}
+	})
+}
+
+func TestLRUStoreList(t *testing.T) {
+	ctx := context.Background()
+	store := NewLRUStore(0)
+	store.Set(ctx, "b", Record{EventType: "company.updated"})
+	store.Set(ctx, "a", Record{EventType: "company.updated"})
+	store.Set(ctx, "c", Record{EventType: "payroll.processed"})
+
+	entries, next, _ := store.List(ctx, ListOptions{EventType: "company.updated", Limit: 10})
+	if len(entries) != 2 || entries[0].Key != "a" || entries[1].Key != "b" || next != "" {
+		t.Errorf("incorrect page: %+v (next %q)", entries, next)
+	}
+}
@@ -0,0 +1,178 @@
+package worker
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// queueSampleInterval is how often the pool reports its backlog to Metrics.
+const queueSampleInterval = 10 * time.Second
+
+// Metrics receives measurements of the pool's work. The default,
+// PrometheusMetrics, exports them on /metrics; Pool.SetMetrics replaces it,
+// e.g. to send them to another monitoring system.
+type Metrics interface {
+	// JobProcessed records one processing attempt at an event: how long it
+	// took, and its result, "success", "skipped" or the error's class
+	// ("transient", "permanent" or "unknown").
+	JobProcessed(eventType, result string, duration time.Duration)
+	// JobRetried records a job scheduled for another attempt after an error
+	// of the given class.
+	JobRetried(eventType, class string)
+	// JobDeadLettered records a job added to the dead-letter queue.
+	JobDeadLettered(eventType string, reason DeadLetterReason)
+	// QueueSampled records the pool's backlog, every queueSampleInterval.
+	QueueSampled(stats QueueStats)
+}
+
+// QueueStats is the pool's backlog at one moment.
+type QueueStats struct {
+	Queued      int // Jobs waiting in the queue, or -1 if the queue can't tell.
+	Retrying    int // Jobs waiting for their retry delay.
+	DeadLetters int // Entries in the dead-letter queue.
+}
+
+// PrometheusMetrics is the default Metrics, recording into the
+// webhook_worker_* Prometheus metrics.
+type PrometheusMetrics struct{}
+
+var _ Metrics = PrometheusMetrics{}
+
+// JobProcessed implements Metrics.
+func (PrometheusMetrics) JobProcessed(eventType, result string, duration time.Duration) {
+	jobDuration.WithLabelValues(eventType, result).Observe(duration.Seconds())
+}
+
+// JobRetried implements Metrics.
+func (PrometheusMetrics) JobRetried(_, class string) {
+	jobRetries.WithLabelValues(class).Inc()
+}
+
+// JobDeadLettered implements Metrics.
+func (PrometheusMetrics) JobDeadLettered(_ string, reason DeadLetterReason) {
+	deadLetters.WithLabelValues(string(reason)).Inc()
+}
+
+// QueueSampled implements Metrics.
+func (PrometheusMetrics) QueueSampled(stats QueueStats) {
+	if stats.Queued >= 0 {
+		queueDepth.Set(float64(stats.Queued))
+	}
+	retryingJobs.Set(float64(stats.Retrying))
+	deadLetterQueueSize.Set(float64(stats.DeadLetters))
+}
+
+var (
+	inflightJobs = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "webhook_worker_inflight_jobs",
+		Help: "Number of jobs currently being processed, by event type.",
+	}, []string{"event_type"})
+
+	retriesHeld = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "webhook_worker_retries_held",
+		Help: "1 while scheduled retries are held, e.g. during a provider outage, otherwise 0.",
+	})
+
+	jobsQuarantined = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "webhook_worker_quarantined_total",
+		Help: "Jobs quarantined as poison messages, by event type.",
+	}, []string{"event_type"})
+
+	retryBudgetExhausted = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "webhook_worker_retry_budget_exhausted_total",
+		Help: "Jobs dead-lettered instead of retried because the retry budget was spent.",
+	})
+
+	workerCount = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "webhook_worker_count",
+		Help: "Number of running workers.",
+	})
+
+	concurrencyLimit = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "webhook_worker_concurrency_limit",
+		Help: "Configured maximum concurrent jobs, by event type.",
+	}, []string{"event_type"})
+
+	concurrencyWaits = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "webhook_worker_concurrency_waits_total",
+		Help: "Jobs that had to wait for a free slot under their event type's concurrency limit.",
+	}, []string{"event_type"})
+
+	deadLetters = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "webhook_worker_dead_letters_total",
+		Help: "Jobs added to the dead-letter queue, by reason.",
+	}, []string{"reason"})
+
+	jobErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "webhook_worker_job_errors_total",
+		Help: "Failed processing attempts, by error class: transient, permanent or unknown.",
+	}, []string{"class"})
+
+	jobRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "webhook_worker_retries_total",
+		Help: "Jobs scheduled for another attempt, by the class of the error that failed them.",
+	}, []string{"class"})
+
+	unhandledEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "webhook_worker_unhandled_events_total",
+		Help: "Events skipped because no handler matched their event type.",
+	}, []string{"event_type"})
+
+	jobDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "webhook_worker_job_duration_seconds",
+		Help:    "How long each processing attempt took, by event type and result (success, skipped, transient, permanent or unknown).",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"event_type", "result"})
+
+	queueDepth = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "webhook_worker_queue_depth",
+		Help: "Jobs waiting in the queue, for queues that can report it.",
+	})
+
+	retryingJobs = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "webhook_worker_retrying_jobs",
+		Help: "Jobs waiting for their retry delay.",
+	})
+
+	deadLetterQueueSize = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "webhook_worker_dead_letter_queue_size",
+		Help: "Entries in the in-memory dead-letter queue.",
+	})
+
+	handlerPanics = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "webhook_worker_panics_total",
+		Help: "Processing attempts that panicked and were retried, by event type (\"batch\" for a batch handler).",
+	}, []string{"event_type"})
+
+	noOpUpdatesSkipped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "webhook_worker_noop_updates_skipped_total",
+		Help: "Update events skipped because they changed nothing relevant since the last update processed, by event type.",
+	}, []string{"event_type"})
+
+	sandboxRejections = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "webhook_worker_sandbox_rejections_total",
+		Help: "Attempts a sandboxed handler failed, by handler pattern and reason (\"panic\", \"timeout\" or \"busy\").",
+	}, []string{"handler", "reason"})
+
+	idempotencyFallbackActive = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "webhook_idempotency_fallback_active",
+		Help: "1 while the idempotency store has failed and its fallback is in use, otherwise 0.",
+	})
+
+	idempotencyFallbackActivations = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "webhook_idempotency_fallback_activations_total",
+		Help: "Times the idempotency store failed and its fallback was switched to.",
+	})
+
+	idempotencyFallbackOps = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "webhook_idempotency_fallback_operations_total",
+		Help: "Idempotency store operations served by the fallback store, by operation.",
+	}, []string{"operation"})
+
+	controlsSyncFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "webhook_worker_controls_sync_failures_total",
+		Help: "Failed reads of the worker controls shared between replicas.",
+	})
+)
@@ -0,0 +1,92 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"gusto-webhook-guide/internal/models"
+	"io"
+	"log/slog"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingMetrics is a Metrics that keeps what it is given.
+type recordingMetrics struct {
+	mu          sync.Mutex
+	processed   []string // "event_type:result"
+	retried     []string // "event_type:class"
+	deadLetters []DeadLetterReason
+	samples     []QueueStats
+}
+
+func (m *recordingMetrics) JobProcessed(eventType, result string, _ time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.processed = append(m.processed, eventType+":"+result)
+}
+
+func (m *recordingMetrics) JobRetried(eventType, class string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.retried = append(m.retried, eventType+":"+class)
+}
+
+func (m *recordingMetrics) JobDeadLettered(_ string, reason DeadLetterReason) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.deadLetters = append(m.deadLetters, reason)
+}
+
+func (m *recordingMetrics) QueueSampled(stats QueueStats) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.samples = append(m.samples, stats)
+}
+
+func TestPoolMetrics(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	processor := ProcessorFunc(func(_ context.Context, event models.WebhookEvent) error {
+		switch event.UUID {
+		case "transient":
+			return Transientf("timeout")
+		case "permanent":
+			return Permanentf("invalid")
+		case "unknown":
+			return errors.New("surprise")
+		}
+		return nil
+	})
+	pool := NewPool(10, 0, logger, NewIdempotencyStore(), processor)
+	defer pool.Stop()
+	metrics := &recordingMetrics{}
+	pool.SetMetrics(metrics)
+
+	for _, uuid := range []string{"ok", "transient", "permanent", "unknown"} {
+		pool.handleJob(1, models.Job{Payload: []byte(`{"uuid":"` + uuid + `","event_type":"company.updated"}`)})
+	}
+	pool.JobQueue <- models.Job{}
+	pool.Start(0)
+	waitFor(t, func() bool {
+		metrics.mu.Lock()
+		defer metrics.mu.Unlock()
+		return len(metrics.samples) > 0
+	})
+
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+	wantProcessed := []string{"company.updated:success", "company.updated:transient", "company.updated:permanent", "company.updated:unknown"}
+	if !slices.Equal(metrics.processed, wantProcessed) {
+		t.Errorf("incorrect processed jobs: got %v want %v", metrics.processed, wantProcessed)
+	}
+	if wantRetried := []string{"company.updated:transient", "company.updated:unknown"}; !slices.Equal(metrics.retried, wantRetried) {
+		t.Errorf("incorrect retries: got %v want %v", metrics.retried, wantRetried)
+	}
+	if wantDeadLetters := []DeadLetterReason{ReasonHandlerBug}; !slices.Equal(metrics.deadLetters, wantDeadLetters) {
+		t.Errorf("incorrect dead letters: got %v want %v", metrics.deadLetters, wantDeadLetters)
+	}
+	if want := (QueueStats{Queued: 1, Retrying: 2, DeadLetters: 1}); metrics.samples[0] != want {
+		t.Errorf("incorrect queue sample: got %+v want %+v", metrics.samples[0], want)
+	}
+}
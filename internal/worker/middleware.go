@@ -0,0 +1,51 @@
+package worker
+
+import (
+	"context"
+	"gusto-webhook-guide/internal/models"
+	"log/slog"
+	"time"
+)
+
+// JobHandler processes one attempt at an event, like Processor.Process.
+type JobHandler func(ctx context.Context, event models.WebhookEvent) error
+
+// JobMiddleware wraps a JobHandler to add behaviour around processing, such
+// as logging, metrics or tracing, without changing the worker loop.
+type JobMiddleware func(next JobHandler) JobHandler
+
+// Use adds middleware around the Processor. The first middleware added is
+// the outermost. Middleware runs inside the job timeout, so ctx carries the
+// attempt's deadline, and only for events the worker has claimed. It must be
+// called before Start.
+func (p *Pool) Use(mw ...JobMiddleware) {
+	p.middleware = append(p.middleware, mw...)
+}
+
+// handler returns the Processor wrapped in the pool's middleware.
+func (p *Pool) handler() JobHandler {
+	h := JobHandler(p.processor.Process)
+	for i := len(p.middleware) - 1; i >= 0; i-- {
+		h = p.middleware[i](h)
+	}
+	return h
+}
+
+// LogJobs is a JobMiddleware that logs how long each attempt took and
+// whether it failed.
+func LogJobs(logger *slog.Logger) JobMiddleware {
+	return func(next JobHandler) JobHandler {
+		return func(ctx context.Context, event models.WebhookEvent) error {
+			start := time.Now()
+			err := next(ctx, event)
+			logger.Info("Job attempt finished",
+				"event_uuid", event.UUID,
+				"event_type", event.EventType,
+				"attempt", Attempt(ctx),
+				"duration", time.Since(start),
+				"error", err,
+			)
+			return err
+		}
+	}
+}
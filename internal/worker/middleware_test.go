@@ -0,0 +1,48 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"gusto-webhook-guide/internal/models"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestPoolUse(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	var calls []string
+	processor := ProcessorFunc(func(context.Context, models.WebhookEvent) error {
+		calls = append(calls, "processor")
+		return nil
+	})
+	trace := func(name string) JobMiddleware {
+		return func(next JobHandler) JobHandler {
+			return func(ctx context.Context, event models.WebhookEvent) error {
+				calls = append(calls, name+" before")
+				err := next(ctx, event)
+				calls = append(calls, name+" after")
+				return err
+			}
+		}
+	}
+
+	var logs bytes.Buffer
+	pool := NewPool(1, 1, logger, NewIdempotencyStore(), processor)
+	pool.Use(trace("outer"), trace("inner"), LogJobs(slog.New(slog.NewJSONHandler(&logs, nil))))
+	payload, _ := json.Marshal(models.WebhookEvent{UUID: "mw-uuid", EventType: "company.updated"})
+	pool.Start(1)
+	pool.JobQueue <- models.Job{Payload: payload}
+	pool.Stop()
+
+	want := []string{"outer before", "inner before", "processor", "inner after", "outer after"}
+	if !slices.Equal(calls, want) {
+		t.Errorf("incorrect middleware order: got %v want %v", calls, want)
+	}
+	if !strings.Contains(logs.String(), `"event_uuid":"mw-uuid"`) {
+		t.Errorf("LogJobs did not log the attempt: %s", logs.String())
+	}
+}
@@ -0,0 +1,95 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// MigrationCount reports how many items a migration read from its source,
+// wrote to its target, and read back from the target to verify.
+type MigrationCount struct {
+	Read     int `json:"read"`
+	Written  int `json:"written"`
+	Verified int `json:"verified"`
+}
+
+// Complete reports whether everything read was written and verified.
+func (c MigrationCount) Complete() bool {
+	return c.Written == c.Read && c.Verified == c.Written
+}
+
+// CopyKeys copies every idempotency key in src to dst, then reads each one
+// back from dst to check its status survived. Keys are left in src. dst
+// applies its own TTL, if any, from the time of the copy.
+func CopyKeys(ctx context.Context, src Lister, dst Store) (MigrationCount, error) {
+	var count MigrationCount
+	var copied []Entry
+	cursor := ""
+	for {
+		entries, next, err := src.List(ctx, ListOptions{Cursor: cursor, Limit: 500})
+		if err != nil {
+			return count, fmt.Errorf("listing keys: %w", err)
+		}
+		for _, e := range entries {
+			count.Read++
+			if err := dst.Set(ctx, e.Key, e.Record); err != nil {
+				return count, fmt.Errorf("writing key %s: %w", e.Key, err)
+			}
+			count.Written++
+			copied = append(copied, e)
+		}
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+
+	for _, e := range copied {
+		rec, found, err := dst.Get(ctx, e.Key)
+		if err != nil {
+			return count, fmt.Errorf("verifying key %s: %w", e.Key, err)
+		}
+		if found && rec.Status == e.Status {
+			count.Verified++
+		}
+	}
+	return count, nil
+}
+
+// MoveJobs takes jobs off src and enqueues them on dst until src has had
+// nothing to hand out for idle, or is closed. Each job is acknowledged on
+// src only once dst has it, so a failure part way leaves it on src rather
+// than losing it. A job moved twice is dropped by the idempotency store.
+// Verified counts the jobs acknowledged on src.
+func MoveJobs(ctx context.Context, src, dst JobQueue, idle time.Duration) (MigrationCount, error) {
+	var count MigrationCount
+	for {
+		waitCtx, cancel := context.WithCancel(ctx)
+		timer := time.AfterFunc(idle, cancel)
+		job, ack, err := src.Dequeue(waitCtx)
+		timer.Stop()
+		if err != nil {
+			cancel()
+			if errors.Is(err, ErrQueueClosed) || (waitCtx.Err() != nil && ctx.Err() == nil) {
+				return count, nil // Drained.
+			}
+			return count, fmt.Errorf("taking job: %w", err)
+		}
+		count.Read++
+
+		err = dst.Enqueue(ctx, job, -1)
+		if err == nil {
+			count.Written++
+			err = ack()
+			if err == nil {
+				count.Verified++
+			}
+		}
+		cancel()
+		if err != nil {
+			return count, fmt.Errorf("moving job: %w", err)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package worker
+
+import (
+	"context"
+	"gusto-webhook-guide/internal/models"
+	"testing"
+	"time"
+)
+
+func TestCopyKeys(t *testing.T) {
+	ctx := context.Background()
+	src := NewIdempotencyStore()
+	src.Set(ctx, "a", Record{Status: StatusSucceeded})
+	src.Set(ctx, "b", Record{Status: StatusDeadLettered})
+	dst := NewLRUStore(10)
+
+	count, err := CopyKeys(ctx, src, dst)
+	if err != nil {
+		t.Fatalf("CopyKeys failed: %v", err)
+	}
+	if want := (MigrationCount{Read: 2, Written: 2, Verified: 2}); count != want || !count.Complete() {
+		t.Errorf("incorrect counts: got %+v want %+v", count, want)
+	}
+	if rec, found, _ := dst.Get(ctx, "b"); !found || rec.Status != StatusDeadLettered {
+		t.Errorf("key not copied: got %+v (found %v)", rec, found)
+	}
+}
+
+func TestMoveJobs(t *testing.T) {
+	ctx := context.Background()
+	src := make(ChannelQueue, 3)
+	src <- models.Job{Payload: []byte("a")}
+	src <- models.Job{Payload: []byte("b"), Attempts: 2}
+	dst := make(ChannelQueue, 3)
+
+	// An open, empty source counts as drained after the idle period.
+	count, err := MoveJobs(ctx, src, dst, 20*time.Millisecond)
+	if err != nil {
+		t.Fatalf("MoveJobs failed: %v", err)
+	}
+	if want := (MigrationCount{Read: 2, Written: 2, Verified: 2}); count != want {
+		t.Errorf("incorrect counts: got %+v want %+v", count, want)
+	}
+	if len(src) != 0 || len(dst) != 2 {
+		t.Errorf("jobs not moved: %d left on source, %d on target", len(src), len(dst))
+	}
+
+	// A target that stays full stops the move with an error.
+	src <- models.Job{Payload: []byte("c")}
+	full := make(ChannelQueue)
+	fullCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	count, err = MoveJobs(fullCtx, src, full, time.Second)
+	if err == nil || count.Complete() {
+		t.Errorf("expected an incomplete move to a full queue: got %+v (err %v)", count, err)
+	}
+}
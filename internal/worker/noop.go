@@ -0,0 +1,99 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"gusto-webhook-guide/internal/deliveries"
+	"gusto-webhook-guide/internal/models"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// NoOpUpdates skips *.updated events that change nothing relevant, to cut
+// the downstream load of the provider's frequent no-op updates. It caches
+// the payload of the last update processed successfully for each resource,
+// and fails a later update of the same resource with ErrSkipped, recording
+// it as StatusSkipped, if the payloads only differ in ignored fields. Events
+// without a payload are always processed.
+type NoOpUpdates struct {
+	mu      sync.Mutex
+	max     int
+	ignore  []string
+	order   []string // Resource keys in first-cached order, for eviction.
+	results map[string][]byte
+}
+
+// NewNoOpUpdates creates a NoOpUpdates caching the payloads of up to max
+// resources, forgetting the oldest once the limit is reached. Changes to
+// the ignore fields, e.g. "updated_at" or "version", and anything nested
+// under them, don't count as relevant.
+func NewNoOpUpdates(max int, ignore []string) *NoOpUpdates {
+	return &NoOpUpdates{max: max, ignore: ignore, results: make(map[string][]byte)}
+}
+
+// Middleware is a JobMiddleware that skips no-op updates.
+func (n *NoOpUpdates) Middleware(next JobHandler) JobHandler {
+	return func(ctx context.Context, event models.WebhookEvent) error {
+		key := updatedResource(event)
+		if key == "" {
+			return next(ctx, event)
+		}
+		n.mu.Lock()
+		last, cached := n.results[key]
+		n.mu.Unlock()
+		if cached && !n.relevant(deliveries.Diff(last, event.Payload)) {
+			noOpUpdatesSkipped.WithLabelValues(event.EventType).Inc()
+			return fmt.Errorf("%w: no relevant changes to %s since the last update", ErrSkipped, key)
+		}
+
+		if err := next(ctx, event); err != nil {
+			return err
+		}
+		n.store(key, event.Payload)
+		return nil
+	}
+}
+
+// relevant reports whether any of changes is to a field that isn't ignored.
+func (n *NoOpUpdates) relevant(changes []deliveries.Change) bool {
+	return slices.ContainsFunc(changes, func(c deliveries.Change) bool {
+		return !slices.ContainsFunc(n.ignore, func(field string) bool {
+			return c.Path == field || strings.HasPrefix(c.Path, field+".") || strings.HasPrefix(c.Path, field+"[")
+		})
+	})
+}
+
+// store caches the payload processed for a resource.
+func (n *NoOpUpdates) store(key string, payload []byte) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.max <= 0 {
+		return
+	}
+	if _, found := n.results[key]; !found {
+		if len(n.order) >= n.max {
+			delete(n.results, n.order[0])
+			n.order = n.order[1:]
+		}
+		n.order = append(n.order, key)
+	}
+	n.results[key] = payload
+}
+
+// updatedResource returns the key of the resource an *.updated event with a
+// payload is about, or "" for other events. The entity is the object that
+// changed, if the event names one, and otherwise the resource.
+func updatedResource(event models.WebhookEvent) string {
+	if !strings.HasSuffix(event.EventType, ".updated") || len(event.Payload) == 0 || string(event.Payload) == "null" {
+		return ""
+	}
+	kind, id := event.EntityType, event.EntityUUID
+	if id == "" {
+		kind, id = event.ResourceType, event.ResourceUUID
+	}
+	if id == "" {
+		return ""
+	}
+	return event.EventType + ":" + strings.ToLower(kind) + "/" + id
+}
@@ -0,0 +1,78 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"gusto-webhook-guide/internal/models"
+	"io"
+	"log/slog"
+	"testing"
+)
+
+func TestNoOpUpdates(t *testing.T) {
+	noOps := NewNoOpUpdates(10, []string{"updated_at"})
+	calls := 0
+	fail := false
+	handler := noOps.Middleware(func(context.Context, models.WebhookEvent) error {
+		calls++
+		if fail {
+			return Transientf("downstream unavailable")
+		}
+		return nil
+	})
+	update := func(eventType, resourceUUID, payload string) models.WebhookEvent {
+		return models.WebhookEvent{EventType: eventType, ResourceType: "Company", ResourceUUID: resourceUUID, Payload: json.RawMessage(payload)}
+	}
+
+	testCases := []struct {
+		name        string
+		event       models.WebhookEvent
+		fail        bool
+		expectSkip  bool
+		expectCalls int
+	}{
+		{name: "First Update", event: update("company.updated", "c1", `{"name":"Acme","updated_at":"1"}`), expectCalls: 1},
+		{name: "Only Ignored Fields Changed", event: update("company.updated", "c1", `{"name":"Acme","updated_at":"2"}`), expectSkip: true, expectCalls: 1},
+		{name: "Relevant Change", event: update("company.updated", "c1", `{"name":"Acme Corp","updated_at":"3"}`), expectCalls: 2},
+		{name: "Another Resource", event: update("company.updated", "c2", `{"name":"Acme Corp"}`), expectCalls: 3},
+		{name: "Not An Update", event: update("company.created", "c1", `{"name":"Acme Corp"}`), expectCalls: 4},
+		{name: "No Payload", event: update("company.updated", "c1", ``), expectCalls: 5},
+		{name: "Failed Update Isn't Cached", event: update("company.updated", "c2", `{"name":"Initech"}`), fail: true, expectCalls: 6},
+		{name: "Retry Of Failed Update", event: update("company.updated", "c2", `{"name":"Initech"}`), expectCalls: 7},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fail = tc.fail
+			err := handler(context.Background(), tc.event)
+			if skipped := errors.Is(err, ErrSkipped); skipped != tc.expectSkip {
+				t.Errorf("incorrect result: got %v want skipped %v", err, tc.expectSkip)
+			}
+			if calls != tc.expectCalls {
+				t.Errorf("handler calls: got %d want %d", calls, tc.expectCalls)
+			}
+		})
+	}
+}
+
+func TestPoolRecordsSkippedEvents(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	store := NewIdempotencyStore()
+	processor := ProcessorFunc(func(context.Context, models.WebhookEvent) error {
+		return ErrSkipped
+	})
+	pool := NewPool(1, 1, logger, store, processor)
+	payload, _ := json.Marshal(models.WebhookEvent{UUID: "skip-uuid", EventType: "company.updated"})
+	pool.Start(1)
+	pool.JobQueue <- models.Job{Payload: payload}
+	pool.Stop()
+
+	rec, found, _ := store.Get(context.Background(), "skip-uuid")
+	if !found || rec.Status != StatusSkipped {
+		t.Errorf("incorrect record: got %+v (found %v) want status %s", rec, found, StatusSkipped)
+	}
+	if pool.DeadLetters().Len() != 0 {
+		t.Error("a skipped event was dead-lettered")
+	}
+}
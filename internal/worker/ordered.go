@@ -0,0 +1,114 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"gusto-webhook-guide/internal/models"
+	"hash/fnv"
+	"time"
+)
+
+// orderedBacklog is how many jobs may wait for each worker of an ordered pool.
+const orderedBacklog = 16
+
+// SetOrdered makes the pool process events for the same resource one at a
+// time, in the order they leave the queue. A dispatcher routes each job by
+// its resource_uuid to one of the workers launched by Start. Once a busy
+// worker's backlog fills, dispatching to the others waits for it, and a
+// retried event is processed after any later events for its resource.
+// Ordered pools don't autoscale. It must be called before Start.
+func (p *Pool) SetOrdered(ordered bool) {
+	p.ordered = ordered
+}
+
+// dispatchedJob is a job routed to a worker of an ordered pool.
+type dispatchedJob struct {
+	job models.Job
+	ack func() error
+}
+
+// startOrdered launches numWorkers workers, each fed its share of the
+// resources by a single dispatcher.
+func (p *Pool) startOrdered(numWorkers int) {
+	if numWorkers < 1 {
+		return
+	}
+	shards := make([]chan dispatchedJob, numWorkers)
+
+	p.workersMu.Lock()
+	for i := range shards {
+		shards[i] = make(chan dispatchedJob, orderedBacklog)
+		p.nextWorkerID++
+		p.workers = append(p.workers, func() {}) // Retired by the dispatcher.
+		p.wg.Add(1)
+		go p.shardWorker(p.nextWorkerID, shards[i])
+	}
+	workerCount.Set(float64(len(p.workers)))
+	p.workersMu.Unlock()
+
+	p.wg.Add(1)
+	go p.dispatch(p.workerContext(), shards)
+}
+
+// dispatch takes jobs off the queue and hands each to the worker for its
+// resource until the queue closes or ctx is cancelled, then stops the
+// workers once they have finished their backlog.
+func (p *Pool) dispatch(ctx context.Context, shards []chan dispatchedJob) {
+	defer p.wg.Done()
+	defer func() {
+		for _, shard := range shards {
+			close(shard)
+		}
+	}()
+
+	for ctx.Err() == nil {
+		p.waitResumed(ctx)
+		job, ack, err := p.queue.Dequeue(ctx)
+		if err != nil {
+			if errors.Is(err, ErrQueueClosed) || ctx.Err() != nil {
+				return
+			}
+			p.logger.Error("Dispatcher failed to dequeue job", "error", err)
+			select {
+			case <-time.After(time.Second): // Don't spin while the queue is unavailable.
+			case <-ctx.Done():
+			}
+			continue
+		}
+		p.inFlight.Add(1) // Until its worker acknowledges it.
+		shards[shardFor(job.Payload, len(shards))] <- dispatchedJob{job: job, ack: ack}
+	}
+}
+
+// shardWorker processes the jobs dispatched to it, one at a time.
+func (p *Pool) shardWorker(id int, jobs <-chan dispatchedJob) {
+	defer p.wg.Done()
+	p.logger.Info("Worker started", "worker_id", id)
+	for d := range jobs {
+		p.waitResumed(context.Background()) // Stop resumes the pool.
+		p.handleJob(id, d.job)
+		if err := d.ack(); err != nil {
+			p.logger.Error("Worker failed to acknowledge job", "worker_id", id, "error", err)
+		}
+		p.inFlight.Add(-1)
+	}
+	p.logger.Info("Worker stopped", "worker_id", id)
+}
+
+// shardFor picks which of n workers handles a job. Jobs without a resource
+// UUID have nothing to be ordered with, so they are spread by event UUID.
+func shardFor(payload []byte, n int) int {
+	var envelope struct {
+		UUID         string `json:"uuid"`
+		ResourceUUID string `json:"resource_uuid"`
+	}
+	json.Unmarshal(payload, &envelope) // Undecodable jobs are dead-lettered by the worker.
+	key := envelope.ResourceUUID
+	if key == "" {
+		key = envelope.UUID
+	}
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(n))
+}
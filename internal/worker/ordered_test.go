@@ -0,0 +1,61 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"gusto-webhook-guide/internal/models"
+	"io"
+	"log/slog"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOrderedPoolKeepsResourceOrder(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+
+	var mu sync.Mutex
+	seen := make(map[string][]string) // Event UUIDs processed per resource.
+	processor := ProcessorFunc(func(ctx context.Context, event models.WebhookEvent) error {
+		time.Sleep(time.Millisecond) // Give unordered workers a chance to overtake.
+		mu.Lock()
+		defer mu.Unlock()
+		seen[event.ResourceUUID] = append(seen[event.ResourceUUID], event.UUID)
+		return nil
+	})
+	pool := NewPool(100, 4, logger, NewIdempotencyStore(), processor)
+	pool.SetOrdered(true)
+
+	want := make(map[string][]string)
+	for i := range 40 {
+		resource := fmt.Sprintf("resource-%d", i%3)
+		event := models.WebhookEvent{UUID: fmt.Sprintf("event-%d", i), EventType: "company.updated", ResourceUUID: resource}
+		want[resource] = append(want[resource], event.UUID)
+		payload, _ := json.Marshal(event)
+		pool.JobQueue <- models.Job{Payload: payload}
+	}
+	pool.Start(4)
+	if got := pool.Workers(); got != 4 {
+		t.Errorf("incorrect worker count: got %d want 4", got)
+	}
+	pool.Stop()
+
+	for resource, events := range want {
+		if !slices.Equal(seen[resource], events) {
+			t.Errorf("events for %s processed out of order: got %v want %v", resource, seen[resource], events)
+		}
+	}
+}
+
+func TestShardFor(t *testing.T) {
+	a := shardFor([]byte(`{"uuid":"1","resource_uuid":"r"}`), 8)
+	b := shardFor([]byte(`{"uuid":"2","resource_uuid":"r"}`), 8)
+	if a != b {
+		t.Errorf("events for one resource routed to different workers: %d and %d", a, b)
+	}
+	if n := shardFor([]byte(`{"invalid-json`), 8); n < 0 || n >= 8 {
+		t.Errorf("shard out of range: got %d", n)
+	}
+}
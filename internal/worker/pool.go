@@ -1,163 +1,695 @@
 package worker
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"gusto-webhook-guide/internal/models"
-	"io"
 	"log/slog"
-	"net/http"
-	"strings"
+	"runtime/debug"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"golang.org/x/sync/semaphore"
 )
 
 const maxRetries = 5
-const retryDelay = 10 * time.Second
+
+// defaultRetryDelay is how long a transient failure waits before its retry,
+// unless the provider says otherwise.
+const defaultRetryDelay = 10 * time.Second
+
+// defaultStopGrace is how long Stop lets in-flight jobs finish before
+// cancelling them.
+const defaultStopGrace = 10 * time.Second
+
+// abandonedJobWait is how long StopContext waits for cancelled jobs to
+// return before abandoning them.
+const abandonedJobWait = time.Second
+
+// maxRetryAfter caps a provider-requested retry delay, so a bad Retry-After
+// header can't park a job indefinitely.
+const maxRetryAfter = 15 * time.Minute
+
+// Processor performs the provider-specific work for an event. Returning an
+// *ErrTransient (see Transientf) schedules a retry; an *ErrPermanent (see
+// Permanentf) gives up immediately.
+type Processor interface {
+	Process(ctx context.Context, event models.WebhookEvent) error
+}
+
+// ProcessorFunc adapts an ordinary function to the Processor interface.
+type ProcessorFunc func(ctx context.Context, event models.WebhookEvent) error
+
+// Process calls f(ctx, event).
+func (f ProcessorFunc) Process(ctx context.Context, event models.WebhookEvent) error {
+	return f(ctx, event)
+}
+
+type attemptKey struct{}
+
+// WithAttempt returns a copy of ctx for the given attempt at an event,
+// starting at 1. Workers call it before running a Processor.
+func WithAttempt(ctx context.Context, attempt int) context.Context {
+	return context.WithValue(ctx, attemptKey{}, attempt)
+}
+
+// Attempt returns the attempt set by WithAttempt, or 0 if there is none.
+func Attempt(ctx context.Context) int {
+	n, _ := ctx.Value(attemptKey{}).(int)
+	return n
+}
 
 // Pool manages a pool of workers and a job queue.
 type Pool struct {
-	JobQueue         chan models.Job
+	JobQueue         chan models.Job // The normal-priority lane of the default queue.
+	lanes            *PriorityQueue  // The default queue.
+	queue            JobQueue
 	wg               sync.WaitGroup
 	logger           *slog.Logger
-	idempotencyStore *IdempotencyStore
+	idempotencyStore Store
+	processor        Processor
+	ctx              context.Context // Cancelled by Stop to abort pending retry waits.
+	cancel           context.CancelFunc
+	jobsCtx          context.Context // Cancelled once in-flight jobs outlive the stop grace period.
+	cancelJobs       context.CancelFunc
+	jobTimeout       time.Duration // Processing deadline per attempt; zero means none.
+	maxJobAge        time.Duration // Age past which jobs are dead-lettered; zero means none.
+	retryBudget      *retryBudget  // Optional cap on the share of attempts that are retries.
+	retryDelay       time.Duration
+	drain            drainMeter    // Jobs finished recently, for DrainTime.
+	poisonAttempts   int           // Abnormal attempts after which a job is quarantined; zero disables.
+	poisonDuration   time.Duration // Attempts this long count as abnormal; zero means only panics do.
+	quarantine       *Quarantine
+	stopGrace        time.Duration
+	limits           map[string]*semaphore.Weighted // Per-event-type concurrency caps.
+	locker           Locker                         // Optional cross-replica claim lock.
+	unhandled        *UnhandledTracker
+	deadLetters      *DeadLetterQueue
+	schedule         Schedule    // Optional store for future-dated jobs.
+	results          ResultStore // Optional store for what processing produced.
+	unknownErrors    UnknownErrorPolicy
+	ordered          bool        // Process each resource's events in order.
+	spillPath        string      // Optional file Stop saves unprocessed jobs to.
+	drainOnStop      atomic.Bool // Stop processes queued jobs rather than spilling them.
+	middleware       []JobMiddleware
+	metrics          Metrics
+	batchSize        int           // Jobs each worker takes at once; below 2 disables batching.
+	batchWait        time.Duration // How long a worker waits to fill a batch.
+
+	triageMu    sync.Mutex
+	triageRules []TriageRule
+
+	workersMu    sync.Mutex
+	workers      []context.CancelFunc // Retires each running worker.
+	nextWorkerID int
+	inFlight     atomic.Int64 // Jobs taken off the queue and not yet acknowledged.
+
+	pauseMu sync.Mutex
+	resumed chan struct{} // Closed by Resume; nil unless paused.
+
+	controls     ControlStore // Optional toggles shared with other replicas.
+	controlsSync time.Duration
+	controlsDone chan struct{} // Closed when syncControls returns.
+	controlsMu   sync.Mutex
+	shared       Controls // The shared toggles last applied or recorded.
+
+	latencyMu    sync.Mutex
+	latencyTotal time.Duration // Processing time since the autoscaler last looked.
+	latencyJobs  int
+
+	failuresMu sync.Mutex
+	failures   map[string][]AttemptFailure // Failed attempts of events being retried, by UUID.
+
+	retriesMu   sync.Mutex
+	retries     retryHeap            // Jobs waiting for their retry delay.
+	retryHolds  map[string]RetryHold // While any are set, due retries stay scheduled.
+	retryWake   chan struct{}        // Signals runRetries that a retry was scheduled.
+	retriesDone chan struct{}        // Closed when runRetries returns.
 }
 
 // NewPool creates a new worker pool.
-func NewPool(maxQueueSize, numWorkers int, logger *slog.Logger, store *IdempotencyStore) *Pool {
-	return &Pool{
-		JobQueue:         make(chan models.Job, maxQueueSize),
+func NewPool(maxQueueSize, numWorkers int, logger *slog.Logger, store Store, processor Processor) *Pool {
+	ctx, cancel := context.WithCancel(context.Background())
+	jobsCtx, cancelJobs := context.WithCancel(context.Background())
+	jobQueue := make(chan models.Job, maxQueueSize)
+	lanes := newPriorityQueue(jobQueue, maxQueueSize)
+	p := &Pool{
+		JobQueue:         jobQueue,
+		lanes:            lanes,
+		queue:            lanes,
 		logger:           logger,
 		idempotencyStore: store,
+		processor:        processor,
+		ctx:              ctx,
+		cancel:           cancel,
+		jobsCtx:          jobsCtx,
+		cancelJobs:       cancelJobs,
+		stopGrace:        defaultStopGrace,
+		retryDelay:       defaultRetryDelay,
+		retryWake:        make(chan struct{}, 1),
+		retriesDone:      make(chan struct{}),
+		unhandled:        NewUnhandledTracker(),
+		deadLetters:      NewDeadLetterQueue(defaultDeadLetterCapacity),
+		quarantine:       NewQuarantine(defaultQuarantineCapacity),
+		unknownErrors:    RetryUnknown,
+		metrics:          PrometheusMetrics{},
 	}
+	go p.runRetries()
+	return p
+}
+
+// SetQueue replaces the default in-memory queue, e.g. with a durable
+// PostgresQueue. It must be called before Start and before jobs are queued.
+func (p *Pool) SetQueue(q JobQueue) {
+	p.queue = q
+}
+
+// SetMetrics replaces where the pool's measurements are recorded, by default
+// PrometheusMetrics. It must be called before Start.
+func (p *Pool) SetMetrics(m Metrics) {
+	p.metrics = m
+}
+
+// SetUnknownErrorPolicy sets what happens to events whose Processor error is
+// neither transient nor permanent. It must be called before Start.
+func (p *Pool) SetUnknownErrorPolicy(policy UnknownErrorPolicy) {
+	p.unknownErrors = policy
+}
+
+// SetJobTimeout sets the deadline for processing each attempt at a job.
+// An attempt that runs out of time fails with a transient error. Zero, the
+// default, means no deadline. It must be called before Start.
+func (p *Pool) SetJobTimeout(timeout time.Duration) {
+	p.jobTimeout = timeout
+}
+
+// SetMaxJobAge sets how long after a webhook was received its job may still
+// be processed. Older jobs, such as a backlog left by an outage, are
+// dead-lettered as stale rather than acting on out-of-date events; they can
+// be redriven if they turn out to be wanted. Jobs whose queue didn't record
+// when they were received are always processed. Zero, the default, means no
+// limit. It must be called before Start.
+func (p *Pool) SetMaxJobAge(age time.Duration) {
+	p.maxJobAge = age
+}
+
+// SetRetryDelay sets how long a transient failure waits before its retry
+// when the provider doesn't say, by default 10 seconds. It must be called
+// before Start.
+func (p *Pool) SetRetryDelay(delay time.Duration) {
+	p.retryDelay = delay
+}
+
+// SetStopGrace sets how long Stop waits for in-flight jobs before
+// cancelling their contexts, which aborts their API calls. It must be called
+// before Start.
+func (p *Pool) SetStopGrace(grace time.Duration) {
+	p.stopGrace = grace
+}
+
+// Queue returns the queue workers take jobs from.
+func (p *Pool) Queue() JobQueue {
+	return p.queue
+}
+
+// Unhandled returns the tracker of events no handler matched.
+func (p *Pool) Unhandled() *UnhandledTracker {
+	return p.unhandled
 }
 
 // Start launches the worker goroutines.
 func (p *Pool) Start(numWorkers int) {
-	for i := 1; i <= numWorkers; i++ {
-		p.wg.Add(1)
-		go p.worker(i)
+	go p.sampleQueue()
+	if p.controls != nil {
+		p.controlsDone = make(chan struct{})
+		go p.syncControls()
+	}
+	if p.ordered {
+		p.startOrdered(numWorkers)
+		return
+	}
+	for range numWorkers {
+		p.addWorker()
+	}
+}
+
+// Workers returns the number of running workers.
+func (p *Pool) Workers() int {
+	p.workersMu.Lock()
+	defer p.workersMu.Unlock()
+	return len(p.workers)
+}
+
+// workerContext returns the context for taking jobs off the queue. The
+// in-memory queue is drained at shutdown rather than abandoned, so only
+// retiring a worker stops it waiting for jobs.
+func (p *Pool) workerContext() context.Context {
+	switch p.queue.(type) {
+	case ChannelQueue, *PriorityQueue:
+		return context.Background()
+	}
+	return p.ctx
+}
+
+// addWorker launches another worker.
+func (p *Pool) addWorker() {
+	ctx, retire := context.WithCancel(p.workerContext())
+
+	p.workersMu.Lock()
+	defer p.workersMu.Unlock()
+	p.nextWorkerID++
+	p.workers = append(p.workers, retire)
+	workerCount.Set(float64(len(p.workers)))
+	p.wg.Add(1)
+	if p.batchSize > 1 {
+		go p.batchWorker(ctx, p.nextWorkerID)
+	} else {
+		go p.worker(ctx, p.nextWorkerID)
+	}
+}
+
+// removeWorker retires the newest worker once it finishes its current job,
+// reporting whether there was one to retire.
+func (p *Pool) removeWorker() bool {
+	p.workersMu.Lock()
+	defer p.workersMu.Unlock()
+	if len(p.workers) == 0 {
+		return false
 	}
+	last := len(p.workers) - 1
+	p.workers[last]()
+	p.workers = p.workers[:last]
+	workerCount.Set(float64(len(p.workers)))
+	return true
 }
 
-// Stop waits for all workers to finish processing.
+// Stop waits for all workers to finish processing. Jobs still running after
+// the stop grace period have their contexts cancelled, and Stop then waits
+// for them however long they take; StopContext gives up instead. With a
+// spill path set, queued jobs and pending retries are saved rather than
+// processed or abandoned. Otherwise a paused pool is resumed so that the
+// in-memory queue is still drained.
 func (p *Pool) Stop() {
-	p.logger.Info("Stopping worker pool... Closing job queue.")
-	close(p.JobQueue) // Signal workers to stop by closing the channel.
-	p.wg.Wait()
+	done := p.shutdown()
+	select {
+	case <-done:
+	case <-time.After(p.stopGrace):
+		p.logger.Warn("In-flight jobs did not finish in time, cancelling them", "grace", p.stopGrace)
+		p.cancelJobs()
+		<-done
+	}
+	p.stopWorkers()
 	p.logger.Info("All workers have stopped.")
 }
 
-// worker is the background goroutine that processes jobs from the queue.
-func (p *Pool) worker(id int) {
-	defer p.wg.Done()
-	p.logger.Info("Worker started", "worker_id", id)
+// StopContext stops the pool like Stop, but waits for in-flight jobs only
+// until ctx is done rather than for the stop grace period. It then cancels
+// their contexts and, if they still haven't returned after
+// abandonedJobWait, e.g. because a handler ignores its context, gives up on
+// them. It returns how many jobs were abandoned, whose outcomes may never be
+// recorded, along with ctx's error.
+func (p *Pool) StopContext(ctx context.Context) (int, error) {
+	done := p.shutdown()
+	select {
+	case <-done:
+		p.stopWorkers()
+		p.logger.Info("All workers have stopped.")
+		return 0, nil
+	case <-ctx.Done():
+	}
+	p.logger.Warn("In-flight jobs did not finish by the deadline, cancelling them", "in_flight", p.inFlight.Load())
+	p.cancelJobs()
+	select {
+	case <-done:
+		p.stopWorkers()
+		p.logger.Info("All workers have stopped.")
+		return 0, ctx.Err()
+	case <-time.After(abandonedJobWait):
+	}
+	p.stopWorkers()
+	abandoned := int(p.inFlight.Load())
+	p.logger.Error("In-flight jobs ignored cancellation, abandoning them", "abandoned", abandoned)
+	return abandoned, ctx.Err()
+}
 
-	for job := range p.JobQueue {
-		var event models.WebhookEvent // Corrected type
-		if err := json.Unmarshal(job.Payload, &event); err != nil {
-			p.logger.Error("Worker failed to unmarshal job payload", "worker_id", id, "error", err)
-			continue // Discard unparseable job.
+// shutdown stops retries, spills queued jobs if configured and closes the
+// queue, returning a channel closed once every worker has returned.
+func (p *Pool) shutdown() <-chan struct{} {
+	p.logger.Info("Stopping worker pool... Closing job queue.")
+	p.cancel()      // Abort any retries still waiting to be re-queued.
+	<-p.retriesDone // The retry scheduler must not send on a closed queue.
+	if p.controlsDone != nil {
+		<-p.controlsDone // Shared controls must not pause the pool again.
+	}
+	if p.spillPath != "" && !p.drainOnStop.Load() {
+		p.Pause() // Keep workers from taking the jobs being spilled.
+		p.spillJobs()
+	}
+	p.Resume()
+	p.lanes.Close() // Signal workers to stop by closing the queue.
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		// Jobs that were in flight may have failed and scheduled retries
+		// since, which nothing will deliver now, and workers stopped by
+		// StopContext may have left jobs queued.
+		if p.spillPath != "" {
+			p.spillJobs()
+		} else {
+			p.abandonRetries()
 		}
+		close(done)
+	}()
+	return done
+}
+
+// spillJobs spills the unprocessed jobs, logging the outcome.
+func (p *Pool) spillJobs() {
+	spilled, err := p.spill()
+	if err != nil {
+		p.logger.Error("Failed to spill unprocessed jobs", "path", p.spillPath, "jobs", spilled, "error", err)
+	} else if spilled > 0 {
+		p.logger.Info("Unprocessed jobs spilled for the next startup", "path", p.spillPath, "jobs", spilled)
+	}
+}
+
+// stopWorkers cancels any remaining job contexts and retires every worker.
+func (p *Pool) stopWorkers() {
+	p.cancelJobs()
+	for p.removeWorker() {
+	}
+}
 
-		logger := p.logger.With("worker_id", id, "event_uuid", event.UUID, "attempt", job.Attempts+1)
+// worker is the background goroutine that processes jobs from the queue
+// until it closes or ctx, which retires the worker, is cancelled.
+func (p *Pool) worker(ctx context.Context, id int) {
+	defer p.wg.Done()
+	p.logger.Info("Worker started", "worker_id", id)
 
-		if p.idempotencyStore.Has(event.UUID) {
-			logger.Warn("Duplicate webhook event detected and ignored")
+	for ctx.Err() == nil {
+		p.waitResumed(ctx)
+		job, ack, err := p.queue.Dequeue(ctx)
+		if err != nil {
+			if errors.Is(err, ErrQueueClosed) || ctx.Err() != nil {
+				break
+			}
+			p.logger.Error("Worker failed to dequeue job", "worker_id", id, "error", err)
+			select {
+			case <-time.After(time.Second): // Don't spin while the queue is unavailable.
+			case <-ctx.Done():
+			}
 			continue
 		}
 
-		err := p.processEvent(event)
+		p.inFlight.Add(1)
+		p.handleJob(id, job)
+		if err := ack(); err != nil {
+			p.logger.Error("Worker failed to acknowledge job", "worker_id", id, "error", err)
+		}
+		p.inFlight.Add(-1)
+	}
+	p.logger.Info("Worker stopped", "worker_id", id)
+}
+
+// handleJob claims, processes and records the outcome of a single job.
+func (p *Pool) handleJob(id int, job models.Job) {
+	c, ok := p.claimJob(id, job)
+	if !ok {
+		return
+	}
+	defer c.unlock()
 
-		if err == nil {
-			logger.Info("Event processed successfully")
-			p.idempotencyStore.Set(event.UUID)
+	err := c.err
+	if err == nil {
+		if done, acquireErr := p.acquire(c.ctx, c.event.EventType); acquireErr != nil {
+			err = Transientf("waiting for concurrency slot: %w", acquireErr)
 		} else {
-			var permanentErr *ErrPermanent
-			var transientErr *ErrTransient
+			start := time.Now()
+			err = p.processEvent(c.ctx, c.logger, c.event)
+			c.elapsed = time.Since(start)
+			done()
+		}
+	}
+	p.finishJob(c, err)
+}
+
+// claimedJob is a job whose event a worker has tried to claim.
+type claimedJob struct {
+	job     models.Job
+	event   models.WebhookEvent
+	ctx     context.Context
+	logger  *slog.Logger
+	claim   Record
+	claimed bool
+	unlock  func()        // Releases the claim lock, if any.
+	err     error         // Why the event couldn't be claimed, to be retried.
+	elapsed time.Duration // How long processing took.
+}
 
-			if errors.As(err, &permanentErr) {
-				logger.Error("Event failed with permanent error, will not be retried", "error", err)
-				p.idempotencyStore.Set(event.UUID)
-			} else if errors.As(err, &transientErr) {
+// claimJob decodes a job and claims its event, so that two workers (or
+// replicas) receiving the same UUID can't both process it. It reports false
+// if there is nothing to process: the payload was undecodable or the job too
+// old and it was dead-lettered, or another worker has the event. Otherwise
+// the caller must call unlock once the outcome is recorded, and retry the
+// job if err is set.
+func (p *Pool) claimJob(id int, job models.Job) (*claimedJob, bool) {
+	logger := p.logger.With("worker_id", id)
+	if job.RequestID != "" {
+		logger = logger.With("request_id", job.RequestID)
+	}
+	var event models.WebhookEvent // Corrected type
+	if err := json.Unmarshal(job.Payload, &event); err != nil {
+		logger.Error("Worker failed to unmarshal job payload", "error", err)
+		p.deadLetter(logger, job, event, job.Attempts+1, ReasonSchemaError, fmt.Errorf("%w: %w", ErrSchema, err))
+		return nil, false
+	}
+
+	logger = logger.With("event_uuid", event.UUID, "attempt", job.Attempts+1)
+
+	ctx := WithAttempt(job.Context(), job.Attempts+1)
+	if p.schedule != nil {
+		ctx = context.WithValue(ctx, scheduleKey{}, p.schedule)
+	}
+	ctx = p.withResult(ctx)
+	now := time.Now().UTC()
+	claim := Record{EventType: event.EventType, Status: StatusProcessing, Attempts: job.Attempts + 1, ClaimedAt: now, ProcessedAt: now}
+	// A pending record is a claim abandoned by a crashed process. Count
+	// its attempts too, so the record reflects every try.
+	if prior, found, err := p.idempotencyStore.Get(ctx, event.UUID); err == nil && found && prior.Status == StatusPending {
+		claim.Attempts += prior.Attempts
+	}
+
+	// With a claim lock configured, replicas coordinate even when each has
+	// its own idempotency store.
+	unlock, locked, err := p.tryLock(ctx, logger, event.UUID)
+	if err == nil && !locked {
+		logger.Warn("Event is being processed by another replica, ignoring")
+		return nil, false
+	}
+
+	c := &claimedJob{job: job, event: event, ctx: ctx, logger: logger, claim: claim, unlock: unlock}
+	if err != nil {
+		c.err = Transientf("acquiring claim lock: %w", err)
+	} else if c.claimed, err = p.idempotencyStore.SetIfAbsent(ctx, event.UUID, claim); err != nil {
+		// Without a dedup answer we can't safely process; retry later.
+		c.err = Transientf("claiming idempotency key: %w", err)
+	} else if !c.claimed {
+		logger.Warn("Duplicate webhook event detected and ignored")
+		unlock()
+		return nil, false
+	} else if age := now.Sub(job.ReceivedAt); p.maxJobAge > 0 && !job.ReceivedAt.IsZero() && age > p.maxJobAge {
+		err := fmt.Errorf("%w: received %s ago, max age is %s", ErrStale, age.Round(time.Second), p.maxJobAge)
+		logger.Warn("Event is too old to process, moving to dead-letter queue", "received_at", job.ReceivedAt, "age", age)
+		p.record(ctx, logger, event.UUID, claim, StatusDeadLettered, err)
+		p.deadLetter(logger, job, event, claim.Attempts, ReasonStale, err)
+		unlock()
+		return nil, false
+	}
+	return c, true
+}
+
+// finishJob records the outcome of processing a claimed job, err, and
+// retries or dead-letters it if it failed.
+func (p *Pool) finishJob(c *claimedJob, err error) {
+	ctx, logger, event, job, claim := c.ctx, c.logger, c.event, c.job, c.claim
+	p.saveResult(c, err)
+	p.retryBudget.attempt(time.Now())
+	p.drain.finish(time.Now())
+	retrying := false
+	defer func() {
+		if !retrying {
+			p.forgetFailures(event.UUID)
+		}
+	}()
+	if errors.Is(err, ErrUnhandled) && !IsPermanent(err) {
+		p.unhandled.Observe(p.logger, event.EventType, time.Now().UTC())
+		p.record(ctx, logger, event.UUID, claim, StatusSucceeded, nil)
+	} else if errors.Is(err, ErrSkipped) && !IsPermanent(err) && !IsTransient(err) {
+		logger.Info("Event skipped", "reason", err)
+		p.record(ctx, logger, event.UUID, claim, StatusSkipped, nil)
+	} else if err == nil {
+		logger.Info("Event processed successfully")
+		p.record(ctx, logger, event.UUID, claim, StatusSucceeded, nil)
+	} else {
+		class := errorClass(err)
+		if class == "unknown" {
+			// Don't drop the event: handle it as the policy says.
+			logger.Error("Event failed with an unknown error", "error", err, "policy", p.unknownErrors)
+			err = p.unknownErrors.wrap(err)
+		}
+		jobErrors.WithLabelValues(class).Inc()
+
+		var transientErr *ErrTransient
+		if IsPermanent(err) {
+			logger.Error("Event failed with permanent error, will not be retried", "error", err)
+			p.record(ctx, logger, event.UUID, claim, StatusPermanentFailure, err)
+			p.deadLetter(logger, job, event, claim.Attempts, classify(err), err)
+		} else if errors.As(err, &transientErr) {
+			held := p.retriesHeldNow()
+			if !held {
 				job.Attempts++
-				if job.Attempts < maxRetries {
-					logger.Warn("Event failed with transient error, re-queuing for another attempt", "error", err, "delay", retryDelay)
-					go func(j models.Job) { 
-						time.Sleep(retryDelay)
-						p.JobQueue <- j
-					}(job)
+			}
+			failures, poisoned := p.recordFailure(event.UUID, err, c.elapsed)
+			switch {
+			case poisoned:
+				logger.Error("Job looks like a poison message, quarantining it", "error", err, "failed_attempts", len(failures))
+				p.record(ctx, logger, event.UUID, claim, StatusQuarantined, err)
+				p.quarantineJob(logger, job, event, failures)
+			case job.Attempts >= maxRetries:
+				logger.Error("CRITICAL: Job failed after max retries, moving to dead-letter queue", "error", err)
+				p.record(ctx, logger, event.UUID, claim, StatusDeadLettered, err) // Mark as processed to prevent Gusto retries.
+				p.deadLetter(logger, job, event, claim.Attempts, ReasonRetriesExhausted, err)
+			case !held && !p.retryBudget.allowRetry(time.Now()):
+				logger.Error("Retry budget exhausted, moving to dead-letter queue instead of retrying", "error", err)
+				retryBudgetExhausted.Inc()
+				p.record(ctx, logger, event.UUID, claim, StatusDeadLettered, err)
+				p.deadLetter(logger, job, event, claim.Attempts, ReasonRetryBudget, err)
+			default:
+				delay := p.retryDelay
+				if transientErr.RetryAfter > 0 {
+					delay = min(transientErr.RetryAfter, maxRetryAfter)
+				}
+				if held {
+					// Expected during an outage, and retried once it ends.
+					logger.Info("Event failed with transient error while retries are held, not counting the attempt", "error", err)
 				} else {
-					logger.Error("CRITICAL: Job failed after max retries, moving to dead-letter queue (simulated)", "error", err)
-					p.idempotencyStore.Set(event.UUID) // Mark as processed to prevent Gusto retries.
+					logger.Warn("Event failed with transient error, re-queuing for another attempt", "error", err, "delay", delay)
+				}
+				if c.claimed {
+					p.release(ctx, logger, event)
 				}
-			} else {
-				logger.Error("Event failed with an unknown error", "error", err)
+				p.scheduleRetry(job, delay, logger)
+				p.metrics.JobRetried(event.EventType, class)
+				retrying = true
 			}
 		}
 	}
 }
 
-// GustoAPIErrorResponse defines the structure of a Gusto API error.
-type GustoAPIErrorResponse struct {
-	Errors []struct {
-		Category string `json:"category"`
-		Message  string `json:"message"`
-	} `json:"errors"`
+// callHandler runs the event's handler. A panic is logged with its stack
+// and returned as a transient error, so that the event is retried and the
+// worker survives.
+func (p *Pool) callHandler(ctx context.Context, logger *slog.Logger, event models.WebhookEvent) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			handlerPanics.WithLabelValues(event.EventType).Inc()
+			logger.Error("Event handler panicked", "event_type", event.EventType,
+				"panic", r, "stack", string(debug.Stack()))
+			err = Transientf("%w: %v", ErrHandlerPanic, r)
+		}
+	}()
+	return p.handler()(ctx, event)
 }
 
-// processEvent makes a real API call back to Gusto and handles the response.
-func (p *Pool) processEvent(event models.WebhookEvent) error {
-	p.logger.Info("Worker processing event", "event_uuid", event.UUID, "event_type", event.EventType)
+// record stores the final outcome for an event in the idempotency store,
+// building on the claim written when the attempt started.
+func (p *Pool) record(ctx context.Context, logger *slog.Logger, key string, claim Record, status Status, cause error) {
+	rec := claim
+	rec.Status = status
+	rec.ProcessedAt = time.Now().UTC()
+	if cause != nil {
+		rec.LastError = cause.Error()
+	}
+	if err := p.idempotencyStore.Set(ctx, key, rec); err != nil {
+		logger.Error("Failed to record event outcome in idempotency store", "status", status, "error", err)
+	}
+}
 
-	// We'll use the 'company.updated' event to trigger a real API call.
-	if strings.Contains(event.EventType, "company.updated") {
-		// 1. Get the company-specific access token.
-		accessToken := "supply-access-token-here"
+// release drops the worker's claim on an event so a later attempt can process it.
+func (p *Pool) release(ctx context.Context, logger *slog.Logger, event models.WebhookEvent) {
+	if err := p.idempotencyStore.Delete(ctx, event.UUID); err != nil {
+		logger.Error("Failed to release idempotency claim", "error", err)
+	}
+}
 
-		// 2. Make an API call to get company details.
-		companyURL := fmt.Sprintf("https://api.gusto-demo.com/v1/companies/%s", event.ResourceUUID)
-		req, _ := http.NewRequest("GET", companyURL, nil)
-		req.Header.Set("Authorization", "Bearer "+accessToken)
+// processEvent hands the event to the provider-specific processor. Its
+// context ends at the job timeout, or when Stop gives up waiting for it.
+func (p *Pool) processEvent(ctx context.Context, logger *slog.Logger, event models.WebhookEvent) error {
+	logger.Info("Worker processing event", "event_type", event.EventType)
 
-		client := &http.Client{Timeout: 15 * time.Second}
-		resp, err := client.Do(req)
-		if err != nil {
-			// A client-side error (e.g., DNS, timeout) is a transient failure.
-			return &ErrTransient{Err: fmt.Errorf("http client error: %w", err)}
-		}
-		defer resp.Body.Close()
-
-		// 3. Handle the API response.
-		if resp.StatusCode >= 400 {
-			// This is an API error from Gusto. Parse the error response.
-			bodyBytes, _ := io.ReadAll(resp.Body)
-			var gustoError GustoAPIErrorResponse
-			if err := json.Unmarshal(bodyBytes, &gustoError); err != nil {
-				// If we can't parse the error, treat it as transient.
-				return &ErrTransient{Err: fmt.Errorf("failed to parse Gusto error response: %w", err)}
-			}
+	var cancel context.CancelFunc
+	if p.jobTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, p.jobTimeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+	stop := context.AfterFunc(p.jobsCtx, cancel)
+	defer stop()
 
-			if len(gustoError.Errors) > 0 {
-				errorCategory := gustoError.Errors[0].Category
-				apiErr := fmt.Errorf("Gusto API error: %s", gustoError.Errors[0].Message)
-
-				// Use the 'category' from the JSON error to classify the failure.
-				switch errorCategory {
-				case "server_error", "rate_limit_error", "system_error":
-					return &ErrTransient{Err: apiErr}
-				default:
-					// Treat all others (validation, auth, etc.) as permanent.
-					return &ErrPermanent{Err: apiErr}
-				}
-			}
-		}
+	start := time.Now()
+	err := p.callHandler(ctx, logger, event)
+	elapsed := time.Since(start)
+	p.observeLatency(elapsed)
+	if err != nil && ctx.Err() != nil && !IsPermanent(err) && !IsTransient(err) {
+		// An interrupted attempt may well succeed next time.
+		err = Transientf("processing interrupted: %w", err)
+	}
+	p.metrics.JobProcessed(event.EventType, errorClass(err), elapsed)
+	return err
+}
 
-		// If status code is 2xx, the API call was successful.
-		p.logger.Info("Successfully fetched company details after webhook event.")
+// errorClass returns "success" for a nil error, "skipped" for ErrSkipped,
+// or the class of a processing error: "transient", "permanent" or
+// "unknown".
+func errorClass(err error) string {
+	switch {
+	case err == nil:
+		return "success"
+	case errors.Is(err, ErrSkipped) && !IsPermanent(err) && !IsTransient(err):
+		return "skipped"
+	case IsPermanent(err):
+		return "permanent"
+	case IsTransient(err):
+		return "transient"
+	default:
+		return "unknown"
 	}
+}
+
+// sampleQueue reports the pool's backlog to its Metrics every
+// queueSampleInterval until the pool stops.
+func (p *Pool) sampleQueue() {
+	ticker := time.NewTicker(queueSampleInterval)
+	defer ticker.Stop()
+	for {
+		stats := QueueStats{Queued: -1, DeadLetters: p.deadLetters.Len()}
+		if q, ok := p.queue.(depther); ok {
+			stats.Queued, _ = q.Depth()
+		}
+		p.retriesMu.Lock()
+		stats.Retrying = len(p.retries)
+		p.retriesMu.Unlock()
+		p.metrics.QueueSampled(stats)
 
-	// For all other event types, we do nothing.
-	return nil
-}
\ No newline at end of file
+		select {
+		case <-ticker.C:
+		case <-p.ctx.Done():
+			return
+		}
+	}
+}
@@ -1,20 +1,35 @@
 package worker
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"gusto-webhook-guide/internal/models"
 	"io"
 	"log/slog"
 	"testing"
+	"time"
 )
 
+// stubProcessor fails company.updated events transiently and company.deleted
+// events permanently, and succeeds for everything else.
+var stubProcessor = ProcessorFunc(func(ctx context.Context, event models.WebhookEvent) error {
+	switch event.EventType {
+	case "company.updated":
+		return &ErrTransient{Err: errors.New("gusto unavailable")}
+	case "company.deleted":
+		return &ErrPermanent{Err: errors.New("validation failed")}
+	}
+	return nil
+})
+
 func TestWorkerLogic(t *testing.T) {
 	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
 
 	testCases := []struct {
 		name                   string
 		initialStoreState      map[string]bool
-		jobPayload             models.WebhookEvent 
+		jobPayload             models.WebhookEvent
 		expectedFinalStoreKeys []string
 	}{
 		{
@@ -61,10 +76,10 @@ func TestWorkerLogic(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			idempotencyStore := NewIdempotencyStore()
 			for key := range tc.initialStoreState {
-				idempotencyStore.Set(key)
+				idempotencyStore.Set(context.Background(), key, Record{Status: StatusSucceeded})
 			}
 
-			pool := NewPool(1, 1, logger, idempotencyStore)
+			pool := NewPool(1, 1, logger, idempotencyStore, stubProcessor)
 			payloadBytes, _ := json.Marshal(tc.jobPayload)
 			job := models.Job{Payload: payloadBytes, Attempts: 0}
 
@@ -90,7 +105,7 @@ func TestWorkerLogic(t *testing.T) {
 
 	t.Run("Failure - Unparseable JSON", func(t *testing.T) {
 		idempotencyStore := NewIdempotencyStore()
-		pool := NewPool(1, 1, logger, idempotencyStore)
+		pool := NewPool(1, 1, logger, idempotencyStore, stubProcessor)
 		job := models.Job{Payload: []byte(`{"invalid-json`), Attempts: 0}
 
 		pool.Start(1)
@@ -102,4 +117,315 @@ func TestWorkerLogic(t *testing.T) {
 			t.Errorf("store should be empty after unparseable JSON, but has %d keys", len(idempotencyStore.store))
 		}
 	})
-}
\ No newline at end of file
+}
+
+func TestWorkerRecordsOutcome(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	idempotencyStore := NewIdempotencyStore()
+	attempt := 0
+	processor := ProcessorFunc(func(ctx context.Context, event models.WebhookEvent) error {
+		attempt = Attempt(ctx)
+		return stubProcessor(ctx, event)
+	})
+	pool := NewPool(1, 1, logger, idempotencyStore, processor)
+
+	payloadBytes, _ := json.Marshal(models.WebhookEvent{UUID: "outcome-uuid", EventType: "company.created"})
+	pool.Start(1)
+	pool.JobQueue <- models.Job{Payload: payloadBytes, Attempts: 2}
+	close(pool.JobQueue)
+	pool.wg.Wait()
+
+	rec, found, err := idempotencyStore.Get(context.Background(), "outcome-uuid")
+	if err != nil || !found {
+		t.Fatalf("expected a record for the processed event, got found=%v err=%v", found, err)
+	}
+	if rec.Status != StatusSucceeded {
+		t.Errorf("incorrect status: got %q want %q", rec.Status, StatusSucceeded)
+	}
+	if rec.EventType != "company.created" {
+		t.Errorf("incorrect event type: got %q want %q", rec.EventType, "company.created")
+	}
+	if rec.Attempts != 3 {
+		t.Errorf("incorrect attempts: got %d want 3", rec.Attempts)
+	}
+	if attempt != 3 {
+		t.Errorf("incorrect attempt in processor context: got %d want 3", attempt)
+	}
+	if rec.ClaimedAt.IsZero() || rec.ProcessedAt.Before(rec.ClaimedAt) {
+		t.Errorf("invalid timestamps: claimed %v, processed %v", rec.ClaimedAt, rec.ProcessedAt)
+	}
+}
+
+func TestWorkerClaimsPendingEvent(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	idempotencyStore := NewIdempotencyStore()
+	pool := NewPool(1, 1, logger, idempotencyStore, stubProcessor)
+
+	// A claim abandoned by a crash and reset at startup doesn't block the
+	// redelivery, and its attempt still counts.
+	idempotencyStore.Set(context.Background(), "pending-uuid", Record{EventType: "company.created", Status: StatusPending, Attempts: 1})
+
+	payloadBytes, _ := json.Marshal(models.WebhookEvent{UUID: "pending-uuid", EventType: "company.created"})
+	pool.Start(1)
+	pool.JobQueue <- models.Job{Payload: payloadBytes}
+	close(pool.JobQueue)
+	pool.wg.Wait()
+
+	rec, _, _ := idempotencyStore.Get(context.Background(), "pending-uuid")
+	if rec.Status != StatusSucceeded {
+		t.Errorf("incorrect status: got %q want %q", rec.Status, StatusSucceeded)
+	}
+	if rec.Attempts != 2 {
+		t.Errorf("incorrect attempts: got %d want 2", rec.Attempts)
+	}
+}
+
+func TestWorkerHonorsRetryAfter(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+
+	testCases := []struct {
+		name          string
+		retryDelay    time.Duration
+		retryAfter    time.Duration
+		expectedDelay time.Duration
+	}{
+		{name: "Default Delay", retryAfter: 0, expectedDelay: defaultRetryDelay},
+		{name: "Configured Delay", retryDelay: time.Second, retryAfter: 0, expectedDelay: time.Second},
+		{name: "Provider Delay", retryAfter: 2 * time.Minute, expectedDelay: 2 * time.Minute},
+		{name: "Capped Provider Delay", retryAfter: 24 * time.Hour, expectedDelay: maxRetryAfter},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			processor := ProcessorFunc(func(ctx context.Context, event models.WebhookEvent) error {
+				return &ErrTransient{Err: errors.New("rate limited"), RetryAfter: tc.retryAfter}
+			})
+			pool := NewPool(1, 1, logger, NewIdempotencyStore(), processor)
+			defer pool.Stop()
+			if tc.retryDelay > 0 {
+				pool.SetRetryDelay(tc.retryDelay)
+			}
+
+			payloadBytes, _ := json.Marshal(models.WebhookEvent{UUID: "rate-limited-uuid", EventType: "company.updated"})
+			start := time.Now()
+			pool.handleJob(1, models.Job{Payload: payloadBytes})
+
+			pool.retriesMu.Lock()
+			defer pool.retriesMu.Unlock()
+			if len(pool.retries) != 1 {
+				t.Fatalf("incorrect number of scheduled retries: got %d want 1", len(pool.retries))
+			}
+			delay := pool.retries[0].dueAt.Sub(start)
+			if delay < tc.expectedDelay || delay > tc.expectedDelay+time.Second {
+				t.Errorf("incorrect retry delay: got %v want %v", delay, tc.expectedDelay)
+			}
+		})
+	}
+}
+
+func TestWorkerUnknownErrorPolicy(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	processor := ProcessorFunc(func(ctx context.Context, event models.WebhookEvent) error {
+		return errors.New("unexpected failure")
+	})
+
+	testCases := []struct {
+		name                string
+		policy              UnknownErrorPolicy
+		expectedRetries     int
+		expectedDeadLetters int
+	}{
+		{name: "Retry", policy: RetryUnknown, expectedRetries: 1},
+		{name: "Dead Letter", policy: DeadLetterUnknown, expectedDeadLetters: 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			pool := NewPool(1, 1, logger, NewIdempotencyStore(), processor)
+			defer pool.Stop()
+			pool.SetUnknownErrorPolicy(tc.policy)
+
+			payloadBytes, _ := json.Marshal(models.WebhookEvent{UUID: "unknown-error-uuid", EventType: "company.created"})
+			pool.handleJob(1, models.Job{Payload: payloadBytes})
+
+			pool.retriesMu.Lock()
+			retries := len(pool.retries)
+			pool.retriesMu.Unlock()
+			if retries != tc.expectedRetries {
+				t.Errorf("incorrect number of scheduled retries: got %d want %d", retries, tc.expectedRetries)
+			}
+			deadLetters := pool.DeadLetters().List(DeadLetterFilter{})
+			if len(deadLetters) != tc.expectedDeadLetters {
+				t.Fatalf("incorrect number of dead letters: got %d want %d", len(deadLetters), tc.expectedDeadLetters)
+			}
+			if len(deadLetters) > 0 && deadLetters[0].Reason != ReasonHandlerBug {
+				t.Errorf("incorrect reason: got %q want %q", deadLetters[0].Reason, ReasonHandlerBug)
+			}
+		})
+	}
+}
+
+func TestParseUnknownErrorPolicy(t *testing.T) {
+	testCases := []struct {
+		input       string
+		expected    UnknownErrorPolicy
+		expectError bool
+	}{
+		{input: "", expected: RetryUnknown},
+		{input: "retry", expected: RetryUnknown},
+		{input: "dead_letter", expected: DeadLetterUnknown},
+		{input: "drop", expectError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			got, err := ParseUnknownErrorPolicy(tc.input)
+			if (err != nil) != tc.expectError {
+				t.Fatalf("unexpected error result: got %v want error %v", err, tc.expectError)
+			}
+			if got != tc.expected {
+				t.Errorf("incorrect policy: got %q want %q", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestWorkerJobTimeout(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	processor := ProcessorFunc(func(ctx context.Context, event models.WebhookEvent) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	pool := NewPool(1, 1, logger, NewIdempotencyStore(), processor)
+	defer pool.Stop()
+	pool.SetJobTimeout(10 * time.Millisecond)
+
+	payloadBytes, _ := json.Marshal(models.WebhookEvent{UUID: "slow-uuid", EventType: "company.created"})
+	pool.handleJob(1, models.Job{Payload: payloadBytes})
+
+	// The timed-out attempt is retried rather than dropped.
+	pool.retriesMu.Lock()
+	defer pool.retriesMu.Unlock()
+	if len(pool.retries) != 1 {
+		t.Fatalf("incorrect number of scheduled retries: got %d want 1", len(pool.retries))
+	}
+}
+
+func TestWorkerSurvivesHandlerPanic(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	processed := make(chan string, 1)
+	processor := ProcessorFunc(func(ctx context.Context, event models.WebhookEvent) error {
+		if event.UUID == "panicking-uuid" {
+			var m map[string]int
+			m["boom"]++ // Assignment to a nil map.
+		}
+		processed <- event.UUID
+		return nil
+	})
+	pool := NewPool(2, 1, logger, NewIdempotencyStore(), processor)
+	defer pool.Stop()
+	pool.Start(1)
+
+	for _, uuid := range []string{"panicking-uuid", "next-uuid"} {
+		payloadBytes, _ := json.Marshal(models.WebhookEvent{UUID: uuid, EventType: "company.created"})
+		pool.JobQueue <- models.Job{Payload: payloadBytes}
+	}
+	select {
+	case uuid := <-processed:
+		if uuid != "next-uuid" {
+			t.Errorf("incorrect event processed: got %q", uuid)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the worker stopped processing after a panic")
+	}
+	if workers := pool.Workers(); workers != 1 {
+		t.Errorf("incorrect worker count: got %d want 1", workers)
+	}
+
+	// The panicking attempt is retried rather than dropped.
+	pool.retriesMu.Lock()
+	defer pool.retriesMu.Unlock()
+	if len(pool.retries) != 1 || pool.retries[0].job.Attempts != 1 {
+		t.Fatalf("incorrect scheduled retries: got %d want 1", len(pool.retries))
+	}
+}
+
+func TestStopCancelsInFlightJobs(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	started := make(chan struct{})
+	processor := ProcessorFunc(func(ctx context.Context, event models.WebhookEvent) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	store := NewIdempotencyStore()
+	pool := NewPool(1, 1, logger, store, processor)
+	pool.SetStopGrace(10 * time.Millisecond)
+
+	payloadBytes, _ := json.Marshal(models.WebhookEvent{UUID: "in-flight-uuid", EventType: "company.created"})
+	pool.Start(1)
+	pool.JobQueue <- models.Job{Payload: payloadBytes}
+	<-started
+
+	stopped := make(chan struct{})
+	go func() {
+		pool.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop blocked on an in-flight job")
+	}
+
+	// The claim is released so the provider's redelivery is processed.
+	if _, found, _ := store.Get(context.Background(), "in-flight-uuid"); found {
+		t.Errorf("expected the cancelled job's claim to be released")
+	}
+}
+
+func TestStopContext(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+
+	testCases := []struct {
+		name              string
+		honorsCancel      bool
+		expectedAbandoned int
+	}{
+		{name: "Cancelled Job Returns", honorsCancel: true, expectedAbandoned: 0},
+		{name: "Job Ignores Cancellation", honorsCancel: false, expectedAbandoned: 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			started := make(chan struct{})
+			release := make(chan struct{})
+			defer close(release)
+			processor := ProcessorFunc(func(ctx context.Context, event models.WebhookEvent) error {
+				close(started)
+				if tc.honorsCancel {
+					<-ctx.Done()
+					return ctx.Err()
+				}
+				<-release
+				return nil
+			})
+			pool := NewPool(1, 1, logger, NewIdempotencyStore(), processor)
+
+			payloadBytes, _ := json.Marshal(models.WebhookEvent{UUID: "in-flight-uuid", EventType: "company.created"})
+			pool.Start(1)
+			pool.JobQueue <- models.Job{Payload: payloadBytes}
+			<-started
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+			abandoned, err := pool.StopContext(ctx)
+			if abandoned != tc.expectedAbandoned {
+				t.Errorf("incorrect number of abandoned jobs: got %d want %d", abandoned, tc.expectedAbandoned)
+			}
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("incorrect error: got %v want %v", err, context.DeadlineExceeded)
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package worker
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"gusto-webhook-guide/internal/models"
+	"strconv"
+	"time"
+)
+
+// postgresQueueSchema creates the tables used by PostgresQueue. A job is
+// available when locked_until is unset or has passed; webhook_retry_timers
+// holds the retries waiting for their delay.
+const postgresQueueSchema = `
+CREATE TABLE IF NOT EXISTS webhook_job_queue (
+	id           BIGSERIAL PRIMARY KEY,
+	payload      BYTEA NOT NULL,
+	attempts     INTEGER NOT NULL DEFAULT 0,
+	enqueued_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+	received_at  TIMESTAMPTZ,
+	request_id   TEXT NOT NULL DEFAULT '',
+	locked_until TIMESTAMPTZ
+);
+ALTER TABLE webhook_job_queue ADD COLUMN IF NOT EXISTS received_at TIMESTAMPTZ;
+ALTER TABLE webhook_job_queue ADD COLUMN IF NOT EXISTS request_id TEXT NOT NULL DEFAULT '';
+CREATE TABLE IF NOT EXISTS webhook_retry_timers (
+	id          BIGSERIAL PRIMARY KEY,
+	payload     BYTEA NOT NULL,
+	attempts    INTEGER NOT NULL DEFAULT 0,
+	received_at TIMESTAMPTZ,
+	request_id  TEXT NOT NULL DEFAULT '',
+	due_at      TIMESTAMPTZ NOT NULL
+);
+`
+
+// PostgresQueue is a durable JobQueue backed by a Postgres table. Dequeued
+// jobs are leased rather than removed: a job whose worker crashes becomes
+// available again once its lease expires, and the idempotency store keeps
+// it from being processed twice. Several replicas can share one queue.
+type PostgresQueue struct {
+	db           *sql.DB
+	lease        time.Duration
+	pollInterval time.Duration // How often an empty queue is polled.
+}
+
+var (
+	_ JobQueue   = (*PostgresQueue)(nil)
+	_ RetryStore = (*PostgresQueue)(nil)
+)
+
+// NewPostgresQueue creates a PostgresQueue using an open database handle.
+// lease must exceed the longest time a job takes to process.
+func NewPostgresQueue(db *sql.DB, lease time.Duration) *PostgresQueue {
+	return &PostgresQueue{db: db, lease: lease, pollInterval: 500 * time.Millisecond}
+}
+
+// Migrate creates the backing table if it does not already exist.
+func (q *PostgresQueue) Migrate(ctx context.Context) error {
+	if _, err := q.db.ExecContext(ctx, postgresQueueSchema); err != nil {
+		return fmt.Errorf("creating job queue table: %w", err)
+	}
+	return nil
+}
+
+// Enqueue implements JobQueue. The queue is unbounded, so wait is unused.
+func (q *PostgresQueue) Enqueue(ctx context.Context, job models.Job, _ time.Duration) error {
+	if _, err := q.db.ExecContext(ctx,
+		`INSERT INTO webhook_job_queue (payload, attempts, received_at, request_id) VALUES ($1, $2, $3, $4)`,
+		job.Payload, job.Attempts, sql.NullTime{Time: job.ReceivedAt, Valid: !job.ReceivedAt.IsZero()}, job.RequestID,
+	); err != nil {
+		return fmt.Errorf("enqueuing job: %w", err)
+	}
+	return nil
+}
+
+// Dequeue implements JobQueue, polling until a job is available or ctx is
+// done. Concurrent callers never receive the same job while it is leased.
+func (q *PostgresQueue) Dequeue(ctx context.Context) (models.Job, func() error, error) {
+	for {
+		job, ack, err := q.tryDequeue(ctx)
+		if err == nil || !errors.Is(err, sql.ErrNoRows) {
+			return job, ack, err
+		}
+		select {
+		case <-time.After(q.pollInterval):
+		case <-ctx.Done():
+			return models.Job{}, nil, ctx.Err()
+		}
+	}
+}
+
+// tryDequeue leases the oldest available job, returning sql.ErrNoRows if
+// there is none.
+func (q *PostgresQueue) tryDequeue(ctx context.Context) (models.Job, func() error, error) {
+	now := time.Now().UTC()
+	var id int64
+	var job models.Job
+	var receivedAt sql.NullTime
+	err := q.db.QueryRowContext(ctx, `
+		UPDATE webhook_job_queue SET locked_until = $1
+		WHERE id = (
+			SELECT id FROM webhook_job_queue
+			WHERE locked_until IS NULL OR locked_until <= $2
+			ORDER BY id
+			FOR UPDATE SKIP LOCKED
+			LIMIT 1
+		)
+		RETURNING id, payload, attempts, received_at, request_id`,
+		now.Add(q.lease), now,
+	).Scan(&id, &job.Payload, &job.Attempts, &receivedAt, &job.RequestID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Job{}, nil, err
+	}
+	if err != nil {
+		return models.Job{}, nil, fmt.Errorf("dequeuing job: %w", err)
+	}
+	if receivedAt.Valid {
+		job.ReceivedAt = receivedAt.Time.UTC()
+	}
+
+	ack := func() error {
+		if _, err := q.db.ExecContext(context.WithoutCancel(ctx), `DELETE FROM webhook_job_queue WHERE id = $1`, id); err != nil {
+			return fmt.Errorf("acknowledging job: %w", err)
+		}
+		return nil
+	}
+	return job, ack, nil
+}
+
+// SaveRetry implements RetryStore.
+func (q *PostgresQueue) SaveRetry(ctx context.Context, job models.Job, dueAt time.Time) (string, error) {
+	var id int64
+	err := q.db.QueryRowContext(ctx,
+		`INSERT INTO webhook_retry_timers (payload, attempts, received_at, request_id, due_at) VALUES ($1, $2, $3, $4, $5) RETURNING id`,
+		job.Payload, job.Attempts, sql.NullTime{Time: job.ReceivedAt, Valid: !job.ReceivedAt.IsZero()}, job.RequestID, dueAt,
+	).Scan(&id)
+	if err != nil {
+		return "", fmt.Errorf("saving retry: %w", err)
+	}
+	return strconv.FormatInt(id, 10), nil
+}
+
+// DeleteRetry implements RetryStore.
+func (q *PostgresQueue) DeleteRetry(ctx context.Context, id string) error {
+	if _, err := q.db.ExecContext(ctx, `DELETE FROM webhook_retry_timers WHERE id = $1`, id); err != nil {
+		return fmt.Errorf("deleting retry: %w", err)
+	}
+	return nil
+}
+
+// Retries implements RetryStore.
+func (q *PostgresQueue) Retries(ctx context.Context) ([]StoredRetry, error) {
+	rows, err := q.db.QueryContext(ctx, `
+		SELECT id, payload, attempts, received_at, request_id, due_at
+		FROM webhook_retry_timers ORDER BY due_at, id`)
+	if err != nil {
+		return nil, fmt.Errorf("reading retries: %w", err)
+	}
+	defer rows.Close()
+
+	var retries []StoredRetry
+	for rows.Next() {
+		var r StoredRetry
+		var id int64
+		var receivedAt sql.NullTime
+		if err := rows.Scan(&id, &r.Job.Payload, &r.Job.Attempts, &receivedAt, &r.Job.RequestID, &r.DueAt); err != nil {
+			return nil, fmt.Errorf("reading retries: %w", err)
+		}
+		r.ID = strconv.FormatInt(id, 10)
+		if receivedAt.Valid {
+			r.Job.ReceivedAt = receivedAt.Time.UTC()
+		}
+		r.DueAt = r.DueAt.UTC()
+		retries = append(retries, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading retries: %w", err)
+	}
+	return retries, nil
+}
@@ -0,0 +1,97 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"gusto-webhook-guide/internal/models"
+	"testing"
+	"time"
+)
+
+func TestPostgresQueue(t *testing.T) {
+	db := openTestPostgres(t)
+	ctx := context.Background()
+	if _, err := db.Exec(`DROP TABLE IF EXISTS webhook_job_queue`); err != nil {
+		t.Fatalf("failed to reset table: %v", err)
+	}
+
+	q := NewPostgresQueue(db, 50*time.Millisecond)
+	q.pollInterval = 10 * time.Millisecond
+	if err := q.Migrate(ctx); err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+	for _, payload := range []string{"a", "b"} {
+		if err := q.Enqueue(ctx, models.Job{Payload: []byte(payload), Attempts: 1}, 0); err != nil {
+			t.Fatalf("Enqueue failed: %v", err)
+		}
+	}
+
+	// Jobs come out oldest first, and a leased job isn't handed out twice.
+	first, ackFirst, err := q.Dequeue(ctx)
+	if err != nil || string(first.Payload) != "a" || first.Attempts != 1 {
+		t.Fatalf("incorrect first job: got %+v (err %v)", first, err)
+	}
+	second, _, err := q.Dequeue(ctx)
+	if err != nil || string(second.Payload) != "b" {
+		t.Fatalf("incorrect second job: got %+v (err %v)", second, err)
+	}
+	if err := ackFirst(); err != nil {
+		t.Fatalf("ack failed: %v", err)
+	}
+
+	// The unacknowledged job is handed out again once its lease expires.
+	again, _, err := q.Dequeue(ctx)
+	if err != nil || string(again.Payload) != "b" {
+		t.Fatalf("incorrect job after lease expiry: got %+v (err %v)", again, err)
+	}
+
+	// An empty queue blocks until ctx is done.
+	time.Sleep(60 * time.Millisecond)
+	_, ack, _ := q.Dequeue(ctx)
+	ack()
+	timeout, cancel := context.WithTimeout(ctx, 30*time.Millisecond)
+	defer cancel()
+	if _, _, err := q.Dequeue(timeout); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("incorrect error for an empty queue: got %v want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestPostgresQueueRetries(t *testing.T) {
+	db := openTestPostgres(t)
+	ctx := context.Background()
+	if _, err := db.Exec(`DROP TABLE IF EXISTS webhook_retry_timers`); err != nil {
+		t.Fatalf("failed to reset table: %v", err)
+	}
+	q := NewPostgresQueue(db, time.Minute)
+	if err := q.Migrate(ctx); err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	later, err := q.SaveRetry(ctx, models.Job{Payload: []byte("later"), Attempts: 2, RequestID: "req-1"}, now.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("SaveRetry failed: %v", err)
+	}
+	if _, err := q.SaveRetry(ctx, models.Job{Payload: []byte("sooner"), ReceivedAt: now}, now.Add(time.Minute)); err != nil {
+		t.Fatalf("SaveRetry failed: %v", err)
+	}
+
+	// Retries come back earliest first.
+	retries, err := q.Retries(ctx)
+	if err != nil || len(retries) != 2 {
+		t.Fatalf("incorrect retries: got %+v (err %v)", retries, err)
+	}
+	if string(retries[0].Job.Payload) != "sooner" || !retries[0].Job.ReceivedAt.Equal(now) || !retries[0].DueAt.Equal(now.Add(time.Minute)) {
+		t.Errorf("incorrect first retry: got %+v", retries[0])
+	}
+	if retries[1].ID != later || retries[1].Job.Attempts != 2 || retries[1].Job.RequestID != "req-1" {
+		t.Errorf("incorrect second retry: got %+v", retries[1])
+	}
+
+	if err := q.DeleteRetry(ctx, later); err != nil {
+		t.Fatalf("DeleteRetry failed: %v", err)
+	}
+	if retries, _ := q.Retries(ctx); len(retries) != 1 {
+		t.Errorf("incorrect retries after delete: got %d want 1", len(retries))
+	}
+}
@@ -0,0 +1,74 @@
+package worker
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// postgresResultsSchema creates the table used by PostgresResultStore.
+const postgresResultsSchema = `
+CREATE TABLE IF NOT EXISTS webhook_event_results (
+	event_uuid  TEXT PRIMARY KEY,
+	result      JSONB NOT NULL,
+	recorded_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS webhook_event_results_recorded_at_idx ON webhook_event_results (recorded_at);
+`
+
+// PostgresResultStore is a ResultStore backed by a Postgres table, shared
+// by every replica.
+type PostgresResultStore struct {
+	db *sql.DB
+}
+
+var _ ResultStore = (*PostgresResultStore)(nil)
+
+// NewPostgresResultStore creates a PostgresResultStore using an open
+// database handle.
+func NewPostgresResultStore(db *sql.DB) *PostgresResultStore {
+	return &PostgresResultStore{db: db}
+}
+
+// Migrate creates the backing table if it does not already exist.
+func (s *PostgresResultStore) Migrate(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, postgresResultsSchema); err != nil {
+		return fmt.Errorf("creating event results table: %w", err)
+	}
+	return nil
+}
+
+// PutResult implements ResultStore.
+func (s *PostgresResultStore) PutResult(ctx context.Context, eventUUID string, r Result) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("encoding event result: %w", err)
+	}
+	if _, err := s.db.ExecContext(ctx, `
+		INSERT INTO webhook_event_results (event_uuid, result, recorded_at) VALUES ($1, $2, $3)
+		ON CONFLICT (event_uuid) DO UPDATE SET result = EXCLUDED.result, recorded_at = EXCLUDED.recorded_at`,
+		eventUUID, data, r.RecordedAt,
+	); err != nil {
+		return fmt.Errorf("storing event result: %w", err)
+	}
+	return nil
+}
+
+// GetResult implements ResultStore.
+func (s *PostgresResultStore) GetResult(ctx context.Context, eventUUID string) (Result, bool, error) {
+	var data []byte
+	err := s.db.QueryRowContext(ctx, `SELECT result FROM webhook_event_results WHERE event_uuid = $1`, eventUUID).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Result{}, false, nil
+	}
+	if err != nil {
+		return Result{}, false, fmt.Errorf("reading event result: %w", err)
+	}
+	var r Result
+	if err := json.Unmarshal(data, &r); err != nil {
+		return Result{}, false, fmt.Errorf("decoding event result: %w", err)
+	}
+	return r, true, nil
+}
@@ -0,0 +1,49 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestPostgresResultStore(t *testing.T) {
+	db := openTestPostgres(t)
+	ctx := context.Background()
+	if _, err := db.Exec(`DROP TABLE IF EXISTS webhook_event_results`); err != nil {
+		t.Fatalf("failed to reset table: %v", err)
+	}
+
+	s := NewPostgresResultStore(db)
+	if err := s.Migrate(ctx); err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+	// Migrate must be safe to run on every startup.
+	if err := s.Migrate(ctx); err != nil {
+		t.Fatalf("second Migrate failed: %v", err)
+	}
+
+	if _, found, err := s.GetResult(ctx, "e1"); err != nil || found {
+		t.Fatalf("GetResult of unknown event = %v, %v, want not found", found, err)
+	}
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	for _, attempt := range []int{1, 2} {
+		r := Result{
+			EventType:  "employee.created",
+			Attempt:    attempt,
+			Artifacts:  []Artifact{{Kind: "employee", ID: "emp-1", Action: "created"}},
+			Data:       map[string]json.RawMessage{"payroll_id": json.RawMessage(`"p-1"`)},
+			RecordedAt: now,
+		}
+		if err := s.PutResult(ctx, "e1", r); err != nil {
+			t.Fatalf("PutResult failed: %v", err)
+		}
+	}
+	got, found, err := s.GetResult(ctx, "e1")
+	if err != nil || !found {
+		t.Fatalf("GetResult failed: found %v, err %v", found, err)
+	}
+	if got.Attempt != 2 || len(got.Artifacts) != 1 || got.Artifacts[0].ID != "emp-1" || string(got.Data["payroll_id"]) != `"p-1"` {
+		t.Errorf("incorrect result: %+v", got)
+	}
+}
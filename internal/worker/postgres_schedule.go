@@ -0,0 +1,87 @@
+package worker
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// postgresScheduleSchema creates the table used by PostgresSchedule.
+const postgresScheduleSchema = `
+CREATE TABLE IF NOT EXISTS webhook_scheduled_jobs (
+	id      TEXT PRIMARY KEY,
+	due_at  TIMESTAMPTZ NOT NULL,
+	payload BYTEA NOT NULL
+);
+CREATE INDEX IF NOT EXISTS webhook_scheduled_jobs_due_at_idx ON webhook_scheduled_jobs (due_at);
+`
+
+// PostgresSchedule is a Schedule backed by a Postgres table, shared by
+// every replica. Replicas may enqueue the same due job; the idempotency
+// store drops the duplicate.
+type PostgresSchedule struct {
+	db *sql.DB
+}
+
+var _ Schedule = (*PostgresSchedule)(nil)
+
+// NewPostgresSchedule creates a PostgresSchedule using an open database handle.
+func NewPostgresSchedule(db *sql.DB) *PostgresSchedule {
+	return &PostgresSchedule{db: db}
+}
+
+// Migrate creates the backing table if it does not already exist.
+func (s *PostgresSchedule) Migrate(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, postgresScheduleSchema); err != nil {
+		return fmt.Errorf("creating schedule table: %w", err)
+	}
+	return nil
+}
+
+// Add implements Schedule.
+func (s *PostgresSchedule) Add(ctx context.Context, job ScheduledJob) error {
+	if _, err := s.db.ExecContext(ctx, `
+		INSERT INTO webhook_scheduled_jobs (id, due_at, payload) VALUES ($1, $2, $3)
+		ON CONFLICT (id) DO UPDATE SET due_at = EXCLUDED.due_at, payload = EXCLUDED.payload`,
+		job.ID, job.DueAt, job.Payload,
+	); err != nil {
+		return fmt.Errorf("adding scheduled job: %w", err)
+	}
+	return nil
+}
+
+// Due implements Schedule.
+func (s *PostgresSchedule) Due(ctx context.Context, now time.Time, limit int) ([]ScheduledJob, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, due_at, payload FROM webhook_scheduled_jobs
+		WHERE due_at <= $1 ORDER BY due_at, id LIMIT $2`,
+		now, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("reading due scheduled jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var due []ScheduledJob
+	for rows.Next() {
+		var job ScheduledJob
+		if err := rows.Scan(&job.ID, &job.DueAt, &job.Payload); err != nil {
+			return nil, fmt.Errorf("reading due scheduled jobs: %w", err)
+		}
+		job.DueAt = job.DueAt.UTC()
+		due = append(due, job)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading due scheduled jobs: %w", err)
+	}
+	return due, nil
+}
+
+// Remove implements Schedule.
+func (s *PostgresSchedule) Remove(ctx context.Context, id string) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM webhook_scheduled_jobs WHERE id = $1`, id); err != nil {
+		return fmt.Errorf("removing scheduled job: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPostgresSchedule(t *testing.T) {
+	db := openTestPostgres(t)
+	ctx := context.Background()
+	if _, err := db.Exec(`DROP TABLE IF EXISTS webhook_scheduled_jobs`); err != nil {
+		t.Fatalf("failed to reset table: %v", err)
+	}
+
+	s := NewPostgresSchedule(db)
+	if err := s.Migrate(ctx); err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+	// Migrate must be safe to run on every startup.
+	if err := s.Migrate(ctx); err != nil {
+		t.Fatalf("second Migrate failed: %v", err)
+	}
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	for _, job := range []ScheduledJob{
+		{ID: "later", DueAt: now.Add(time.Hour), Payload: []byte("later")},
+		{ID: "second", DueAt: now.Add(-time.Minute), Payload: []byte("second")},
+		{ID: "first", DueAt: now.Add(-time.Hour), Payload: []byte("stale")},
+		{ID: "first", DueAt: now.Add(-time.Hour), Payload: []byte("first")},
+	} {
+		if err := s.Add(ctx, job); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+
+	due, err := s.Due(ctx, now, 10)
+	if err != nil {
+		t.Fatalf("Due failed: %v", err)
+	}
+	if len(due) != 2 || string(due[0].Payload) != "first" || string(due[1].Payload) != "second" {
+		t.Fatalf("incorrect due jobs: got %+v want first and second", due)
+	}
+	if !due[0].DueAt.Equal(now.Add(-time.Hour)) {
+		t.Errorf("incorrect due time: got %v want %v", due[0].DueAt, now.Add(-time.Hour))
+	}
+
+	if err := s.Remove(ctx, "first"); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if err := s.Remove(ctx, "unknown"); err != nil {
+		t.Errorf("removing an unknown job failed: %v", err)
+	}
+	if due, _ := s.Due(ctx, now, 10); len(due) != 1 || due[0].ID != "second" {
+		t.Errorf("incorrect due jobs after remove: got %+v want second", due)
+	}
+}
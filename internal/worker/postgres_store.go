@@ -0,0 +1,206 @@
+package worker
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// postgresSchema creates the table used by PostgresStore. The processed_at
+// index supports auditing which webhooks were handled in a time window.
+const postgresSchema = `
+CREATE TABLE IF NOT EXISTS webhook_events_processed (
+	event_uuid   TEXT PRIMARY KEY,
+	event_type   TEXT NOT NULL,
+	status       TEXT NOT NULL,
+	processed_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS webhook_events_processed_processed_at_idx
+	ON webhook_events_processed (processed_at);
+ALTER TABLE webhook_events_processed
+	ADD COLUMN IF NOT EXISTS attempts   INTEGER NOT NULL DEFAULT 0,
+	ADD COLUMN IF NOT EXISTS last_error TEXT NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS claimed_at TIMESTAMPTZ;
+`
+
+// PostgresStore is a Store backed by a Postgres table. Unlike the in-memory
+// store it survives restarts and keeps an auditable record of each event.
+type PostgresStore struct {
+	db *sql.DB
+}
+
+var (
+	_ Store     = (*PostgresStore)(nil)
+	_ Recoverer = (*PostgresStore)(nil)
+	_ Lister    = (*PostgresStore)(nil)
+)
+
+// NewPostgresStore creates a PostgresStore using an open database handle.
+// The caller is responsible for registering a driver and closing db.
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+// Migrate creates the backing table if it does not already exist.
+func (s *PostgresStore) Migrate(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, postgresSchema); err != nil {
+		return fmt.Errorf("creating idempotency table: %w", err)
+	}
+	return nil
+}
+
+// Has reports whether a key (event UUID) has a recorded outcome.
+func (s *PostgresStore) Has(ctx context.Context, key string) (bool, error) {
+	var found bool
+	err := s.db.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM webhook_events_processed WHERE event_uuid = $1 AND status NOT IN ($2, $3))`,
+		key, string(StatusProcessing), string(StatusPending),
+	).Scan(&found)
+	if err != nil {
+		return false, fmt.Errorf("querying idempotency key: %w", err)
+	}
+	return found, nil
+}
+
+// Get returns the record for a key (event UUID).
+func (s *PostgresStore) Get(ctx context.Context, key string) (Record, bool, error) {
+	var rec Record
+	var status string
+	var claimedAt sql.NullTime
+	err := s.db.QueryRowContext(ctx, `
+		SELECT event_type, status, attempts, last_error, claimed_at, processed_at
+		FROM webhook_events_processed WHERE event_uuid = $1`,
+		key,
+	).Scan(&rec.EventType, &status, &rec.Attempts, &rec.LastError, &claimedAt, &rec.ProcessedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Record{}, false, nil
+	}
+	if err != nil {
+		return Record{}, false, fmt.Errorf("reading idempotency key: %w", err)
+	}
+	rec.Status = Status(status)
+	rec.ClaimedAt = claimedAt.Time
+	return rec, true, nil
+}
+
+// Set records the outcome for a key (event UUID), overwriting any prior outcome.
+func (s *PostgresStore) Set(ctx context.Context, key string, rec Record) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO webhook_events_processed
+			(event_uuid, event_type, status, attempts, last_error, claimed_at, processed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (event_uuid) DO UPDATE
+		SET event_type = EXCLUDED.event_type,
+		    status = EXCLUDED.status,
+		    attempts = EXCLUDED.attempts,
+		    last_error = EXCLUDED.last_error,
+		    claimed_at = EXCLUDED.claimed_at,
+		    processed_at = EXCLUDED.processed_at`,
+		key, rec.EventType, string(rec.Status), rec.Attempts, rec.LastError, nullTime(rec.ClaimedAt), rec.ProcessedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("recording idempotency key: %w", err)
+	}
+	return nil
+}
+
+// SetIfAbsent inserts a key (event UUID) unless a row for it already exists.
+// A pending row, left behind by a crashed process, is replaced.
+func (s *PostgresStore) SetIfAbsent(ctx context.Context, key string, rec Record) (bool, error) {
+	res, err := s.db.ExecContext(ctx, `
+		INSERT INTO webhook_events_processed
+			(event_uuid, event_type, status, attempts, last_error, claimed_at, processed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (event_uuid) DO UPDATE
+		SET event_type = EXCLUDED.event_type,
+		    status = EXCLUDED.status,
+		    attempts = EXCLUDED.attempts,
+		    last_error = EXCLUDED.last_error,
+		    claimed_at = EXCLUDED.claimed_at,
+		    processed_at = EXCLUDED.processed_at
+		WHERE webhook_events_processed.status = $8`,
+		key, rec.EventType, string(rec.Status), rec.Attempts, rec.LastError, nullTime(rec.ClaimedAt), rec.ProcessedAt,
+		string(StatusPending),
+	)
+	if err != nil {
+		return false, fmt.Errorf("claiming idempotency key: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("claiming idempotency key: %w", err)
+	}
+	return n == 1, nil
+}
+
+// Delete removes a key (event UUID) from the store.
+func (s *PostgresStore) Delete(ctx context.Context, key string) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM webhook_events_processed WHERE event_uuid = $1`, key); err != nil {
+		return fmt.Errorf("deleting idempotency key: %w", err)
+	}
+	return nil
+}
+
+// List returns a page of rows ordered by event UUID.
+func (s *PostgresStore) List(ctx context.Context, opts ListOptions) ([]Entry, string, error) {
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = math.MaxInt32
+	}
+	// Fetch one extra row to learn whether another page follows.
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT event_uuid, event_type, status, attempts, last_error, claimed_at, processed_at
+		FROM webhook_events_processed
+		WHERE event_uuid > $1 AND ($2 = '' OR event_type = $2)
+		ORDER BY event_uuid
+		LIMIT $3`,
+		opts.Cursor, opts.EventType, int64(limit)+1,
+	)
+	if err != nil {
+		return nil, "", fmt.Errorf("listing idempotency keys: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []Entry
+	for rows.Next() {
+		var e Entry
+		var status string
+		var claimedAt sql.NullTime
+		if err := rows.Scan(&e.Key, &e.EventType, &status, &e.Attempts, &e.LastError, &claimedAt, &e.ProcessedAt); err != nil {
+			return nil, "", fmt.Errorf("listing idempotency keys: %w", err)
+		}
+		e.Status = Status(status)
+		e.ClaimedAt = claimedAt.Time
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("listing idempotency keys: %w", err)
+	}
+	entries, next := paginate(entries, limit)
+	return entries, next, nil
+}
+
+// RecoverStale resets rows claimed before cutoff that never recorded an outcome.
+func (s *PostgresStore) RecoverStale(ctx context.Context, cutoff time.Time) (int, error) {
+	res, err := s.db.ExecContext(ctx, `
+		UPDATE webhook_events_processed
+		SET status = $1, last_error = $2, processed_at = $3
+		WHERE status = $4 AND COALESCE(claimed_at, processed_at) < $5`,
+		string(StatusPending), abandonedClaimError, time.Now().UTC(), string(StatusProcessing), cutoff,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("resetting stale claims: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("resetting stale claims: %w", err)
+	}
+	return int(n), nil
+}
+
+// nullTime maps the zero time to SQL NULL.
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
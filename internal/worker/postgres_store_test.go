@@ -0,0 +1,180 @@
+package worker
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// openTestPostgres connects to the database named by TEST_POSTGRES_DSN, or
+// skips the test if it is unset.
+func openTestPostgres(t *testing.T) *sql.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN not set; skipping Postgres tests")
+	}
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := db.Exec(`DROP TABLE IF EXISTS webhook_events_processed`); err != nil {
+		t.Fatalf("failed to reset table: %v", err)
+	}
+	return db
+}
+
+func TestPostgresStore(t *testing.T) {
+	db := openTestPostgres(t)
+	ctx := context.Background()
+
+	store := NewPostgresStore(db)
+	if err := store.Migrate(ctx); err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+	// Migrate must be safe to run on every startup.
+	if err := store.Migrate(ctx); err != nil {
+		t.Fatalf("second Migrate failed: %v", err)
+	}
+
+	key := "pg-uuid-123"
+	if found, err := store.Has(ctx, key); err != nil || found {
+		t.Fatalf("Expected Has(%q) to be false, got %v (err %v)", key, found, err)
+	}
+
+	// Only the first claim succeeds, and a claim is not an outcome.
+	claim := Record{EventType: "company.updated", Status: StatusProcessing, ProcessedAt: time.Now().UTC()}
+	if ok, err := store.SetIfAbsent(ctx, key, claim); err != nil || !ok {
+		t.Fatalf("Expected first SetIfAbsent to succeed, got %v (err %v)", ok, err)
+	}
+	if ok, _ := store.SetIfAbsent(ctx, key, claim); ok {
+		t.Errorf("Expected second SetIfAbsent to fail")
+	}
+	if found, _ := store.Has(ctx, key); found {
+		t.Errorf("Expected Has(%q) to be false while only claimed", key)
+	}
+
+	// Releasing the claim allows it to be taken again.
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if ok, _ := store.SetIfAbsent(ctx, key, claim); !ok {
+		t.Errorf("Expected SetIfAbsent to succeed after release")
+	}
+
+	rec := Record{EventType: "company.updated", Status: StatusSucceeded, ProcessedAt: time.Now().UTC()}
+	if err := store.Set(ctx, key, rec); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if found, err := store.Has(ctx, key); err != nil || !found {
+		t.Fatalf("Expected Has(%q) to be true, got %v (err %v)", key, found, err)
+	}
+
+	// Get round-trips every recorded field.
+	failed := Record{
+		EventType:   "company.updated",
+		Status:      StatusPermanentFailure,
+		Attempts:    2,
+		LastError:   "permanent error: validation failed",
+		ClaimedAt:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		ProcessedAt: time.Date(2024, 1, 1, 0, 0, 1, 0, time.UTC),
+	}
+	if err := store.Set(ctx, "failed-uuid", failed); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	got, found, err := store.Get(ctx, "failed-uuid")
+	if err != nil || !found {
+		t.Fatalf("Expected Get to succeed, got found=%v err=%v", found, err)
+	}
+	if !got.ClaimedAt.Equal(failed.ClaimedAt) || !got.ProcessedAt.Equal(failed.ProcessedAt) {
+		t.Errorf("incorrect timestamps: got %+v want %+v", got, failed)
+	}
+	got.ClaimedAt, got.ProcessedAt = failed.ClaimedAt, failed.ProcessedAt
+	if got != failed {
+		t.Errorf("incorrect record: got %+v want %+v", got, failed)
+	}
+
+	// A later outcome for the same key overwrites the earlier one.
+	rec.Status = StatusDeadLettered
+	if err := store.Set(ctx, key, rec); err != nil {
+		t.Fatalf("second Set failed: %v", err)
+	}
+	var status string
+	if err := db.QueryRow(`SELECT status FROM webhook_events_processed WHERE event_uuid = $1`, key).Scan(&status); err != nil {
+		t.Fatalf("failed to read back status: %v", err)
+	}
+	if status != string(StatusDeadLettered) {
+		t.Errorf("incorrect status: got %q want %q", status, StatusDeadLettered)
+	}
+}
+
+func TestPostgresStoreRecoverStale(t *testing.T) {
+	db := openTestPostgres(t)
+	ctx := context.Background()
+
+	store := NewPostgresStore(db)
+	if err := store.Migrate(ctx); err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+	cutoff := time.Now().UTC().Add(-10 * time.Minute)
+	store.Set(ctx, "stale", Record{Status: StatusProcessing, Attempts: 2, ClaimedAt: cutoff.Add(-time.Minute), ProcessedAt: cutoff.Add(-time.Minute)})
+	store.Set(ctx, "fresh", Record{Status: StatusProcessing, Attempts: 1, ClaimedAt: cutoff.Add(time.Minute), ProcessedAt: cutoff.Add(time.Minute)})
+	store.Set(ctx, "done", Record{Status: StatusSucceeded, ClaimedAt: cutoff.Add(-time.Hour), ProcessedAt: cutoff.Add(-time.Hour)})
+
+	recovered, err := store.RecoverStale(ctx, cutoff)
+	if err != nil {
+		t.Fatalf("RecoverStale failed: %v", err)
+	}
+	if recovered != 1 {
+		t.Errorf("incorrect recovered count: got %d want 1", recovered)
+	}
+	if rec, _, _ := store.Get(ctx, "stale"); rec.Status != StatusPending || rec.Attempts != 2 {
+		t.Errorf("stale claim not reset to pending: %+v", rec)
+	}
+	if rec, _, _ := store.Get(ctx, "fresh"); rec.Status != StatusProcessing {
+		t.Errorf("fresh claim should be left alone, got %q", rec.Status)
+	}
+
+	// A pending key can be claimed again, but a processing one can't.
+	claim := Record{Status: StatusProcessing, Attempts: 3, ClaimedAt: time.Now().UTC(), ProcessedAt: time.Now().UTC()}
+	if ok, err := store.SetIfAbsent(ctx, "stale", claim); err != nil || !ok {
+		t.Errorf("Expected SetIfAbsent to claim a pending key, got %v (err %v)", ok, err)
+	}
+	if ok, _ := store.SetIfAbsent(ctx, "fresh", claim); ok {
+		t.Errorf("Expected SetIfAbsent to fail for a processing key")
+	}
+}
+
+func TestPostgresStoreList(t *testing.T) {
+	db := openTestPostgres(t)
+	ctx := context.Background()
+
+	store := NewPostgresStore(db)
+	if err := store.Migrate(ctx); err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+	now := time.Now().UTC()
+	store.Set(ctx, "a", Record{EventType: "company.updated", Status: StatusSucceeded, ProcessedAt: now})
+	store.Set(ctx, "b", Record{EventType: "payroll.processed", Status: StatusSucceeded, ProcessedAt: now})
+	store.Set(ctx, "c", Record{EventType: "company.updated", Status: StatusSucceeded, ProcessedAt: now})
+
+	entries, next, err := store.List(ctx, ListOptions{EventType: "company.updated", Limit: 1})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Key != "a" || next != "a" {
+		t.Fatalf("incorrect first page: %+v (next %q)", entries, next)
+	}
+	entries, next, err = store.List(ctx, ListOptions{EventType: "company.updated", Cursor: next, Limit: 1})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Key != "c" || next != "" {
+		t.Errorf("incorrect second page: %+v (next %q)", entries, next)
+	}
+}
@@ -0,0 +1,158 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"gusto-webhook-guide/internal/models"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PriorityQueue is the Pool's default, in-memory JobQueue. It keeps a
+// bounded lane per priority and hands out higher-priority jobs first.
+// Queued jobs are lost if the process exits.
+//
+// Its memory doesn't need shrinking after a burst: the lanes are channels,
+// and the runtime clears a channel slot as a job is received, so a payload
+// is only held until a worker takes it. What stays allocated is a fixed
+// array of job headers per lane.
+type PriorityQueue struct {
+	high, normal, low ChannelQueue
+
+	mu      sync.RWMutex  // Held for reading while enqueueing, so that Close waits for senders.
+	closed  bool          // Set by Close; later jobs are refused.
+	closing chan struct{} // Closed by Close, to stop senders waiting for room.
+}
+
+var _ JobQueue = (*PriorityQueue)(nil)
+
+// NewPriorityQueue creates a PriorityQueue holding up to size jobs per priority.
+func NewPriorityQueue(size int) *PriorityQueue {
+	return newPriorityQueue(make(chan models.Job, size), size)
+}
+
+// newPriorityQueue creates a PriorityQueue whose normal lane is normal.
+func newPriorityQueue(normal chan models.Job, size int) *PriorityQueue {
+	return &PriorityQueue{
+		high:    make(ChannelQueue, size),
+		normal:  ChannelQueue(normal),
+		low:     make(ChannelQueue, size),
+		closing: make(chan struct{}),
+	}
+}
+
+// lane returns the lane for jobs of priority p.
+func (q *PriorityQueue) lane(p models.Priority) ChannelQueue {
+	switch {
+	case p > models.PriorityNormal:
+		return q.high
+	case p < models.PriorityNormal:
+		return q.low
+	default:
+		return q.normal
+	}
+}
+
+// Enqueue implements JobQueue, adding the job to the lane for its priority.
+// It returns ErrQueueClosed once Close has been called, including to a call
+// waiting for room.
+func (q *PriorityQueue) Enqueue(ctx context.Context, job models.Job, wait time.Duration) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.closed {
+		return ErrQueueClosed
+	}
+	return q.lane(job.Priority).enqueue(ctx, job, wait, q.closing)
+}
+
+// Dequeue implements JobQueue. It returns the highest-priority job waiting,
+// and ErrQueueClosed once Close has been called and every lane is empty.
+// Like ChannelQueue's, cancelling ctx only stops this call from waiting.
+func (q *PriorityQueue) Dequeue(ctx context.Context) (models.Job, func() error, error) {
+	for {
+		// Take the highest-priority job that is ready, if any.
+		closed := false
+		for _, lane := range []ChannelQueue{q.high, q.normal, q.low} {
+			select {
+			case job, ok := <-lane:
+				if ok {
+					return job, noopAck, nil
+				}
+				closed = true
+			default:
+			}
+		}
+		if closed {
+			return models.Job{}, nil, ErrQueueClosed
+		}
+
+		// Otherwise wait for the next job in any lane. Only the normal lane
+		// is ever closed; when it is, look again to drain the others.
+		select {
+		case job := <-q.high:
+			return job, noopAck, nil
+		case job, ok := <-q.normal:
+			if ok {
+				return job, noopAck, nil
+			}
+		case job := <-q.low:
+			return job, noopAck, nil
+		case <-ctx.Done():
+			return models.Job{}, nil, ctx.Err()
+		}
+	}
+}
+
+// Close closes the queue, like closing a ChannelQueue. Jobs already queued
+// are still handed out, in priority order, and later ones are refused.
+func (q *PriorityQueue) Close() {
+	close(q.closing)
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = true
+	close(q.normal)
+}
+
+// Depth returns the number of queued jobs and the total capacity of all lanes.
+func (q *PriorityQueue) Depth() (int, int) {
+	return len(q.high) + len(q.normal) + len(q.low), cap(q.high) + cap(q.normal) + cap(q.low)
+}
+
+// Priorities assigns priorities to event types. Keys are event types, e.g.
+// "payroll.processed", or resource prefixes, e.g. "payroll", which match
+// every event type starting with "payroll.".
+type Priorities map[string]models.Priority
+
+// For returns the priority for eventType, preferring an exact match over a
+// prefix. Unlisted event types are normal priority.
+func (ps Priorities) For(eventType string) models.Priority {
+	if p, ok := ps[eventType]; ok {
+		return p
+	}
+	prefix, _, _ := strings.Cut(eventType, ".")
+	return ps[prefix]
+}
+
+// ParsePriorities parses a comma-separated list of key=priority pairs, where
+// priority is high, normal or low, e.g. "payroll=high,company=low".
+func ParsePriorities(raw string) (Priorities, error) {
+	levels := map[string]models.Priority{"high": models.PriorityHigh, "normal": models.PriorityNormal, "low": models.PriorityLow}
+	priorities := make(Priorities)
+	for _, pair := range strings.Split(raw, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		key, level, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("invalid priority %q: want event_type=priority", pair)
+		}
+		p, ok := levels[strings.TrimSpace(level)]
+		if !ok {
+			return nil, fmt.Errorf("invalid priority %q: priority must be high, normal or low", pair)
+		}
+		priorities[strings.TrimSpace(key)] = p
+	}
+	return priorities, nil
+}
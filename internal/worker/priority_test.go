@@ -0,0 +1,93 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"gusto-webhook-guide/internal/models"
+	"testing"
+	"time"
+)
+
+func TestPriorityQueue(t *testing.T) {
+	ctx := context.Background()
+	q := NewPriorityQueue(2)
+
+	for _, job := range []models.Job{
+		{Payload: []byte("low"), Priority: models.PriorityLow},
+		{Payload: []byte("normal")},
+		{Payload: []byte("high"), Priority: models.PriorityHigh},
+	} {
+		if err := q.Enqueue(ctx, job, 0); err != nil {
+			t.Fatalf("Enqueue failed: %v", err)
+		}
+	}
+	if length, capacity := q.Depth(); length != 3 || capacity != 6 {
+		t.Errorf("incorrect depth: got %d/%d want 3/6", length, capacity)
+	}
+
+	// Closing still hands out the queued jobs, highest priority first.
+	q.Close()
+	for _, want := range []string{"high", "normal", "low"} {
+		job, _, err := q.Dequeue(ctx)
+		if err != nil || string(job.Payload) != want {
+			t.Fatalf("incorrect Dequeue result: got %q (err %v) want %q", job.Payload, err, want)
+		}
+	}
+	if _, _, err := q.Dequeue(ctx); !errors.Is(err, ErrQueueClosed) {
+		t.Errorf("incorrect error for a closed queue: got %v want %v", err, ErrQueueClosed)
+	}
+}
+
+func TestParsePriorities(t *testing.T) {
+	priorities, err := ParsePriorities("payroll=high, company=low,payroll.processed=normal")
+	if err != nil {
+		t.Fatalf("ParsePriorities failed: %v", err)
+	}
+
+	testCases := []struct {
+		eventType string
+		want      models.Priority
+	}{
+		{eventType: "payroll.submitted", want: models.PriorityHigh},
+		{eventType: "payroll.processed", want: models.PriorityNormal},
+		{eventType: "company.updated", want: models.PriorityLow},
+		{eventType: "employee.created", want: models.PriorityNormal},
+	}
+	for _, tc := range testCases {
+		if got := priorities.For(tc.eventType); got != tc.want {
+			t.Errorf("incorrect priority for %s: got %d want %d", tc.eventType, got, tc.want)
+		}
+	}
+
+	for _, raw := range []string{"payroll", "payroll=urgent", "=high"} {
+		if _, err := ParsePriorities(raw); err == nil {
+			t.Errorf("expected an error parsing %q", raw)
+		}
+	}
+}
+
+func TestPriorityQueueEnqueueAfterClose(t *testing.T) {
+	ctx := context.Background()
+	q := NewPriorityQueue(1)
+	if err := q.Enqueue(ctx, models.Job{Payload: []byte("first")}, 0); err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+
+	// A sender waiting for room gives up once the queue closes.
+	waiting := make(chan error, 1)
+	go func() { waiting <- q.Enqueue(ctx, models.Job{Payload: []byte("waiting")}, -1) }()
+	time.Sleep(10 * time.Millisecond)
+	q.Close()
+	if err := <-waiting; !errors.Is(err, ErrQueueClosed) {
+		t.Errorf("incorrect error for a waiting sender: got %v want %v", err, ErrQueueClosed)
+	}
+
+	for _, priority := range []models.Priority{models.PriorityHigh, models.PriorityNormal, models.PriorityLow} {
+		if err := q.Enqueue(ctx, models.Job{Priority: priority}, 0); !errors.Is(err, ErrQueueClosed) {
+			t.Errorf("incorrect error enqueueing priority %v after Close: got %v want %v", priority, err, ErrQueueClosed)
+		}
+	}
+	if length, _ := q.Depth(); length != 1 {
+		t.Errorf("incorrect depth after Close: got %d want 1", length)
+	}
+}
@@ -0,0 +1,201 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gusto-webhook-guide/internal/models"
+	"log/slog"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// defaultQuarantineCapacity is how many entries a Pool's quarantine keeps
+// before evicting the oldest.
+const defaultQuarantineCapacity = 100
+
+// ErrQuarantinedNotFound is returned for a quarantine ID that isn't held.
+var ErrQuarantinedNotFound = errors.New("quarantined job not found")
+
+// AttemptFailure is one failed attempt at a job.
+type AttemptFailure struct {
+	Error    string        `json:"error"`
+	Duration time.Duration `json:"duration_ns"`
+	Panicked bool          `json:"panicked,omitempty"`
+	At       time.Time     `json:"at"`
+}
+
+// QuarantinedJob is a job taken out of circulation as a poison message: one
+// whose attempts keep crashing the handler or running abnormally long.
+// Unlike a dead letter, it isn't expected to succeed once some outside cause
+// has passed, so it is kept apart, with every failed attempt, to be
+// inspected before anything is done with it.
+type QuarantinedJob struct {
+	ID            string           `json:"id"`
+	EventUUID     string           `json:"event_uuid"`
+	EventType     string           `json:"event_type"`
+	Failures      []AttemptFailure `json:"failures"` // Oldest first.
+	Payload       []byte           `json:"payload"`
+	QuarantinedAt time.Time        `json:"quarantined_at"`
+}
+
+// Quarantine is an in-memory list of quarantined jobs, oldest first. Its
+// contents are lost on restart.
+type Quarantine struct {
+	mu      sync.Mutex
+	max     int
+	nextID  int
+	entries []QuarantinedJob
+}
+
+// NewQuarantine creates a Quarantine keeping up to max entries. The oldest
+// entry is evicted once the limit is reached.
+func NewQuarantine(max int) *Quarantine {
+	return &Quarantine{max: max}
+}
+
+// Add stores qj with a new ID and returns it. It also returns the entry
+// evicted to make room, if any.
+func (q *Quarantine) Add(qj QuarantinedJob) (added QuarantinedJob, evicted *QuarantinedJob) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.nextID++
+	qj.ID = strconv.Itoa(q.nextID)
+	if len(q.entries) >= q.max {
+		oldest := q.entries[0]
+		evicted = &oldest
+		q.entries = q.entries[1:]
+	}
+	q.entries = append(q.entries, qj)
+	return qj, evicted
+}
+
+// List returns the entries, oldest first.
+func (q *Quarantine) List() []QuarantinedJob {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return slices.Clone(q.entries)
+}
+
+// Get returns the entry with the given ID.
+func (q *Quarantine) Get(id string) (QuarantinedJob, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	i := slices.IndexFunc(q.entries, func(qj QuarantinedJob) bool { return qj.ID == id })
+	if i < 0 {
+		return QuarantinedJob{}, false
+	}
+	return q.entries[i], true
+}
+
+// Remove deletes the entry with the given ID and reports whether it existed.
+func (q *Quarantine) Remove(id string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	i := slices.IndexFunc(q.entries, func(qj QuarantinedJob) bool { return qj.ID == id })
+	if i < 0 {
+		return false
+	}
+	q.entries = slices.Delete(q.entries, i, i+1)
+	return true
+}
+
+// Len returns the number of entries.
+func (q *Quarantine) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.entries)
+}
+
+// SetPoisonDetection quarantines jobs as poison messages once maxAbnormal of
+// their attempts have gone abnormally: panicked, or run for slowAttempt or
+// longer (zero leaves duration out of it). Such jobs would otherwise keep
+// crashing handlers or tying up workers until their retries ran out. Only
+// attempts seen by this process count. Zero maxAbnormal, the default,
+// disables detection. It must be called before Start.
+func (p *Pool) SetPoisonDetection(maxAbnormal int, slowAttempt time.Duration) {
+	p.poisonAttempts = maxAbnormal
+	p.poisonDuration = slowAttempt
+}
+
+// Quarantine returns the pool's quarantine.
+func (p *Pool) Quarantine() *Quarantine {
+	return p.quarantine
+}
+
+// recordFailure adds a failed attempt to the event's history, returning the
+// history and whether enough of its attempts were abnormal for the event to
+// be quarantined. It does nothing unless poison detection is enabled.
+func (p *Pool) recordFailure(eventUUID string, err error, elapsed time.Duration) ([]AttemptFailure, bool) {
+	if p.poisonAttempts <= 0 {
+		return nil, false
+	}
+	p.failuresMu.Lock()
+	defer p.failuresMu.Unlock()
+	if p.failures == nil {
+		p.failures = make(map[string][]AttemptFailure)
+	}
+	failures := append(p.failures[eventUUID], AttemptFailure{
+		Error:    err.Error(),
+		Duration: elapsed,
+		Panicked: errors.Is(err, ErrHandlerPanic),
+		At:       time.Now().UTC(),
+	})
+	p.failures[eventUUID] = failures
+
+	abnormal := 0
+	for _, f := range failures {
+		if f.Panicked || (p.poisonDuration > 0 && f.Duration >= p.poisonDuration) {
+			abnormal++
+		}
+	}
+	return failures, abnormal >= p.poisonAttempts
+}
+
+// forgetFailures drops the event's failure history once it won't be retried.
+func (p *Pool) forgetFailures(eventUUID string) {
+	if p.poisonAttempts <= 0 {
+		return
+	}
+	p.failuresMu.Lock()
+	defer p.failuresMu.Unlock()
+	delete(p.failures, eventUUID)
+}
+
+// quarantineJob adds a poison job to the quarantine.
+func (p *Pool) quarantineJob(logger *slog.Logger, job models.Job, event models.WebhookEvent, failures []AttemptFailure) {
+	jobsQuarantined.WithLabelValues(event.EventType).Inc()
+	qj, evicted := p.quarantine.Add(QuarantinedJob{
+		EventUUID:     event.UUID,
+		EventType:     event.EventType,
+		Failures:      failures,
+		Payload:       job.Payload,
+		QuarantinedAt: time.Now().UTC(),
+	})
+	logger.Info("Job quarantined", "quarantine_id", qj.ID)
+	if evicted != nil {
+		logger.Warn("Quarantine is full, evicted the oldest entry", "evicted_id", evicted.ID, "evicted_event_uuid", evicted.EventUUID)
+	}
+}
+
+// ReleaseQuarantined queues the quarantined job with the given ID again,
+// with a fresh attempt count, e.g. once its handler has been fixed.
+func (p *Pool) ReleaseQuarantined(ctx context.Context, id string) error {
+	if p.ctx.Err() != nil {
+		return ErrPoolStopping
+	}
+	qj, found := p.quarantine.Get(id)
+	if !found {
+		return ErrQuarantinedNotFound
+	}
+	if err := p.idempotencyStore.Delete(ctx, qj.EventUUID); err != nil {
+		return fmt.Errorf("releasing idempotency key for %s: %w", qj.EventUUID, err)
+	}
+	if err := p.queue.Enqueue(ctx, models.Job{Payload: qj.Payload}, 0); err != nil {
+		return fmt.Errorf("releasing quarantined job %s: %w", qj.ID, err)
+	}
+	p.quarantine.Remove(qj.ID)
+	return nil
+}
@@ -0,0 +1,65 @@
+package worker
+
+import (
+	"context"
+	"gusto-webhook-guide/internal/models"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestPoolQuarantinesPoisonJobs(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	store := NewIdempotencyStore()
+	processor := ProcessorFunc(func(_ context.Context, event models.WebhookEvent) error {
+		switch event.UUID {
+		case "crashes":
+			panic("nil map")
+		case "slow":
+			time.Sleep(20 * time.Millisecond)
+		}
+		return Transientf("gusto unavailable")
+	})
+	pool := NewPool(10, 0, logger, store, processor)
+	defer pool.Stop()
+	pool.SetPoisonDetection(2, 10*time.Millisecond)
+
+	for _, uuid := range []string{"crashes", "slow", "flaky"} {
+		job := models.Job{Payload: []byte(`{"uuid":"` + uuid + `","event_type":"company.updated"}`)}
+		quarantined := pool.Quarantine().Len()
+		pool.handleJob(1, job)
+		if pool.Quarantine().Len() != quarantined {
+			t.Fatalf("Expected %s not to be quarantined after one attempt", uuid)
+		}
+		pool.handleJob(1, job)
+	}
+
+	entries := pool.Quarantine().List()
+	if len(entries) != 2 || entries[0].EventUUID != "crashes" || entries[1].EventUUID != "slow" {
+		t.Fatalf("incorrect jobs quarantined: got %+v want crashes and slow", entries)
+	}
+	if failures := entries[0].Failures; len(failures) != 2 || !failures[0].Panicked || !failures[1].Panicked {
+		t.Errorf("incorrect failure history: got %+v want two panics", failures)
+	}
+	if rec, _, _ := store.Get(ctx, "crashes"); rec.Status != StatusQuarantined {
+		t.Errorf("incorrect status for quarantined job: got %q want %q", rec.Status, StatusQuarantined)
+	}
+	if dls := pool.DeadLetters().List(DeadLetterFilter{}); len(dls) != 0 {
+		t.Errorf("Expected quarantined jobs to stay out of the dead-letter queue, got %+v", dls)
+	}
+
+	if err := pool.ReleaseQuarantined(ctx, entries[0].ID); err != nil {
+		t.Fatalf("ReleaseQuarantined failed: %v", err)
+	}
+	if pool.Quarantine().Len() != 1 {
+		t.Errorf("Expected the released job to leave the quarantine")
+	}
+	if found, _ := store.Has(ctx, "crashes"); found {
+		t.Errorf("Expected the released job's idempotency key to be deleted")
+	}
+	if err := pool.ReleaseQuarantined(ctx, entries[0].ID); err != ErrQuarantinedNotFound {
+		t.Errorf("incorrect error releasing twice: got %v want %v", err, ErrQuarantinedNotFound)
+	}
+}
@@ -0,0 +1,139 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"gusto-webhook-guide/internal/models"
+	"time"
+)
+
+var (
+	// ErrQueueFull is returned by JobQueue.Enqueue when a bounded queue has
+	// no room for the job.
+	ErrQueueFull = errors.New("job queue is full")
+	// ErrQueueClosed is returned by JobQueue.Dequeue once the queue is closed
+	// and drained.
+	ErrQueueClosed = errors.New("job queue is closed")
+)
+
+// JobQueue holds jobs waiting for a worker.
+type JobQueue interface {
+	// Enqueue adds a job. If a bounded queue is full, it waits up to wait
+	// for room (until ctx is done if wait is negative) before returning
+	// ErrQueueFull.
+	Enqueue(ctx context.Context, job models.Job, wait time.Duration) error
+	// Dequeue blocks until a job is available. Once the job has been
+	// handled, ack must be called to remove it from the queue.
+	Dequeue(ctx context.Context) (job models.Job, ack func() error, err error)
+}
+
+// Redriver is implemented by queues with a dead-letter queue whose messages
+// can be moved back for another try.
+type Redriver interface {
+	// Redrive starts moving dead-lettered jobs back to the queue and returns
+	// an identifier for the move.
+	Redrive(ctx context.Context) (string, error)
+}
+
+// StoredRetry is a retry persisted by a RetryStore.
+type StoredRetry struct {
+	ID    string
+	Job   models.Job
+	DueAt time.Time
+}
+
+// RetryStore is implemented by durable queues that also keep the retries
+// waiting for their delay, so that a restart doesn't lose them. See
+// Pool.LoadRetries.
+type RetryStore interface {
+	// SaveRetry stores a job due for another attempt at dueAt and returns
+	// its ID.
+	SaveRetry(ctx context.Context, job models.Job, dueAt time.Time) (string, error)
+	// DeleteRetry removes a stored retry; removing an unknown ID is not an
+	// error.
+	DeleteRetry(ctx context.Context, id string) error
+	// Retries returns every stored retry.
+	Retries(ctx context.Context) ([]StoredRetry, error)
+}
+
+// formatReceivedAt encodes a job's ReceivedAt for queues that store it as a
+// string, as "" if it is unset.
+func formatReceivedAt(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// parseReceivedAt decodes a string from formatReceivedAt. Anything else,
+// such as a job queued before ReceivedAt was recorded, decodes as unset.
+func parseReceivedAt(s string) time.Time {
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// ChannelQueue is a bounded, in-memory JobQueue, as used for each lane of
+// a PriorityQueue. Queued jobs are lost if the process exits.
+type ChannelQueue chan models.Job
+
+var _ JobQueue = ChannelQueue(nil)
+
+// Enqueue implements JobQueue.
+func (q ChannelQueue) Enqueue(ctx context.Context, job models.Job, wait time.Duration) error {
+	return q.enqueue(ctx, job, wait, nil)
+}
+
+// enqueue is Enqueue, giving up with ErrQueueClosed if closing is closed
+// while waiting for room.
+func (q ChannelQueue) enqueue(ctx context.Context, job models.Job, wait time.Duration, closing <-chan struct{}) error {
+	select {
+	case q <- job:
+		return nil
+	default:
+	}
+	if wait == 0 {
+		return ErrQueueFull
+	}
+
+	var timeout <-chan time.Time
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case q <- job:
+		return nil
+	case <-timeout:
+		return ErrQueueFull
+	case <-ctx.Done():
+		return ErrQueueFull
+	case <-closing:
+		return ErrQueueClosed
+	}
+}
+
+// Dequeue implements JobQueue. It returns ErrQueueClosed once the channel
+// is closed and empty. The Pool doesn't cancel ctx at shutdown, so that
+// jobs already queued are drained, only to retire a worker.
+func (q ChannelQueue) Dequeue(ctx context.Context) (models.Job, func() error, error) {
+	select {
+	case job, ok := <-q:
+		if !ok {
+			return models.Job{}, nil, ErrQueueClosed
+		}
+		return job, noopAck, nil
+	case <-ctx.Done():
+		return models.Job{}, nil, ctx.Err()
+	}
+}
+
+// Depth returns the number of queued jobs and the queue's capacity.
+func (q ChannelQueue) Depth() (int, int) {
+	return len(q), cap(q)
+}
+
+func noopAck() error { return nil }
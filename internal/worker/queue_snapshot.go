@@ -0,0 +1,122 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"gusto-webhook-guide/internal/models"
+	"slices"
+	"time"
+)
+
+// QueueSnapshotVersion is the format version of QueueSnapshot.
+const QueueSnapshotVersion = 1
+
+var (
+	// ErrPoolStopping is returned by queue operations once Stop has been called.
+	ErrPoolStopping = errors.New("worker pool is stopping")
+	// ErrSnapshotVersion is returned when restoring a snapshot in an unknown format.
+	ErrSnapshotVersion = errors.New("unsupported queue snapshot version")
+)
+
+// QueueSnapshot is a point-in-time copy of the jobs a Pool has not processed
+// yet, used to move work between queue backends by hand.
+type QueueSnapshot struct {
+	Version int           `json:"version"`
+	TakenAt time.Time     `json:"taken_at"`
+	Pending []SnapshotJob `json:"pending"` // Jobs waiting in the queue, oldest first.
+	Retries []SnapshotJob `json:"retries"` // Jobs waiting for their retry delay.
+	// DeadLetters are the dead-letter queue's entries, oldest first.
+	DeadLetters []DeadLetter `json:"dead_letters,omitempty"`
+}
+
+// SnapshotJob is a queued job. DueAt is only set for scheduled retries.
+type SnapshotJob struct {
+	Payload    []byte          `json:"payload"`
+	Attempts   int             `json:"attempts"`
+	Priority   models.Priority `json:"priority,omitempty"`
+	ReceivedAt time.Time       `json:"received_at,omitzero"`
+	RequestID  string          `json:"request_id,omitempty"`
+	DueAt      time.Time       `json:"due_at,omitzero"`
+}
+
+// SnapshotQueue copies the pending and scheduled-retry jobs, pending jobs in
+// priority order. Pending jobs are briefly taken off the in-memory queue and
+// put back, so workers keep running; a job picked up meanwhile may or may
+// not be included. With a durable queue set by SetQueue, pending jobs are
+// already persisted and only retries are included. Restoring a snapshot
+// while its jobs are still queued is safe, since duplicates are deduplicated
+// by the idempotency store.
+func (p *Pool) SnapshotQueue() (QueueSnapshot, error) {
+	if p.ctx.Err() != nil {
+		return QueueSnapshot{}, ErrPoolStopping
+	}
+	snap := QueueSnapshot{
+		Version: QueueSnapshotVersion,
+		TakenAt: time.Now().UTC(),
+		Pending: []SnapshotJob{},
+		Retries: []SnapshotJob{},
+	}
+
+	var drained []models.Job
+	for _, lane := range []ChannelQueue{p.lanes.high, p.lanes.normal, p.lanes.low} {
+	drain:
+		for {
+			select {
+			case job := <-lane:
+				drained = append(drained, job)
+			default:
+				break drain
+			}
+		}
+	}
+	for _, job := range drained {
+		snap.Pending = append(snap.Pending, SnapshotJob{Payload: job.Payload, Attempts: job.Attempts, Priority: job.Priority, ReceivedAt: job.ReceivedAt, RequestID: job.RequestID})
+		p.lanes.lane(job.Priority) <- job
+	}
+
+	p.retriesMu.Lock()
+	for _, r := range p.retries {
+		snap.Retries = append(snap.Retries, SnapshotJob{Payload: r.job.Payload, Attempts: r.job.Attempts, Priority: r.job.Priority, ReceivedAt: r.job.ReceivedAt, RequestID: r.job.RequestID, DueAt: r.dueAt})
+	}
+	p.retriesMu.Unlock()
+	slices.SortFunc(snap.Retries, func(a, b SnapshotJob) int { return a.DueAt.Compare(b.DueAt) })
+	snap.DeadLetters = p.deadLetters.List(DeadLetterFilter{})
+	return snap, nil
+}
+
+// RestoreQueue enqueues the jobs in snap. Pending jobs are queued
+// immediately and retries are scheduled for their remaining delay. Dead
+// letters are added to the dead-letter queue under new IDs, so restoring
+// them into the process they came from duplicates them. It stops with an
+// error if the queue fills up, returning how many jobs and dead letters were
+// restored.
+func (p *Pool) RestoreQueue(snap QueueSnapshot) (int, error) {
+	if snap.Version != QueueSnapshotVersion {
+		return 0, fmt.Errorf("%w %d", ErrSnapshotVersion, snap.Version)
+	}
+	if p.ctx.Err() != nil {
+		return 0, ErrPoolStopping
+	}
+
+	restored := 0
+	for _, j := range snap.Pending {
+		err := p.queue.Enqueue(p.ctx, models.Job{Payload: j.Payload, Attempts: j.Attempts, Priority: j.Priority, ReceivedAt: j.ReceivedAt, RequestID: j.RequestID}, 0)
+		if errors.Is(err, ErrQueueFull) {
+			return restored, fmt.Errorf("job queue is full after restoring %d of %d pending jobs", restored, len(snap.Pending))
+		}
+		if err != nil {
+			return restored, err
+		}
+		restored++
+	}
+	for _, j := range snap.Retries {
+		delay := max(time.Until(j.DueAt), 0)
+		p.scheduleRetry(models.Job{Payload: j.Payload, Attempts: j.Attempts, Priority: j.Priority, ReceivedAt: j.ReceivedAt, RequestID: j.RequestID}, delay, p.logger)
+		restored++
+	}
+	for _, dl := range snap.DeadLetters {
+		p.deadLetters.Add(dl)
+		restored++
+	}
+	return restored, nil
+}
@@ -0,0 +1,81 @@
+package worker
+
+import (
+	"gusto-webhook-guide/internal/models"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestQueueSnapshotAndRestore(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	source := NewPool(10, 0, logger, NewIdempotencyStore(), stubProcessor)
+	defer source.Stop()
+
+	source.JobQueue <- models.Job{Payload: []byte(`{"uuid":"a"}`)}
+	source.JobQueue <- models.Job{Payload: []byte(`{"uuid":"b"}`), Attempts: 1, RequestID: "req-b"}
+	source.scheduleRetry(models.Job{Payload: []byte(`{"uuid":"c"}`), Attempts: 2}, time.Hour, logger)
+
+	snap, err := source.SnapshotQueue()
+	if err != nil {
+		t.Fatalf("SnapshotQueue failed: %v", err)
+	}
+	if len(snap.Pending) != 2 || string(snap.Pending[0].Payload) != `{"uuid":"a"}` || snap.Pending[1].Attempts != 1 {
+		t.Errorf("incorrect pending jobs: %+v", snap.Pending)
+	}
+	if len(snap.Retries) != 1 || snap.Retries[0].Attempts != 2 || time.Until(snap.Retries[0].DueAt) < 59*time.Minute {
+		t.Errorf("incorrect scheduled retries: %+v", snap.Retries)
+	}
+	// Taking a snapshot leaves the queue as it was.
+	if got := len(source.JobQueue); got != 2 {
+		t.Errorf("snapshot should not consume jobs: queue has %d, want 2", got)
+	}
+
+	target := NewPool(10, 0, logger, NewIdempotencyStore(), stubProcessor)
+	defer target.Stop()
+	restored, err := target.RestoreQueue(snap)
+	if err != nil || restored != 3 {
+		t.Fatalf("RestoreQueue restored %d jobs (err %v), want 3", restored, err)
+	}
+	if got := len(target.JobQueue); got != 2 {
+		t.Errorf("incorrect restored queue length: got %d want 2", got)
+	}
+	<-target.JobQueue
+	if job := <-target.JobQueue; job.RequestID != "req-b" {
+		t.Errorf("incorrect restored request ID: got %q want %q", job.RequestID, "req-b")
+	}
+	target.retriesMu.Lock()
+	if got := len(target.retries); got != 1 {
+		t.Errorf("incorrect restored retries: got %d want 1", got)
+	}
+	target.retriesMu.Unlock()
+}
+
+func TestRestoreQueueRejectsFullQueue(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	pool := NewPool(1, 0, logger, NewIdempotencyStore(), stubProcessor)
+	defer pool.Stop()
+
+	snap := QueueSnapshot{Version: QueueSnapshotVersion, Pending: []SnapshotJob{{Payload: []byte(`{}`)}, {Payload: []byte(`{}`)}}}
+	restored, err := pool.RestoreQueue(snap)
+	if err == nil || restored != 1 {
+		t.Errorf("expected an error after restoring 1 job, got %d (err %v)", restored, err)
+	}
+
+	if _, err := pool.RestoreQueue(QueueSnapshot{Version: 99}); err == nil {
+		t.Errorf("expected an error for an unknown snapshot version")
+	}
+}
+
+// waitFor polls cond until it is true or a second has passed.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("condition not met within 1s")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,55 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"gusto-webhook-guide/internal/models"
+	"testing"
+	"time"
+)
+
+func TestChannelQueue(t *testing.T) {
+	ctx := context.Background()
+	q := make(ChannelQueue, 1)
+
+	if err := q.Enqueue(ctx, models.Job{Payload: []byte("a")}, 0); err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+
+	testCases := []struct {
+		name string
+		ctx  context.Context
+		wait time.Duration
+	}{
+		{name: "No Wait", ctx: ctx, wait: 0},
+		{name: "Bounded Wait", ctx: ctx, wait: 10 * time.Millisecond},
+		{name: "Wait Until Context Done", ctx: cancelledAfter(t, 10*time.Millisecond), wait: -1},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := q.Enqueue(tc.ctx, models.Job{}, tc.wait); !errors.Is(err, ErrQueueFull) {
+				t.Errorf("incorrect error for a full queue: got %v want %v", err, ErrQueueFull)
+			}
+		})
+	}
+
+	job, ack, err := q.Dequeue(ctx)
+	if err != nil || string(job.Payload) != "a" {
+		t.Fatalf("incorrect Dequeue result: got %q (err %v) want %q", job.Payload, err, "a")
+	}
+	if err := ack(); err != nil {
+		t.Errorf("ack failed: %v", err)
+	}
+
+	close(q)
+	if _, _, err := q.Dequeue(ctx); !errors.Is(err, ErrQueueClosed) {
+		t.Errorf("incorrect error for a closed queue: got %v want %v", err, ErrQueueClosed)
+	}
+}
+
+// cancelledAfter returns a context cancelled after d.
+func cancelledAfter(t *testing.T, d time.Duration) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	t.Cleanup(cancel)
+	return ctx
+}
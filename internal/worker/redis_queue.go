@@ -0,0 +1,197 @@
+package worker
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gusto-webhook-guide/internal/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisStreamQueue is a durable JobQueue backed by a Redis stream and
+// consumer group, so webhook ingestion and processing can run in separate
+// processes. Delivery is at least once: a job whose consumer dies without
+// acknowledging it stays pending in the group and is claimed by another
+// consumer once it has been idle for claimAfter. Retries waiting for their
+// delay are kept in a hash named after the stream with a ":retries" suffix.
+type RedisStreamQueue struct {
+	client     redis.UniversalClient
+	stream     string
+	group      string
+	consumer   string        // Unique per process.
+	claimAfter time.Duration // Idle time after which a pending job is reclaimed.
+	block      time.Duration // How long each read waits for new jobs.
+}
+
+var (
+	_ JobQueue   = (*RedisStreamQueue)(nil)
+	_ RetryStore = (*RedisStreamQueue)(nil)
+)
+
+// NewRedisStreamQueue creates a RedisStreamQueue reading stream as consumer
+// in group. claimAfter must exceed the longest time a job takes to process.
+func NewRedisStreamQueue(client redis.UniversalClient, stream, group, consumer string, claimAfter time.Duration) *RedisStreamQueue {
+	return &RedisStreamQueue{
+		client:     client,
+		stream:     stream,
+		group:      group,
+		consumer:   consumer,
+		claimAfter: claimAfter,
+		block:      time.Second,
+	}
+}
+
+// Migrate creates the stream and consumer group if they do not already
+// exist. A new group starts at the beginning of the stream, so jobs added
+// before it existed are processed.
+func (q *RedisStreamQueue) Migrate(ctx context.Context) error {
+	err := q.client.XGroupCreateMkStream(ctx, q.stream, q.group, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return fmt.Errorf("creating consumer group: %w", err)
+	}
+	return nil
+}
+
+// Enqueue implements JobQueue. The stream is unbounded, so wait is unused.
+func (q *RedisStreamQueue) Enqueue(ctx context.Context, job models.Job, _ time.Duration) error {
+	err := q.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: q.stream,
+		Values: map[string]any{"payload": job.Payload, "attempts": job.Attempts, "received_at": formatReceivedAt(job.ReceivedAt), "request_id": job.RequestID},
+	}).Err()
+	if err != nil {
+		return fmt.Errorf("enqueuing job: %w", err)
+	}
+	return nil
+}
+
+// Dequeue implements JobQueue. Jobs abandoned by another consumer are
+// reclaimed before new ones are read.
+func (q *RedisStreamQueue) Dequeue(ctx context.Context) (models.Job, func() error, error) {
+	for {
+		msgs, _, err := q.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+			Stream:   q.stream,
+			Group:    q.group,
+			Consumer: q.consumer,
+			MinIdle:  q.claimAfter,
+			Start:    "0",
+			Count:    1,
+		}).Result()
+		if err != nil {
+			return models.Job{}, nil, q.readError(ctx, "reclaiming job", err)
+		}
+
+		if len(msgs) == 0 {
+			streams, err := q.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+				Group:    q.group,
+				Consumer: q.consumer,
+				Streams:  []string{q.stream, ">"},
+				Count:    1,
+				Block:    q.block,
+			}).Result()
+			if errors.Is(err, redis.Nil) {
+				continue // Nothing arrived within the block time.
+			}
+			if err != nil {
+				return models.Job{}, nil, q.readError(ctx, "dequeuing job", err)
+			}
+			msgs = streams[0].Messages
+		}
+		if len(msgs) == 0 {
+			continue
+		}
+		return q.decode(ctx, msgs[0])
+	}
+}
+
+// decode turns a stream entry into a job and its ack function.
+func (q *RedisStreamQueue) decode(ctx context.Context, msg redis.XMessage) (models.Job, func() error, error) {
+	ack := func() error {
+		ctx := context.WithoutCancel(ctx)
+		if err := q.client.XAck(ctx, q.stream, q.group, msg.ID).Err(); err != nil {
+			return fmt.Errorf("acknowledging job: %w", err)
+		}
+		// The entry is no longer needed once acknowledged.
+		if err := q.client.XDel(ctx, q.stream, msg.ID).Err(); err != nil {
+			return fmt.Errorf("deleting job: %w", err)
+		}
+		return nil
+	}
+
+	payload, _ := msg.Values["payload"].(string)
+	attemptsRaw, _ := msg.Values["attempts"].(string)
+	attempts, err := strconv.Atoi(attemptsRaw)
+	if err != nil {
+		// An entry we can't decode would otherwise be reclaimed forever.
+		ack()
+		return models.Job{}, nil, fmt.Errorf("decoding job %s: invalid attempts %q", msg.ID, attemptsRaw)
+	}
+	receivedAt, _ := msg.Values["received_at"].(string) // Missing from entries queued by older versions.
+	requestID, _ := msg.Values["request_id"].(string)
+	return models.Job{Payload: []byte(payload), Attempts: attempts, ReceivedAt: parseReceivedAt(receivedAt), RequestID: requestID}, ack, nil
+}
+
+// readError wraps err, or returns ctx's error if the read was cancelled.
+func (q *RedisStreamQueue) readError(ctx context.Context, op string, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return fmt.Errorf("%s: %w", op, err)
+}
+
+// retriesKey is the hash holding the stream's stored retries, by ID.
+func (q *RedisStreamQueue) retriesKey() string {
+	return q.stream + ":retries"
+}
+
+// SaveRetry implements RetryStore.
+func (q *RedisStreamQueue) SaveRetry(ctx context.Context, job models.Job, dueAt time.Time) (string, error) {
+	raw := make([]byte, 16)
+	rand.Read(raw)
+	id := hex.EncodeToString(raw)
+	data, err := json.Marshal(SnapshotJob{Payload: job.Payload, Attempts: job.Attempts, Priority: job.Priority, ReceivedAt: job.ReceivedAt, RequestID: job.RequestID, DueAt: dueAt.UTC()})
+	if err != nil {
+		return "", fmt.Errorf("encoding retry: %w", err)
+	}
+	if err := q.client.HSet(ctx, q.retriesKey(), id, data).Err(); err != nil {
+		return "", fmt.Errorf("saving retry: %w", err)
+	}
+	return id, nil
+}
+
+// DeleteRetry implements RetryStore.
+func (q *RedisStreamQueue) DeleteRetry(ctx context.Context, id string) error {
+	if err := q.client.HDel(ctx, q.retriesKey(), id).Err(); err != nil {
+		return fmt.Errorf("deleting retry: %w", err)
+	}
+	return nil
+}
+
+// Retries implements RetryStore. Entries that can't be decoded are deleted,
+// since they would otherwise be skipped at every startup.
+func (q *RedisStreamQueue) Retries(ctx context.Context) ([]StoredRetry, error) {
+	entries, err := q.client.HGetAll(ctx, q.retriesKey()).Result()
+	if err != nil {
+		return nil, fmt.Errorf("reading retries: %w", err)
+	}
+	retries := make([]StoredRetry, 0, len(entries))
+	for id, data := range entries {
+		var j SnapshotJob
+		if err := json.Unmarshal([]byte(data), &j); err != nil {
+			q.DeleteRetry(ctx, id)
+			continue
+		}
+		retries = append(retries, StoredRetry{
+			ID:    id,
+			Job:   models.Job{Payload: j.Payload, Attempts: j.Attempts, Priority: j.Priority, ReceivedAt: j.ReceivedAt, RequestID: j.RequestID},
+			DueAt: j.DueAt,
+		})
+	}
+	return retries, nil
+}
@@ -0,0 +1,111 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"gusto-webhook-guide/internal/models"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestRedisStreamQueue(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+
+	newQueue := func(consumer string) *RedisStreamQueue {
+		q := NewRedisStreamQueue(client, "jobs", "workers", consumer, 50*time.Millisecond)
+		q.block = 10 * time.Millisecond
+		if err := q.Migrate(ctx); err != nil {
+			t.Fatalf("Migrate failed: %v", err)
+		}
+		return q
+	}
+	ingest := newQueue("ingest")
+	// Migrate must be safe to run on every startup.
+	if err := ingest.Migrate(ctx); err != nil {
+		t.Fatalf("second Migrate failed: %v", err)
+	}
+	for _, payload := range []string{"a", "b"} {
+		if err := ingest.Enqueue(ctx, models.Job{Payload: []byte(payload), Attempts: 2}, 0); err != nil {
+			t.Fatalf("Enqueue failed: %v", err)
+		}
+	}
+
+	// Jobs added before a worker starts are delivered in order.
+	crashed := newQueue("crashed")
+	first, ackFirst, err := crashed.Dequeue(ctx)
+	if err != nil || string(first.Payload) != "a" || first.Attempts != 2 {
+		t.Fatalf("incorrect first job: got %+v (err %v)", first, err)
+	}
+	if err := ackFirst(); err != nil {
+		t.Fatalf("ack failed: %v", err)
+	}
+	if second, _, err := crashed.Dequeue(ctx); err != nil || string(second.Payload) != "b" {
+		t.Fatalf("incorrect second job: got %+v (err %v)", second, err)
+	}
+
+	// The second job is never acknowledged, so another consumer reclaims it
+	// once it has been idle long enough.
+	survivor := newQueue("survivor")
+	time.Sleep(60 * time.Millisecond)
+	reclaimed, ack, err := survivor.Dequeue(ctx)
+	if err != nil || string(reclaimed.Payload) != "b" {
+		t.Fatalf("incorrect reclaimed job: got %+v (err %v)", reclaimed, err)
+	}
+	if err := ack(); err != nil {
+		t.Fatalf("ack failed: %v", err)
+	}
+	if n, _ := client.XLen(ctx, "jobs").Result(); n != 0 {
+		t.Errorf("Expected acknowledged jobs to be deleted, stream has %d", n)
+	}
+
+	// An empty stream blocks until ctx is done.
+	timeout, cancel := context.WithTimeout(ctx, 30*time.Millisecond)
+	defer cancel()
+	if _, _, err := survivor.Dequeue(timeout); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("incorrect error for an empty stream: got %v want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestRedisStreamQueueRetries(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+	q := NewRedisStreamQueue(client, "jobs", "workers", "worker", time.Minute)
+
+	dueAt := time.Now().Add(5 * time.Minute).UTC().Truncate(time.Millisecond)
+	receivedAt := time.Now().UTC().Truncate(time.Millisecond)
+	id, err := q.SaveRetry(ctx, models.Job{Payload: []byte("a"), Attempts: 3, ReceivedAt: receivedAt, RequestID: "req-1"}, dueAt)
+	if err != nil {
+		t.Fatalf("SaveRetry failed: %v", err)
+	}
+	server.HSet("jobs:retries", "corrupt", "not json")
+
+	retries, err := q.Retries(ctx)
+	if err != nil || len(retries) != 1 {
+		t.Fatalf("incorrect retries: got %+v (err %v)", retries, err)
+	}
+	r := retries[0]
+	if r.ID != id || string(r.Job.Payload) != "a" || r.Job.Attempts != 3 || !r.Job.ReceivedAt.Equal(receivedAt) || r.Job.RequestID != "req-1" || !r.DueAt.Equal(dueAt) {
+		t.Errorf("incorrect stored retry: got %+v", r)
+	}
+	if server.HGet("jobs:retries", "corrupt") != "" {
+		t.Errorf("Expected the undecodable retry to be deleted")
+	}
+
+	if err := q.DeleteRetry(ctx, id); err != nil {
+		t.Fatalf("DeleteRetry failed: %v", err)
+	}
+	if err := q.DeleteRetry(ctx, id); err != nil {
+		t.Errorf("deleting an unknown retry failed: %v", err)
+	}
+	if retries, _ := q.Retries(ctx); len(retries) != 0 {
+		t.Errorf("incorrect retries after delete: got %+v", retries)
+	}
+}
@@ -0,0 +1,223 @@
+package worker
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// setIfAbsentScript writes the record hash (field/value pairs in ARGV[2:])
+// only if the key does not exist or holds a pending claim, applying the TTL
+// (ARGV[1], in milliseconds) in the same atomic step.
+var setIfAbsentScript = redis.NewScript(`
+local status = redis.call("HGET", KEYS[1], "status")
+if status and status ~= "pending" then
+	return 0
+end
+redis.call("DEL", KEYS[1])
+redis.call("HSET", KEYS[1], unpack(ARGV, 2))
+if tonumber(ARGV[1]) > 0 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return 1
+`)
+
+// recoverScript marks a claim pending (ARGV[1] is the error to record and
+// ARGV[2] the time) if it is still processing, keeping its TTL.
+var recoverScript = redis.NewScript(`
+if redis.call("HGET", KEYS[1], "status") ~= "processing" then
+	return 0
+end
+redis.call("HSET", KEYS[1], "status", "pending", "last_error", ARGV[1], "processed_at", ARGV[2])
+return 1
+`)
+
+// RedisStore is a Store backed by Redis, shared by every replica so that a
+// retried delivery landing on a different instance is still deduplicated.
+type RedisStore struct {
+	client redis.UniversalClient
+	prefix string
+	ttl    time.Duration
+}
+
+var (
+	_ Store     = (*RedisStore)(nil)
+	_ Recoverer = (*RedisStore)(nil)
+	_ Lister    = (*RedisStore)(nil)
+)
+
+// NewRedisStore creates a RedisStore. Keys are namespaced with prefix and
+// expire after ttl; a zero ttl keeps them forever.
+func NewRedisStore(client redis.UniversalClient, prefix string, ttl time.Duration) *RedisStore {
+	return &RedisStore{
+		client: client,
+		prefix: prefix,
+		ttl:    ttl,
+	}
+}
+
+// Has reports whether a key (event UUID) has a recorded outcome.
+func (s *RedisStore) Has(ctx context.Context, key string) (bool, error) {
+	status, err := s.client.HGet(ctx, s.prefix+key, "status").Result()
+	if err == redis.Nil {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("querying idempotency key: %w", err)
+	}
+	return Status(status).final(), nil
+}
+
+// Get returns the record for a key (event UUID).
+func (s *RedisStore) Get(ctx context.Context, key string) (Record, bool, error) {
+	fields, err := s.client.HGetAll(ctx, s.prefix+key).Result()
+	if err != nil {
+		return Record{}, false, fmt.Errorf("reading idempotency key: %w", err)
+	}
+	if len(fields) == 0 {
+		return Record{}, false, nil
+	}
+	return recordFromHash(fields), true, nil
+}
+
+// Set records the outcome for a key (event UUID), overwriting any prior outcome.
+func (s *RedisStore) Set(ctx context.Context, key string, rec Record) error {
+	redisKey := s.prefix + key
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, redisKey, recordFields(rec)...)
+		if s.ttl > 0 {
+			pipe.Expire(ctx, redisKey, s.ttl)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("recording idempotency key: %w", err)
+	}
+	return nil
+}
+
+// SetIfAbsent stores a key (event UUID) unless it already exists. Because the
+// check and write happen in one script, only one replica can claim an event.
+func (s *RedisStore) SetIfAbsent(ctx context.Context, key string, rec Record) (bool, error) {
+	args := append([]any{s.ttl.Milliseconds()}, recordFields(rec)...)
+	stored, err := setIfAbsentScript.Run(ctx, s.client, []string{s.prefix + key}, args...).Int()
+	if err != nil {
+		return false, fmt.Errorf("claiming idempotency key: %w", err)
+	}
+	return stored == 1, nil
+}
+
+// Delete removes a key (event UUID) from the store.
+func (s *RedisStore) Delete(ctx context.Context, key string) error {
+	if err := s.client.Del(ctx, s.prefix+key).Err(); err != nil {
+		return fmt.Errorf("deleting idempotency key: %w", err)
+	}
+	return nil
+}
+
+// List returns a page of keys in SCAN order, which is not sorted. The cursor
+// is Redis's SCAN cursor, and a page may hold somewhat more than opts.Limit
+// entries.
+func (s *RedisStore) List(ctx context.Context, opts ListOptions) ([]Entry, string, error) {
+	cursor, err := strconv.ParseUint(cmp.Or(opts.Cursor, "0"), 10, 64)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid cursor %q", opts.Cursor)
+	}
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = math.MaxInt
+	}
+
+	var entries []Entry
+	for {
+		keys, nextCursor, err := s.client.Scan(ctx, cursor, s.prefix+"*", int64(min(limit, 1000))).Result()
+		if err != nil {
+			return nil, "", fmt.Errorf("listing idempotency keys: %w", err)
+		}
+		for _, key := range keys {
+			fields, err := s.client.HGetAll(ctx, key).Result()
+			if err != nil {
+				return nil, "", fmt.Errorf("reading idempotency key: %w", err)
+			}
+			rec := recordFromHash(fields)
+			if len(fields) == 0 || (opts.EventType != "" && rec.EventType != opts.EventType) {
+				continue
+			}
+			entries = append(entries, Entry{Key: strings.TrimPrefix(key, s.prefix), Record: rec})
+		}
+
+		cursor = nextCursor
+		if cursor == 0 {
+			return entries, "", nil
+		}
+		if len(entries) >= limit {
+			return entries, strconv.FormatUint(cursor, 10), nil
+		}
+	}
+}
+
+// RecoverStale resets claims taken before cutoff that never recorded an
+// outcome. It scans every key under the store's prefix, so it is meant to run
+// once at startup.
+func (s *RedisStore) RecoverStale(ctx context.Context, cutoff time.Time) (int, error) {
+	recovered := 0
+	iter := s.client.Scan(ctx, 0, s.prefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		fields, err := s.client.HMGet(ctx, iter.Val(), "status", "claimed_at").Result()
+		if err != nil {
+			return recovered, fmt.Errorf("reading claim: %w", err)
+		}
+		if status, _ := fields[0].(string); status != string(StatusProcessing) {
+			continue
+		}
+		claimedAt, _ := fields[1].(string)
+		if t, err := time.Parse(time.RFC3339Nano, claimedAt); err == nil && !t.Before(cutoff) {
+			continue
+		}
+
+		now := time.Now().UTC().Format(time.RFC3339Nano)
+		reset, err := recoverScript.Run(ctx, s.client, []string{iter.Val()}, abandonedClaimError, now).Int()
+		if err != nil {
+			return recovered, fmt.Errorf("resetting stale claim: %w", err)
+		}
+		recovered += reset
+	}
+	if err := iter.Err(); err != nil {
+		return recovered, fmt.Errorf("scanning for stale claims: %w", err)
+	}
+	return recovered, nil
+}
+
+// recordFromHash parses a Redis hash written by recordFields.
+func recordFromHash(fields map[string]string) Record {
+	rec := Record{
+		EventType: fields["event_type"],
+		Status:    Status(fields["status"]),
+		LastError: fields["last_error"],
+	}
+	rec.Attempts, _ = strconv.Atoi(fields["attempts"])
+	rec.ClaimedAt, _ = time.Parse(time.RFC3339Nano, fields["claimed_at"])
+	rec.ProcessedAt, _ = time.Parse(time.RFC3339Nano, fields["processed_at"])
+	return rec
+}
+
+// recordFields flattens a Record into Redis hash field/value pairs.
+func recordFields(rec Record) []any {
+	fields := []any{
+		"event_type", rec.EventType,
+		"status", string(rec.Status),
+		"attempts", rec.Attempts,
+		"last_error", rec.LastError,
+		"processed_at", rec.ProcessedAt.Format(time.RFC3339Nano),
+	}
+	if !rec.ClaimedAt.IsZero() {
+		fields = append(fields, "claimed_at", rec.ClaimedAt.Format(time.RFC3339Nano))
+	}
+	return fields
+}
@@ -0,0 +1,174 @@
+package worker
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestRedisStore(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+
+	store := NewRedisStore(client, "idem:", time.Hour)
+	key := "redis-uuid-123"
+
+	if found, err := store.Has(ctx, key); err != nil || found {
+		t.Fatalf("Expected Has(%q) to be false, got %v (err %v)", key, found, err)
+	}
+
+	// Only the first claim succeeds, and a claim is not an outcome.
+	claim := Record{EventType: "company.updated", Status: StatusProcessing, ProcessedAt: time.Now().UTC()}
+	if ok, err := store.SetIfAbsent(ctx, key, claim); err != nil || !ok {
+		t.Fatalf("Expected first SetIfAbsent to succeed, got %v (err %v)", ok, err)
+	}
+	if ok, _ := store.SetIfAbsent(ctx, key, claim); ok {
+		t.Errorf("Expected second SetIfAbsent to fail")
+	}
+	if found, _ := store.Has(ctx, key); found {
+		t.Errorf("Expected Has(%q) to be false while only claimed", key)
+	}
+	if ttl := server.TTL("idem:" + key); ttl != time.Hour {
+		t.Errorf("incorrect claim TTL: got %v want %v", ttl, time.Hour)
+	}
+
+	// Releasing the claim allows it to be taken again.
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if ok, _ := store.SetIfAbsent(ctx, key, claim); !ok {
+		t.Errorf("Expected SetIfAbsent to succeed after release")
+	}
+
+	rec := Record{EventType: "company.updated", Status: StatusSucceeded, ProcessedAt: time.Now().UTC()}
+	if err := store.Set(ctx, key, rec); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if found, err := store.Has(ctx, key); err != nil || !found {
+		t.Fatalf("Expected Has(%q) to be true, got %v (err %v)", key, found, err)
+	}
+
+	if got := server.HGet("idem:"+key, "status"); got != string(StatusSucceeded) {
+		t.Errorf("incorrect status stored: got %q want %q", got, StatusSucceeded)
+	}
+
+	// Get round-trips every recorded field.
+	failed := Record{
+		EventType:   "company.updated",
+		Status:      StatusPermanentFailure,
+		Attempts:    2,
+		LastError:   "permanent error: validation failed",
+		ClaimedAt:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		ProcessedAt: time.Date(2024, 1, 1, 0, 0, 1, 0, time.UTC),
+	}
+	if err := store.Set(ctx, "failed-uuid", failed); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	got, found, err := store.Get(ctx, "failed-uuid")
+	if err != nil || !found {
+		t.Fatalf("Expected Get to succeed, got found=%v err=%v", found, err)
+	}
+	if !got.ClaimedAt.Equal(failed.ClaimedAt) || !got.ProcessedAt.Equal(failed.ProcessedAt) {
+		t.Errorf("incorrect timestamps: got %+v want %+v", got, failed)
+	}
+	got.ClaimedAt, got.ProcessedAt = failed.ClaimedAt, failed.ProcessedAt
+	if got != failed {
+		t.Errorf("incorrect record: got %+v want %+v", got, failed)
+	}
+
+	// Keys expire after the configured TTL.
+	server.FastForward(time.Hour + time.Second)
+	if found, _ := store.Has(ctx, key); found {
+		t.Errorf("Expected key %q to have expired", key)
+	}
+}
+
+func TestRedisStoreRecoverStale(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+
+	store := NewRedisStore(client, "idem:", time.Hour)
+	cutoff := time.Now().UTC().Add(-10 * time.Minute)
+	store.Set(ctx, "stale", Record{Status: StatusProcessing, Attempts: 2, ClaimedAt: cutoff.Add(-time.Minute)})
+	store.Set(ctx, "fresh", Record{Status: StatusProcessing, Attempts: 1, ClaimedAt: cutoff.Add(time.Minute)})
+	store.Set(ctx, "done", Record{Status: StatusSucceeded, ClaimedAt: cutoff.Add(-time.Hour)})
+
+	recovered, err := store.RecoverStale(ctx, cutoff)
+	if err != nil {
+		t.Fatalf("RecoverStale failed: %v", err)
+	}
+	if recovered != 1 {
+		t.Errorf("incorrect recovered count: got %d want 1", recovered)
+	}
+
+	rec, _, _ := store.Get(ctx, "stale")
+	if rec.Status != StatusPending || rec.Attempts != 2 || rec.LastError == "" {
+		t.Errorf("stale claim not reset to pending: %+v", rec)
+	}
+	if ttl := server.TTL("idem:stale"); ttl != time.Hour {
+		t.Errorf("recovery should keep the TTL: got %v want %v", ttl, time.Hour)
+	}
+	if rec, _, _ := store.Get(ctx, "fresh"); rec.Status != StatusProcessing {
+		t.Errorf("fresh claim should be left alone, got %q", rec.Status)
+	}
+
+	// A pending key can be claimed again, but isn't an outcome.
+	if found, _ := store.Has(ctx, "stale"); found {
+		t.Errorf("Expected Has to be false for a pending key")
+	}
+	claim := Record{Status: StatusProcessing, Attempts: 3, ClaimedAt: time.Now().UTC()}
+	if ok, err := store.SetIfAbsent(ctx, "stale", claim); err != nil || !ok {
+		t.Errorf("Expected SetIfAbsent to claim a pending key, got %v (err %v)", ok, err)
+	}
+	if rec, _, _ := store.Get(ctx, "stale"); rec.Attempts != 3 || rec.LastError != "" {
+		t.Errorf("claim should replace the pending record: %+v", rec)
+	}
+}
+
+func TestRedisStoreList(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+
+	store := NewRedisStore(client, "idem:", time.Hour)
+	for i := range 25 {
+		eventType := "company.updated"
+		if i%5 == 0 {
+			eventType = "payroll.processed"
+		}
+		store.Set(ctx, "key-"+strconv.Itoa(i), Record{EventType: eventType, Status: StatusSucceeded})
+	}
+	client.Set(ctx, "unrelated", "value", 0) // Outside the prefix.
+
+	// Walking every page visits each key exactly once.
+	seen := make(map[string]bool)
+	cursor := ""
+	for {
+		entries, next, err := store.List(ctx, ListOptions{EventType: "payroll.processed", Cursor: cursor, Limit: 2})
+		if err != nil {
+			t.Fatalf("List failed: %v", err)
+		}
+		for _, e := range entries {
+			if e.EventType != "payroll.processed" || seen[e.Key] {
+				t.Errorf("unexpected entry %+v", e)
+			}
+			seen[e.Key] = true
+		}
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+	if len(seen) != 5 {
+		t.Errorf("incorrect number of listed keys: got %d want 5", len(seen))
+	}
+}
@@ -0,0 +1,187 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"gusto-webhook-guide/internal/models"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// UnregisteredPolicy is what a Registry does with events no handler is
+// registered for.
+type UnregisteredPolicy string
+
+const (
+	// IgnoreUnregistered succeeds without a trace.
+	IgnoreUnregistered UnregisteredPolicy = "ignore"
+	// LogUnregistered succeeds, but the event is logged and counted as
+	// unhandled. It is the default.
+	LogUnregistered UnregisteredPolicy = "log"
+	// DeadLetterUnregistered dead-letters the event, so it can be redriven
+	// once a handler exists.
+	DeadLetterUnregistered UnregisteredPolicy = "dead_letter"
+)
+
+// ParseUnregisteredPolicy parses "ignore", "log" or "dead_letter". An empty
+// string is LogUnregistered.
+func ParseUnregisteredPolicy(s string) (UnregisteredPolicy, error) {
+	switch policy := UnregisteredPolicy(s); policy {
+	case "":
+		return LogUnregistered, nil
+	case IgnoreUnregistered, LogUnregistered, DeadLetterUnregistered:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unregistered event policy %q: want %q, %q or %q",
+			s, IgnoreUnregistered, LogUnregistered, DeadLetterUnregistered)
+	}
+}
+
+// Registry is a Processor that routes each event to the handler registered
+// for its type. Handlers may be registered for an exact event type, e.g.
+// "employee.created", or a wildcard, e.g. "company.*" for every event type
+// starting with "company.", or "*" for every event type. An exact match is
+// preferred, then the longest wildcard.
+type Registry struct {
+	mu            sync.RWMutex
+	handlers      map[string]JobHandler
+	batchHandlers map[string]BatchHandler
+	unregistered  UnregisteredPolicy
+}
+
+var (
+	_ Processor      = (*Registry)(nil)
+	_ BatchProcessor = (*Registry)(nil)
+)
+
+// NewRegistry creates a Registry with no handlers, which logs unregistered
+// event types.
+func NewRegistry() *Registry {
+	return &Registry{
+		handlers:      make(map[string]JobHandler),
+		batchHandlers: make(map[string]BatchHandler),
+		unregistered:  LogUnregistered,
+	}
+}
+
+// On registers fn for pattern, replacing any handler already registered for
+// it.
+func (r *Registry) On(pattern string, fn JobHandler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers[pattern] = fn
+}
+
+// OnBatch registers fn for pattern, to process the events of a batch that
+// match it together when the pool batches jobs (see Pool.SetBatching).
+// Without batching, or for events no batch handler matches, the handler
+// registered with On is used.
+func (r *Registry) OnBatch(pattern string, fn BatchHandler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.batchHandlers[pattern] = fn
+}
+
+// SetUnregisteredPolicy sets what happens to events no handler matches.
+func (r *Registry) SetUnregisteredPolicy(policy UnregisteredPolicy) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.unregistered = policy
+}
+
+// Handler returns the handler for eventType, if one matches.
+func (r *Registry) Handler(eventType string) (JobHandler, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	pattern, ok := match(r.handlers, eventType)
+	return r.handlers[pattern], ok
+}
+
+// match returns the key of handlers that best matches eventType: the exact
+// type, then "a.b.*", "a.*" and "*" for "a.b.c".
+func match[H any](handlers map[string]H, eventType string) (string, bool) {
+	if _, ok := handlers[eventType]; ok {
+		return eventType, true
+	}
+	prefix := eventType
+	for {
+		i := strings.LastIndex(prefix, ".")
+		if i < 0 {
+			break
+		}
+		prefix = prefix[:i]
+		if _, ok := handlers[prefix+".*"]; ok {
+			return prefix + ".*", true
+		}
+	}
+	_, ok := handlers["*"]
+	return "*", ok
+}
+
+// Process implements Processor, running the event's handler or applying the
+// unregistered policy.
+func (r *Registry) Process(ctx context.Context, event models.WebhookEvent) error {
+	if fn, ok := r.Handler(event.EventType); ok {
+		return fn(ctx, event)
+	}
+	r.mu.RLock()
+	policy := r.unregistered
+	r.mu.RUnlock()
+	switch policy {
+	case IgnoreUnregistered:
+		return nil
+	case DeadLetterUnregistered:
+		return Permanentf("%w: %s", ErrUnhandled, event.EventType)
+	default:
+		return fmt.Errorf("%w: %s", ErrUnhandled, event.EventType)
+	}
+}
+
+// ProcessBatch implements BatchProcessor. Events matching the same batch
+// handler pattern are passed to it together, in their original order; the
+// rest are processed one at a time as by Process.
+func (r *Registry) ProcessBatch(ctx context.Context, events []models.WebhookEvent) []error {
+	var patterns []string
+	groups := make(map[string][]int) // Indexes into events, by batch handler pattern.
+	r.mu.RLock()
+	for i, event := range events {
+		if pattern, ok := match(r.batchHandlers, event.EventType); ok {
+			if groups[pattern] == nil {
+				patterns = append(patterns, pattern)
+			}
+			groups[pattern] = append(groups[pattern], i)
+		}
+	}
+	batchHandlers := maps.Clone(r.batchHandlers)
+	r.mu.RUnlock()
+
+	errs := make([]error, len(events))
+	batched := make([]bool, len(events))
+	for _, pattern := range patterns {
+		indexes := groups[pattern]
+		group := make([]models.WebhookEvent, len(indexes))
+		for j, i := range indexes {
+			group[j] = events[i]
+			batched[i] = true
+		}
+		groupErrs := batchHandlers[pattern](ctx, group)
+		if groupErrs != nil && len(groupErrs) != len(group) {
+			// Which events failed is unknown, and another attempt won't say.
+			err := Permanentf("batch handler for %q returned %d errors for %d events", pattern, len(groupErrs), len(group))
+			groupErrs = slices.Repeat([]error{err}, len(group))
+		}
+		for j, i := range indexes {
+			if groupErrs != nil {
+				errs[i] = groupErrs[j]
+			}
+		}
+	}
+	for i, event := range events {
+		if !batched[i] {
+			errs[i] = r.Process(ctx, event)
+		}
+	}
+	return errs
+}
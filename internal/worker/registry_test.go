@@ -0,0 +1,108 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"gusto-webhook-guide/internal/models"
+	"io"
+	"log/slog"
+	"testing"
+)
+
+func TestRegistryRoutes(t *testing.T) {
+	registry := NewRegistry()
+	var got string
+	handle := func(name string) JobHandler {
+		return func(context.Context, models.WebhookEvent) error {
+			got = name
+			return nil
+		}
+	}
+	registry.On("company.updated", handle("exact"))
+	registry.On("company.*", handle("company"))
+	registry.On("payroll.*", handle("payroll"))
+	registry.On("payroll.tax.*", handle("payroll tax"))
+
+	testCases := []struct {
+		eventType string
+		expected  string
+	}{
+		{eventType: "company.updated", expected: "exact"},
+		{eventType: "company.created", expected: "company"},
+		{eventType: "company.bank_account.created", expected: "company"},
+		{eventType: "payroll.tax.filed", expected: "payroll tax"},
+		{eventType: "payroll.processed", expected: "payroll"},
+		{eventType: "employee.created", expected: ""},
+		{eventType: "company", expected: ""},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.eventType, func(t *testing.T) {
+			got = ""
+			registry.Process(context.Background(), models.WebhookEvent{EventType: tc.eventType})
+			if got != tc.expected {
+				t.Errorf("wrong handler: got %q want %q", got, tc.expected)
+			}
+		})
+	}
+
+	registry.On("*", handle("catch-all"))
+	got = ""
+	if err := registry.Process(context.Background(), models.WebhookEvent{EventType: "employee.created"}); err != nil || got != "catch-all" {
+		t.Errorf("catch-all handler not used: got %q, %v", got, err)
+	}
+}
+
+func TestRegistryUnregisteredPolicy(t *testing.T) {
+	testCases := []struct {
+		policy          UnregisteredPolicy
+		expectUnhandled bool
+		expectPermanent bool
+	}{
+		{policy: IgnoreUnregistered},
+		{policy: LogUnregistered, expectUnhandled: true},
+		{policy: DeadLetterUnregistered, expectUnhandled: true, expectPermanent: true},
+	}
+	for _, tc := range testCases {
+		t.Run(string(tc.policy), func(t *testing.T) {
+			registry := NewRegistry()
+			registry.SetUnregisteredPolicy(tc.policy)
+			err := registry.Process(context.Background(), models.WebhookEvent{EventType: "employee.created"})
+			if got := errors.Is(err, ErrUnhandled); got != tc.expectUnhandled {
+				t.Errorf("unhandled classification: got %v want %v (err %v)", got, tc.expectUnhandled, err)
+			}
+			if got := IsPermanent(err); got != tc.expectPermanent {
+				t.Errorf("permanent classification: got %v want %v (err %v)", got, tc.expectPermanent, err)
+			}
+		})
+	}
+}
+
+func TestPoolDeadLettersUnregisteredEvents(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	registry := NewRegistry()
+	registry.SetUnregisteredPolicy(DeadLetterUnregistered)
+	pool := NewPool(10, 0, logger, NewIdempotencyStore(), registry)
+	defer pool.Stop()
+
+	pool.handleJob(1, models.Job{Payload: []byte(`{"uuid":"new-type","event_type":"employee.created"}`)})
+
+	entries := pool.DeadLetters().List(DeadLetterFilter{})
+	if len(entries) != 1 || entries[0].Reason != ReasonUnhandled {
+		t.Fatalf("incorrect dead letters: got %+v want one with reason %q", entries, ReasonUnhandled)
+	}
+	if rec, found, _ := pool.idempotencyStore.Get(context.Background(), "new-type"); !found || rec.Status != StatusPermanentFailure {
+		t.Errorf("incorrect record: got %+v (found %v)", rec, found)
+	}
+}
+
+func TestParseUnregisteredPolicy(t *testing.T) {
+	if policy, err := ParseUnregisteredPolicy(""); err != nil || policy != LogUnregistered {
+		t.Errorf("empty policy: got %q, %v want %q", policy, err, LogUnregistered)
+	}
+	if policy, err := ParseUnregisteredPolicy("dead_letter"); err != nil || policy != DeadLetterUnregistered {
+		t.Errorf("dead_letter policy: got %q, %v", policy, err)
+	}
+	if _, err := ParseUnregisteredPolicy("drop"); err == nil {
+		t.Error("expected an error for an unknown policy")
+	}
+}
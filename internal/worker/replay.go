@@ -0,0 +1,51 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"gusto-webhook-guide/internal/models"
+)
+
+// ReplayResult is the outcome of replaying one event.
+type ReplayResult struct {
+	EventUUID string `json:"event_uuid"`
+	Status    Status `json:"status,omitempty"` // Empty if no outcome was recorded, e.g. a retry is pending.
+	LastError string `json:"last_error,omitempty"`
+}
+
+// Replay processes events again, in the order given, one at a time in the
+// calling goroutine. Each event's idempotency key is deleted first so it
+// isn't ignored as a duplicate. An event that fails with a transient error
+// is retried later as usual, so it may complete after the events following
+// it. Replay stops early when ctx is done, returning the results so far.
+func (p *Pool) Replay(ctx context.Context, payloads [][]byte) ([]ReplayResult, error) {
+	if p.ctx.Err() != nil {
+		return nil, ErrPoolStopping
+	}
+
+	results := make([]ReplayResult, 0, len(payloads))
+	for _, payload := range payloads {
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+		var event models.WebhookEvent
+		if err := json.Unmarshal(payload, &event); err != nil {
+			results = append(results, ReplayResult{LastError: err.Error()})
+			continue
+		}
+		if err := p.idempotencyStore.Delete(ctx, event.UUID); err != nil {
+			return results, fmt.Errorf("releasing idempotency key for %s: %w", event.UUID, err)
+		}
+
+		p.handleJob(0, models.Job{Payload: payload, Ctx: context.WithoutCancel(ctx)})
+
+		result := ReplayResult{EventUUID: event.UUID}
+		if rec, found, err := p.idempotencyStore.Get(ctx, event.UUID); err == nil && found {
+			result.Status = rec.Status
+			result.LastError = rec.LastError
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
@@ -0,0 +1,53 @@
+package worker
+
+import (
+	"context"
+	"gusto-webhook-guide/internal/models"
+	"io"
+	"log/slog"
+	"testing"
+)
+
+func TestReplay(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	store := NewIdempotencyStore()
+	var processed []string
+	processor := ProcessorFunc(func(ctx context.Context, event models.WebhookEvent) error {
+		processed = append(processed, event.UUID)
+		return stubProcessor(ctx, event)
+	})
+	pool := NewPool(10, 0, logger, store, processor)
+	defer pool.Stop()
+
+	// An event processed before is replayed despite its idempotency key.
+	store.Set(ctx, "a", Record{EventType: "company.created", Status: StatusSucceeded})
+
+	results, err := pool.Replay(ctx, [][]byte{
+		[]byte(`{"uuid":"a","event_type":"company.created"}`),
+		[]byte(`{"uuid":"b","event_type":"company.deleted"}`),
+		[]byte(`not json`),
+		[]byte(`{"uuid":"c","event_type":"company.updated"}`),
+	})
+	if err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+
+	expected := []ReplayResult{
+		{EventUUID: "a", Status: StatusSucceeded},
+		{EventUUID: "b", Status: StatusPermanentFailure, LastError: "permanent error: validation failed"},
+		{LastError: "invalid character 'o' in literal null (expecting 'u')"},
+		{EventUUID: "c"}, // Waiting for a retry.
+	}
+	if len(results) != len(expected) {
+		t.Fatalf("incorrect results: got %+v want %+v", results, expected)
+	}
+	for i := range results {
+		if results[i] != expected[i] {
+			t.Errorf("incorrect result %d: got %+v want %+v", i, results[i], expected[i])
+		}
+	}
+	if len(processed) != 3 || processed[0] != "a" || processed[1] != "b" || processed[2] != "c" {
+		t.Errorf("incorrect processing order: got %v want [a b c]", processed)
+	}
+}
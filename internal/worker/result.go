@@ -0,0 +1,177 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// defaultResultCapacity is how many results a MemoryResultStore keeps
+// unless told otherwise.
+const defaultResultCapacity = 10000
+
+// Artifact references something processing an event produced downstream,
+// e.g. the employee record created for an employee.created event.
+type Artifact struct {
+	Kind   string `json:"kind"`             // What was produced, e.g. "employee" or "journal_entry".
+	ID     string `json:"id"`               // Its ID in the downstream system.
+	Action string `json:"action,omitempty"` // e.g. "created", "updated" or "deleted".
+	URL    string `json:"url,omitempty"`    // Where to look it up, if anywhere.
+}
+
+// Result is what the last attempt at an event produced, kept alongside its
+// idempotency record so support can see not just that it succeeded but what
+// it did. Processors add to it with AddArtifacts and SetResultData.
+type Result struct {
+	EventType  string                     `json:"event_type"`
+	Attempt    int                        `json:"attempt"`
+	Error      string                     `json:"error,omitempty"` // Why the attempt failed, if it did.
+	Artifacts  []Artifact                 `json:"artifacts,omitempty"`
+	Data       map[string]json.RawMessage `json:"data,omitempty"` // Derived records, by name.
+	RecordedAt time.Time                  `json:"recorded_at"`
+}
+
+// ResultStore persists Results by event UUID.
+type ResultStore interface {
+	// PutResult stores an event's result, replacing any earlier one.
+	PutResult(ctx context.Context, eventUUID string, r Result) error
+	// GetResult returns the result for an event, and false if none exists.
+	GetResult(ctx context.Context, eventUUID string) (Result, bool, error)
+}
+
+type resultKey struct{}
+
+// resultCollector gathers what an attempt produced.
+type resultCollector struct {
+	mu        sync.Mutex
+	artifacts []Artifact
+	data      map[string]json.RawMessage
+}
+
+// AddArtifacts records downstream entities the attempt created or changed.
+// Processors call it with the context they were given. It does nothing if
+// the pool has no ResultStore, or for events processed in a batch.
+func AddArtifacts(ctx context.Context, artifacts ...Artifact) {
+	c, _ := ctx.Value(resultKey{}).(*resultCollector)
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.artifacts = append(c.artifacts, artifacts...)
+}
+
+// SetResultData records a record derived from the event under name,
+// replacing any the attempt set before under that name. value is encoded as
+// JSON straight away, so it may be changed afterwards. Like AddArtifacts,
+// it does nothing without a ResultStore.
+func SetResultData(ctx context.Context, name string, value any) error {
+	c, _ := ctx.Value(resultKey{}).(*resultCollector)
+	if c == nil {
+		return nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("encoding result data %q: %w", name, err)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.data == nil {
+		c.data = make(map[string]json.RawMessage)
+	}
+	c.data[name] = data
+	return nil
+}
+
+// SetResultStore sets where the results processors produce are kept. It
+// must be called before Start. Without one, AddArtifacts and SetResultData
+// do nothing.
+func (p *Pool) SetResultStore(s ResultStore) {
+	p.results = s
+}
+
+// withResult returns a copy of ctx that collects the attempt's result, if
+// the pool keeps results.
+func (p *Pool) withResult(ctx context.Context) context.Context {
+	if p.results == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, resultKey{}, &resultCollector{})
+}
+
+// saveResult stores what a claimed job's attempt produced, if anything,
+// failed attempts included, so that partial work is visible too.
+func (p *Pool) saveResult(c *claimedJob, err error) {
+	collector, _ := c.ctx.Value(resultKey{}).(*resultCollector)
+	if collector == nil {
+		return
+	}
+	collector.mu.Lock()
+	result := Result{
+		EventType:  c.event.EventType,
+		Attempt:    c.claim.Attempts,
+		Artifacts:  collector.artifacts,
+		Data:       collector.data,
+		RecordedAt: time.Now().UTC(),
+	}
+	collector.mu.Unlock()
+	if len(result.Artifacts) == 0 && len(result.Data) == 0 {
+		return
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	if err := p.results.PutResult(context.WithoutCancel(c.ctx), c.event.UUID, result); err != nil {
+		c.logger.Error("Failed to store event result", "error", err)
+	}
+}
+
+// MemoryResultStore is a ResultStore for a single process, keeping the most
+// recent results up to a capacity. Its contents are lost on restart.
+type MemoryResultStore struct {
+	mu      sync.Mutex
+	max     int
+	order   []string // Event UUIDs, oldest result first.
+	results map[string]Result
+}
+
+var _ ResultStore = (*MemoryResultStore)(nil)
+
+// NewMemoryResultStore creates a MemoryResultStore keeping up to max
+// results, or a default number if max is not positive. The oldest result is
+// evicted once the limit is reached.
+func NewMemoryResultStore(max int) *MemoryResultStore {
+	if max <= 0 {
+		max = defaultResultCapacity
+	}
+	return &MemoryResultStore{max: max, results: make(map[string]Result)}
+}
+
+// PutResult implements ResultStore.
+func (s *MemoryResultStore) PutResult(_ context.Context, eventUUID string, r Result) error {
+	if eventUUID == "" {
+		return errors.New("result has no event UUID")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, found := s.results[eventUUID]; !found {
+		if len(s.order) >= s.max {
+			delete(s.results, s.order[0])
+			s.order = s.order[1:]
+		}
+		s.order = append(s.order, eventUUID)
+	}
+	s.results[eventUUID] = r
+	return nil
+}
+
+// GetResult implements ResultStore.
+func (s *MemoryResultStore) GetResult(_ context.Context, eventUUID string) (Result, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, found := s.results[eventUUID]
+	return r, found, nil
+}
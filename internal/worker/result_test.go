@@ -0,0 +1,78 @@
+package worker
+
+import (
+	"context"
+	"gusto-webhook-guide/internal/models"
+	"io"
+	"log/slog"
+	"testing"
+)
+
+func TestPoolStoresResults(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	processor := ProcessorFunc(func(ctx context.Context, event models.WebhookEvent) error {
+		switch event.UUID {
+		case "created":
+			AddArtifacts(ctx, Artifact{Kind: "employee", ID: "emp-1", Action: "created"})
+			return SetResultData(ctx, "payroll", map[string]string{"id": "p-1"})
+		case "partial":
+			AddArtifacts(ctx, Artifact{Kind: "journal_entry", ID: "je-1", Action: "created"})
+			return Permanentf("ledger rejected the entry")
+		}
+		return nil
+	})
+	results := NewMemoryResultStore(0)
+	pool := NewPool(10, 0, logger, NewIdempotencyStore(), processor)
+	defer pool.Stop()
+	pool.SetResultStore(results)
+
+	for _, uuid := range []string{"created", "partial", "nothing"} {
+		pool.handleJob(1, models.Job{Payload: []byte(`{"uuid":"` + uuid + `","event_type":"employee.created"}`)})
+	}
+
+	r, found, _ := results.GetResult(ctx, "created")
+	if !found {
+		t.Fatal("expected a result for the created event")
+	}
+	if r.EventType != "employee.created" || r.Attempt != 1 || r.Error != "" || r.RecordedAt.IsZero() {
+		t.Errorf("incorrect result: %+v", r)
+	}
+	if len(r.Artifacts) != 1 || r.Artifacts[0].ID != "emp-1" || string(r.Data["payroll"]) != `{"id":"p-1"}` {
+		t.Errorf("incorrect artifacts or data: %+v", r)
+	}
+
+	r, found, _ = results.GetResult(ctx, "partial")
+	if !found || r.Error == "" || len(r.Artifacts) != 1 || r.Artifacts[0].ID != "je-1" {
+		t.Errorf("expected the failed attempt's artifacts and error, got %+v (found %v)", r, found)
+	}
+	if _, found, _ := results.GetResult(ctx, "nothing"); found {
+		t.Error("expected no result for an event that produced nothing")
+	}
+}
+
+func TestResultHelpersWithoutStore(t *testing.T) {
+	ctx := context.Background()
+	AddArtifacts(ctx, Artifact{Kind: "employee", ID: "emp-1"})
+	if err := SetResultData(ctx, "payroll", func() {}); err != nil {
+		t.Errorf("expected SetResultData to do nothing without a store, got %v", err)
+	}
+}
+
+func TestMemoryResultStoreEvictsOldest(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryResultStore(2)
+	for _, uuid := range []string{"a", "b", "a", "c"} {
+		if err := s.PutResult(ctx, uuid, Result{EventType: uuid}); err != nil {
+			t.Fatalf("PutResult failed: %v", err)
+		}
+	}
+	for uuid, want := range map[string]bool{"a": false, "b": true, "c": true} {
+		if _, found, _ := s.GetResult(ctx, uuid); found != want {
+			t.Errorf("result for %q: found %v want %v", uuid, found, want)
+		}
+	}
+	if err := s.PutResult(ctx, "", Result{}); err == nil {
+		t.Error("expected an error for a result without an event UUID")
+	}
+}
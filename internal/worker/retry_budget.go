@@ -0,0 +1,91 @@
+package worker
+
+import (
+	"sync"
+	"time"
+)
+
+// retryBudgetSlots is how many slots a retry budget's window is divided
+// into. Counts age out of the window a slot at a time.
+const retryBudgetSlots = 10
+
+// retryBudgetMinRetries is how many retries a budget allows per window
+// however little traffic there is, so that the odd failure during a quiet
+// period is still retried.
+const retryBudgetMinRetries = 10
+
+// SetRetryBudget caps retries at percent of the attempts made over a sliding
+// window, so a widespread transient failure doesn't multiply the load on a
+// struggling API. Once the budget is spent, jobs that would have been
+// retried are dead-lettered as retry_budget_exhausted, to be redriven once
+// the cause has passed. Retries while retries are held don't count, since
+// they wait rather than add load. Zero, the default, means no budget. It
+// must be called before Start.
+func (p *Pool) SetRetryBudget(percent int, window time.Duration) {
+	if percent <= 0 {
+		p.retryBudget = nil
+		return
+	}
+	p.retryBudget = &retryBudget{percent: percent, slot: max(window/retryBudgetSlots, 1)}
+}
+
+// retryBudget counts attempts and retries over a sliding window. A nil
+// retryBudget allows every retry.
+type retryBudget struct {
+	percent int
+	slot    time.Duration
+
+	mu    sync.Mutex
+	slots [retryBudgetSlots]budgetSlot
+}
+
+// budgetSlot holds the counts for one slot-long interval.
+type budgetSlot struct {
+	index    int64 // Which interval since the epoch the counts are for.
+	attempts int
+	retries  int
+}
+
+// current returns the slot for now, reset if it still holds the counts of
+// an interval that has left the window. b.mu must be held.
+func (b *retryBudget) current(now time.Time) *budgetSlot {
+	index := now.UnixNano() / int64(b.slot)
+	s := &b.slots[index%retryBudgetSlots]
+	if s.index != index {
+		*s = budgetSlot{index: index}
+	}
+	return s
+}
+
+// attempt counts an attempt at a job.
+func (b *retryBudget) attempt(now time.Time) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.current(now).attempts++
+}
+
+// allowRetry reports whether the window has room for another retry, and if
+// so counts it.
+func (b *retryBudget) allowRetry(now time.Time) bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	index := now.UnixNano() / int64(b.slot)
+	attempts, retries := 0, 0
+	for _, s := range b.slots {
+		if index-s.index < retryBudgetSlots {
+			attempts += s.attempts
+			retries += s.retries
+		}
+	}
+	if retries >= retryBudgetMinRetries && retries*100 >= attempts*b.percent {
+		return false
+	}
+	b.current(now).retries++
+	return true
+}
@@ -0,0 +1,79 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"gusto-webhook-guide/internal/models"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestRetryBudget(t *testing.T) {
+	b := &retryBudget{percent: 20, slot: time.Second}
+	now := time.Unix(1000, 0)
+
+	// The minimum is allowed whatever the traffic.
+	for i := range retryBudgetMinRetries {
+		if !b.allowRetry(now) {
+			t.Fatalf("Expected retry %d to be allowed", i+1)
+		}
+	}
+	if b.allowRetry(now) {
+		t.Fatalf("Expected a retry past the minimum to be refused with no attempts counted")
+	}
+
+	// 60 attempts at 20% leave room for 2 more retries.
+	for range 60 {
+		b.attempt(now)
+	}
+	if !b.allowRetry(now) || !b.allowRetry(now) {
+		t.Fatalf("Expected retries within 20%% of attempts to be allowed")
+	}
+	if b.allowRetry(now) {
+		t.Fatalf("Expected a retry over 20%% of attempts to be refused")
+	}
+
+	// Once the window has passed, the counts age out.
+	if !b.allowRetry(now.Add(retryBudgetSlots * time.Second)) {
+		t.Errorf("Expected a retry to be allowed once the window has passed")
+	}
+
+	var unlimited *retryBudget
+	unlimited.attempt(now)
+	if !unlimited.allowRetry(now) {
+		t.Errorf("Expected a nil budget to allow every retry")
+	}
+}
+
+func TestPoolDeadLettersRetriesOverBudget(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	store := NewIdempotencyStore()
+	processor := ProcessorFunc(func(context.Context, models.WebhookEvent) error {
+		return Transientf("gusto unavailable")
+	})
+	pool := NewPool(100, 0, logger, store, processor)
+	defer pool.Stop()
+	pool.SetRetryBudget(20, time.Hour)
+
+	for i := range retryBudgetMinRetries + 1 {
+		pool.handleJob(1, models.Job{Payload: fmt.Appendf(nil, `{"uuid":"e%d","event_type":"company.updated"}`, i)})
+	}
+
+	entries := pool.DeadLetters().List(DeadLetterFilter{})
+	want := fmt.Sprintf("e%d", retryBudgetMinRetries)
+	if len(entries) != 1 || entries[0].EventUUID != want || entries[0].Reason != ReasonRetryBudget {
+		t.Fatalf("incorrect dead letters: got %+v want %s dead-lettered for the retry budget", entries, want)
+	}
+	if rec, _, _ := store.Get(ctx, want); rec.Status != StatusDeadLettered {
+		t.Errorf("incorrect status for event over budget: got %q want %q", rec.Status, StatusDeadLettered)
+	}
+	pool.retriesMu.Lock()
+	scheduled := len(pool.retries)
+	pool.retriesMu.Unlock()
+	if scheduled != retryBudgetMinRetries {
+		t.Errorf("incorrect number of retries scheduled: got %d want %d", scheduled, retryBudgetMinRetries)
+	}
+}
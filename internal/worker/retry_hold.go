@@ -0,0 +1,82 @@
+package worker
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+	"time"
+)
+
+// RetryHold is a reason scheduled retries are being held back.
+type RetryHold struct {
+	// Source is who placed the hold, e.g. "manual" or "status_page".
+	Source string    `json:"source"`
+	Reason string    `json:"reason,omitempty"`
+	Since  time.Time `json:"since"`
+}
+
+// HoldRetries stops scheduled retries being re-queued, e.g. while the
+// provider has declared an outage, so that events failing against it wait
+// rather than fail again. Transient failures while retries are held don't
+// use up the event's attempts, so no event is dead-lettered for an outage.
+// Holds are per source: holding again from the same source only updates
+// its reason, and retries resume once every source has released its hold.
+func (p *Pool) HoldRetries(source, reason string) {
+	p.holdRetries(RetryHold{Source: source, Reason: reason, Since: time.Now().UTC()})
+}
+
+// holdRetries places hold, keeping the time of any hold already placed by
+// its source, and returns the hold in force.
+func (p *Pool) holdRetries(hold RetryHold) RetryHold {
+	p.retriesMu.Lock()
+	defer p.retriesMu.Unlock()
+	if held, found := p.retryHolds[hold.Source]; found {
+		held.Reason = hold.Reason
+		p.retryHolds[hold.Source] = held
+		return held
+	}
+	if p.retryHolds == nil {
+		p.retryHolds = make(map[string]RetryHold)
+	}
+	p.retryHolds[hold.Source] = hold
+	retriesHeld.Set(1)
+	p.logger.Warn("Retries held", "source", hold.Source, "reason", hold.Reason, "scheduled", len(p.retries))
+	return hold
+}
+
+// ReleaseRetries releases source's hold on retries. Once no hold remains,
+// retries that fell due meanwhile are re-queued.
+func (p *Pool) ReleaseRetries(source string) {
+	p.retriesMu.Lock()
+	hold, found := p.retryHolds[source]
+	delete(p.retryHolds, source)
+	remaining := len(p.retryHolds)
+	p.retriesMu.Unlock()
+	if !found {
+		return
+	}
+	p.logger.Info("Retry hold released", "source", source, "held_for", time.Since(hold.Since), "remaining_holds", remaining)
+	if remaining == 0 {
+		retriesHeld.Set(0)
+		select {
+		case p.retryWake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// RetryHolds returns the holds on retries, by source.
+func (p *Pool) RetryHolds() []RetryHold {
+	p.retriesMu.Lock()
+	defer p.retriesMu.Unlock()
+	return slices.SortedFunc(maps.Values(p.retryHolds), func(a, b RetryHold) int {
+		return cmp.Compare(a.Source, b.Source)
+	})
+}
+
+// retriesHeldNow reports whether any source is holding retries.
+func (p *Pool) retriesHeldNow() bool {
+	p.retriesMu.Lock()
+	defer p.retriesMu.Unlock()
+	return len(p.retryHolds) > 0
+}
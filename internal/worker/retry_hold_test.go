@@ -0,0 +1,56 @@
+package worker
+
+import (
+	"context"
+	"gusto-webhook-guide/internal/models"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestHoldRetries(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	processor := ProcessorFunc(func(context.Context, models.WebhookEvent) error {
+		return Transientf("gusto is down")
+	})
+	pool := NewPool(10, 0, logger, NewIdempotencyStore(), processor)
+	defer pool.Stop()
+
+	pool.HoldRetries("status_page", "major outage")
+	pool.HoldRetries("manual", "")
+	pool.handleJob(1, models.Job{Payload: []byte(`{"uuid":"a","event_type":"company.updated"}`), Attempts: maxRetries - 1})
+
+	// The failure didn't use up the last attempt, so the event is scheduled
+	// rather than dead-lettered, but it isn't re-queued while held.
+	if got := pool.DeadLetters().List(DeadLetterFilter{}); len(got) != 0 {
+		t.Fatalf("event dead-lettered while retries were held: %+v", got)
+	}
+	pool.retriesMu.Lock()
+	scheduled := len(pool.retries)
+	pool.retries[0].dueAt = time.Now() // Due straight away once released.
+	pool.retriesMu.Unlock()
+	if scheduled != 1 {
+		t.Fatalf("incorrect scheduled retries: got %d want 1", scheduled)
+	}
+
+	pool.ReleaseRetries("status_page")
+	if holds := pool.RetryHolds(); len(holds) != 1 || holds[0].Source != "manual" {
+		t.Errorf("incorrect holds after releasing one: got %+v", holds)
+	}
+	select {
+	case job := <-pool.JobQueue:
+		t.Fatalf("retry re-queued while still held: %+v", job)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	pool.ReleaseRetries("manual")
+	select {
+	case job := <-pool.JobQueue:
+		if job.Attempts != maxRetries-1 {
+			t.Errorf("incorrect attempts: got %d want %d", job.Attempts, maxRetries-1)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("retry was not re-queued after the holds were released")
+	}
+}
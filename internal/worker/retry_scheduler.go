@@ -0,0 +1,208 @@
+package worker
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"gusto-webhook-guide/internal/models"
+	"log/slog"
+	"time"
+)
+
+// scheduledRetry is a job waiting for its retry delay.
+type scheduledRetry struct {
+	job     models.Job
+	dueAt   time.Time
+	logger  *slog.Logger
+	storeID string // Its ID in the queue's RetryStore, if it was saved there.
+}
+
+// retryHeap is a min-heap of scheduled retries ordered by due time. It
+// implements heap.Interface.
+type retryHeap []*scheduledRetry
+
+func (h retryHeap) Len() int           { return len(h) }
+func (h retryHeap) Less(i, j int) bool { return h[i].dueAt.Before(h[j].dueAt) }
+func (h retryHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *retryHeap) Push(x any)        { *h = append(*h, x.(*scheduledRetry)) }
+func (h *retryHeap) Pop() any {
+	old := *h
+	r := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return r
+}
+
+// scheduleRetry arranges for job to be pushed back onto the queue once delay
+// has elapsed. If the queue is a RetryStore the retry is saved there too,
+// or kept only in memory if that fails.
+func (p *Pool) scheduleRetry(job models.Job, delay time.Duration, logger *slog.Logger) {
+	r := &scheduledRetry{job: job, dueAt: time.Now().Add(delay).UTC(), logger: logger}
+	if store, ok := p.queue.(RetryStore); ok {
+		id, err := store.SaveRetry(context.Background(), job, r.dueAt)
+		if err != nil {
+			logger.Error("Failed to save retry, it will be lost if the process restarts", "error", err)
+		}
+		r.storeID = id
+	}
+	p.pushRetry(r)
+}
+
+// pushRetry adds r to the retry heap.
+func (p *Pool) pushRetry(r *scheduledRetry) {
+	p.retriesMu.Lock()
+	heap.Push(&p.retries, r)
+	p.retriesMu.Unlock()
+
+	// Wake the scheduler in case this retry is now the earliest.
+	select {
+	case p.retryWake <- struct{}{}:
+	default:
+	}
+}
+
+// runRetries is the single goroutine that re-enqueues retries as they fall
+// due. Stop waits for it to return before closing the queue, so it never
+// sends on a closed channel. Retries still waiting
+// when the pool stops are abandoned, unless Stop spills them; their claims
+// were released, so the provider's redelivery is processed normally.
+func (p *Pool) runRetries() {
+	defer close(p.retriesDone)
+
+	timer := time.NewTimer(0)
+	timer.Stop()
+	for {
+		r, wait := p.nextRetry()
+		if r != nil {
+			if !p.deliverRetry(r) {
+				p.abandonRetries()
+				return
+			}
+			continue
+		}
+
+		if wait > 0 {
+			timer.Reset(wait)
+		}
+		select {
+		case <-timer.C:
+		case <-p.retryWake:
+		case <-p.ctx.Done():
+			p.abandonRetries()
+			return
+		}
+		timer.Stop()
+	}
+}
+
+// nextRetry pops the earliest retry if it is due. Otherwise it returns how
+// long until it will be, or zero if nothing is scheduled or retries are held.
+func (p *Pool) nextRetry() (*scheduledRetry, time.Duration) {
+	p.retriesMu.Lock()
+	defer p.retriesMu.Unlock()
+	if len(p.retries) == 0 || len(p.retryHolds) > 0 {
+		return nil, 0
+	}
+	if wait := time.Until(p.retries[0].dueAt); wait > 0 {
+		return nil, wait
+	}
+	return heap.Pop(&p.retries).(*scheduledRetry), 0
+}
+
+// deliverRetry pushes a due retry onto the queue, waiting for room if it is
+// full. It returns false if the pool stopped first.
+func (p *Pool) deliverRetry(r *scheduledRetry) bool {
+	if err := r.job.Context().Err(); err != nil {
+		r.logger.Warn("Retry abandoned, job context was cancelled", "error", err)
+		p.forgetRetry(r)
+		return true
+	}
+	err := p.queue.Enqueue(p.ctx, r.job, -1)
+	switch {
+	case err == nil:
+		p.forgetRetry(r)
+		return true
+	case p.ctx.Err() != nil:
+		if r.storeID != "" {
+			r.logger.Info("Retry left in the queue's retry store for the next startup")
+		} else if p.spillPath != "" {
+			p.retriesMu.Lock()
+			heap.Push(&p.retries, r) // Spilled by Stop.
+			p.retriesMu.Unlock()
+		} else {
+			r.logger.Warn("Retry abandoned, worker pool is stopping")
+		}
+		return false
+	default:
+		// The claim was released, so a redelivery is still processed.
+		r.logger.Error("Retry abandoned, failed to re-queue job", "error", err)
+		p.forgetRetry(r)
+		return true
+	}
+}
+
+// forgetRetry removes a retry that was delivered or abandoned from the
+// queue's RetryStore.
+func (p *Pool) forgetRetry(r *scheduledRetry) {
+	if r.storeID == "" {
+		return
+	}
+	if err := p.queue.(RetryStore).DeleteRetry(context.Background(), r.storeID); err != nil {
+		// It is loaded again at the next startup; the idempotency store
+		// drops the duplicate if the event has been processed by then.
+		r.logger.Error("Failed to delete delivered retry from the retry store", "error", err)
+	}
+}
+
+// abandonRetries drops every retry still scheduled, unless Stop is going to
+// spill them. Those saved in the queue's RetryStore are kept there for
+// LoadRetries.
+func (p *Pool) abandonRetries() {
+	if p.spillPath != "" {
+		return
+	}
+	p.retriesMu.Lock()
+	defer p.retriesMu.Unlock()
+	abandoned := 0
+	for _, r := range p.retries {
+		if r.storeID == "" {
+			abandoned++
+		}
+	}
+	if kept := len(p.retries) - abandoned; kept > 0 {
+		p.logger.Info("Retries left in the queue's retry store for the next startup", "count", kept)
+	}
+	if abandoned > 0 {
+		p.logger.Warn("Retries abandoned, worker pool is stopping", "count", abandoned)
+	}
+	p.retries = nil
+}
+
+// LoadRetries rebuilds the retry schedule from the queue's RetryStore,
+// so that retries scheduled before a restart still happen, when they were
+// due or straight away if that has passed. It returns how many were loaded;
+// queues that aren't a RetryStore have none. Call it once at startup, after
+// SetQueue.
+//
+// Replicas sharing a queue each load every stored retry, including those
+// another running replica is waiting on, so a retry may be queued twice;
+// the idempotency store drops the duplicate.
+func (p *Pool) LoadRetries(ctx context.Context) (int, error) {
+	store, ok := p.queue.(RetryStore)
+	if !ok {
+		return 0, nil
+	}
+	stored, err := store.Retries(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("loading retries: %w", err)
+	}
+	for _, sr := range stored {
+		p.pushRetry(&scheduledRetry{
+			job:     sr.Job,
+			dueAt:   sr.DueAt,
+			logger:  p.logger.With("request_id", sr.Job.RequestID, "stored_retry_id", sr.ID),
+			storeID: sr.ID,
+		})
+	}
+	return len(stored), nil
+}
@@ -0,0 +1,160 @@
+package worker
+
+import (
+	"context"
+	"gusto-webhook-guide/internal/models"
+	"io"
+	"log/slog"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRetrySchedulerOrder(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	pool := NewPool(10, 0, logger, NewIdempotencyStore(), stubProcessor)
+	defer pool.Stop()
+
+	pool.scheduleRetry(models.Job{Payload: []byte("c")}, 60*time.Millisecond, logger)
+	pool.scheduleRetry(models.Job{Payload: []byte("a")}, 20*time.Millisecond, logger)
+	pool.scheduleRetry(models.Job{Payload: []byte("b")}, 40*time.Millisecond, logger)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	pool.scheduleRetry(models.Job{Payload: []byte("cancelled"), Ctx: ctx}, 0, logger)
+
+	for _, want := range []string{"a", "b", "c"} {
+		select {
+		case job := <-pool.JobQueue:
+			if string(job.Payload) != want {
+				t.Errorf("incorrect retry order: got %q want %q", job.Payload, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("retry %q was not re-queued", want)
+		}
+	}
+	if got := len(pool.JobQueue); got != 0 {
+		t.Errorf("Expected the cancelled job to be dropped, queue has %d jobs", got)
+	}
+}
+
+func TestStopAbandonsRetries(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	pool := NewPool(1, 0, logger, NewIdempotencyStore(), stubProcessor)
+
+	// One retry is blocked on a full queue and another is far off; neither
+	// may keep Stop waiting or send on the closed queue.
+	pool.JobQueue <- models.Job{}
+	pool.scheduleRetry(models.Job{}, 0, logger)
+	pool.scheduleRetry(models.Job{}, time.Hour, logger)
+	waitFor(t, func() bool {
+		pool.retriesMu.Lock()
+		defer pool.retriesMu.Unlock()
+		return len(pool.retries) == 1
+	})
+
+	stopped := make(chan struct{})
+	go func() {
+		pool.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatalf("Stop did not return")
+	}
+	if got := len(pool.retries); got != 0 {
+		t.Errorf("incorrect retries left after Stop: got %d want 0", got)
+	}
+}
+
+// retryStoreQueue is a ChannelQueue that also keeps retries in memory, as a
+// durable queue would.
+type retryStoreQueue struct {
+	ChannelQueue
+	mu      sync.Mutex
+	nextID  int
+	retries map[string]StoredRetry
+}
+
+func (q *retryStoreQueue) SaveRetry(_ context.Context, job models.Job, dueAt time.Time) (string, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.nextID++
+	id := strconv.Itoa(q.nextID)
+	q.retries[id] = StoredRetry{ID: id, Job: job, DueAt: dueAt}
+	return id, nil
+}
+
+func (q *retryStoreQueue) DeleteRetry(_ context.Context, id string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.retries, id)
+	return nil
+}
+
+func (q *retryStoreQueue) Retries(context.Context) ([]StoredRetry, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var retries []StoredRetry
+	for _, r := range q.retries {
+		retries = append(retries, r)
+	}
+	return retries, nil
+}
+
+func (q *retryStoreQueue) stored() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.retries)
+}
+
+func TestRetriesSurviveRestart(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	queue := &retryStoreQueue{ChannelQueue: make(ChannelQueue, 10), retries: make(map[string]StoredRetry)}
+
+	// A retry scheduled before a restart is kept by the queue.
+	before := NewPool(10, 0, logger, NewIdempotencyStore(), stubProcessor)
+	before.SetQueue(queue)
+	before.scheduleRetry(models.Job{Payload: []byte("later"), Attempts: 2, RequestID: "req-1"}, 80*time.Millisecond, logger)
+	if got := queue.stored(); got != 1 {
+		t.Fatalf("incorrect stored retries: got %d want 1", got)
+	}
+	before.Stop()
+	if got := queue.stored(); got != 1 {
+		t.Fatalf("Expected Stop to leave the stored retry, got %d", got)
+	}
+
+	// The next process rebuilds its schedule from the queue and delivers
+	// the retry once it is due.
+	after := NewPool(10, 0, logger, NewIdempotencyStore(), stubProcessor)
+	after.SetQueue(queue)
+	defer after.Stop()
+	loaded, err := after.LoadRetries(context.Background())
+	if err != nil || loaded != 1 {
+		t.Fatalf("LoadRetries: got %d, %v want 1, nil", loaded, err)
+	}
+	select {
+	case job := <-queue.ChannelQueue:
+		t.Fatalf("retry %q was delivered before it was due", job.Payload)
+	case <-time.After(20 * time.Millisecond):
+	}
+	select {
+	case job := <-queue.ChannelQueue:
+		if string(job.Payload) != "later" || job.Attempts != 2 || job.RequestID != "req-1" {
+			t.Errorf("incorrect retried job: got %+v", job)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("loaded retry was not re-queued")
+	}
+	waitFor(t, func() bool { return queue.stored() == 0 })
+}
+
+func TestLoadRetriesWithoutRetryStore(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	pool := NewPool(10, 0, logger, NewIdempotencyStore(), stubProcessor)
+	defer pool.Stop()
+	if loaded, err := pool.LoadRetries(context.Background()); err != nil || loaded != 0 {
+		t.Errorf("LoadRetries: got %d, %v want 0, nil", loaded, err)
+	}
+}
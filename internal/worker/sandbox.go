@@ -0,0 +1,103 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gusto-webhook-guide/internal/models"
+	"log/slog"
+	"runtime/debug"
+	"time"
+)
+
+var (
+	// ErrSandboxTimeout is wrapped by the transient error an attempt fails
+	// with when a sandboxed handler outlives its timeout.
+	ErrSandboxTimeout = errors.New("handler timed out")
+	// ErrSandboxBusy is wrapped by the transient error an attempt fails with
+	// when every slot of a sandboxed handler is taken.
+	ErrSandboxBusy = errors.New("handler at its concurrency limit")
+)
+
+// Sandbox limits a handler so a buggy one can't hold up the events of every
+// other handler. The zero value only isolates panics.
+type Sandbox struct {
+	// Timeout is how long an attempt may run. Unlike the pool's job timeout,
+	// which only cancels the attempt's context, the worker stops waiting for
+	// the handler once it passes, even if the handler ignores ctx. Zero
+	// leaves it to the job timeout.
+	Timeout time.Duration
+	// MaxConcurrent caps how many attempts the handler may run at once,
+	// counting timed-out attempts that haven't returned yet. An event
+	// arriving while every slot is taken is retried later rather than
+	// holding a worker. Zero means no cap.
+	MaxConcurrent int
+	// Logger, if set, logs panics with their stack trace.
+	Logger *slog.Logger
+}
+
+// OnSandboxed registers fn for pattern like On, running it within sb's
+// limits. A panic in fn fails the attempt with ErrHandlerPanic without
+// crashing the process. Attempts rejected by the sandbox are counted in
+// webhook_worker_sandbox_rejections_total by pattern.
+func (r *Registry) OnSandboxed(pattern string, sb Sandbox, fn JobHandler) {
+	r.On(pattern, sb.wrap(pattern, fn))
+}
+
+// wrap returns fn confined to the sandbox, labelled name in metrics.
+func (sb Sandbox) wrap(name string, fn JobHandler) JobHandler {
+	var slots chan struct{}
+	if sb.MaxConcurrent > 0 {
+		slots = make(chan struct{}, sb.MaxConcurrent)
+	}
+	return func(ctx context.Context, event models.WebhookEvent) error {
+		if slots != nil {
+			select {
+			case slots <- struct{}{}:
+			default:
+				sandboxRejections.WithLabelValues(name, "busy").Inc()
+				return Transientf("%w: %s allows %d at once", ErrSandboxBusy, name, sb.MaxConcurrent)
+			}
+		}
+		if sb.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, sb.Timeout)
+			defer cancel()
+		}
+
+		// Run fn on its own goroutine, so the worker can move on without it.
+		// The slot is only freed once fn has actually returned.
+		done := make(chan error, 1)
+		go func() {
+			defer func() {
+				if slots != nil {
+					<-slots
+				}
+			}()
+			defer func() {
+				if r := recover(); r != nil {
+					sandboxRejections.WithLabelValues(name, "panic").Inc()
+					handlerPanics.WithLabelValues(event.EventType).Inc()
+					if sb.Logger != nil {
+						sb.Logger.Error("Sandboxed handler panicked", "handler", name, "event_uuid", event.UUID,
+							"event_type", event.EventType, "panic", r, "stack", string(debug.Stack()))
+					}
+					done <- Transientf("%w: %v", ErrHandlerPanic, r)
+				}
+			}()
+			done <- fn(ctx, event)
+		}()
+
+		select {
+		case err := <-done:
+			return err
+		case <-ctx.Done():
+			if sb.Timeout > 0 && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				sandboxRejections.WithLabelValues(name, "timeout").Inc()
+				return Transientf("%w: %s took longer than %s", ErrSandboxTimeout, name, sb.Timeout)
+			}
+			// The job timed out or the pool is stopping.
+			return fmt.Errorf("handler %s abandoned: %w", name, ctx.Err())
+		}
+	}
+}
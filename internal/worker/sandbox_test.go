@@ -0,0 +1,92 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"gusto-webhook-guide/internal/models"
+	"testing"
+	"time"
+)
+
+func TestSandbox(t *testing.T) {
+	event := models.WebhookEvent{UUID: "evt-1", EventType: "payroll.processed"}
+	block := make(chan struct{})
+	defer close(block)
+
+	testCases := []struct {
+		name        string
+		sandbox     Sandbox
+		handler     JobHandler
+		expectedErr error
+	}{
+		{
+			name:    "Success",
+			handler: func(context.Context, models.WebhookEvent) error { return nil },
+		},
+		{
+			name:        "Panic",
+			handler:     func(context.Context, models.WebhookEvent) error { panic("boom") },
+			expectedErr: ErrHandlerPanic,
+		},
+		{
+			name:    "Ignores Its Context Past The Timeout",
+			sandbox: Sandbox{Timeout: 20 * time.Millisecond},
+			handler: func(context.Context, models.WebhookEvent) error {
+				<-block
+				return nil
+			},
+			expectedErr: ErrSandboxTimeout,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			registry := NewRegistry()
+			registry.OnSandboxed("payroll.*", tc.sandbox, tc.handler)
+
+			err := registry.Process(context.Background(), event)
+			if tc.expectedErr == nil && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tc.expectedErr != nil && (!errors.Is(err, tc.expectedErr) || !IsTransient(err)) {
+				t.Errorf("incorrect error: got %v want a transient %v", err, tc.expectedErr)
+			}
+		})
+	}
+}
+
+func TestSandboxConcurrency(t *testing.T) {
+	event := models.WebhookEvent{UUID: "evt-1", EventType: "payroll.processed"}
+	block := make(chan struct{})
+	registry := NewRegistry()
+	registry.OnSandboxed("payroll.*", Sandbox{Timeout: 20 * time.Millisecond, MaxConcurrent: 1},
+		func(context.Context, models.WebhookEvent) error {
+			<-block
+			return nil
+		})
+	registry.On("company.updated", func(context.Context, models.WebhookEvent) error { return nil })
+
+	// The timed-out attempt still holds the only slot.
+	if err := registry.Process(context.Background(), event); !errors.Is(err, ErrSandboxTimeout) {
+		t.Fatalf("first attempt: got %v want %v", err, ErrSandboxTimeout)
+	}
+	if err := registry.Process(context.Background(), event); !errors.Is(err, ErrSandboxBusy) || !IsTransient(err) {
+		t.Errorf("second attempt: got %v want a transient %v", err, ErrSandboxBusy)
+	}
+	if err := registry.Process(context.Background(), models.WebhookEvent{EventType: "company.updated"}); err != nil {
+		t.Errorf("another handler was held up: %v", err)
+	}
+
+	close(block)
+	deadline := time.Now().Add(time.Second)
+	for {
+		err := registry.Process(context.Background(), event)
+		if !errors.Is(err, ErrSandboxBusy) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the slot wasn't freed once the handler returned")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,234 @@
+package worker
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gusto-webhook-guide/internal/models"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// scheduleBatchSize is how many due jobs a scheduler tick enqueues at most.
+const scheduleBatchSize = 100
+
+// ErrNoSchedule is returned by ScheduleAt when the pool has no Schedule.
+var ErrNoSchedule = errors.New("no schedule configured for future-dated jobs")
+
+// ErrScheduleReadOnly is returned by a FileSchedule's Add and Remove while
+// it is read-only.
+var ErrScheduleReadOnly = errors.New("schedule is read-only")
+
+// ScheduledJob is an event to be processed at a later time.
+type ScheduledJob struct {
+	ID      string    `json:"id"` // The event's UUID.
+	DueAt   time.Time `json:"due_at"`
+	Payload []byte    `json:"payload"`
+}
+
+// Schedule durably stores future-dated jobs until they fall due.
+type Schedule interface {
+	// Add stores a job, replacing any job with the same ID.
+	Add(ctx context.Context, job ScheduledJob) error
+	// Due returns up to limit jobs due at or before now, earliest first.
+	Due(ctx context.Context, now time.Time, limit int) ([]ScheduledJob, error)
+	// Remove deletes a job; removing an unknown ID is not an error.
+	Remove(ctx context.Context, id string) error
+}
+
+type scheduleKey struct{}
+
+// ScheduleAt arranges for event to be processed at dueAt, like any other
+// event: it goes through the idempotency store, retries and dead-lettering.
+// Processors call it with the context they were given, e.g. to act on an
+// employee's termination once it takes effect. The event's UUID identifies
+// the scheduled job, so derive it from the triggering event: a handler that
+// is retried then replaces its earlier job rather than adding another.
+func ScheduleAt(ctx context.Context, dueAt time.Time, event models.WebhookEvent) error {
+	schedule, _ := ctx.Value(scheduleKey{}).(Schedule)
+	if schedule == nil {
+		return ErrNoSchedule
+	}
+	if event.UUID == "" {
+		return errors.New("scheduled event has no UUID")
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("encoding scheduled event: %w", err)
+	}
+	if err := schedule.Add(ctx, ScheduledJob{ID: event.UUID, DueAt: dueAt.UTC(), Payload: payload}); err != nil {
+		return fmt.Errorf("scheduling event: %w", err)
+	}
+	return nil
+}
+
+// SetSchedule sets where ScheduleAt stores future-dated jobs. It must be
+// called before Start; RunSchedule then enqueues them as they fall due.
+func (p *Pool) SetSchedule(s Schedule) {
+	p.schedule = s
+}
+
+// RunSchedule enqueues scheduled jobs as they fall due, checking every
+// interval until ctx is cancelled. A job is removed from the schedule only
+// once queued, so a crash in between queues it again after a restart; the
+// idempotency store drops the duplicate.
+func (p *Pool) RunSchedule(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		p.enqueueDue(ctx, time.Now().UTC())
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// enqueueDue moves the jobs due at now from the schedule to the queue.
+func (p *Pool) enqueueDue(ctx context.Context, now time.Time) {
+	due, err := p.schedule.Due(ctx, now, scheduleBatchSize)
+	if err != nil {
+		p.logger.Error("Failed to read due scheduled jobs", "error", err)
+		return
+	}
+	for _, job := range due {
+		if err := p.queue.Enqueue(ctx, models.Job{Payload: job.Payload}, 0); err != nil {
+			// Leave the rest for the next tick.
+			p.logger.Warn("Failed to enqueue scheduled job, will try again", "scheduled_id", job.ID, "error", err)
+			return
+		}
+		if err := p.schedule.Remove(ctx, job.ID); err != nil {
+			p.logger.Error("Failed to remove enqueued scheduled job", "scheduled_id", job.ID, "error", err)
+		}
+		p.logger.Info("Scheduled job is due and was queued", "scheduled_id", job.ID, "due_at", job.DueAt)
+	}
+}
+
+// FileSchedule is a Schedule persisted to a JSON file on every change, for
+// a single process.
+type FileSchedule struct {
+	mu       sync.Mutex
+	path     string
+	jobs     map[string]ScheduledJob
+	readOnly bool
+}
+
+var _ Schedule = (*FileSchedule)(nil)
+
+// OpenFileSchedule creates a FileSchedule backed by path, loading any jobs
+// already saved there. An empty path keeps jobs in memory only.
+func OpenFileSchedule(path string) (*FileSchedule, error) {
+	s := &FileSchedule{path: path, jobs: make(map[string]ScheduledJob)}
+	if path == "" {
+		return s, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading schedule: %w", err)
+	}
+	if err := json.Unmarshal(data, &s.jobs); err != nil {
+		return nil, fmt.Errorf("decoding schedule: %w", err)
+	}
+	return s, nil
+}
+
+// SetReadOnly sets whether the schedule refuses changes, with
+// ErrScheduleReadOnly, so that it leaves its file to another process, e.g.
+// one being handed the listeners.
+func (s *FileSchedule) SetReadOnly(readOnly bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.readOnly = readOnly
+}
+
+// Add implements Schedule.
+func (s *FileSchedule) Add(_ context.Context, job ScheduledJob) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prev, existed := s.jobs[job.ID]
+	s.jobs[job.ID] = job
+	if err := s.save(); err != nil {
+		if existed {
+			s.jobs[job.ID] = prev
+		} else {
+			delete(s.jobs, job.ID)
+		}
+		return err
+	}
+	return nil
+}
+
+// Due implements Schedule.
+func (s *FileSchedule) Due(_ context.Context, now time.Time, limit int) ([]ScheduledJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var due []ScheduledJob
+	for _, job := range s.jobs {
+		if !job.DueAt.After(now) {
+			due = append(due, job)
+		}
+	}
+	slices.SortFunc(due, func(a, b ScheduledJob) int {
+		return cmp.Or(a.DueAt.Compare(b.DueAt), cmp.Compare(a.ID, b.ID))
+	})
+	return due[:min(len(due), limit)], nil
+}
+
+// Remove implements Schedule.
+func (s *FileSchedule) Remove(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, found := s.jobs[id]
+	if !found {
+		return nil
+	}
+	delete(s.jobs, id)
+	if err := s.save(); err != nil {
+		s.jobs[id] = job
+		return err
+	}
+	return nil
+}
+
+// save writes the schedule to its file, replacing it atomically. s.mu must
+// be held.
+func (s *FileSchedule) save() error {
+	if s.readOnly {
+		return ErrScheduleReadOnly
+	}
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.Marshal(s.jobs)
+	if err != nil {
+		return fmt.Errorf("encoding schedule: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("creating schedule file: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // No-op once renamed.
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing schedule file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing schedule file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("replacing schedule file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"gusto-webhook-guide/internal/models"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestScheduleAtWithoutSchedule(t *testing.T) {
+	err := ScheduleAt(context.Background(), time.Now(), models.WebhookEvent{UUID: "a"})
+	if !errors.Is(err, ErrNoSchedule) {
+		t.Errorf("incorrect error: got %v want %v", err, ErrNoSchedule)
+	}
+}
+
+func TestFileSchedulePersists(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "schedule.json")
+	now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+
+	s, err := OpenFileSchedule(path)
+	if err != nil {
+		t.Fatalf("OpenFileSchedule failed: %v", err)
+	}
+	s.Add(ctx, ScheduledJob{ID: "later", DueAt: now.Add(time.Hour), Payload: []byte("later")})
+	s.Add(ctx, ScheduledJob{ID: "second", DueAt: now.Add(-time.Minute), Payload: []byte("second")})
+	s.Add(ctx, ScheduledJob{ID: "first", DueAt: now.Add(-time.Hour), Payload: []byte("stale")})
+	s.Add(ctx, ScheduledJob{ID: "first", DueAt: now.Add(-time.Hour), Payload: []byte("first")}) // Replaces the job.
+	s.Add(ctx, ScheduledJob{ID: "removed", DueAt: now, Payload: []byte("removed")})
+	s.Remove(ctx, "removed")
+
+	reopened, err := OpenFileSchedule(path)
+	if err != nil {
+		t.Fatalf("reopening schedule failed: %v", err)
+	}
+	due, err := reopened.Due(ctx, now, 10)
+	if err != nil {
+		t.Fatalf("Due failed: %v", err)
+	}
+	if len(due) != 2 || string(due[0].Payload) != "first" || string(due[1].Payload) != "second" {
+		t.Errorf("incorrect due jobs: got %+v want first and second", due)
+	}
+	if due, _ := reopened.Due(ctx, now, 1); len(due) != 1 {
+		t.Errorf("incorrect number of due jobs with a limit: got %d want 1", len(due))
+	}
+}
+
+func TestFileScheduleReadOnly(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "schedule.json")
+	s, _ := OpenFileSchedule(path)
+	s.Add(ctx, ScheduledJob{ID: "kept", DueAt: time.Now(), Payload: []byte("kept")})
+
+	s.SetReadOnly(true)
+	if err := s.Add(ctx, ScheduledJob{ID: "refused", DueAt: time.Now()}); !errors.Is(err, ErrScheduleReadOnly) {
+		t.Errorf("incorrect Add error: got %v want %v", err, ErrScheduleReadOnly)
+	}
+	if err := s.Remove(ctx, "kept"); !errors.Is(err, ErrScheduleReadOnly) {
+		t.Errorf("incorrect Remove error: got %v want %v", err, ErrScheduleReadOnly)
+	}
+	reopened, _ := OpenFileSchedule(path)
+	for _, schedule := range []*FileSchedule{s, reopened} {
+		if due, _ := schedule.Due(ctx, time.Now(), 10); len(due) != 1 || due[0].ID != "kept" {
+			t.Errorf("incorrect jobs after refused changes: got %+v want kept", due)
+		}
+	}
+
+	s.SetReadOnly(false)
+	if err := s.Remove(ctx, "kept"); err != nil {
+		t.Errorf("Remove failed once writable again: %v", err)
+	}
+}
+
+func TestPoolSchedulesEvents(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	effective := time.Now().Add(time.Hour).UTC()
+	processor := ProcessorFunc(func(ctx context.Context, event models.WebhookEvent) error {
+		if event.EventType == "employee.terminated" {
+			return ScheduleAt(ctx, effective, models.WebhookEvent{
+				UUID:       event.UUID + ":effective",
+				EventType:  "employee.termination_effective",
+				EntityUUID: event.EntityUUID,
+			})
+		}
+		return nil
+	})
+	schedule, _ := OpenFileSchedule("")
+	store := NewIdempotencyStore()
+	pool := NewPool(1, 0, logger, store, processor)
+	defer pool.Stop()
+	pool.SetSchedule(schedule)
+
+	// A retried handler replaces its scheduled job instead of adding another.
+	for range 2 {
+		pool.handleJob(1, models.Job{Payload: []byte(`{"uuid":"t1","event_type":"employee.terminated","entity_uuid":"e1"}`)})
+		store.Delete(ctx, "t1")
+	}
+
+	pool.enqueueDue(ctx, time.Now())
+	if got := len(pool.JobQueue); got != 0 {
+		t.Fatalf("job queued before it was due: got %d queued", got)
+	}
+
+	// The queue is full, so the job stays scheduled until there is room.
+	pool.JobQueue <- models.Job{Payload: []byte(`{"uuid":"filler"}`)}
+	pool.enqueueDue(ctx, effective)
+	if due, _ := schedule.Due(ctx, effective, 10); len(due) != 1 {
+		t.Fatalf("incorrect scheduled jobs after a full queue: got %+v want 1", due)
+	}
+	<-pool.JobQueue
+
+	pool.enqueueDue(ctx, effective)
+	if due, _ := schedule.Due(ctx, effective, 10); len(due) != 0 {
+		t.Errorf("queued job should leave the schedule: got %+v", due)
+	}
+	job := <-pool.JobQueue
+	pool.handleJob(1, job)
+	if rec, found, _ := store.Get(ctx, "t1:effective"); !found || rec.EventType != "employee.termination_effective" || rec.Status != StatusSucceeded {
+		t.Errorf("incorrect record for the scheduled event: got %+v (found %v)", rec, found)
+	}
+}
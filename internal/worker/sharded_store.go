@@ -0,0 +1,167 @@
+package worker
+
+import (
+	"context"
+	"hash/maphash"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// ShardedStore is an in-memory Store that spreads keys over several
+// independently locked shards. It behaves like IdempotencyStore but avoids
+// a single mutex becoming a contention point with many workers.
+type ShardedStore struct {
+	shards []storeShard
+	seed   maphash.Seed
+	ttl    time.Duration // Zero means keys never expire.
+	now    func() time.Time
+}
+
+type storeShard struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+}
+
+var (
+	_ Store  = (*ShardedStore)(nil)
+	_ Lister = (*ShardedStore)(nil)
+)
+
+// NewShardedStore creates a ShardedStore with the given number of shards
+// (at least one) whose keys expire ttl after they were set.
+func NewShardedStore(shards int, ttl time.Duration) *ShardedStore {
+	s := &ShardedStore{
+		shards: make([]storeShard, max(shards, 1)),
+		seed:   maphash.MakeSeed(),
+		ttl:    ttl,
+		now:    time.Now,
+	}
+	for i := range s.shards {
+		s.shards[i].entries = make(map[string]memoryEntry)
+	}
+	return s
+}
+
+// shard returns the shard holding key.
+func (s *ShardedStore) shard(key string) *storeShard {
+	return &s.shards[maphash.String(s.seed, key)%uint64(len(s.shards))]
+}
+
+// Has reports whether a key (event UUID) has a recorded outcome.
+func (s *ShardedStore) Has(_ context.Context, key string) (bool, error) {
+	sh := s.shard(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	e, found := sh.entries[key]
+	return found && !s.expired(e) && e.rec.Status.final(), nil
+}
+
+// Get returns the record for a key (event UUID).
+func (s *ShardedStore) Get(_ context.Context, key string) (Record, bool, error) {
+	sh := s.shard(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	e, found := sh.entries[key]
+	if !found || s.expired(e) {
+		return Record{}, false, nil
+	}
+	return e.rec, true, nil
+}
+
+// Set adds a key (event UUID) to the store.
+func (s *ShardedStore) Set(_ context.Context, key string, rec Record) error {
+	sh := s.shard(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	sh.entries[key] = s.entry(rec)
+	return nil
+}
+
+// SetIfAbsent adds a key (event UUID) to the store unless it is already present.
+func (s *ShardedStore) SetIfAbsent(_ context.Context, key string, rec Record) (bool, error) {
+	sh := s.shard(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	if e, found := sh.entries[key]; found && !s.expired(e) && e.rec.Status != StatusPending {
+		return false, nil
+	}
+	sh.entries[key] = s.entry(rec)
+	return true, nil
+}
+
+// Delete removes a key (event UUID) from the store.
+func (s *ShardedStore) Delete(_ context.Context, key string) error {
+	sh := s.shard(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	delete(sh.entries, key)
+	return nil
+}
+
+// List returns a page of unexpired entries, ordered by key.
+func (s *ShardedStore) List(_ context.Context, opts ListOptions) ([]Entry, string, error) {
+	var entries []Entry
+	for i := range s.shards {
+		sh := &s.shards[i]
+		sh.mu.Lock()
+		for key, e := range sh.entries {
+			if !s.expired(e) && inPage(key, e.rec, opts) {
+				entries = append(entries, Entry{Key: key, Record: e.rec})
+			}
+		}
+		sh.mu.Unlock()
+	}
+	entries, next := paginate(entries, opts.Limit)
+	return entries, next, nil
+}
+
+// Len returns the number of keys currently held, including any expired
+// keys that have not been swept yet.
+func (s *ShardedStore) Len() int {
+	n := 0
+	for i := range s.shards {
+		sh := &s.shards[i]
+		sh.mu.Lock()
+		n += len(sh.entries)
+		sh.mu.Unlock()
+	}
+	return n
+}
+
+// Sweep removes expired keys and returns how many were removed. Shards are
+// locked one at a time, so other shards stay available during a sweep.
+func (s *ShardedStore) Sweep() int {
+	removed := 0
+	for i := range s.shards {
+		sh := &s.shards[i]
+		sh.mu.Lock()
+		for key, e := range sh.entries {
+			if s.expired(e) {
+				delete(sh.entries, key)
+				removed++
+			}
+		}
+		sh.mu.Unlock()
+	}
+	return removed
+}
+
+// RunSweeper calls Sweep every interval until ctx is cancelled.
+func (s *ShardedStore) RunSweeper(ctx context.Context, interval time.Duration, logger *slog.Logger) {
+	runSweeper(ctx, s, interval, logger)
+}
+
+// entry wraps rec with the configured TTL.
+func (s *ShardedStore) entry(rec Record) memoryEntry {
+	e := memoryEntry{rec: rec}
+	if s.ttl > 0 {
+		e.expiresAt = s.now().Add(s.ttl)
+	}
+	return e
+}
+
+// expired reports whether e has passed its expiry.
+func (s *ShardedStore) expired(e memoryEntry) bool {
+	return !e.expiresAt.IsZero() && !s.now().Before(e.expiresAt)
+}
@@ -0,0 +1,141 @@
+package worker
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestShardedStore(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Claim Set and Has", func(t *testing.T) {
+		store := NewShardedStore(4, 0)
+		key := "sharded-uuid-123"
+
+		claim := Record{Status: StatusProcessing}
+		if ok, _ := store.SetIfAbsent(ctx, key, claim); !ok {
+			t.Fatalf("Expected first SetIfAbsent to succeed")
+		}
+		if ok, _ := store.SetIfAbsent(ctx, key, claim); ok {
+			t.Errorf("Expected second SetIfAbsent to fail")
+		}
+		if found, _ := store.Has(ctx, key); found {
+			t.Errorf("Expected Has(%q) to be false while only claimed", key)
+		}
+
+		store.Set(ctx, key, Record{Status: StatusSucceeded})
+		if found, _ := store.Has(ctx, key); !found {
+			t.Errorf("Expected Has(%q) to be true", key)
+		}
+		store.Delete(ctx, key)
+		if _, found, _ := store.Get(ctx, key); found {
+			t.Errorf("Expected key %q to be deleted", key)
+		}
+	})
+
+	t.Run("Keys Spread Across Shards", func(t *testing.T) {
+		store := NewShardedStore(8, 0)
+		for i := range 1000 {
+			store.Set(ctx, strconv.Itoa(i), Record{Status: StatusSucceeded})
+		}
+		if got := store.Len(); got != 1000 {
+			t.Errorf("incorrect length: got %d want 1000", got)
+		}
+		for i := range store.shards {
+			if len(store.shards[i].entries) == 0 {
+				t.Errorf("shard %d is empty; keys are not being distributed", i)
+			}
+		}
+	})
+
+	t.Run("Concurrent Claims", func(t *testing.T) {
+		store := NewShardedStore(16, 0)
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		claimed := 0
+
+		// Exactly one of many concurrent claims on a key succeeds.
+		for range 100 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if ok, _ := store.SetIfAbsent(ctx, "contended", Record{Status: StatusProcessing}); ok {
+					mu.Lock()
+					claimed++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+		if claimed != 1 {
+			t.Errorf("incorrect number of successful claims: got %d want 1", claimed)
+		}
+	})
+
+	t.Run("TTL Expiry and Sweep", func(t *testing.T) {
+		now := time.Now()
+		store := NewShardedStore(4, time.Hour)
+		store.now = func() time.Time { return now }
+
+		store.Set(ctx, "old-key", Record{Status: StatusSucceeded})
+		now = now.Add(2 * time.Hour)
+		store.Set(ctx, "new-key", Record{Status: StatusSucceeded})
+
+		if found, _ := store.Has(ctx, "old-key"); found {
+			t.Errorf("Expected old-key to have expired")
+		}
+		if removed := store.Sweep(); removed != 1 {
+			t.Errorf("incorrect swept count: got %d want 1", removed)
+		}
+		if got := store.Len(); got != 1 {
+			t.Errorf("incorrect length after sweep: got %d want 1", got)
+		}
+	})
+}
+
+// BenchmarkStores runs the worker's claim-then-record pattern from many
+// goroutines against each in-memory store. Compare with:
+//
+//	go test -run '^$' -bench Stores -cpu 1,4,16 ./internal/worker/
+func BenchmarkStores(b *testing.B) {
+	stores := []struct {
+		name  string
+		store func() Store
+	}{
+		{name: "Mutex", store: func() Store { return NewIdempotencyStore() }},
+		{name: "LRU", store: func() Store { return NewLRUStore(0) }},
+		{name: "Sharded", store: func() Store { return NewShardedStore(32, 0) }},
+	}
+
+	for _, bc := range stores {
+		b.Run(bc.name, func(b *testing.B) {
+			ctx := context.Background()
+			store := bc.store()
+			var next sync.Mutex
+			worker := 0
+
+			b.RunParallel(func(pb *testing.PB) {
+				// Each goroutine cycles through its own keys, so the
+				// benchmark measures lock contention rather than map growth.
+				next.Lock()
+				keys := make([]string, 1024)
+				for i := range keys {
+					keys[i] = strconv.Itoa(worker) + "-" + strconv.Itoa(i)
+				}
+				worker++
+				next.Unlock()
+
+				for i := 0; pb.Next(); i++ {
+					key := keys[i%len(keys)]
+					store.SetIfAbsent(ctx, key, Record{Status: StatusProcessing})
+					store.Set(ctx, key, Record{Status: StatusSucceeded})
+					store.Has(ctx, key)
+					store.Delete(ctx, key)
+				}
+			})
+		})
+	}
+}
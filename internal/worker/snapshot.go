@@ -0,0 +1,121 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// IdempotencySnapshotVersion is the format version of IdempotencyStore
+// snapshots.
+const IdempotencySnapshotVersion = 1
+
+// snapshotFile is the on-disk format of an IdempotencyStore snapshot.
+type snapshotFile struct {
+	Version int                      `json:"version"`
+	TakenAt time.Time                `json:"taken_at"`
+	Entries map[string]snapshotEntry `json:"entries"`
+}
+
+type snapshotEntry struct {
+	Record    Record    `json:"record"`
+	ExpiresAt time.Time `json:"expires_at,omitzero"`
+}
+
+// SaveSnapshot writes the store's unexpired keys to path as JSON. The file is
+// replaced atomically so a crash mid-write never leaves a partial snapshot.
+func (s *IdempotencyStore) SaveSnapshot(path string) error {
+	s.mu.Lock()
+	snap := snapshotFile{
+		Version: IdempotencySnapshotVersion,
+		TakenAt: s.now().UTC(),
+		Entries: make(map[string]snapshotEntry, len(s.store)),
+	}
+	for key, e := range s.store {
+		// In-flight claims are skipped: after a restart nobody holds them.
+		if s.expired(e) || e.rec.Status == StatusProcessing {
+			continue
+		}
+		snap.Entries[key] = snapshotEntry{Record: e.rec, ExpiresAt: e.expiresAt}
+	}
+	s.mu.Unlock()
+
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return fmt.Errorf("encoding snapshot: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("creating snapshot file: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // No-op once renamed.
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing snapshot file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing snapshot file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("replacing snapshot file: %w", err)
+	}
+	return nil
+}
+
+// LoadSnapshot restores keys from a snapshot written by SaveSnapshot and
+// returns how many were loaded. A missing file is not an error.
+func (s *IdempotencyStore) LoadSnapshot(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("reading snapshot file: %w", err)
+	}
+
+	var snap snapshotFile
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return 0, fmt.Errorf("decoding snapshot file: %w", err)
+	}
+	if snap.Version != IdempotencySnapshotVersion {
+		return 0, fmt.Errorf("unsupported snapshot version %d", snap.Version)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	loaded := 0
+	for key, se := range snap.Entries {
+		e := memoryEntry{rec: se.Record, expiresAt: se.ExpiresAt}
+		if s.expired(e) {
+			continue
+		}
+		s.store[key] = e
+		loaded++
+	}
+	return loaded, nil
+}
+
+// RunSnapshotter calls SaveSnapshot every interval until ctx is cancelled.
+// Callers should take a final snapshot after the worker pool has stopped.
+func (s *IdempotencyStore) RunSnapshotter(ctx context.Context, path string, interval time.Duration, logger *slog.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.SaveSnapshot(path); err != nil {
+				logger.Error("Failed to snapshot idempotency store", "path", path, "error", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package worker
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSnapshot(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "idempotency.json")
+
+	t.Run("Missing File Loads Nothing", func(t *testing.T) {
+		store := NewIdempotencyStore()
+		loaded, err := store.LoadSnapshot(path)
+		if err != nil || loaded != 0 {
+			t.Errorf("expected empty load without error, got %d (err %v)", loaded, err)
+		}
+	})
+
+	t.Run("Save and Restore", func(t *testing.T) {
+		now := time.Now()
+		original := NewIdempotencyStoreWithTTL(time.Hour)
+		original.now = func() time.Time { return now }
+
+		done := Record{EventType: "company.updated", Status: StatusSucceeded, Attempts: 1}
+		original.Set(ctx, "done-key", done)
+		original.SetIfAbsent(ctx, "claimed-key", Record{Status: StatusProcessing})
+
+		if err := original.SaveSnapshot(path); err != nil {
+			t.Fatalf("SaveSnapshot failed: %v", err)
+		}
+
+		restored := NewIdempotencyStoreWithTTL(time.Hour)
+		restored.now = func() time.Time { return now }
+		loaded, err := restored.LoadSnapshot(path)
+		if err != nil {
+			t.Fatalf("LoadSnapshot failed: %v", err)
+		}
+
+		// In-flight claims are not persisted.
+		if loaded != 1 {
+			t.Errorf("incorrect number of keys loaded: got %d want 1", loaded)
+		}
+		got, found, _ := restored.Get(ctx, "done-key")
+		if !found || got != done {
+			t.Errorf("incorrect restored record: got %+v (found %v) want %+v", got, found, done)
+		}
+
+		// Keys keep their original expiry across the restore.
+		now = now.Add(time.Hour)
+		if found, _ := restored.Has(ctx, "done-key"); found {
+			t.Errorf("expected restored key to expire at its original time")
+		}
+	})
+
+	t.Run("Expired Keys Are Not Restored", func(t *testing.T) {
+		restored := NewIdempotencyStoreWithTTL(time.Hour)
+		restored.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+		loaded, err := restored.LoadSnapshot(path)
+		if err != nil || loaded != 0 {
+			t.Errorf("expected no keys to be loaded, got %d (err %v)", loaded, err)
+		}
+	})
+
+	t.Run("Corrupt File", func(t *testing.T) {
+		corrupt := filepath.Join(t.TempDir(), "corrupt.json")
+		os.WriteFile(corrupt, []byte("{not json"), 0o600)
+		if _, err := NewIdempotencyStore().LoadSnapshot(corrupt); err == nil {
+			t.Errorf("expected an error for a corrupt snapshot")
+		}
+	})
+}
@@ -0,0 +1,133 @@
+package worker
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// SetSpillPath makes Stop save the jobs it would otherwise lose to a file
+// at path: those still in the in-memory queue, which are then not processed,
+// and retries waiting for their delay. LoadSpill queues them again at the
+// next startup. It must be called before Start.
+func (p *Pool) SetSpillPath(path string) {
+	p.spillPath = path
+}
+
+// DrainOnStop makes Stop process the jobs still in the in-memory queue
+// instead of spilling them, as after handing off to a process that has
+// already loaded the spill file. Jobs left when Stop gives up, and retries
+// waiting for their delay, are spilled all the same. Unlike SetSpillPath it
+// may be called while the pool is running.
+func (p *Pool) DrainOnStop() {
+	p.drainOnStop.Store(true)
+}
+
+// spill writes the unprocessed jobs to the spill file, returning how many
+// there were: those in the in-memory queue and retries waiting for their
+// delay. Jobs already in the file, e.g. spilled earlier in the same
+// shutdown or by another process, are kept. The retry scheduler must have
+// stopped and workers must be paused or gone, so that neither takes the
+// jobs being spilled.
+func (p *Pool) spill() (int, error) {
+	snap := QueueSnapshot{Version: QueueSnapshotVersion, TakenAt: time.Now().UTC()}
+	if p.queue == JobQueue(p.lanes) {
+		for _, lane := range []ChannelQueue{p.lanes.high, p.lanes.normal, p.lanes.low} {
+		drain:
+			for {
+				select {
+				case job, ok := <-lane:
+					if !ok {
+						break drain // Closed and empty.
+					}
+					snap.Pending = append(snap.Pending, SnapshotJob{Payload: job.Payload, Attempts: job.Attempts, Priority: job.Priority, ReceivedAt: job.ReceivedAt, RequestID: job.RequestID})
+				default:
+					break drain
+				}
+			}
+		}
+	}
+	p.retriesMu.Lock()
+	for _, r := range p.retries {
+		if r.storeID != "" {
+			continue // Kept in the queue's RetryStore.
+		}
+		snap.Retries = append(snap.Retries, SnapshotJob{Payload: r.job.Payload, Attempts: r.job.Attempts, Priority: r.job.Priority, ReceivedAt: r.job.ReceivedAt, RequestID: r.job.RequestID, DueAt: r.dueAt})
+	}
+	p.retries = nil
+	p.retriesMu.Unlock()
+
+	count := len(snap.Pending) + len(snap.Retries)
+	if count == 0 {
+		return 0, nil
+	}
+	data, err := os.ReadFile(p.spillPath)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return count, fmt.Errorf("reading spill file: %w", err)
+	default:
+		var earlier QueueSnapshot
+		if err := json.Unmarshal(data, &earlier); err != nil {
+			return count, fmt.Errorf("decoding spill file: %w", err)
+		}
+		snap.Pending = append(earlier.Pending, snap.Pending...)
+		snap.Retries = append(earlier.Retries, snap.Retries...)
+	}
+	data, err = json.Marshal(snap)
+	if err != nil {
+		return count, fmt.Errorf("encoding spill: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(p.spillPath), filepath.Base(p.spillPath)+".tmp-*")
+	if err != nil {
+		return count, fmt.Errorf("creating spill file: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // No-op once renamed.
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return count, fmt.Errorf("writing spill file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return count, fmt.Errorf("writing spill file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), p.spillPath); err != nil {
+		return count, fmt.Errorf("replacing spill file: %w", err)
+	}
+	return count, nil
+}
+
+// LoadSpill queues the jobs spilled by the last Stop, then removes the spill
+// file, returning how many jobs were restored. A missing file is not an
+// error. If the queue fills up the file is kept, so loading it again later
+// only repeats jobs the idempotency store will drop.
+func (p *Pool) LoadSpill() (int, error) {
+	if p.spillPath == "" {
+		return 0, nil
+	}
+	data, err := os.ReadFile(p.spillPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("reading spill file: %w", err)
+	}
+
+	var snap QueueSnapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return 0, fmt.Errorf("decoding spill file: %w", err)
+	}
+	restored, err := p.RestoreQueue(snap)
+	if err != nil {
+		return restored, err
+	}
+	if err := os.Remove(p.spillPath); err != nil {
+		return restored, fmt.Errorf("removing spill file: %w", err)
+	}
+	return restored, nil
+}
@@ -0,0 +1,145 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"gusto-webhook-guide/internal/models"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSpillRoundTrip(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	path := filepath.Join(t.TempDir(), "spill.json")
+	noop := ProcessorFunc(func(context.Context, models.WebhookEvent) error { return nil })
+
+	source := NewPool(10, 0, logger, NewIdempotencyStore(), noop)
+	source.SetSpillPath(path)
+	payload, _ := json.Marshal(models.WebhookEvent{UUID: "queued", EventType: "company.updated"})
+	source.JobQueue <- models.Job{Payload: payload}
+	source.scheduleRetry(models.Job{Payload: []byte(`{"uuid":"retry"}`), Attempts: 1}, time.Hour, logger)
+	source.Stop()
+
+	target := NewPool(10, 0, logger, NewIdempotencyStore(), noop)
+	defer target.Stop()
+	target.SetSpillPath(path)
+	restored, err := target.LoadSpill()
+	if err != nil || restored != 2 {
+		t.Fatalf("incorrect LoadSpill result: got %d (err %v) want 2", restored, err)
+	}
+	if got := len(target.JobQueue); got != 1 {
+		t.Errorf("incorrect number of queued jobs: got %d want 1", got)
+	}
+	target.retriesMu.Lock()
+	retries := len(target.retries)
+	target.retriesMu.Unlock()
+	if retries != 1 {
+		t.Errorf("incorrect number of scheduled retries: got %d want 1", retries)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("spill file not removed after loading: %v", err)
+	}
+
+	if restored, err := target.LoadSpill(); err != nil || restored != 0 {
+		t.Errorf("incorrect result without a spill file: got %d (err %v)", restored, err)
+	}
+}
+
+func TestSpillRetryScheduledDuringStop(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	path := filepath.Join(t.TempDir(), "spill.json")
+	started := make(chan struct{})
+	fail := make(chan struct{})
+	processor := ProcessorFunc(func(context.Context, models.WebhookEvent) error {
+		close(started)
+		<-fail
+		return Transientf("downstream unavailable")
+	})
+
+	pool := NewPool(10, 1, logger, NewIdempotencyStore(), processor)
+	pool.SetSpillPath(path)
+	pool.SetStopGrace(time.Minute)
+	pool.Start(1)
+	for _, uuid := range []string{"in-flight", "queued"} {
+		payload, _ := json.Marshal(models.WebhookEvent{UUID: uuid, EventType: "company.updated"})
+		pool.JobQueue <- models.Job{Payload: payload}
+		if uuid == "in-flight" {
+			<-started
+		}
+	}
+
+	// The in-flight job fails only after the queue has been spilled.
+	stopped := make(chan struct{})
+	go func() {
+		pool.Stop()
+		close(stopped)
+	}()
+	waitFor(t, func() bool { _, err := os.Stat(path); return err == nil })
+	close(fail)
+	<-stopped
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading spill file: %v", err)
+	}
+	var snap QueueSnapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		t.Fatalf("decoding spill file: %v", err)
+	}
+	if len(snap.Pending) != 1 || len(snap.Retries) != 1 {
+		t.Fatalf("incorrect spill: got %d pending and %d retries want 1 and 1", len(snap.Pending), len(snap.Retries))
+	}
+	var event models.WebhookEvent
+	json.Unmarshal(snap.Retries[0].Payload, &event)
+	if event.UUID != "in-flight" || snap.Retries[0].Attempts != 1 {
+		t.Errorf("incorrect spilled retry: %+v", snap.Retries[0])
+	}
+}
+
+func TestDrainOnStop(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	path := filepath.Join(t.TempDir(), "spill.json")
+	var mu sync.Mutex
+	var processed []string
+	processor := ProcessorFunc(func(_ context.Context, event models.WebhookEvent) error {
+		mu.Lock()
+		defer mu.Unlock()
+		processed = append(processed, event.UUID)
+		if event.UUID == "failing" {
+			return Transientf("downstream unavailable")
+		}
+		return nil
+	})
+
+	pool := NewPool(10, 1, logger, NewIdempotencyStore(), processor)
+	pool.SetSpillPath(path)
+	pool.Start(1)
+	pool.Pause()
+	for _, uuid := range []string{"first", "second", "failing"} {
+		payload, _ := json.Marshal(models.WebhookEvent{UUID: uuid, EventType: "company.updated"})
+		pool.JobQueue <- models.Job{Payload: payload}
+	}
+	pool.DrainOnStop()
+	pool.Stop()
+
+	if want := []string{"first", "second", "failing"}; !slices.Equal(processed, want) {
+		t.Errorf("incorrect jobs processed: got %v want %v", processed, want)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading spill file: %v", err)
+	}
+	var snap QueueSnapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		t.Fatalf("decoding spill file: %v", err)
+	}
+	if len(snap.Pending) != 0 || len(snap.Retries) != 1 {
+		t.Errorf("incorrect spill: got %d pending and %d retries want 0 and 1", len(snap.Pending), len(snap.Retries))
+	}
+}
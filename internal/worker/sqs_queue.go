@@ -0,0 +1,181 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gusto-webhook-guide/internal/models"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// ErrNoDeadLetterQueue is returned by SQSQueue.Redrive when no dead-letter
+// queue was configured.
+var ErrNoDeadLetterQueue = errors.New("no dead-letter queue configured")
+
+// SQSAPI is the subset of the SQS client used by SQSQueue. *sqs.Client
+// satisfies it.
+type SQSAPI interface {
+	SendMessage(ctx context.Context, params *sqs.SendMessageInput, optFns ...func(*sqs.Options)) (*sqs.SendMessageOutput, error)
+	ReceiveMessage(ctx context.Context, params *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error)
+	DeleteMessage(ctx context.Context, params *sqs.DeleteMessageInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error)
+	ChangeMessageVisibility(ctx context.Context, params *sqs.ChangeMessageVisibilityInput, optFns ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityOutput, error)
+	StartMessageMoveTask(ctx context.Context, params *sqs.StartMessageMoveTaskInput, optFns ...func(*sqs.Options)) (*sqs.StartMessageMoveTaskOutput, error)
+}
+
+// SQSQueue is a durable JobQueue backed by an AWS SQS queue, letting the
+// webhook receiver and the workers scale independently.
+//
+// A received message stays invisible to other consumers while it is being
+// processed: its visibility timeout is extended periodically until the job
+// is acknowledged. If the process dies, the message reappears once
+// the timeout lapses. Messages received too often are moved to the queue's
+// dead-letter queue by its SQS redrive policy; Redrive moves them back.
+type SQSQueue struct {
+	client     SQSAPI
+	queueURL   string
+	dlqARN     string        // Dead-letter queue ARN; empty if none.
+	visibility time.Duration // Visibility timeout for received messages.
+	heartbeat  time.Duration // How often the timeout of a held message is extended.
+	waitTime   int32         // Long-poll duration of each receive, in seconds.
+}
+
+var (
+	_ JobQueue = (*SQSQueue)(nil)
+	_ Redriver = (*SQSQueue)(nil)
+)
+
+// NewSQSQueue creates an SQSQueue for queueURL. dlqARN is the ARN of its
+// dead-letter queue, or empty if it has none. visibility should comfortably
+// be at least a second; it is extended every half timeout while a job is
+// being handled.
+func NewSQSQueue(client SQSAPI, queueURL, dlqARN string, visibility time.Duration) *SQSQueue {
+	return &SQSQueue{
+		client:     client,
+		queueURL:   queueURL,
+		dlqARN:     dlqARN,
+		visibility: visibility,
+		heartbeat:  visibility / 2,
+		waitTime:   20,
+	}
+}
+
+// Enqueue implements JobQueue. SQS queues are unbounded, so wait is unused.
+func (q *SQSQueue) Enqueue(ctx context.Context, job models.Job, _ time.Duration) error {
+	attrs := map[string]types.MessageAttributeValue{
+		"attempts": {DataType: aws.String("Number"), StringValue: aws.String(strconv.Itoa(job.Attempts))},
+	}
+	if !job.ReceivedAt.IsZero() { // SQS rejects empty attribute values.
+		attrs["received_at"] = types.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String(formatReceivedAt(job.ReceivedAt))}
+	}
+	if job.RequestID != "" {
+		attrs["request_id"] = types.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String(job.RequestID)}
+	}
+	_, err := q.client.SendMessage(ctx, &sqs.SendMessageInput{
+		QueueUrl:          aws.String(q.queueURL),
+		MessageBody:       aws.String(string(job.Payload)),
+		MessageAttributes: attrs,
+	})
+	if err != nil {
+		return fmt.Errorf("enqueuing job: %w", err)
+	}
+	return nil
+}
+
+// Dequeue implements JobQueue, long-polling until a message arrives or ctx
+// is done.
+func (q *SQSQueue) Dequeue(ctx context.Context) (models.Job, func() error, error) {
+	for {
+		out, err := q.client.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+			QueueUrl:              aws.String(q.queueURL),
+			MaxNumberOfMessages:   1,
+			WaitTimeSeconds:       q.waitTime,
+			VisibilityTimeout:     int32(q.visibility / time.Second),
+			MessageAttributeNames: []string{"attempts", "received_at", "request_id"},
+		})
+		if ctx.Err() != nil {
+			return models.Job{}, nil, ctx.Err()
+		}
+		if err != nil {
+			return models.Job{}, nil, fmt.Errorf("dequeuing job: %w", err)
+		}
+		if len(out.Messages) == 0 {
+			continue
+		}
+
+		msg := out.Messages[0]
+		job := models.Job{Payload: []byte(aws.ToString(msg.Body))}
+		if attr, found := msg.MessageAttributes["attempts"]; found {
+			job.Attempts, _ = strconv.Atoi(aws.ToString(attr.StringValue))
+		}
+		if attr, found := msg.MessageAttributes["received_at"]; found {
+			job.ReceivedAt = parseReceivedAt(aws.ToString(attr.StringValue))
+		}
+		if attr, found := msg.MessageAttributes["request_id"]; found {
+			job.RequestID = aws.ToString(attr.StringValue)
+		}
+		return job, q.hold(ctx, aws.ToString(msg.ReceiptHandle)), nil
+	}
+}
+
+// hold keeps a received message invisible until the returned ack function
+// is called, which then deletes it.
+func (q *SQSQueue) hold(ctx context.Context, receiptHandle string) func() error {
+	ctx = context.WithoutCancel(ctx)
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(q.heartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				// A failed extension is retried on the next tick; at worst
+				// the message is redelivered and deduplicated.
+				q.client.ChangeMessageVisibility(ctx, &sqs.ChangeMessageVisibilityInput{
+					QueueUrl:          aws.String(q.queueURL),
+					ReceiptHandle:     aws.String(receiptHandle),
+					VisibilityTimeout: int32(q.visibility / time.Second),
+				})
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	return func() error {
+		close(stop)
+		wg.Wait()
+		_, err := q.client.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+			QueueUrl:      aws.String(q.queueURL),
+			ReceiptHandle: aws.String(receiptHandle),
+		})
+		if err != nil {
+			return fmt.Errorf("acknowledging job: %w", err)
+		}
+		return nil
+	}
+}
+
+// Redrive implements Redriver, starting an SQS message move task from the
+// dead-letter queue back to the queue its messages came from. It returns
+// the task handle.
+func (q *SQSQueue) Redrive(ctx context.Context) (string, error) {
+	if q.dlqARN == "" {
+		return "", ErrNoDeadLetterQueue
+	}
+	out, err := q.client.StartMessageMoveTask(ctx, &sqs.StartMessageMoveTaskInput{
+		SourceArn: aws.String(q.dlqARN),
+	})
+	if err != nil {
+		return "", fmt.Errorf("starting dead-letter redrive: %w", err)
+	}
+	return aws.ToString(out.TaskHandle), nil
+}
@@ -0,0 +1,195 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"gusto-webhook-guide/internal/models"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// fakeSQS is an in-memory SQSAPI holding a single queue.
+type fakeSQS struct {
+	mu         sync.Mutex
+	messages   []*fakeMessage
+	nextID     int
+	extensions int    // ChangeMessageVisibility calls.
+	movedFrom  string // Source ARN of the last message move task.
+}
+
+type fakeMessage struct {
+	body      string
+	attempts  string
+	handle    string
+	visibleAt time.Time
+}
+
+// expire makes every in-flight message visible again, as if its
+// visibility timeout had lapsed.
+func (f *fakeSQS) expire() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, m := range f.messages {
+		m.visibleAt = time.Time{}
+	}
+}
+
+func (f *fakeSQS) find(handle string) *fakeMessage {
+	for _, m := range f.messages {
+		if m.handle == handle {
+			return m
+		}
+	}
+	return nil
+}
+
+func (f *fakeSQS) SendMessage(_ context.Context, in *sqs.SendMessageInput, _ ...func(*sqs.Options)) (*sqs.SendMessageOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.messages = append(f.messages, &fakeMessage{
+		body:     aws.ToString(in.MessageBody),
+		attempts: aws.ToString(in.MessageAttributes["attempts"].StringValue),
+	})
+	return &sqs.SendMessageOutput{}, nil
+}
+
+func (f *fakeSQS) ReceiveMessage(ctx context.Context, in *sqs.ReceiveMessageInput, _ ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := time.Now()
+	for _, m := range f.messages {
+		if m.visibleAt.After(now) {
+			continue
+		}
+		f.nextID++
+		m.handle = strconv.Itoa(f.nextID) // Each receive gets a new handle.
+		m.visibleAt = now.Add(time.Duration(in.VisibilityTimeout) * time.Second)
+		return &sqs.ReceiveMessageOutput{Messages: []types.Message{{
+			Body:          aws.String(m.body),
+			ReceiptHandle: aws.String(m.handle),
+			MessageAttributes: map[string]types.MessageAttributeValue{
+				"attempts": {DataType: aws.String("Number"), StringValue: aws.String(m.attempts)},
+			},
+		}}}, nil
+	}
+
+	// Stand in for a long poll that found nothing.
+	f.mu.Unlock()
+	select {
+	case <-time.After(time.Millisecond):
+	case <-ctx.Done():
+	}
+	f.mu.Lock()
+	return &sqs.ReceiveMessageOutput{}, ctx.Err()
+}
+
+func (f *fakeSQS) DeleteMessage(_ context.Context, in *sqs.DeleteMessageInput, _ ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, m := range f.messages {
+		if m.handle == aws.ToString(in.ReceiptHandle) {
+			f.messages = append(f.messages[:i], f.messages[i+1:]...)
+			return &sqs.DeleteMessageOutput{}, nil
+		}
+	}
+	return nil, errors.New("receipt handle is invalid")
+}
+
+func (f *fakeSQS) ChangeMessageVisibility(_ context.Context, in *sqs.ChangeMessageVisibilityInput, _ ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.extensions++
+	m := f.find(aws.ToString(in.ReceiptHandle))
+	if m == nil {
+		return nil, errors.New("receipt handle is invalid")
+	}
+	m.visibleAt = time.Now().Add(time.Duration(in.VisibilityTimeout) * time.Second)
+	return &sqs.ChangeMessageVisibilityOutput{}, nil
+}
+
+func (f *fakeSQS) StartMessageMoveTask(_ context.Context, in *sqs.StartMessageMoveTaskInput, _ ...func(*sqs.Options)) (*sqs.StartMessageMoveTaskOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.movedFrom = aws.ToString(in.SourceArn)
+	return &sqs.StartMessageMoveTaskOutput{TaskHandle: aws.String("task-1")}, nil
+}
+
+func TestSQSQueue(t *testing.T) {
+	ctx := context.Background()
+	client := &fakeSQS{}
+	q := NewSQSQueue(client, "https://sqs.example.com/jobs", "", time.Second)
+	q.heartbeat = 5 * time.Millisecond
+
+	for _, payload := range []string{"a", "b"} {
+		if err := q.Enqueue(ctx, models.Job{Payload: []byte(payload), Attempts: 2}, 0); err != nil {
+			t.Fatalf("Enqueue failed: %v", err)
+		}
+	}
+
+	first, ackFirst, err := q.Dequeue(ctx)
+	if err != nil || string(first.Payload) != "a" || first.Attempts != 2 {
+		t.Fatalf("incorrect first job: got %+v (err %v)", first, err)
+	}
+	// A held job's visibility is extended until it is acknowledged.
+	time.Sleep(30 * time.Millisecond)
+	if err := ackFirst(); err != nil {
+		t.Fatalf("ack failed: %v", err)
+	}
+	client.mu.Lock()
+	extensions := client.extensions
+	client.mu.Unlock()
+	if extensions == 0 {
+		t.Errorf("visibility was not extended while the job was held")
+	}
+	time.Sleep(20 * time.Millisecond)
+	client.mu.Lock()
+	if client.extensions != extensions {
+		t.Errorf("visibility was extended after ack: got %d extensions want %d", client.extensions, extensions)
+	}
+	client.mu.Unlock()
+
+	// A job whose worker died reappears once its visibility timeout lapses.
+	crashed, _, err := q.Dequeue(ctx)
+	if err != nil || string(crashed.Payload) != "b" {
+		t.Fatalf("incorrect second job: got %+v (err %v)", crashed, err)
+	}
+	client.expire()
+	other := NewSQSQueue(client, "https://sqs.example.com/jobs", "", time.Second)
+	recovered, ackRecovered, err := other.Dequeue(ctx)
+	if err != nil || string(recovered.Payload) != "b" || recovered.Attempts != 2 {
+		t.Fatalf("incorrect recovered job: got %+v (err %v)", recovered, err)
+	}
+	if err := ackRecovered(); err != nil {
+		t.Fatalf("ack failed: %v", err)
+	}
+
+	// Dequeue gives up once ctx is done.
+	shortCtx := cancelledAfter(t, 20*time.Millisecond)
+	if _, _, err := q.Dequeue(shortCtx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("incorrect error from empty queue: got %v want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestSQSQueueRedrive(t *testing.T) {
+	ctx := context.Background()
+	client := &fakeSQS{}
+
+	if _, err := NewSQSQueue(client, "https://sqs.example.com/jobs", "", time.Second).Redrive(ctx); !errors.Is(err, ErrNoDeadLetterQueue) {
+		t.Errorf("incorrect error without a dead-letter queue: got %v want %v", err, ErrNoDeadLetterQueue)
+	}
+
+	dlq := "arn:aws:sqs:us-east-1:123456789012:jobs-dlq"
+	task, err := NewSQSQueue(client, "https://sqs.example.com/jobs", dlq, time.Second).Redrive(ctx)
+	if err != nil {
+		t.Fatalf("Redrive failed: %v", err)
+	}
+	if task != "task-1" || client.movedFrom != dlq {
+		t.Errorf("incorrect redrive: got task %q from %q want task %q from %q", task, client.movedFrom, "task-1", dlq)
+	}
+}
@@ -1,29 +1,266 @@
 package worker
 
-import "sync"
+import (
+	"cmp"
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+)
 
+// Status is the final disposition recorded for a processed event.
+type Status string
+
+const (
+	StatusProcessing       Status = "processing" // Claimed by a worker, outcome not yet known.
+	StatusPending          Status = "pending"    // Claim abandoned by a crashed process; the next delivery may claim it.
+	StatusSucceeded        Status = "succeeded"
+	StatusPermanentFailure Status = "permanent_failure"
+	StatusDeadLettered     Status = "dead_lettered"
+	StatusQuarantined      Status = "quarantined" // Taken out of circulation as a poison message.
+	StatusSkipped          Status = "skipped"     // Deliberately not processed, e.g. a no-op update.
+)
+
+// abandonedClaimError is recorded as LastError when a stale claim is reset.
+const abandonedClaimError = "claim abandoned, the process handling it did not finish"
+
+// final reports whether s is an outcome rather than an in-flight or
+// abandoned claim.
+func (s Status) final() bool {
+	return s != StatusProcessing && s != StatusPending
+}
+
+// Record describes how an event was handled.
+type Record struct {
+	EventType   string    `json:"event_type"`
+	Status      Status    `json:"status"`
+	Attempts    int       `json:"attempts"`             // Processing attempts made, including the last one.
+	LastError   string    `json:"last_error,omitempty"` // Error from the last failed attempt, if any.
+	ClaimedAt   time.Time `json:"claimed_at"`           // When the last attempt claimed the event.
+	ProcessedAt time.Time `json:"processed_at"`         // When the record was last written.
+}
+
+// Store tracks which event UUIDs have already been handled so that retried
+// deliveries from Gusto are not processed twice.
+type Store interface {
+	// Has reports whether a key (event UUID) has a recorded outcome. Keys
+	// that are only claimed (StatusProcessing or StatusPending) are not counted.
+	Has(ctx context.Context, key string) (bool, error)
+	// Get returns the record for a key (event UUID), and false if none exists.
+	Get(ctx context.Context, key string) (Record, bool, error)
+	// Set records the outcome for a key (event UUID).
+	Set(ctx context.Context, key string, rec Record) error
+	// SetIfAbsent atomically stores rec only if key is not already present,
+	// claimed or otherwise, or holds an abandoned (StatusPending) claim. It
+	// reports whether the key was stored.
+	SetIfAbsent(ctx context.Context, key string, rec Record) (bool, error)
+	// Delete removes a key, releasing a claim so the event can be retried.
+	Delete(ctx context.Context, key string) error
+}
+
+// Recoverer is implemented by durable stores, whose claims can outlive a
+// crashed process and would otherwise block redeliveries until they expire.
+type Recoverer interface {
+	// RecoverStale resets StatusProcessing records claimed before cutoff to
+	// StatusPending and returns how many were reset.
+	RecoverStale(ctx context.Context, cutoff time.Time) (int, error)
+}
+
+// Entry is a record together with its key (event UUID).
+type Entry struct {
+	Key string `json:"event_uuid"`
+	Record
+}
+
+// ListOptions selects a page of entries.
+type ListOptions struct {
+	EventType string // Only entries of this event type; empty for all.
+	Cursor    string // Opaque cursor from a previous page; empty for the first.
+	Limit     int    // Maximum entries per page; stores may return slightly more.
+}
+
+// Lister is implemented by stores whose keys can be enumerated, for the
+// admin API. Pages are returned until the next cursor is empty.
+type Lister interface {
+	List(ctx context.Context, opts ListOptions) (entries []Entry, next string, err error)
+}
+
+// ErrNotListable is returned by List on stores that wrap another store,
+// such as DualStore, when the wrapped store can't be listed.
+var ErrNotListable = errors.New("idempotency store can't be listed")
+
+// IdempotencyStore is an in-memory Store. Its contents are lost on restart.
 type IdempotencyStore struct {
 	mu    sync.Mutex
-	store map[string]bool
+	store map[string]memoryEntry
+	ttl   time.Duration // Zero means keys never expire.
+	now   func() time.Time
+}
+
+var (
+	_ Store  = (*IdempotencyStore)(nil)
+	_ Lister = (*IdempotencyStore)(nil)
+)
+
+// memoryEntry is a stored record along with its expiry time.
+type memoryEntry struct {
+	rec       Record
+	expiresAt time.Time // Zero if the entry never expires.
 }
 
 func NewIdempotencyStore() *IdempotencyStore {
+	return NewIdempotencyStoreWithTTL(0)
+}
+
+// NewIdempotencyStoreWithTTL creates a store whose keys expire ttl after
+// they were set. Expired keys are ignored by Has and removed by Sweep.
+func NewIdempotencyStoreWithTTL(ttl time.Duration) *IdempotencyStore {
 	return &IdempotencyStore{
-		store: make(map[string]bool),
+		store: make(map[string]memoryEntry),
+		ttl:   ttl,
+		now:   time.Now,
 	}
 }
 
-// Has checks if a key (event UUID) exists in the store.
-func (s *IdempotencyStore) Has(key string) bool {
+// Has reports whether a key (event UUID) has a recorded outcome.
+func (s *IdempotencyStore) Has(_ context.Context, key string) (bool, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	_, found := s.store[key]
-	return found
+	e, found := s.store[key]
+	return found && !s.expired(e) && e.rec.Status.final(), nil
+}
+
+// Get returns the record for a key (event UUID).
+func (s *IdempotencyStore) Get(_ context.Context, key string) (Record, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, found := s.store[key]
+	if !found || s.expired(e) {
+		return Record{}, false, nil
+	}
+	return e.rec, true, nil
 }
 
 // Set adds a key (event UUID) to the store.
-func (s *IdempotencyStore) Set(key string) {
+func (s *IdempotencyStore) Set(_ context.Context, key string, rec Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.set(key, rec)
+	return nil
+}
+
+// SetIfAbsent adds a key (event UUID) to the store unless it is already present.
+func (s *IdempotencyStore) SetIfAbsent(_ context.Context, key string, rec Record) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, found := s.store[key]; found && !s.expired(e) && e.rec.Status != StatusPending {
+		return false, nil
+	}
+	s.set(key, rec)
+	return true, nil
+}
+
+// Delete removes a key (event UUID) from the store.
+func (s *IdempotencyStore) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.store, key)
+	return nil
+}
+
+// List returns a page of unexpired entries, ordered by key.
+func (s *IdempotencyStore) List(_ context.Context, opts ListOptions) ([]Entry, string, error) {
+	s.mu.Lock()
+	var entries []Entry
+	for key, e := range s.store {
+		if !s.expired(e) && inPage(key, e.rec, opts) {
+			entries = append(entries, Entry{Key: key, Record: e.rec})
+		}
+	}
+	s.mu.Unlock()
+	entries, next := paginate(entries, opts.Limit)
+	return entries, next, nil
+}
+
+// set stores rec under key with the configured TTL. Callers must hold s.mu.
+func (s *IdempotencyStore) set(key string, rec Record) {
+	e := memoryEntry{rec: rec}
+	if s.ttl > 0 {
+		e.expiresAt = s.now().Add(s.ttl)
+	}
+	s.store[key] = e
+}
+
+// Len returns the number of keys currently held, including any expired
+// keys that have not been swept yet.
+func (s *IdempotencyStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.store)
+}
+
+// Sweep removes expired keys and returns how many were removed.
+func (s *IdempotencyStore) Sweep() int {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	s.store[key] = true
-}
\ No newline at end of file
+	removed := 0
+	for key, e := range s.store {
+		if s.expired(e) {
+			delete(s.store, key)
+			removed++
+		}
+	}
+	return removed
+}
+
+// RunSweeper calls Sweep every interval until ctx is cancelled, keeping
+// memory bounded on long-running deployments.
+func (s *IdempotencyStore) RunSweeper(ctx context.Context, interval time.Duration, logger *slog.Logger) {
+	runSweeper(ctx, s, interval, logger)
+}
+
+// sweeper is an in-memory store that removes its own expired keys.
+type sweeper interface {
+	Sweep() int
+	Len() int
+}
+
+// runSweeper calls s.Sweep every interval until ctx is cancelled.
+func runSweeper(ctx context.Context, s sweeper, interval time.Duration, logger *slog.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if removed := s.Sweep(); removed > 0 {
+				logger.Info("Swept expired idempotency keys", "removed", removed, "remaining", s.Len())
+			}
+		}
+	}
+}
+
+// expired reports whether e has passed its expiry. Callers must hold s.mu.
+func (s *IdempotencyStore) expired(e memoryEntry) bool {
+	return !e.expiresAt.IsZero() && !s.now().Before(e.expiresAt)
+}
+
+// inPage reports whether an entry belongs after opts.Cursor and matches
+// opts.EventType. In-memory stores use the last key of a page as the cursor.
+func inPage(key string, rec Record, opts ListOptions) bool {
+	return key > opts.Cursor && (opts.EventType == "" || rec.EventType == opts.EventType)
+}
+
+// paginate sorts entries by key and keeps the first limit of them, returning
+// the cursor for the next page, or "" if there is none.
+func paginate(entries []Entry, limit int) ([]Entry, string) {
+	slices.SortFunc(entries, func(a, b Entry) int { return cmp.Compare(a.Key, b.Key) })
+	if limit <= 0 || len(entries) <= limit {
+		return entries, ""
+	}
+	entries = entries[:limit]
+	return entries, entries[limit-1].Key
+}
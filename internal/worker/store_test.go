@@ -1,25 +1,29 @@
 package worker
 
 import (
+	"context"
 	"sync"
 	"testing"
+	"time"
 )
 
 func TestIdempotencyStore(t *testing.T) {
+	ctx := context.Background()
+
 	t.Run("Set and Has", func(t *testing.T) {
 		store := NewIdempotencyStore()
 		key := "test-uuid-123"
 
 		// Initially, the key should not exist.
-		if store.Has(key) {
+		if found, _ := store.Has(ctx, key); found {
 			t.Errorf("Expected Has(%q) to be false, but got true", key)
 		}
 
 		// Set the key.
-		store.Set(key)
+		store.Set(ctx, key, Record{Status: StatusSucceeded})
 
 		// Now, the key should exist.
-		if !store.Has(key) {
+		if found, _ := store.Has(ctx, key); !found {
 			t.Errorf("Expected Has(%q) to be true, but got false", key)
 		}
 	})
@@ -37,14 +41,125 @@ func TestIdempotencyStore(t *testing.T) {
 		for range numGoroutines {
 			go func() {
 				defer wg.Done()
-				store.Set(key)
+				store.Set(ctx, key, Record{Status: StatusSucceeded})
 			}()
 		}
 		wg.Wait()
 
 		// After all goroutines complete, the key must exist.
-		if !store.Has(key) {
+		if found, _ := store.Has(ctx, key); !found {
 			t.Errorf("Expected key to be set after concurrent writes, but it was not")
 		}
 	})
-}
\ No newline at end of file
+
+	t.Run("TTL Expiry and Sweep", func(t *testing.T) {
+		now := time.Now()
+		store := NewIdempotencyStoreWithTTL(time.Hour)
+		store.now = func() time.Time { return now }
+
+		store.Set(ctx, "old-key", Record{Status: StatusSucceeded})
+		now = now.Add(30 * time.Minute)
+		store.Set(ctx, "new-key", Record{Status: StatusSucceeded})
+
+		// Move past the first key's expiry but not the second's.
+		now = now.Add(45 * time.Minute)
+		if found, _ := store.Has(ctx, "old-key"); found {
+			t.Errorf("Expected expired key to be reported as absent")
+		}
+		if found, _ := store.Has(ctx, "new-key"); !found {
+			t.Errorf("Expected unexpired key to be present")
+		}
+
+		// Expired keys still occupy memory until swept.
+		if got := store.Len(); got != 2 {
+			t.Errorf("incorrect Len before sweep: got %d want 2", got)
+		}
+		if removed := store.Sweep(); removed != 1 {
+			t.Errorf("incorrect number of keys swept: got %d want 1", removed)
+		}
+		if got := store.Len(); got != 1 {
+			t.Errorf("incorrect Len after sweep: got %d want 1", got)
+		}
+	})
+
+	t.Run("SetIfAbsent Claims Exactly Once", func(t *testing.T) {
+		store := NewIdempotencyStore()
+		key := "claimed-key"
+		numGoroutines := 100
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		winners := 0
+
+		wg.Add(numGoroutines)
+		for range numGoroutines {
+			go func() {
+				defer wg.Done()
+				if ok, _ := store.SetIfAbsent(ctx, key, Record{Status: StatusProcessing}); ok {
+					mu.Lock()
+					winners++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+
+		if winners != 1 {
+			t.Errorf("Expected exactly one successful claim, got %d", winners)
+		}
+
+		// A claim alone is not a recorded outcome.
+		if found, _ := store.Has(ctx, key); found {
+			t.Errorf("Expected Has(%q) to be false while only claimed", key)
+		}
+
+		// Releasing the claim allows it to be taken again.
+		store.Delete(ctx, key)
+		if ok, _ := store.SetIfAbsent(ctx, key, Record{Status: StatusProcessing}); !ok {
+			t.Errorf("Expected claim to succeed after release")
+		}
+	})
+
+	t.Run("Get Returns Recorded Outcome", func(t *testing.T) {
+		store := NewIdempotencyStore()
+		key := "outcome-key"
+
+		if _, found, _ := store.Get(ctx, key); found {
+			t.Fatalf("Expected Get(%q) to find nothing", key)
+		}
+
+		want := Record{
+			EventType:   "company.updated",
+			Status:      StatusDeadLettered,
+			Attempts:    5,
+			LastError:   "transient error: timeout",
+			ClaimedAt:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			ProcessedAt: time.Date(2024, 1, 1, 0, 0, 1, 0, time.UTC),
+		}
+		store.Set(ctx, key, want)
+
+		got, found, err := store.Get(ctx, key)
+		if err != nil || !found {
+			t.Fatalf("Expected Get(%q) to succeed, got found=%v err=%v", key, found, err)
+		}
+		if got != want {
+			t.Errorf("incorrect record: got %+v want %+v", got, want)
+		}
+	})
+}
+
+func TestPaginate(t *testing.T) {
+	entries := []Entry{{Key: "c"}, {Key: "a"}, {Key: "b"}}
+
+	page, next := paginate(entries, 2)
+	if len(page) != 2 || page[0].Key != "a" || page[1].Key != "b" {
+		t.Errorf("incorrect first page: %+v", page)
+	}
+	if next != "b" {
+		t.Errorf("incorrect next cursor: got %q want %q", next, "b")
+	}
+
+	// Everything fits, so there is no next page.
+	if page, next := paginate(entries, 3); len(page) != 3 || next != "" {
+		t.Errorf("expected a single full page, got %+v (next %q)", page, next)
+	}
+}
@@ -0,0 +1,110 @@
+package worker
+
+import (
+	"cmp"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	// unhandledWindow is how far back UnhandledTracker.Report looks.
+	unhandledWindow = 24 * time.Hour
+	// unhandledLogInterval is the minimum time between log lines for one
+	// event type.
+	unhandledLogInterval = time.Minute
+)
+
+// UnhandledType summarizes the unhandled events of one type in the last 24h.
+type UnhandledType struct {
+	EventType string    `json:"event_type"`
+	Count     int       `json:"count"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// UnhandledTracker counts events that no handler matched, in hourly buckets
+// covering the last 24 hours, and rate-limits the log line for each type.
+type UnhandledTracker struct {
+	mu    sync.Mutex
+	types map[string]*unhandledCounts
+}
+
+type unhandledCounts struct {
+	buckets    [24]unhandledBucket // Indexed by hour modulo 24.
+	lastSeen   time.Time
+	lastLogged time.Time
+	suppressed int // Occurrences not logged since lastLogged.
+}
+
+type unhandledBucket struct {
+	hour  int64 // Hours since the Unix epoch.
+	count int
+}
+
+// NewUnhandledTracker creates an empty UnhandledTracker.
+func NewUnhandledTracker() *UnhandledTracker {
+	return &UnhandledTracker{types: make(map[string]*unhandledCounts)}
+}
+
+// Observe counts an unhandled event of eventType seen at now. The first
+// occurrence per type per minute is logged; the ones in between are
+// reported as suppressed in the next log line.
+func (t *UnhandledTracker) Observe(logger *slog.Logger, eventType string, now time.Time) {
+	unhandledEvents.WithLabelValues(eventType).Inc()
+
+	t.mu.Lock()
+	c, found := t.types[eventType]
+	if !found {
+		c = &unhandledCounts{}
+		t.types[eventType] = c
+	}
+	hour := now.Unix() / 3600
+	b := &c.buckets[hour%24]
+	if b.hour != hour {
+		*b = unhandledBucket{hour: hour}
+	}
+	b.count++
+	c.lastSeen = now
+
+	shouldLog := now.Sub(c.lastLogged) >= unhandledLogInterval
+	suppressed := c.suppressed
+	if shouldLog {
+		c.lastLogged = now
+		c.suppressed = 0
+	} else {
+		c.suppressed++
+	}
+	t.mu.Unlock()
+
+	if shouldLog {
+		logger.Warn("No handler for event type, skipping event", "event_type", eventType, "suppressed", suppressed)
+	}
+}
+
+// Report returns the event types seen in the 24 hours before now, most
+// frequent first.
+func (t *UnhandledTracker) Report(now time.Time) []UnhandledType {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	oldest := (now.Unix() - int64(unhandledWindow/time.Second)) / 3600
+	report := []UnhandledType{}
+	for eventType, c := range t.types {
+		total := 0
+		for _, b := range c.buckets {
+			if b.hour > oldest {
+				total += b.count
+			}
+		}
+		if total > 0 {
+			report = append(report, UnhandledType{EventType: eventType, Count: total, LastSeen: c.lastSeen})
+		} else {
+			delete(t.types, eventType) // Nothing left in the window.
+		}
+	}
+	slices.SortFunc(report, func(a, b UnhandledType) int {
+		return cmp.Or(cmp.Compare(b.Count, a.Count), cmp.Compare(a.EventType, b.EventType))
+	})
+	return report
+}
@@ -0,0 +1,86 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"gusto-webhook-guide/internal/models"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUnhandledTrackerReport(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	tracker := NewUnhandledTracker()
+	now := time.Date(2025, 6, 2, 12, 30, 0, 0, time.UTC)
+
+	tracker.Observe(logger, "payroll.cancelled", now.Add(-25*time.Hour)) // Outside the window.
+	tracker.Observe(logger, "payroll.cancelled", now.Add(-2*time.Hour))
+	for range 3 {
+		tracker.Observe(logger, "employee.terminated", now.Add(-time.Minute))
+	}
+
+	report := tracker.Report(now)
+	want := []UnhandledType{
+		{EventType: "employee.terminated", Count: 3, LastSeen: now.Add(-time.Minute)},
+		{EventType: "payroll.cancelled", Count: 1, LastSeen: now.Add(-2 * time.Hour)},
+	}
+	if fmt.Sprint(report) != fmt.Sprint(want) {
+		t.Errorf("incorrect report: got %v want %v", report, want)
+	}
+
+	// A day later everything has aged out.
+	if report := tracker.Report(now.Add(unhandledWindow)); len(report) != 0 {
+		t.Errorf("Expected empty report after the window, got %v", report)
+	}
+}
+
+func TestUnhandledTrackerRateLimitsLogs(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	tracker := NewUnhandledTracker()
+	now := time.Now()
+
+	for i := range 5 {
+		tracker.Observe(logger, "payroll.cancelled", now.Add(time.Duration(i)*time.Second))
+	}
+	tracker.Observe(logger, "payroll.cancelled", now.Add(unhandledLogInterval))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("incorrect number of log lines: got %d want 2", len(lines))
+	}
+	var last struct{ Suppressed int }
+	json.Unmarshal([]byte(lines[1]), &last)
+	if last.Suppressed != 4 {
+		t.Errorf("incorrect suppressed count: got %d want 4", last.Suppressed)
+	}
+}
+
+func TestWorkerSkipsUnhandledEvent(t *testing.T) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	idempotencyStore := NewIdempotencyStore()
+	processor := ProcessorFunc(func(ctx context.Context, event models.WebhookEvent) error {
+		return fmt.Errorf("%w: %s", ErrUnhandled, event.EventType)
+	})
+	pool := NewPool(1, 1, logger, idempotencyStore, processor)
+
+	payloadBytes, _ := json.Marshal(models.WebhookEvent{UUID: "unhandled-uuid", EventType: "payroll.cancelled"})
+	pool.Start(1)
+	pool.JobQueue <- models.Job{Payload: payloadBytes}
+	close(pool.JobQueue)
+	pool.wg.Wait()
+
+	rec, _, _ := idempotencyStore.Get(context.Background(), "unhandled-uuid")
+	if rec.Status != StatusSucceeded {
+		t.Errorf("incorrect status: got %q want %q", rec.Status, StatusSucceeded)
+	}
+	report := pool.Unhandled().Report(time.Now())
+	if len(report) != 1 || report[0].EventType != "payroll.cancelled" || report[0].Count != 1 {
+		t.Errorf("incorrect report: got %v", report)
+	}
+}